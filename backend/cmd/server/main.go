@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,28 +20,103 @@ import (
 	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/config"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/drift"
+	"multi-avatar-chat/internal/expiry"
+	"multi-avatar-chat/internal/jobs"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/notify"
+	"multi-avatar-chat/internal/offline"
+	"multi-avatar-chat/internal/repl"
+	"multi-avatar-chat/internal/seed"
+	"multi-avatar-chat/internal/suggest"
+	"multi-avatar-chat/internal/threadgc"
+	"multi-avatar-chat/internal/tracing"
 	"multi-avatar-chat/internal/watcher"
 )
 
 func main() {
+	// --seed <name> populates the database with a ready-made dataset and exits
+	seedName := flag.String("seed", "", "populate the database with a dataset and exit (available: "+strings.Join(seed.Names(), ", ")+")")
+	// --repl chats in a conversation from the terminal while the server runs
+	replMode := flag.Bool("repl", false, "start a terminal chat alongside the server")
+	replConversation := flag.Int64("conversation", 0, "conversation ID for --repl (chosen interactively when omitted)")
+	// --record stores API requests and responses for reproducing reported bugs
+	record := flag.Bool("record", false, "record API requests and responses, downloadable from /api/admin/captures")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Printf("Warning: Failed to load config: %v (continuing without OpenAI)", err)
 		cfg = &config.Config{
-			DBPath:    getEnvOrDefault("DB_PATH", "data/app.db"),
-			StaticDir: getEnvOrDefault("STATIC_DIR", "static"),
+			DBPath:      getEnvOrDefault("DB_PATH", "data/app.db"),
+			StaticDir:   getEnvOrDefault("STATIC_DIR", "static"),
+			SettingsDir: getEnvOrDefault("SETTINGS_DIR", "settings"),
 		}
 	}
 
+	// Export OpenTelemetry traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Printf("Warning: Failed to set up tracing: %v (continuing without tracing)", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+
 	// Ensure data directory exists
 	dbDir := filepath.Dir(cfg.DBPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
-	// Initialize database
-	database, err := db.NewDB(cfg.DBPath)
+	// Keep server logs out of the terminal chat
+	if *replMode {
+		logPath := filepath.Join(dbDir, "repl.log")
+		logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open REPL log file: %v", err)
+		}
+		defer logFile.Close()
+		log.Printf("Server logs are written to %s", logPath)
+		log.SetOutput(logFile)
+	}
+
+	// Initialize database (encrypted with SQLCipher when a key is configured)
+	encryptionKey, err := config.LoadDBEncryptionKey()
+	if err != nil {
+		log.Fatalf("Failed to load database encryption key: %v", err)
+	}
+	if encryptionKey != "" && db.IsMemoryPath(cfg.DBPath) {
+		log.Printf("Warning: DB_PATH=%s is never written to disk, ignoring the encryption key", db.MemoryPath)
+		encryptionKey = ""
+	}
+
+	// DB_EPHEMERAL=true deletes the database files on shutdown, so an ephemeral demo leaves nothing behind
+	// An in-memory database (DB_PATH=:memory:) is always ephemeral
+	ephemeral := db.IsMemoryPath(cfg.DBPath)
+	if v := os.Getenv("DB_EPHEMERAL"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			ephemeral = ephemeral || enabled
+		} else {
+			log.Printf("Warning: invalid DB_EPHEMERAL=%q, using default false", v)
+		}
+	}
+	if ephemeral {
+		log.Printf("Ephemeral database: all state is wiped on shutdown path=%s", cfg.DBPath)
+		// Runs after the deferred Close below
+		defer func() {
+			if err := db.RemoveFiles(cfg.DBPath); err != nil {
+				log.Printf("Warning: failed to wipe ephemeral database path=%s err=%v", cfg.DBPath, err)
+			}
+		}()
+	}
+
+	var database *db.DB
+	if encryptionKey != "" {
+		database, err = db.NewEncryptedDB(cfg.DBPath, encryptionKey)
+	} else {
+		database, err = db.NewDB(cfg.DBPath)
+	}
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
@@ -47,15 +128,111 @@ func main() {
 	}
 	log.Println("Database migrated successfully")
 
+	// DB_CACHE_TTL sets how long avatar and participant lookups are cached (default 10s, "0" disables)
+	if ttlStr := os.Getenv("DB_CACHE_TTL"); ttlStr != "" {
+		if d, err := time.ParseDuration(ttlStr); err == nil && d >= 0 {
+			database.SetCacheTTL(d)
+		} else {
+			log.Printf("Warning: invalid DB_CACHE_TTL=%q, using default %v", ttlStr, db.DefaultCacheTTL)
+		}
+	}
+
+	// DB_SLOW_QUERY_THRESHOLD logs statements and lock waits taking longer (default 100ms, "0" disables)
+	if v := os.Getenv("DB_SLOW_QUERY_THRESHOLD"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			database.SetSlowQueryThreshold(d)
+		} else {
+			log.Printf("Warning: invalid DB_SLOW_QUERY_THRESHOLD=%q, using default %v", v, db.DefaultSlowQueryThreshold)
+		}
+	}
+
+	// MENTION_START_CHARS and MENTION_CHARS set the regexp character classes of unquoted @mention names
+	// (default \p{L} for the first character and \p{L}\p{N}_ for the rest)
+	mentionStartChars := getEnvOrDefault("MENTION_START_CHARS", logic.DefaultMentionStartChars)
+	mentionChars := getEnvOrDefault("MENTION_CHARS", logic.DefaultMentionChars)
+	if err := logic.ConfigureMentions(mentionStartChars, mentionChars); err != nil {
+		log.Printf("Warning: invalid MENTION_START_CHARS=%q or MENTION_CHARS=%q, using defaults: %v",
+			mentionStartChars, mentionChars, err)
+	}
+
+	// settings/safety.yaml sets a preamble prepended to every assistant's instructions and to judgment prompts
+	// The builder is passed to everything that creates assistants or judges messages
+	var instructions logic.InstructionsBuilder
+	if preamble, err := config.LoadSafetyPreamble(cfg.SettingsDir); err == nil {
+		instructions = logic.NewInstructionsBuilder(preamble)
+		log.Printf("Safety preamble loaded length=%d", len(preamble))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: failed to load safety preamble: %v (running without a preamble)", err)
+	}
+
 	// Initialize OpenAI client (optional)
 	var assistantClient *assistant.Client
 	if cfg.OpenAI.APIKey != "" {
-		assistantClient = assistant.NewClient(cfg.OpenAI.APIKey)
-		log.Println("OpenAI client initialized")
+		// OPENAI_BREAKER_THRESHOLD and OPENAI_BREAKER_COOLDOWN tune the circuit breaker (default 5 failures, 30s)
+		threshold := assistant.DefaultFailureThreshold
+		if v := os.Getenv("OPENAI_BREAKER_THRESHOLD"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				threshold = n
+			}
+		}
+		coolDown := assistant.DefaultCoolDown
+		if v := os.Getenv("OPENAI_BREAKER_COOLDOWN"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				coolDown = d
+			}
+		}
+		// JUDGMENT_MODEL sets the model used to decide whether avatars respond (default gpt-4o-mini)
+		judgmentModel := getEnvOrDefault("JUDGMENT_MODEL", assistant.DefaultJudgmentModel)
+		// OPENAI_RUN_TIMEOUT, OPENAI_ACTIVE_RUN_TIMEOUT, OPENAI_POLL_INTERVAL and OPENAI_MAX_POLL_INTERVAL
+		// tune how runs are waited for (default 30s, 30s, 500ms and 1s)
+		timeouts, err := config.LoadOpenAITimeouts()
+		if err != nil {
+			log.Printf("Warning: ignoring invalid OpenAI timeouts, using defaults for them: %v", err)
+		}
+		assistantClient = assistant.NewClient(cfg.OpenAI.APIKey,
+			assistant.WithCircuitBreaker(threshold, coolDown),
+			assistant.WithJudgmentModel(judgmentModel),
+			assistant.WithOpenAIProject(cfg.OpenAI.Organization, cfg.OpenAI.Project),
+			assistant.WithTimeouts(assistant.Timeouts{
+				Run:             timeouts.RunTimeout,
+				ActiveRun:       timeouts.ActiveRunTimeout,
+				PollInterval:    timeouts.PollInterval,
+				MaxPollInterval: timeouts.MaxPollInterval,
+			}))
+		log.Printf("OpenAI client initialized judgment_model=%s breaker_threshold=%d breaker_cooldown=%v run_timeout=%v active_run_timeout=%v organization=%q project=%q",
+			judgmentModel, threshold, coolDown, assistantClient.RunTimeout(), assistantClient.ActiveRunTimeout(),
+			assistantClient.Organization(), assistantClient.Project())
+
+		// Skip content already added to a thread within FORWARD_DEDUP_WINDOW (e.g. "10m"; "0" disables)
+		// so retries and overlapping forwards do not repeat a message in an avatar's context
+		dedupWindow := assistant.DefaultForwardDedupWindow
+		if v := os.Getenv("FORWARD_DEDUP_WINDOW"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				dedupWindow = d
+			} else {
+				log.Printf("Warning: invalid FORWARD_DEDUP_WINDOW=%q, using default %v", v, assistant.DefaultForwardDedupWindow)
+			}
+		}
+		if dedupWindow > 0 {
+			assistantClient.ForwardQueue().SetLedger(database, dedupWindow)
+		}
 	} else {
 		log.Println("Warning: OpenAI API key not configured, assistant features disabled")
 	}
 
+	if *seedName != "" {
+		if ephemeral {
+			log.Fatalf("Cannot seed an ephemeral database: it would be wiped right after seeding")
+		}
+		result, err := seed.Run(database, assistantClient, instructions, *seedName)
+		if err != nil {
+			log.Fatalf("Failed to seed database: %v", err)
+		}
+		log.Printf("Database seeded seed=%s avatars_created=%d conversations_created=%d",
+			*seedName, result.AvatarsCreated, result.ConversationsCreated)
+		return
+	}
+
 	// Initialize WatcherManager
 	// Default: 0 means random interval (5-20 seconds) for natural responses
 	// Set WATCHER_INTERVAL environment variable for fixed interval (e.g., "10s" for testing)
@@ -66,14 +243,272 @@ func main() {
 		}
 	}
 	watcherManager := watcher.NewManager(database, assistantClient, watcherInterval)
+	watcherManager.SetInstructions(instructions)
+
+	// Set JUDGMENT_MODE=batch to judge all avatars of a conversation with one LLM call per message
+	if os.Getenv("JUDGMENT_MODE") == "batch" {
+		watcherManager.SetBatchJudgment(true)
+		log.Printf("Batched judgment enabled")
+	}
+	// JUDGMENT_CACHE_TTL sets how long an avatar's judgment of an identical prompt is reused (default 5m, "0" disables)
+	if v := os.Getenv("JUDGMENT_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			watcherManager.SetJudgmentCacheTTL(d)
+		} else {
+			log.Printf("Warning: invalid JUDGMENT_CACHE_TTL=%q, using default %v", v, watcher.DefaultJudgmentCacheTTL)
+		}
+	}
+	// AVATAR_LOOP_LIMIT is how many avatar messages in a row avatars may exchange before waiting for the user (0 disables)
+	if v := os.Getenv("AVATAR_LOOP_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			watcherManager.SetLoopLimit(n)
+		} else {
+			log.Printf("Warning: invalid AVATAR_LOOP_LIMIT=%q, using default %d", v, watcher.DefaultLoopLimit)
+		}
+	}
 	if watcherInterval == 0 {
 		log.Printf("WatcherManager initialized with random interval (5-20 seconds)")
 	} else {
 		log.Printf("WatcherManager initialized with fixed interval=%v", watcherInterval)
 	}
 
+	// Initialize email notifications (optional)
+	// Requires settings/secrets/smtp.yaml; NOTIFY_IDLE_AFTER sets when unanswered questions are reported (default 30m)
+	var notifyService *notify.Service
+	if smtpCfg, err := config.LoadSMTPConfig(cfg.SettingsDir); err == nil {
+		idleAfter := 30 * time.Minute
+		if idleStr := os.Getenv("NOTIFY_IDLE_AFTER"); idleStr != "" {
+			if d, err := time.ParseDuration(idleStr); err == nil && d > 0 {
+				idleAfter = d
+			}
+		}
+		notifyService = notify.NewService(database, notify.NewSMTPMailer(smtpCfg), idleAfter)
+		watcherManager.SetMentionNotifier(notifyService)
+		notifyService.Start()
+		log.Printf("Email notifications enabled smtp_host=%s", smtpCfg.Host)
+	} else {
+		log.Printf("Email notifications disabled: %v", err)
+	}
+
 	// Create router (これによりbroadcasterがWatcherManagerに設定される)
 	router := api.NewRouter(database, assistantClient, cfg.StaticDir, watcherManager)
+	router.SetInstructions(instructions)
+
+	// settings/redaction.yaml lists terms redacted from user messages of conversations with a redaction policy
+	if terms, err := config.LoadRedactionTerms(cfg.SettingsDir); err == nil {
+		router.SetRedactor(logic.NewRedactor(terms))
+		log.Printf("Redaction terms loaded count=%d", len(terms))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: failed to load redaction terms: %v (redacting without terms)", err)
+	}
+
+	// SSE_BUFFER_SIZE and SSE_OVERFLOW_POLICY control how slow SSE clients are handled
+	bufferSize := api.DefaultClientBufferSize
+	if v := os.Getenv("SSE_BUFFER_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			bufferSize = n
+		} else {
+			log.Printf("Warning: invalid SSE_BUFFER_SIZE=%q, using default %d", v, api.DefaultClientBufferSize)
+		}
+	}
+	overflowPolicy := api.OverflowDropOldest
+	if v := os.Getenv("SSE_OVERFLOW_POLICY"); v != "" {
+		if p, ok := api.ParseOverflowPolicy(v); ok {
+			overflowPolicy = p
+		} else {
+			log.Printf("Warning: invalid SSE_OVERFLOW_POLICY=%q, using default %s", v, api.OverflowDropOldest)
+		}
+	}
+	router.GetBroadcaster().SetOverflow(bufferSize, overflowPolicy)
+
+	// SSE_REPLAY_BUFFER_SIZE sets how many events per conversation are kept for clients resuming with Last-Event-ID
+	if v := os.Getenv("SSE_REPLAY_BUFFER_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			router.GetBroadcaster().SetReplayBufferSize(n)
+		} else {
+			log.Printf("Warning: invalid SSE_REPLAY_BUFFER_SIZE=%q, using default %d", v, api.DefaultReplayBufferSize)
+		}
+	}
+
+	// SSE_HEARTBEAT_INTERVAL sets how often a ": ping" comment is written to SSE streams so reverse proxies
+	// do not close idle connections; 0 disables heartbeats
+	if v := os.Getenv("SSE_HEARTBEAT_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			router.SetSSEHeartbeat(d)
+		} else {
+			log.Printf("Warning: invalid SSE_HEARTBEAT_INTERVAL=%q, using default %v", v, api.DefaultHeartbeatInterval)
+		}
+	}
+
+	// SSE_VIEWER_COUNT=false disables viewer_count events (enabled by default)
+	viewerCount := true
+	if v := os.Getenv("SSE_VIEWER_COUNT"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			viewerCount = enabled
+		} else {
+			log.Printf("Warning: invalid SSE_VIEWER_COUNT=%q, using default %t", v, viewerCount)
+		}
+	}
+	router.GetBroadcaster().SetViewerCountEvents(viewerCount)
+
+	// FORWARD_CONCURRENCY bounds how many avatar threads a user message is written to at once
+	if v := os.Getenv("FORWARD_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			router.SetForwardConcurrency(n)
+		} else {
+			log.Printf("Warning: invalid FORWARD_CONCURRENCY=%q, using default %d", v, api.DefaultForwardConcurrency)
+		}
+	}
+
+	// AVATAR_RENAME_NOTICES=false stops posting a system message when an avatar is renamed (enabled by default)
+	if v := os.Getenv("AVATAR_RENAME_NOTICES"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			router.SetRenameNotices(enabled)
+		} else {
+			log.Printf("Warning: invalid AVATAR_RENAME_NOTICES=%q, using default true", v)
+		}
+	}
+
+	// BEHAVIOR_WEBHOOK_ALLOWED_HOSTS lists the hosts (comma-separated) behavior script webhooks may call
+	// even though they are local or private, such as an internal automation server
+	if v := os.Getenv("BEHAVIOR_WEBHOOK_ALLOWED_HOSTS"); v != "" {
+		var hosts []string
+		for _, host := range strings.Split(v, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		router.SetBehaviorWebhookHosts(hosts)
+		watcherManager.SetBehaviorWebhookHosts(hosts)
+	}
+
+	// MAX_CONVERSATION_AVATARS bounds how many avatars a conversation can have
+	if v := os.Getenv("MAX_CONVERSATION_AVATARS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			router.SetMaxConversationAvatars(n)
+		} else {
+			log.Printf("Warning: invalid MAX_CONVERSATION_AVATARS=%q, using default %d", v, api.DefaultMaxConversationAvatars)
+		}
+	}
+
+	// SEND_MESSAGE_TIMEOUT bounds how long sending a message waits for avatar threads before answering 202
+	if v := os.Getenv("SEND_MESSAGE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			router.SetSendTimeout(d)
+		} else {
+			log.Printf("Warning: invalid SEND_MESSAGE_TIMEOUT=%q, using default %v", v, api.DefaultSendTimeout)
+		}
+	}
+
+	// RATE_LIMIT_MESSAGES, RATE_LIMIT_AVATARS and their _PER_TOKEN variants limit message sending and avatar
+	// creation per client IP and per bearer token (e.g. "30/1m", "off" disables a limit)
+	// RATE_LIMIT_TRUST_PROXY=true takes the client IP from the last X-Forwarded-For entry
+	rateLimits := api.DefaultRateLimits()
+	for env, limit := range map[string]*api.RateLimit{
+		"RATE_LIMIT_MESSAGES":           &rateLimits.MessagesPerIP,
+		"RATE_LIMIT_MESSAGES_PER_TOKEN": &rateLimits.MessagesPerToken,
+		"RATE_LIMIT_AVATARS":            &rateLimits.AvatarsPerIP,
+		"RATE_LIMIT_AVATARS_PER_TOKEN":  &rateLimits.AvatarsPerToken,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if l, ok := api.ParseRateLimit(v); ok {
+				*limit = l
+			} else {
+				log.Printf("Warning: invalid %s=%q, using default %v", env, v, *limit)
+			}
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_TRUST_PROXY"); v != "" {
+		if trust, err := strconv.ParseBool(v); err == nil {
+			rateLimits.TrustProxy = trust
+		} else {
+			log.Printf("Warning: invalid RATE_LIMIT_TRUST_PROXY=%q, using default false", v)
+		}
+	}
+	router.SetRateLimits(rateLimits)
+	log.Printf("Rate limits messages=%v messages_per_token=%v avatars=%v avatars_per_token=%v trust_proxy=%t",
+		rateLimits.MessagesPerIP, rateLimits.MessagesPerToken, rateLimits.AvatarsPerIP, rateLimits.AvatarsPerToken, rateLimits.TrustProxy)
+
+	// --record keeps the newest HTTP_RECORD_LIMIT captures (default 500)
+	if *record {
+		keep := api.DefaultCaptureLimit
+		if v := os.Getenv("HTTP_RECORD_LIMIT"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				keep = n
+			} else {
+				log.Printf("Warning: invalid HTTP_RECORD_LIMIT=%q, using default %d", v, api.DefaultCaptureLimit)
+			}
+		}
+		router.SetCapture(keep)
+		log.Printf("Recording API requests and responses keep=%d (download from /api/admin/captures)", keep)
+	}
+
+	// Run long operations (thread imports, on-demand digests) as background jobs
+	// JOB_WORKERS bounds how many jobs run at the same time
+	jobWorkers := jobs.DefaultWorkers
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			jobWorkers = n
+		} else {
+			log.Printf("Warning: invalid JOB_WORKERS=%q, using default %d", v, jobWorkers)
+		}
+	}
+	jobRunner := jobs.NewRunner(database, jobWorkers)
+	router.SetJobRunner(jobRunner)
+
+	// Queue user messages while the OpenAI API is unavailable and replay them after recovery
+	offlineQueue := offline.NewQueue(database, assistantClient)
+	router.SetOfflineQueue(offlineQueue)
+	offlineQueue.Start()
+
+	// WATCHER_HIBERNATE_AFTER stops the watchers of conversations without messages for that long (e.g. "24h");
+	// they restart when a message is sent or a client subscribes to the conversation's events
+	var hibernateAfter time.Duration
+	if v := os.Getenv("WATCHER_HIBERNATE_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			hibernateAfter = d
+			watcherManager.SetIdleHibernation(d)
+		} else {
+			log.Printf("Warning: invalid WATCHER_HIBERNATE_AFTER=%q, hibernation disabled", v)
+		}
+	}
+
+	// WATCHER_LAZY_START=true starts the watchers of a conversation only when it is first used,
+	// so startup time does not grow with the number of conversations (disabled by default)
+	if v := os.Getenv("WATCHER_LAZY_START"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			watcherManager.SetLazyStart(enabled)
+		} else {
+			log.Printf("Warning: invalid WATCHER_LAZY_START=%q, using default false", v)
+		}
+	}
+
+	// STREAM_RESPONSES=false sends avatar responses only once they are complete (streamed by default)
+	if v := os.Getenv("STREAM_RESPONSES"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err != nil {
+			log.Printf("Warning: invalid STREAM_RESPONSES=%q, using default true", v)
+		} else if !enabled {
+			watcherManager.SetResponseStreamer(nil)
+		}
+	}
+
+	// TIME_AWARENESS=false leaves the current time out of the run instructions;
+	// TIME_AWARENESS_ZONE sets the time zone avatars are told the time in (server local time by default)
+	// Watchers copy the setting when they start, so it must be set before InitializeAll below
+	if v := os.Getenv("TIME_AWARENESS_ZONE"); v != "" {
+		if location, err := time.LoadLocation(v); err != nil {
+			log.Printf("Warning: invalid TIME_AWARENESS_ZONE=%q, using local time: %v", v, err)
+		} else {
+			watcherManager.SetTimeAwareness(location)
+		}
+	}
+	if v := os.Getenv("TIME_AWARENESS"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err != nil {
+			log.Printf("Warning: invalid TIME_AWARENESS=%q, using default true", v)
+		} else if !enabled {
+			watcherManager.SetTimeAwareness(nil)
+		}
+	}
 
 	// Initialize all watchers for existing conversations
 	// 注意: NewRouterの後に呼ぶことで、broadcasterが設定された状態でウォッチャーが作成される
@@ -81,15 +516,138 @@ func main() {
 	if err := watcherManager.InitializeAll(ctx); err != nil {
 		log.Printf("Warning: Failed to initialize watchers: %v", err)
 	}
-	log.Printf("Watchers initialized: count=%d", watcherManager.WatcherCount())
+	log.Printf("Watchers initialized: count=%d hibernated_conversations=%d",
+		watcherManager.WatcherCount(), watcherManager.HibernatedCount())
+	if hibernateAfter > 0 {
+		watcherManager.StartHibernation(min(watcher.DefaultHibernationInterval, hibernateAfter))
+		log.Printf("Idle conversation hibernation enabled hibernate_after=%v", hibernateAfter)
+	}
+
+	// Cancel runs stuck in progress so they do not block avatar threads forever
+	// RUN_MAX_DURATION sets how long a run may stay active, RUN_REAPER_INTERVAL how often runs are checked
+	reaper := watcher.NewReaper(watcherManager, database, assistantClient)
+	reaper.SetNotifier(router.GetBroadcaster())
+	if v := os.Getenv("RUN_MAX_DURATION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			reaper.SetMaxDuration(d)
+		} else {
+			log.Printf("Warning: invalid RUN_MAX_DURATION=%q, using default %v", v, watcher.DefaultMaxRunDuration)
+		}
+	}
+	if v := os.Getenv("RUN_REAPER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			reaper.SetInterval(d)
+		} else {
+			log.Printf("Warning: invalid RUN_REAPER_INTERVAL=%q, using default %v", v, watcher.DefaultReapInterval)
+		}
+	}
+	reaper.Start()
+
+	// Retry deleting OpenAI threads of deleted conversations that could not be deleted right away
+	// THREAD_GC_INTERVAL sets how often they are retried
+	threadCollector := threadgc.NewCollector(database, assistantClient)
+	if v := os.Getenv("THREAD_GC_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			threadCollector.SetInterval(d)
+		} else {
+			log.Printf("Warning: invalid THREAD_GC_INTERVAL=%q, using default %v", v, threadgc.DefaultInterval)
+		}
+	}
+	threadCollector.Start()
+
+	// Compare every avatar's assistant with its prompt to catch edits made in the OpenAI dashboard
+	// ASSISTANT_DRIFT_INTERVAL sets how often the assistants are checked
+	driftDetector := drift.NewDetector(database, assistantClient)
+	driftDetector.SetInstructions(instructions)
+	if v := os.Getenv("ASSISTANT_DRIFT_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			driftDetector.SetInterval(d)
+		} else {
+			log.Printf("Warning: invalid ASSISTANT_DRIFT_INTERVAL=%q, using default %v", v, drift.DefaultInterval)
+		}
+	}
+	driftDetector.Start()
+
+	// Delete conversations whose TTL has passed, notifying every connected client first
+	// DEFAULT_CONVERSATION_TTL (e.g. "24h") sets the TTL of conversations created without one
+	// CONVERSATION_EXPIRY_INTERVAL sets how often expired conversations are looked for
+	if v := os.Getenv("DEFAULT_CONVERSATION_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			router.SetDefaultConversationTTL(d)
+		} else {
+			log.Printf("Warning: invalid DEFAULT_CONVERSATION_TTL=%q, conversations do not expire by default", v)
+		}
+	}
+	expiryJob := expiry.NewJob(database, assistantClient, watcherManager)
+	expiryJob.SetNotifier(router.GetBroadcaster())
+	if v := os.Getenv("CONVERSATION_EXPIRY_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			expiryJob.SetInterval(d)
+		} else {
+			log.Printf("Warning: invalid CONVERSATION_EXPIRY_INTERVAL=%q, using default %v", v, expiry.DefaultInterval)
+		}
+	}
+	expiryJob.Start()
+
+	// Initialize daily digest job (optional)
+	// Set DIGEST_INTERVAL (e.g., "24h") to post periodic summaries to active conversations
+	// Set DIGEST_WEBHOOK_URL to also deliver each digest to a webhook
+	var digestJob *digest.Job
+	if intervalStr := os.Getenv("DIGEST_INTERVAL"); intervalStr != "" {
+		if d, err := time.ParseDuration(intervalStr); err == nil && d > 0 {
+			digestJob = digest.NewJob(database, assistantClient, d)
+			if webhookURL := os.Getenv("DIGEST_WEBHOOK_URL"); webhookURL != "" {
+				digestJob.AddNotifier(digest.NewWebhookNotifier(webhookURL))
+			}
+			if notifyService != nil {
+				digestJob.AddNotifier(notifyService)
+			}
+			router.SetDigestJob(digestJob)
+			digestJob.Start()
+		} else {
+			log.Printf("Warning: invalid DIGEST_INTERVAL=%q, digest job disabled", intervalStr)
+		}
+	}
+
+	// Set SUGGESTIONS_LULL (e.g., "30s") to suggest replies to the user once a watched conversation
+	// has been quiet for that long
+	var suggestJob *suggest.Job
+	if v := os.Getenv("SUGGESTIONS_LULL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			suggestJob = suggest.NewJob(database, assistantClient, d)
+			router.SetSuggestionJob(suggestJob)
+			suggestJob.Start()
+		} else {
+			log.Printf("Warning: invalid SUGGESTIONS_LULL=%q, suggestions disabled", v)
+		}
+	}
+
+	// Start jobs once every handler is registered so queued jobs from a previous run resume
+	jobRunner.Start()
+
+	// TLS_CERT_FILE/TLS_KEY_FILE or TLS_AUTOCERT_HOSTS serve HTTPS (and HTTP/2) without a reverse proxy
+	// TLS_REDIRECT_ADDR is the plain HTTP listener redirecting to HTTPS (default :80, "off" disables it)
+	tlsCfg, err := config.LoadTLSConfig()
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
 
 	// Setup server
-	port := getEnvOrDefault("PORT", "8080")
+	defaultPort := "8080"
+	if tlsCfg.Mode != config.TLSModeOff {
+		defaultPort = "443"
+	}
+	port := getEnvOrDefault("PORT", defaultPort)
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: router,
 	}
 
+	var redirectServer *http.Server
+	if tlsCfg.Mode != config.TLSModeOff {
+		redirectServer = configureTLS(server, tlsCfg, port)
+	}
+
 	// Handle graceful shutdown
 	done := make(chan bool, 1)
 	quit := make(chan os.Signal, 1)
@@ -99,11 +657,42 @@ func main() {
 		<-quit
 		log.Println("Server is shutting down...")
 
-		// Shutdown watchers first
+		// Stop reaping before the watchers stop tracking their runs
+		reaper.Stop()
+		threadCollector.Stop()
+		driftDetector.Stop()
+		expiryJob.Stop()
+
+		// Shutdown watchers
 		if err := watcherManager.Shutdown(); err != nil {
 			log.Printf("Error shutting down watchers: %v", err)
 		}
 
+		// Stop offline replay (queued messages are kept in the database)
+		offlineQueue.Stop()
+
+		// Wait for running jobs (queued jobs are resumed on the next start)
+		jobRunner.Stop()
+
+		// Stop digest job
+		if digestJob != nil {
+			digestJob.Stop()
+		}
+
+		// Stop suggesting replies
+		if suggestJob != nil {
+			suggestJob.Stop()
+		}
+
+		// Stop email notifications
+		if notifyService != nil {
+			notifyService.Stop()
+		}
+
+		// Close SSE streams so browsers reconnect to the replacement instance
+		// Done right before the server stops listening so reconnects are not refused by this instance
+		router.GetBroadcaster().Shutdown(api.ShutdownReconnectDelay)
+
 		// Shutdown HTTP server with timeout
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -111,14 +700,47 @@ func main() {
 		if err := server.Shutdown(ctx); err != nil {
 			log.Fatalf("Server forced to shutdown: %v", err)
 		}
+		if redirectServer != nil {
+			redirectServer.Shutdown(ctx)
+		}
+
+		// Flush spans that have not been exported yet
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error shutting down tracing: %v", err)
+		}
 
 		close(done)
 	}()
 
-	log.Printf("Server starting on port %s", port)
+	log.Printf("Server starting on port %s tls=%s", port, tlsCfg.Mode)
+	if tlsCfg.Mode == config.TLSModeAutocert {
+		log.Printf("Let's Encrypt certificates enabled hosts=%s cache_dir=%s", strings.Join(tlsCfg.AutocertHosts, ","), tlsCfg.AutocertCacheDir)
+	}
 	log.Printf("Static files served from: %s", cfg.StaticDir)
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	// The REPL runs in the foreground and stops the server when it exits
+	if *replMode {
+		go func() {
+			chat := repl.New(database, router, router.GetBroadcaster(), os.Stdin, os.Stdout)
+			chat.SetColor(os.Getenv("NO_COLOR") == "")
+			if err := chat.Run(context.Background(), *replConversation); err != nil && err != io.EOF {
+				fmt.Fprintf(os.Stderr, "REPL error: %v\n", err)
+			}
+			quit <- syscall.SIGTERM
+		}()
+	}
+
+	if redirectServer != nil {
+		startRedirectServer(redirectServer)
+	}
+
+	if tlsCfg.Mode == config.TLSModeOff {
+		err = server.ListenAndServe()
+	} else {
+		// Autocert provides certificates through TLSConfig, so the file names are empty
+		err = server.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile)
+	}
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 
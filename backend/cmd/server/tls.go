@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"time"
+
+	"multi-avatar-chat/internal/api"
+	"multi-avatar-chat/internal/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// configureTLS prepares server for HTTPS according to cfg
+// HTTP/2 is negotiated automatically by net/http once TLS is on.
+// Returns the plain HTTP server redirecting to HTTPS, which also answers ACME challenges in autocert mode,
+// or nil when there is no redirect listener
+func configureTLS(server *http.Server, cfg config.TLSConfig, httpsPort string) *http.Server {
+	server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	redirect := api.HTTPSRedirectHandler(httpsPort)
+	if cfg.Mode == config.TLSModeAutocert {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertHosts...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	if cfg.RedirectAddr == "" {
+		return nil
+	}
+	return &http.Server{
+		Addr:              cfg.RedirectAddr,
+		Handler:           redirect,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// startRedirectServer runs the HTTP to HTTPS redirect listener in the background
+// A failure is logged without stopping the HTTPS server
+func startRedirectServer(server *http.Server) {
+	go func() {
+		log.Printf("HTTP to HTTPS redirect listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Warning: HTTP to HTTPS redirect failed: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/watcher"
+)
+
+// AdminHandler handles operator HTTP requests
+type AdminHandler struct {
+	db          *db.DB
+	assistant   *assistant.Client
+	broadcaster *EventBroadcaster
+	watcher     *watcher.WatcherManager
+	// instructions builds the assistant instructions synced to OpenAI
+	instructions logic.InstructionsBuilder
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(database *db.DB, assistantClient *assistant.Client) *AdminHandler {
+	return &AdminHandler{
+		db:        database,
+		assistant: assistantClient,
+	}
+}
+
+// SetBroadcaster sets the event broadcaster whose delivery stats are reported
+func (h *AdminHandler) SetBroadcaster(b *EventBroadcaster) {
+	h.broadcaster = b
+}
+
+// SetWatcherManager sets the watcher manager whose judgment cache stats are reported
+func (h *AdminHandler) SetWatcherManager(wm *watcher.WatcherManager) {
+	h.watcher = wm
+}
+
+// SetInstructions sets the builder of assistant instructions, which adds the safety preamble
+func (h *AdminHandler) SetInstructions(instructions logic.InstructionsBuilder) {
+	h.instructions = instructions
+}
+
+// ThreadQueueResponse groups pending forwards of a single thread
+type ThreadQueueResponse struct {
+	ThreadID string                  `json:"thread_id"`
+	Items    []assistant.ForwardItem `json:"items"`
+}
+
+// QueuesResponse represents the pending work per thread
+type QueuesResponse struct {
+	Total   int                   `json:"total"`
+	Failed  int                   `json:"failed"`
+	Threads []ThreadQueueResponse `json:"threads"`
+}
+
+// ListQueues handles GET /api/admin/queues
+func (h *AdminHandler) ListQueues(w http.ResponseWriter, r *http.Request) {
+	response := QueuesResponse{Threads: []ThreadQueueResponse{}}
+
+	if h.assistant != nil {
+		index := make(map[string]int)
+		for _, item := range h.assistant.ForwardQueue().List() {
+			i, ok := index[item.ThreadID]
+			if !ok {
+				i = len(response.Threads)
+				index[item.ThreadID] = i
+				response.Threads = append(response.Threads, ThreadQueueResponse{ThreadID: item.ThreadID})
+			}
+			response.Threads[i].Items = append(response.Threads[i].Items, item)
+
+			response.Total++
+			if item.Status == assistant.ForwardStatusFailed {
+				response.Failed++
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RetryQueueItem handles POST /api/admin/queues/items/{id}/retry
+// The retry runs in the background; poll ListQueues for the outcome
+func (h *AdminHandler) RetryQueueItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.assistant == nil {
+		http.Error(w, "Queue item not found", http.StatusNotFound)
+		return
+	}
+
+	switch err := h.assistant.ForwardQueue().Retry(id); err {
+	case nil:
+		log.Printf("[API] Queue item retry accepted item_id=%d", id)
+		recordAudit(h.db, r, models.AuditActionQueueItemRetry, "queue_item", strconv.FormatInt(id, 10), nil, nil)
+		w.WriteHeader(http.StatusAccepted)
+	case assistant.ErrForwardNotFound:
+		http.Error(w, "Queue item not found", http.StatusNotFound)
+	case assistant.ErrForwardInProgress:
+		http.Error(w, "Queue item is still in progress", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to retry queue item", http.StatusInternalServerError)
+	}
+}
+
+// DiscardQueueItem handles DELETE /api/admin/queues/items/{id}
+func (h *AdminHandler) DiscardQueueItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.assistant == nil {
+		http.Error(w, "Queue item not found", http.StatusNotFound)
+		return
+	}
+
+	switch err := h.assistant.ForwardQueue().Discard(id); err {
+	case nil:
+		log.Printf("[API] Queue item discarded item_id=%d", id)
+		recordAudit(h.db, r, models.AuditActionQueueItemDiscard, "queue_item", strconv.FormatInt(id, 10), nil, nil)
+		w.WriteHeader(http.StatusNoContent)
+	case assistant.ErrForwardNotFound:
+		http.Error(w, "Queue item not found", http.StatusNotFound)
+	case assistant.ErrForwardInProgress:
+		http.Error(w, "Queue item is still in progress", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to discard queue item", http.StatusInternalServerError)
+	}
+}
+
+// CacheStats handles GET /api/admin/cache
+func (h *AdminHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.db.CacheStats())
+}
+
+// QueryStats handles GET /api/admin/db/queries
+// Reports per-statement timings and waits for the database lock, costliest statements first
+func (h *AdminHandler) QueryStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.db.QueryStats())
+}
+
+// ResetQueryStats handles DELETE /api/admin/db/queries
+func (h *AdminHandler) ResetQueryStats(w http.ResponseWriter, r *http.Request) {
+	h.db.ResetQueryStats()
+	log.Printf("[API] Query stats reset")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JudgmentCacheStats handles GET /api/admin/judgment-cache
+func (h *AdminHandler) JudgmentCacheStats(w http.ResponseWriter, r *http.Request) {
+	var stats watcher.JudgmentCacheStats
+	if h.watcher != nil {
+		stats = h.watcher.JudgmentCacheStats()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// SSEStats handles GET /api/admin/sse
+// Reports connected clients and events dropped for slow clients
+func (h *AdminHandler) SSEStats(w http.ResponseWriter, r *http.Request) {
+	if h.broadcaster == nil {
+		http.Error(w, "Event broadcaster is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.broadcaster.Stats())
+}
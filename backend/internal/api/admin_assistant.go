@@ -0,0 +1,449 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// AdminAssistantResponse represents an OpenAI assistant and the local avatar linked to it
+type AdminAssistantResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Model      string   `json:"model"`
+	Tools      []string `json:"tools"`
+	AvatarID   *int64   `json:"avatar_id,omitempty"`
+	AvatarName string   `json:"avatar_name,omitempty"`
+	// Managed is true for assistants tagged as created by this application
+	Managed  bool              `json:"managed"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// AdminAssistantsResponse lists the assistants of the OpenAI account
+// UnlinkedAvatars are avatars whose assistant is not in the account
+type AdminAssistantsResponse struct {
+	Assistants      []AdminAssistantResponse `json:"assistants"`
+	UnlinkedAvatars []AvatarResponse         `json:"unlinked_avatars"`
+}
+
+// RelinkAssistantRequest represents the request body for linking an assistant to an avatar
+type RelinkAssistantRequest struct {
+	AvatarID int64 `json:"avatar_id"`
+}
+
+// ListAssistants handles GET /api/admin/assistants
+// managed=true lists only the assistants tagged as created by this application, managed=false only the others
+func (h *AdminHandler) ListAssistants(w http.ResponseWriter, r *http.Request) {
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var managedFilter *bool
+	if v := r.URL.Query().Get("managed"); v != "" {
+		managed, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid managed (must be true or false)", http.StatusBadRequest)
+			return
+		}
+		managedFilter = &managed
+	}
+
+	assistants, err := h.assistant.WithContext(r.Context()).ListAssistants()
+	if err != nil {
+		log.Printf("[API] ListAssistants failed: OpenAI error err=%v", err)
+		http.Error(w, "Failed to list OpenAI assistants", http.StatusBadGateway)
+		return
+	}
+
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		log.Printf("[API] ListAssistants failed: DB error getting avatars err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+
+	linked := make(map[string]*models.Avatar)
+	for i := range avatars {
+		if id := avatars[i].OpenAIAssistantID; id != "" && linked[id] == nil {
+			linked[id] = &avatars[i]
+		}
+	}
+
+	response := AdminAssistantsResponse{
+		Assistants:      []AdminAssistantResponse{},
+		UnlinkedAvatars: []AvatarResponse{},
+	}
+	inAccount := make(map[string]bool)
+	for _, a := range assistants {
+		inAccount[a.ID] = true
+		if managedFilter != nil && a.Metadata.IsApp() != *managedFilter {
+			continue
+		}
+		item := AdminAssistantResponse{
+			ID:       a.ID,
+			Name:     a.Name,
+			Model:    a.Model,
+			Tools:    []string{},
+			Managed:  a.Metadata.IsApp(),
+			Metadata: a.Metadata,
+		}
+		for _, tool := range a.Tools {
+			item.Tools = append(item.Tools, tool.Type)
+		}
+		if avatar := linked[a.ID]; avatar != nil {
+			item.AvatarID = &avatar.ID
+			item.AvatarName = avatar.Name
+		}
+		response.Assistants = append(response.Assistants, item)
+	}
+	for i := range avatars {
+		if !inAccount[avatars[i].OpenAIAssistantID] {
+			response.UnlinkedAvatars = append(response.UnlinkedAvatars, newAvatarResponse(&avatars[i]))
+		}
+	}
+
+	log.Printf("[API] ListAssistants completed assistant_count=%d unlinked_avatar_count=%d",
+		len(response.Assistants), len(response.UnlinkedAvatars))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ImportAssistant handles POST /api/admin/assistants/{assistant_id}/import
+// Creates a local avatar from an existing assistant, taking its name, instructions and tools
+func (h *AdminHandler) ImportAssistant(w http.ResponseWriter, r *http.Request) {
+	assistantID := r.PathValue("assistant_id")
+
+	existing, ok := h.getAccountAssistant(w, r, assistantID)
+	if !ok {
+		return
+	}
+
+	if avatar, err := h.findAvatarByAssistant(assistantID); err != nil {
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	} else if avatar != nil {
+		http.Error(w, "Assistant is already linked to an avatar", http.StatusConflict)
+		return
+	}
+
+	// Assistants created by this application carry the user priority instruction; keep only the prompt
+	prompt := logic.PromptFromInstructions(existing.Instructions)
+	if existing.Name == "" || prompt == "" {
+		http.Error(w, "Assistant must have a name and instructions to be imported", http.StatusBadRequest)
+		return
+	}
+
+	if err := checkAvatarName(h.db, existing.Name, 0); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	avatar, err := h.db.CreateAvatar(existing.Name, prompt, existing.ID)
+	if err != nil {
+		log.Printf("[API] ImportAssistant failed: DB error creating avatar assistant_id=%s err=%v", assistantID, err)
+		http.Error(w, "Failed to create avatar", http.StatusInternalServerError)
+		return
+	}
+
+	avatar.CanSearch, avatar.CanCode = toolCapabilities(existing.Tools)
+	if avatar.CanSearch || avatar.CanCode {
+		if err := h.db.UpdateAvatarCapabilities(avatar.ID, avatar.CanSearch, avatar.CanCode, avatar.CanCite); err != nil {
+			http.Error(w, "Failed to create avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tagAvatarAssistant(h.assistant.WithContext(r.Context()), assistantID, avatar.ID, existing.Metadata)
+
+	log.Printf("[API] Assistant imported assistant_id=%s avatar_id=%d", assistantID, avatar.ID)
+	recordAudit(h.db, r, models.AuditActionAvatarImport, "avatar", strconv.FormatInt(avatar.ID, 10),
+		nil, newAvatarResponse(avatar))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
+
+// RelinkAssistant handles POST /api/admin/assistants/{assistant_id}/relink
+// Links an existing assistant to an avatar, replacing the avatar's current assistant
+// The capability flags that map to tools are taken from the assistant
+func (h *AdminHandler) RelinkAssistant(w http.ResponseWriter, r *http.Request) {
+	assistantID := r.PathValue("assistant_id")
+
+	var req RelinkAssistantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AvatarID == 0 {
+		http.Error(w, "avatar_id is required", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.db.GetAvatar(req.AvatarID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	existing, ok := h.getAccountAssistant(w, r, assistantID)
+	if !ok {
+		return
+	}
+
+	if owner, err := h.findAvatarByAssistant(assistantID); err != nil {
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	} else if owner != nil && owner.ID != before.ID {
+		http.Error(w, "Assistant is already linked to another avatar", http.StatusConflict)
+		return
+	}
+
+	avatar, err := h.db.UpdateAvatar(before.ID, before.Name, before.Prompt, existing.ID)
+	if err != nil {
+		log.Printf("[API] RelinkAssistant failed: DB error updating avatar avatar_id=%d err=%v", before.ID, err)
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	avatar.CanSearch, avatar.CanCode = toolCapabilities(existing.Tools)
+	if avatar.CanSearch != before.CanSearch || avatar.CanCode != before.CanCode {
+		if err := h.db.UpdateAvatarCapabilities(avatar.ID, avatar.CanSearch, avatar.CanCode, avatar.CanCite); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tagAvatarAssistant(h.assistant.WithContext(r.Context()), assistantID, avatar.ID, existing.Metadata)
+
+	log.Printf("[API] Assistant relinked assistant_id=%s avatar_id=%d previous_assistant_id=%s",
+		assistantID, avatar.ID, before.OpenAIAssistantID)
+	recordAudit(h.db, r, models.AuditActionAvatarRelink, "avatar", strconv.FormatInt(avatar.ID, 10),
+		newAvatarResponse(before), newAvatarResponse(avatar))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
+
+// SyncInstructionsFailure describes an assistant whose instructions could not be updated
+type SyncInstructionsFailure struct {
+	AvatarID    int64  `json:"avatar_id"`
+	AssistantID string `json:"assistant_id"`
+	Error       string `json:"error"`
+}
+
+// SyncInstructionsResponse reports the result of re-syncing assistant instructions
+type SyncInstructionsResponse struct {
+	Synced int                       `json:"synced"`
+	Failed []SyncInstructionsFailure `json:"failed"`
+}
+
+// SyncInstructions handles POST /api/admin/assistants/sync-instructions
+// Rewrites the name and instructions of every avatar's assistant from the avatar, so assistants
+// updated before the instructions were built in one place get the user priority instruction back,
+// and existing assistants get the current safety preamble
+func (h *AdminHandler) SyncInstructions(w http.ResponseWriter, r *http.Request) {
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		log.Printf("[API] SyncInstructions failed: DB error getting avatars err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+
+	client := h.assistant.WithContext(r.Context())
+	response := SyncInstructionsResponse{Failed: []SyncInstructionsFailure{}}
+	for _, avatar := range avatars {
+		if avatar.OpenAIAssistantID == "" {
+			continue
+		}
+		if _, err := client.UpdateAssistant(avatar.OpenAIAssistantID, avatar.Name, h.instructions.AssistantInstructions(avatar.Prompt)); err != nil {
+			log.Printf("[API] SyncInstructions: failed to update assistant avatar_id=%d assistant_id=%s err=%v",
+				avatar.ID, avatar.OpenAIAssistantID, err)
+			response.Failed = append(response.Failed, SyncInstructionsFailure{
+				AvatarID:    avatar.ID,
+				AssistantID: avatar.OpenAIAssistantID,
+				Error:       err.Error(),
+			})
+			continue
+		}
+		if err := h.db.ClearAssistantDrift(avatar.ID); err != nil {
+			log.Printf("[API] Warning: failed to clear assistant drift avatar_id=%d err=%v", avatar.ID, err)
+		}
+		response.Synced++
+	}
+
+	log.Printf("[API] SyncInstructions completed synced=%d failed=%d", response.Synced, len(response.Failed))
+	recordAudit(h.db, r, models.AuditActionInstructionsSync, "assistants", "", nil, response)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AssistantDriftResponse describes an avatar whose assistant instructions were changed outside the application
+type AssistantDriftResponse struct {
+	AvatarID    int64  `json:"avatar_id"`
+	AvatarName  string `json:"avatar_name"`
+	AssistantID string `json:"assistant_id"`
+	// Instructions are the instructions found on the assistant, ExpectedInstructions the ones built from the avatar
+	Instructions         string    `json:"instructions"`
+	ExpectedInstructions string    `json:"expected_instructions"`
+	DetectedAt           time.Time `json:"detected_at"`
+	CheckedAt            time.Time `json:"checked_at"`
+}
+
+// ListAssistantDrift handles GET /api/admin/assistants/drift
+// Lists the avatars whose assistant had other instructions than the avatar when the assistants were last checked
+func (h *AdminHandler) ListAssistantDrift(w http.ResponseWriter, r *http.Request) {
+	drifts, err := h.db.GetAssistantDrifts()
+	if err != nil {
+		log.Printf("[API] ListAssistantDrift failed: DB error err=%v", err)
+		http.Error(w, "Failed to get assistant drift", http.StatusInternalServerError)
+		return
+	}
+
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		log.Printf("[API] ListAssistantDrift failed: DB error getting avatars err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[int64]*models.Avatar, len(avatars))
+	for i := range avatars {
+		byID[avatars[i].ID] = &avatars[i]
+	}
+
+	response := []AssistantDriftResponse{}
+	for _, drift := range drifts {
+		avatar := byID[drift.AvatarID]
+		if avatar == nil {
+			continue
+		}
+		response = append(response, AssistantDriftResponse{
+			AvatarID:             drift.AvatarID,
+			AvatarName:           avatar.Name,
+			AssistantID:          drift.AssistantID,
+			Instructions:         drift.Instructions,
+			ExpectedInstructions: h.instructions.AssistantInstructions(avatar.Prompt),
+			DetectedAt:           drift.DetectedAt,
+			CheckedAt:            drift.CheckedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResyncAssistant handles POST /api/admin/assistants/drift/{avatar_id}/resync
+// Overwrites the name and instructions of the avatar's assistant from the avatar and clears its drift
+func (h *AdminHandler) ResyncAssistant(w http.ResponseWriter, r *http.Request) {
+	avatarID, err := strconv.ParseInt(r.PathValue("avatar_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	avatar, err := h.db.GetAvatar(avatarID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+	if avatar.OpenAIAssistantID == "" {
+		http.Error(w, "Avatar has no assistant", http.StatusBadRequest)
+		return
+	}
+
+	// The instructions found by the last check, if the assistant had drifted
+	var found any
+	if drift, err := h.db.GetAssistantDrift(avatarID); err == nil {
+		found = drift.Instructions
+	}
+
+	instructions := h.instructions.AssistantInstructions(avatar.Prompt)
+	if _, err := h.assistant.WithContext(r.Context()).UpdateAssistant(avatar.OpenAIAssistantID, avatar.Name, instructions); err != nil {
+		log.Printf("[API] ResyncAssistant failed: OpenAI error avatar_id=%d assistant_id=%s err=%v",
+			avatarID, avatar.OpenAIAssistantID, err)
+		writeStatusError(w, openAIStatusError("Failed to update OpenAI assistant", err))
+		return
+	}
+
+	if err := h.db.ClearAssistantDrift(avatarID); err != nil {
+		log.Printf("[API] ResyncAssistant failed: DB error clearing drift avatar_id=%d err=%v", avatarID, err)
+		http.Error(w, "Failed to clear assistant drift", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Assistant resynced avatar_id=%d assistant_id=%s", avatarID, avatar.OpenAIAssistantID)
+	recordAudit(h.db, r, models.AuditActionAssistantResync, "avatar", strconv.FormatInt(avatarID, 10),
+		map[string]any{"instructions": found}, map[string]any{"instructions": instructions})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
+
+// getAccountAssistant retrieves an assistant from the OpenAI account, writing the error response on failure
+func (h *AdminHandler) getAccountAssistant(w http.ResponseWriter, r *http.Request, assistantID string) (*assistant.Assistant, bool) {
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	existing, err := h.assistant.WithContext(r.Context()).GetAssistant(assistantID)
+	if assistant.IsNotFound(err) {
+		http.Error(w, "Assistant not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("[API] Failed to get OpenAI assistant assistant_id=%s err=%v", assistantID, err)
+		http.Error(w, "Failed to get OpenAI assistant", http.StatusBadGateway)
+		return nil, false
+	}
+	return existing, true
+}
+
+// tagAvatarAssistant records the avatar in the metadata of its assistant, keeping the other entries
+// Failures are logged; the avatar works without the tag
+func tagAvatarAssistant(client *assistant.Client, assistantID string, avatarID int64, existing assistant.Metadata) {
+	if client == nil {
+		return
+	}
+	if _, err := client.UpdateAssistantMetadata(assistantID, existing.Merge(assistant.AvatarMetadata(avatarID))); err != nil {
+		log.Printf("[API] Warning: failed to tag assistant assistant_id=%s avatar_id=%d err=%v", assistantID, avatarID, err)
+	}
+}
+
+// findAvatarByAssistant returns the avatar linked to an assistant, or nil if there is none
+func (h *AdminHandler) findAvatarByAssistant(assistantID string) (*models.Avatar, error) {
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		return nil, err
+	}
+	for i := range avatars {
+		if avatars[i].OpenAIAssistantID == assistantID {
+			return &avatars[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// newAssistantAccountHandler creates an admin handler backed by a mock OpenAI account
+// holding asst_linked, tagged as managed by this application, and asst_external, listed over two pages
+func newAssistantAccountHandler(t *testing.T) (*AdminHandler, func()) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/assistants":
+			if r.URL.Query().Get("after") == "" {
+				w.Write([]byte(`{"data": [{"id": "asst_linked", "name": "Alice", "model": "gpt-4o",
+					"metadata": {"app": "multi-avatar-chat", "avatar_id": "1"}}],
+					"has_more": true, "last_id": "asst_linked"}`))
+				return
+			}
+			w.Write([]byte(`{"data": [{"id": "asst_external", "name": "Researcher", "model": "gpt-4o",
+				"tools": [{"type": "file_search"}]}], "has_more": false}`))
+		case "/assistants/asst_external":
+			w.Write([]byte(`{"id": "asst_external", "name": "Researcher", "model": "gpt-4o",
+				"instructions": "` + jsonEscape(logic.UserPriorityInstruction) + `You research things",
+				"tools": [{"type": "file_search"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "not found"}}`))
+		}
+	}))
+
+	avatarHandler := setupTestAvatarHandler(t)
+	client := assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+
+	return NewAdminHandler(avatarHandler.db, client), func() {
+		server.Close()
+	}
+}
+
+// jsonEscape escapes a string for embedding in a JSON string literal
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+func TestListAssistants(t *testing.T) {
+	handler, cleanup := newAssistantAccountHandler(t)
+	defer cleanup()
+
+	linked, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_linked")
+	handler.db.CreateAvatar("Bob", "prompt", "asst_deleted")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/assistants", nil)
+	w := httptest.NewRecorder()
+	handler.ListAssistants(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp AdminAssistantsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Assistants) != 2 {
+		t.Fatalf("expected 2 assistants across pages, got %+v", resp.Assistants)
+	}
+	if a := resp.Assistants[0]; a.AvatarID == nil || *a.AvatarID != linked.ID || a.AvatarName != "Alice" {
+		t.Errorf("expected asst_linked to be linked to Alice, got %+v", a)
+	}
+	if a := resp.Assistants[1]; a.AvatarID != nil || len(a.Tools) != 1 || a.Tools[0] != assistant.ToolFileSearch {
+		t.Errorf("expected unlinked asst_external with file_search, got %+v", a)
+	}
+	if len(resp.UnlinkedAvatars) != 1 || resp.UnlinkedAvatars[0].Name != "Bob" {
+		t.Errorf("expected Bob to be unlinked, got %+v", resp.UnlinkedAvatars)
+	}
+}
+
+func TestListAssistants_ManagedFilter(t *testing.T) {
+	handler, cleanup := newAssistantAccountHandler(t)
+	defer cleanup()
+
+	list := func(query string) (*httptest.ResponseRecorder, AdminAssistantsResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/assistants"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ListAssistants(w, req)
+
+		var resp AdminAssistantsResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w, resp
+	}
+
+	_, resp := list("?managed=true")
+	if len(resp.Assistants) != 1 || resp.Assistants[0].ID != "asst_linked" || !resp.Assistants[0].Managed {
+		t.Errorf("expected only the managed asst_linked, got %+v", resp.Assistants)
+	}
+	if md := resp.Assistants[0].Metadata; md[assistant.MetadataKeyAvatarID] != "1" {
+		t.Errorf("expected the assistant metadata to be returned, got %v", md)
+	}
+
+	_, resp = list("?managed=false")
+	if len(resp.Assistants) != 1 || resp.Assistants[0].ID != "asst_external" || resp.Assistants[0].Managed {
+		t.Errorf("expected only the unmanaged asst_external, got %+v", resp.Assistants)
+	}
+
+	if w, _ := list("?managed=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid filter, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestImportAssistant(t *testing.T) {
+	handler, cleanup := newAssistantAccountHandler(t)
+	defer cleanup()
+
+	importAssistant := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/assistants/"+id+"/import", nil)
+		req.SetPathValue("assistant_id", id)
+		w := httptest.NewRecorder()
+		handler.ImportAssistant(w, req)
+		return w
+	}
+
+	w := importAssistant("asst_external")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var avatar AvatarResponse
+	json.NewDecoder(w.Body).Decode(&avatar)
+	if avatar.Name != "Researcher" || avatar.Prompt != "You research things" || avatar.OpenAIAssistantID != "asst_external" {
+		t.Errorf("unexpected imported avatar: %+v", avatar)
+	}
+	if !avatar.CanSearch || avatar.CanCode {
+		t.Errorf("expected capabilities from the assistant tools, got %+v", avatar)
+	}
+
+	if w := importAssistant("asst_external"); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d for a linked assistant, got %d", http.StatusConflict, w.Code)
+	}
+	if w := importAssistant("asst_missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing assistant, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRelinkAssistant(t *testing.T) {
+	handler, cleanup := newAssistantAccountHandler(t)
+	defer cleanup()
+
+	bob, _ := handler.db.CreateAvatar("Bob", "prompt", "asst_deleted")
+
+	relink := func(id string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/assistants/"+id+"/relink", bytes.NewBufferString(body))
+		req.SetPathValue("assistant_id", id)
+		w := httptest.NewRecorder()
+		handler.RelinkAssistant(w, req)
+		return w
+	}
+
+	w := relink("asst_external", `{"avatar_id": `+strconv.FormatInt(bob.ID, 10)+`}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	updated, _ := handler.db.GetAvatar(bob.ID)
+	if updated.OpenAIAssistantID != "asst_external" || updated.Prompt != "prompt" || !updated.CanSearch {
+		t.Errorf("unexpected relinked avatar: %+v", updated)
+	}
+
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "")
+	if w := relink("asst_external", `{"avatar_id": `+strconv.FormatInt(alice.ID, 10)+`}`); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d for an assistant linked elsewhere, got %d", http.StatusConflict, w.Code)
+	}
+	if w := relink("asst_external", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d without avatar_id, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := relink("asst_external", `{"avatar_id": 999}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing avatar, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// newInstructionsClient creates a client for a mock OpenAI account that records the instructions
+// sent for each assistant; updates of the failing assistant are rejected
+func newInstructionsClient(t *testing.T, failing string) (*assistant.Client, map[string]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	instructions := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimPrefix(r.URL.Path, "/assistants/")
+		if r.Method != http.MethodPost || id == r.URL.Path || id == failing {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "rejected"}}`))
+			return
+		}
+		var body assistant.UpdateAssistantRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		instructions[id] = body.Instructions
+		mu.Unlock()
+		w.Write([]byte(`{"id": "` + id + `"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	})), instructions
+}
+
+func TestSyncInstructions(t *testing.T) {
+	avatarHandler := setupTestAvatarHandler(t)
+
+	client, instructions := newInstructionsClient(t, "asst_broken")
+	handler := NewAdminHandler(avatarHandler.db, client)
+	builder := logic.NewInstructionsBuilder("Never share personal data.")
+	handler.SetInstructions(builder)
+
+	alice, _ := handler.db.CreateAvatar("Alice", "Be kind", "asst_alice")
+	broken, _ := handler.db.CreateAvatar("Bob", "Be brief", "asst_broken")
+	handler.db.CreateAvatar("Local", "No assistant", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/assistants/sync-instructions", nil)
+	w := httptest.NewRecorder()
+	handler.SyncInstructions(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp SyncInstructionsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Synced != 1 || len(resp.Failed) != 1 || resp.Failed[0].AvatarID != broken.ID {
+		t.Fatalf("expected one synced and one failed assistant, got %+v", resp)
+	}
+	if got := instructions["asst_alice"]; got != builder.AssistantInstructions(alice.Prompt) {
+		t.Errorf("expected asst_alice instructions with the safety preamble and the user priority instruction, got %q", got)
+	}
+}
+
+func TestResyncAssistant(t *testing.T) {
+	avatarHandler := setupTestAvatarHandler(t)
+
+	client, instructions := newInstructionsClient(t, "")
+	handler := NewAdminHandler(avatarHandler.db, client)
+
+	alice, _ := handler.db.CreateAvatar("Alice", "Be kind", "asst_alice")
+	handler.db.RecordAssistantDrift(alice.ID, "asst_alice", "Edited in the dashboard")
+
+	w := httptest.NewRecorder()
+	handler.ListAssistantDrift(w, httptest.NewRequest(http.MethodGet, "/api/admin/assistants/drift", nil))
+	var drifts []AssistantDriftResponse
+	json.NewDecoder(w.Body).Decode(&drifts)
+	if len(drifts) != 1 || drifts[0].AvatarName != "Alice" || drifts[0].Instructions != "Edited in the dashboard" ||
+		drifts[0].ExpectedInstructions != (logic.InstructionsBuilder{}).AssistantInstructions(alice.Prompt) {
+		t.Fatalf("expected Alice's drift with both instructions, got %+v", drifts)
+	}
+
+	resync := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/assistants/drift/"+id+"/resync", nil)
+		req.SetPathValue("avatar_id", id)
+		w := httptest.NewRecorder()
+		handler.ResyncAssistant(w, req)
+		return w
+	}
+
+	if w := resync(strconv.FormatInt(alice.ID, 10)); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := instructions["asst_alice"]; got != (logic.InstructionsBuilder{}).AssistantInstructions(alice.Prompt) {
+		t.Errorf("expected the assistant to get the avatar's instructions, got %q", got)
+	}
+	if drifts, _ := handler.db.GetAssistantDrifts(); len(drifts) != 0 {
+		t.Errorf("expected the drift to be cleared, got %+v", drifts)
+	}
+	entries, _ := handler.db.GetAuditEntries(models.AuditFilter{Action: models.AuditActionAssistantResync, Limit: 10})
+	if len(entries) != 1 || entries[0].Changes["instructions"].From != "Edited in the dashboard" {
+		t.Errorf("expected the resync to be audited with the replaced instructions, got %+v", entries)
+	}
+
+	if w := resync("999"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
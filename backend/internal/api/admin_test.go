@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/testutil"
+	"multi-avatar-chat/internal/watcher"
+)
+
+// newFailingAssistantClient creates a client whose thread message creation always fails
+func newFailingAssistantClient(t *testing.T) *assistant.Client {
+	t.Helper()
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs") {
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": {"message": "server error"}}`))
+	}))
+	t.Cleanup(mockServer.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: mockServer.URL, TrimPrefix: "/v1"},
+	}))
+}
+
+func TestListQueues(t *testing.T) {
+	client := newFailingAssistantClient(t)
+	handler := NewAdminHandler(nil, client)
+
+	client.ForwardQueue().Forward(assistant.ForwardItem{ThreadID: "thread_1", AvatarName: "Alice", Content: "hello"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/queues", nil)
+	w := httptest.NewRecorder()
+	handler.ListQueues(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp QueuesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Total != 1 || resp.Failed != 1 {
+		t.Errorf("expected 1 failed item, got total=%d failed=%d", resp.Total, resp.Failed)
+	}
+	if len(resp.Threads) != 1 || resp.Threads[0].ThreadID != "thread_1" {
+		t.Errorf("unexpected threads: %+v", resp.Threads)
+	}
+}
+
+func TestListQueues_NoAssistant(t *testing.T) {
+	handler := NewAdminHandler(nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/queues", nil)
+	w := httptest.NewRecorder()
+	handler.ListQueues(w, req)
+
+	var resp QueuesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Total != 0 || resp.Threads == nil {
+		t.Errorf("expected empty queue list, got %+v", resp)
+	}
+}
+
+func TestRetryQueueItem(t *testing.T) {
+	client := newFailingAssistantClient(t)
+	handler := NewAdminHandler(nil, client)
+
+	client.ForwardQueue().Forward(assistant.ForwardItem{ThreadID: "thread_1", Content: "hello"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/queues/items/1/retry", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.RetryQueueItem(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/queues/items/999/retry", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handler.RetryQueueItem(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+	handler := NewAdminHandler(convHandler.db, nil)
+
+	avatar, _ := convHandler.db.CreateAvatar("Bot", "prompt", "")
+	convHandler.db.GetAvatar(avatar.ID)
+	convHandler.db.GetAvatar(avatar.ID)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/cache", nil)
+	w := httptest.NewRecorder()
+	handler.CacheStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var stats db.CacheStats
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats.Hits < 1 || stats.Misses < 1 {
+		t.Errorf("expected at least one hit and one miss, got %+v", stats)
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+	handler := NewAdminHandler(convHandler.db, nil)
+
+	convHandler.db.CreateAvatar("Bot", "prompt", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/db/queries", nil)
+	w := httptest.NewRecorder()
+	handler.QueryStats(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var stats db.QueryStats
+	json.NewDecoder(w.Body).Decode(&stats)
+	if len(stats.Queries) == 0 || stats.Lock.Acquisitions == 0 {
+		t.Errorf("expected statement and lock stats, got %+v", stats)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/admin/db/queries", nil)
+	w = httptest.NewRecorder()
+	handler.ResetQueryStats(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if stats := convHandler.db.QueryStats(); len(stats.Queries) != 0 {
+		t.Errorf("expected no statements after reset, got %+v", stats.Queries)
+	}
+}
+
+func TestJudgmentCacheStats(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+	handler := NewAdminHandler(convHandler.db, nil)
+
+	getStats := func() watcher.JudgmentCacheStats {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/judgment-cache", nil)
+		w := httptest.NewRecorder()
+		handler.JudgmentCacheStats(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var stats watcher.JudgmentCacheStats
+		json.NewDecoder(w.Body).Decode(&stats)
+		return stats
+	}
+
+	// Without watchers there is nothing to report
+	if stats := getStats(); stats != (watcher.JudgmentCacheStats{}) {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+
+	handler.SetWatcherManager(watcher.NewManager(convHandler.db, nil, 0))
+	if stats := getStats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("expected a fresh cache, got %+v", stats)
+	}
+}
+
+func TestSSEStats(t *testing.T) {
+	broadcaster := NewEventBroadcaster()
+	handler := NewAdminHandler(nil, nil)
+	handler.SetBroadcaster(broadcaster)
+
+	ch := broadcaster.Subscribe(1)
+	defer broadcaster.Unsubscribe(1, ch)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/sse", nil)
+	w := httptest.NewRecorder()
+	handler.SSEStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var stats BroadcasterStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.Clients != 1 || stats.Policy != OverflowDropOldest || stats.BufferSize != DefaultClientBufferSize {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
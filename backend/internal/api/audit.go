@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// ActorHeader identifies who performed an administrative action
+	// The application has no authentication; a proxy or client may set it
+	ActorHeader = "X-Actor"
+	// anonymousActor is recorded when the request carries no actor
+	anonymousActor = "anonymous"
+	// maxActorLength truncates overly long actor headers
+	maxActorLength = 100
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditHandler handles audit log HTTP requests
+type AuditHandler struct {
+	db *db.DB
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(database *db.DB) *AuditHandler {
+	return &AuditHandler{db: database}
+}
+
+// List handles GET /api/admin/audit
+// Filters: actor, action, target_type, target_id, since, until (RFC 3339), before_id, limit
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+		Limit:      defaultAuditLimit,
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" (must be RFC 3339)", http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+
+	if v := query.Get("before_id"); v != "" {
+		beforeID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || beforeID <= 0 {
+			http.Error(w, "Invalid before_id", http.StatusBadRequest)
+			return
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > maxAuditLimit {
+			limit = maxAuditLimit
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.db.GetAuditEntries(filter)
+	if err != nil {
+		log.Printf("[API] ListAudit failed: DB error err=%v", err)
+		http.Error(w, "Failed to list audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// recordAudit appends an administrative action to the audit log
+// before and after are the target's state (nil for created or deleted targets); only
+// differing fields are stored. Failures are logged and never fail the request
+func recordAudit(database *db.DB, r *http.Request, action, targetType, targetID string, before, after any) {
+	if database == nil {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Actor:      auditActor(r),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    auditChanges(before, after),
+	}
+	if err := database.CreateAuditEntry(entry); err != nil {
+		log.Printf("[API] Warning: failed to record audit entry action=%s target=%s/%s err=%v",
+			action, targetType, targetID, err)
+	}
+}
+
+// auditActor returns the actor named by the request, or anonymousActor
+func auditActor(r *http.Request) string {
+	actor := strings.TrimSpace(r.Header.Get(ActorHeader))
+	if actor == "" {
+		return anonymousActor
+	}
+	if len(actor) > maxActorLength {
+		actor = actor[:maxActorLength]
+	}
+	return actor
+}
+
+// auditChanges diffs the JSON representations of before and after field by field
+func auditChanges(before, after any) map[string]models.AuditChange {
+	from, to := auditFields(before), auditFields(after)
+
+	changes := map[string]models.AuditChange{}
+	for name, value := range from {
+		if other, ok := to[name]; !ok || !reflect.DeepEqual(value, other) {
+			changes[name] = models.AuditChange{From: value, To: to[name]}
+		}
+	}
+	for name, value := range to {
+		if _, ok := from[name]; !ok {
+			changes[name] = models.AuditChange{To: value}
+		}
+	}
+	return changes
+}
+
+// auditFields converts a value to its JSON object fields
+func auditFields(v any) map[string]any {
+	fields := map[string]any{}
+	if v == nil {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestAudit_RecordsAvatarChanges(t *testing.T) {
+	avatarHandler := setupTestAvatarHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", strings.NewReader(`{"name": "Alice", "prompt": "Be helpful"}`))
+	req.Header.Set(ActorHeader, "ops@example.com")
+	w := httptest.NewRecorder()
+	avatarHandler.Create(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/avatars/1", strings.NewReader(`{"name": "Alice", "prompt": "Be concise"}`))
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	avatarHandler.Update(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	handler := NewAuditHandler(avatarHandler.db)
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/audit?target_type=avatar&target_id=1", nil)
+	w = httptest.NewRecorder()
+	handler.List(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var entries []models.AuditEntry
+	json.NewDecoder(w.Body).Decode(&entries)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", entries)
+	}
+
+	update := entries[0]
+	if update.Action != models.AuditActionAvatarUpdate || update.Actor != anonymousActor {
+		t.Errorf("unexpected update entry: %+v", update)
+	}
+	if len(update.Changes) != 1 || update.Changes["prompt"].From != "Be helpful" || update.Changes["prompt"].To != "Be concise" {
+		t.Errorf("expected only the prompt change, got %+v", update.Changes)
+	}
+
+	create := entries[1]
+	if create.Action != models.AuditActionAvatarCreate || create.Actor != "ops@example.com" {
+		t.Errorf("unexpected create entry: %+v", create)
+	}
+	if create.Changes["name"].From != nil || create.Changes["name"].To != "Alice" {
+		t.Errorf("expected created fields, got %+v", create.Changes)
+	}
+}
+
+func TestAudit_RecordsTeamAndCaptureActions(t *testing.T) {
+	teamHandler, database := setupTestTeamHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/teams", strings.NewReader(`{"name": "Support"}`))
+	w := httptest.NewRecorder()
+	teamHandler.Create(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/teams/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	teamHandler.Delete(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	NewCaptureHandler(database).Clear(w, httptest.NewRequest(http.MethodDelete, "/api/admin/captures", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	entries, err := database.GetAuditEntries(models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditEntries failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %+v", entries)
+	}
+	if entries[0].Action != models.AuditActionCapturesClear || entries[0].Changes["deleted"].To != float64(0) {
+		t.Errorf("unexpected capture entry: %+v", entries[0])
+	}
+	if entries[1].Action != models.AuditActionTeamDelete || entries[1].TargetID != "1" || entries[1].Changes["name"].From != "Support" {
+		t.Errorf("unexpected team delete entry: %+v", entries[1])
+	}
+	if entries[2].Action != models.AuditActionTeamCreate || entries[2].Changes["name"].To != "Support" {
+		t.Errorf("unexpected team create entry: %+v", entries[2])
+	}
+}
+
+func TestAuditList_Filters(t *testing.T) {
+	avatarHandler := setupTestAvatarHandler(t)
+
+	database := avatarHandler.db
+	database.CreateAuditEntry(&models.AuditEntry{Actor: "alice", Action: models.AuditActionConversationDelete, TargetType: "conversation", TargetID: "1"})
+	database.CreateAuditEntry(&models.AuditEntry{Actor: "bob", Action: models.AuditActionConversationInterrupt, TargetType: "conversation", TargetID: "2"})
+
+	handler := NewAuditHandler(database)
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/audit?action=conversation.interrupt&actor=bob", nil)
+	w := httptest.NewRecorder()
+	handler.List(w, req)
+
+	var entries []models.AuditEntry
+	json.NewDecoder(w.Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].TargetID != "2" {
+		t.Errorf("expected only bob's interrupt, got %+v", entries)
+	}
+}
+
+func TestAuditList_InvalidParams(t *testing.T) {
+	avatarHandler := setupTestAvatarHandler(t)
+
+	handler := NewAuditHandler(avatarHandler.db)
+	for _, query := range []string{"since=yesterday", "limit=0", "before_id=abc"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.List(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
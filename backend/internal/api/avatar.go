@@ -3,40 +3,132 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/watcher"
 )
 
 // AvatarHandler handles avatar-related HTTP requests
 type AvatarHandler struct {
-	db        *db.DB
-	assistant *assistant.Client
+	db          *db.DB
+	assistant   *assistant.Client
+	watcher     *watcher.WatcherManager
+	broadcaster *EventBroadcaster
+	// renameNotices posts a system message in the avatar's conversations when it is renamed
+	renameNotices bool
+	// webhookHosts are the hosts behavior script webhooks may call at local or private addresses
+	webhookHosts []string
+	// instructions builds the instructions of the avatars' assistants
+	instructions logic.InstructionsBuilder
 }
 
 // NewAvatarHandler creates a new avatar handler
 func NewAvatarHandler(database *db.DB, assistantClient *assistant.Client) *AvatarHandler {
 	return &AvatarHandler{
-		db:        database,
-		assistant: assistantClient,
+		db:            database,
+		assistant:     assistantClient,
+		renameNotices: true,
 	}
 }
 
+// SetWatcherManager sets the watcher manager whose watchers learn about renamed avatars
+func (h *AvatarHandler) SetWatcherManager(wm *watcher.WatcherManager) {
+	h.watcher = wm
+}
+
+// SetBroadcaster sets the event broadcaster for rename notices
+func (h *AvatarHandler) SetBroadcaster(broadcaster *EventBroadcaster) {
+	h.broadcaster = broadcaster
+}
+
+// SetRenameNotices enables or disables the system message posted when an avatar is renamed
+func (h *AvatarHandler) SetRenameNotices(enabled bool) {
+	h.renameNotices = enabled
+}
+
+// SetInstructions sets the builder of assistant instructions, which adds the safety preamble
+func (h *AvatarHandler) SetInstructions(instructions logic.InstructionsBuilder) {
+	h.instructions = instructions
+}
+
+// SetBehaviorWebhookHosts sets the hosts script test runs accept as webhook targets at local or private addresses
+func (h *AvatarHandler) SetBehaviorWebhookHosts(hosts []string) {
+	h.webhookHosts = hosts
+}
+
 // CreateAvatarRequest represents the request body for creating an avatar
 type CreateAvatarRequest struct {
 	Name   string `json:"name"`
 	Prompt string `json:"prompt"`
+	// Color and Emoji are optional; a stable pair is assigned from the name when omitted
+	Color string `json:"color,omitempty"`
+	Emoji string `json:"emoji,omitempty"`
+	// Keywords and RelevanceThreshold tune the pre-filter that runs before LLM judgment
+	Keywords           []string `json:"keywords,omitempty"`
+	RelevanceThreshold *float64 `json:"relevance_threshold,omitempty"`
+	// CanSearch and CanCode enable file search and the code interpreter on the assistant;
+	// CanCite asks the avatar to cite sources. All are off when omitted
+	CanSearch *bool `json:"can_search,omitempty"`
+	CanCode   *bool `json:"can_code,omitempty"`
+	CanCite   *bool `json:"can_cite,omitempty"`
+	// FormattingRules are added to the run instructions and repair responses before they are stored
+	FormattingRules *models.FormattingRules `json:"formatting_rules,omitempty"`
+	// IsolatedContext keeps other avatars' messages away from the avatar; off when omitted
+	IsolatedContext *bool `json:"isolated_context,omitempty"`
 }
 
 // AvatarResponse represents an avatar in API responses
 type AvatarResponse struct {
-	ID                int64  `json:"id"`
-	Name              string `json:"name"`
-	Prompt            string `json:"prompt"`
-	OpenAIAssistantID string `json:"openai_assistant_id,omitempty"`
-	CreatedAt         string `json:"created_at"`
+	ID                 int64    `json:"id"`
+	Name               string   `json:"name"`
+	Prompt             string   `json:"prompt"`
+	OpenAIAssistantID  string   `json:"openai_assistant_id,omitempty"`
+	Color              string   `json:"color"`
+	Emoji              string   `json:"emoji"`
+	Keywords           []string `json:"keywords"`
+	RelevanceThreshold float64  `json:"relevance_threshold"`
+	CanSearch          bool     `json:"can_search"`
+	CanCode            bool     `json:"can_code"`
+	CanCite            bool     `json:"can_cite"`
+	// NeedsRelink reports that the assistant no longer exists on OpenAI and the avatar is silent
+	NeedsRelink bool `json:"needs_relink"`
+	// FormattingRules shape the layout of the avatar's responses
+	FormattingRules models.FormattingRules `json:"formatting_rules"`
+	// IsolatedContext reports that the avatar only sees user messages
+	IsolatedContext bool   `json:"isolated_context"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// newAvatarResponse converts an avatar model to its API representation
+func newAvatarResponse(avatar *models.Avatar) AvatarResponse {
+	return AvatarResponse{
+		ID:                 avatar.ID,
+		Name:               avatar.Name,
+		Prompt:             avatar.Prompt,
+		OpenAIAssistantID:  avatar.OpenAIAssistantID,
+		Color:              avatar.Color,
+		Emoji:              avatar.Emoji,
+		Keywords:           avatar.Keywords,
+		RelevanceThreshold: avatar.RelevanceThreshold,
+		CanSearch:          avatar.CanSearch,
+		CanCode:            avatar.CanCode,
+		CanCite:            avatar.CanCite,
+		NeedsRelink:        avatar.NeedsRelink,
+		FormattingRules:    avatar.FormattingRules,
+		IsolatedContext:    avatar.IsolatedContext,
+		CreatedAt:          avatar.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          avatar.UpdatedAt.Format(time.RFC3339),
+	}
 }
 
 // Create handles POST /api/avatars
@@ -52,36 +144,112 @@ func (h *AvatarHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add user priority instruction to prompt
-	userPriorityPrompt := "【重要】`Name: ユーザ` となっているメッセージがユーザの意見です。あなたはこれを最重視して発言をする必要があります。ユーザの意見を尊重し、それに基づいて応答してください。\n\n" + req.Prompt
+	if req.Color != "" && !logic.IsValidAvatarColor(req.Color) {
+		http.Error(w, "Invalid color (must be #RRGGBB)", http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePromptVariables(req.Prompt); err != nil {
+		http.Error(w, "Invalid prompt: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !isValidRelevanceThreshold(req.RelevanceThreshold) {
+		http.Error(w, "Invalid relevance_threshold (must be between 0 and 1)", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidFormattingRules(req.FormattingRules) {
+		http.Error(w, formattingRulesInvalid, http.StatusBadRequest)
+		return
+	}
 
-	// Create OpenAI Assistant
+	avatar, err := h.createAvatar(req, "")
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditActionAvatarCreate, "avatar", strconv.FormatInt(avatar.ID, 10),
+		nil, newAvatarResponse(avatar))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
+
+// createAvatar creates the OpenAI assistant and the avatar described by a validated request
+// An empty model uses the client's default model
+func (h *AvatarHandler) createAvatar(req CreateAvatarRequest, model string) (*models.Avatar, error) {
+	// Check the name before creating the assistant so a conflict leaves nothing behind
+	if err := checkAvatarName(h.db, req.Name, 0); err != nil {
+		return nil, err
+	}
+
+	// Create OpenAI Assistant with the tools enabled by the requested capabilities
 	var assistantID string
 	if h.assistant != nil {
-		openAIAssistant, err := h.assistant.CreateAssistant(req.Name, userPriorityPrompt)
+		tools := capabilityTools(req.CanSearch != nil && *req.CanSearch, req.CanCode != nil && *req.CanCode)
+		openAIAssistant, err := h.assistant.CreateAssistantWithModel(req.Name, h.instructions.AssistantInstructions(req.Prompt), model, tools)
 		if err != nil {
-			http.Error(w, "Failed to create OpenAI assistant: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, openAIStatusError("Failed to create OpenAI assistant", err)
 		}
 		assistantID = openAIAssistant.ID
 	}
 
+	failed := &statusError{http.StatusInternalServerError, "Failed to create avatar"}
+
 	// Save to database
 	avatar, err := h.db.CreateAvatar(req.Name, req.Prompt, assistantID)
+	if err == db.ErrDuplicate {
+		return nil, checkAvatarName(h.db, req.Name, 0)
+	}
 	if err != nil {
-		http.Error(w, "Failed to create avatar", http.StatusInternalServerError)
-		return
+		return nil, failed
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(AvatarResponse{
-		ID:                avatar.ID,
-		Name:              avatar.Name,
-		Prompt:            avatar.Prompt,
-		OpenAIAssistantID: avatar.OpenAIAssistantID,
-		CreatedAt:         avatar.CreatedAt.Format("2006-01-02T15:04:05Z"),
-	})
+	// Apply explicit display overrides
+	if req.Color != "" || req.Emoji != "" {
+		if err := h.db.ApplyAvatarDisplay(avatar, req.Color, req.Emoji); err != nil {
+			return nil, failed
+		}
+	}
+
+	// Apply pre-filter tuning
+	if applyPrefilter(avatar, req.Keywords, req.RelevanceThreshold) {
+		if err := h.db.UpdateAvatarPrefilter(avatar.ID, avatar.Keywords, avatar.RelevanceThreshold); err != nil {
+			return nil, failed
+		}
+	}
+
+	// Apply capability flags
+	if applyCapabilities(avatar, req.CanSearch, req.CanCode, req.CanCite) {
+		if err := h.db.UpdateAvatarCapabilities(avatar.ID, avatar.CanSearch, avatar.CanCode, avatar.CanCite); err != nil {
+			return nil, failed
+		}
+	}
+
+	// Apply formatting rules
+	if req.FormattingRules != nil {
+		avatar.FormattingRules = *req.FormattingRules
+		if err := h.db.UpdateAvatarFormattingRules(avatar.ID, avatar.FormattingRules); err != nil {
+			return nil, failed
+		}
+	}
+
+	// Apply context isolation
+	if req.IsolatedContext != nil && *req.IsolatedContext {
+		avatar.IsolatedContext = true
+		if err := h.db.UpdateAvatarIsolatedContext(avatar.ID, true); err != nil {
+			return nil, failed
+		}
+	}
+
+	if assistantID != "" {
+		tagAvatarAssistant(h.assistant, assistantID, avatar.ID, nil)
+	}
+
+	return avatar, nil
 }
 
 // List handles GET /api/avatars
@@ -93,14 +261,8 @@ func (h *AvatarHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := make([]AvatarResponse, len(avatars))
-	for i, avatar := range avatars {
-		response[i] = AvatarResponse{
-			ID:                avatar.ID,
-			Name:              avatar.Name,
-			Prompt:            avatar.Prompt,
-			OpenAIAssistantID: avatar.OpenAIAssistantID,
-			CreatedAt:         avatar.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		}
+	for i := range avatars {
+		response[i] = newAvatarResponse(&avatars[i])
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -125,20 +287,34 @@ func (h *AvatarHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setVersionHeaders(w, avatar.UpdatedAt)
+	if notModified(r, avatar.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AvatarResponse{
-		ID:                avatar.ID,
-		Name:              avatar.Name,
-		Prompt:            avatar.Prompt,
-		OpenAIAssistantID: avatar.OpenAIAssistantID,
-		CreatedAt:         avatar.CreatedAt.Format("2006-01-02T15:04:05Z"),
-	})
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
 }
 
 // UpdateAvatarRequest represents the request body for updating an avatar
 type UpdateAvatarRequest struct {
 	Name   string `json:"name"`
 	Prompt string `json:"prompt"`
+	// Color and Emoji keep their current values when omitted
+	Color string `json:"color,omitempty"`
+	Emoji string `json:"emoji,omitempty"`
+	// Keywords and RelevanceThreshold keep their current values when omitted; send [] to clear keywords
+	Keywords           []string `json:"keywords,omitempty"`
+	RelevanceThreshold *float64 `json:"relevance_threshold,omitempty"`
+	// Capability flags keep their current values when omitted
+	CanSearch *bool `json:"can_search,omitempty"`
+	CanCode   *bool `json:"can_code,omitempty"`
+	CanCite   *bool `json:"can_cite,omitempty"`
+	// FormattingRules replace the current rules when present
+	FormattingRules *models.FormattingRules `json:"formatting_rules,omitempty"`
+	// IsolatedContext keeps its current value when omitted
+	IsolatedContext *bool `json:"isolated_context,omitempty"`
 }
 
 // Update handles PUT /api/avatars/{id}
@@ -155,6 +331,26 @@ func (h *AvatarHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Color != "" && !logic.IsValidAvatarColor(req.Color) {
+		http.Error(w, "Invalid color (must be #RRGGBB)", http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePromptVariables(req.Prompt); err != nil {
+		http.Error(w, "Invalid prompt: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !isValidRelevanceThreshold(req.RelevanceThreshold) {
+		http.Error(w, "Invalid relevance_threshold (must be between 0 and 1)", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidFormattingRules(req.FormattingRules) {
+		http.Error(w, formattingRulesInvalid, http.StatusBadRequest)
+		return
+	}
+
 	// Get existing avatar
 	existing, err := h.db.GetAvatar(id)
 	if err == sql.ErrNoRows {
@@ -166,31 +362,257 @@ func (h *AvatarHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With If-Match, refuse to overwrite an edit made after the client read the avatar
+	if !checkIfMatch(w, r, "Avatar", existing.UpdatedAt) {
+		return
+	}
+
+	if err := checkAvatarName(h.db, req.Name, id); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
 	// Update OpenAI Assistant if prompt changed
 	assistantID := existing.OpenAIAssistantID
 	if h.assistant != nil && existing.OpenAIAssistantID != "" && (req.Prompt != existing.Prompt || req.Name != existing.Name) {
-		_, err := h.assistant.UpdateAssistant(existing.OpenAIAssistantID, req.Name, req.Prompt)
+		_, err := h.assistant.UpdateAssistant(existing.OpenAIAssistantID, req.Name, h.instructions.AssistantInstructions(req.Prompt))
 		if err != nil {
-			http.Error(w, "Failed to update OpenAI assistant: "+err.Error(), http.StatusInternalServerError)
+			writeStatusError(w, openAIStatusError("Failed to update OpenAI assistant", err))
 			return
 		}
 	}
 
-	// Update in database
-	avatar, err := h.db.UpdateAvatar(id, req.Name, req.Prompt, assistantID)
+	// Update in database; a conditional update also catches an edit saved while the assistant was updated
+	var avatar *models.Avatar
+	if hasIfMatch(r) {
+		avatar, err = h.db.UpdateAvatarIfUnmodified(id, req.Name, req.Prompt, assistantID, existing.UpdatedAt)
+	} else {
+		avatar, err = h.db.UpdateAvatar(id, req.Name, req.Prompt, assistantID)
+	}
+	if err == db.ErrModified {
+		writePreconditionFailed(w, "Avatar")
+		return
+	}
+	if err == db.ErrDuplicate {
+		writeStatusError(w, checkAvatarName(h.db, req.Name, id))
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
 		return
 	}
 
+	// Update display metadata if requested
+	if req.Color != "" || req.Emoji != "" {
+		if err := h.db.ApplyAvatarDisplay(avatar, req.Color, req.Emoji); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Update pre-filter tuning if requested
+	if applyPrefilter(avatar, req.Keywords, req.RelevanceThreshold) {
+		if err := h.db.UpdateAvatarPrefilter(avatar.ID, avatar.Keywords, avatar.RelevanceThreshold); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Update capability flags if requested, switching the assistant's tools when they change
+	if applyCapabilities(avatar, req.CanSearch, req.CanCode, req.CanCite) {
+		toolsChanged := avatar.CanSearch != existing.CanSearch || avatar.CanCode != existing.CanCode
+		if h.assistant != nil && avatar.OpenAIAssistantID != "" && toolsChanged {
+			tools := capabilityTools(avatar.CanSearch, avatar.CanCode)
+			if _, err := h.assistant.UpdateAssistantTools(avatar.OpenAIAssistantID, tools); err != nil {
+				writeStatusError(w, openAIStatusError("Failed to update OpenAI assistant", err))
+				return
+			}
+		}
+		if err := h.db.UpdateAvatarCapabilities(avatar.ID, avatar.CanSearch, avatar.CanCode, avatar.CanCite); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Update formatting rules if requested
+	if req.FormattingRules != nil {
+		avatar.FormattingRules = *req.FormattingRules
+		if err := h.db.UpdateAvatarFormattingRules(avatar.ID, avatar.FormattingRules); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Update context isolation if requested; watchers read it per message, so it applies from the next one
+	if req.IsolatedContext != nil && *req.IsolatedContext != avatar.IsolatedContext {
+		avatar.IsolatedContext = *req.IsolatedContext
+		if err := h.db.UpdateAvatarIsolatedContext(avatar.ID, avatar.IsolatedContext); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Each optional update above moves updated_at on; report the version that was saved last
+	if saved, err := h.db.GetAvatar(avatar.ID); err == nil {
+		avatar.UpdatedAt = saved.UpdatedAt
+	}
+
+	if avatar.Name != existing.Name {
+		h.propagateRename(existing.Name, avatar)
+	}
+
+	recordAudit(h.db, r, models.AuditActionAvatarUpdate, "avatar", strconv.FormatInt(avatar.ID, 10),
+		newAvatarResponse(existing), newAvatarResponse(avatar))
+
+	setVersionHeaders(w, avatar.UpdatedAt)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AvatarResponse{
-		ID:                avatar.ID,
-		Name:              avatar.Name,
-		Prompt:            avatar.Prompt,
-		OpenAIAssistantID: avatar.OpenAIAssistantID,
-		CreatedAt:         avatar.CreatedAt.Format("2006-01-02T15:04:05Z"),
-	})
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
+
+// propagateRename tells running watchers about an avatar's new name and, with rename notices enabled,
+// posts a system message in every conversation the avatar takes part in
+// Failures are logged; the rename itself has already been saved
+func (h *AvatarHandler) propagateRename(oldName string, avatar *models.Avatar) {
+	if h.watcher != nil {
+		if err := h.watcher.RefreshAvatar(avatar.ID); err != nil {
+			log.Printf("[API] Warning: failed to refresh watchers after rename avatar_id=%d err=%v", avatar.ID, err)
+		}
+	}
+
+	if !h.renameNotices {
+		return
+	}
+	conversationIDs, err := h.db.GetAvatarConversationIDs(avatar.ID)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get conversations for rename notice avatar_id=%d err=%v", avatar.ID, err)
+		return
+	}
+	content := logic.FormatRenameNotice(oldName, avatar.Name)
+	for _, conversationID := range conversationIDs {
+		msg, err := h.db.CreateMessage(conversationID, models.SenderTypeSystem, nil, content)
+		if err != nil {
+			log.Printf("[API] Warning: failed to post rename notice conversation_id=%d avatar_id=%d err=%v", conversationID, avatar.ID, err)
+			continue
+		}
+		if h.broadcaster != nil {
+			h.broadcaster.BroadcastMessage(conversationID, models.NewMessageEvent(msg))
+		}
+	}
+	log.Printf("[API] Rename propagated avatar_id=%d old_name=%q new_name=%q conversations=%d",
+		avatar.ID, oldName, avatar.Name, len(conversationIDs))
+}
+
+// checkAvatarName returns a 409 error suggesting a free name when another avatar has the same name,
+// ignoring case and character width, since mentions could not tell the two apart
+// excludeID is the avatar being renamed, or 0 for a new avatar
+func checkAvatarName(database *db.DB, name string, excludeID int64) error {
+	avatars, err := database.GetAllAvatars()
+	if err != nil {
+		return &statusError{http.StatusInternalServerError, "Failed to get avatars"}
+	}
+
+	key := logic.NormalizeAvatarName(name)
+	names := make([]string, len(avatars))
+	taken := false
+	for i, a := range avatars {
+		names[i] = a.Name
+		if a.ID != excludeID && logic.NormalizeAvatarName(a.Name) == key {
+			taken = true
+		}
+	}
+	if !taken {
+		return nil
+	}
+	return &statusError{http.StatusConflict,
+		fmt.Sprintf("An avatar named %q already exists (try %q)", name, logic.SuggestAvatarName(name, names))}
+}
+
+// validatePromptVariables rejects prompts that use variables other than the supported ones
+func validatePromptVariables(prompt string) error {
+	unknown := logic.UnknownPromptVariables(prompt)
+	if len(unknown) == 0 {
+		return nil
+	}
+	return fmt.Errorf("unknown prompt variables: {{%s}} (supported: {{%s}})",
+		strings.Join(unknown, "}}, {{"), strings.Join(logic.KnownPromptVariables, "}}, {{"))
+}
+
+// formattingRulesInvalid is the error message for formatting rules outside their limits
+var formattingRulesInvalid = fmt.Sprintf("Invalid formatting_rules (max_paragraphs must be between 0 and %d)", logic.MaxFormattingParagraphs)
+
+// isValidFormattingRules checks optional formatting rules against their limits
+func isValidFormattingRules(rules *models.FormattingRules) bool {
+	return rules == nil || logic.IsValidFormattingRules(*rules)
+}
+
+// isValidRelevanceThreshold checks that an optional relevance threshold is between 0 and 1
+func isValidRelevanceThreshold(threshold *float64) bool {
+	return threshold == nil || (*threshold >= 0 && *threshold <= 1)
+}
+
+// applyPrefilter copies requested pre-filter settings onto the avatar
+// Returns false when the request changes nothing
+func applyPrefilter(avatar *models.Avatar, keywords []string, threshold *float64) bool {
+	if keywords == nil && threshold == nil {
+		return false
+	}
+	if keywords != nil {
+		cleaned := []string{}
+		for _, k := range keywords {
+			if k = strings.TrimSpace(k); k != "" {
+				cleaned = append(cleaned, k)
+			}
+		}
+		avatar.Keywords = cleaned
+	}
+	if threshold != nil {
+		avatar.RelevanceThreshold = *threshold
+	}
+	return true
+}
+
+// applyCapabilities copies requested capability flags onto the avatar
+// Returns false when the request changes nothing
+func applyCapabilities(avatar *models.Avatar, canSearch, canCode, canCite *bool) bool {
+	if canSearch == nil && canCode == nil && canCite == nil {
+		return false
+	}
+	if canSearch != nil {
+		avatar.CanSearch = *canSearch
+	}
+	if canCode != nil {
+		avatar.CanCode = *canCode
+	}
+	if canCite != nil {
+		avatar.CanCite = *canCite
+	}
+	return true
+}
+
+// capabilityTools returns the assistant tools enabled by an avatar's capabilities
+// Citing sources needs no tool; it only changes the run instructions
+func capabilityTools(canSearch, canCode bool) []assistant.Tool {
+	var tools []assistant.Tool
+	if canSearch {
+		tools = append(tools, assistant.Tool{Type: assistant.ToolFileSearch})
+	}
+	if canCode {
+		tools = append(tools, assistant.Tool{Type: assistant.ToolCodeInterpreter})
+	}
+	return tools
+}
+
+// toolCapabilities reports which capabilities the tools of an assistant enable
+func toolCapabilities(tools []assistant.Tool) (canSearch, canCode bool) {
+	for _, tool := range tools {
+		switch tool.Type {
+		case assistant.ToolFileSearch:
+			canSearch = true
+		case assistant.ToolCodeInterpreter:
+			canCode = true
+		}
+	}
+	return canSearch, canCode
 }
 
 // Delete handles DELETE /api/avatars/{id}
@@ -226,5 +648,59 @@ func (h *AvatarHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.db, r, models.AuditActionAvatarDelete, "avatar", strconv.FormatInt(id, 10),
+		newAvatarResponse(existing), nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RecreateAssistant handles POST /api/avatars/{id}/recreate-assistant
+// Creates a new OpenAI assistant from the avatar's prompt and capabilities and links it to the avatar,
+// e.g. after the previous assistant was deleted on OpenAI. Linking the new assistant clears needs_relink
+func (h *AvatarHandler) RecreateAssistant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.db.GetAvatar(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	client := h.assistant.WithContext(r.Context())
+	tools := capabilityTools(before.CanSearch, before.CanCode)
+	created, err := client.CreateAssistantWithModel(before.Name, h.instructions.AssistantInstructions(before.Prompt), "", tools)
+	if err != nil {
+		writeStatusError(w, openAIStatusError("Failed to create OpenAI assistant", err))
+		return
+	}
+
+	avatar, err := h.db.UpdateAvatar(before.ID, before.Name, before.Prompt, created.ID)
+	if err != nil {
+		log.Printf("[API] RecreateAssistant failed: DB error updating avatar avatar_id=%d err=%v", before.ID, err)
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	tagAvatarAssistant(client, created.ID, avatar.ID, nil)
+
+	log.Printf("[API] Assistant recreated avatar_id=%d assistant_id=%s previous_assistant_id=%s needs_relink=%v",
+		avatar.ID, created.ID, before.OpenAIAssistantID, before.NeedsRelink)
+	recordAudit(h.db, r, models.AuditActionAssistantRecreate, "avatar", strconv.FormatInt(avatar.ID, 10),
+		newAvatarResponse(before), newAvatarResponse(avatar))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/script"
+)
+
+// maxAvatarScriptLength is the largest behavior script source accepted, in bytes
+const maxAvatarScriptLength = 20000
+
+// SetAvatarScriptRequest is the request body of PUT /api/avatars/{id}/script
+type SetAvatarScriptRequest struct {
+	Source string `json:"source"`
+}
+
+// TestAvatarScriptRequest is the request body of POST /api/avatars/{id}/script/test
+// Source defaults to the avatar's saved script; SenderType defaults to "user"
+type TestAvatarScriptRequest struct {
+	Source     string `json:"source,omitempty"`
+	Content    string `json:"content"`
+	SenderType string `json:"sender_type,omitempty"`
+	SenderName string `json:"sender_name,omitempty"`
+}
+
+// TestAvatarScriptResponse is what a behavior script asked for in a dry run
+// Error is the runtime error that stopped the script, if any
+type TestAvatarScriptResponse struct {
+	logic.BehaviorResult
+	Error string `json:"error,omitempty"`
+}
+
+// GetAvatarScript handles GET /api/avatars/{id}/script
+func (h *AvatarHandler) GetAvatarScript(w http.ResponseWriter, r *http.Request) {
+	avatarScript, ok := h.getAvatarScript(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(avatarScript)
+}
+
+// SetAvatarScript handles PUT /api/avatars/{id}/script
+// The script is compiled first so syntax errors are reported instead of failing on every message
+func (h *AvatarHandler) SetAvatarScript(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	var req SetAvatarScriptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := compileAvatarScript(w, req.Source); !ok {
+		return
+	}
+
+	if _, err := h.db.GetAvatar(id); err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	before, err := h.db.GetAvatarScript(id)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to get avatar script", http.StatusInternalServerError)
+		return
+	}
+
+	avatarScript, err := h.db.SetAvatarScript(id, req.Source)
+	if err != nil {
+		http.Error(w, "Failed to set avatar script", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Avatar script set avatar_id=%d length=%d", id, len(req.Source))
+	recordAudit(h.db, r, models.AuditActionAvatarScriptUpdate, "avatar", strconv.FormatInt(id, 10),
+		avatarScriptAuditState(before), avatarScriptAuditState(avatarScript))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(avatarScript)
+}
+
+// DeleteAvatarScript handles DELETE /api/avatars/{id}/script
+// The avatar goes back to deciding by mentions and LLM judgment alone
+func (h *AvatarHandler) DeleteAvatarScript(w http.ResponseWriter, r *http.Request) {
+	before, ok := h.getAvatarScript(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteAvatarScript(before.AvatarID); err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to delete avatar script", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Avatar script deleted avatar_id=%d", before.AvatarID)
+	recordAudit(h.db, r, models.AuditActionAvatarScriptDelete, "avatar", strconv.FormatInt(before.AvatarID, 10),
+		avatarScriptAuditState(before), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestAvatarScript handles POST /api/avatars/{id}/script/test
+// Runs a script against a sample message without responding or calling webhooks,
+// so a script can be checked before it is saved
+func (h *AvatarHandler) TestAvatarScript(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TestAvatarScriptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+	if req.SenderType == "" {
+		req.SenderType = string(models.SenderTypeUser)
+	}
+
+	avatar, err := h.db.GetAvatar(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	source := req.Source
+	if source == "" {
+		saved, ok := h.getAvatarScript(w, r)
+		if !ok {
+			return
+		}
+		source = saved.Source
+	}
+	program, ok := compileAvatarScript(w, source)
+	if !ok {
+		return
+	}
+
+	message := logic.BehaviorMessage{SenderType: req.SenderType, SenderName: req.SenderName, Content: req.Content}
+	result, err := logic.RunBehaviorScript(program, logic.BehaviorInput{
+		AvatarName:   avatar.Name,
+		Message:      message,
+		Recent:       []logic.BehaviorMessage{message},
+		WebhookHosts: h.webhookHosts,
+	})
+	resp := TestAvatarScriptResponse{BehaviorResult: *result}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// compileAvatarScript checks the size and syntax of a script, writing an error response if it is invalid
+func compileAvatarScript(w http.ResponseWriter, source string) (*script.Program, bool) {
+	if strings.TrimSpace(source) == "" {
+		http.Error(w, "source is required", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(source) > maxAvatarScriptLength {
+		http.Error(w, "Script too long (max "+strconv.Itoa(maxAvatarScriptLength)+" bytes)", http.StatusBadRequest)
+		return nil, false
+	}
+	program, err := logic.CompileBehaviorScript(source)
+	if err != nil {
+		http.Error(w, "Invalid script: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return program, true
+}
+
+// getAvatarScript loads the behavior script of the avatar in the path, writing an error response if it fails
+func (h *AvatarHandler) getAvatarScript(w http.ResponseWriter, r *http.Request) (*models.AvatarScript, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	avatarScript, err := h.db.GetAvatarScript(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Avatar script not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get avatar script", http.StatusInternalServerError)
+		return nil, false
+	}
+	return avatarScript, true
+}
+
+// avatarScriptAuditState is the audited part of a behavior script, nil when there is none
+func avatarScriptAuditState(s *models.AvatarScript) any {
+	if s == nil {
+		return nil
+	}
+	return map[string]any{"source": s.Source}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestAvatarScript(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	avatar, _ := handler.db.CreateAvatar("Alice", "prompt", "")
+
+	call := func(fn http.HandlerFunc, method, id, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/avatars/"+id+"/script"+path, strings.NewReader(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		fn(w, req)
+		return w
+	}
+
+	if w := call(handler.GetAvatarScript, http.MethodGet, "1", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d without a script, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w := call(handler.SetAvatarScript, http.MethodPut, "1", "", `{"source": "if 'help' in message.content:\n    respond()"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var saved models.AvatarScript
+	json.NewDecoder(w.Body).Decode(&saved)
+	if saved.AvatarID != avatar.ID || !strings.Contains(saved.Source, "respond()") {
+		t.Errorf("unexpected script: %+v", saved)
+	}
+
+	invalid := []struct {
+		id   string
+		body string
+		want int
+		msg  string
+	}{
+		{"1", `{"source": ""}`, http.StatusBadRequest, "source is required"},
+		{"1", `{"source": "if True\n    respond()"}`, http.StatusBadRequest, "script:2:1"},
+		{"1", `{"source": "while True:\n    respond()"}`, http.StatusBadRequest, "does not support while loops"},
+		{"1", `{"source": "reply()"}`, http.StatusBadRequest, "undefined: reply"},
+		{"999", `{"source": "respond()"}`, http.StatusNotFound, ""},
+	}
+	for _, tt := range invalid {
+		w := call(handler.SetAvatarScript, http.MethodPut, tt.id, "", tt.body)
+		if w.Code != tt.want || !strings.Contains(w.Body.String(), tt.msg) {
+			t.Errorf("PUT %s %s: expected status %d with %q, got %d: %s", tt.id, tt.body, tt.want, tt.msg, w.Code, w.Body.String())
+		}
+	}
+
+	// The saved script is tested by default
+	var result TestAvatarScriptResponse
+	w = call(handler.TestAvatarScript, http.MethodPost, "1", "/test", `{"content": "I need help"}`)
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.Decision != "respond" {
+		t.Errorf("expected the saved script to respond, got %d %+v", w.Code, result)
+	}
+
+	// A draft is tested without calling its webhooks, and runtime errors are reported with the output so far
+	draft := `{"content": "hi", "source": "print(avatar.name)\nwebhook('https://example.com', {'a': 1})\nadd_context(message.nope)"}`
+	result = TestAvatarScriptResponse{}
+	w = call(handler.TestAvatarScript, http.MethodPost, "1", "/test", draft)
+	json.NewDecoder(w.Body).Decode(&result)
+	if len(result.Output) != 1 || result.Output[0] != "Alice" || len(result.Webhooks) != 1 || !strings.Contains(result.Error, "line 3") {
+		t.Errorf("unexpected dry run result: %+v", result)
+	}
+
+	if w := call(handler.TestAvatarScript, http.MethodPost, "1", "/test", `{"content": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d without content, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w := call(handler.DeleteAvatarScript, http.MethodDelete, "1", "", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := call(handler.TestAvatarScript, http.MethodPost, "1", "/test", `{"content": "hi"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d testing without a saved script, got %d", http.StatusNotFound, w.Code)
+	}
+
+	entries, _ := handler.db.GetAuditEntries(models.AuditFilter{TargetType: "avatar", Limit: 10})
+	actions := map[string]bool{}
+	for _, e := range entries {
+		actions[e.Action] = true
+	}
+	if !actions[models.AuditActionAvatarScriptUpdate] || !actions[models.AuditActionAvatarScriptDelete] {
+		t.Errorf("expected script changes to be audited, got %v", actions)
+	}
+}
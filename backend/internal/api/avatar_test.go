@@ -5,45 +5,28 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strconv"
 	"strings"
 	"testing"
 
 	"multi-avatar-chat/internal/assistant"
-	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
 )
 
-func setupTestAvatarHandler(t *testing.T) (*AvatarHandler, func()) {
+func setupTestAvatarHandler(t *testing.T) *AvatarHandler {
 	t.Helper()
 
-	tmpFile, err := os.CreateTemp("", "test_avatar_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	database, err := db.NewDB(tmpFile.Name())
-	if err != nil {
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	if err := database.Migrate(); err != nil {
-		t.Fatalf("migration failed: %v", err)
-	}
+	database := testutil.NewTestDB(t)
 
 	handler := NewAvatarHandler(database, nil) // nil assistant for testing
 
-	cleanup := func() {
-		database.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return handler, cleanup
+	return handler
 }
 
 func TestCreateAvatar_Success(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	body := `{"name": "TestBot", "prompt": "You are helpful"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
@@ -73,8 +56,7 @@ func TestCreateAvatar_Success(t *testing.T) {
 }
 
 func TestCreateAvatar_MissingFields(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	testCases := []struct {
 		name string
@@ -101,8 +83,7 @@ func TestCreateAvatar_MissingFields(t *testing.T) {
 }
 
 func TestListAvatars_Empty(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/avatars", nil)
 	w := httptest.NewRecorder()
@@ -124,8 +105,7 @@ func TestListAvatars_Empty(t *testing.T) {
 }
 
 func TestListAvatars_WithData(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	// Create test avatars
 	createBody := `{"name": "Avatar1", "prompt": "Prompt 1"}`
@@ -160,8 +140,7 @@ func TestListAvatars_WithData(t *testing.T) {
 }
 
 func TestGetAvatar_Success(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	// Create test avatar
 	createBody := `{"name": "GetTest", "prompt": "Test prompt"}`
@@ -194,8 +173,7 @@ func TestGetAvatar_Success(t *testing.T) {
 }
 
 func TestGetAvatar_NotFound(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/avatars/99999", nil)
 	req.SetPathValue("id", "99999")
@@ -209,8 +187,7 @@ func TestGetAvatar_NotFound(t *testing.T) {
 }
 
 func TestUpdateAvatar_Success(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	// Create test avatar
 	createBody := `{"name": "Original", "prompt": "Original prompt"}`
@@ -244,9 +221,89 @@ func TestUpdateAvatar_Success(t *testing.T) {
 	}
 }
 
+func TestUpdateAvatar_KeepsUserPriorityInstruction(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	client, instructions := newInstructionsClient(t, "")
+	handler.assistant = client
+	avatar, _ := handler.db.CreateAvatar("Alice", "Old prompt", "asst_alice")
+
+	body := `{"name": "Alice", "prompt": "New prompt"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/avatars/1", bytes.NewBufferString(body))
+	req.SetPathValue("id", strconv.FormatInt(avatar.ID, 10))
+	w := httptest.NewRecorder()
+	handler.Update(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := instructions["asst_alice"]; got != logic.UserPriorityInstruction+"New prompt" {
+		t.Errorf("expected the updated instructions to keep the user priority instruction, got %q", got)
+	}
+}
+
+func TestAvatar_ConditionalRequests(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	avatar, _ := handler.db.CreateAvatar("Alice", "Prompt", "")
+	id := strconv.FormatInt(avatar.ID, 10)
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/avatars/"+id, nil)
+		req.SetPathValue("id", id)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		handler.Get(w, req)
+		return w
+	}
+	update := func(prompt, ifMatch string) *httptest.ResponseRecorder {
+		body := `{"name": "Alice", "prompt": "` + prompt + `"}`
+		req := httptest.NewRequest(http.MethodPut, "/api/avatars/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		return w
+	}
+
+	w := get("", "")
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected ETag and Last-Modified headers, got %v", w.Header())
+	}
+	var response AvatarResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.UpdatedAt == "" {
+		t.Error("expected updated_at in the response")
+	}
+	if w := get("If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+
+	// The first editor saves; the second still holds the old ETag
+	w = update("First edit", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	newETag := w.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("expected a new ETag after the update, got %q", newETag)
+	}
+	if w := update("Second edit", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale If-Match, got %d", w.Code)
+	}
+	if got, _ := handler.db.GetAvatar(avatar.ID); got.Prompt != "First edit" {
+		t.Errorf("expected the first edit to be kept, got %q", got.Prompt)
+	}
+	if w := get("If-None-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for an outdated ETag, got %d", w.Code)
+	}
+}
+
 func TestDeleteAvatar_Success(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	// Create test avatar
 	createBody := `{"name": "ToDelete", "prompt": "Delete me"}`
@@ -277,8 +334,7 @@ func TestDeleteAvatar_Success(t *testing.T) {
 }
 
 func TestDeleteAvatar_NotFound(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/avatars/99999", nil)
 	req.SetPathValue("id", "99999")
@@ -292,8 +348,7 @@ func TestDeleteAvatar_NotFound(t *testing.T) {
 }
 
 func TestCreateAvatar_AddsUserPriorityPrompt(t *testing.T) {
-	handler, cleanup := setupTestAvatarHandler(t)
-	defer cleanup()
+	handler := setupTestAvatarHandler(t)
 
 	// Create a mock HTTP server that captures the request body
 	var capturedInstructions string
@@ -324,7 +379,7 @@ func TestCreateAvatar_AddsUserPriorityPrompt(t *testing.T) {
 
 	// Create assistant client pointing to mock server
 	httpClient := &http.Client{
-		Transport: &mockTransport{baseURL: mockServer.URL},
+		Transport: &testutil.RedirectTransport{BaseURL: mockServer.URL, TrimPrefix: "/v1"},
 	}
 	assistantClient := assistant.NewClient("test-api-key", assistant.WithHTTPClient(httpClient))
 	handler.assistant = assistantClient
@@ -352,46 +407,519 @@ func TestCreateAvatar_AddsUserPriorityPrompt(t *testing.T) {
 	}
 }
 
-// mockTransport redirects requests to a mock server
-type mockTransport struct {
-	baseURL string
+func TestCreateAvatar_AssignsDisplayMetadata(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "ColorBot", "prompt": "You are colorful"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	var response AvatarResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Color == "" || response.Emoji == "" {
+		t.Errorf("expected color and emoji to be assigned, got color=%q emoji=%q", response.Color, response.Emoji)
+	}
+}
+
+func TestCreateAvatar_ExplicitDisplayMetadata(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "ColorBot", "prompt": "You are colorful", "color": "#112233", "emoji": "🤖"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	// Verify the override is persisted
+	req = httptest.NewRequest(http.MethodGet, "/api/avatars/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+
+	var response AvatarResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Color != "#112233" {
+		t.Errorf("expected color '#112233', got %q", response.Color)
+	}
+	if response.Emoji != "🤖" {
+		t.Errorf("expected emoji '🤖', got %q", response.Emoji)
+	}
+}
+
+func TestCreateAvatar_InvalidColor(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "ColorBot", "prompt": "You are colorful", "color": "red"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestAvatar_PromptVariables(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "Guide", "prompt": "Host {{conversation_title}} on {{today}}"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created AvatarResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Prompt != "Host {{conversation_title}} on {{today}}" {
+		t.Errorf("expected the prompt to be stored unresolved, got %q", created.Prompt)
+	}
+
+	body = `{"name": "Guide", "prompt": "Host {{conversation_title}} for {{customer}}"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "{{customer}}") {
+		t.Errorf("expected unknown variable to be rejected on create, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/avatars/"+strconv.FormatInt(created.ID, 10), bytes.NewBufferString(body))
+	req.SetPathValue("id", strconv.FormatInt(created.ID, 10))
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "{{customer}}") {
+		t.Errorf("expected unknown variable to be rejected on update, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateAvatar_PrefilterTuning(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "Chef", "prompt": "You are a chef", "keywords": ["pasta", " ", "pizza"], "relevance_threshold": 0.3}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/avatars/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+
+	var response AvatarResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(response.Keywords) != 2 || response.Keywords[0] != "pasta" || response.Keywords[1] != "pizza" {
+		t.Errorf("expected keywords [pasta pizza], got %v", response.Keywords)
+	}
+	if response.RelevanceThreshold != 0.3 {
+		t.Errorf("expected relevance_threshold 0.3, got %v", response.RelevanceThreshold)
+	}
+}
+
+func TestUpdateAvatar_PrefilterTuning(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	createBody := `{"name": "Chef", "prompt": "You are a chef", "keywords": ["pasta"], "relevance_threshold": 0.3}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(createBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	// Omitted keywords keep their current value
+	updateBody := `{"name": "Chef", "prompt": "You are a chef", "relevance_threshold": 0.5}`
+	req = httptest.NewRequest(http.MethodPut, "/api/avatars/1", bytes.NewBufferString(updateBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response AvatarResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(response.Keywords) != 1 || response.Keywords[0] != "pasta" {
+		t.Errorf("expected keywords to be kept, got %v", response.Keywords)
+	}
+	if response.RelevanceThreshold != 0.5 {
+		t.Errorf("expected relevance_threshold 0.5, got %v", response.RelevanceThreshold)
+	}
+}
+
+func TestCreateAvatar_InvalidRelevanceThreshold(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "Chef", "prompt": "You are a chef", "relevance_threshold": 1.5}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
 }
 
-func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Redirect OpenAI API calls to mock server
-	// Parse the baseURL to extract host and port
-	baseURL := t.baseURL
-	if strings.HasPrefix(baseURL, "http://") {
-		baseURL = baseURL[7:]
-	} else if strings.HasPrefix(baseURL, "https://") {
-		baseURL = baseURL[8:]
-	}
-	
-	// Extract host and port
-	parts := strings.Split(baseURL, "/")
-	host := parts[0]
-	
-	// Create new request with mock server URL
-	// Remove /v1 prefix from path if present, as mock server handles both
-	path := req.URL.Path
-	if strings.HasPrefix(path, "/v1") {
-		path = path[3:]
-	}
-	newURL := "http://" + host + path
-	if req.URL.RawQuery != "" {
-		newURL += "?" + req.URL.RawQuery
-	}
-	
-	newReq, err := http.NewRequest(req.Method, newURL, req.Body)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Copy headers
-	for k, v := range req.Header {
-		newReq.Header[k] = v
-	}
-	
-	return http.DefaultTransport.RoundTrip(newReq)
+func TestAvatarFormattingRules(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "Reporter", "prompt": "You report", "formatting_rules": {"name_tag": true, "max_paragraphs": 2}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created AvatarResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if !created.FormattingRules.NameTag || created.FormattingRules.MaxParagraphs != 2 || created.FormattingRules.BulletLists {
+		t.Errorf("unexpected formatting rules after create: %+v", created.FormattingRules)
+	}
+
+	id := strconv.FormatInt(created.ID, 10)
+	body = `{"name": "Reporter", "prompt": "You report", "formatting_rules": {"bullet_lists": true}}`
+	req = httptest.NewRequest(http.MethodPut, "/api/avatars/"+id, bytes.NewBufferString(body))
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+
+	var updated AvatarResponse
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.FormattingRules != (models.FormattingRules{BulletLists: true}) {
+		t.Errorf("expected formatting rules to be replaced, got %+v", updated.FormattingRules)
+	}
+
+	body = `{"name": "Reporter", "prompt": "You report", "formatting_rules": {"max_paragraphs": -1}}`
+	req = httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a negative paragraph limit, got %d", http.StatusBadRequest, w.Code)
+	}
 }
 
+func TestAvatarIsolatedContext(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	body := `{"name": "Judge", "prompt": "You judge", "isolated_context": true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created AvatarResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if !created.IsolatedContext {
+		t.Errorf("expected the avatar to be created isolated")
+	}
+
+	// Omitting the flag keeps it, setting it to false turns it off
+	id := strconv.FormatInt(created.ID, 10)
+	for _, tt := range []struct {
+		body string
+		want bool
+	}{
+		{`{"name": "Judge", "prompt": "You judge"}`, true},
+		{`{"name": "Judge", "prompt": "You judge", "isolated_context": false}`, false},
+	} {
+		req = httptest.NewRequest(http.MethodPut, "/api/avatars/"+id, bytes.NewBufferString(tt.body))
+		req.SetPathValue("id", id)
+		w = httptest.NewRecorder()
+		handler.Update(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var updated AvatarResponse
+		json.NewDecoder(w.Body).Decode(&updated)
+		if updated.IsolatedContext != tt.want {
+			t.Errorf("%s: expected isolated_context %v, got %v", tt.body, tt.want, updated.IsolatedContext)
+		}
+	}
+}
+
+func TestAvatarCapabilities(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	// Record the tools sent with each assistant request, and the metadata sent to tag the assistant
+	var capturedTools [][]assistant.Tool
+	var capturedMetadata []assistant.Metadata
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody struct {
+			Tools    []assistant.Tool   `json:"tools"`
+			Metadata assistant.Metadata `json:"metadata"`
+		}
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		if r.URL.Path != "/assistants" && reqBody.Metadata != nil {
+			capturedMetadata = append(capturedMetadata, reqBody.Metadata)
+		} else {
+			capturedTools = append(capturedTools, reqBody.Tools)
+		}
+		json.NewEncoder(w).Encode(assistant.Assistant{ID: "asst_test"})
+	}))
+	defer mockServer.Close()
+
+	handler.assistant = assistant.NewClient("test-api-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: mockServer.URL, TrimPrefix: "/v1"},
+	}))
+
+	body := `{"name": "Researcher", "prompt": "You research", "can_search": true, "can_cite": true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created AvatarResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if !created.CanSearch || created.CanCode || !created.CanCite {
+		t.Errorf("unexpected capabilities after create: %+v", created)
+	}
+	if len(capturedTools) != 1 || len(capturedTools[0]) != 1 || capturedTools[0][0].Type != assistant.ToolFileSearch {
+		t.Errorf("expected assistant created with file_search, got %+v", capturedTools)
+	}
+	if len(capturedMetadata) != 1 || !capturedMetadata[0].IsApp() ||
+		capturedMetadata[0][assistant.MetadataKeyAvatarID] != strconv.FormatInt(created.ID, 10) {
+		t.Errorf("expected the assistant to be tagged with the avatar, got %+v", capturedMetadata)
+	}
+
+	// Switching capabilities replaces the assistant's tools and keeps omitted flags
+	body = `{"name": "Researcher", "prompt": "You research", "can_search": false, "can_code": true}`
+	req = httptest.NewRequest(http.MethodPut, "/api/avatars/1", bytes.NewBufferString(body))
+	req.SetPathValue("id", strconv.FormatInt(created.ID, 10))
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var updated AvatarResponse
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.CanSearch || !updated.CanCode || !updated.CanCite {
+		t.Errorf("unexpected capabilities after update: %+v", updated)
+	}
+	if len(capturedTools) != 2 || len(capturedTools[1]) != 1 || capturedTools[1][0].Type != assistant.ToolCodeInterpreter {
+		t.Errorf("expected assistant tools replaced with code_interpreter, got %+v", capturedTools)
+	}
+
+	stored, _ := handler.db.GetAvatar(created.ID)
+	if stored.CanSearch || !stored.CanCode || !stored.CanCite {
+		t.Errorf("unexpected stored capabilities: %+v", stored)
+	}
+}
+
+func TestCreateAvatar_OpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected int
+		message  string
+	}{
+		{"rate limited", http.StatusTooManyRequests,
+			`{"error": {"message": "Rate limit reached", "code": "rate_limit_exceeded"}}`,
+			http.StatusServiceUnavailable, "OpenAI is busy"},
+		{"auth", http.StatusUnauthorized,
+			`{"error": {"message": "Incorrect API key provided", "code": "invalid_api_key"}}`,
+			http.StatusBadGateway, "API key was rejected"},
+		{"invalid request", http.StatusBadRequest,
+			`{"error": {"message": "Invalid value for 'model'", "type": "invalid_request_error"}}`,
+			http.StatusBadRequest, "Invalid value for 'model'"},
+	}
+
+	for _, tt := range tests {
+		handler := setupTestAvatarHandler(t)
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		handler.assistant = assistant.NewClient("test-api-key", assistant.WithHTTPClient(&http.Client{
+			Transport: &testutil.RedirectTransport{BaseURL: mockServer.URL, TrimPrefix: "/v1"},
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewBufferString(`{"name": "TestBot", "prompt": "You are helpful"}`))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+
+		if w.Code != tt.expected || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("%s: expected status %d with %q, got %d: %s", tt.name, tt.expected, tt.message, w.Code, w.Body.String())
+		}
+
+		mockServer.Close()
+	}
+}
+
+func TestAvatar_DuplicateName(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	create := func(name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(CreateAvatarRequest{Name: name, Prompt: "Prompt"})
+		req := httptest.NewRequest(http.MethodPost, "/api/avatars", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+		return w
+	}
+
+	if w := create("Alice"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	create("Bob")
+
+	w := create("ＡＬＩＣＥ")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `try "ＡＬＩＣＥ2"`) {
+		t.Errorf("expected a suggested name in the error, got %q", w.Body.String())
+	}
+
+	rename := func(id, name string) int {
+		body, _ := json.Marshal(UpdateAvatarRequest{Name: name, Prompt: "Prompt"})
+		req := httptest.NewRequest(http.MethodPut, "/api/avatars/"+id, bytes.NewReader(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		return w.Code
+	}
+	if code := rename("2", "alice"); code != http.StatusConflict {
+		t.Errorf("expected status %d renaming to a taken name, got %d", http.StatusConflict, code)
+	}
+	if code := rename("1", "ALICE"); code != http.StatusOK {
+		t.Errorf("expected status %d changing the case of the own name, got %d", http.StatusOK, code)
+	}
+}
+
+func TestUpdateAvatar_RenameNotice(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	avatar, _ := handler.db.CreateAvatar("Alice", "Prompt", "")
+	conv, _ := handler.db.CreateConversation("Chat", "")
+	other, _ := handler.db.CreateConversation("Other", "")
+	handler.db.AddAvatarToConversation(conv.ID, avatar.ID)
+
+	update := func(name string) {
+		body, _ := json.Marshal(UpdateAvatarRequest{Name: name, Prompt: "Prompt"})
+		req := httptest.NewRequest(http.MethodPut, "/api/avatars/1", bytes.NewReader(body))
+		req.SetPathValue("id", strconv.FormatInt(avatar.ID, 10))
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	}
+
+	update("Alicia")
+	messages, _ := handler.db.GetMessages(conv.ID)
+	if len(messages) != 1 || messages[0].SenderType != models.SenderTypeSystem ||
+		messages[0].Content != logic.FormatRenameNotice("Alice", "Alicia") {
+		t.Fatalf("expected a rename notice, got %+v", messages)
+	}
+	if messages, _ := handler.db.GetMessages(other.ID); len(messages) != 0 {
+		t.Errorf("expected no notice in conversations without the avatar, got %d messages", len(messages))
+	}
+
+	// Updates that keep the name post nothing
+	update("Alicia")
+	if messages, _ := handler.db.GetMessages(conv.ID); len(messages) != 1 {
+		t.Errorf("expected no notice without a rename, got %d messages", len(messages))
+	}
+
+	handler.SetRenameNotices(false)
+	update("Alice")
+	if messages, _ := handler.db.GetMessages(conv.ID); len(messages) != 1 {
+		t.Errorf("expected no notice with rename notices disabled, got %d messages", len(messages))
+	}
+}
+
+func TestRecreateAssistant(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	avatar, _ := handler.db.CreateAvatar("Researcher", "You research", "asst_deleted")
+	handler.db.UpdateAvatarCapabilities(avatar.ID, true, false, false)
+	handler.db.MarkAvatarNeedsRelink(avatar.ID, "asst_deleted")
+
+	recreate := func(id int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/avatars/1/recreate-assistant", nil)
+		req.SetPathValue("id", strconv.FormatInt(id, 10))
+		w := httptest.NewRecorder()
+		handler.RecreateAssistant(w, req)
+		return w
+	}
+
+	if w := recreate(avatar.ID); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d without OpenAI, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	// Record the assistant created for the avatar
+	var created struct {
+		Name         string           `json:"name"`
+		Instructions string           `json:"instructions"`
+		Tools        []assistant.Tool `json:"tools"`
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/assistants" {
+			json.NewDecoder(r.Body).Decode(&created)
+		}
+		json.NewEncoder(w).Encode(assistant.Assistant{ID: "asst_new"})
+	}))
+	defer mockServer.Close()
+	handler.assistant = assistant.NewClient("test-api-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: mockServer.URL, TrimPrefix: "/v1"},
+	}))
+
+	w := recreate(avatar.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response AvatarResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.OpenAIAssistantID != "asst_new" || response.NeedsRelink {
+		t.Errorf("unexpected response: %+v", response)
+	}
+	if created.Name != "Researcher" || !strings.Contains(created.Instructions, "You research") ||
+		len(created.Tools) != 1 || created.Tools[0].Type != assistant.ToolFileSearch {
+		t.Errorf("unexpected assistant request: %+v", created)
+	}
+
+	stored, _ := handler.db.GetAvatar(avatar.ID)
+	if stored.OpenAIAssistantID != "asst_new" || stored.NeedsRelink {
+		t.Errorf("unexpected stored avatar: %+v", stored)
+	}
+
+	if w := recreate(999); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing avatar, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// maxAvatarTriggers is how many triggers an avatar can have in one conversation
+	maxAvatarTriggers = 20
+	// maxTriggerPatternLength is the longest trigger keyword or pattern accepted, in bytes
+	maxTriggerPatternLength = 200
+)
+
+// CreateAvatarTriggerRequest is the request body of POST /api/conversations/{id}/avatars/{avatar_id}/triggers
+// Pattern is a keyword unless Regex is set
+type CreateAvatarTriggerRequest struct {
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex"`
+}
+
+// ListTriggers handles GET /api/conversations/{id}/avatars/{avatar_id}/triggers
+func (h *ConversationAvatarHandler) ListTriggers(w http.ResponseWriter, r *http.Request) {
+	conversationID, avatarID, ok := h.participantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	triggers, err := h.db.GetAvatarTriggers(conversationID, avatarID)
+	if err != nil {
+		log.Printf("[API] ListTriggers failed: DB error err=%v", err)
+		http.Error(w, "Failed to get triggers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(triggers)
+}
+
+// CreateTrigger handles POST /api/conversations/{id}/avatars/{avatar_id}/triggers
+// A message matching the trigger makes the avatar respond without asking the LLM
+func (h *ConversationAvatarHandler) CreateTrigger(w http.ResponseWriter, r *http.Request) {
+	conversationID, avatarID, ok := h.participantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAvatarTriggerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Pattern) == "" {
+		http.Error(w, "pattern is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Pattern) > maxTriggerPatternLength {
+		http.Error(w, "Pattern too long (max "+strconv.Itoa(maxTriggerPatternLength)+" bytes)", http.StatusBadRequest)
+		return
+	}
+	if _, err := logic.CompileTrigger(req.Pattern, req.Regex); err != nil {
+		http.Error(w, "Invalid pattern: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.db.GetAvatarTriggers(conversationID, avatarID)
+	if err != nil {
+		log.Printf("[API] CreateTrigger failed: DB error getting triggers err=%v", err)
+		http.Error(w, "Failed to get triggers", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxAvatarTriggers {
+		http.Error(w, "Too many triggers (max "+strconv.Itoa(maxAvatarTriggers)+")", http.StatusConflict)
+		return
+	}
+
+	trigger, err := h.db.CreateAvatarTrigger(conversationID, avatarID, req.Pattern, req.Regex)
+	if err != nil {
+		log.Printf("[API] CreateTrigger failed: DB error err=%v", err)
+		http.Error(w, "Failed to create trigger", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Avatar trigger created conversation_id=%d avatar_id=%d trigger_id=%d regex=%v",
+		conversationID, avatarID, trigger.ID, trigger.Regex)
+	recordAudit(h.db, r, models.AuditActionAvatarTriggerCreate, "conversation", strconv.FormatInt(conversationID, 10),
+		nil, avatarTriggerAuditState(trigger))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(trigger)
+}
+
+// DeleteTrigger handles DELETE /api/conversations/{id}/avatars/{avatar_id}/triggers/{trigger_id}
+func (h *ConversationAvatarHandler) DeleteTrigger(w http.ResponseWriter, r *http.Request) {
+	conversationID, avatarID, ok := h.participantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	triggerID, err := strconv.ParseInt(r.PathValue("trigger_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid trigger ID", http.StatusBadRequest)
+		return
+	}
+
+	trigger, err := h.db.DeleteAvatarTrigger(conversationID, avatarID, triggerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Trigger not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] DeleteTrigger failed: DB error err=%v", err)
+		http.Error(w, "Failed to delete trigger", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Avatar trigger deleted conversation_id=%d avatar_id=%d trigger_id=%d", conversationID, avatarID, triggerID)
+	recordAudit(h.db, r, models.AuditActionAvatarTriggerDelete, "conversation", strconv.FormatInt(conversationID, 10),
+		avatarTriggerAuditState(trigger), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// participantFromPath reads the conversation and avatar IDs in the path and checks the avatar takes part,
+// writing an error response if it does not
+func (h *ConversationAvatarHandler) participantFromPath(w http.ResponseWriter, r *http.Request) (conversationID, avatarID int64, ok bool) {
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	avatarID, err = strconv.ParseInt(r.PathValue("avatar_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	if _, err := h.db.GetAvatarThreadID(conversationID, avatarID); err == sql.ErrNoRows {
+		http.Error(w, "Avatar not in conversation", http.StatusNotFound)
+		return 0, 0, false
+	} else if err != nil {
+		log.Printf("[API] Failed to get participant conversation_id=%d avatar_id=%d err=%v", conversationID, avatarID, err)
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	return conversationID, avatarID, true
+}
+
+// avatarTriggerAuditState is the audited part of a trigger
+func avatarTriggerAuditState(t *models.AvatarTrigger) any {
+	return map[string]any{"avatar_id": t.AvatarID, "trigger_id": t.ID, "pattern": t.Pattern, "regex": t.Regex}
+}
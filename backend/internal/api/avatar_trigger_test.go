@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestAvatarTriggers_CreateListDelete(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	avatar, _ := database.CreateAvatar("LegalBot", "Prompt", "asst_123")
+	database.AddAvatarToConversation(conv.ID, avatar.ID)
+
+	convID := strconv.FormatInt(conv.ID, 10)
+	avatarID := strconv.FormatInt(avatar.ID, 10)
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/"+convID+"/avatars/"+avatarID+"/triggers", bytes.NewBufferString(body))
+		req.SetPathValue("id", convID)
+		req.SetPathValue("avatar_id", avatarID)
+		w := httptest.NewRecorder()
+		handler.CreateTrigger(w, req)
+		return w
+	}
+
+	w := create(`{"pattern": "contract"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created models.AvatarTrigger
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Pattern != "contract" || created.Regex || created.AvatarID != avatar.ID {
+		t.Errorf("unexpected trigger: %+v", created)
+	}
+
+	for _, body := range []string{`{"pattern": "  "}`, `{"pattern": "(", "regex": true}`, `{"pattern": "` + strings.Repeat("a", maxTriggerPatternLength+1) + `"}`} {
+		if w := create(body); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, body, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/"+convID+"/avatars/"+avatarID+"/triggers", nil)
+	req.SetPathValue("id", convID)
+	req.SetPathValue("avatar_id", avatarID)
+	w = httptest.NewRecorder()
+	handler.ListTriggers(w, req)
+	var triggers []models.AvatarTrigger
+	json.NewDecoder(w.Body).Decode(&triggers)
+	if len(triggers) != 1 || triggers[0].ID != created.ID {
+		t.Fatalf("expected the created trigger, got %+v", triggers)
+	}
+
+	triggerID := strconv.FormatInt(created.ID, 10)
+	del := func() int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/conversations/"+convID+"/avatars/"+avatarID+"/triggers/"+triggerID, nil)
+		req.SetPathValue("id", convID)
+		req.SetPathValue("avatar_id", avatarID)
+		req.SetPathValue("trigger_id", triggerID)
+		w := httptest.NewRecorder()
+		handler.DeleteTrigger(w, req)
+		return w.Code
+	}
+	if code := del(); code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, code)
+	}
+	if code := del(); code != http.StatusNotFound {
+		t.Errorf("expected status %d deleting again, got %d", http.StatusNotFound, code)
+	}
+
+	entries, _ := database.GetAuditEntries(models.AuditFilter{Action: models.AuditActionAvatarTriggerDelete, Limit: 10})
+	if len(entries) != 1 {
+		t.Errorf("expected the deletion to be audited, got %d entries", len(entries))
+	}
+}
+
+func TestAvatarTriggers_AvatarNotInConversation(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	avatar, _ := database.CreateAvatar("LegalBot", "Prompt", "asst_123")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/avatars/1/triggers", bytes.NewBufferString(`{"pattern": "contract"}`))
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	req.SetPathValue("avatar_id", strconv.FormatInt(avatar.ID, 10))
+	w := httptest.NewRecorder()
+	handler.CreateTrigger(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestAvatarTriggers_Limit(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	avatar, _ := database.CreateAvatar("LegalBot", "Prompt", "asst_123")
+	database.AddAvatarToConversation(conv.ID, avatar.ID)
+	for i := 0; i < maxAvatarTriggers; i++ {
+		database.CreateAvatarTrigger(conv.ID, avatar.ID, "keyword"+strconv.Itoa(i), false)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/avatars/1/triggers", bytes.NewBufferString(`{"pattern": "contract"}`))
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	req.SetPathValue("avatar_id", strconv.FormatInt(avatar.ID, 10))
+	w := httptest.NewRecorder()
+	handler.CreateTrigger(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
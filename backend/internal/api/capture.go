@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// DefaultCaptureLimit is how many captures are kept when recording is enabled
+	DefaultCaptureLimit = 500
+	// maxCaptureBodySize truncates recorded request and response bodies
+	maxCaptureBodySize = 64 << 10
+
+	defaultCaptureDownloadLimit = 1000
+	maxCaptureDownloadLimit     = 10000
+
+	// capturePath is the capture download endpoint, which is never recorded itself
+	capturePath = "/api/admin/captures"
+
+	redactedValue = "[REDACTED]"
+)
+
+// secretHeaders are recorded as redactedValue
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// secretFieldNames are substrings of JSON field names whose values are recorded as redactedValue
+// Names ending in "token" are secret too; "tokens" (usage counts) are not
+var secretFieldNames = []string{"api_key", "apikey", "password", "secret"}
+
+// CaptureHandler records API requests and responses in debug mode and serves the capture
+type CaptureHandler struct {
+	db *db.DB
+	// keep is how many captures are kept; 0 means recording is off
+	keep atomic.Int64
+}
+
+// NewCaptureHandler creates a capture handler with recording off
+func NewCaptureHandler(database *db.DB) *CaptureHandler {
+	return &CaptureHandler{db: database}
+}
+
+// SetRecording enables recording, keeping the newest keep captures; 0 disables it
+func (h *CaptureHandler) SetRecording(keep int) {
+	h.keep.Store(int64(keep))
+}
+
+// Recording reports whether requests are being recorded
+func (h *CaptureHandler) Recording() bool {
+	return h.keep.Load() > 0
+}
+
+// Download handles GET /api/admin/captures
+// Returns captures oldest first as a JSON attachment. Query: after_id, limit
+func (h *CaptureHandler) Download(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var afterID int64
+	if v := query.Get("after_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, "Invalid after_id", http.StatusBadRequest)
+			return
+		}
+		afterID = id
+	}
+
+	limit := defaultCaptureDownloadLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxCaptureDownloadLimit)
+	}
+
+	captures, err := h.db.GetHTTPCaptures(afterID, limit)
+	if err != nil {
+		log.Printf("[API] DownloadCaptures failed: DB error err=%v", err)
+		http.Error(w, "Failed to get captures", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="http-capture.json"`)
+	json.NewEncoder(w).Encode(captures)
+}
+
+// Clear handles DELETE /api/admin/captures
+func (h *CaptureHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.db.DeleteHTTPCaptures()
+	if err != nil {
+		log.Printf("[API] ClearCaptures failed: DB error err=%v", err)
+		http.Error(w, "Failed to clear captures", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Captures cleared count=%d", deleted)
+	recordAudit(h.db, r, models.AuditActionCapturesClear, "captures", "", nil, map[string]int64{"deleted": deleted})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted})
+}
+
+// pendingCapture is a request being recorded
+type pendingCapture struct {
+	capture  models.HTTPCapture
+	response *captureWriter
+}
+
+// begin starts recording a request if recording is on and the request is capturable
+// The request body is read up to the size limit and replaced so handlers still see all of it.
+// Returns nil when the request is not recorded
+func (h *CaptureHandler) begin(req *http.Request) *pendingCapture {
+	if !h.Recording() || req.URL.Path == capturePath {
+		return nil
+	}
+
+	p := &pendingCapture{
+		capture: models.HTTPCapture{
+			Method:         req.Method,
+			Path:           req.URL.Path,
+			Query:          req.URL.RawQuery,
+			RequestHeaders: captureHeaders(req.Header),
+		},
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxCaptureBodySize+1))
+		if err != nil {
+			log.Printf("[API] Warning: failed to read request body for capture path=%s err=%v", req.URL.Path, err)
+		}
+		req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+
+		if len(body) > maxCaptureBodySize {
+			body = body[:maxCaptureBodySize]
+			p.capture.Truncated = true
+		}
+		p.capture.RequestBody = redactBody(body)
+	}
+	return p
+}
+
+// wrap returns a response writer that copies the response body into the capture
+func (p *pendingCapture) wrap(w http.ResponseWriter) http.ResponseWriter {
+	p.response = &captureWriter{ResponseWriter: w}
+	return p.response
+}
+
+// finish stores the recorded request; failures are logged and never fail the request
+func (h *CaptureHandler) finish(p *pendingCapture, status int, duration time.Duration) {
+	p.capture.Status = status
+	p.capture.DurationMS = duration.Milliseconds()
+	p.capture.ResponseHeaders = captureHeaders(p.response.Header())
+	p.capture.ResponseBody = redactBody(p.response.body.Bytes())
+	p.capture.Truncated = p.capture.Truncated || p.response.truncated
+
+	if err := h.db.CreateHTTPCapture(&p.capture, int(h.keep.Load())); err != nil {
+		log.Printf("[API] Warning: failed to record capture method=%s path=%s err=%v", p.capture.Method, p.capture.Path, err)
+	}
+}
+
+// readCloser combines a reader with the closer of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter copies up to maxCaptureBodySize bytes of a response body
+type captureWriter struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	room := maxCaptureBodySize - cw.body.Len()
+	if len(b) > room {
+		cw.truncated = true
+	}
+	if room > 0 {
+		cw.body.Write(b[:min(len(b), room)])
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// captureHeaders flattens headers, redacting the ones carrying credentials
+func captureHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			headers[name] = redactedValue
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// jsonStringFieldRegex matches a JSON field with a string value, for bodies truncated into invalid JSON
+var jsonStringFieldRegex = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*"(?:[^"\\]|\\.)*"?`)
+
+// redactBody returns a body as text, redacting secret fields when it is JSON
+func redactBody(body []byte) string {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		// Truncated JSON cannot be decoded, so its string fields are redacted textually
+		return jsonStringFieldRegex.ReplaceAllStringFunc(string(body), func(field string) string {
+			name := jsonStringFieldRegex.FindStringSubmatch(field)[1]
+			if !isSecretField(name) {
+				return field
+			}
+			return `"` + name + `":"` + redactedValue + `"`
+		})
+	}
+	if !redactSecretFields(value) {
+		return string(body)
+	}
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return string(body)
+	}
+	return string(redacted)
+}
+
+// redactSecretFields replaces the values of secret fields in a decoded JSON value
+// Returns whether anything was replaced
+func redactSecretFields(value any) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if isSecretField(key) {
+				v[key] = redactedValue
+				changed = true
+				continue
+			}
+			changed = redactSecretFields(field) || changed
+		}
+	case []any:
+		for _, item := range v {
+			changed = redactSecretFields(item) || changed
+		}
+	}
+	return changed
+}
+
+// isSecretField reports whether a JSON field name looks like it holds a credential
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, "token") {
+		return true
+	}
+	for _, secret := range secretFieldNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
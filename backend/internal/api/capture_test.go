@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestCaptureRouter(t *testing.T) (*Router, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	router := NewRouter(database, nil, "", nil)
+	t.Cleanup(func() { router.GetBroadcaster().Shutdown(0) })
+	return router, database
+}
+
+func TestCapture_RecordsAPIRequests(t *testing.T) {
+	router, database := setupTestCaptureRouter(t)
+
+	// Nothing is recorded until recording is enabled
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/avatars", nil))
+	router.SetCapture(10)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/avatars?source=test", strings.NewReader(`{"name":"Alice","prompt":"p"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected the avatar to be created with the body intact, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	captures, err := database.GetHTTPCaptures(0, 10)
+	if err != nil {
+		t.Fatalf("failed to get captures: %v", err)
+	}
+	if len(captures) != 1 {
+		t.Fatalf("expected 1 capture, got %d", len(captures))
+	}
+	c := captures[0]
+	if c.Method != http.MethodPost || c.Path != "/api/avatars" || c.Query != "source=test" || c.Status != http.StatusCreated {
+		t.Errorf("unexpected capture %+v", c)
+	}
+	if c.RequestBody != `{"name":"Alice","prompt":"p"}` || !strings.Contains(c.ResponseBody, `"name":"Alice"`) {
+		t.Errorf("expected the request and response bodies, got %q and %q", c.RequestBody, c.ResponseBody)
+	}
+	if c.RequestHeaders["Authorization"] != redactedValue {
+		t.Errorf("expected the Authorization header to be redacted, got %q", c.RequestHeaders["Authorization"])
+	}
+
+	// Downloading is not recorded itself
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, capturePath, nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("expected a capture attachment, got %d %q", rec.Code, rec.Header().Get("Content-Disposition"))
+	}
+	var downloaded []models.HTTPCapture
+	if err := json.NewDecoder(rec.Body).Decode(&downloaded); err != nil {
+		t.Fatalf("failed to decode download: %v", err)
+	}
+	if len(downloaded) != 1 || downloaded[0].ID != c.ID {
+		t.Errorf("expected the recorded capture, got %+v", downloaded)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, capturePath, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted":1`) {
+		t.Errorf("expected 1 capture cleared, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCapture_NotRecordedOutsideAPI(t *testing.T) {
+	router, database := setupTestCaptureRouter(t)
+	router.SetCapture(10)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	captures, _ := database.GetHTTPCaptures(0, 10)
+	if len(captures) != 0 {
+		t.Errorf("expected only /api/ requests to be recorded, got %+v", captures)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"plain text", "hello", "hello"},
+		{"no secrets", `{"name":"Alice"}`, `{"name":"Alice"}`},
+		{"nested secrets", `{"config":{"api_key":"sk-1","items":[{"password":"p"}]},"total_tokens":3}`,
+			`{"config":{"api_key":"[REDACTED]","items":[{"password":"[REDACTED]"}]},"total_tokens":3}`},
+		{"access token", `{"accessToken":"abc"}`, `{"accessToken":"[REDACTED]"}`},
+		{"truncated JSON", `{"name":"Alice","client_secret":"s3cr`, `{"name":"Alice","client_secret":"[REDACTED]"`},
+	}
+	for _, tt := range tests {
+		if got := redactBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCaptureWriter_Truncates(t *testing.T) {
+	rec := httptest.NewRecorder()
+	cw := &captureWriter{ResponseWriter: rec}
+
+	cw.Write([]byte(strings.Repeat("a", maxCaptureBodySize-1)))
+	if cw.truncated {
+		t.Fatal("expected a body within the limit not to be truncated")
+	}
+	cw.Write([]byte("bc"))
+	if !cw.truncated || cw.body.Len() != maxCaptureBodySize {
+		t.Errorf("expected the capture to stop at the limit, got truncated=%v len=%d", cw.truncated, cw.body.Len())
+	}
+	if rec.Body.Len() != maxCaptureBodySize+1 {
+		t.Errorf("expected the full response to be written, got %d bytes", rec.Body.Len())
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionETag returns the strong ETag of a resource version, derived from its updated_at
+func versionETag(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixMicro(), 36) + `"`
+}
+
+// setVersionHeaders sets the ETag and Last-Modified headers of a resource version
+func setVersionHeaders(w http.ResponseWriter, updatedAt time.Time) {
+	w.Header().Set("ETag", versionETag(updatedAt))
+	w.Header().Set("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+}
+
+// notModified reports whether a GET can be answered with 304 Not Modified
+// If-None-Match takes precedence over If-Modified-Since, which only has second precision
+func notModified(r *http.Request, updatedAt time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, versionETag(updatedAt), false)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		return err == nil && !updatedAt.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// hasIfMatch reports whether an update is conditional on the version the client has
+func hasIfMatch(r *http.Request) bool {
+	return r.Header.Get("If-Match") != ""
+}
+
+// checkIfMatch reports whether an update may go ahead: it has no If-Match or If-Match names the current version
+// Otherwise it writes 412 Precondition Failed; resource names the resource in the error, e.g. "Avatar"
+func checkIfMatch(w http.ResponseWriter, r *http.Request, resource string, updatedAt time.Time) bool {
+	if !hasIfMatch(r) || etagListMatches(r.Header.Get("If-Match"), versionETag(updatedAt), true) {
+		return true
+	}
+	writePreconditionFailed(w, resource)
+	return false
+}
+
+// writePreconditionFailed tells the client its copy of a resource is out of date
+func writePreconditionFailed(w http.ResponseWriter, resource string) {
+	http.Error(w, resource+" was modified since it was read; reload it and try again", http.StatusPreconditionFailed)
+}
+
+// etagListMatches reports whether a comma-separated If-Match or If-None-Match list contains etag or "*"
+// Strong comparison (If-Match) never matches weak W/ tags; weak comparison ignores the W/ prefix
+func etagListMatches(list, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestEtagListMatches(t *testing.T) {
+	etag := versionETag(time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC))
+
+	tests := []struct {
+		list   string
+		strong bool
+		want   bool
+	}{
+		{etag, true, true},
+		{`"other", ` + etag, true, true},
+		{"*", true, true},
+		{`"other"`, true, false},
+		{"W/" + etag, true, false},
+		{"W/" + etag, false, true},
+	}
+	for _, tt := range tests {
+		if got := etagListMatches(tt.list, etag, tt.strong); got != tt.want {
+			t.Errorf("etagListMatches(%q, strong=%v) = %v, want %v", tt.list, tt.strong, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 500000000, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", nil, false},
+		{"matching etag", map[string]string{"If-None-Match": versionETag(updatedAt)}, true},
+		{"stale etag", map[string]string{"If-None-Match": `"stale"`}, false},
+		{"same second", map[string]string{"If-Modified-Since": updatedAt.Format(http.TimeFormat)}, true},
+		{"earlier", map[string]string{"If-Modified-Since": updatedAt.Add(-time.Second).Format(http.TimeFormat)}, false},
+		// If-None-Match wins over If-Modified-Since
+		{"etag precedence", map[string]string{
+			"If-None-Match":     `"stale"`,
+			"If-Modified-Since": updatedAt.Format(http.TimeFormat),
+		}, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		if got := notModified(req, updatedAt); got != tt.want {
+			t.Errorf("%s: notModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_CORSAllowsConditionalRequests(t *testing.T) {
+	router := NewRouter(testutil.NewTestDB(t), nil, "", nil)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/avatars/1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	allowed := rec.Header().Get("Access-Control-Allow-Headers")
+	for _, header := range []string{"If-Match", "If-None-Match", "If-Modified-Since"} {
+		if !strings.Contains(allowed, header) {
+			t.Errorf("expected %s in Access-Control-Allow-Headers, got %q", header, allowed)
+		}
+	}
+	if exposed := rec.Header().Get("Access-Control-Expose-Headers"); exposed != "ETag, Last-Modified" {
+		t.Errorf("expected ETag and Last-Modified to be exposed, got %q", exposed)
+	}
+}
@@ -1,17 +1,24 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/jobs"
 	"multi-avatar-chat/internal/logic"
 	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/offline"
+	"multi-avatar-chat/internal/tracing"
 	"multi-avatar-chat/internal/watcher"
 )
 
@@ -20,13 +27,34 @@ type ConversationHandler struct {
 	db        *db.DB
 	assistant *assistant.Client
 	watcher   *watcher.WatcherManager
+	offline   *offline.Queue
+	broadcast *EventBroadcaster
+	// forwardConcurrency bounds how many avatar threads a user message is written to at once
+	forwardConcurrency int
+	// sendTimeout bounds how long SendMessage waits for deliveries before answering 202 Accepted
+	sendTimeout time.Duration
+	deliveries  *deliveryStore
+	// jobs runs long operations in the background when set
+	jobs *jobs.Runner
+	// redactor removes personal information from user messages of conversations with a redaction policy
+	redactor *logic.Redactor
+	// maxAvatars bounds the number of avatars a conversation is created with
+	maxAvatars int
+	// defaultTTL is the TTL of conversations created without one; 0 keeps them forever
+	defaultTTL time.Duration
 }
 
+// DefaultForwardConcurrency is the number of avatar threads written to at once by default
+const DefaultForwardConcurrency = 4
+
 // NewConversationHandler creates a new conversation handler
 func NewConversationHandler(database *db.DB, assistantClient *assistant.Client) *ConversationHandler {
 	return &ConversationHandler{
-		db:        database,
-		assistant: assistantClient,
+		db:          database,
+		assistant:   assistantClient,
+		sendTimeout: DefaultSendTimeout,
+		deliveries:  newDeliveryStore(),
+		redactor:    logic.NewRedactor(nil),
 	}
 }
 
@@ -35,18 +63,196 @@ func (h *ConversationHandler) SetWatcherManager(wm *watcher.WatcherManager) {
 	h.watcher = wm
 }
 
+// SetBroadcaster sets the event broadcaster used to notify clients of interrupts
+func (h *ConversationHandler) SetBroadcaster(b *EventBroadcaster) {
+	h.broadcast = b
+}
+
+// SetOfflineQueue sets the queue that holds messages while the OpenAI API is unavailable
+func (h *ConversationHandler) SetOfflineQueue(q *offline.Queue) {
+	h.offline = q
+}
+
+// SetForwardConcurrency sets how many avatar threads a user message is written to at once
+func (h *ConversationHandler) SetForwardConcurrency(n int) {
+	h.forwardConcurrency = n
+}
+
+// SetMaxAvatars sets how many avatars a conversation can be created with
+func (h *ConversationHandler) SetMaxAvatars(n int) {
+	h.maxAvatars = n
+}
+
+// SetDefaultTTL sets the TTL of conversations created without one
+func (h *ConversationHandler) SetDefaultTTL(d time.Duration) {
+	h.defaultTTL = d
+}
+
+// SetJobRunner runs thread imports as background jobs
+func (h *ConversationHandler) SetJobRunner(runner *jobs.Runner) {
+	h.jobs = runner
+	runner.Register(JobTypeImportThread, h.runImportThreadJob)
+}
+
+// SetRedactor sets the redactor applied to user messages, e.g. one with configured terms
+func (h *ConversationHandler) SetRedactor(redactor *logic.Redactor) {
+	h.redactor = redactor
+}
+
+// forwardWorkers returns the configured forward concurrency, falling back to the default
+func (h *ConversationHandler) forwardWorkers() int {
+	if h.forwardConcurrency > 0 {
+		return h.forwardConcurrency
+	}
+	return DefaultForwardConcurrency
+}
+
+// checkAvatarIDs drops duplicate avatar IDs and checks that every avatar exists
+// and that they fit the participant limit
+// Failures are returned as *statusError so they map to an HTTP status
+func (h *ConversationHandler) checkAvatarIDs(avatarIDs []int64) ([]int64, error) {
+	if len(avatarIDs) == 0 {
+		return nil, nil
+	}
+
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, "Failed to get avatars"}
+	}
+	known := make(map[int64]bool)
+	for _, avatar := range avatars {
+		known[avatar.ID] = true
+	}
+
+	var ids []int64
+	var unknown []string
+	seen := make(map[int64]bool)
+	for _, id := range avatarIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if !known[id] {
+			unknown = append(unknown, strconv.FormatInt(id, 10))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(unknown) > 0 {
+		return nil, &statusError{http.StatusBadRequest, "Unknown avatar_ids: " + strings.Join(unknown, ", ")}
+	}
+
+	if limit := maxConversationAvatars(h.maxAvatars); len(ids) > limit {
+		return nil, &statusError{http.StatusBadRequest, tooManyAvatarsMessage(limit)}
+	}
+	return ids, nil
+}
+
+// systemInstructionsTooLong is the error message for system instructions over the length limit
+var systemInstructionsTooLong = fmt.Sprintf("system_instructions must be at most %d characters", logic.MaxSystemInstructionsLength)
+
+// maxContextMessagesNegative is the error message for a negative run context limit
+const maxContextMessagesNegative = "max_context_messages must be 0 (the whole thread) or more"
+
+// invalidTTL is the error message for a TTL that is not a non-negative duration
+const invalidTTL = "ttl must be a duration such as 24h, or 0 for no expiry"
+
+// parseTTL parses a conversation TTL such as "24h"; "0" means no expiry
+func parseTTL(s string) (time.Duration, bool) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// expiresAt returns when a conversation with the TTL expires, nil for no expiry
+func expiresAt(ttl time.Duration) *time.Time {
+	if ttl == 0 {
+		return nil
+	}
+	t := time.Now().Add(ttl)
+	return &t
+}
+
 // CreateConversationRequest represents the request body for creating a conversation
 type CreateConversationRequest struct {
-	Title     string  `json:"title"`
-	AvatarIDs []int64 `json:"avatar_ids,omitempty"`
+	Title string `json:"title"`
+	// AvatarIDs defaults to the default avatars when omitted; [] creates the conversation without avatars
+	AvatarIDs       []int64 `json:"avatar_ids,omitempty"`
+	ResponseStyle   string  `json:"response_style,omitempty"`
+	RedactionPolicy string  `json:"redaction_policy,omitempty"`
+	// SystemInstructions are appended to every avatar's run instructions in the conversation
+	SystemInstructions string `json:"system_instructions,omitempty"`
+	// MaxContextMessages limits how many recent thread messages each avatar's run reads; 0 reads the whole thread
+	MaxContextMessages int    `json:"max_context_messages,omitempty"`
+	InitialMessage     string `json:"initial_message,omitempty"`
+	// TTL deletes the conversation after this duration, e.g. "24h"; "0" overrides the server default
+	TTL string `json:"ttl,omitempty"`
+	// Language is the language avatars talk in; user messages are translated into it
+	Language string `json:"language,omitempty"`
+	// DisplayLanguage is the language avatar replies are translated into for display
+	DisplayLanguage string `json:"display_language,omitempty"`
+	// OpenAIOrganization and OpenAIProject bill the conversation's OpenAI usage to them instead of the server's defaults
+	OpenAIOrganization string `json:"openai_organization,omitempty"`
+	OpenAIProject      string `json:"openai_project,omitempty"`
+}
+
+// CreateConversationResponse represents the response for creating a conversation
+type CreateConversationResponse struct {
+	ConversationResponse
+	InitialMessage *MessageResponse `json:"initial_message,omitempty"`
+	// Redactions counts the values of each kind redacted from the initial message
+	Redactions map[string]int `json:"redactions,omitempty"`
 }
 
 // ConversationResponse represents a conversation in API responses
 type ConversationResponse struct {
-	ID        int64  `json:"id"`
-	Title     string `json:"title"`
-	ThreadID  string `json:"thread_id,omitempty"`
-	CreatedAt string `json:"created_at"`
+	ID                 int64  `json:"id"`
+	Title              string `json:"title"`
+	ThreadID           string `json:"thread_id,omitempty"`
+	ResponseStyle      string `json:"response_style"`
+	RedactionPolicy    string `json:"redaction_policy"`
+	SystemInstructions string `json:"system_instructions"`
+	MaxContextMessages int    `json:"max_context_messages"`
+	Language           string `json:"language"`
+	DisplayLanguage    string `json:"display_language"`
+	OpenAIOrganization string `json:"openai_organization"`
+	OpenAIProject      string `json:"openai_project"`
+	CreatedAt          string `json:"created_at"`
+	UpdatedAt          string `json:"updated_at"`
+	// ExpiresAt is when the conversation is deleted, omitted when it has no TTL
+	ExpiresAt *string `json:"expires_at,omitempty"`
+	// Published shows the conversation at /gallery/{gallery_slug}
+	Published   bool   `json:"published"`
+	GallerySlug string `json:"gallery_slug,omitempty"`
+}
+
+// newConversationResponse converts a conversation model to its API representation
+func newConversationResponse(conv *models.Conversation) ConversationResponse {
+	var expires *string
+	if conv.ExpiresAt != nil {
+		s := conv.ExpiresAt.Format(time.RFC3339)
+		expires = &s
+	}
+	return ConversationResponse{
+		ID:                 conv.ID,
+		Title:              conv.Title,
+		ThreadID:           conv.ThreadID,
+		ResponseStyle:      conv.ResponseStyle,
+		RedactionPolicy:    conv.RedactionPolicy,
+		SystemInstructions: conv.SystemInstructions,
+		MaxContextMessages: conv.MaxContextMessages,
+		Language:           conv.Language,
+		DisplayLanguage:    conv.DisplayLanguage,
+		OpenAIOrganization: conv.OpenAIOrganization,
+		OpenAIProject:      conv.OpenAIProject,
+		CreatedAt:          conv.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          conv.UpdatedAt.Format(time.RFC3339),
+		ExpiresAt:          expires,
+		Published:          conv.Published,
+		GallerySlug:        conv.GallerySlug,
+	}
 }
 
 // Create handles POST /api/conversations
@@ -68,8 +274,92 @@ func (h *ConversationHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	responseStyle, ok := logic.ParseResponseStyle(req.ResponseStyle)
+	if !ok {
+		log.Printf("[API] Create conversation failed: invalid response_style=%q", req.ResponseStyle)
+		http.Error(w, "Invalid response_style (must be brief, normal or detailed)", http.StatusBadRequest)
+		return
+	}
+
+	redactionPolicy, ok := logic.ParseRedactionPolicy(req.RedactionPolicy)
+	if !ok {
+		log.Printf("[API] Create conversation failed: invalid redaction_policy=%q", req.RedactionPolicy)
+		http.Error(w, "Invalid redaction_policy (must be off, regex or llm)", http.StatusBadRequest)
+		return
+	}
+
+	systemInstructions, ok := logic.ParseSystemInstructions(req.SystemInstructions)
+	if !ok {
+		log.Printf("[API] Create conversation failed: system_instructions too long length=%d", len(req.SystemInstructions))
+		http.Error(w, systemInstructionsTooLong, http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxContextMessages < 0 {
+		log.Printf("[API] Create conversation failed: invalid max_context_messages=%d", req.MaxContextMessages)
+		http.Error(w, maxContextMessagesNegative, http.StatusBadRequest)
+		return
+	}
+
+	language, ok := logic.ParseLanguage(req.Language)
+	if !ok {
+		log.Printf("[API] Create conversation failed: invalid language=%q", req.Language)
+		http.Error(w, invalidLanguage("language"), http.StatusBadRequest)
+		return
+	}
+	displayLanguage, ok := logic.ParseLanguage(req.DisplayLanguage)
+	if !ok {
+		log.Printf("[API] Create conversation failed: invalid display_language=%q", req.DisplayLanguage)
+		http.Error(w, invalidLanguage("display_language"), http.StatusBadRequest)
+		return
+	}
+
+	organization, ok := parseOpenAIRoutingID(req.OpenAIOrganization)
+	if !ok {
+		log.Printf("[API] Create conversation failed: invalid openai_organization=%q", req.OpenAIOrganization)
+		http.Error(w, invalidOpenAIRoutingID("openai_organization"), http.StatusBadRequest)
+		return
+	}
+	project, ok := parseOpenAIRoutingID(req.OpenAIProject)
+	if !ok {
+		log.Printf("[API] Create conversation failed: invalid openai_project=%q", req.OpenAIProject)
+		http.Error(w, invalidOpenAIRoutingID("openai_project"), http.StatusBadRequest)
+		return
+	}
+
+	ttl := h.defaultTTL
+	if req.TTL != "" {
+		if ttl, ok = parseTTL(req.TTL); !ok {
+			log.Printf("[API] Create conversation failed: invalid ttl=%q", req.TTL)
+			http.Error(w, invalidTTL, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Conversations created without avatar_ids get the default roster; an empty list opts out
+	if req.AvatarIDs == nil {
+		defaults, err := h.defaultAvatarIDs()
+		if err != nil {
+			log.Printf("[API] Create conversation failed: DB error getting default avatars err=%v", err)
+			http.Error(w, "Failed to get default avatars", http.StatusInternalServerError)
+			return
+		}
+		req.AvatarIDs = defaults
+		if len(req.AvatarIDs) > 0 {
+			log.Printf("[API] Create conversation using default avatars avatar_ids=%v", req.AvatarIDs)
+		}
+	}
+
+	avatarIDs, err := h.checkAvatarIDs(req.AvatarIDs)
+	if err != nil {
+		log.Printf("[API] Create conversation failed: invalid avatar_ids=%v err=%v", req.AvatarIDs, err)
+		writeStatusError(w, err)
+		return
+	}
+	req.AvatarIDs = avatarIDs
+
 	// Save to database (no thread_id for conversation itself)
-	conv, err := h.db.CreateConversation(req.Title, "")
+	conv, err := h.db.CreateConversationWithSettings(req.Title, "", string(responseStyle), string(redactionPolicy), systemInstructions, req.MaxContextMessages)
 	if err != nil {
 		log.Printf("[API] Failed to create conversation in DB err=%v", err)
 		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
@@ -77,22 +367,64 @@ func (h *ConversationHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("[API] Conversation created in DB conversation_id=%d", conv.ID)
 
+	if language != "" || displayLanguage != "" {
+		conv.Language, conv.DisplayLanguage = language, displayLanguage
+		translated, err := h.db.UpdateConversation(conv)
+		if err != nil {
+			log.Printf("[API] Failed to set conversation languages conversation_id=%d err=%v", conv.ID, err)
+			http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+			return
+		}
+		conv = translated
+		log.Printf("[API] Conversation languages set conversation_id=%d language=%q display_language=%q",
+			conv.ID, conv.Language, conv.DisplayLanguage)
+	}
+
+	// Set before the avatar threads are created so they belong to the conversation's project
+	if organization != "" || project != "" {
+		conv.OpenAIOrganization, conv.OpenAIProject = organization, project
+		routed, err := h.db.UpdateConversation(conv)
+		if err != nil {
+			log.Printf("[API] Failed to set conversation OpenAI project conversation_id=%d err=%v", conv.ID, err)
+			http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+			return
+		}
+		conv = routed
+		log.Printf("[API] Conversation OpenAI project set conversation_id=%d openai_organization=%q openai_project=%q",
+			conv.ID, conv.OpenAIOrganization, conv.OpenAIProject)
+	}
+
+	if ttl > 0 {
+		expiring, err := h.db.SetConversationExpiry(conv.ID, expiresAt(ttl))
+		if err != nil {
+			log.Printf("[API] Failed to set conversation expiry conversation_id=%d err=%v", conv.ID, err)
+			http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+			return
+		}
+		conv = expiring
+		log.Printf("[API] Conversation expiry set conversation_id=%d ttl=%v", conv.ID, ttl)
+	}
+
 	// Add avatars to conversation and create threads for each avatar
+	var addedAvatarIDs []int64
 	for _, avatarID := range req.AvatarIDs {
 		var threadID string
 		if h.assistant != nil {
 			log.Printf("[API] Creating OpenAI thread for avatar conversation_id=%d avatar_id=%d", conv.ID, avatarID)
-			thread, err := h.assistant.CreateThread()
+			id, err := createThreadWithRetry(h.assistantFor(conv), conv.ID, avatarID)
 			if err != nil {
-				log.Printf("[API] Failed to create OpenAI thread for avatar conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
+				log.Printf("[API] Giving up on OpenAI thread for avatar conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
 				// Continue even if thread creation fails, but log the error
-				// Add avatar without thread_id
+				// Add avatar without thread_id; the thread can be recreated via the recreate-thread endpoint
+				// Its watcher still starts and picks up the thread once it exists
 				if err := h.db.AddAvatarToConversationWithThreadID(conv.ID, avatarID, ""); err != nil {
 					log.Printf("[API] Failed to add avatar to conversation conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
+				} else {
+					addedAvatarIDs = append(addedAvatarIDs, avatarID)
 				}
 				continue
 			}
-			threadID = thread.ID
+			threadID = id
 			log.Printf("[API] OpenAI thread created for avatar conversation_id=%d avatar_id=%d thread_id=%s", conv.ID, avatarID, threadID)
 		} else {
 			log.Printf("[API] OpenAI assistant client is nil, skipping thread creation for avatar_id=%d", avatarID)
@@ -104,25 +436,61 @@ func (h *ConversationHandler) Create(w http.ResponseWriter, r *http.Request) {
 			// Continue even if one fails
 		} else {
 			log.Printf("[API] Avatar added to conversation conversation_id=%d avatar_id=%d thread_id=%s", conv.ID, avatarID, threadID)
-			// Start watcher for the avatar
-			if h.watcher != nil {
-				if err := h.watcher.StartWatcher(conv.ID, avatarID); err != nil {
-					log.Printf("[API] Warning: Failed to start watcher conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
-				}
+			addedAvatarIDs = append(addedAvatarIDs, avatarID)
+		}
+	}
+
+	response := CreateConversationResponse{ConversationResponse: newConversationResponse(conv)}
+
+	// Save and forward the initial message before watchers start,
+	// so the watchers treat it as new instead of initializing past it
+	var initialMsg *models.Message
+	if req.InitialMessage != "" {
+		database := h.db.WithContext(r.Context())
+		var tracked *messageDeliveries
+		initialMsg, response.Redactions, tracked, err = h.saveUserMessage(r.Context(), database, conv, req.InitialMessage, false)
+		if err != nil {
+			log.Printf("[API] Create conversation failed: DB error saving initial message conversation_id=%d err=%v", conv.ID, err)
+			http.Error(w, "Failed to save initial message", http.StatusInternalServerError)
+			return
+		}
+		if tracked != nil {
+			tracked.wait()
+		}
+
+		response.InitialMessage = &MessageResponse{
+			ID:          initialMsg.ID,
+			Sequence:    initialMsg.Sequence,
+			SenderType:  string(initialMsg.SenderType),
+			SenderID:    initialMsg.SenderID,
+			Content:     initialMsg.Content,
+			CreatedAt:   initialMsg.CreatedAt.Format(time.RFC3339),
+			AILabel:     models.NewAILabel(initialMsg.SenderType, ""),
+			Translation: initialMsg.Translation,
+		}
+	}
+
+	// Start watchers for the avatars
+	if h.watcher != nil {
+		for _, avatarID := range addedAvatarIDs {
+			var err error
+			if initialMsg != nil {
+				err = h.watcher.StartWatcherAfter(conv.ID, avatarID, initialMsg.Sequence-1)
+			} else {
+				err = h.watcher.StartWatcher(conv.ID, avatarID)
+			}
+			if err != nil {
+				log.Printf("[API] Warning: Failed to start watcher conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
 			}
 		}
 	}
 
-	log.Printf("[API] Create conversation completed conversation_id=%d title=%q", conv.ID, conv.Title)
+	log.Printf("[API] Create conversation completed conversation_id=%d title=%q initial_message=%t",
+		conv.ID, conv.Title, initialMsg != nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(ConversationResponse{
-		ID:        conv.ID,
-		Title:     conv.Title,
-		ThreadID:  conv.ThreadID,
-		CreatedAt: conv.CreatedAt.Format(time.RFC3339),
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 // List handles GET /api/conversations
@@ -134,13 +502,8 @@ func (h *ConversationHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := make([]ConversationResponse, len(conversations))
-	for i, conv := range conversations {
-		response[i] = ConversationResponse{
-			ID:        conv.ID,
-			Title:     conv.Title,
-			ThreadID:  conv.ThreadID,
-			CreatedAt: conv.CreatedAt.Format(time.RFC3339),
-		}
+	for i := range conversations {
+		response[i] = newConversationResponse(&conversations[i])
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -165,13 +528,211 @@ func (h *ConversationHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setVersionHeaders(w, conv.UpdatedAt)
+	if notModified(r, conv.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ConversationResponse{
-		ID:        conv.ID,
-		Title:     conv.Title,
-		ThreadID:  conv.ThreadID,
-		CreatedAt: conv.CreatedAt.Format(time.RFC3339),
-	})
+	json.NewEncoder(w).Encode(newConversationResponse(conv))
+}
+
+// UpdateConversationRequest represents the request body for updating a conversation
+// Omitted fields keep their current values
+type UpdateConversationRequest struct {
+	Title           *string `json:"title,omitempty"`
+	ResponseStyle   *string `json:"response_style,omitempty"`
+	RedactionPolicy *string `json:"redaction_policy,omitempty"`
+	// SystemInstructions replaces the conversation's instructions; "" removes them
+	SystemInstructions *string `json:"system_instructions,omitempty"`
+	// MaxContextMessages replaces the run context limit; 0 removes it
+	MaxContextMessages *int `json:"max_context_messages,omitempty"`
+	// TTL sets the conversation to expire this long from now; "0" removes the expiry
+	TTL *string `json:"ttl,omitempty"`
+	// Language replaces the language avatars talk in; "" turns translation of user messages off
+	Language *string `json:"language,omitempty"`
+	// DisplayLanguage replaces the language replies are shown in; "" turns translation of replies off
+	DisplayLanguage *string `json:"display_language,omitempty"`
+	// OpenAIOrganization and OpenAIProject replace where the conversation's usage is billed; "" uses the server's default
+	OpenAIOrganization *string `json:"openai_organization,omitempty"`
+	OpenAIProject      *string `json:"openai_project,omitempty"`
+}
+
+// Update handles PATCH /api/conversations/{id}
+func (h *ConversationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] Update conversation started")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		log.Printf("[API] Update conversation failed: invalid conversation ID err=%v", err)
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[API] Update conversation failed: invalid request body err=%v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		log.Printf("[API] Update conversation failed: conversation not found conversation_id=%d", id)
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] Update conversation failed: DB error getting conversation err=%v", err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+	previousTitle := conv.Title
+
+	// With If-Match, refuse to overwrite an edit made after the client read the conversation
+	if !checkIfMatch(w, r, "Conversation", conv.UpdatedAt) {
+		log.Printf("[API] Update conversation failed: If-Match does not match conversation_id=%d", id)
+		return
+	}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			log.Printf("[API] Update conversation failed: title is empty")
+			http.Error(w, "Title is required", http.StatusBadRequest)
+			return
+		}
+		conv.Title = *req.Title
+	}
+
+	if req.ResponseStyle != nil {
+		style, ok := logic.ParseResponseStyle(*req.ResponseStyle)
+		if !ok {
+			log.Printf("[API] Update conversation failed: invalid response_style=%q", *req.ResponseStyle)
+			http.Error(w, "Invalid response_style (must be brief, normal or detailed)", http.StatusBadRequest)
+			return
+		}
+		conv.ResponseStyle = string(style)
+	}
+
+	if req.RedactionPolicy != nil {
+		policy, ok := logic.ParseRedactionPolicy(*req.RedactionPolicy)
+		if !ok {
+			log.Printf("[API] Update conversation failed: invalid redaction_policy=%q", *req.RedactionPolicy)
+			http.Error(w, "Invalid redaction_policy (must be off, regex or llm)", http.StatusBadRequest)
+			return
+		}
+		conv.RedactionPolicy = string(policy)
+	}
+
+	if req.SystemInstructions != nil {
+		instructions, ok := logic.ParseSystemInstructions(*req.SystemInstructions)
+		if !ok {
+			log.Printf("[API] Update conversation failed: system_instructions too long length=%d", len(*req.SystemInstructions))
+			http.Error(w, systemInstructionsTooLong, http.StatusBadRequest)
+			return
+		}
+		conv.SystemInstructions = instructions
+	}
+
+	if req.MaxContextMessages != nil {
+		if *req.MaxContextMessages < 0 {
+			log.Printf("[API] Update conversation failed: invalid max_context_messages=%d", *req.MaxContextMessages)
+			http.Error(w, maxContextMessagesNegative, http.StatusBadRequest)
+			return
+		}
+		conv.MaxContextMessages = *req.MaxContextMessages
+	}
+
+	if req.Language != nil {
+		language, ok := logic.ParseLanguage(*req.Language)
+		if !ok {
+			log.Printf("[API] Update conversation failed: invalid language=%q", *req.Language)
+			http.Error(w, invalidLanguage("language"), http.StatusBadRequest)
+			return
+		}
+		conv.Language = language
+	}
+
+	if req.DisplayLanguage != nil {
+		language, ok := logic.ParseLanguage(*req.DisplayLanguage)
+		if !ok {
+			log.Printf("[API] Update conversation failed: invalid display_language=%q", *req.DisplayLanguage)
+			http.Error(w, invalidLanguage("display_language"), http.StatusBadRequest)
+			return
+		}
+		conv.DisplayLanguage = language
+	}
+
+	if req.OpenAIOrganization != nil {
+		organization, ok := parseOpenAIRoutingID(*req.OpenAIOrganization)
+		if !ok {
+			log.Printf("[API] Update conversation failed: invalid openai_organization=%q", *req.OpenAIOrganization)
+			http.Error(w, invalidOpenAIRoutingID("openai_organization"), http.StatusBadRequest)
+			return
+		}
+		conv.OpenAIOrganization = organization
+	}
+
+	if req.OpenAIProject != nil {
+		project, ok := parseOpenAIRoutingID(*req.OpenAIProject)
+		if !ok {
+			log.Printf("[API] Update conversation failed: invalid openai_project=%q", *req.OpenAIProject)
+			http.Error(w, invalidOpenAIRoutingID("openai_project"), http.StatusBadRequest)
+			return
+		}
+		conv.OpenAIProject = project
+	}
+
+	var ttl time.Duration
+	if req.TTL != nil {
+		var ok bool
+		if ttl, ok = parseTTL(*req.TTL); !ok {
+			log.Printf("[API] Update conversation failed: invalid ttl=%q", *req.TTL)
+			http.Error(w, invalidTTL, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var updated *models.Conversation
+	if hasIfMatch(r) {
+		updated, err = h.db.UpdateConversationIfUnmodified(conv, conv.UpdatedAt)
+	} else {
+		updated, err = h.db.UpdateConversation(conv)
+	}
+	if err == db.ErrModified {
+		log.Printf("[API] Update conversation failed: modified concurrently conversation_id=%d", id)
+		writePreconditionFailed(w, "Conversation")
+		return
+	}
+	if err != nil {
+		log.Printf("[API] Update conversation failed: DB error updating conversation err=%v", err)
+		http.Error(w, "Failed to update conversation", http.StatusInternalServerError)
+		return
+	}
+
+	if req.TTL != nil {
+		if updated, err = h.db.SetConversationExpiry(id, expiresAt(ttl)); err != nil {
+			log.Printf("[API] Update conversation failed: DB error setting expiry err=%v", err)
+			http.Error(w, "Failed to update conversation", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Watchers use the title as the topic of judgment prompts
+	if h.watcher != nil && updated.Title != previousTitle {
+		if err := h.watcher.RefreshConversation(updated.ID); err != nil {
+			log.Printf("[API] Update conversation warning: failed to refresh watchers conversation_id=%d err=%v", updated.ID, err)
+		}
+	}
+
+	log.Printf("[API] Update conversation completed conversation_id=%d title=%q response_style=%s redaction_policy=%s system_instructions_length=%d max_context_messages=%d language=%q display_language=%q openai_organization=%q openai_project=%q",
+		updated.ID, updated.Title, updated.ResponseStyle, updated.RedactionPolicy, len(updated.SystemInstructions), updated.MaxContextMessages,
+		updated.Language, updated.DisplayLanguage, updated.OpenAIOrganization, updated.OpenAIProject)
+
+	setVersionHeaders(w, updated.UpdatedAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newConversationResponse(updated))
 }
 
 // Delete handles DELETE /api/conversations/{id}
@@ -207,11 +768,21 @@ func (h *ConversationHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Delete OpenAI Thread
-	if h.assistant != nil && existing.ThreadID != "" {
-		// Ignore errors for thread deletion
-		_ = h.assistant.DeleteThread(existing.ThreadID)
-		log.Printf("[API] OpenAI thread deleted thread_id=%s", existing.ThreadID)
+	// Collect every OpenAI thread of the conversation before its rows are gone
+	threadIDs := []string{}
+	if existing.ThreadID != "" {
+		threadIDs = append(threadIDs, existing.ThreadID)
+	}
+	_, avatarThreadIDs, err := h.db.GetConversationAvatarsWithThreads(id)
+	if err != nil {
+		log.Printf("[API] Delete conversation failed: DB error getting avatar threads err=%v", err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+	for _, threadID := range avatarThreadIDs {
+		if threadID != "" {
+			threadIDs = append(threadIDs, threadID)
+		}
 	}
 
 	// Delete from database
@@ -221,29 +792,84 @@ func (h *ConversationHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.deleteThreads(r.Context(), existing, threadIDs)
+	if h.broadcast != nil {
+		h.broadcast.DropConversation(id)
+	}
+
+	recordAudit(h.db, r, models.AuditActionConversationDelete, "conversation", strconv.FormatInt(id, 10),
+		newConversationResponse(existing), nil)
+
 	log.Printf("[API] Delete conversation completed conversation_id=%d", id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// deleteThreads deletes the OpenAI threads of a deleted conversation
+// Threads that cannot be deleted now are recorded so the thread collector retries them later
+func (h *ConversationHandler) deleteThreads(ctx context.Context, conv *models.Conversation, threadIDs []string) {
+	for _, threadID := range threadIDs {
+		if h.assistant == nil {
+			h.recordPendingThreadDeletion(threadID, conv.ID, "assistant client not configured")
+			continue
+		}
+		err := h.assistantFor(conv).WithContext(ctx).DeleteThread(threadID)
+		if err != nil && !assistant.IsNotFound(err) {
+			log.Printf("[API] Warning: Failed to delete OpenAI thread thread_id=%s err=%v", threadID, err)
+			h.recordPendingThreadDeletion(threadID, conv.ID, err.Error())
+			continue
+		}
+		log.Printf("[API] OpenAI thread deleted thread_id=%s", threadID)
+	}
+}
+
+func (h *ConversationHandler) recordPendingThreadDeletion(threadID string, conversationID int64, reason string) {
+	if err := h.db.AddPendingThreadDeletion(threadID, conversationID, reason); err != nil {
+		log.Printf("[API] Warning: Failed to record pending thread deletion thread_id=%s err=%v", threadID, err)
+	}
+}
+
 // MessageResponse represents a message in API responses
 type MessageResponse struct {
-	ID         int64  `json:"id"`
-	SenderType string `json:"sender_type"`
-	SenderID   *int64 `json:"sender_id,omitempty"`
-	SenderName string `json:"sender_name,omitempty"`
-	Content    string `json:"content"`
-	CreatedAt  string `json:"created_at"`
+	ID          int64  `json:"id"`
+	Sequence    int64  `json:"sequence"` // Per-conversation order, starting at 1
+	SenderType  string `json:"sender_type"`
+	SenderID    *int64 `json:"sender_id,omitempty"`
+	SenderName  string `json:"sender_name,omitempty"`
+	SenderColor string `json:"sender_color,omitempty"`
+	SenderEmoji string `json:"sender_emoji,omitempty"`
+	Content     string `json:"content"`
+	CreatedAt   string `json:"created_at"`
+	// Artifacts holds code interpreter outputs produced while generating the message
+	Artifacts []ArtifactResponse `json:"artifacts,omitempty"`
+	// Citations holds the sources the message cites
+	Citations []CitationResponse `json:"citations,omitempty"`
+	// PromptVariant is the prompt experiment variant an avatar responded with
+	PromptVariant string `json:"prompt_variant,omitempty"`
+	// AILabel marks AI-generated messages and the model that generated them
+	AILabel models.AILabel `json:"ai_label"`
+	// Translation is the message as avatars read it when Content was translated for the room
+	Translation *models.MessageTranslation `json:"translation,omitempty"`
+	// Silent is set for messages the avatars pass over, sent with the silent option or imported
+	Silent bool `json:"silent,omitempty"`
 }
 
 // SendMessageRequest represents the request body for sending a message
 type SendMessageRequest struct {
 	Content string `json:"content"`
+	// Silent stores and broadcasts the message without forwarding it to the avatars, e.g. for backfills and admin notes
+	Silent bool `json:"silent,omitempty"`
 }
 
 // SendMessageResponse represents the response for sending a message
 type SendMessageResponse struct {
 	UserMessage     MessageResponse   `json:"user_message"`
 	AvatarResponses []MessageResponse `json:"avatar_responses,omitempty"`
+	// Deliveries reports whether the message reached each avatar's thread
+	Deliveries []DeliveryResponse `json:"deliveries,omitempty"`
+	// DeliveryURL is set when forwarding is still in progress; poll it for the final deliveries
+	DeliveryURL string `json:"delivery_url,omitempty"`
+	// Redactions counts the values of each kind redacted from the message before it was stored
+	Redactions map[string]int `json:"redactions,omitempty"`
 }
 
 // SendMessage handles POST /api/conversations/{id}/messages
@@ -265,21 +891,17 @@ func (h *ConversationHandler) SendMessage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Truncate content for logging
-	contentPreview := req.Content
-	if len(contentPreview) > 100 {
-		contentPreview = contentPreview[:100] + "..."
-	}
-	log.Printf("[API] SendMessage request conversation_id=%d content=%q", id, contentPreview)
-
 	if req.Content == "" {
 		log.Printf("[API] SendMessage failed: content is required")
 		http.Error(w, "Content is required", http.StatusBadRequest)
 		return
 	}
 
+	// Record DB calls as part of the request trace
+	database := h.db.WithContext(r.Context())
+
 	// Verify conversation exists
-	conv, err := h.db.GetConversation(id)
+	conv, err := database.GetConversation(id)
 	if err == sql.ErrNoRows {
 		log.Printf("[API] SendMessage failed: conversation not found conversation_id=%d", id)
 		http.Error(w, "Conversation not found", http.StatusNotFound)
@@ -293,7 +915,7 @@ func (h *ConversationHandler) SendMessage(w http.ResponseWriter, r *http.Request
 	log.Printf("[API] Conversation found conversation_id=%d thread_id=%s", conv.ID, conv.ThreadID)
 
 	// Get conversation avatars for debugging
-	avatars, err := h.db.GetConversationAvatars(id)
+	avatars, err := database.GetConversationAvatars(id)
 	if err != nil {
 		log.Printf("[API] Warning: failed to get conversation avatars err=%v", err)
 	} else {
@@ -304,58 +926,51 @@ func (h *ConversationHandler) SendMessage(w http.ResponseWriter, r *http.Request
 		log.Printf("[API] Conversation avatars conversation_id=%d count=%d names=%v", id, len(avatars), avatarNames)
 	}
 
-	// Save user message to database
-	msg, err := h.db.CreateMessage(id, models.SenderTypeUser, nil, req.Content)
+	// Save user message to database and send it to all avatar threads, waiting at most
+	// sendTimeout so a stuck thread does not hold the connection; unfinished deliveries
+	// can be polled afterwards
+	msg, redactions, tracked, err := h.saveUserMessage(r.Context(), database, conv, req.Content, req.Silent)
 	if err != nil {
 		log.Printf("[API] SendMessage failed: DB error saving message err=%v", err)
 		http.Error(w, "Failed to save message", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("[API] User message saved to DB message_id=%d conversation_id=%d", msg.ID, id)
 
-	// Send user message to all avatar threads
-	if h.assistant != nil {
-		avatars, threadIDs, err := h.db.GetConversationAvatarsWithThreads(id)
-		if err != nil {
-			log.Printf("[API] Warning: failed to get conversation avatars with threads err=%v", err)
-		} else {
-			// Format user message for OpenAI Thread
-			formattedContent := logic.FormatUserMessage(req.Content)
-
-			// Send to each avatar's thread
-			for i, avatar := range avatars {
-				if i >= len(threadIDs) || threadIDs[i] == "" {
-					log.Printf("[API] Skipping avatar without thread_id conversation_id=%d avatar_id=%d avatar_name=%s", id, avatar.ID, avatar.Name)
-					continue
-				}
-
-				threadID := threadIDs[i]
-				log.Printf("[API] Sending user message to avatar thread conversation_id=%d avatar_id=%d avatar_name=%s thread_id=%s", id, avatar.ID, avatar.Name, threadID)
-				log.Printf("[API] LLM Input thread_id=%s avatar_name=%s message_content=%q", threadID, avatar.Name, formattedContent)
-
-				// Wait for any active runs to complete before adding message
-				if err := h.assistant.WaitForActiveRunsToComplete(threadID, 30*time.Second); err != nil {
-					log.Printf("[API] Warning: timeout waiting for active runs thread_id=%s avatar_name=%s err=%v", threadID, avatar.Name, err)
-				}
-
-				_, err := h.assistant.CreateMessage(threadID, formattedContent)
-				if err != nil {
-					log.Printf("[API] Warning: failed to send message to avatar thread thread_id=%s avatar_name=%s err=%v", threadID, avatar.Name, err)
-					// Continue - message is saved locally
-				} else {
-					log.Printf("[API] Message sent to avatar thread successfully thread_id=%s avatar_name=%s", threadID, avatar.Name)
-				}
-			}
+	// Truncate content for logging (after redaction, so redacted values never reach the logs)
+	contentPreview := msg.Content
+	if len(contentPreview) > 100 {
+		contentPreview = contentPreview[:100] + "..."
+	}
+	log.Printf("[API] SendMessage request conversation_id=%d content=%q silent=%v", id, contentPreview, req.Silent)
+
+	status := http.StatusCreated
+	var deliveries []DeliveryResponse
+	var deliveryURL string
+	if tracked != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), h.sendTimeout)
+		select {
+		case <-tracked.done:
+		case <-ctx.Done():
+		}
+		cancel()
+
+		var complete bool
+		deliveries, complete = tracked.snapshot()
+		if !complete {
+			status = http.StatusAccepted
+			deliveryURL = fmt.Sprintf("/api/conversations/%d/messages/%d/deliveries", id, msg.ID)
+			log.Printf("[API] SendMessage deadline reached, forwarding continues conversation_id=%d message_id=%d timeout=%v",
+				id, msg.ID, h.sendTimeout)
 		}
-	} else {
-		log.Printf("[API] Skipping OpenAI thread: assistant is nil")
 	}
 
 	// Generate avatar responses only if WatcherManager is not active
 	// When WatcherManager is active, avatars will respond asynchronously via polling
 	var avatarResponses []MessageResponse
-	if h.watcher == nil {
-		avatarResponses = h.generateAvatarResponses(conv, avatars, req.Content)
+	if req.Silent {
+		log.Printf("[API] Skipping avatar response: silent message")
+	} else if h.watcher == nil {
+		avatarResponses = h.generateAvatarResponses(conv, avatars, msg.Content)
 	} else {
 		log.Printf("[API] Skipping synchronous avatar response: WatcherManager is active")
 	}
@@ -363,23 +978,205 @@ func (h *ConversationHandler) SendMessage(w http.ResponseWriter, r *http.Request
 	log.Printf("[API] SendMessage completed conversation_id=%d message_id=%d avatar_responses=%d duration=%v",
 		id, msg.ID, len(avatarResponses), time.Since(start))
 
-	// Build response
-	userMessage := MessageResponse{
-		ID:         msg.ID,
-		SenderType: string(msg.SenderType),
-		SenderID:   msg.SenderID,
-		Content:    msg.Content,
-		CreatedAt:  msg.CreatedAt.Format(time.RFC3339),
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(SendMessageResponse{
-		UserMessage:     userMessage,
+		UserMessage:     newUserMessageResponse(msg),
 		AvatarResponses: avatarResponses,
+		Deliveries:      deliveries,
+		DeliveryURL:     deliveryURL,
+		Redactions:      redactions,
 	})
 }
 
+// newUserMessageResponse converts a saved user message to its API representation
+func newUserMessageResponse(msg *models.Message) MessageResponse {
+	return MessageResponse{
+		ID:          msg.ID,
+		Sequence:    msg.Sequence,
+		SenderType:  string(msg.SenderType),
+		SenderID:    msg.SenderID,
+		Content:     msg.Content,
+		CreatedAt:   msg.CreatedAt.Format(time.RFC3339),
+		AILabel:     models.NewAILabel(msg.SenderType, ""),
+		Translation: msg.Translation,
+		Silent:      msg.Silent,
+	}
+}
+
+// saveUserMessage redacts a user message according to the conversation's policy, saves it,
+// records its references to other conversations and starts forwarding it to the avatar threads
+// A silent message is broadcast instead of forwarded, and watchers pass over it.
+// Returns the number of values of each kind that were redacted
+func (h *ConversationHandler) saveUserMessage(ctx context.Context, database *db.DB, conv *models.Conversation, content string, silent bool) (*models.Message, map[string]int, *messageDeliveries, error) {
+	id := conv.ID
+	content, redactions := h.redactUserContent(ctx, conv, content)
+
+	// Restart the watchers of a hibernated conversation before saving, so they see the message
+	if h.watcher != nil && !silent {
+		if err := h.watcher.Wake(id); err != nil {
+			log.Printf("[API] Warning: failed to wake conversation conversation_id=%d err=%v", id, err)
+		}
+	}
+
+	// Watchers read the silent flag with the message, so they move past it without reacting even after a restart
+	create := database.CreateMessage
+	if silent {
+		create = database.CreateSilentMessage
+	}
+	msg, err := create(id, models.SenderTypeUser, nil, content)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	log.Printf("[API] User message saved to DB message_id=%d conversation_id=%d silent=%v", msg.ID, id, silent)
+
+	if len(redactions) > 0 {
+		if err := database.CreateRedactions(id, msg.ID, conv.RedactionPolicy, redactions); err != nil {
+			log.Printf("[API] Warning: failed to record redactions message_id=%d err=%v", msg.ID, err)
+		}
+	}
+
+	// Record cross-references to other conversations for backlinks
+	if _, err := database.RecordConversationReferences(msg); err != nil {
+		log.Printf("[API] Warning: failed to record conversation references message_id=%d err=%v", msg.ID, err)
+	}
+
+	if silent {
+		// No watcher posts a response that would show the message, so clients learn of it here
+		if h.broadcast != nil {
+			h.broadcast.BroadcastMessage(id, models.NewMessageEvent(msg))
+		}
+		return msg, redactions, nil, nil
+	}
+
+	// Let watchers continue this trace when they pick up the message
+	tracing.RememberMessage(ctx, msg.ID)
+
+	return msg, redactions, h.forwardUserMessage(database, conv, h.translateUserMessage(database, conv, msg)), nil
+}
+
+// postUserMessage saves a user message and starts forwarding it to the avatar threads without waiting for the deliveries
+// Used for messages sent over a WebSocket. Returns sql.ErrNoRows if the conversation does not exist
+func (h *ConversationHandler) postUserMessage(ctx context.Context, id int64, content string, silent bool) (*models.Message, map[string]int, error) {
+	database := h.db.WithContext(ctx)
+	conv, err := database.GetConversation(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, redactions, _, err := h.saveUserMessage(ctx, database, conv, content, silent)
+	return msg, redactions, err
+}
+
+// SendUserMessage posts a user message to a conversation without going through HTTP,
+// for clients running in the same process such as the terminal REPL.
+// Waits until the message reaches the avatar threads; avatars answer through their watchers
+func (h *ConversationHandler) SendUserMessage(ctx context.Context, id int64, content string) (*models.Message, error) {
+	database := h.db.WithContext(ctx)
+	conv, err := database.GetConversation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, _, tracked, err := h.saveUserMessage(ctx, database, conv, content, false)
+	if err != nil {
+		return nil, err
+	}
+	if tracked != nil {
+		tracked.wait()
+	}
+	return msg, nil
+}
+
+// forwardUserMessage sends a saved user message to the threads of all avatars in the conversation
+// Threads are written in the background by up to forwardConcurrency workers; the returned
+// tracker reports the outcome for each avatar in conversation order and can be polled by message ID.
+// While the OpenAI API is unavailable the message is queued and replayed after recovery
+func (h *ConversationHandler) forwardUserMessage(database *db.DB, conv *models.Conversation, msg *models.Message) *messageDeliveries {
+	id := conv.ID
+	queueOffline := h.offline != nil && h.offline.ShouldQueue(id)
+	if h.assistant == nil && !queueOffline {
+		log.Printf("[API] Skipping OpenAI thread: assistant is nil")
+		return nil
+	}
+
+	avatars, threadIDs, err := database.GetConversationAvatarsWithThreads(id)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get conversation avatars with threads err=%v", err)
+		return nil
+	}
+
+	// Format user message for OpenAI Thread
+	formattedContent := logic.FormatUserMessage(msg.Content)
+
+	deliveries := make([]DeliveryResponse, len(avatars))
+	var pending []int
+	for i, avatar := range avatars {
+		deliveries[i] = DeliveryResponse{AvatarID: avatar.ID, AvatarName: avatar.Name, Status: DeliveryStatusPending}
+
+		if i >= len(threadIDs) || threadIDs[i] == "" {
+			log.Printf("[API] Skipping avatar without thread_id conversation_id=%d avatar_id=%d avatar_name=%s", id, avatar.ID, avatar.Name)
+			deliveries[i].Status = DeliveryStatusSkipped
+			continue
+		}
+
+		if queueOffline {
+			if err := h.offline.Enqueue(id, msg.ID, avatar.ID, threadIDs[i], formattedContent); err != nil {
+				log.Printf("[API] Warning: failed to queue message for avatar thread thread_id=%s avatar_name=%s err=%v", threadIDs[i], avatar.Name, err)
+				deliveries[i].Status = DeliveryStatusFailed
+				deliveries[i].Error = err.Error()
+			} else {
+				deliveries[i].Status = DeliveryStatusQueued
+			}
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	tracked := newMessageDeliveries(id, deliveries)
+	h.deliveries.add(msg.ID, tracked)
+
+	sem := make(chan struct{}, h.forwardWorkers())
+	var wg sync.WaitGroup
+	for _, i := range pending {
+		wg.Add(1)
+		go func(i int, avatarID int64, avatarName, threadID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			log.Printf("[API] Sending user message to avatar thread conversation_id=%d avatar_id=%d avatar_name=%s thread_id=%s", id, avatarID, avatarName, threadID)
+			log.Printf("[API] LLM Input thread_id=%s avatar_name=%s message_content=%q", threadID, avatarName, formattedContent)
+
+			// Forward through the queue so pending and failed deliveries are visible to operators
+			err := h.assistant.ForwardQueue().Forward(assistant.ForwardItem{
+				ThreadID:       threadID,
+				ConversationID: id,
+				MessageID:      msg.ID,
+				AvatarID:       avatarID,
+				AvatarName:     avatarName,
+				Content:        formattedContent,
+				Organization:   conv.OpenAIOrganization,
+				Project:        conv.OpenAIProject,
+			})
+			if err != nil {
+				log.Printf("[API] Warning: failed to send message to avatar thread thread_id=%s avatar_name=%s err=%v", threadID, avatarName, err)
+				// Continue - message is saved locally and the failed forward can be retried
+				tracked.set(i, DeliveryStatusFailed, err.Error())
+				return
+			}
+			log.Printf("[API] Message sent to avatar thread successfully thread_id=%s avatar_name=%s", threadID, avatarName)
+			tracked.set(i, DeliveryStatusDelivered, "")
+		}(i, avatars[i].ID, avatars[i].Name, threadIDs[i])
+	}
+
+	go func() {
+		wg.Wait()
+		tracked.finish()
+	}()
+
+	return tracked
+}
+
 // generateAvatarResponses generates responses from avatars
 // Returns a slice of messages created by avatars
 func (h *ConversationHandler) generateAvatarResponses(
@@ -416,16 +1213,41 @@ func (h *ConversationHandler) generateAvatarResponses(
 		return nil
 	}
 
-	// Create a run for the avatar to respond
-	run, err := h.assistant.CreateRun(conv.ThreadID, responder.OpenAIAssistantID)
+	// Create a run for the avatar to respond, applying the conversation's run settings and the avatar's resolved prompt
+	var runSettings []string
+	participants := []string{"ユーザ"}
+	for _, a := range avatars {
+		participants = append(participants, a.Name)
+	}
+	for _, section := range []string{
+		logic.FormatResponseStyleInstructions(logic.ResponseStyle(conv.ResponseStyle)),
+		logic.FormatLanguageInstructions(conv.Language),
+		logic.FormatPromptVariablesInstructions(responder.Prompt, logic.PromptVariables{
+			ConversationTitle: conv.Title,
+			Today:             time.Now(),
+			Participants:      participants,
+		}),
+		logic.FormatFormattingRulesInstructions(responder.Name, responder.FormattingRules),
+		logic.FormatSystemInstructions(conv.SystemInstructions),
+	} {
+		if section != "" {
+			runSettings = append(runSettings, section)
+		}
+	}
+	client := h.assistantFor(conv)
+	run, err := client.CreateRunWithOptions(conv.ThreadID, assistant.CreateRunRequest{
+		AssistantID:            responder.OpenAIAssistantID,
+		AdditionalInstructions: strings.Join(runSettings, "\n\n"),
+		TruncationStrategy:     assistant.LastMessages(conv.MaxContextMessages),
+	})
 	if err != nil {
 		log.Printf("[API] Failed to create run err=%v", err)
 		return nil
 	}
 	log.Printf("[API] Run created run_id=%s", run.ID)
 
-	// Wait for run to complete (30 second timeout)
-	completedRun, err := h.assistant.WaitForRun(conv.ThreadID, run.ID, 30*time.Second)
+	// Wait for run to complete
+	completedRun, err := client.WaitForRun(conv.ThreadID, run.ID, client.RunTimeout())
 	if err != nil {
 		log.Printf("[API] Run failed or timed out err=%v", err)
 		return nil
@@ -433,32 +1255,100 @@ func (h *ConversationHandler) generateAvatarResponses(
 	log.Printf("[API] Run completed run_id=%s status=%s", completedRun.ID, completedRun.Status)
 
 	// Get the latest assistant message
-	responseContent, err := h.assistant.GetLatestAssistantMessage(conv.ThreadID)
+	response, err := client.GetLatestAssistantMessage(conv.ThreadID)
 	if err != nil {
 		log.Printf("[API] Failed to get assistant message err=%v", err)
 		return nil
 	}
-	log.Printf("[API] Got assistant response content_length=%d", len(responseContent))
+	log.Printf("[API] Got assistant response content_length=%d", len(response.Content))
+
+	// Repair the response where it ignores the avatar's formatting rules
+	content, formattingPrefix := logic.ApplyFormattingRules(responder.Name, response.Content, responder.FormattingRules)
 
 	// Save avatar message to database
 	avatarID := responder.ID
-	avatarMsg, err := h.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatarID, responseContent)
+	avatarMsg, err := h.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatarID, content)
 	if err != nil {
 		log.Printf("[API] Failed to save avatar message err=%v", err)
 		return nil
 	}
 	log.Printf("[API] Avatar message saved message_id=%d avatar_id=%d", avatarMsg.ID, avatarID)
 
+	if completedRun.Model != "" {
+		if err := h.db.RecordMessageModel(avatarMsg.ID, completedRun.Model); err != nil {
+			log.Printf("[API] Warning: failed to record message model message_id=%d err=%v", avatarMsg.ID, err)
+		}
+	}
+
+	var citations []models.MessageCitation
+	if len(response.Citations) > 0 {
+		client.ResolveCitationFilenames(response.Citations)
+		citations = logic.ShiftCitations(messageCitations(response.Citations), formattingPrefix, content)
+		if err := h.db.CreateMessageCitations(avatarMsg.ID, citations); err != nil {
+			log.Printf("[API] Warning: failed to save message citations message_id=%d err=%v", avatarMsg.ID, err)
+		}
+	}
+
 	return []MessageResponse{{
-		ID:         avatarMsg.ID,
-		SenderType: string(avatarMsg.SenderType),
-		SenderID:   avatarMsg.SenderID,
-		SenderName: responder.Name,
-		Content:    avatarMsg.Content,
-		CreatedAt:  avatarMsg.CreatedAt.Format(time.RFC3339),
+		ID:          avatarMsg.ID,
+		Sequence:    avatarMsg.Sequence,
+		SenderType:  string(avatarMsg.SenderType),
+		SenderID:    avatarMsg.SenderID,
+		SenderName:  responder.Name,
+		SenderColor: responder.Color,
+		SenderEmoji: responder.Emoji,
+		Content:     avatarMsg.Content,
+		CreatedAt:   avatarMsg.CreatedAt.Format(time.RFC3339),
+		Citations:   newCitationResponses(citations),
+		AILabel:     models.NewAILabel(avatarMsg.SenderType, completedRun.Model),
 	}}
 }
 
+// BacklinkResponse represents a message in another conversation that references a conversation
+type BacklinkResponse struct {
+	ConversationID int64  `json:"conversation_id"`
+	Title          string `json:"title"`
+	MessageID      int64  `json:"message_id"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// GetBacklinks handles GET /api/conversations/{id}/backlinks
+func (h *ConversationHandler) GetBacklinks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetConversation(id); err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	links, err := h.db.GetConversationBacklinks(id)
+	if err != nil {
+		log.Printf("[API] GetBacklinks failed: DB error conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get backlinks", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]BacklinkResponse, len(links))
+	for i, link := range links {
+		response[i] = BacklinkResponse{
+			ConversationID: link.SourceConversationID,
+			Title:          link.SourceTitle,
+			MessageID:      link.MessageID,
+			CreatedAt:      link.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetMessages handles GET /api/conversations/{id}/messages
 func (h *ConversationHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[API] GetMessages started")
@@ -493,34 +1383,74 @@ func (h *ConversationHandler) GetMessages(w http.ResponseWriter, r *http.Request
 	}
 	log.Printf("[API] Messages retrieved conversation_id=%d count=%d", id, len(messages))
 
-	// Get avatars for sender names
-	avatars, _ := h.db.GetConversationAvatars(id)
-	avatarMap := make(map[int64]string)
+	response := h.newMessageResponses(id, messages)
+
+	log.Printf("[API] GetMessages completed conversation_id=%d message_count=%d", id, len(response))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// newMessageResponses converts messages of a conversation to their API representation,
+// with their artifacts, citations, AI labels and sender display metadata
+func (h *ConversationHandler) newMessageResponses(conversationID int64, messages []models.Message) []MessageResponse {
+	artifacts, err := h.db.GetConversationArtifacts(conversationID)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get message artifacts conversation_id=%d err=%v", conversationID, err)
+	}
+	citations, err := h.db.GetConversationCitations(conversationID)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get message citations conversation_id=%d err=%v", conversationID, err)
+	}
+	variants, err := h.db.GetConversationPromptVariants(conversationID)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get prompt variants conversation_id=%d err=%v", conversationID, err)
+	}
+	messageModels, err := h.db.GetConversationMessageModels(conversationID)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get message models conversation_id=%d err=%v", conversationID, err)
+	}
+	translations, err := h.db.GetConversationTranslations(conversationID)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get message translations conversation_id=%d err=%v", conversationID, err)
+	}
+
+	// Get avatars for sender names and display metadata
+	avatars, _ := h.db.GetConversationAvatars(conversationID)
+	avatarMap := make(map[int64]models.Avatar)
 	for _, a := range avatars {
-		avatarMap[a.ID] = a.Name
+		avatarMap[a.ID] = a
 	}
 
 	response := make([]MessageResponse, len(messages))
 	for i, msg := range messages {
 		resp := MessageResponse{
-			ID:         msg.ID,
-			SenderType: string(msg.SenderType),
-			SenderID:   msg.SenderID,
-			Content:    msg.Content,
-			CreatedAt:  msg.CreatedAt.Format(time.RFC3339),
+			ID:            msg.ID,
+			Sequence:      msg.Sequence,
+			SenderType:    string(msg.SenderType),
+			SenderID:      msg.SenderID,
+			Content:       msg.Content,
+			CreatedAt:     msg.CreatedAt.Format(time.RFC3339),
+			Artifacts:     newArtifactResponses(conversationID, artifacts[msg.ID]),
+			Citations:     newCitationResponses(citations[msg.ID]),
+			PromptVariant: variants[msg.ID],
+			AILabel:       models.NewAILabel(msg.SenderType, messageModels[msg.ID]),
+			Silent:        msg.Silent,
+		}
+		if translation, ok := translations[msg.ID]; ok {
+			resp.Translation = &translation
 		}
 		if msg.SenderID != nil {
-			if name, ok := avatarMap[*msg.SenderID]; ok {
-				resp.SenderName = name
+			if avatar, ok := avatarMap[*msg.SenderID]; ok {
+				resp.SenderName = avatar.Name
+				resp.SenderColor = avatar.Color
+				resp.SenderEmoji = avatar.Emoji
 			}
 		}
 		response[i] = resp
 	}
 
-	log.Printf("[API] GetMessages completed conversation_id=%d message_count=%d", id, len(response))
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response
 }
 
 // Interrupt handles POST /api/conversations/{id}/interrupt
@@ -560,6 +1490,12 @@ func (h *ConversationHandler) Interrupt(w http.ResponseWriter, r *http.Request)
 		log.Printf("[API] Warning: WatcherManager is nil, cannot interrupt conversation_id=%d", id)
 	}
 
+	if h.broadcast != nil {
+		h.broadcast.BroadcastInterrupt(id)
+	}
+
+	recordAudit(h.db, r, models.AuditActionConversationInterrupt, "conversation", strconv.FormatInt(id, 10), nil, nil)
+
 	log.Printf("[API] Interrupt conversation completed conversation_id=%d", id)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -10,15 +11,87 @@ import (
 
 	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
 	"multi-avatar-chat/internal/watcher"
 )
 
+// Thread statuses reported for conversation participants
+const (
+	ThreadStatusReady   = "ready"
+	ThreadStatusMissing = "missing"
+)
+
+// Thread creation is retried with exponential backoff before an avatar is added without a thread
+var (
+	threadCreateAttempts = 3
+	threadCreateBackoff  = 500 * time.Millisecond
+)
+
+// createThreadWithRetry creates an OpenAI thread for an avatar, retrying failed attempts
+// with exponential backoff. The last error is returned when every attempt fails
+func createThreadWithRetry(client *assistant.Client, conversationID, avatarID int64) (string, error) {
+	backoff := threadCreateBackoff
+	var lastErr error
+	for attempt := 1; attempt <= threadCreateAttempts; attempt++ {
+		thread, err := client.CreateThreadWithMetadata(assistant.ThreadMetadata(conversationID, avatarID))
+		if err == nil {
+			return thread.ID, nil
+		}
+		lastErr = err
+		log.Printf("[API] Failed to create OpenAI thread for avatar conversation_id=%d avatar_id=%d attempt=%d/%d err=%v",
+			conversationID, avatarID, attempt, threadCreateAttempts, err)
+		if attempt < threadCreateAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return "", lastErr
+}
+
+// ConversationAvatarResponse represents a conversation participant with the status of its thread
+type ConversationAvatarResponse struct {
+	AvatarResponse
+	ThreadID     string `json:"thread_id,omitempty"`
+	ThreadStatus string `json:"thread_status"`
+}
+
+// newConversationAvatarResponse converts a participant and its thread ID to its API representation
+func newConversationAvatarResponse(avatar *models.Avatar, threadID string) ConversationAvatarResponse {
+	status := ThreadStatusReady
+	if threadID == "" {
+		status = ThreadStatusMissing
+	}
+	return ConversationAvatarResponse{
+		AvatarResponse: newAvatarResponse(avatar),
+		ThreadID:       threadID,
+		ThreadStatus:   status,
+	}
+}
+
+// DefaultMaxConversationAvatars is the number of avatars a conversation can have by default
+const DefaultMaxConversationAvatars = 10
+
+// maxConversationAvatars returns the configured participant limit, falling back to the default
+func maxConversationAvatars(n int) int {
+	if n > 0 {
+		return n
+	}
+	return DefaultMaxConversationAvatars
+}
+
+// tooManyAvatarsMessage is the error message for participants over the limit
+func tooManyAvatarsMessage(limit int) string {
+	return fmt.Sprintf("Too many avatars (a conversation can have at most %d)", limit)
+}
+
 // ConversationAvatarHandler handles avatar participation in conversations
 type ConversationAvatarHandler struct {
 	db          *db.DB
 	assistant   *assistant.Client
 	watcher     *watcher.WatcherManager
 	broadcaster *EventBroadcaster
+	// maxAvatars bounds the number of avatars in a conversation
+	maxAvatars int
 }
 
 // NewConversationAvatarHandler creates a new handler
@@ -35,6 +108,11 @@ func (h *ConversationAvatarHandler) SetBroadcaster(broadcaster *EventBroadcaster
 	h.broadcaster = broadcaster
 }
 
+// SetMaxAvatars sets how many avatars a conversation can have
+func (h *ConversationAvatarHandler) SetMaxAvatars(n int) {
+	h.maxAvatars = n
+}
+
 // AddAvatarRequest represents the request body for adding an avatar
 type AddAvatarRequest struct {
 	AvatarID int64 `json:"avatar_id"`
@@ -86,58 +164,193 @@ func (h *ConversationAvatarHandler) AddAvatar(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	participants, err := h.db.GetConversationAvatars(conversationID)
+	if err != nil {
+		log.Printf("[API] AddAvatar failed: DB error getting participants err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+	limit := maxConversationAvatars(h.maxAvatars)
+	if len(participants) >= limit && !containsAvatar(participants, avatar.ID) {
+		log.Printf("[API] AddAvatar failed: participant limit reached conversation_id=%d limit=%d", conversationID, limit)
+		http.Error(w, tooManyAvatarsMessage(limit), http.StatusConflict)
+		return
+	}
+
+	if err := h.attachAvatar(conversationID, avatar); err != nil {
+		log.Printf("[API] AddAvatar failed: DB error adding avatar err=%v", err)
+		http.Error(w, "Failed to add avatar", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] AddAvatar completed conversation_id=%d avatar_id=%d", conversationID, req.AvatarID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// containsAvatar reports whether an avatar is among the participants
+func containsAvatar(participants []models.Avatar, avatarID int64) bool {
+	for _, a := range participants {
+		if a.ID == avatarID {
+			return true
+		}
+	}
+	return false
+}
+
+// attachAvatar creates the avatar's OpenAI thread, adds it to the conversation,
+// starts its watcher and broadcasts the avatar_joined event
+// Only a failure to persist the participation is returned as an error
+func (h *ConversationAvatarHandler) attachAvatar(conversationID int64, avatar *models.Avatar) error {
 	// Create OpenAI Thread for the avatar
 	var threadID string
 	if h.assistant != nil {
-		log.Printf("[API] Creating OpenAI thread for avatar conversation_id=%d avatar_id=%d", conversationID, req.AvatarID)
-		thread, err := h.assistant.CreateThread()
+		log.Printf("[API] Creating OpenAI thread for avatar conversation_id=%d avatar_id=%d", conversationID, avatar.ID)
+		id, err := createThreadWithRetry(h.assistantFor(conversationID), conversationID, avatar.ID)
 		if err != nil {
-			log.Printf("[API] Failed to create OpenAI thread for avatar conversation_id=%d avatar_id=%d err=%v", conversationID, req.AvatarID, err)
-			// Continue even if thread creation fails, but log the error
-			// Add avatar without thread_id
-			if err := h.db.AddAvatarToConversationWithThreadID(conversationID, req.AvatarID, ""); err != nil {
-				log.Printf("[API] AddAvatar failed: DB error adding avatar err=%v", err)
-				http.Error(w, "Failed to add avatar", http.StatusInternalServerError)
-				return
-			}
+			// Continue even if thread creation fails; the thread can be recreated later
+			log.Printf("[API] Giving up on OpenAI thread for avatar conversation_id=%d avatar_id=%d err=%v", conversationID, avatar.ID, err)
 		} else {
-			threadID = thread.ID
-			log.Printf("[API] OpenAI thread created for avatar conversation_id=%d avatar_id=%d thread_id=%s", conversationID, req.AvatarID, threadID)
-
-			// Add avatar to conversation with thread ID
-			if err := h.db.AddAvatarToConversationWithThreadID(conversationID, req.AvatarID, threadID); err != nil {
-				log.Printf("[API] AddAvatar failed: DB error adding avatar err=%v", err)
-				http.Error(w, "Failed to add avatar", http.StatusInternalServerError)
-				return
-			}
+			threadID = id
+			log.Printf("[API] OpenAI thread created for avatar conversation_id=%d avatar_id=%d thread_id=%s", conversationID, avatar.ID, threadID)
 		}
 	} else {
-		log.Printf("[API] OpenAI assistant client is nil, skipping thread creation for avatar_id=%d", req.AvatarID)
-		// Add avatar without thread_id
-		if err := h.db.AddAvatarToConversationWithThreadID(conversationID, req.AvatarID, ""); err != nil {
-			log.Printf("[API] AddAvatar failed: DB error adding avatar err=%v", err)
-			http.Error(w, "Failed to add avatar", http.StatusInternalServerError)
-			return
-		}
+		log.Printf("[API] OpenAI assistant client is nil, skipping thread creation for avatar_id=%d", avatar.ID)
+	}
+
+	// Add avatar to conversation (without thread_id if thread creation failed)
+	if err := h.db.AddAvatarToConversationWithThreadID(conversationID, avatar.ID, threadID); err != nil {
+		return err
 	}
 
 	// Start watcher
 	if h.watcher != nil {
-		if err := h.watcher.StartWatcher(conversationID, req.AvatarID); err != nil {
-			log.Printf("[API] AddAvatar warning: failed to start watcher err=%v", err)
+		if err := h.watcher.StartWatcher(conversationID, avatar.ID); err != nil {
+			log.Printf("[API] Warning: failed to start watcher conversation_id=%d avatar_id=%d err=%v", conversationID, avatar.ID, err)
 			// Continue - avatar was added, watcher failure is non-fatal
 		}
 	}
 
 	// Broadcast avatar joined event via SSE
 	if h.broadcaster != nil {
-		h.broadcaster.BroadcastAvatarJoined(conversationID, avatar.ID, avatar.Name)
-		log.Printf("[API] AddAvatar broadcasted avatar_joined event conversation_id=%d avatar_id=%d",
+		h.broadcaster.BroadcastAvatarJoinedWithDisplay(conversationID, avatar.ID, avatar.Name, avatar.Color, avatar.Emoji)
+		log.Printf("[API] Broadcasted avatar_joined event conversation_id=%d avatar_id=%d",
 			conversationID, avatar.ID)
 	}
 
-	log.Printf("[API] AddAvatar completed conversation_id=%d avatar_id=%d", conversationID, req.AvatarID)
-	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// AttachTeamRequest represents the request body for attaching a team
+type AttachTeamRequest struct {
+	TeamID int64 `json:"team_id"`
+}
+
+// AttachTeamResponse lists the avatars added by attaching a team
+type AttachTeamResponse struct {
+	TeamID     int64   `json:"team_id"`
+	AddedIDs   []int64 `json:"added_avatar_ids"`
+	SkippedIDs []int64 `json:"skipped_avatar_ids,omitempty"`
+	FailedIDs  []int64 `json:"failed_avatar_ids,omitempty"`
+}
+
+// AttachTeam handles POST /api/conversations/{id}/teams
+// Every team member that is not already a participant is added to the conversation
+func (h *ConversationAvatarHandler) AttachTeam(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] AttachTeam started")
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		log.Printf("[API] AttachTeam failed: invalid conversation ID err=%v", err)
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AttachTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[API] AttachTeam failed: invalid request body err=%v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[API] AttachTeam request conversation_id=%d team_id=%d", conversationID, req.TeamID)
+
+	// Verify conversation exists
+	_, err = h.db.GetConversation(conversationID)
+	if err == sql.ErrNoRows {
+		log.Printf("[API] AttachTeam failed: conversation not found conversation_id=%d", conversationID)
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] AttachTeam failed: DB error getting conversation err=%v", err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	// Verify team exists
+	if _, err := h.db.GetTeam(req.TeamID); err == sql.ErrNoRows {
+		log.Printf("[API] AttachTeam failed: team not found team_id=%d", req.TeamID)
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[API] AttachTeam failed: DB error getting team err=%v", err)
+		http.Error(w, "Failed to get team", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.db.GetTeamMembers(req.TeamID)
+	if err != nil {
+		log.Printf("[API] AttachTeam failed: DB error getting team members err=%v", err)
+		http.Error(w, "Failed to get team members", http.StatusInternalServerError)
+		return
+	}
+
+	participants, err := h.db.GetConversationAvatars(conversationID)
+	if err != nil {
+		log.Printf("[API] AttachTeam failed: DB error getting participants err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+	existing := make(map[int64]bool)
+	for _, a := range participants {
+		existing[a.ID] = true
+	}
+
+	// The team is attached entirely or not at all
+	joining := 0
+	for _, member := range members {
+		if !existing[member.ID] {
+			joining++
+		}
+	}
+	limit := maxConversationAvatars(h.maxAvatars)
+	if len(participants)+joining > limit {
+		log.Printf("[API] AttachTeam failed: participant limit exceeded conversation_id=%d participants=%d joining=%d limit=%d",
+			conversationID, len(participants), joining, limit)
+		http.Error(w, tooManyAvatarsMessage(limit), http.StatusConflict)
+		return
+	}
+
+	response := AttachTeamResponse{TeamID: req.TeamID, AddedIDs: []int64{}}
+	for i := range members {
+		member := &members[i]
+		if existing[member.ID] {
+			response.SkippedIDs = append(response.SkippedIDs, member.ID)
+			continue
+		}
+		if err := h.attachAvatar(conversationID, member); err != nil {
+			log.Printf("[API] AttachTeam warning: failed to add avatar conversation_id=%d avatar_id=%d err=%v", conversationID, member.ID, err)
+			response.FailedIDs = append(response.FailedIDs, member.ID)
+			continue
+		}
+		response.AddedIDs = append(response.AddedIDs, member.ID)
+	}
+
+	log.Printf("[API] AttachTeam completed conversation_id=%d team_id=%d added=%d skipped=%d failed=%d",
+		conversationID, req.TeamID, len(response.AddedIDs), len(response.SkippedIDs), len(response.FailedIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // RemoveAvatar handles DELETE /api/conversations/{id}/avatars/{avatar_id}
@@ -180,6 +393,13 @@ func (h *ConversationAvatarHandler) RemoveAvatar(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// The remaining avatars stop listing the one that left
+	if h.watcher != nil {
+		if err := h.watcher.RefreshConversation(conversationID); err != nil {
+			log.Printf("[API] RemoveAvatar warning: failed to refresh watchers err=%v", err)
+		}
+	}
+
 	// Broadcast avatar left event via SSE
 	if h.broadcaster != nil {
 		h.broadcaster.BroadcastAvatarLeft(conversationID, avatarID)
@@ -217,7 +437,7 @@ func (h *ConversationAvatarHandler) ListAvatars(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	avatars, err := h.db.GetConversationAvatars(conversationID)
+	avatars, threadIDs, err := h.db.GetConversationAvatarsWithThreads(conversationID)
 	if err != nil {
 		log.Printf("[API] ListAvatars failed: DB error getting avatars err=%v", err)
 		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
@@ -225,15 +445,9 @@ func (h *ConversationAvatarHandler) ListAvatars(w http.ResponseWriter, r *http.R
 	}
 
 	// Convert to response format
-	response := make([]AvatarResponse, len(avatars))
-	for i, avatar := range avatars {
-		response[i] = AvatarResponse{
-			ID:                avatar.ID,
-			Name:              avatar.Name,
-			Prompt:            avatar.Prompt,
-			OpenAIAssistantID: avatar.OpenAIAssistantID,
-			CreatedAt:         avatar.CreatedAt.Format(time.RFC3339),
-		}
+	response := make([]ConversationAvatarResponse, len(avatars))
+	for i := range avatars {
+		response[i] = newConversationAvatarResponse(&avatars[i], threadIDs[i])
 	}
 
 	log.Printf("[API] ListAvatars completed conversation_id=%d count=%d", conversationID, len(response))
@@ -241,3 +455,74 @@ func (h *ConversationAvatarHandler) ListAvatars(w http.ResponseWriter, r *http.R
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// RecreateThread handles POST /api/conversations/{id}/avatars/{avatar_id}/recreate-thread
+// A new OpenAI thread replaces the avatar's current thread, or gives it one if creation failed earlier
+func (h *ConversationAvatarHandler) RecreateThread(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] RecreateThread started")
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		log.Printf("[API] RecreateThread failed: invalid conversation ID err=%v", err)
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	avatarID, err := strconv.ParseInt(r.PathValue("avatar_id"), 10, 64)
+	if err != nil {
+		log.Printf("[API] RecreateThread failed: invalid avatar ID err=%v", err)
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[API] RecreateThread request conversation_id=%d avatar_id=%d", conversationID, avatarID)
+
+	oldThreadID, err := h.db.GetAvatarThreadID(conversationID, avatarID)
+	if err == sql.ErrNoRows {
+		log.Printf("[API] RecreateThread failed: avatar not in conversation conversation_id=%d avatar_id=%d", conversationID, avatarID)
+		http.Error(w, "Avatar not in conversation", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] RecreateThread failed: DB error getting thread err=%v", err)
+		http.Error(w, "Failed to get avatar thread", http.StatusInternalServerError)
+		return
+	}
+
+	avatar, err := h.db.GetAvatar(avatarID)
+	if err != nil {
+		log.Printf("[API] RecreateThread failed: DB error getting avatar err=%v", err)
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	if h.assistant == nil {
+		log.Printf("[API] RecreateThread failed: OpenAI assistant client is nil")
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	threadID, err := createThreadWithRetry(h.assistantFor(conversationID), conversationID, avatarID)
+	if err != nil {
+		log.Printf("[API] RecreateThread failed: thread creation failed conversation_id=%d avatar_id=%d err=%v", conversationID, avatarID, err)
+		http.Error(w, "Failed to create thread", http.StatusBadGateway)
+		return
+	}
+
+	// Watchers look the thread up on every run, so they pick up the new thread without restarting
+	if err := h.db.UpdateAvatarThreadID(conversationID, avatarID, threadID); err != nil {
+		log.Printf("[API] RecreateThread failed: DB error updating thread err=%v", err)
+		http.Error(w, "Failed to update avatar thread", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditActionThreadRecreate, "conversation", strconv.FormatInt(conversationID, 10),
+		map[string]any{"avatar_id": avatarID, "thread_id": oldThreadID},
+		map[string]any{"avatar_id": avatarID, "thread_id": threadID})
+
+	log.Printf("[API] RecreateThread completed conversation_id=%d avatar_id=%d old_thread_id=%s thread_id=%s",
+		conversationID, avatarID, oldThreadID, threadID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newConversationAvatarResponse(avatar, threadID))
+}
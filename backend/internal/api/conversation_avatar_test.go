@@ -5,43 +5,28 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/testutil"
 )
 
-func setupTestConversationAvatarHandler(t *testing.T) (*ConversationAvatarHandler, *db.DB, func()) {
+func setupTestConversationAvatarHandler(t *testing.T) (*ConversationAvatarHandler, *db.DB) {
 	t.Helper()
 
-	tmpFile, err := os.CreateTemp("", "test_conv_avatar_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	database, err := db.NewDB(tmpFile.Name())
-	if err != nil {
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	if err := database.Migrate(); err != nil {
-		t.Fatalf("migration failed: %v", err)
-	}
+	database := testutil.NewTestDB(t)
 
 	handler := NewConversationAvatarHandler(database, nil, nil) // nil assistant and watcher for testing
 
-	cleanup := func() {
-		database.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return handler, database, cleanup
+	return handler, database
 }
 
 func TestAddAvatar(t *testing.T) {
-	handler, database, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, database := setupTestConversationAvatarHandler(t)
 
 	// Create conversation and avatar
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
@@ -68,8 +53,7 @@ func TestAddAvatar(t *testing.T) {
 }
 
 func TestAddAvatar_ConversationNotFound(t *testing.T) {
-	handler, _, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationAvatarHandler(t)
 
 	reqBody := AddAvatarRequest{AvatarID: 1}
 	body, _ := json.Marshal(reqBody)
@@ -86,8 +70,7 @@ func TestAddAvatar_ConversationNotFound(t *testing.T) {
 }
 
 func TestAddAvatar_AvatarNotFound(t *testing.T) {
-	handler, database, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, database := setupTestConversationAvatarHandler(t)
 
 	database.CreateConversation("Test Chat", "thread_123")
 
@@ -106,8 +89,7 @@ func TestAddAvatar_AvatarNotFound(t *testing.T) {
 }
 
 func TestRemoveAvatar(t *testing.T) {
-	handler, database, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, database := setupTestConversationAvatarHandler(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 	avatar, _ := database.CreateAvatar("TestBot", "Prompt", "asst_123")
@@ -132,8 +114,7 @@ func TestRemoveAvatar(t *testing.T) {
 }
 
 func TestRemoveAvatar_NotInConversation(t *testing.T) {
-	handler, database, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, database := setupTestConversationAvatarHandler(t)
 
 	database.CreateConversation("Test Chat", "thread_123")
 	database.CreateAvatar("TestBot", "Prompt", "asst_123")
@@ -152,8 +133,7 @@ func TestRemoveAvatar_NotInConversation(t *testing.T) {
 }
 
 func TestListConversationAvatars(t *testing.T) {
-	handler, database, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, database := setupTestConversationAvatarHandler(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 	avatar1, _ := database.CreateAvatar("Bot1", "Prompt1", "asst_1")
@@ -182,8 +162,7 @@ func TestListConversationAvatars(t *testing.T) {
 }
 
 func TestListConversationAvatars_ConversationNotFound(t *testing.T) {
-	handler, _, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationAvatarHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/conversations/99999/avatars", nil)
 	req.SetPathValue("id", "99999")
@@ -197,8 +176,7 @@ func TestListConversationAvatars_ConversationNotFound(t *testing.T) {
 }
 
 func TestListConversationAvatars_Empty(t *testing.T) {
-	handler, database, cleanup := setupTestConversationAvatarHandler(t)
-	defer cleanup()
+	handler, database := setupTestConversationAvatarHandler(t)
 
 	database.CreateConversation("Test Chat", "thread_123")
 
@@ -221,3 +199,263 @@ func TestListConversationAvatars_Empty(t *testing.T) {
 		t.Errorf("expected 0 avatars, got %d", len(response))
 	}
 }
+
+func TestAttachTeam(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	alice, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Prompt", "asst_2")
+	team, _ := database.CreateTeam("Support", "")
+	database.AddTeamMember(team.ID, alice.ID)
+	database.AddTeamMember(team.ID, bob.ID)
+
+	// Alice is already a participant
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+
+	body, _ := json.Marshal(AttachTeamRequest{TeamID: team.ID})
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/teams", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+
+	w := httptest.NewRecorder()
+	handler.AttachTeam(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp AttachTeamResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.AddedIDs) != 1 || resp.AddedIDs[0] != bob.ID {
+		t.Errorf("expected only Bob to be added, got %v", resp.AddedIDs)
+	}
+	if len(resp.SkippedIDs) != 1 || resp.SkippedIDs[0] != alice.ID {
+		t.Errorf("expected Alice to be skipped, got %v", resp.SkippedIDs)
+	}
+
+	avatars, _ := database.GetConversationAvatars(conv.ID)
+	if len(avatars) != 2 {
+		t.Errorf("expected 2 avatars, got %d", len(avatars))
+	}
+}
+
+func TestAttachTeam_TeamNotFound(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	database.CreateConversation("Test Chat", "thread_123")
+
+	body, _ := json.Marshal(AttachTeamRequest{TeamID: 999})
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/teams", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+
+	w := httptest.NewRecorder()
+	handler.AttachTeam(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// newThreadAssistantClient returns a client whose thread creation fails the first failures times
+func newThreadAssistantClient(t *testing.T, failures int) *assistant.Client {
+	t.Helper()
+
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || r.URL.Path != "/threads" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "not found"}}`))
+			return
+		}
+		calls++
+		if calls <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"message": "server error"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "thread_` + strconv.Itoa(calls) + `"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+}
+
+// withFastThreadRetry shortens the thread creation backoff for the duration of a test
+func withFastThreadRetry(t *testing.T) {
+	t.Helper()
+	backoff := threadCreateBackoff
+	threadCreateBackoff = time.Millisecond
+	t.Cleanup(func() { threadCreateBackoff = backoff })
+}
+
+func TestAddAvatar_RetriesThreadCreation(t *testing.T) {
+	withFastThreadRetry(t)
+	handler, database := setupTestConversationAvatarHandler(t)
+	handler.assistant = newThreadAssistantClient(t, 2)
+
+	conv, _ := database.CreateConversation("Test Chat", "")
+	avatar, _ := database.CreateAvatar("TestBot", "Prompt", "asst_123")
+
+	body, _ := json.Marshal(AddAvatarRequest{AvatarID: avatar.ID})
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/avatars", bytes.NewReader(body))
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.AddAvatar(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if threadID, _ := database.GetAvatarThreadID(conv.ID, avatar.ID); threadID != "thread_3" {
+		t.Errorf("expected thread from the third attempt, got %q", threadID)
+	}
+}
+
+func TestListConversationAvatars_ThreadStatus(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "")
+	ready, _ := database.CreateAvatar("Ready", "Prompt", "asst_1")
+	missing, _ := database.CreateAvatar("Missing", "Prompt", "asst_2")
+	database.AddAvatarToConversationWithThreadID(conv.ID, ready.ID, "thread_ready")
+	database.AddAvatarToConversationWithThreadID(conv.ID, missing.ID, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/avatars", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.ListAvatars(w, req)
+
+	var response []ConversationAvatarResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	statuses := make(map[string]string)
+	for _, a := range response {
+		statuses[a.Name] = a.ThreadStatus
+	}
+	if statuses["Ready"] != ThreadStatusReady || statuses["Missing"] != ThreadStatusMissing {
+		t.Errorf("unexpected thread statuses: %v", statuses)
+	}
+}
+
+func TestRecreateThread(t *testing.T) {
+	withFastThreadRetry(t)
+	handler, database := setupTestConversationAvatarHandler(t)
+	handler.assistant = newThreadAssistantClient(t, 0)
+
+	conv, _ := database.CreateConversation("Test Chat", "")
+	avatar, _ := database.CreateAvatar("TestBot", "Prompt", "asst_123")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/avatars/1/recreate-thread", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	req.SetPathValue("avatar_id", strconv.FormatInt(avatar.ID, 10))
+	w := httptest.NewRecorder()
+	handler.RecreateThread(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response ConversationAvatarResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.ThreadID != "thread_1" || response.ThreadStatus != ThreadStatusReady {
+		t.Errorf("unexpected response: %+v", response)
+	}
+	if threadID, _ := database.GetAvatarThreadID(conv.ID, avatar.ID); threadID != "thread_1" {
+		t.Errorf("expected stored thread thread_1, got %q", threadID)
+	}
+}
+
+func TestRecreateThread_Errors(t *testing.T) {
+	withFastThreadRetry(t)
+	handler, database := setupTestConversationAvatarHandler(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "")
+	avatar, _ := database.CreateAvatar("TestBot", "Prompt", "asst_123")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "")
+
+	recreate := func(avatarID int64) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/avatars/1/recreate-thread", nil)
+		req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+		req.SetPathValue("avatar_id", strconv.FormatInt(avatarID, 10))
+		w := httptest.NewRecorder()
+		handler.RecreateThread(w, req)
+		return w.Code
+	}
+
+	if code := recreate(999); code != http.StatusNotFound {
+		t.Errorf("expected status %d for avatar outside the conversation, got %d", http.StatusNotFound, code)
+	}
+	if code := recreate(avatar.ID); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d without OpenAI, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	handler.assistant = newThreadAssistantClient(t, threadCreateAttempts)
+	if code := recreate(avatar.ID); code != http.StatusBadGateway {
+		t.Errorf("expected status %d when every attempt fails, got %d", http.StatusBadGateway, code)
+	}
+}
+
+func TestAddAvatar_ParticipantLimit(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+	handler.SetMaxAvatars(1)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	alice, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Prompt", "asst_2")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+
+	add := func(avatarID int64) int {
+		body, _ := json.Marshal(AddAvatarRequest{AvatarID: avatarID})
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/avatars", bytes.NewReader(body))
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler.AddAvatar(w, req)
+		return w.Code
+	}
+
+	if code := add(bob.ID); code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, code)
+	}
+	// Adding an existing participant again is not refused
+	if code := add(alice.ID); code != http.StatusNoContent {
+		t.Errorf("expected status %d for existing participant, got %d", http.StatusNoContent, code)
+	}
+}
+
+func TestAttachTeam_ParticipantLimit(t *testing.T) {
+	handler, database := setupTestConversationAvatarHandler(t)
+	handler.SetMaxAvatars(2)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	alice, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Prompt", "asst_2")
+	carol, _ := database.CreateAvatar("Carol", "Prompt", "asst_3")
+	team, _ := database.CreateTeam("Support", "")
+	database.AddTeamMember(team.ID, bob.ID)
+	database.AddTeamMember(team.ID, carol.ID)
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+
+	body, _ := json.Marshal(AttachTeamRequest{TeamID: team.ID})
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/teams", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.AttachTeam(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if avatars, _ := database.GetConversationAvatars(conv.ID); len(avatars) != 1 {
+		t.Errorf("expected no team member to be added, got %d avatars", len(avatars))
+	}
+}
@@ -1,24 +1,194 @@
 package api
 
 import (
+	"database/sql"
+	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/watcher"
+)
+
+const (
+	// defaultEventHistoryLimit はイベント履歴の既定の取得件数
+	defaultEventHistoryLimit = 200
+	// maxEventHistoryLimit はイベント履歴の最大取得件数
+	maxEventHistoryLimit = 1000
+	// DefaultHeartbeatInterval はSSEストリームにハートビートを送る既定の間隔
+	// リバースプロキシがアイドル状態の接続を切断しないよう、nginx の既定のタイムアウト (60秒) より短くする
+	DefaultHeartbeatInterval = 15 * time.Second
 )
 
+// heartbeatComment はハートビートとして送るSSEのコメント行。EventSourceはコメントをイベントとして扱わない
+var heartbeatComment = []byte(": ping\n\n")
+
 // ConversationEventsHandler は会話イベントのSSE接続を処理する
 type ConversationEventsHandler struct {
 	broadcaster *EventBroadcaster
+	db          *db.DB
+	watcher     *watcher.WatcherManager
+	// sender はWebSocketから送信されたメッセージを保存する
+	sender *ConversationHandler
+	// allowMessage はWebSocketからのメッセージ送信にHTTPと同じレート制限を適用する。nil なら制限しない
+	allowMessage func(r *http.Request) (bool, time.Duration)
+	// heartbeat はSSEストリームにハートビートを送る間隔。0 なら送らない
+	heartbeat time.Duration
+}
+
+// EventHistoryResponse は保存済みイベントのAPIレスポンスを表す
+type EventHistoryResponse struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt string          `json:"created_at"`
 }
 
 // NewConversationEventsHandler は新しいハンドラーを作成する
 func NewConversationEventsHandler(broadcaster *EventBroadcaster) *ConversationEventsHandler {
 	return &ConversationEventsHandler{
 		broadcaster: broadcaster,
+		heartbeat:   DefaultHeartbeatInterval,
+	}
+}
+
+// SetHeartbeatInterval はSSEストリームにハートビートを送る間隔を設定する。0 の場合は送らない
+// 以降の接続から適用される
+func (h *ConversationEventsHandler) SetHeartbeatInterval(d time.Duration) {
+	h.heartbeat = d
+}
+
+// SetDB はイベント履歴の取得に使うデータベースを設定する
+func (h *ConversationEventsHandler) SetDB(database *db.DB) {
+	h.db = database
+}
+
+// SetWatcherManager は購読時に休止中の会話のウォッチャーを再開するためのマネージャーを設定する
+func (h *ConversationEventsHandler) SetWatcherManager(wm *watcher.WatcherManager) {
+	h.watcher = wm
+}
+
+// HandleHistory は GET /api/conversations/{id}/events/history を処理する
+// after_id より後のイベントを古い順に返す
+func (h *ConversationEventsHandler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] GetEventHistory started")
+
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.db == nil {
+		log.Printf("[API] GetEventHistory failed: database not configured")
+		http.Error(w, "Event history is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var afterID int64
+	if v := r.URL.Query().Get("after_id"); v != "" {
+		afterID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || afterID < 0 {
+			http.Error(w, "Invalid after_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultEventHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > maxEventHistoryLimit {
+			limit = maxEventHistoryLimit
+		}
+	}
+
+	if _, err := h.db.GetConversation(conversationID); err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[API] GetEventHistory failed: DB error getting conversation err=%v", err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := h.db.GetConversationEvents(conversationID, afterID, limit)
+	if err != nil {
+		log.Printf("[API] GetEventHistory failed: DB error err=%v", err)
+		http.Error(w, "Failed to get event history", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]EventHistoryResponse, len(events))
+	for i, e := range events {
+		response[i] = EventHistoryResponse{
+			ID:        e.ID,
+			Type:      e.EventType,
+			Data:      json.RawMessage(e.Data),
+			CreatedAt: e.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		}
+	}
+
+	log.Printf("[API] GetEventHistory completed conversation_id=%d count=%d", conversationID, len(response))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleViewers は GET /api/conversations/{id}/viewers を処理する
+// 現在の視聴者数とプロセス起動後の接続履歴を返す
+func (h *ConversationEventsHandler) HandleViewers(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	stats := h.broadcaster.ViewerStats(conversationID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// parseEventTypes は ?types=message,typing 形式のクエリを解析する
+// 指定がない場合は nil を返し、すべてのイベントを受信する
+func parseEventTypes(r *http.Request) []string {
+	value := r.URL.Query().Get("types")
+	if value == "" {
+		return nil
 	}
+
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// parseLastEventID は再接続したクライアントが送る Last-Event-ID ヘッダーを解析する
+// ヘッダーがない場合や不正な値の場合は 0 を返し、再送しない
+func parseLastEventID(r *http.Request) int64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		return 0
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || id < 0 {
+		log.Printf("[SSE] Ignoring invalid Last-Event-ID value=%q", value)
+		return 0
+	}
+	return id
 }
 
 // HandleEvents は GET /api/conversations/{id}/events を処理する
+// ?types=message,avatar_joined のように受信するイベントタイプを絞り込める
 func (h *ConversationEventsHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
 	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -44,12 +214,26 @@ func (h *ConversationEventsHandler) HandleEvents(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// イベントを購読
-	eventCh := h.broadcaster.Subscribe(conversationID)
+	// イベントを購読し、再接続の場合は切断中に送信されたイベントを受け取る
+	eventCh, missed, _ := h.broadcaster.SubscribeFrom(conversationID, parseLastEventID(r), parseEventTypes(r)...)
+	if eventCh == nil {
+		// シャットダウン中は別のインスタンスへの再接続を促す
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
 	defer h.broadcaster.Unsubscribe(conversationID, eventCh)
 
+	// 休止中の会話は閲覧が再開されたのでウォッチャーを再開する
+	if h.watcher != nil {
+		if err := h.watcher.Wake(conversationID); err != nil {
+			log.Printf("[SSE] Failed to wake conversation conversation_id=%d err=%v", conversationID, err)
+		}
+	}
+
 	// 接続完了イベントを送信
-	_, err = w.Write([]byte("event: connected\ndata: {}\n\n"))
+	connected, _ := FormatSSE(Event{Type: models.EventTypeConnected, Data: models.EmptyEvent{}})
+	_, err = w.Write(connected)
 	if err != nil {
 		log.Printf("[SSE] Failed to send connected event err=%v", err)
 		return
@@ -58,6 +242,28 @@ func (h *ConversationEventsHandler) HandleEvents(w http.ResponseWriter, r *http.
 
 	log.Printf("[SSE] Client connected conversation_id=%d", conversationID)
 
+	// 切断中のイベントを再送する。再送できない場合は resync で会話の再取得を促す
+	for _, event := range missed {
+		data, err := FormatSSE(event)
+		if err != nil {
+			log.Printf("[SSE] Failed to format event err=%v", err)
+			continue
+		}
+		if _, err := w.Write(data); err != nil {
+			log.Printf("[SSE] Failed to write event err=%v", err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	// イベントがない間も接続が切られないよう、一定間隔でハートビートを送る
+	var heartbeat <-chan time.Time
+	if h.heartbeat > 0 {
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
 	// イベントとクライアント切断を監視
 	ctx := r.Context()
 	for {
@@ -65,8 +271,15 @@ func (h *ConversationEventsHandler) HandleEvents(w http.ResponseWriter, r *http.
 		case <-ctx.Done():
 			log.Printf("[SSE] Client disconnected conversation_id=%d", conversationID)
 			return
+		case <-heartbeat:
+			if _, err := w.Write(heartbeatComment); err != nil {
+				log.Printf("[SSE] Failed to write heartbeat err=%v", err)
+				return
+			}
+			flusher.Flush()
 		case event, ok := <-eventCh:
 			if !ok {
+				// シャットダウンまたは切断ポリシーでストリームが閉じられた
 				log.Printf("[SSE] Event channel closed conversation_id=%d", conversationID)
 				return
 			}
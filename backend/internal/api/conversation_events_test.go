@@ -1,9 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
 )
 
 func TestConversationEventsHandler_HandleEvents_InvalidID(t *testing.T) {
@@ -96,3 +102,263 @@ func (w *testResponseWriter) Flush() {
 	}
 }
 
+
+func TestConversationEventsHandler_HandleHistory(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	database := convHandler.db
+	conv, _ := database.CreateConversation("Test", "")
+
+	broadcaster := NewEventBroadcaster()
+	broadcaster.SetHistory(database)
+	convHandler.SetBroadcaster(broadcaster)
+
+	// Events are recorded even when no client is subscribed
+	broadcaster.BroadcastAvatarJoined(conv.ID, 1, "Alice")
+	broadcaster.BroadcastMessage(conv.ID, models.MessageEvent{Content: "hello"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/interrupt", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	convHandler.Interrupt(httptest.NewRecorder(), req)
+
+	handler := NewConversationEventsHandler(broadcaster)
+	handler.SetDB(database)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/conversations/1/events/history", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.HandleHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var events []EventHistoryResponse
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events (message events are not recorded), got %d", len(events))
+	}
+	if events[0].Type != "avatar_joined" || events[1].Type != "interrupt" {
+		t.Errorf("unexpected event types: %s, %s", events[0].Type, events[1].Type)
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(events[0].Data, &data); err != nil || data["avatar_name"] != "Alice" {
+		t.Errorf("unexpected avatar_joined data: %s", events[0].Data)
+	}
+
+	// after_id skips events the client already has
+	req = httptest.NewRequest(http.MethodGet, "/api/conversations/1/events/history?after_id="+strconv.FormatInt(events[0].ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w = httptest.NewRecorder()
+	handler.HandleHistory(w, req)
+
+	var after []EventHistoryResponse
+	json.NewDecoder(w.Body).Decode(&after)
+	if len(after) != 1 || after[0].Type != "interrupt" {
+		t.Errorf("expected only the interrupt event, got %+v", after)
+	}
+}
+
+func TestConversationEventsHandler_HandleHistory_NotFound(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	handler := NewConversationEventsHandler(NewEventBroadcaster())
+	handler.SetDB(convHandler.db)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/999/events/history", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	handler.HandleHistory(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestConversationEventsHandler_HandleHistory_InvalidLimit(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	conv, _ := convHandler.db.CreateConversation("Test", "")
+	handler := NewConversationEventsHandler(NewEventBroadcaster())
+	handler.SetDB(convHandler.db)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/events/history?limit=0", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.HandleHistory(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", nil},
+		{"?types=message", []string{"message"}},
+		{"?types=message,%20typing,,avatar_joined", []string{"message", "typing", "avatar_joined"}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/events"+tt.query, nil)
+		got := parseEventTypes(req)
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("parseEventTypes(%q) = %v, want %v", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestConversationEventsHandler_HandleEvents_ShuttingDown(t *testing.T) {
+	broadcaster := NewEventBroadcaster()
+	broadcaster.Shutdown(time.Second)
+	handler := NewConversationEventsHandler(broadcaster)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/events", nil)
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+
+	handler.HandleEvents(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+}
+
+func TestConversationEventsHandler_HandleEvents_ClosedOnShutdown(t *testing.T) {
+	broadcaster := NewEventBroadcaster()
+	handler := NewConversationEventsHandler(broadcaster)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/events", nil)
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleEvents(rr, req)
+		close(done)
+	}()
+
+	// Wait for the client to subscribe
+	for i := 0; i < 100 && broadcaster.ClientCount(1) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	broadcaster.Shutdown(time.Second)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected handler to return after shutdown")
+	}
+	if !strings.Contains(rr.Body.String(), "event: server_shutdown\nretry: 1000\n") {
+		t.Errorf("Expected server_shutdown event in stream, got %q", rr.Body.String())
+	}
+}
+
+func TestConversationEventsHandler_HandleEvents_Resume(t *testing.T) {
+	broadcaster := NewEventBroadcaster()
+	handler := NewConversationEventsHandler(broadcaster)
+
+	// Events sent before the client reconnects
+	ch := broadcaster.Subscribe(1)
+	broadcaster.Broadcast(1, Event{Type: models.EventTypeMessage, Data: map[string]any{"content": "seen"}})
+	broadcaster.Broadcast(1, Event{Type: models.EventTypeMessage, Data: map[string]any{"content": "missed"}})
+	seen, missed := <-ch, <-ch
+	broadcaster.Unsubscribe(1, ch)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/events", nil)
+	req.SetPathValue("id", "1")
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(seen.ID, 10))
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleEvents(rr, req)
+		close(done)
+	}()
+	for i := 0; i < 100 && broadcaster.ClientCount(1) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	broadcaster.Shutdown(time.Second)
+	<-done
+
+	body := rr.Body.String()
+	replayed := "event: message\nid: " + strconv.FormatInt(missed.ID, 10) + "\ndata: {\"content\":\"missed\"}\n\n"
+	if !strings.Contains(body, replayed) || strings.Contains(body, `"seen"`) {
+		t.Errorf("Expected only the missed event to be replayed, got %q", body)
+	}
+}
+
+func TestConversationEventsHandler_HandleEvents_Heartbeat(t *testing.T) {
+	broadcaster := NewEventBroadcaster()
+	handler := NewConversationEventsHandler(broadcaster)
+	handler.SetHeartbeatInterval(10 * time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/events", nil)
+	req.SetPathValue("id", "1")
+
+	pinged := make(chan struct{}, 1)
+	rr := &testResponseWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		onWrite: func(data []byte) {
+			if string(data) == ": ping\n\n" {
+				select {
+				case pinged <- struct{}{}:
+				default:
+				}
+			}
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleEvents(rr, req)
+		close(done)
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Error("Expected a heartbeat on an idle stream")
+	}
+	broadcaster.Shutdown(time.Second)
+	<-done
+}
+
+func TestConversationEventsHandler_HandleViewers(t *testing.T) {
+	broadcaster := NewEventBroadcaster()
+	handler := NewConversationEventsHandler(broadcaster)
+	broadcaster.Subscribe(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/viewers", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.HandleViewers(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var stats ViewerStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.ConversationID != 1 || stats.Viewers != 1 || stats.PeakViewers != 1 || stats.TotalConnections != 1 {
+		t.Errorf("Unexpected viewer stats: %+v", stats)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/conversations/invalid/viewers", nil)
+	req.SetPathValue("id", "invalid")
+	w = httptest.NewRecorder()
+	handler.HandleViewers(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// defaultFeedLimit is the number of recent messages in a conversation feed by default
+	defaultFeedLimit = 50
+	// maxFeedLimit caps the limit parameter of the conversation feed
+	maxFeedLimit = 200
+	// feedTitleLength is the number of characters of a message used as its entry title
+	feedTitleLength = 80
+	// atomNamespace is the XML namespace of Atom documents
+	atomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+// atomFeed is an Atom feed of a conversation's recent messages
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomEntry is one message in a conversation feed
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// GetFeed handles GET /api/conversations/{id}/feed
+// Returns the latest limit (default 50) messages as an Atom feed, newest first,
+// so a conversation can be followed in a feed reader without an open event stream
+func (h *ConversationHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultFeedLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > maxFeedLimit {
+			limit = maxFeedLimit
+		}
+	}
+
+	conv, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	messages, err := h.db.GetRecentMessages(id, limit)
+	if err != nil {
+		log.Printf("[API] GetFeed failed: DB error getting messages conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	// Avatars that have left the conversation keep their names in the feed
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		log.Printf("[API] GetFeed failed: DB error getting avatars err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+	avatarNames := make(map[int64]string, len(avatars))
+	for _, a := range avatars {
+		avatarNames[a.ID] = a.Name
+	}
+
+	updated := conv.UpdatedAt
+	if len(messages) > 0 && messages[0].CreatedAt.After(updated) {
+		updated = messages[0].CreatedAt
+	}
+
+	setVersionHeaders(w, updated)
+	if notModified(r, updated) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	feed := newAtomFeed(conv, messages, avatarNames, feedURL(r), updated)
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("[API] GetFeed failed: encoding feed conversation_id=%d err=%v", id, err)
+	}
+}
+
+// newAtomFeed builds the feed of a conversation from its recent messages, newest first
+func newAtomFeed(conv *models.Conversation, messages []models.Message, avatarNames map[int64]string, selfURL string, updated time.Time) atomFeed {
+	feed := atomFeed{
+		XMLNS:   atomNamespace,
+		ID:      fmt.Sprintf("urn:multi-avatar-chat:conversation:%d", conv.ID),
+		Title:   conv.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: selfURL},
+		Entries: make([]atomEntry, len(messages)),
+	}
+	for i, msg := range messages {
+		sender := feedSenderName(&msg, avatarNames)
+		feed.Entries[i] = atomEntry{
+			ID:      fmt.Sprintf("urn:multi-avatar-chat:conversation:%d:message:%d", conv.ID, msg.ID),
+			Title:   sender + ": " + feedEntryTitle(msg.Content),
+			Updated: msg.CreatedAt.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: sender},
+			Content: atomContent{Type: "text", Body: msg.Content},
+		}
+	}
+	return feed
+}
+
+// feedSenderName returns the name shown as the author of a message
+func feedSenderName(msg *models.Message, avatarNames map[int64]string) string {
+	switch msg.SenderType {
+	case models.SenderTypeUser:
+		return "ユーザ"
+	case models.SenderTypeSystem:
+		return "System"
+	}
+	if msg.SenderID != nil {
+		if name, ok := avatarNames[*msg.SenderID]; ok {
+			return name
+		}
+	}
+	return "Avatar"
+}
+
+// feedEntryTitle shortens the first line of a message to an entry title
+func feedEntryTitle(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	runes := []rune(line)
+	if len(runes) > feedTitleLength {
+		return string(runes[:feedTitleLength]) + "…"
+	}
+	return line
+}
+
+// feedURL returns the absolute URL a feed was requested at, used as its self link
+func feedURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestGetFeed(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Planning", "")
+	avatar, _ := handler.db.CreateAvatar("Alice", "prompt", "")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "What should we build?\nSomething small.")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatar.ID, strings.Repeat("a", 100))
+	handler.db.CreateMessage(conv.ID, models.SenderTypeSystem, nil, "Digest")
+
+	getFeed := func(query string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/feed"+query, nil)
+		req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		handler.GetFeed(w, req)
+		return w
+	}
+
+	w := getFeed("?limit=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("expected an Atom content type, got %q", ct)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid feed: %v\n%s", err, w.Body.String())
+	}
+	if feed.Title != "Planning" || feed.Link.Href != "http://example.com/api/conversations/1/feed?limit=2" {
+		t.Errorf("unexpected feed: %+v", feed)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(feed.Entries))
+	}
+	if feed.Entries[0].Author.Name != "System" || feed.Entries[1].Author.Name != "Alice" {
+		t.Errorf("expected the newest messages first, got %+v", feed.Entries)
+	}
+	if title := feed.Entries[1].Title; title != "Alice: "+strings.Repeat("a", feedTitleLength)+"…" {
+		t.Errorf("expected a shortened title, got %q", title)
+	}
+
+	feed = atomFeed{}
+	xml.Unmarshal(getFeed("", nil).Body.Bytes(), &feed)
+	if len(feed.Entries) != 3 || feed.Entries[2].Title != "ユーザ: What should we build?" ||
+		feed.Entries[2].Content.Body != "What should we build?\nSomething small." {
+		t.Errorf("unexpected user entry: %+v", feed.Entries)
+	}
+
+	// Feed readers polling with the ETag get 304 until a message arrives
+	etag := w.Header().Get("ETag")
+	if w := getFeed("", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+
+	if w := getFeed("?limit=0", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid limit, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/999/feed", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handler.GetFeed(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// maxImportedHistoryLength keeps the history seeded into avatar threads below
+// the OpenAI limit for the content of a single message
+const maxImportedHistoryLength = 200000
+
+// unknownAssistantName labels assistant messages whose assistant is not linked to an avatar
+const unknownAssistantName = "Assistant"
+
+// ImportThreadRequest represents the request body for importing an OpenAI thread
+type ImportThreadRequest struct {
+	ThreadID      string  `json:"thread_id"`
+	Title         string  `json:"title,omitempty"`
+	AvatarIDs     []int64 `json:"avatar_ids,omitempty"`
+	ResponseStyle string  `json:"response_style,omitempty"`
+}
+
+// ImportThreadResponse represents the conversation created from an imported thread
+type ImportThreadResponse struct {
+	ConversationResponse
+	ImportedMessages int `json:"imported_messages"`
+}
+
+// ImportThread handles POST /api/conversations/import-thread
+// Copies the messages of an existing OpenAI thread into a new conversation.
+// Each chosen avatar gets a fresh thread seeded with the imported history;
+// the imported thread itself is left untouched.
+// With a job runner configured the import runs as a job and 202 Accepted is returned
+func (h *ConversationHandler) ImportThread(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] ImportThread started")
+
+	var req ImportThreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ThreadID == "" {
+		http.Error(w, "thread_id is required", http.StatusBadRequest)
+		return
+	}
+
+	responseStyle, ok := logic.ParseResponseStyle(req.ResponseStyle)
+	if !ok {
+		http.Error(w, "Invalid response_style (must be brief, normal or detailed)", http.StatusBadRequest)
+		return
+	}
+
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	avatarIDs, err := h.checkAvatarIDs(req.AvatarIDs)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	req.AvatarIDs = avatarIDs
+	req.ResponseStyle = string(responseStyle)
+
+	// Large threads take a while to copy; run the import as a job when a runner is configured
+	if h.jobs != nil {
+		job, err := h.jobs.Enqueue(JobTypeImportThread, req)
+		if err != nil {
+			log.Printf("[API] ImportThread failed: enqueue job err=%v", err)
+			http.Error(w, "Failed to start import", http.StatusInternalServerError)
+			return
+		}
+		writeJobAccepted(w, job)
+		return
+	}
+
+	resp, err := h.importThread(r.Context(), req)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// runImportThreadJob executes an import_thread job
+func (h *ConversationHandler) runImportThreadJob(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ImportThreadRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	return h.importThread(ctx, req)
+}
+
+// importThread copies the messages of an OpenAI thread into a new conversation
+// Failures are returned as *statusError so they map to an HTTP status
+func (h *ConversationHandler) importThread(ctx context.Context, req ImportThreadRequest) (*ImportThreadResponse, error) {
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, "Failed to get avatars"}
+	}
+	byAssistant := make(map[string]*models.Avatar)
+	for i := range avatars {
+		if id := avatars[i].OpenAIAssistantID; id != "" && byAssistant[id] == nil {
+			byAssistant[id] = &avatars[i]
+		}
+	}
+
+	client := h.assistant.WithContext(ctx)
+	threadMessages, err := client.ListAllMessages(req.ThreadID)
+	if assistant.IsNotFound(err) {
+		return nil, &statusError{http.StatusNotFound, "Thread not found"}
+	}
+	if err != nil {
+		log.Printf("[API] ImportThread failed: list messages thread_id=%s err=%v", req.ThreadID, err)
+		return nil, &statusError{http.StatusBadGateway, "Failed to read OpenAI thread"}
+	}
+
+	messages, history := importedMessages(threadMessages, byAssistant)
+
+	title := req.Title
+	if title == "" {
+		title = "Imported " + req.ThreadID
+	}
+
+	conv, err := h.db.CreateConversationWithStyle(title, "", req.ResponseStyle)
+	if err != nil {
+		log.Printf("[API] ImportThread failed: DB error creating conversation err=%v", err)
+		return nil, &statusError{http.StatusInternalServerError, "Failed to create conversation"}
+	}
+
+	if _, err := h.db.ImportMessages(conv.ID, messages); err != nil {
+		log.Printf("[API] ImportThread failed: DB error importing messages conversation_id=%d err=%v", conv.ID, err)
+		h.db.DeleteConversation(conv.ID)
+		return nil, &statusError{http.StatusInternalServerError, "Failed to import messages"}
+	}
+
+	// Every avatar thread starts from the same history
+	seed := logic.FormatImportedHistory(history, maxImportedHistoryLength)
+
+	var addedAvatarIDs []int64
+	for _, avatarID := range req.AvatarIDs {
+		threadID, err := createThreadWithRetry(client, conv.ID, avatarID)
+		if err != nil {
+			// Add the avatar without a thread; it can be recreated via the recreate-thread endpoint
+			log.Printf("[API] Giving up on OpenAI thread for avatar conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
+		} else if seed != "" {
+			if _, err := client.CreateMessage(threadID, seed); err != nil {
+				log.Printf("[API] Warning: failed to seed avatar thread with imported history thread_id=%s avatar_id=%d err=%v",
+					threadID, avatarID, err)
+			}
+		}
+
+		if err := h.db.AddAvatarToConversationWithThreadID(conv.ID, avatarID, threadID); err != nil {
+			log.Printf("[API] Failed to add avatar to conversation conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
+			continue
+		}
+		addedAvatarIDs = append(addedAvatarIDs, avatarID)
+	}
+
+	// Watchers start after the imported messages so they are not answered again
+	if h.watcher != nil {
+		for _, avatarID := range addedAvatarIDs {
+			if err := h.watcher.StartWatcher(conv.ID, avatarID); err != nil {
+				log.Printf("[API] Warning: Failed to start watcher conversation_id=%d avatar_id=%d err=%v", conv.ID, avatarID, err)
+			}
+		}
+	}
+
+	log.Printf("[API] ImportThread completed conversation_id=%d thread_id=%s message_count=%d avatar_count=%d",
+		conv.ID, req.ThreadID, len(messages), len(addedAvatarIDs))
+
+	return &ImportThreadResponse{
+		ConversationResponse: newConversationResponse(conv),
+		ImportedMessages:     len(messages),
+	}, nil
+}
+
+// importedMessages converts the messages of an OpenAI thread into conversation messages
+// and the history seeded into avatar threads, both in chronological order.
+// Assistant messages are attributed to the avatar linked to their assistant, if any;
+// messages without text are skipped
+func importedMessages(threadMessages []assistant.Message, byAssistant map[string]*models.Avatar) ([]models.Message, []logic.MessageForFormat) {
+	// Threads list newest first; reverse before sorting so messages within the same second keep their order
+	sorted := make([]assistant.Message, len(threadMessages))
+	for i, tm := range threadMessages {
+		sorted[len(threadMessages)-1-i] = tm
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt < sorted[j].CreatedAt })
+
+	var messages []models.Message
+	var history []logic.MessageForFormat
+	for _, tm := range sorted {
+		var parts []string
+		for _, content := range tm.Content {
+			if content.Type == "text" && content.Text != nil && content.Text.Value != "" {
+				parts = append(parts, content.Text.Value)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		msg := models.Message{
+			SenderType: models.SenderTypeUser,
+			Content:    strings.Join(parts, "\n\n"),
+			CreatedAt:  time.Unix(tm.CreatedAt, 0),
+		}
+		entry := logic.MessageForFormat{SenderType: logic.SenderTypeUserFormat, Content: msg.Content}
+
+		if tm.Role == "assistant" {
+			msg.SenderType = models.SenderTypeAvatar
+			entry.SenderType = logic.SenderTypeAvatarFormat
+			entry.SenderName = unknownAssistantName
+			if avatar := byAssistant[tm.AssistantID]; avatar != nil {
+				msg.SenderID = &avatar.ID
+				entry.SenderName = avatar.Name
+			}
+		}
+
+		messages = append(messages, msg)
+		history = append(history, entry)
+	}
+	return messages, history
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// newImportAssistantClient creates a client serving thread_src, whose messages are listed newest first.
+// Messages added to new threads are recorded in seeds by thread ID
+func newImportAssistantClient(t *testing.T, seeds map[string]string) *assistant.Client {
+	t.Helper()
+
+	var mu sync.Mutex
+	threads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/threads/thread_src/messages":
+			w.Write([]byte(`{"data": [
+				{"id": "msg_3", "role": "assistant", "assistant_id": "asst_unknown", "created_at": 1700000200,
+					"content": [{"type": "text", "text": {"value": "Another view"}}]},
+				{"id": "msg_2", "role": "assistant", "assistant_id": "asst_alice", "created_at": 1700000100,
+					"content": [{"type": "text", "text": {"value": "Hello from Alice"}}]},
+				{"id": "msg_1", "role": "user", "created_at": 1700000000,
+					"content": [{"type": "text", "text": {"value": "Hi there"}}]}
+			], "has_more": false}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/threads":
+			threads++
+			w.Write([]byte(`{"id": "thread_new` + strconv.Itoa(threads) + `"}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages"):
+			var body assistant.CreateMessageRequest
+			data, _ := io.ReadAll(r.Body)
+			json.Unmarshal(data, &body)
+			threadID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/threads/"), "/messages")
+			seeds[threadID] = body.Content
+			w.Write([]byte(`{"id": "msg_seed", "role": "user"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "not found"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+}
+
+func TestImportThread(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	seeds := make(map[string]string)
+	handler.assistant = newImportAssistantClient(t, seeds)
+
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_alice")
+	bob, _ := handler.db.CreateAvatar("Bob", "prompt", "asst_bob")
+
+	body := `{"thread_id": "thread_src", "avatar_ids": [` + strconv.FormatInt(bob.ID, 10) + `]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/import-thread", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.ImportThread(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var resp ImportThreadResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.ImportedMessages != 3 || resp.Title != "Imported thread_src" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	messages, _ := handler.db.GetMessages(resp.ID)
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+	if messages[0].SenderType != models.SenderTypeUser || messages[0].Content != "Hi there" {
+		t.Errorf("expected the user message first, got %+v", messages[0])
+	}
+	if messages[1].SenderID == nil || *messages[1].SenderID != alice.ID {
+		t.Errorf("expected the second message to be attributed to Alice, got %+v", messages[1])
+	}
+	if messages[2].SenderType != models.SenderTypeAvatar || messages[2].SenderID != nil {
+		t.Errorf("expected an unattributed avatar message, got %+v", messages[2])
+	}
+	if messages[0].CreatedAt.Unix() != 1700000000 {
+		t.Errorf("expected the original timestamp, got %v", messages[0].CreatedAt)
+	}
+
+	threadID, err := handler.db.GetAvatarThreadID(resp.ID, bob.ID)
+	if err != nil || threadID == "" {
+		t.Fatalf("expected Bob to have a fresh thread, got %q err=%v", threadID, err)
+	}
+	seed := seeds[threadID]
+	if !strings.Contains(seed, "Hi there") || !strings.Contains(seed, "Name: (Avatar) Alice") ||
+		strings.Index(seed, "Hi there") > strings.Index(seed, "Another view") {
+		t.Errorf("expected the thread to be seeded with the history in order, got %q", seed)
+	}
+}
+
+func TestImportThread_Errors(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	importThread := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/import-thread", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.ImportThread(w, req)
+		return w.Code
+	}
+
+	if code := importThread(`{"thread_id": "thread_src"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d without OpenAI, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	handler.assistant = newImportAssistantClient(t, make(map[string]string))
+
+	if code := importThread(`{}`); code != http.StatusBadRequest {
+		t.Errorf("expected status %d without thread_id, got %d", http.StatusBadRequest, code)
+	}
+	if code := importThread(`{"thread_id": "thread_src", "avatar_ids": [999]}`); code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown avatar, got %d", http.StatusBadRequest, code)
+	}
+	if code := importThread(`{"thread_id": "thread_missing"}`); code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing thread, got %d", http.StatusNotFound, code)
+	}
+
+	conversations, _ := handler.db.GetAllConversations()
+	if len(conversations) != 0 {
+		t.Errorf("expected no conversations after failed imports, got %d", len(conversations))
+	}
+}
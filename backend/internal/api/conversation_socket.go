@@ -0,0 +1,227 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// maxSocketPayload はクライアントから受信するWebSocketメッセージの最大サイズ
+	maxSocketPayload = 64 << 10
+	// socketTypingInterval は1つの接続から typing イベントをブロードキャストする最短間隔
+	socketTypingInterval = 2 * time.Second
+)
+
+// クライアントからWebSocketで受信するメッセージのタイプ
+const (
+	socketSendMessage = "send_message"
+	socketTyping      = "typing"
+)
+
+// クライアントの要求に対してWebSocketで返すメッセージのタイプ
+const (
+	socketMessageSent = "message_sent"
+	socketError       = "error"
+)
+
+// socketRequest はクライアントからWebSocketで受信するメッセージ
+// ID はクライアントが付ける任意の識別子で、応答にそのまま含める
+type socketRequest struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// socketReply はクライアントの要求への応答
+// ブロードキャストされるイベントと同じ type と data の形で送信する
+type socketReply struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	Data any    `json:"data"`
+}
+
+// SocketMessageSent は send_message に成功したときの応答
+type SocketMessageSent struct {
+	UserMessage MessageResponse `json:"user_message"`
+	// Redactions は保存前にメッセージから伏せた値の種類ごとの数
+	Redactions map[string]int `json:"redactions,omitempty"`
+}
+
+// SocketError は要求を処理できなかったときの応答
+type SocketError struct {
+	Message string `json:"message"`
+	// RetryAfterSeconds はレート制限に達したときに再送できるまでの秒数
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"`
+}
+
+// SetMessageSender はWebSocketから送信されたメッセージを保存するハンドラーを設定する
+// 設定しない場合、send_message はエラーになる
+func (h *ConversationEventsHandler) SetMessageSender(sender *ConversationHandler) {
+	h.sender = sender
+}
+
+// HandleWebSocket は GET /api/conversations/{id}/ws を処理する
+// SSEと同じイベントをWebSocketで送信し、同じ接続でメッセージ送信と入力中の通知を受け付ける
+// SSEをバッファリングするプロキシの背後にいるクライアント向け。?types= による絞り込みもSSEと同じ
+func (h *ConversationEventsHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[WebSocket] New connection request conversation_id=%d", conversationID)
+
+	eventCh := h.broadcaster.Subscribe(conversationID, parseEventTypes(r)...)
+	if eventCh == nil {
+		// シャットダウン中は別のインスタンスへの再接続を促す
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.broadcaster.Unsubscribe(conversationID, eventCh)
+
+	// CORSと同じくすべてのオリジンを受け付けるため、Originを検査しない websocket.Server を使う
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		h.serveSocket(ws, r, conversationID, eventCh)
+	}}
+	server.ServeHTTP(w, r)
+}
+
+// serveSocket は接続が閉じるまでイベントを送信し、クライアントからの要求を処理する
+func (h *ConversationEventsHandler) serveSocket(ws *websocket.Conn, r *http.Request, conversationID int64, eventCh chan Event) {
+	ws.MaxPayloadBytes = maxSocketPayload
+
+	// 休止中の会話は閲覧が再開されたのでウォッチャーを再開する
+	if h.watcher != nil {
+		if err := h.watcher.Wake(conversationID); err != nil {
+			log.Printf("[WebSocket] Failed to wake conversation conversation_id=%d err=%v", conversationID, err)
+		}
+	}
+
+	if err := websocket.JSON.Send(ws, Event{Type: models.EventTypeConnected, Data: models.EmptyEvent{}}); err != nil {
+		log.Printf("[WebSocket] Failed to send connected event err=%v", err)
+		return
+	}
+	log.Printf("[WebSocket] Client connected conversation_id=%d", conversationID)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		h.readSocket(ws, r, conversationID)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			log.Printf("[WebSocket] Client disconnected conversation_id=%d", conversationID)
+			return
+		case event, ok := <-eventCh:
+			if !ok {
+				// シャットダウンまたは切断ポリシーでストリームが閉じられた
+				log.Printf("[WebSocket] Event channel closed conversation_id=%d", conversationID)
+				return
+			}
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				log.Printf("[WebSocket] Failed to write event err=%v", err)
+				return
+			}
+		}
+	}
+}
+
+// readSocket はクライアントが接続を閉じるまで要求を読み取って処理する
+func (h *ConversationEventsHandler) readSocket(ws *websocket.Conn, r *http.Request, conversationID int64) {
+	var lastTyping time.Time
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			if err != io.EOF {
+				log.Printf("[WebSocket] Failed to read message conversation_id=%d err=%v", conversationID, err)
+			}
+			return
+		}
+
+		var req socketRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			h.replySocket(ws, socketReply{Type: socketError, Data: SocketError{Message: "Invalid message"}})
+			continue
+		}
+
+		switch req.Type {
+		case socketTyping:
+			// 入力のたびに送られても、ブロードキャストは一定間隔に抑える
+			if time.Since(lastTyping) >= socketTypingInterval {
+				lastTyping = time.Now()
+				h.broadcaster.BroadcastTyping(conversationID)
+			}
+		case socketSendMessage:
+			h.replySocket(ws, h.sendSocketMessage(r, conversationID, req))
+		default:
+			h.replySocket(ws, socketReply{Type: socketError, ID: req.ID, Data: SocketError{Message: "Unknown message type " + strconv.Quote(req.Type)}})
+		}
+	}
+}
+
+// sendSocketMessage はWebSocketで受信したメッセージを POST /api/conversations/{id}/messages と同じように保存する
+// アバターへの転送の完了は待たず、アバターはウォッチャーを通じて応答する
+func (h *ConversationEventsHandler) sendSocketMessage(r *http.Request, conversationID int64, req socketRequest) socketReply {
+	fail := func(message string) socketReply {
+		return socketReply{Type: socketError, ID: req.ID, Data: SocketError{Message: message}}
+	}
+
+	var body SendMessageRequest
+	if err := json.Unmarshal(req.Data, &body); err != nil {
+		return fail("Invalid message data")
+	}
+	if body.Content == "" {
+		return fail("Content is required")
+	}
+	if h.sender == nil {
+		return fail("Sending messages is not available")
+	}
+
+	// HTTPでの送信と同じレート制限を適用する
+	if h.allowMessage != nil {
+		if ok, wait := h.allowMessage(r); !ok {
+			retryAfter := max(int(math.Ceil(wait.Seconds())), 1)
+			return socketReply{Type: socketError, ID: req.ID, Data: SocketError{
+				Message:           fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter),
+				RetryAfterSeconds: retryAfter,
+			}}
+		}
+	}
+
+	msg, redactions, err := h.sender.postUserMessage(r.Context(), conversationID, body.Content, body.Silent)
+	if err == sql.ErrNoRows {
+		return fail("Conversation not found")
+	}
+	if err != nil {
+		log.Printf("[WebSocket] SendMessage failed conversation_id=%d err=%v", conversationID, err)
+		return fail("Failed to save message")
+	}
+
+	log.Printf("[WebSocket] SendMessage completed conversation_id=%d message_id=%d silent=%v", conversationID, msg.ID, body.Silent)
+	return socketReply{Type: socketMessageSent, ID: req.ID, Data: SocketMessageSent{
+		UserMessage: newUserMessageResponse(msg),
+		Redactions:  redactions,
+	}}
+}
+
+// replySocket はクライアントの要求に応答する
+func (h *ConversationEventsHandler) replySocket(ws *websocket.Conn, reply socketReply) {
+	if err := websocket.JSON.Send(ws, reply); err != nil {
+		log.Printf("[WebSocket] Failed to send reply type=%s err=%v", reply.Type, err)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestSocket(t *testing.T, limits RateLimits) (*Router, *db.DB, *models.Conversation, *websocket.Conn) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+	router := NewRouter(database, nil, "", nil)
+	router.SetRateLimits(limits)
+	server := httptest.NewServer(router)
+	t.Cleanup(func() {
+		router.GetBroadcaster().Shutdown(0)
+		server.Close()
+	})
+
+	conv, _ := database.CreateConversation("Socket", "")
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/conversations/" + strconv.FormatInt(conv.ID, 10) + "/ws"
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	if event := receiveSocket(t, ws); event.Type != models.EventTypeConnected {
+		t.Fatalf("expected the connected event first, got %q", event.Type)
+	}
+	return router, database, conv, ws
+}
+
+// socketFrame is an event or reply as received by a client
+type socketFrame struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+func receiveSocket(t *testing.T, ws *websocket.Conn) socketFrame {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frame socketFrame
+	if err := websocket.JSON.Receive(ws, &frame); err != nil {
+		t.Fatalf("failed to receive: %v", err)
+	}
+	return frame
+}
+
+func TestConversationSocket_EventsAndTyping(t *testing.T) {
+	router, _, conv, ws := setupTestSocket(t, DefaultRateLimits())
+
+	router.GetBroadcaster().BroadcastAvatarJoined(conv.ID, 1, "Alice")
+	if event := receiveSocket(t, ws); event.Type != models.EventTypeAvatarJoined || !strings.Contains(string(event.Data), `"Alice"`) {
+		t.Errorf("expected the avatar_joined event, got %s %s", event.Type, event.Data)
+	}
+
+	// Typing is broadcast once per interval
+	websocket.Message.Send(ws, `{"type":"typing"}`)
+	websocket.Message.Send(ws, `{"type":"typing"}`)
+	if event := receiveSocket(t, ws); event.Type != models.EventTypeTyping || !strings.Contains(string(event.Data), `"user"`) {
+		t.Errorf("expected the typing event, got %s %s", event.Type, event.Data)
+	}
+
+	// Unknown types are answered with an error carrying the request ID
+	websocket.Message.Send(ws, `{"type":"bogus","id":"x"}`)
+	if reply := receiveSocket(t, ws); reply.Type != socketError || reply.ID != "x" {
+		t.Errorf("expected an error for an unknown type, got %s %s", reply.Type, reply.Data)
+	}
+}
+
+func TestConversationSocket_SendMessage(t *testing.T) {
+	_, database, conv, ws := setupTestSocket(t, RateLimits{MessagesPerIP: RateLimit{Requests: 1, Per: time.Minute}})
+
+	websocket.Message.Send(ws, `{"type":"send_message","id":"1","data":{"content":"Hello"}}`)
+	reply := receiveSocket(t, ws)
+	var sent SocketMessageSent
+	json.Unmarshal(reply.Data, &sent)
+	if reply.Type != socketMessageSent || reply.ID != "1" || sent.UserMessage.Content != "Hello" {
+		t.Fatalf("expected the saved message, got %s %s", reply.Type, reply.Data)
+	}
+	messages, _ := database.GetMessages(conv.ID)
+	if len(messages) != 1 || messages[0].ID != sent.UserMessage.ID {
+		t.Errorf("expected the message to be saved, got %+v", messages)
+	}
+
+	// Socket messages share the limits of POST /messages
+	websocket.Message.Send(ws, `{"type":"send_message","id":"2","data":{"content":"Again"}}`)
+	reply = receiveSocket(t, ws)
+	var failure SocketError
+	json.Unmarshal(reply.Data, &failure)
+	if reply.Type != socketError || reply.ID != "2" || failure.RetryAfterSeconds < 1 {
+		t.Errorf("expected the message to be rate limited, got %s %s", reply.Type, reply.Data)
+	}
+
+	websocket.Message.Send(ws, `{"type":"send_message","id":"3","data":{"content":""}}`)
+	if reply := receiveSocket(t, ws); reply.Type != socketError || !strings.Contains(string(reply.Data), "Content is required") {
+		t.Errorf("expected empty content to be refused, got %s %s", reply.Type, reply.Data)
+	}
+}
+
+func TestConversationSocket_InvalidID(t *testing.T) {
+	handler := NewConversationEventsHandler(NewEventBroadcaster())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/invalid/ws", nil)
+	req.SetPathValue("id", "invalid")
+	rr := httptest.NewRecorder()
+	handler.HandleWebSocket(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// SplitConversationResponse represents the conversation created by a split
+type SplitConversationResponse struct {
+	ConversationResponse
+	MovedMessages int `json:"moved_messages"`
+}
+
+// SplitConversation handles POST /api/conversations/{id}/split?at=message_id
+// Moves the message and every later message into a new conversation with the same avatars and settings,
+// for when a room drifts onto a topic of its own. Each avatar gets a fresh thread seeded with the moved
+// history, and the original conversation gets a system message pointing to the new one.
+// The title defaults to the original title with "(split)" appended and can be set with the title parameter
+func (h *ConversationHandler) SplitConversation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+	at, err := strconv.ParseInt(r.URL.Query().Get("at"), 10, 64)
+	if err != nil {
+		http.Error(w, "at must be a message ID", http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	msg, err := h.db.GetMessage(id, at)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get message", http.StatusInternalServerError)
+		return
+	}
+	if first, err := h.db.GetMessagesAround(id, msg.Sequence, 1, 0); err != nil {
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	} else if len(first) == 1 {
+		http.Error(w, "Cannot split at the first message", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(r.URL.Query().Get("title"))
+	if title == "" {
+		title = conv.Title + " (split)"
+	}
+
+	avatars, err := h.db.GetConversationAvatars(id)
+	if err != nil {
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+
+	split, err := h.db.CreateConversationWithSettings(title, "", conv.ResponseStyle, conv.RedactionPolicy,
+		conv.SystemInstructions, conv.MaxContextMessages)
+	if err != nil {
+		log.Printf("[API] SplitConversation failed: DB error creating conversation err=%v", err)
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+	// The split is billed like the original, and its threads are created in the same project
+	if conv.Language != "" || conv.DisplayLanguage != "" || conv.OpenAIOrganization != "" || conv.OpenAIProject != "" {
+		split.Language, split.DisplayLanguage = conv.Language, conv.DisplayLanguage
+		split.OpenAIOrganization, split.OpenAIProject = conv.OpenAIOrganization, conv.OpenAIProject
+		if translated, err := h.db.UpdateConversation(split); err != nil {
+			log.Printf("[API] Warning: failed to copy conversation settings conversation_id=%d err=%v", split.ID, err)
+		} else {
+			split = translated
+		}
+	}
+
+	moved, err := h.db.MoveMessagesFrom(id, msg.Sequence, split.ID)
+	if err != nil {
+		log.Printf("[API] SplitConversation failed: DB error moving messages conversation_id=%d err=%v", id, err)
+		h.db.DeleteConversation(split.ID)
+		http.Error(w, "Failed to move messages", http.StatusInternalServerError)
+		return
+	}
+
+	// Fresh threads start from the moved history; isolated avatars only get the user's messages
+	avatarNames := make(map[int64]string)
+	for _, a := range avatars {
+		avatarNames[a.ID] = a.Name
+	}
+	seed, userSeed := splitHistory(moved, avatarNames)
+
+	client := h.assistantFor(split)
+	var addedAvatarIDs []int64
+	for _, avatar := range avatars {
+		var threadID string
+		if h.assistant != nil {
+			threadID, err = createThreadWithRetry(client, split.ID, avatar.ID)
+			if err != nil {
+				// Add the avatar without a thread; it can be recreated via the recreate-thread endpoint
+				log.Printf("[API] Giving up on OpenAI thread for avatar conversation_id=%d avatar_id=%d err=%v", split.ID, avatar.ID, err)
+			} else if content := avatarSeed(avatar, seed, userSeed); content != "" {
+				if _, err := client.CreateMessage(threadID, content); err != nil {
+					log.Printf("[API] Warning: failed to seed avatar thread with split history thread_id=%s avatar_id=%d err=%v",
+						threadID, avatar.ID, err)
+				}
+			}
+		}
+
+		if err := h.db.AddAvatarToConversationWithThreadID(split.ID, avatar.ID, threadID); err != nil {
+			log.Printf("[API] Failed to add avatar to conversation conversation_id=%d avatar_id=%d err=%v", split.ID, avatar.ID, err)
+			continue
+		}
+		addedAvatarIDs = append(addedAvatarIDs, avatar.ID)
+	}
+
+	// Leave a pointer in the original conversation; it also lists the original in the new one's backlinks
+	notice, err := h.db.CreateMessage(id, models.SenderTypeSystem, nil,
+		fmt.Sprintf("%d messages moved to conversation #%d", len(moved), split.ID))
+	if err != nil {
+		log.Printf("[API] Warning: failed to save split notice conversation_id=%d err=%v", id, err)
+	} else {
+		if _, err := h.db.RecordConversationReferences(notice); err != nil {
+			log.Printf("[API] Warning: failed to record conversation references message_id=%d err=%v", notice.ID, err)
+		}
+		if h.broadcast != nil {
+			h.broadcast.BroadcastMessage(id, models.NewMessageEvent(notice))
+		}
+	}
+
+	// Watchers start after the moved messages so they are not answered again
+	if h.watcher != nil {
+		for _, avatarID := range addedAvatarIDs {
+			if err := h.watcher.StartWatcher(split.ID, avatarID); err != nil {
+				log.Printf("[API] Warning: Failed to start watcher conversation_id=%d avatar_id=%d err=%v", split.ID, avatarID, err)
+			}
+		}
+	}
+
+	log.Printf("[API] SplitConversation completed conversation_id=%d split_id=%d at=%d moved=%d avatar_count=%d",
+		id, split.ID, at, len(moved), len(addedAvatarIDs))
+	recordAudit(h.db, r, models.AuditActionConversationSplit, "conversation", strconv.FormatInt(id, 10), nil,
+		map[string]any{"conversation_id": split.ID, "message_id": at, "moved_messages": len(moved)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SplitConversationResponse{
+		ConversationResponse: newConversationResponse(split),
+		MovedMessages:        len(moved),
+	})
+}
+
+// splitHistory formats moved messages as the history seeded into avatar threads,
+// both in full and with the user's messages only
+func splitHistory(messages []models.Message, avatarNames map[int64]string) (string, string) {
+	var history, userHistory []logic.MessageForFormat
+	for _, msg := range messages {
+		switch msg.SenderType {
+		case models.SenderTypeUser:
+			history = append(history, logic.MessageForFormat{SenderType: logic.SenderTypeUserFormat, Content: msg.Content})
+			userHistory = append(userHistory, history[len(history)-1])
+		case models.SenderTypeAvatar:
+			name := unknownAssistantName
+			if msg.SenderID != nil && avatarNames[*msg.SenderID] != "" {
+				name = avatarNames[*msg.SenderID]
+			}
+			history = append(history, logic.MessageForFormat{SenderType: logic.SenderTypeAvatarFormat, SenderName: name, Content: msg.Content})
+		}
+	}
+	return logic.FormatImportedHistory(history, maxImportedHistoryLength),
+		logic.FormatImportedHistory(userHistory, maxImportedHistoryLength)
+}
+
+// avatarSeed picks the history an avatar's thread is seeded with
+func avatarSeed(avatar models.Avatar, seed, userSeed string) string {
+	if avatar.IsolatedContext {
+		return userSeed
+	}
+	return seed
+}
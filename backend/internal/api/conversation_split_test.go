@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestSplitConversation(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+	mockServer := testutil.NewMockAssistant(t)
+	handler.assistant = mockServer.Client()
+
+	conv, _ := handler.db.CreateConversationWithStyle("Planning", "", "brief")
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_1")
+	judge, _ := handler.db.CreateAvatar("Judge", "prompt", "asst_2")
+	handler.db.UpdateAvatarIsolatedContext(judge.ID, true)
+	handler.db.AddAvatarToConversation(conv.ID, alice.ID)
+	handler.db.AddAvatarToConversation(conv.ID, judge.ID)
+
+	first, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "When do we release?")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Friday")
+	at, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Also, who should we hire?")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "A designer")
+
+	split := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/conversations/%d/split?%s", conv.ID, query), nil)
+		req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+		w := httptest.NewRecorder()
+		handler.SplitConversation(w, req)
+		return w
+	}
+
+	for _, tt := range []struct {
+		query string
+		code  int
+	}{
+		{"", http.StatusBadRequest},
+		{"at=99999", http.StatusNotFound},
+		{fmt.Sprintf("at=%d", first.ID), http.StatusBadRequest},
+	} {
+		if w := split(tt.query); w.Code != tt.code {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.code, w.Code)
+		}
+	}
+
+	w := split(fmt.Sprintf("at=%d", at.ID))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var resp SplitConversationResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Title != "Planning (split)" || resp.ResponseStyle != "brief" || resp.MovedMessages != 2 {
+		t.Errorf("unexpected split conversation: %+v", resp)
+	}
+
+	moved, _ := handler.db.GetMessages(resp.ID)
+	if len(moved) != 2 || moved[0].ID != at.ID || moved[0].Sequence != 1 {
+		t.Errorf("unexpected messages in the split conversation: %+v", moved)
+	}
+	remaining, _ := handler.db.GetMessages(conv.ID)
+	if len(remaining) != 3 || remaining[2].SenderType != models.SenderTypeSystem ||
+		remaining[2].Content != fmt.Sprintf("2 messages moved to conversation #%d", resp.ID) {
+		t.Errorf("expected the first two messages and a notice to remain, got %+v", remaining)
+	}
+	if links, _ := handler.db.GetConversationBacklinks(resp.ID); len(links) != 1 || links[0].SourceConversationID != conv.ID {
+		t.Errorf("expected a backlink from the original conversation, got %+v", links)
+	}
+
+	// Every avatar joins with a fresh thread seeded with the moved history
+	avatars, threadIDs, _ := handler.db.GetConversationAvatarsWithThreads(resp.ID)
+	if len(avatars) != 2 {
+		t.Fatalf("expected both avatars in the split conversation, got %d", len(avatars))
+	}
+	for i, avatar := range avatars {
+		messages, err := handler.assistant.ListAllMessages(threadIDs[i])
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("expected one seed message in %s's thread, got %d (%v)", avatar.Name, len(messages), err)
+		}
+		seed := messages[0].Text()
+		if !strings.Contains(seed, "Also, who should we hire?") || strings.Contains(seed, "When do we release?") {
+			t.Errorf("unexpected seed for %s: %q", avatar.Name, seed)
+		}
+		if hasAvatarMessage := strings.Contains(seed, "A designer"); hasAvatarMessage == avatar.IsolatedContext {
+			t.Errorf("expected only isolated avatars to miss the avatar messages, got %q for %s", seed, avatar.Name)
+		}
+	}
+
+	entries, _ := handler.db.GetAuditEntries(models.AuditFilter{Action: models.AuditActionConversationSplit, Limit: 10})
+	if len(entries) != 1 || entries[0].TargetID != strconv.FormatInt(conv.ID, 10) {
+		t.Errorf("expected the split to be audited, got %+v", entries)
+	}
+}
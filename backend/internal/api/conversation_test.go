@@ -3,46 +3,36 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
-
-	"multi-avatar-chat/internal/db"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/offline"
+	"multi-avatar-chat/internal/testutil"
+	"multi-avatar-chat/internal/watcher"
 )
 
-func setupTestConversationHandler(t *testing.T) (*ConversationHandler, *AvatarHandler, func()) {
+func setupTestConversationHandler(t *testing.T) (*ConversationHandler, *AvatarHandler) {
 	t.Helper()
 
-	tmpFile, err := os.CreateTemp("", "test_conv_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	database, err := db.NewDB(tmpFile.Name())
-	if err != nil {
-		t.Fatalf("failed to create database: %v", err)
-	}
-
-	if err := database.Migrate(); err != nil {
-		t.Fatalf("migration failed: %v", err)
-	}
+	database := testutil.NewTestDB(t)
 
 	convHandler := NewConversationHandler(database, nil)
 	avatarHandler := NewAvatarHandler(database, nil)
 
-	cleanup := func() {
-		database.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return convHandler, avatarHandler, cleanup
+	return convHandler, avatarHandler
 }
 
 func TestCreateConversation_Success(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	body := `{"title": "Test Chat"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
@@ -69,8 +59,7 @@ func TestCreateConversation_Success(t *testing.T) {
 }
 
 func TestCreateConversation_MissingTitle(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	body := `{}`
 	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
@@ -85,8 +74,7 @@ func TestCreateConversation_MissingTitle(t *testing.T) {
 }
 
 func TestListConversations_Empty(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/conversations", nil)
 	w := httptest.NewRecorder()
@@ -108,8 +96,7 @@ func TestListConversations_Empty(t *testing.T) {
 }
 
 func TestListConversations_WithData(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	// Create test conversations
 	createBody := `{"title": "Chat 1"}`
@@ -144,8 +131,7 @@ func TestListConversations_WithData(t *testing.T) {
 }
 
 func TestGetConversation_Success(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	// Create test conversation
 	createBody := `{"title": "Get Test"}`
@@ -175,8 +161,7 @@ func TestGetConversation_Success(t *testing.T) {
 }
 
 func TestGetConversation_NotFound(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/conversations/99999", nil)
 	req.SetPathValue("id", "99999")
@@ -189,9 +174,40 @@ func TestGetConversation_NotFound(t *testing.T) {
 	}
 }
 
+func TestConversation_ConditionalUpdate(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Chat", "")
+	id := strconv.FormatInt(conv.ID, 10)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/"+id, nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.Get(w, req)
+	etag := w.Header().Get("ETag")
+
+	update := func(title string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/conversations/"+id, bytes.NewBufferString(`{"title": "`+title+`"}`))
+		req.SetPathValue("id", id)
+		req.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		return w.Code
+	}
+
+	if code := update("First"); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := update("Second"); code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale If-Match, got %d", code)
+	}
+	if got, _ := handler.db.GetConversation(conv.ID); got.Title != "First" {
+		t.Errorf("expected the first edit to be kept, got %q", got.Title)
+	}
+}
+
 func TestDeleteConversation_Success(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	// Create test conversation
 	createBody := `{"title": "ToDelete"}`
@@ -221,9 +237,56 @@ func TestDeleteConversation_Success(t *testing.T) {
 	}
 }
 
+func TestDeleteConversation_DeletesAvatarThreads(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		mu.Lock()
+		deleted = append(deleted, id)
+		mu.Unlock()
+		if id == "thread_bob" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "failed"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "` + id + `", "deleted": true}`))
+	}))
+	defer server.Close()
+	handler.assistant = assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+
+	conv, _ := handler.db.CreateConversation("Threads", "thread_legacy")
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := handler.db.CreateAvatar("Bob", "prompt", "asst_2")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, alice.ID, "thread_alice")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, bob.ID, "thread_bob")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/conversations/"+strconv.FormatInt(conv.ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.Delete(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if len(deleted) != 3 {
+		t.Errorf("expected the legacy and both avatar threads to be deleted, got %v", deleted)
+	}
+
+	// The thread that failed to delete is left for the thread collector
+	pending, _ := handler.db.GetPendingThreadDeletions(0)
+	if len(pending) != 1 || pending[0].ThreadID != "thread_bob" || pending[0].ConversationID != conv.ID {
+		t.Errorf("expected thread_bob to be pending deletion, got %+v", pending)
+	}
+}
+
 func TestSendMessage_Success(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	// Create test conversation
 	createBody := `{"title": "Message Test"}`
@@ -257,9 +320,170 @@ func TestSendMessage_Success(t *testing.T) {
 	}
 }
 
+func TestSendMessage_QueuesWhileOffline(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Offline", "")
+	avatar, _ := handler.db.CreateAvatar("Bot", "prompt", "asst_1")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	// Messages are queued while the circuit is open
+	client := assistant.NewClient("test-key", assistant.WithCircuitBreaker(1, time.Minute))
+	client.CircuitBreaker().RecordFailure()
+	handler.SetOfflineQueue(offline.NewQueue(handler.db, client))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages", bytes.NewBufferString(`{"content": "Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.SendMessage(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	forwards, err := handler.db.GetOfflineForwards()
+	if err != nil {
+		t.Fatalf("failed to get offline forwards: %v", err)
+	}
+	if len(forwards) != 1 {
+		t.Fatalf("expected 1 queued forward, got %d", len(forwards))
+	}
+	if forwards[0].ThreadID != "thread_1" || forwards[0].AvatarID != avatar.ID {
+		t.Errorf("unexpected forward: %+v", forwards[0])
+	}
+
+	var response SendMessageResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.Deliveries) != 1 || response.Deliveries[0].Status != DeliveryStatusQueued {
+		t.Errorf("expected a queued delivery, got %+v", response.Deliveries)
+	}
+}
+
+func TestSendMessage_Silent(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Silent", "")
+	avatar, _ := handler.db.CreateAvatar("Bot", "prompt", "asst_1")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	handler.SetOfflineQueue(offline.NewQueue(handler.db, nil))
+	manager := watcher.NewManager(handler.db, nil, time.Second)
+	handler.SetWatcherManager(manager)
+	broadcaster := NewEventBroadcaster()
+	handler.SetBroadcaster(broadcaster)
+	events := broadcaster.Subscribe(conv.ID)
+	defer broadcaster.Unsubscribe(conv.ID, events)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages",
+		bytes.NewBufferString(`{"content": "Backfilled note", "silent": true}`))
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.SendMessage(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var response SendMessageResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.UserMessage.Content != "Backfilled note" || len(response.Deliveries) != 0 {
+		t.Errorf("expected a stored message without deliveries, got %+v", response)
+	}
+
+	// The message is neither forwarded nor answered
+	forwards, _ := handler.db.GetOfflineForwards()
+	if len(forwards) != 0 {
+		t.Errorf("expected no queued forwards, got %d", len(forwards))
+	}
+	if stored, _ := handler.db.GetMessage(conv.ID, response.UserMessage.ID); !response.UserMessage.Silent || stored == nil || !stored.Silent {
+		t.Errorf("expected the message to be stored as silent, got %+v", stored)
+	}
+
+	select {
+	case event := <-events:
+		data, _ := event.Data.(models.MessageEvent)
+		if event.Type != models.EventTypeMessage || data.Content != "Backfilled note" || !data.Silent {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	default:
+		t.Error("expected a message event")
+	}
+}
+
+func TestSendMessage_ForwardsConcurrently(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	const delay = 200 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs"):
+			w.Write([]byte(`{"data": []}`))
+		case r.URL.Path == "/threads/thread_broken/messages":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "bad request"}}`))
+		default:
+			time.Sleep(delay)
+			w.Write([]byte(`{"id": "msg_1", "role": "user"}`))
+		}
+	}))
+	defer server.Close()
+	handler.assistant = assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+	handler.SetForwardConcurrency(5)
+
+	conv, _ := handler.db.CreateConversation("Fan-out", "")
+	for i := 0; i < 5; i++ {
+		avatar, _ := handler.db.CreateAvatar(fmt.Sprintf("Bot%d", i), "prompt", "asst")
+		handler.db.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, fmt.Sprintf("thread_%d", i))
+	}
+	broken, _ := handler.db.CreateAvatar("Broken", "prompt", "asst")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, broken.ID, "thread_broken")
+	threadless, _ := handler.db.CreateAvatar("Threadless", "prompt", "asst")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, threadless.ID, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages", bytes.NewBufferString(`{"content": "Hello"}`))
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handler.SendMessage(w, req)
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if elapsed >= 3*delay {
+		t.Errorf("expected threads to be written concurrently, took %v", elapsed)
+	}
+
+	var response SendMessageResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.Deliveries) != 7 {
+		t.Fatalf("expected 7 deliveries, got %+v", response.Deliveries)
+	}
+	statuses := make(map[int64]DeliveryResponse)
+	for _, d := range response.Deliveries {
+		statuses[d.AvatarID] = d
+	}
+	if d := statuses[broken.ID]; d.Status != DeliveryStatusFailed || d.Error == "" {
+		t.Errorf("expected a failed delivery with an error, got %+v", d)
+	}
+	if d := statuses[threadless.ID]; d.Status != DeliveryStatusSkipped {
+		t.Errorf("expected a skipped delivery, got %+v", d)
+	}
+	delivered := 0
+	for _, d := range response.Deliveries {
+		if d.Status == DeliveryStatusDelivered {
+			delivered++
+		}
+	}
+	if delivered != 5 {
+		t.Errorf("expected 5 delivered, got %d", delivered)
+	}
+}
+
 func TestSendMessage_ConversationNotFound(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	msgBody := `{"content": "Hello"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/conversations/99999/messages", bytes.NewBufferString(msgBody))
@@ -275,8 +499,7 @@ func TestSendMessage_ConversationNotFound(t *testing.T) {
 }
 
 func TestGetMessages_Empty(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	// Create test conversation
 	createBody := `{"title": "Empty Messages"}`
@@ -306,8 +529,7 @@ func TestGetMessages_Empty(t *testing.T) {
 }
 
 func TestGetMessages_WithData(t *testing.T) {
-	handler, _, cleanup := setupTestConversationHandler(t)
-	defer cleanup()
+	handler, _ := setupTestConversationHandler(t)
 
 	// Create test conversation
 	createBody := `{"title": "Messages Test"}`
@@ -351,3 +573,367 @@ func TestGetMessages_WithData(t *testing.T) {
 	}
 }
 
+
+func TestCreateConversation_WithResponseStyle(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	body := `{"title": "Brief Chat", "response_style": "brief"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var response ConversationResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.ResponseStyle != "brief" {
+		t.Errorf("expected response_style 'brief', got '%s'", response.ResponseStyle)
+	}
+}
+
+func TestCreateConversation_InvalidResponseStyle(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	body := `{"title": "Chat", "response_style": "essay"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUpdateConversation_ResponseStyle(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	createBody := `{"title": "Update Test"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(createBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/conversations/1", bytes.NewBufferString(`{"response_style": "detailed"}`))
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response ConversationResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.ResponseStyle != "detailed" {
+		t.Errorf("expected response_style 'detailed', got '%s'", response.ResponseStyle)
+	}
+	if response.Title != "Update Test" {
+		t.Errorf("expected title to be unchanged, got '%s'", response.Title)
+	}
+}
+
+func TestConversation_SystemInstructions(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	body := `{"title": "Formal Room", "system_instructions": "  Speak in formal English.  "}`
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var created ConversationResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.SystemInstructions != "Speak in formal English." {
+		t.Errorf("expected trimmed system_instructions, got %q", created.SystemInstructions)
+	}
+
+	id := strconv.FormatInt(created.ID, 10)
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/conversations/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		return w
+	}
+
+	// Other updates keep the instructions
+	w = update(`{"title": "Renamed"}`)
+	var updated ConversationResponse
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.SystemInstructions != "Speak in formal English." {
+		t.Errorf("expected system_instructions to be kept, got %q", updated.SystemInstructions)
+	}
+
+	tooLong, _ := json.Marshal(map[string]string{"system_instructions": strings.Repeat("a", logic.MaxSystemInstructionsLength+1)})
+	if w := update(string(tooLong)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for too long instructions, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = update(`{"system_instructions": ""}`)
+	updated = ConversationResponse{}
+	json.NewDecoder(w.Body).Decode(&updated)
+	if w.Code != http.StatusOK || updated.SystemInstructions != "" {
+		t.Errorf("expected the instructions to be removed, got %d %q", w.Code, updated.SystemInstructions)
+	}
+}
+
+func TestConversation_MaxContextMessages(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations",
+		bytes.NewBufferString(`{"title": "Long Room", "max_context_messages": 30}`))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	var created ConversationResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if w.Code != http.StatusCreated || created.MaxContextMessages != 30 {
+		t.Fatalf("expected max_context_messages 30, got %d %+v", w.Code, created)
+	}
+
+	id := strconv.FormatInt(created.ID, 10)
+	update := func(body string) (int, ConversationResponse) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/conversations/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		var updated ConversationResponse
+		json.NewDecoder(w.Body).Decode(&updated)
+		return w.Code, updated
+	}
+
+	if code, updated := update(`{"title": "Renamed"}`); code != http.StatusOK || updated.MaxContextMessages != 30 {
+		t.Errorf("expected the limit to be kept, got %d %d", code, updated.MaxContextMessages)
+	}
+	if code, _ := update(`{"max_context_messages": -1}`); code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a negative limit, got %d", http.StatusBadRequest, code)
+	}
+	if code, updated := update(`{"max_context_messages": 0}`); code != http.StatusOK || updated.MaxContextMessages != 0 {
+		t.Errorf("expected the limit to be removed, got %d %d", code, updated.MaxContextMessages)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/conversations",
+		bytes.NewBufferString(`{"title": "Bad", "max_context_messages": -5}`))
+	w = httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a negative limit, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestConversation_TTL(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+	handler.SetDefaultTTL(24 * time.Hour)
+
+	create := func(body string) (int, ConversationResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+		var created ConversationResponse
+		json.NewDecoder(w.Body).Decode(&created)
+		return w.Code, created
+	}
+	expiresWithin := func(c ConversationResponse, ttl time.Duration) bool {
+		if c.ExpiresAt == nil {
+			return false
+		}
+		expires, err := time.Parse(time.RFC3339, *c.ExpiresAt)
+		return err == nil && time.Until(expires) > ttl-time.Minute && time.Until(expires) <= ttl
+	}
+
+	// Conversations created without a TTL get the server default
+	code, demo := create(`{"title": "Demo"}`)
+	if code != http.StatusCreated || !expiresWithin(demo, 24*time.Hour) {
+		t.Fatalf("expected the default TTL, got %d %+v", code, demo)
+	}
+	if code, short := create(`{"title": "Short", "ttl": "1h"}`); code != http.StatusCreated || !expiresWithin(short, time.Hour) {
+		t.Errorf("expected a 1h TTL, got %d %+v", code, short)
+	}
+	if code, kept := create(`{"title": "Kept", "ttl": "0"}`); code != http.StatusCreated || kept.ExpiresAt != nil {
+		t.Errorf("expected ttl 0 to override the default, got %d %+v", code, kept)
+	}
+	for _, ttl := range []string{"tomorrow", "-1h"} {
+		if code, _ := create(`{"title": "Bad", "ttl": "` + ttl + `"}`); code != http.StatusBadRequest {
+			t.Errorf("expected status %d for ttl %q, got %d", http.StatusBadRequest, ttl, code)
+		}
+	}
+
+	id := strconv.FormatInt(demo.ID, 10)
+	update := func(body string) (int, ConversationResponse) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/conversations/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		var updated ConversationResponse
+		json.NewDecoder(w.Body).Decode(&updated)
+		return w.Code, updated
+	}
+
+	if code, updated := update(`{"title": "Renamed"}`); code != http.StatusOK || !expiresWithin(updated, 24*time.Hour) {
+		t.Errorf("expected the expiry to be kept, got %d %+v", code, updated)
+	}
+	if code, updated := update(`{"ttl": "30m"}`); code != http.StatusOK || !expiresWithin(updated, 30*time.Minute) {
+		t.Errorf("expected the expiry to be moved, got %d %+v", code, updated)
+	}
+	if code, _ := update(`{"ttl": "soon"}`); code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid ttl, got %d", http.StatusBadRequest, code)
+	}
+	if code, updated := update(`{"ttl": "0"}`); code != http.StatusOK || updated.ExpiresAt != nil {
+		t.Errorf("expected the expiry to be removed, got %d %+v", code, updated)
+	}
+}
+
+func TestUpdateConversation_NotFound(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/conversations/99999", bytes.NewBufferString(`{"response_style": "brief"}`))
+	req.SetPathValue("id", "99999")
+	w := httptest.NewRecorder()
+
+	handler.Update(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetBacklinks(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	target, _ := handler.db.CreateConversation("Planning", "")
+	source, _ := handler.db.CreateConversation("Follow-up", "")
+
+	msgBody := fmt.Sprintf(`{"content": "see conversation #%d for details"}`, target.ID)
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/2/messages", bytes.NewBufferString(msgBody))
+	req.SetPathValue("id", fmt.Sprint(source.ID))
+	w := httptest.NewRecorder()
+	handler.SendMessage(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/conversations/1/backlinks", nil)
+	req.SetPathValue("id", fmt.Sprint(target.ID))
+	w = httptest.NewRecorder()
+	handler.GetBacklinks(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var backlinks []BacklinkResponse
+	if err := json.NewDecoder(w.Body).Decode(&backlinks); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(backlinks) != 1 {
+		t.Fatalf("expected 1 backlink, got %d", len(backlinks))
+	}
+	if backlinks[0].ConversationID != source.ID || backlinks[0].Title != "Follow-up" {
+		t.Errorf("unexpected backlink: %+v", backlinks[0])
+	}
+}
+
+func TestGetBacklinks_NotFound(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/999/backlinks", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	handler.GetBacklinks(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCreateConversation_WithInitialMessage(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	body := `{"title": "Demo", "initial_message": "Hello everyone"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var response CreateConversationResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.InitialMessage == nil {
+		t.Fatal("expected initial_message in response")
+	}
+	if response.InitialMessage.Content != "Hello everyone" || response.InitialMessage.SenderType != "user" {
+		t.Errorf("unexpected initial message: %+v", response.InitialMessage)
+	}
+
+	messages, err := handler.db.GetMessages(response.ID)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != response.InitialMessage.ID {
+		t.Errorf("expected the initial message to be persisted, got %+v", messages)
+	}
+}
+
+func TestCreateConversation_ValidatesAvatarIDs(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	alice, _ := handler.db.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := handler.db.CreateAvatar("Bob", "Prompt", "asst_2")
+	carol, _ := handler.db.CreateAvatar("Carol", "Prompt", "asst_3")
+
+	create := func(avatarIDs ...int64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(CreateConversationRequest{Title: "Test Chat", AvatarIDs: avatarIDs})
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+		return w
+	}
+
+	w := create(alice.ID, 998, 999)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for unknown avatars, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "998, 999") {
+		t.Errorf("expected unknown IDs in error, got %q", w.Body.String())
+	}
+	if convs, _ := handler.db.GetAllConversations(); len(convs) != 0 {
+		t.Errorf("expected no conversation to be created, got %d", len(convs))
+	}
+
+	handler.SetMaxAvatars(2)
+	if w := create(alice.ID, bob.ID, carol.ID); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d over the limit, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Duplicates do not count towards the limit
+	w = create(alice.ID, bob.ID, alice.ID)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var response ConversationResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if avatars, _ := handler.db.GetConversationAvatars(response.ID); len(avatars) != 2 {
+		t.Errorf("expected 2 avatars, got %d", len(avatars))
+	}
+}
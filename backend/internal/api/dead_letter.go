@@ -0,0 +1,186 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/watcher"
+)
+
+// DeadLetterHandler handles HTTP requests for avatar responses that failed after retries
+type DeadLetterHandler struct {
+	db      *db.DB
+	watcher *watcher.WatcherManager
+}
+
+// NewDeadLetterHandler creates a new dead letter handler
+func NewDeadLetterHandler(database *db.DB, wm *watcher.WatcherManager) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		db:      database,
+		watcher: wm,
+	}
+}
+
+// DeadLetterResponse represents a failed avatar response
+// Context is the snapshot of the thread, assistant and run instructions at the last failure
+type DeadLetterResponse struct {
+	ID             int64           `json:"id"`
+	ConversationID int64           `json:"conversation_id"`
+	AvatarID       int64           `json:"avatar_id"`
+	MessageID      int64           `json:"message_id"`
+	Error          string          `json:"error"`
+	Attempts       int             `json:"attempts"`
+	Context        json.RawMessage `json:"context"`
+	Status         string          `json:"status"`
+	CreatedAt      string          `json:"created_at"`
+	UpdatedAt      string          `json:"updated_at"`
+	ResolvedAt     *string         `json:"resolved_at,omitempty"`
+}
+
+// newDeadLetterResponse converts a dead letter to its API representation
+func newDeadLetterResponse(dl *models.DeadLetter) DeadLetterResponse {
+	resp := DeadLetterResponse{
+		ID:             dl.ID,
+		ConversationID: dl.ConversationID,
+		AvatarID:       dl.AvatarID,
+		MessageID:      dl.MessageID,
+		Error:          dl.Error,
+		Attempts:       dl.Attempts,
+		Context:        json.RawMessage(dl.Context),
+		Status:         dl.Status,
+		CreatedAt:      dl.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      dl.UpdatedAt.Format(time.RFC3339),
+	}
+	if !json.Valid(resp.Context) {
+		resp.Context = json.RawMessage("{}")
+	}
+	if dl.ResolvedAt != nil {
+		resolvedAt := dl.ResolvedAt.Format(time.RFC3339)
+		resp.ResolvedAt = &resolvedAt
+	}
+	return resp
+}
+
+// List handles GET /api/admin/dead-letters
+// Filters: status (open, retrying or resolved), conversation_id
+func (h *DeadLetterHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	status := query.Get("status")
+	switch status {
+	case "", models.DeadLetterStatusOpen, models.DeadLetterStatusRetrying, models.DeadLetterStatusResolved:
+	default:
+		http.Error(w, "Invalid status (must be open, retrying or resolved)", http.StatusBadRequest)
+		return
+	}
+
+	var conversationID int64
+	if v := query.Get("conversation_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid conversation_id", http.StatusBadRequest)
+			return
+		}
+		conversationID = id
+	}
+
+	deadLetters, err := h.db.GetDeadLetters(status, conversationID)
+	if err != nil {
+		log.Printf("[API] ListDeadLetters failed: DB error err=%v", err)
+		http.Error(w, "Failed to get dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]DeadLetterResponse, len(deadLetters))
+	for i := range deadLetters {
+		response[i] = newDeadLetterResponse(&deadLetters[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Get handles GET /api/admin/dead-letters/{id}
+func (h *DeadLetterHandler) Get(w http.ResponseWriter, r *http.Request) {
+	dl, ok := h.getDeadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDeadLetterResponse(dl))
+}
+
+// Retry handles POST /api/admin/dead-letters/{id}/retry
+// The avatar responds to the trigger message again in the background, skipping the judgment;
+// poll the dead letter until its status changes from retrying to resolved or open
+func (h *DeadLetterHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	dl, ok := h.getDeadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	if h.watcher == nil {
+		http.Error(w, "Avatar watchers are not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	started, err := h.db.StartDeadLetterRetry(dl.ID)
+	if err != nil {
+		http.Error(w, "Failed to retry dead letter", http.StatusInternalServerError)
+		return
+	}
+	if !started {
+		http.Error(w, "Dead letter is not open", http.StatusConflict)
+		return
+	}
+
+	if err := h.watcher.RetryDeadLetter(*dl); err != nil {
+		h.db.ReopenDeadLetter(dl.ID)
+		switch err {
+		case watcher.ErrNoWatcher:
+			http.Error(w, "Avatar is no longer in the conversation", http.StatusConflict)
+		case watcher.ErrRetryQueueFull:
+			http.Error(w, "Too many retries waiting for the avatar", http.StatusTooManyRequests)
+		default:
+			http.Error(w, "Failed to retry dead letter", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("[API] Dead letter retry accepted dead_letter_id=%d conversation_id=%d avatar_id=%d",
+		dl.ID, dl.ConversationID, dl.AvatarID)
+	recordAudit(h.db, r, models.AuditActionDeadLetterRetry, "dead_letter", strconv.FormatInt(dl.ID, 10),
+		map[string]string{"status": dl.Status}, map[string]string{"status": models.DeadLetterStatusRetrying})
+
+	dl.Status = models.DeadLetterStatusRetrying
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newDeadLetterResponse(dl))
+}
+
+// getDeadLetter loads the dead letter named by the path, writing an error response if it cannot
+func (h *DeadLetterHandler) getDeadLetter(w http.ResponseWriter, r *http.Request) (*models.DeadLetter, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dead letter ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	dl, err := h.db.GetDeadLetter(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get dead letter", http.StatusInternalServerError)
+		return nil, false
+	}
+	return dl, true
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+	"multi-avatar-chat/internal/watcher"
+)
+
+func setupTestDeadLetterHandler(t *testing.T) (*DeadLetterHandler, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	manager := watcher.NewManager(database, nil, time.Second)
+	handler := NewDeadLetterHandler(database, manager)
+
+	t.Cleanup(func() { manager.Shutdown() })
+
+	return handler, database
+}
+
+// createTestDeadLetter creates a dead letter with its conversation, avatar and trigger message
+func createTestDeadLetter(t *testing.T, database *db.DB) *models.DeadLetter {
+	t.Helper()
+
+	conv, _ := database.CreateConversation("Failures", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	dl, err := database.CreateDeadLetter(conv.ID, avatar.ID, msg.ID, "run failed", 3, `{"thread_id":"thread_1"}`)
+	if err != nil {
+		t.Fatalf("failed to create dead letter: %v", err)
+	}
+	return dl
+}
+
+func TestDeadLetterHandler_ListAndGet(t *testing.T) {
+	handler, database := setupTestDeadLetterHandler(t)
+
+	dl := createTestDeadLetter(t, database)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/dead-letters?status=open", nil)
+	w := httptest.NewRecorder()
+	handler.List(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var list []DeadLetterResponse
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].ID != dl.ID || list[0].Attempts != 3 {
+		t.Fatalf("unexpected dead letters: %+v", list)
+	}
+	if string(list[0].Context) != `{"thread_id":"thread_1"}` {
+		t.Errorf("expected the context snapshot as JSON, got %s", list[0].Context)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/dead-letters?status=resolved", nil)
+	w = httptest.NewRecorder()
+	handler.List(w, req)
+	list = nil
+	json.NewDecoder(w.Body).Decode(&list)
+	if list == nil || len(list) != 0 {
+		t.Errorf("expected an empty list, got %+v", list)
+	}
+
+	for _, query := range []string{"status=failed", "conversation_id=abc"} {
+		req = httptest.NewRequest(http.MethodGet, "/api/admin/dead-letters?"+query, nil)
+		w = httptest.NewRecorder()
+		handler.List(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/dead-letters/999", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDeadLetterHandler_Retry(t *testing.T) {
+	handler, database := setupTestDeadLetterHandler(t)
+
+	dl := createTestDeadLetter(t, database)
+	id := strconv.FormatInt(dl.ID, 10)
+
+	// No watcher is running for the avatar, so the retry is refused and the dead letter stays open
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/dead-letters/"+id+"/retry", nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.Retry(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if got, _ := database.GetDeadLetter(dl.ID); got.Status != models.DeadLetterStatusOpen {
+		t.Errorf("expected the dead letter to stay open, got %s", got.Status)
+	}
+
+	// A dead letter already being retried cannot be retried again
+	database.StartDeadLetterRetry(dl.ID)
+	w = httptest.NewRecorder()
+	handler.Retry(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// SetDefaultAvatarsRequest is the request body of PUT /api/admin/default-avatars
+type SetDefaultAvatarsRequest struct {
+	AvatarIDs []int64 `json:"avatar_ids"`
+}
+
+// DefaultAvatarsResponse lists the avatars added to conversations created without avatar_ids, in order
+type DefaultAvatarsResponse struct {
+	Avatars []AvatarResponse `json:"avatars"`
+}
+
+// GetDefaultAvatars handles GET /api/admin/default-avatars
+func (h *ConversationHandler) GetDefaultAvatars(w http.ResponseWriter, r *http.Request) {
+	avatars, err := h.db.GetDefaultAvatars()
+	if err != nil {
+		log.Printf("[API] GetDefaultAvatars failed: DB error err=%v", err)
+		http.Error(w, "Failed to get default avatars", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDefaultAvatarsResponse(avatars))
+}
+
+// SetDefaultAvatars handles PUT /api/admin/default-avatars
+// The avatars are checked like the avatar_ids of a new conversation; an empty list clears the roster
+func (h *ConversationHandler) SetDefaultAvatars(w http.ResponseWriter, r *http.Request) {
+	var req SetDefaultAvatarsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	avatarIDs, err := h.checkAvatarIDs(req.AvatarIDs)
+	if err != nil {
+		log.Printf("[API] SetDefaultAvatars failed: invalid avatar_ids=%v err=%v", req.AvatarIDs, err)
+		writeStatusError(w, err)
+		return
+	}
+
+	before, err := h.defaultAvatarIDs()
+	if err != nil {
+		http.Error(w, "Failed to get default avatars", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.SetDefaultAvatars(avatarIDs); err != nil {
+		log.Printf("[API] SetDefaultAvatars failed: DB error err=%v", err)
+		http.Error(w, "Failed to set default avatars", http.StatusInternalServerError)
+		return
+	}
+
+	avatars, err := h.db.GetDefaultAvatars()
+	if err != nil {
+		http.Error(w, "Failed to get default avatars", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Default avatars set avatar_ids=%v", avatarIDs)
+	recordAudit(h.db, r, models.AuditActionDefaultAvatarsUpdate, "default_avatars", "",
+		map[string]any{"avatar_ids": before}, map[string]any{"avatar_ids": avatarIDs})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDefaultAvatarsResponse(avatars))
+}
+
+// defaultAvatarIDs returns the IDs of the default avatars, in order
+func (h *ConversationHandler) defaultAvatarIDs() ([]int64, error) {
+	avatars, err := h.db.GetDefaultAvatars()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(avatars))
+	for i, avatar := range avatars {
+		ids[i] = avatar.ID
+	}
+	return ids, nil
+}
+
+func newDefaultAvatarsResponse(avatars []models.Avatar) DefaultAvatarsResponse {
+	resp := DefaultAvatarsResponse{Avatars: make([]AvatarResponse, len(avatars))}
+	for i := range avatars {
+		resp.Avatars[i] = newAvatarResponse(&avatars[i])
+	}
+	return resp
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestDefaultAvatars_AttachedToNewConversations(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := handler.db.CreateAvatar("Bob", "prompt", "asst_2")
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/default-avatars", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.SetDefaultAvatars(w, req)
+		return w
+	}
+	if w := put(`{"avatar_ids": [999]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown avatar, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w := put(`{"avatar_ids": [` + strconv.FormatInt(bob.ID, 10) + `, ` + strconv.FormatInt(alice.ID, 10) + `, ` + strconv.FormatInt(bob.ID, 10) + `]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var roster DefaultAvatarsResponse
+	json.NewDecoder(w.Body).Decode(&roster)
+	if len(roster.Avatars) != 2 || roster.Avatars[0].Name != "Bob" || roster.Avatars[1].Name != "Alice" {
+		t.Fatalf("expected Bob then Alice, got %+v", roster.Avatars)
+	}
+
+	create := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var conv ConversationResponse
+		json.NewDecoder(w.Body).Decode(&conv)
+		avatars, _ := handler.db.GetConversationAvatars(conv.ID)
+		return len(avatars)
+	}
+
+	if n := create(`{"title": "Roster"}`); n != 2 {
+		t.Errorf("expected the default avatars to join, got %d avatars", n)
+	}
+	if n := create(`{"title": "Alone", "avatar_ids": []}`); n != 0 {
+		t.Errorf("expected an empty avatar_ids to opt out, got %d avatars", n)
+	}
+	if n := create(`{"title": "Chosen", "avatar_ids": [` + strconv.FormatInt(alice.ID, 10) + `]}`); n != 1 {
+		t.Errorf("expected only the chosen avatar, got %d avatars", n)
+	}
+
+	if w := put(`{"avatar_ids": []}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/default-avatars", nil)
+	w = httptest.NewRecorder()
+	handler.GetDefaultAvatars(w, req)
+	json.NewDecoder(w.Body).Decode(&roster)
+	if len(roster.Avatars) != 0 {
+		t.Errorf("expected the roster to be cleared, got %+v", roster.Avatars)
+	}
+}
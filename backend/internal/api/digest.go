@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/jobs"
+)
+
+// DigestHandler handles conversation digest HTTP requests
+type DigestHandler struct {
+	db   *db.DB
+	job  *digest.Job
+	jobs *jobs.Runner
+}
+
+// NewDigestHandler creates a new digest handler
+func NewDigestHandler(database *db.DB) *DigestHandler {
+	return &DigestHandler{
+		db: database,
+	}
+}
+
+// SetJob sets the digest job used to generate digests
+func (h *DigestHandler) SetJob(job *digest.Job) {
+	h.job = job
+}
+
+// SetJobRunner generates on-demand digests as background jobs
+func (h *DigestHandler) SetJobRunner(runner *jobs.Runner) {
+	h.jobs = runner
+	runner.Register(JobTypeDigest, h.runDigestJob)
+}
+
+// DigestJobPayload identifies the conversation summarized by a digest job
+type DigestJobPayload struct {
+	ConversationID int64 `json:"conversation_id"`
+}
+
+// DigestResponse represents a generated digest in API responses
+type DigestResponse struct {
+	ID             int64  `json:"id"`
+	ConversationID int64  `json:"conversation_id"`
+	MessageID      int64  `json:"message_id"`
+	MessageCount   int    `json:"message_count"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// Generate handles POST /api/conversations/{id}/digest
+// Generates a digest immediately instead of waiting for the scheduled run.
+// With a job runner configured the digest is generated by a job and 202 Accepted is returned;
+// the job result is null when there were no new messages
+func (h *DigestHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] GenerateDigest started")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.job == nil {
+		log.Printf("[API] GenerateDigest failed: digest job not configured")
+		http.Error(w, "Digests are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	conv, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	// Summarizing waits on the LLM; run it as a job when a runner is configured
+	if h.jobs != nil {
+		job, err := h.jobs.Enqueue(JobTypeDigest, DigestJobPayload{ConversationID: conv.ID})
+		if err != nil {
+			log.Printf("[API] GenerateDigest failed: enqueue job conversation_id=%d err=%v", id, err)
+			http.Error(w, "Failed to start digest", http.StatusInternalServerError)
+			return
+		}
+		writeJobAccepted(w, job)
+		return
+	}
+
+	d, err := h.job.GenerateForConversation(conv)
+	if err != nil {
+		log.Printf("[API] GenerateDigest failed: conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to generate digest", http.StatusInternalServerError)
+		return
+	}
+	if d == nil {
+		log.Printf("[API] GenerateDigest completed: no new messages conversation_id=%d", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Printf("[API] GenerateDigest completed conversation_id=%d digest_id=%d", id, d.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newDigestResponse(d))
+}
+
+// runDigestJob executes a digest job
+func (h *DigestHandler) runDigestJob(ctx context.Context, payload json.RawMessage) (any, error) {
+	if h.job == nil {
+		return nil, errors.New("digests are not enabled")
+	}
+
+	var p DigestJobPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+
+	conv, err := h.db.GetConversation(p.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := h.job.GenerateForConversation(conv)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	log.Printf("[API] Digest job completed conversation_id=%d digest_id=%d", conv.ID, d.ID)
+	return newDigestResponse(d), nil
+}
+
+// newDigestResponse converts a digest to its API representation
+func newDigestResponse(d *digest.Digest) DigestResponse {
+	return DigestResponse{
+		ID:             d.ID,
+		ConversationID: d.ConversationID,
+		MessageID:      d.MessageID,
+		MessageCount:   d.MessageCount,
+		Content:        d.Content,
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestDigestHandler(t *testing.T) (*DigestHandler, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	handler := NewDigestHandler(database)
+
+	return handler, database
+}
+
+func TestGenerateDigest(t *testing.T) {
+	handler, database := setupTestDigestHandler(t)
+
+	handler.SetJob(digest.NewJob(database, nil, time.Hour))
+
+	conv, _ := database.CreateConversation("Planning", "")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/digest", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.Generate(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var resp DigestResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.MessageCount != 1 || resp.Content == "" {
+		t.Errorf("unexpected digest response: %+v", resp)
+	}
+
+	// No new messages since the digest
+	w = httptest.NewRecorder()
+	handler.Generate(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestGenerateDigest_NotEnabled(t *testing.T) {
+	handler, database := setupTestDigestHandler(t)
+
+	database.CreateConversation("Planning", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/digest", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.Generate(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
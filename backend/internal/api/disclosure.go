@@ -0,0 +1,125 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// DisclosureResponse describes the AI-generated content of a conversation, so exports and
+// other consumers can label it without inferring it from sender types
+type DisclosureResponse struct {
+	ConversationID    int64 `json:"conversation_id"`
+	ContainsAIContent bool  `json:"contains_ai_content"`
+	MessageCount      int   `json:"message_count"`
+	AIMessageCount    int   `json:"ai_message_count"`
+	// Models are the known models that generated messages, sorted
+	Models []string `json:"models"`
+	// Avatars are the AI avatars that are in the conversation or wrote messages in it
+	Avatars []DisclosureAvatar `json:"avatars"`
+	// Banner is the disclosure text to show with the conversation, empty without AI content
+	Banner string `json:"banner,omitempty"`
+}
+
+// DisclosureAvatar is an AI avatar of a conversation and the messages it wrote
+type DisclosureAvatar struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	MessageCount int    `json:"message_count"`
+}
+
+// GetDisclosure handles GET /api/conversations/{id}/disclosure
+func (h *ConversationHandler) GetDisclosure(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetConversation(id); err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	messages, err := h.db.GetMessages(id)
+	if err != nil {
+		log.Printf("[API] GetDisclosure failed: DB error getting messages conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+	messageModels, err := h.db.GetConversationMessageModels(id)
+	if err != nil {
+		log.Printf("[API] GetDisclosure failed: DB error getting message models conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get message models", http.StatusInternalServerError)
+		return
+	}
+	members, err := h.db.GetConversationAvatars(id)
+	if err != nil {
+		log.Printf("[API] GetDisclosure failed: DB error getting avatars conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+
+	response := DisclosureResponse{
+		ConversationID: id,
+		MessageCount:   len(messages),
+		Models:         []string{},
+		Avatars:        []DisclosureAvatar{},
+	}
+	avatarIndex := make(map[int64]int)
+	for _, a := range members {
+		avatarIndex[a.ID] = len(response.Avatars)
+		response.Avatars = append(response.Avatars, DisclosureAvatar{ID: a.ID, Name: a.Name})
+	}
+
+	seenModels := make(map[string]bool)
+	var writers []string
+	for _, msg := range messages {
+		label := models.NewAILabel(msg.SenderType, messageModels[msg.ID])
+		if !label.AIGenerated {
+			continue
+		}
+		response.AIMessageCount++
+		if label.Model != "" && !seenModels[label.Model] {
+			seenModels[label.Model] = true
+			response.Models = append(response.Models, label.Model)
+		}
+		if msg.SenderType != models.SenderTypeAvatar || msg.SenderID == nil {
+			continue
+		}
+
+		// Avatars that left the conversation are still disclosed for the messages they wrote
+		i, ok := avatarIndex[*msg.SenderID]
+		if !ok {
+			name := ""
+			if avatar, err := h.db.GetAvatar(*msg.SenderID); err == nil {
+				name = avatar.Name
+			}
+			i = len(response.Avatars)
+			avatarIndex[*msg.SenderID] = i
+			response.Avatars = append(response.Avatars, DisclosureAvatar{ID: *msg.SenderID, Name: name})
+		}
+		if response.Avatars[i].MessageCount == 0 && response.Avatars[i].Name != "" {
+			writers = append(writers, response.Avatars[i].Name)
+		}
+		response.Avatars[i].MessageCount++
+	}
+	sort.Strings(response.Models)
+
+	if response.AIMessageCount > 0 {
+		response.ContainsAIContent = true
+		response.Banner = logic.FormatAIDisclosure(writers, response.Models)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
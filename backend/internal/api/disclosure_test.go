@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestGetDisclosure(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Disclosure", "")
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "")
+	bob, _ := handler.db.CreateAvatar("Bob", "prompt", "")
+	handler.db.AddAvatarToConversation(conv.ID, alice.ID)
+	handler.db.AddAvatarToConversation(conv.ID, bob.ID)
+
+	getDisclosure := func() DisclosureResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/conversations/%d/disclosure", conv.ID), nil)
+		req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+		w := httptest.NewRecorder()
+		handler.GetDisclosure(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var response DisclosureResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return response
+	}
+
+	handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hello")
+	if d := getDisclosure(); d.ContainsAIContent || d.Banner != "" || len(d.Avatars) != 2 {
+		t.Errorf("expected no AI content yet, got %+v", d)
+	}
+
+	reply, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Hi")
+	handler.db.RecordMessageModel(reply.ID, "gpt-4o")
+	digest, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeSystem, nil, "Summary")
+	handler.db.RecordMessageModel(digest.ID, "gpt-4o-mini")
+
+	d := getDisclosure()
+	if !d.ContainsAIContent || d.MessageCount != 3 || d.AIMessageCount != 2 {
+		t.Errorf("unexpected counts: %+v", d)
+	}
+	if strings.Join(d.Models, ",") != "gpt-4o,gpt-4o-mini" {
+		t.Errorf("unexpected models: %v", d.Models)
+	}
+	if d.Avatars[0].Name != "Alice" || d.Avatars[0].MessageCount != 1 || d.Avatars[1].MessageCount != 0 {
+		t.Errorf("unexpected avatars: %+v", d.Avatars)
+	}
+	if !strings.Contains(d.Banner, "Messages from Alice are written by AI avatars (gpt-4o, gpt-4o-mini)") {
+		t.Errorf("unexpected banner: %q", d.Banner)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/99999/disclosure", nil)
+	req.SetPathValue("id", "99999")
+	w := httptest.NewRecorder()
+	handler.GetDisclosure(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetMessages_IncludesAILabels(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Labels", "")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hello")
+	reply, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "Hi")
+	handler.db.RecordMessageModel(reply.ID, "gpt-4o")
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/conversations/%d/messages", conv.ID), nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.GetMessages(w, req)
+
+	var response []MessageResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(response))
+	}
+	if response[0].AILabel != (models.AILabel{}) {
+		t.Errorf("expected the user message not to be AI-generated, got %+v", response[0].AILabel)
+	}
+	if response[1].AILabel != (models.AILabel{AIGenerated: true, Model: "gpt-4o"}) {
+		t.Errorf("unexpected label of the avatar message: %+v", response[1].AILabel)
+	}
+}
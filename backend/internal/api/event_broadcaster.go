@@ -3,100 +3,476 @@ package api
 import (
 	"encoding/json"
 	"log"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/suggest"
 )
 
 // Event はServer-Sent Eventを表す
 type Event struct {
 	Type string `json:"type"`
 	Data any    `json:"data"`
+	// ID は会話ごとに増加するイベントID。クライアントは再接続時に Last-Event-ID で送り返す (0の場合は送信しない)
+	ID int64 `json:"-"`
+	// Retry はクライアントが再接続するまでの待ち時間 (0の場合は送信しない)
+	Retry time.Duration `json:"-"`
+}
+
+// historyEventTypes は会話の履歴として永続化するイベントタイプ
+// メッセージ自体はmessagesテーブルに保存されるため含めない
+var historyEventTypes = map[string]bool{
+	models.EventTypeAvatarJoined:   true,
+	models.EventTypeAvatarLeft:     true,
+	models.EventTypeInterrupt:      true,
+	models.EventTypeRunFailed:      true,
+	models.EventTypeWaitingForUser: true,
+}
+
+// eventFilter はクライアントが受信するイベントタイプの集合
+// 空の場合はすべてのイベントを受信する
+type eventFilter map[string]bool
+
+// newEventFilter はイベントタイプの一覧からフィルターを作成する
+func newEventFilter(types []string) eventFilter {
+	filter := make(eventFilter, len(types))
+	for _, t := range types {
+		if t != "" {
+			filter[t] = true
+		}
+	}
+	return filter
+}
+
+// allows はイベントタイプがフィルターを通過するかを返す
+func (f eventFilter) allows(eventType string) bool {
+	return len(f) == 0 || f[eventType]
+}
+
+// OverflowPolicy はクライアントのバッファが満杯のときの挙動を表す
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest は最も古い未送信イベントを捨てて新しいイベントを入れる
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDisconnect は追いつけないクライアントを切断する
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// DefaultClientBufferSize はクライアントごとのイベントバッファの既定サイズ
+const DefaultClientBufferSize = 10
+
+// ShutdownReconnectDelay はシャットダウン時にクライアントへ指示する再接続までの待ち時間
+const ShutdownReconnectDelay = 3 * time.Second
+
+// ParseOverflowPolicy はポリシー文字列を検証する
+func ParseOverflowPolicy(value string) (OverflowPolicy, bool) {
+	switch OverflowPolicy(value) {
+	case OverflowDropOldest, OverflowDisconnect:
+		return OverflowPolicy(value), true
+	}
+	return "", false
+}
+
+// subscriber は購読中のクライアントの状態を表す
+type subscriber struct {
+	filter  eventFilter
+	dropped atomic.Int64 // このクライアントで捨てたイベント数
+}
+
+// ViewerStats は会話の視聴者数の統計を表す
+// 履歴はプロセス内に保持され、再起動でリセットされる
+type ViewerStats struct {
+	ConversationID   int64      `json:"conversation_id"`
+	Viewers          int        `json:"viewers"`
+	PeakViewers      int        `json:"peak_viewers"`
+	TotalConnections int64      `json:"total_connections"`
+	LastConnectedAt  *time.Time `json:"last_connected_at,omitempty"`
+}
+
+// viewerHistory は会話ごとの接続履歴を表す
+type viewerHistory struct {
+	peak            int
+	total           int64
+	lastConnectedAt time.Time
+}
+
+// BroadcasterStats はSSE配信の統計情報を表す
+type BroadcasterStats struct {
+	Clients       int            `json:"clients"`
+	BufferSize    int            `json:"buffer_size"`
+	Policy        OverflowPolicy `json:"overflow_policy"`
+	DroppedEvents int64          `json:"dropped_events"`
+	Disconnects   int64          `json:"disconnects"`
+	// ReplayBufferSize は会話ごとに再送用に保持するイベント数、Resyncs は再送できずに resync を送った回数
+	ReplayBufferSize int           `json:"replay_buffer_size"`
+	Resyncs          int64         `json:"resyncs"`
+	Conversations    []ViewerStats `json:"conversations"`
 }
 
 // EventBroadcaster はSSEクライアントを管理し、イベントをブロードキャストする
 type EventBroadcaster struct {
-	mu      sync.RWMutex
-	clients map[int64]map[chan Event]struct{} // conversationID -> clients
+	mu          sync.RWMutex
+	clients     map[int64]map[chan Event]*subscriber // conversationID -> clients
+	history     *db.DB                               // nilの場合はイベント履歴を保存しない
+	viewers     map[int64]*viewerHistory             // conversationID -> 接続履歴
+	viewerCount atomic.Bool                          // trueの場合は視聴者数の変化をブロードキャストする
+	bufferSize  int
+	policy      OverflowPolicy
+	dropped     atomic.Int64
+	disconnects atomic.Int64
+	closed      bool // Shutdown後は新しい購読を受け付けない
+
+	// sequenceMu はイベントIDの採番から配信までを直列化する。mu より先に取得する
+	sequenceMu sync.Mutex
+	replay     map[int64]*replayLog // conversationID -> 再送ログ
+	replayBase int64                // 起動時刻 (マイクロ秒) または破棄した再送ログの最後のID。IDはこの続きから採番する
+	replaySize int
+	resyncs    atomic.Int64
 }
 
 // NewEventBroadcaster は新しいイベントブロードキャスターを作成する
 func NewEventBroadcaster() *EventBroadcaster {
 	return &EventBroadcaster{
-		clients: make(map[int64]map[chan Event]struct{}),
+		clients:    make(map[int64]map[chan Event]*subscriber),
+		viewers:    make(map[int64]*viewerHistory),
+		bufferSize: DefaultClientBufferSize,
+		policy:     OverflowDropOldest,
+		replay:     make(map[int64]*replayLog),
+		replayBase: time.Now().UnixMicro(),
+		replaySize: DefaultReplayBufferSize,
 	}
 }
 
-// Subscribe は会話のイベントを受信するクライアントを追加する
-func (b *EventBroadcaster) Subscribe(conversationID int64) chan Event {
+// SetHistory はイベント履歴の保存先データベースを設定する
+func (b *EventBroadcaster) SetHistory(database *db.DB) {
+	b.history = database
+}
+
+// SetOverflow はクライアントごとのバッファサイズと満杯時のポリシーを設定する
+// 既存のクライアントには影響せず、以降の購読から適用される
+func (b *EventBroadcaster) SetOverflow(bufferSize int, policy OverflowPolicy) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	ch := make(chan Event, 10) // バッファ付きチャネル
+	if bufferSize > 0 {
+		b.bufferSize = bufferSize
+	}
+	b.policy = policy
+}
+
+// SetViewerCountEvents は視聴者数が変化したときに viewer_count イベントを送信するかを設定する
+func (b *EventBroadcaster) SetViewerCountEvents(enabled bool) {
+	b.viewerCount.Store(enabled)
+}
+
+// Subscribe は会話のイベントを受信するクライアントを追加する
+// types を指定した場合、そのタイプのイベントだけを受信する
+// Shutdown後は nil を返す
+// viewer_count イベントが有効な場合、購読後に会話の全クライアントへ視聴者数を送信する
+func (b *EventBroadcaster) Subscribe(conversationID int64, types ...string) chan Event {
+	ch, _, _ := b.SubscribeFrom(conversationID, 0, types...)
+	return ch
+}
+
+// SubscribeFrom は Subscribe と同じくクライアントを追加し、lastEventID より後に送信されたイベントを返す
+// 返したイベントとチャネルに届くイベントは重複も欠落もしない。lastEventID が 0 の場合は何も返さない
+// 必要なイベントが再送バッファから消えている場合やサーバーの再起動をまたぐ場合は、resync イベントだけを返して resumed が false になる
+func (b *EventBroadcaster) SubscribeFrom(conversationID, lastEventID int64, types ...string) (ch chan Event, missed []Event, resumed bool) {
+	// 購読から再送イベントの取得までの間にブロードキャストが割り込まないようにする
+	b.sequenceMu.Lock()
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+		b.sequenceMu.Unlock()
+		log.Printf("[SSE] Subscription rejected: shutting down conversation_id=%d", conversationID)
+		return nil, nil, false
+	}
+
+	ch = make(chan Event, b.bufferSize) // バッファ付きチャネル
 
+	filter := newEventFilter(types)
 	if b.clients[conversationID] == nil {
-		b.clients[conversationID] = make(map[chan Event]struct{})
+		b.clients[conversationID] = make(map[chan Event]*subscriber)
 	}
-	b.clients[conversationID][ch] = struct{}{}
+	b.clients[conversationID][ch] = &subscriber{filter: filter}
+	count := len(b.clients[conversationID])
 
-	log.Printf("[SSE] Client subscribed conversation_id=%d total_clients=%d",
-		conversationID, len(b.clients[conversationID]))
+	// 接続履歴を更新する
+	history := b.viewers[conversationID]
+	if history == nil {
+		history = &viewerHistory{}
+		b.viewers[conversationID] = history
+	}
+	history.total++
+	history.lastConnectedAt = time.Now().UTC()
+	if count > history.peak {
+		history.peak = count
+	}
+	b.mu.Unlock()
 
-	return ch
+	resumed = true
+	if lastEventID > 0 {
+		if missed, resumed = b.missedLocked(conversationID, lastEventID, filter); !resumed {
+			b.resyncs.Add(1)
+		}
+	}
+	b.sequenceMu.Unlock()
+
+	log.Printf("[SSE] Client subscribed conversation_id=%d total_clients=%d types=%v last_event_id=%d replayed=%d resumed=%v",
+		conversationID, count, types, lastEventID, len(missed), resumed)
+
+	b.broadcastViewerCount(conversationID)
+	return ch, missed, resumed
 }
 
 // Unsubscribe はクライアントのイベント受信を解除する
+// 満杯で切断済みのクライアントに対して呼んでも安全
 func (b *EventBroadcaster) Unsubscribe(conversationID int64, ch chan Event) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
+	sub := b.removeLocked(conversationID, ch)
+	b.mu.Unlock()
 
-	if clients, ok := b.clients[conversationID]; ok {
-		delete(clients, ch)
-		close(ch)
-		if len(clients) == 0 {
-			delete(b.clients, conversationID)
-		}
+	if sub == nil {
+		return
+	}
+
+	log.Printf("[SSE] Client unsubscribed conversation_id=%d dropped_events=%d",
+		conversationID, sub.dropped.Load())
+
+	b.broadcastViewerCount(conversationID)
+}
+
+// removeLocked はクライアントを登録から外してチャネルを閉じる
+// 呼び出し側で書き込みロックを取得していること
+func (b *EventBroadcaster) removeLocked(conversationID int64, ch chan Event) *subscriber {
+	clients, ok := b.clients[conversationID]
+	if !ok {
+		return nil
+	}
+	sub, ok := clients[ch]
+	if !ok {
+		return nil
 	}
 
-	log.Printf("[SSE] Client unsubscribed conversation_id=%d", conversationID)
+	delete(clients, ch)
+	close(ch)
+	if len(clients) == 0 {
+		delete(b.clients, conversationID)
+	}
+	return sub
 }
 
 // Broadcast は会話を監視しているすべてのクライアントにイベントを送信する
+// 送信はブロックせず、満杯のクライアントにはオーバーフローポリシーを適用する
 func (b *EventBroadcaster) Broadcast(conversationID int64, event Event) {
+	// 購読中のクライアントがいなくても履歴には残す
+	b.recordHistory(conversationID, event)
+
+	if b.deliverAll(conversationID, event) > 0 {
+		b.broadcastViewerCount(conversationID)
+	}
+}
+
+// deliverAll はイベントにIDを採番して会話のクライアントに送信し、切断したクライアント数を返す
+// クライアントがID順にイベントを受け取れるよう、採番から配信までを sequenceMu で直列化する
+func (b *EventBroadcaster) deliverAll(conversationID int64, event Event) int {
+	b.sequenceMu.Lock()
+	defer b.sequenceMu.Unlock()
+
+	event.ID = b.recordReplayLocked(conversationID, event)
+
+	// 送信中はチャネルが閉じられないよう読み込みロックを保持する
 	b.mu.RLock()
 	clients := b.clients[conversationID]
-	b.mu.RUnlock()
-
 	if len(clients) == 0 {
-		return
+		b.mu.RUnlock()
+		return 0
 	}
 
 	log.Printf("[SSE] Broadcasting event type=%s conversation_id=%d clients=%d",
 		event.Type, conversationID, len(clients))
 
-	for ch := range clients {
-		select {
-		case ch <- event:
-		default:
-			// クライアントチャネルが満杯の場合、スキップ
-			log.Printf("[SSE] Client channel full, skipping event")
+	var overflowed []chan Event
+	for ch, sub := range clients {
+		if !sub.filter.allows(event.Type) {
+			continue
+		}
+		if !b.deliver(ch, sub, event) {
+			overflowed = append(overflowed, ch)
+		}
+	}
+	b.mu.RUnlock()
+
+	if len(overflowed) == 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	removed := 0
+	for _, ch := range overflowed {
+		if sub := b.removeLocked(conversationID, ch); sub != nil {
+			removed++
+			b.disconnects.Add(1)
+			log.Printf("[SSE] Client disconnected: channel full conversation_id=%d dropped_events=%d",
+				conversationID, sub.dropped.Load())
 		}
 	}
+	b.mu.Unlock()
+	return removed
+}
+
+// deliver はイベントを1クライアントに送信する
+// 切断ポリシーでクライアントが追いつけない場合は false を返す
+func (b *EventBroadcaster) deliver(ch chan Event, sub *subscriber, event Event) bool {
+	select {
+	case ch <- event:
+		return true
+	default:
+	}
+
+	b.dropped.Add(1)
+	sub.dropped.Add(1)
+
+	if b.policy == OverflowDisconnect {
+		return false
+	}
+
+	// 最も古いイベントを捨てて新しいイベントを入れる
+	select {
+	case <-ch:
+	default:
+	}
+	select {
+	case ch <- event:
+	default:
+		// 他のブロードキャストと競合した場合は新しいイベントを捨てる
+		log.Printf("[SSE] Client channel full, skipping event type=%s", event.Type)
+	}
+	return true
+}
+
+// recordHistory は履歴対象のイベントをデータベースに保存する
+func (b *EventBroadcaster) recordHistory(conversationID int64, event Event) {
+	if b.history == nil || !historyEventTypes[event.Type] {
+		return
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		log.Printf("[SSE] Failed to encode event history type=%s conversation_id=%d err=%v",
+			event.Type, conversationID, err)
+		return
+	}
+	if _, err := b.history.CreateConversationEvent(conversationID, event.Type, string(data)); err != nil {
+		log.Printf("[SSE] Failed to record event history type=%s conversation_id=%d err=%v",
+			event.Type, conversationID, err)
+	}
+}
+
+// BroadcastAll はすべての会話のクライアントにイベントを送信する
+func (b *EventBroadcaster) BroadcastAll(event Event) {
+	b.mu.RLock()
+	conversationIDs := make([]int64, 0, len(b.clients))
+	for id := range b.clients {
+		conversationIDs = append(conversationIDs, id)
+	}
+	b.mu.RUnlock()
+
+	for _, id := range conversationIDs {
+		b.Broadcast(id, event)
+	}
+}
+
+// BroadcastLLMAvailability はOpenAI APIの利用可否の変化をブロードキャストする
+func (b *EventBroadcaster) BroadcastLLMAvailability(open bool, retryAfter time.Duration) {
+	if open {
+		b.BroadcastAll(Event{
+			Type: models.EventTypeLLMUnavailable,
+			Data: models.LLMUnavailableEvent{RetryAfterSeconds: int(retryAfter.Seconds())},
+		})
+		return
+	}
+	b.BroadcastAll(Event{
+		Type: models.EventTypeLLMAvailable,
+		Data: models.EmptyEvent{},
+	})
+}
+
+// BroadcastPresence はユーザが会話を見ているか (viewing / away) の変化をブロードキャストする
+func (b *EventBroadcaster) BroadcastPresence(conversationID int64, status, updatedAt string) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypePresence,
+		Data: models.PresenceEvent{ConversationID: conversationID, Status: status, UpdatedAt: updatedAt},
+	})
+}
+
+// BroadcastConversationExpired は有効期限を過ぎた会話の削除を全クライアントにブロードキャストする
+// 削除される会話のクライアントだけでなく、会話一覧を表示している他の会話のクライアントにも送る
+func (b *EventBroadcaster) BroadcastConversationExpired(conversationID int64, title string) {
+	b.BroadcastAll(Event{
+		Type: models.EventTypeConversationExpired,
+		Data: models.ConversationExpiredEvent{ConversationID: conversationID, Title: title},
+	})
 }
 
 // BroadcastMessage は新しいメッセージイベントをブロードキャストする
-func (b *EventBroadcaster) BroadcastMessage(conversationID int64, message any) {
+func (b *EventBroadcaster) BroadcastMessage(conversationID int64, message models.MessageEvent) {
 	b.Broadcast(conversationID, Event{
-		Type: "message",
+		Type: models.EventTypeMessage,
 		Data: message,
 	})
 }
 
+// BroadcastMessageDelta は書き途中のアバターの応答の一部をブロードキャストする
+func (b *EventBroadcaster) BroadcastMessageDelta(conversationID, avatarID int64, avatarName, runID, delta string) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeMessageDelta,
+		Data: models.MessageDeltaEvent{AvatarID: avatarID, AvatarName: avatarName, RunID: runID, Delta: delta},
+	})
+}
+
+// BroadcastMessageComplete はストリーミングした応答の終了をブロードキャストする
+// messageID は保存したメッセージで、保存しなかった場合は nil
+func (b *EventBroadcaster) BroadcastMessageComplete(conversationID, avatarID int64, runID string, messageID *int64) {
+	status := models.MessageCompleteSaved
+	if messageID == nil {
+		status = models.MessageCompleteFailed
+	}
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeMessageComplete,
+		Data: models.MessageCompleteEvent{AvatarID: avatarID, RunID: runID, Status: status, MessageID: messageID},
+	})
+}
+
+// BroadcastTyping はユーザが入力中であることをブロードキャストする
+func (b *EventBroadcaster) BroadcastTyping(conversationID int64) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeTyping,
+		Data: models.TypingEvent{SenderType: models.SenderTypeUser},
+	})
+}
+
 // BroadcastAvatarJoined はアバター参加イベントをブロードキャストする
 func (b *EventBroadcaster) BroadcastAvatarJoined(conversationID int64, avatarID int64, avatarName string) {
+	b.BroadcastAvatarJoinedWithDisplay(conversationID, avatarID, avatarName, "", "")
+}
+
+// BroadcastAvatarJoinedWithDisplay は表示用の色と絵文字を含めてアバター参加イベントをブロードキャストする
+func (b *EventBroadcaster) BroadcastAvatarJoinedWithDisplay(conversationID int64, avatarID int64, avatarName, avatarColor, avatarEmoji string) {
 	b.Broadcast(conversationID, Event{
-		Type: "avatar_joined",
-		Data: map[string]any{
-			"avatar_id":   avatarID,
-			"avatar_name": avatarName,
+		Type: models.EventTypeAvatarJoined,
+		Data: models.AvatarJoinedEvent{
+			AvatarID:    avatarID,
+			AvatarName:  avatarName,
+			AvatarColor: avatarColor,
+			AvatarEmoji: avatarEmoji,
 		},
 	})
 }
@@ -104,13 +480,93 @@ func (b *EventBroadcaster) BroadcastAvatarJoined(conversationID int64, avatarID
 // BroadcastAvatarLeft はアバター退室イベントをブロードキャストする
 func (b *EventBroadcaster) BroadcastAvatarLeft(conversationID int64, avatarID int64) {
 	b.Broadcast(conversationID, Event{
-		Type: "avatar_left",
-		Data: map[string]any{
-			"avatar_id": avatarID,
+		Type: models.EventTypeAvatarLeft,
+		Data: models.AvatarLeftEvent{AvatarID: avatarID},
+	})
+}
+
+// BroadcastInterrupt は会話の中断イベントをブロードキャストする
+func (b *EventBroadcaster) BroadcastInterrupt(conversationID int64) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeInterrupt,
+		Data: models.EmptyEvent{},
+	})
+}
+
+// BroadcastRunFailed は最大実行時間を超えて打ち切られた実行をブロードキャストする
+func (b *EventBroadcaster) BroadcastRunFailed(conversationID int64, avatarID int64, runID, reason string) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeRunFailed,
+		Data: models.RunFailedEvent{AvatarID: avatarID, RunID: runID, Reason: reason},
+	})
+}
+
+// BroadcastWaitingForUser はアバター同士の発言が続きすぎて、ユーザの発言まで応答を止めたことをブロードキャストする
+func (b *EventBroadcaster) BroadcastWaitingForUser(conversationID int64, avatarMessages int) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeWaitingForUser,
+		Data: models.WaitingForUserEvent{AvatarMessages: avatarMessages},
+	})
+}
+
+// BroadcastMessagesImported はまとめて挿入されたメッセージの範囲をブロードキャストする
+// メッセージごとのイベントは送らないため、クライアントはメッセージ一覧を取得し直す
+func (b *EventBroadcaster) BroadcastMessagesImported(conversationID int64, count int, firstSequence, lastSequence int64) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeMessagesImported,
+		Data: models.MessagesImportedEvent{
+			Count:         count,
+			FirstSequence: firstSequence,
+			LastSequence:  lastSequence,
+		},
+	})
+}
+
+// BroadcastSuggestions は会話が落ち着いた後にユーザへ提案する返信をブロードキャストする
+func (b *EventBroadcaster) BroadcastSuggestions(conversationID int64, suggestions *suggest.Suggestions) {
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeSuggestions,
+		Data: suggestions,
+	})
+}
+
+// broadcastViewerCount は会話の現在の視聴者数をブロードキャストする
+// SetViewerCountEvents で無効にされている場合は何もしない
+func (b *EventBroadcaster) broadcastViewerCount(conversationID int64) {
+	if !b.viewerCount.Load() {
+		return
+	}
+	b.Broadcast(conversationID, Event{
+		Type: models.EventTypeViewerCount,
+		Data: models.ViewerCountEvent{
+			ConversationID: conversationID,
+			Viewers:        b.ClientCount(conversationID),
 		},
 	})
 }
 
+// ViewerStats は会話の現在の視聴者数と接続履歴を返す
+func (b *EventBroadcaster) ViewerStats(conversationID int64) ViewerStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.viewerStatsLocked(conversationID)
+}
+
+// viewerStatsLocked は読み込みロックを取得した状態で視聴者統計を作成する
+func (b *EventBroadcaster) viewerStatsLocked(conversationID int64) ViewerStats {
+	stats := ViewerStats{
+		ConversationID: conversationID,
+		Viewers:        len(b.clients[conversationID]),
+	}
+	if history := b.viewers[conversationID]; history != nil {
+		stats.PeakViewers = history.peak
+		stats.TotalConnections = history.total
+		lastConnectedAt := history.lastConnectedAt
+		stats.LastConnectedAt = &lastConnectedAt
+	}
+	return stats
+}
+
 // ClientCount は会話に購読しているクライアント数を返す
 func (b *EventBroadcaster) ClientCount(conversationID int64) int {
 	b.mu.RLock()
@@ -130,11 +586,95 @@ func (b *EventBroadcaster) TotalClientCount() int {
 	return total
 }
 
+// Shutdown はすべてのクライアントに server_shutdown イベントを送ってストリームを閉じる
+// クライアントは retry 経過後に再接続するため、別のインスタンスに接続し直せる
+// 以降の Subscribe は nil を返す。閉じたクライアント数を返す
+func (b *EventBroadcaster) Shutdown(retry time.Duration) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	event := Event{
+		Type:  models.EventTypeServerShutdown,
+		Data:  models.ServerShutdownEvent{RetryMS: retry.Milliseconds()},
+		Retry: retry,
+	}
+
+	count := 0
+	for conversationID, clients := range b.clients {
+		for ch := range clients {
+			// フィルターに関係なく送信し、満杯なら最も古いイベントを捨てる
+			select {
+			case ch <- event:
+			default:
+				select {
+				case <-ch:
+				default:
+				}
+				select {
+				case ch <- event:
+				default:
+				}
+			}
+			b.removeLocked(conversationID, ch)
+			count++
+		}
+	}
+
+	log.Printf("[SSE] Shutdown: closed %d client streams", count)
+	return count
+}
+
+// Stats はSSE配信の統計情報を返す
+func (b *EventBroadcaster) Stats() BroadcasterStats {
+	// sequenceMu は mu より先に取得するため、先に読み取っておく
+	b.sequenceMu.Lock()
+	replaySize := b.replaySize
+	b.sequenceMu.Unlock()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	clients := 0
+	for _, c := range b.clients {
+		clients += len(c)
+	}
+
+	// 一度でも接続があった会話を会話ID順に並べる
+	conversations := make([]ViewerStats, 0, len(b.viewers))
+	for conversationID := range b.viewers {
+		conversations = append(conversations, b.viewerStatsLocked(conversationID))
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].ConversationID < conversations[j].ConversationID
+	})
+
+	return BroadcasterStats{
+		Clients:          clients,
+		BufferSize:       b.bufferSize,
+		Policy:           b.policy,
+		DroppedEvents:    b.dropped.Load(),
+		Disconnects:      b.disconnects.Load(),
+		ReplayBufferSize: replaySize,
+		Resyncs:          b.resyncs.Load(),
+		Conversations:    conversations,
+	}
+}
+
 // FormatSSE はイベントをSSE形式にフォーマットする
 func FormatSSE(event Event) ([]byte, error) {
 	data, err := json.Marshal(event.Data)
 	if err != nil {
 		return nil, err
 	}
-	return []byte("event: " + event.Type + "\ndata: " + string(data) + "\n\n"), nil
+	retry := ""
+	if event.Retry > 0 {
+		retry = "retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n"
+	}
+	id := ""
+	if event.ID > 0 {
+		id = "id: " + strconv.FormatInt(event.ID, 10) + "\n"
+	}
+	return []byte("event: " + event.Type + "\n" + id + retry + "data: " + string(data) + "\n\n"), nil
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"testing"
 	"time"
+
+	"multi-avatar-chat/internal/models"
 )
 
 func TestNewEventBroadcaster(t *testing.T) {
@@ -138,10 +140,7 @@ func TestEventBroadcaster_BroadcastMessage(t *testing.T) {
 
 	// Broadcast a message
 	go func() {
-		b.BroadcastMessage(conversationID, map[string]any{
-			"id":      1,
-			"content": "Hello",
-		})
+		b.BroadcastMessage(conversationID, models.MessageEvent{ID: 1, Content: "Hello"})
 	}()
 
 	// Receive the event
@@ -174,12 +173,12 @@ func TestEventBroadcaster_BroadcastAvatarJoined(t *testing.T) {
 		if event.Type != "avatar_joined" {
 			t.Errorf("Expected event type 'avatar_joined', got '%s'", event.Type)
 		}
-		data, ok := event.Data.(map[string]any)
+		data, ok := event.Data.(models.AvatarJoinedEvent)
 		if !ok {
-			t.Fatal("Event data is not map[string]any")
+			t.Fatal("Event data is not models.AvatarJoinedEvent")
 		}
-		if data["avatar_name"] != "TestAvatar" {
-			t.Errorf("Expected avatar_name 'TestAvatar', got '%v'", data["avatar_name"])
+		if data.AvatarName != "TestAvatar" {
+			t.Errorf("Expected avatar_name 'TestAvatar', got '%v'", data.AvatarName)
 		}
 	case <-time.After(time.Second):
 		t.Fatal("Timeout waiting for avatar_joined event")
@@ -205,12 +204,12 @@ func TestEventBroadcaster_BroadcastAvatarLeft(t *testing.T) {
 		if event.Type != "avatar_left" {
 			t.Errorf("Expected event type 'avatar_left', got '%s'", event.Type)
 		}
-		data, ok := event.Data.(map[string]any)
+		data, ok := event.Data.(models.AvatarLeftEvent)
 		if !ok {
-			t.Fatal("Event data is not map[string]any")
+			t.Fatal("Event data is not models.AvatarLeftEvent")
 		}
-		if data["avatar_id"] != int64(10) {
-			t.Errorf("Expected avatar_id 10, got '%v'", data["avatar_id"])
+		if data.AvatarID != 10 {
+			t.Errorf("Expected avatar_id 10, got '%v'", data.AvatarID)
 		}
 	case <-time.After(time.Second):
 		t.Fatal("Timeout waiting for avatar_left event")
@@ -219,6 +218,47 @@ func TestEventBroadcaster_BroadcastAvatarLeft(t *testing.T) {
 	b.Unsubscribe(conversationID, ch)
 }
 
+func TestEventBroadcaster_BroadcastLLMAvailability(t *testing.T) {
+	b := NewEventBroadcaster()
+
+	ch1 := b.Subscribe(1)
+	ch2 := b.Subscribe(2)
+
+	b.BroadcastLLMAvailability(true, 30*time.Second)
+
+	for _, ch := range []chan Event{ch1, ch2} {
+		select {
+		case event := <-ch:
+			if event.Type != "llm_unavailable" {
+				t.Errorf("Expected event type 'llm_unavailable', got '%s'", event.Type)
+			}
+			data, ok := event.Data.(models.LLMUnavailableEvent)
+			if !ok {
+				t.Fatal("Event data is not models.LLMUnavailableEvent")
+			}
+			if data.RetryAfterSeconds != 30 {
+				t.Errorf("Expected retry_after_seconds 30, got '%v'", data.RetryAfterSeconds)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for llm_unavailable event")
+		}
+	}
+
+	b.BroadcastLLMAvailability(false, 0)
+
+	select {
+	case event := <-ch1:
+		if event.Type != "llm_available" {
+			t.Errorf("Expected event type 'llm_available', got '%s'", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for llm_available event")
+	}
+
+	b.Unsubscribe(1, ch1)
+	b.Unsubscribe(2, ch2)
+}
+
 func TestFormatSSE(t *testing.T) {
 	event := Event{
 		Type: "message",
@@ -251,3 +291,322 @@ func TestFormatSSE(t *testing.T) {
 	}
 }
 
+
+func TestEventBroadcaster_BroadcastAvatarJoinedWithDisplay(t *testing.T) {
+	b := NewEventBroadcaster()
+	conversationID := int64(1)
+
+	ch := b.Subscribe(conversationID)
+	defer b.Unsubscribe(conversationID, ch)
+
+	go func() {
+		b.BroadcastAvatarJoinedWithDisplay(conversationID, 10, "TestAvatar", "#112233", "🦊")
+	}()
+
+	select {
+	case event := <-ch:
+		data, ok := event.Data.(models.AvatarJoinedEvent)
+		if !ok {
+			t.Fatal("Event data is not models.AvatarJoinedEvent")
+		}
+		if data.AvatarColor != "#112233" {
+			t.Errorf("Expected avatar_color '#112233', got '%v'", data.AvatarColor)
+		}
+		if data.AvatarEmoji != "🦊" {
+			t.Errorf("Expected avatar_emoji '🦊', got '%v'", data.AvatarEmoji)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for avatar_joined event")
+	}
+}
+
+func TestEventBroadcaster_SubscribeWithTypes(t *testing.T) {
+	b := NewEventBroadcaster()
+	conversationID := int64(1)
+
+	filtered := b.Subscribe(conversationID, "message")
+	all := b.Subscribe(conversationID)
+
+	b.BroadcastAvatarLeft(conversationID, 2)
+	b.BroadcastMessage(conversationID, models.MessageEvent{Content: "hello"})
+
+	select {
+	case event := <-filtered:
+		if event.Type != "message" {
+			t.Errorf("Expected only message events, got '%s'", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for event")
+	}
+	if len(filtered) != 0 {
+		t.Errorf("Expected filtered channel to be empty, got %d events", len(filtered))
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected unfiltered channel to receive 2 events, got %d", len(all))
+	}
+
+	b.Unsubscribe(conversationID, filtered)
+	b.Unsubscribe(conversationID, all)
+}
+
+func TestEventBroadcaster_OverflowDropOldest(t *testing.T) {
+	b := NewEventBroadcaster()
+	b.SetOverflow(2, OverflowDropOldest)
+	conversationID := int64(1)
+
+	ch := b.Subscribe(conversationID)
+	for i := 0; i < 3; i++ {
+		b.BroadcastMessage(conversationID, models.MessageEvent{ID: int64(i)})
+	}
+
+	first := <-ch
+	second := <-ch
+	if first.Data.(models.MessageEvent).ID != 1 || second.Data.(models.MessageEvent).ID != 2 {
+		t.Errorf("Expected the newest events [1 2], got [%v %v]", first.Data, second.Data)
+	}
+
+	stats := b.Stats()
+	if stats.DroppedEvents != 1 || stats.Disconnects != 0 || stats.Clients != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	b.Unsubscribe(conversationID, ch)
+}
+
+func TestEventBroadcaster_OverflowDisconnect(t *testing.T) {
+	b := NewEventBroadcaster()
+	b.SetOverflow(1, OverflowDisconnect)
+	conversationID := int64(1)
+
+	slow := b.Subscribe(conversationID)
+	fast := b.Subscribe(conversationID)
+
+	b.BroadcastMessage(conversationID, models.MessageEvent{ID: 1})
+	<-fast
+	b.BroadcastMessage(conversationID, models.MessageEvent{ID: 2})
+
+	// The slow client gets the buffered event, then its channel is closed
+	if event := <-slow; event.Data.(models.MessageEvent).ID != 1 {
+		t.Errorf("Expected buffered event 1, got %v", event.Data)
+	}
+	if _, ok := <-slow; ok {
+		t.Error("Expected slow client channel to be closed")
+	}
+	if event := <-fast; event.Data.(models.MessageEvent).ID != 2 {
+		t.Errorf("Expected fast client to receive event 2, got %v", event.Data)
+	}
+
+	stats := b.Stats()
+	if stats.DroppedEvents != 1 || stats.Disconnects != 1 || stats.Clients != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Unsubscribing an already disconnected client must not panic
+	b.Unsubscribe(conversationID, slow)
+	b.Unsubscribe(conversationID, fast)
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	if p, ok := ParseOverflowPolicy("disconnect"); !ok || p != OverflowDisconnect {
+		t.Errorf("Expected disconnect policy, got %q %v", p, ok)
+	}
+	if _, ok := ParseOverflowPolicy("block"); ok {
+		t.Error("Expected unknown policy to be rejected")
+	}
+}
+
+func TestEventBroadcaster_Shutdown(t *testing.T) {
+	b := NewEventBroadcaster()
+
+	// Shutdown is delivered even to clients filtering for other types
+	filtered := b.Subscribe(1, "message")
+	other := b.Subscribe(2)
+
+	if closed := b.Shutdown(2 * time.Second); closed != 2 {
+		t.Errorf("Expected 2 closed clients, got %d", closed)
+	}
+
+	for _, ch := range []chan Event{filtered, other} {
+		event, ok := <-ch
+		if !ok || event.Type != "server_shutdown" || event.Retry != 2*time.Second {
+			t.Errorf("Expected server_shutdown event, got %+v ok=%v", event, ok)
+		}
+		if _, ok := <-ch; ok {
+			t.Error("Expected channel to be closed after shutdown")
+		}
+	}
+
+	if ch := b.Subscribe(1); ch != nil {
+		t.Error("Expected Subscribe to return nil after shutdown")
+	}
+	if b.TotalClientCount() != 0 {
+		t.Errorf("Expected no clients after shutdown, got %d", b.TotalClientCount())
+	}
+
+	// Handlers unsubscribing after shutdown must not panic
+	b.Unsubscribe(1, filtered)
+}
+
+func TestFormatSSE_Retry(t *testing.T) {
+	data, err := FormatSSE(Event{Type: "server_shutdown", Data: map[string]any{}, Retry: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("FormatSSE returned error: %v", err)
+	}
+
+	expected := "event: server_shutdown\nretry: 3000\ndata: {}\n\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+}
+
+func TestEventBroadcaster_ViewerCountEvents(t *testing.T) {
+	b := NewEventBroadcaster()
+	b.SetViewerCountEvents(true)
+	conversationID := int64(1)
+
+	first := b.Subscribe(conversationID)
+	if event := <-first; event.Type != "viewer_count" || event.Data.(models.ViewerCountEvent).Viewers != 1 {
+		t.Errorf("Expected viewer_count with 1 viewer, got %+v", event)
+	}
+
+	second := b.Subscribe(conversationID)
+	for _, ch := range []chan Event{first, second} {
+		if event := <-ch; event.Type != "viewer_count" || event.Data.(models.ViewerCountEvent).Viewers != 2 {
+			t.Errorf("Expected viewer_count with 2 viewers, got %+v", event)
+		}
+	}
+
+	b.Unsubscribe(conversationID, second)
+	if event := <-first; event.Type != "viewer_count" || event.Data.(models.ViewerCountEvent).Viewers != 1 {
+		t.Errorf("Expected viewer_count with 1 viewer after unsubscribe, got %+v", event)
+	}
+
+	// Clients filtering for other types are not sent viewer counts
+	filtered := b.Subscribe(conversationID, "message")
+	<-first
+	b.BroadcastMessage(conversationID, models.MessageEvent{Content: "hello"})
+	if event := <-filtered; event.Type != "message" {
+		t.Errorf("Expected only message events on filtered channel, got %+v", event)
+	}
+}
+
+func TestEventBroadcaster_ViewerStats(t *testing.T) {
+	b := NewEventBroadcaster()
+	conversationID := int64(1)
+
+	if stats := b.ViewerStats(conversationID); stats.Viewers != 0 || stats.TotalConnections != 0 || stats.LastConnectedAt != nil {
+		t.Errorf("Expected empty stats for unknown conversation, got %+v", stats)
+	}
+
+	first := b.Subscribe(conversationID)
+	second := b.Subscribe(conversationID)
+	b.Unsubscribe(conversationID, first)
+	third := b.Subscribe(conversationID)
+	b.Subscribe(2)
+
+	stats := b.ViewerStats(conversationID)
+	if stats.Viewers != 2 || stats.PeakViewers != 2 || stats.TotalConnections != 3 || stats.LastConnectedAt == nil {
+		t.Errorf("Unexpected viewer stats: %+v", stats)
+	}
+
+	b.Unsubscribe(conversationID, second)
+	b.Unsubscribe(conversationID, third)
+	if stats := b.ViewerStats(conversationID); stats.Viewers != 0 || stats.PeakViewers != 2 {
+		t.Errorf("Expected history to survive disconnects, got %+v", stats)
+	}
+
+	all := b.Stats().Conversations
+	if len(all) != 2 || all[0].ConversationID != 1 || all[1].ConversationID != 2 {
+		t.Errorf("Expected stats for conversations [1 2], got %+v", all)
+	}
+}
+
+func TestEventBroadcaster_SubscribeFrom(t *testing.T) {
+	b := NewEventBroadcaster()
+	conversationID := int64(1)
+
+	ch := b.Subscribe(conversationID)
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "first"})
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessageDelta, Data: "delta"})
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "second"})
+	first, delta, second := <-ch, <-ch, <-ch
+	if first.ID == 0 || delta.ID <= first.ID || second.ID <= delta.ID {
+		t.Fatalf("Expected increasing event IDs, got %d %d %d", first.ID, delta.ID, second.ID)
+	}
+	b.Unsubscribe(conversationID, ch)
+
+	// Events sent while disconnected are replayed; deltas are not kept
+	b.Broadcast(conversationID, Event{Type: models.EventTypeAvatarLeft, Data: "left"})
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "third"})
+	ch, missed, resumed := b.SubscribeFrom(conversationID, first.ID, models.EventTypeMessage)
+	if !resumed || len(missed) != 2 || missed[0].Data != "second" || missed[1].Data != "third" {
+		t.Fatalf("Expected the missed messages to be replayed, got %+v resumed=%v", missed, resumed)
+	}
+
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "fourth"})
+	if event := <-ch; event.Data != "fourth" || event.ID <= missed[1].ID {
+		t.Errorf("Expected the next event after the replay, got %+v", event)
+	}
+
+	// An ID from before the server started cannot be replayed
+	_, missed, resumed = b.SubscribeFrom(conversationID, 1)
+	if resumed || len(missed) != 1 || missed[0].Type != models.EventTypeResync || missed[0].ID < first.ID {
+		t.Errorf("Expected a resync event with the latest ID, got %+v resumed=%v", missed, resumed)
+	}
+}
+
+func TestEventBroadcaster_SubscribeFrom_Evicted(t *testing.T) {
+	b := NewEventBroadcaster()
+	b.SetReplayBufferSize(1)
+	conversationID := int64(1)
+
+	ch := b.Subscribe(conversationID)
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "first"})
+	first := <-ch
+	b.Unsubscribe(conversationID, ch)
+
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "second"})
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "third"})
+
+	if _, missed, resumed := b.SubscribeFrom(conversationID, first.ID); resumed || missed[0].Type != models.EventTypeResync {
+		t.Errorf("Expected a resync once the missed events were evicted, got %+v", missed)
+	}
+	if stats := b.Stats(); stats.Resyncs != 1 || stats.ReplayBufferSize != 1 {
+		t.Errorf("Expected 1 resync with a replay buffer of 1, got %+v", stats)
+	}
+}
+
+func TestEventBroadcaster_DropConversation(t *testing.T) {
+	b := NewEventBroadcaster()
+	conversationID := int64(1)
+
+	ch := b.Subscribe(conversationID)
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "first"})
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "second"})
+	first, second := <-ch, <-ch
+	b.Unsubscribe(conversationID, ch)
+
+	b.DropConversation(conversationID)
+	b.sequenceMu.Lock()
+	_, kept := b.replay[conversationID]
+	b.sequenceMu.Unlock()
+	if kept {
+		t.Fatal("Expected the replay buffer of the deleted conversation to be dropped")
+	}
+
+	// A reused conversation ID continues after the dropped IDs, so old clients resync instead of missing events
+	b.Broadcast(conversationID, Event{Type: models.EventTypeMessage, Data: "new"})
+	if _, missed, resumed := b.SubscribeFrom(conversationID, first.ID); resumed || missed[0].Type != models.EventTypeResync {
+		t.Errorf("Expected a resync for an ID of the dropped conversation, got %+v", missed)
+	}
+	if _, missed, resumed := b.SubscribeFrom(conversationID, second.ID); !resumed || len(missed) != 1 || missed[0].Data != "new" {
+		t.Errorf("Expected the events after the dropped IDs, got %+v resumed=%v", missed, resumed)
+	}
+}
+
+func TestFormatSSE_ID(t *testing.T) {
+	data, _ := FormatSSE(Event{Type: models.EventTypeMessage, Data: models.EmptyEvent{}, ID: 42})
+	if expected := "event: message\nid: 42\ndata: {}\n\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"multi-avatar-chat/internal/models"
+)
+
+// DefaultReplayBufferSize は会話ごとに再送用に保持するイベント数の既定値
+const DefaultReplayBufferSize = 100
+
+// transientEventTypes は再送バッファに保持しないイベントタイプ
+// その時点の状態を表すだけで、再接続後に受け取っても意味がない
+// 応答の断片は数が多く、バッファからメッセージを押し出してしまうため含める
+var transientEventTypes = map[string]bool{
+	models.EventTypeViewerCount:  true,
+	models.EventTypeMessageDelta: true,
+	models.EventTypeTyping:       true,
+}
+
+// replayLog は会話ごとのイベントIDの採番と再送バッファを表す
+type replayLog struct {
+	lastID  int64   // 最後に採番したイベントID
+	evicted int64   // バッファから消えたイベントの最大ID。これより前のイベントは再送できない
+	events  []Event // 再送できるイベント (古い順)
+}
+
+// SetReplayBufferSize は会話ごとに再送用に保持するイベント数を設定する
+// 0 の場合はイベントを保持せず、再接続したクライアントには常に resync を送る
+func (b *EventBroadcaster) SetReplayBufferSize(size int) {
+	b.sequenceMu.Lock()
+	defer b.sequenceMu.Unlock()
+
+	if size >= 0 {
+		b.replaySize = size
+	}
+}
+
+// DropConversation は削除された会話の再送ログを破棄する
+// 会話IDが再利用されても古いIDで再接続したクライアントが resync を受け取るよう、以降の採番は破棄したログの続きから始める
+func (b *EventBroadcaster) DropConversation(conversationID int64) {
+	b.sequenceMu.Lock()
+	defer b.sequenceMu.Unlock()
+
+	rl := b.replay[conversationID]
+	if rl == nil {
+		return
+	}
+	if rl.lastID > b.replayBase {
+		b.replayBase = rl.lastID
+	}
+	delete(b.replay, conversationID)
+}
+
+// replayLogLocked は会話の再送ログを返す。sequenceMu を取得していること
+// 起動前のIDはすべて再送できないため、IDはサーバー起動時刻 (マイクロ秒) の続きから採番する
+func (b *EventBroadcaster) replayLogLocked(conversationID int64) *replayLog {
+	rl := b.replay[conversationID]
+	if rl == nil {
+		rl = &replayLog{lastID: b.replayBase, evicted: b.replayBase}
+		b.replay[conversationID] = rl
+	}
+	return rl
+}
+
+// recordReplayLocked はイベントにIDを採番し、再送対象のイベントをバッファに追加する
+// sequenceMu を取得していること。採番したIDを返す
+func (b *EventBroadcaster) recordReplayLocked(conversationID int64, event Event) int64 {
+	rl := b.replayLogLocked(conversationID)
+	rl.lastID++
+	event.ID = rl.lastID
+
+	if !transientEventTypes[event.Type] {
+		rl.events = append(rl.events, event)
+	}
+	for len(rl.events) > b.replaySize {
+		rl.evicted = rl.events[0].ID
+		rl.events = rl.events[1:]
+	}
+	return event.ID
+}
+
+// missedLocked は lastEventID より後に送信されたイベントのうち、filter を通過するものを返す
+// 必要なイベントがバッファから消えている場合やサーバーの再起動をまたぐ場合は、代わりに resync イベントを返して false を返す
+// resync には最新のIDを付け、次の再接続ではそれ以降のイベントだけを受け取れるようにする
+// sequenceMu を取得していること
+func (b *EventBroadcaster) missedLocked(conversationID, lastEventID int64, filter eventFilter) ([]Event, bool) {
+	rl := b.replayLogLocked(conversationID)
+	if lastEventID < rl.evicted || lastEventID > rl.lastID {
+		return []Event{{Type: models.EventTypeResync, Data: models.EmptyEvent{}, ID: rl.lastID}}, false
+	}
+
+	var missed []Event
+	for _, event := range rl.events {
+		if event.ID > lastEventID && filter.allows(event.Type) {
+			missed = append(missed, event)
+		}
+	}
+	return missed, true
+}
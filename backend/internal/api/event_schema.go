@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/suggest"
+)
+
+// EventSchema はSSEイベントタイプ1つ分のペイロードをJSON Schemaで表す
+type EventSchema struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// History はイベント履歴として保存されるイベントかを表す
+	History bool           `json:"history"`
+	Schema  map[string]any `json:"schema"`
+}
+
+// eventPayload はイベントタイプとペイロードの型の対応を表す
+type eventPayload struct {
+	eventType   string
+	description string
+	payload     any
+}
+
+// eventPayloads はSSEで送信するすべてのイベントタイプ
+// 新しいイベントを送信するときはここに登録する (契約テストで検証している)
+var eventPayloads = []eventPayload{
+	{models.EventTypeConnected, "ストリームの接続完了時に最初に送信される", models.EmptyEvent{}},
+	{models.EventTypeMessage, "会話に新しいメッセージが保存された", models.MessageEvent{}},
+	{models.EventTypeMessageDelta, "アバターが書いている途中の応答の一部。同じ run_id の delta を順に連結する", models.MessageDeltaEvent{}},
+	{models.EventTypeMessageComplete, "ストリーミングした応答が終わった。saved なら message_id のメッセージで置き換え、failed なら破棄する", models.MessageCompleteEvent{}},
+	{models.EventTypeTyping, "ユーザがWebSocketクライアントで入力している。数秒間入力中の表示を出す", models.TypingEvent{}},
+	{models.EventTypeAvatarJoined, "アバターが会話に参加した", models.AvatarJoinedEvent{}},
+	{models.EventTypeAvatarLeft, "アバターが会話から退室した", models.AvatarLeftEvent{}},
+	{models.EventTypeInterrupt, "ユーザが会話を中断した", models.EmptyEvent{}},
+	{models.EventTypeRunFailed, "アバターの実行が最大実行時間を超えて打ち切られた", models.RunFailedEvent{}},
+	{models.EventTypeWaitingForUser, "アバター同士の発言が続いたため、ユーザの発言まで応答を止めた", models.WaitingForUserEvent{}},
+	{models.EventTypeMessagesImported, "メッセージがまとめて挿入された。クライアントはメッセージ一覧を取得し直す", models.MessagesImportedEvent{}},
+	{models.EventTypeSuggestions, "会話が落ち着いた後にユーザへ提案する返信", suggest.Suggestions{}},
+	{models.EventTypeViewerCount, "会話の視聴者数が変化した", models.ViewerCountEvent{}},
+	{models.EventTypeLLMUnavailable, "OpenAI APIが利用できなくなった", models.LLMUnavailableEvent{}},
+	{models.EventTypeLLMAvailable, "OpenAI APIが再び利用できるようになった", models.EmptyEvent{}},
+	{models.EventTypeResync, "再接続時に切断中のイベントを再送できなかった。クライアントはメッセージ一覧を取得し直す", models.EmptyEvent{}},
+	{models.EventTypeServerShutdown, "サーバーが停止する。クライアントは retry_ms 後に再接続する", models.ServerShutdownEvent{}},
+	{models.EventTypePresence, "ユーザが会話を見ている (viewing) か離れている (away) かが変化した", models.PresenceEvent{}},
+	{models.EventTypeConversationExpired, "有効期限を過ぎた会話がまもなく削除される。すべての会話のクライアントに送信する", models.ConversationExpiredEvent{}},
+}
+
+// EventSchemas は登録済みのすべてのイベントタイプのスキーマを返す
+func EventSchemas() []EventSchema {
+	schemas := make([]EventSchema, len(eventPayloads))
+	for i, p := range eventPayloads {
+		schemas[i] = EventSchema{
+			Type:        p.eventType,
+			Description: p.description,
+			History:     historyEventTypes[p.eventType],
+			Schema:      jsonSchema(reflect.TypeOf(p.payload)),
+		}
+	}
+	return schemas
+}
+
+// HandleSchema は GET /api/events/schema を処理する
+// フロントエンドとの間でペイロードの形がずれないよう、すべてのイベントタイプのスキーマを返す
+func (h *ConversationEventsHandler) HandleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(EventSchemas())
+}
+
+// timeType は文字列として出力される time.Time の型
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema はGoの型からJSON Schemaを作成する
+// 構造体はjsonタグに従い、omitempty やポインタでないフィールドを必須とする
+func jsonSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		properties := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchema(field.Type)
+			if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	// any などの型は制約しない
+	return map[string]any{}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/suggest"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// validateSchema checks a decoded JSON value against a schema built by jsonSchema
+func validateSchema(schema map[string]any, value any, path string) error {
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", path, value)
+		}
+		properties, ok := schema["properties"].(map[string]any)
+		if !ok {
+			items, _ := schema["additionalProperties"].(map[string]any)
+			for key, v := range obj {
+				if err := validateSchema(items, v, path+"."+key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, name := range schema["required"].([]string) {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		for key, v := range obj {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: unexpected field %q", path, key)
+			}
+			if err := validateSchema(property, v, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", path, value)
+		}
+		for i, v := range arr {
+			if err := validateSchema(schema["items"].(map[string]any), v, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected a string, got %T", path, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected an integer, got %v", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected a number, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", path, value)
+		}
+	}
+	return nil
+}
+
+func TestEventSchemas_ContractWithStream(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	conv, avatars := testutil.NewTestConversationWithAvatars(t, database, "Alice")
+	msg := testutil.NewTestMessageSeries(t, database, conv.ID, avatars[0], "hello")[0]
+	msg.Citations = []models.MessageCitation{{Type: "file_citation", Marker: "[1]", FileID: "file_1", Quote: "q", EndIndex: 3}}
+
+	router := NewRouter(database, nil, "", nil)
+	broadcaster := router.GetBroadcaster()
+	broadcaster.SetOverflow(32, OverflowDropOldest)
+	broadcaster.SetViewerCountEvents(true)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	// An ID from before the server started cannot be replayed, so the stream starts with resync
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/conversations/"+strconv.FormatInt(conv.ID, 10)+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Read events until the stream is closed by the shutdown
+	type wireEvent struct{ eventType, data string }
+	events := make(chan wireEvent, 64)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current wireEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				current.eventType = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				current.data = strings.TrimPrefix(line, "data: ")
+			case line == "":
+				events <- current
+				current = wireEvent{}
+			}
+		}
+	}()
+
+	if first := <-events; first.eventType != models.EventTypeConnected {
+		t.Fatalf("expected the connected event first, got %+v", first)
+	}
+
+	event := models.NewMessageEvent(msg)
+	event.SenderName = avatars[0].Name
+	event.Silent = true
+	broadcaster.BroadcastMessage(conv.ID, event)
+	broadcaster.BroadcastMessageDelta(conv.ID, avatars[0].ID, "Alice", "run_1", "hel")
+	broadcaster.BroadcastMessageComplete(conv.ID, avatars[0].ID, "run_1", &msg.ID)
+	broadcaster.BroadcastMessageComplete(conv.ID, avatars[0].ID, "run_2", nil)
+	broadcaster.BroadcastTyping(conv.ID)
+	broadcaster.BroadcastAvatarJoinedWithDisplay(conv.ID, avatars[0].ID, "Alice", "#112233", "🦊")
+	broadcaster.BroadcastAvatarLeft(conv.ID, avatars[0].ID)
+	broadcaster.BroadcastInterrupt(conv.ID)
+	broadcaster.BroadcastRunFailed(conv.ID, avatars[0].ID, "run_1", "timeout")
+	broadcaster.BroadcastWaitingForUser(conv.ID, 5)
+	broadcaster.BroadcastMessagesImported(conv.ID, 2, 3, 4)
+	broadcaster.BroadcastSuggestions(conv.ID, &suggest.Suggestions{ConversationID: conv.ID, MessageID: msg.ID, Suggestions: []string{"Go on"}, CreatedAt: time.Now()})
+	broadcaster.BroadcastLLMAvailability(true, time.Minute)
+	broadcaster.BroadcastLLMAvailability(false, 0)
+	broadcaster.BroadcastPresence(conv.ID, models.PresenceAway, time.Now().Format(time.RFC3339))
+	broadcaster.BroadcastConversationExpired(conv.ID, "Demo")
+	broadcaster.Shutdown(time.Second)
+
+	schemas := map[string]map[string]any{}
+	for _, s := range EventSchemas() {
+		schemas[s.Type] = s.Schema
+	}
+
+	seen := map[string]bool{models.EventTypeConnected: true}
+	for e := range events {
+		schema, ok := schemas[e.eventType]
+		if !ok {
+			t.Errorf("event type %q has no registered schema", e.eventType)
+			continue
+		}
+		var value any
+		if err := json.Unmarshal([]byte(e.data), &value); err != nil {
+			t.Errorf("%s: invalid JSON %q: %v", e.eventType, e.data, err)
+			continue
+		}
+		if err := validateSchema(schema, value, e.eventType); err != nil {
+			t.Errorf("payload does not match its schema: %v (data %s)", err, e.data)
+		}
+		seen[e.eventType] = true
+	}
+
+	for eventType := range schemas {
+		if !seen[eventType] {
+			t.Errorf("registered event type %q was not sent by the stream", eventType)
+		}
+	}
+}
+
+func TestEventSchemas_RejectsDrift(t *testing.T) {
+	schema := jsonSchema(reflect.TypeOf(models.AvatarLeftEvent{}))
+
+	var missing, unknown, wrongType any
+	json.Unmarshal([]byte(`{}`), &missing)
+	json.Unmarshal([]byte(`{"avatar_id": 1, "name": "Alice"}`), &unknown)
+	json.Unmarshal([]byte(`{"avatar_id": "1"}`), &wrongType)
+
+	for name, value := range map[string]any{"missing": missing, "unknown": unknown, "wrong type": wrongType} {
+		if err := validateSchema(schema, value, "avatar_left"); err == nil {
+			t.Errorf("%s: expected the payload to be rejected", name)
+		}
+	}
+}
+
+func TestHandleSchema(t *testing.T) {
+	handler := NewConversationEventsHandler(NewEventBroadcaster())
+
+	w := httptest.NewRecorder()
+	handler.HandleSchema(w, httptest.NewRequest(http.MethodGet, "/api/events/schema", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var schemas []EventSchema
+	if err := json.NewDecoder(w.Body).Decode(&schemas); err != nil {
+		t.Fatalf("failed to decode schemas: %v", err)
+	}
+
+	byType := map[string]EventSchema{}
+	for _, s := range schemas {
+		byType[s.Type] = s
+	}
+	if len(byType) != len(eventPayloads) {
+		t.Errorf("expected %d distinct event types, got %d", len(eventPayloads), len(byType))
+	}
+	for eventType := range historyEventTypes {
+		if !byType[eventType].History {
+			t.Errorf("expected %q to be registered as a history event", eventType)
+		}
+	}
+
+	message := byType[models.EventTypeMessage].Schema
+	required, _ := message["required"].([]any)
+	if len(required) == 0 || message["properties"].(map[string]any)["sender_id"] == nil {
+		t.Errorf("unexpected message schema: %+v", message)
+	}
+	for _, name := range required {
+		if name == "sender_id" || name == "citations" {
+			t.Errorf("expected optional field %v not to be required", name)
+		}
+	}
+}
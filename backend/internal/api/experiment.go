@@ -0,0 +1,247 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	defaultComparisonLimit = 50
+	maxComparisonLimit     = 500
+
+	// comparisonPreferenceTie records that neither response of a comparison was better
+	comparisonPreferenceTie = "tie"
+)
+
+// ExperimentHandler handles HTTP requests for model comparison experiments
+type ExperimentHandler struct {
+	db *db.DB
+}
+
+// NewExperimentHandler creates a new experiment handler
+func NewExperimentHandler(database *db.DB) *ExperimentHandler {
+	return &ExperimentHandler{db: database}
+}
+
+// SetExperimentRequest is the request body of PUT /api/admin/experiments/{avatar_id}
+type SetExperimentRequest struct {
+	Model     string `json:"model"`
+	Broadcast string `json:"broadcast"`
+}
+
+// PreferenceRequest is the request body of POST /api/admin/experiments/comparisons/{id}/preference
+type PreferenceRequest struct {
+	Preferred string `json:"preferred"`
+}
+
+// List handles GET /api/admin/experiments
+func (h *ExperimentHandler) List(w http.ResponseWriter, r *http.Request) {
+	experiments, err := h.db.GetResponseExperiments()
+	if err != nil {
+		log.Printf("[API] ListExperiments failed: DB error err=%v", err)
+		http.Error(w, "Failed to list experiments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(experiments)
+}
+
+// Set handles PUT /api/admin/experiments/{avatar_id}
+// Starts or changes the avatar's experiment; broadcast defaults to primary
+func (h *ExperimentHandler) Set(w http.ResponseWriter, r *http.Request) {
+	avatarID, err := strconv.ParseInt(r.PathValue("avatar_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	var req SetExperimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	switch req.Broadcast {
+	case "":
+		req.Broadcast = models.ExperimentVariantPrimary
+	case models.ExperimentVariantPrimary, models.ExperimentVariantComparison:
+	default:
+		http.Error(w, "Invalid broadcast (must be primary or comparison)", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetAvatar(avatarID); err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	var before *models.ResponseExperiment
+	if existing, err := h.db.GetResponseExperiment(avatarID); err == nil {
+		before = existing
+	} else if err != sql.ErrNoRows {
+		http.Error(w, "Failed to get experiment", http.StatusInternalServerError)
+		return
+	}
+
+	experiment, err := h.db.SetResponseExperiment(avatarID, req.Model, req.Broadcast)
+	if err != nil {
+		http.Error(w, "Failed to set experiment", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Experiment set avatar_id=%d model=%s broadcast=%s", avatarID, experiment.Model, experiment.Broadcast)
+	recordAudit(h.db, r, models.AuditActionExperimentUpdate, "avatar", strconv.FormatInt(avatarID, 10),
+		experimentAuditState(before), experimentAuditState(experiment))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(experiment)
+}
+
+// Delete handles DELETE /api/admin/experiments/{avatar_id}
+// Recorded comparisons are kept for review
+func (h *ExperimentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	avatarID, err := strconv.ParseInt(r.PathValue("avatar_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.db.GetResponseExperiment(avatarID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Experiment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get experiment", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.DeleteResponseExperiment(avatarID); err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to delete experiment", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Experiment deleted avatar_id=%d", avatarID)
+	recordAudit(h.db, r, models.AuditActionExperimentDelete, "avatar", strconv.FormatInt(avatarID, 10),
+		experimentAuditState(before), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// experimentAuditState is the audited part of an experiment, nil when there is none
+func experimentAuditState(e *models.ResponseExperiment) any {
+	if e == nil {
+		return nil
+	}
+	return map[string]string{"model": e.Model, "broadcast": e.Broadcast}
+}
+
+// ListComparisons handles GET /api/admin/experiments/comparisons
+// Filters: conversation_id, avatar_id, limit
+func (h *ExperimentHandler) ListComparisons(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var ids [2]int64
+	for i, name := range []string{"conversation_id", "avatar_id"} {
+		if v := query.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			ids[i] = id
+		}
+	}
+
+	limit := defaultComparisonLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxComparisonLimit)
+	}
+
+	comparisons, err := h.db.GetResponseComparisons(ids[0], ids[1], limit)
+	if err != nil {
+		log.Printf("[API] ListComparisons failed: DB error err=%v", err)
+		http.Error(w, "Failed to list comparisons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparisons)
+}
+
+// GetComparison handles GET /api/admin/experiments/comparisons/{id}
+func (h *ExperimentHandler) GetComparison(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid comparison ID", http.StatusBadRequest)
+		return
+	}
+
+	comparison, err := h.db.GetResponseComparison(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comparison not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get comparison", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// SetPreference handles POST /api/admin/experiments/comparisons/{id}/preference
+// preferred is primary, comparison or tie; a later verdict replaces an earlier one
+func (h *ExperimentHandler) SetPreference(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid comparison ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PreferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch req.Preferred {
+	case models.ExperimentVariantPrimary, models.ExperimentVariantComparison, comparisonPreferenceTie:
+	default:
+		http.Error(w, "Invalid preferred (must be primary, comparison or tie)", http.StatusBadRequest)
+		return
+	}
+
+	comparison, err := h.db.SetComparisonPreference(id, req.Preferred)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comparison not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set preference", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestExperimentHandler(t *testing.T) (*ExperimentHandler, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	return NewExperimentHandler(database), database
+}
+
+func TestExperimentHandler_SetAndDelete(t *testing.T) {
+	handler, database := setupTestExperimentHandler(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+
+	set := func(avatarID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/experiments/"+avatarID, strings.NewReader(body))
+		req.SetPathValue("avatar_id", avatarID)
+		w := httptest.NewRecorder()
+		handler.Set(w, req)
+		return w
+	}
+
+	w := set("1", `{"model": "gpt-test"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var experiment models.ResponseExperiment
+	json.NewDecoder(w.Body).Decode(&experiment)
+	if experiment.AvatarID != avatar.ID || experiment.Model != "gpt-test" || experiment.Broadcast != models.ExperimentVariantPrimary {
+		t.Errorf("expected the primary to be broadcast by default, got %+v", experiment)
+	}
+
+	invalid := []struct {
+		avatarID string
+		body     string
+		want     int
+	}{
+		{"1", `{"model": " "}`, http.StatusBadRequest},
+		{"1", `{"model": "gpt-test", "broadcast": "both"}`, http.StatusBadRequest},
+		{"abc", `{"model": "gpt-test"}`, http.StatusBadRequest},
+		{"999", `{"model": "gpt-test"}`, http.StatusNotFound},
+	}
+	for _, tt := range invalid {
+		if w := set(tt.avatarID, tt.body); w.Code != tt.want {
+			t.Errorf("PUT %s %s: expected status %d, got %d", tt.avatarID, tt.body, tt.want, w.Code)
+		}
+	}
+
+	set("1", `{"model": "gpt-other", "broadcast": "comparison"}`)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/experiments", nil)
+	w = httptest.NewRecorder()
+	handler.List(w, req)
+	var experiments []models.ResponseExperiment
+	json.NewDecoder(w.Body).Decode(&experiments)
+	if len(experiments) != 1 || experiments[0].Model != "gpt-other" || experiments[0].Broadcast != models.ExperimentVariantComparison {
+		t.Errorf("expected the updated experiment, got %+v", experiments)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/admin/experiments/1", nil)
+		req.SetPathValue("avatar_id", "1")
+		w := httptest.NewRecorder()
+		handler.Delete(w, req)
+		if w.Code != want {
+			t.Errorf("expected status %d, got %d", want, w.Code)
+		}
+	}
+
+	entries, _ := database.GetAuditEntries(models.AuditFilter{TargetType: "avatar", TargetID: "1", Limit: 10})
+	if len(entries) != 3 || entries[0].Action != models.AuditActionExperimentDelete ||
+		entries[1].Changes["model"].To != "gpt-other" || entries[2].Action != models.AuditActionExperimentUpdate {
+		t.Errorf("expected the experiment changes to be audited, got %+v", entries)
+	}
+}
+
+func TestExperimentHandler_Comparisons(t *testing.T) {
+	handler, database := setupTestExperimentHandler(t)
+
+	conv, _ := database.CreateConversation("Experiment", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	trigger, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	comparison, _ := database.CreateResponseComparison(&models.ResponseComparison{
+		ConversationID:   conv.ID,
+		AvatarID:         avatar.ID,
+		TriggerMessageID: trigger.ID,
+		Broadcast:        models.ExperimentVariantPrimary,
+		Primary:          models.ResponseCandidate{Model: "gpt-a", Content: "one"},
+		Comparison:       models.ResponseCandidate{Model: "gpt-b", Content: "two"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/experiments/comparisons?conversation_id=1&limit=5", nil)
+	w := httptest.NewRecorder()
+	handler.ListComparisons(w, req)
+	var comparisons []models.ResponseComparison
+	json.NewDecoder(w.Body).Decode(&comparisons)
+	if len(comparisons) != 1 || comparisons[0].Comparison.Content != "two" {
+		t.Errorf("expected the comparison, got %+v", comparisons)
+	}
+
+	for _, query := range []string{"conversation_id=x", "avatar_id=0", "limit=-1"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/experiments/comparisons?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ListComparisons(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	prefer := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/experiments/comparisons/"+id+"/preference", strings.NewReader(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.SetPreference(w, req)
+		return w
+	}
+	if w := prefer("1", `{"preferred": "comparison"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := prefer("1", `{"preferred": "neither"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid preference, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := prefer("999", `{"preferred": "tie"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/experiments/comparisons/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.GetComparison(w, req)
+	var got models.ResponseComparison
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.ID != comparison.ID || got.Preferred != models.ExperimentVariantComparison {
+		t.Errorf("expected the reviewed comparison, got %+v", got)
+	}
+}
@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// maxGallerySlugLength bounds the slug a conversation is published under
+const maxGallerySlugLength = 64
+
+// gallerySlugPattern matches lowercase words joined by single hyphens, such as "launch-demo-2024"
+var gallerySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// PublishRequest is the body of PUT /api/conversations/{id}/gallery
+type PublishRequest struct {
+	// Slug is the path of the transcript under /gallery/; empty keeps the current slug or derives one from the title
+	Slug string `json:"slug"`
+}
+
+// Publish handles PUT /api/conversations/{id}/gallery
+// Shows the conversation as a read-only transcript at /gallery/{slug}
+func (h *ConversationHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PublishRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	slug := strings.TrimSpace(req.Slug)
+	if slug != "" && (len(slug) > maxGallerySlugLength || !gallerySlugPattern.MatchString(slug)) {
+		http.Error(w, "Invalid slug (lowercase letters, digits and single hyphens, at most 64 characters)", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	derived := slug == "" && existing.GallerySlug == ""
+	switch {
+	case slug == "" && existing.GallerySlug != "":
+		slug = existing.GallerySlug
+	case derived:
+		slug = gallerySlugFromTitle(existing.Title, id)
+	}
+
+	updated, err := h.db.PublishConversation(id, slug)
+	if err == db.ErrDuplicate && derived {
+		// Another conversation has the same title; the ID tells them apart
+		suffix := "-" + strconv.FormatInt(id, 10)
+		slug = strings.TrimSuffix(slug[:min(len(slug), maxGallerySlugLength-len(suffix))], "-") + suffix
+		updated, err = h.db.PublishConversation(id, slug)
+	}
+	if err == db.ErrDuplicate {
+		http.Error(w, "Another conversation is already published under this slug", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] Publish conversation failed: DB error conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to publish conversation", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditActionConversationPublish, "conversation", strconv.FormatInt(id, 10),
+		galleryAuditFields(existing), galleryAuditFields(updated))
+
+	log.Printf("[API] Publish conversation completed conversation_id=%d slug=%s", id, updated.GallerySlug)
+	setVersionHeaders(w, updated.UpdatedAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newConversationResponse(updated))
+}
+
+// Unpublish handles DELETE /api/conversations/{id}/gallery
+// The slug is kept, so publishing the conversation again restores the same link
+func (h *ConversationHandler) Unpublish(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := h.db.UnpublishConversation(id)
+	if err != nil {
+		log.Printf("[API] Unpublish conversation failed: DB error conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to unpublish conversation", http.StatusInternalServerError)
+		return
+	}
+
+	if existing.Published {
+		recordAudit(h.db, r, models.AuditActionConversationUnpublish, "conversation", strconv.FormatInt(id, 10),
+			galleryAuditFields(existing), galleryAuditFields(updated))
+	}
+
+	log.Printf("[API] Unpublish conversation completed conversation_id=%d", id)
+	setVersionHeaders(w, updated.UpdatedAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newConversationResponse(updated))
+}
+
+// galleryAuditFields are the audited fields of a publish or unpublish
+func galleryAuditFields(conv *models.Conversation) map[string]any {
+	return map[string]any{"published": conv.Published, "gallery_slug": conv.GallerySlug}
+}
+
+// gallerySlugFromTitle derives a slug from a conversation title
+// Characters other than ASCII letters and digits become hyphens; a title without any uses the conversation ID
+func gallerySlugFromTitle(title string, id int64) string {
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(title) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+		if b.Len() >= maxGallerySlugLength {
+			break
+		}
+	}
+	slug := strings.TrimSuffix(b.String()[:min(b.Len(), maxGallerySlugLength)], "-")
+	if slug == "" {
+		return "conversation-" + strconv.FormatInt(id, 10)
+	}
+	return slug
+}
+
+// GalleryHandler serves published conversations as read-only HTML pages
+// The pages need no authentication, so finished demo sessions can be shared as links
+type GalleryHandler struct {
+	db *db.DB
+}
+
+// NewGalleryHandler creates a new gallery handler
+func NewGalleryHandler(database *db.DB) *GalleryHandler {
+	return &GalleryHandler{db: database}
+}
+
+// galleryEntry is a published conversation on the gallery index
+type galleryEntry struct {
+	Slug      string
+	Title     string
+	CreatedAt time.Time
+}
+
+// galleryParticipant is an avatar shown in the header of a transcript
+type galleryParticipant struct {
+	Name         string
+	Emoji        string
+	Color        string
+	MessageCount int
+}
+
+// galleryMessage is one message of a transcript
+type galleryMessage struct {
+	SenderType models.SenderType
+	SenderName string
+	Emoji      string
+	Color      string
+	Model      string
+	Content    string
+	CreatedAt  time.Time
+}
+
+// galleryTranscript is the data of a transcript page
+type galleryTranscript struct {
+	Title        string
+	CreatedAt    time.Time
+	Disclosure   string
+	Participants []galleryParticipant
+	Messages     []galleryMessage
+}
+
+// Index handles GET /gallery
+func (h *GalleryHandler) Index(w http.ResponseWriter, r *http.Request) {
+	conversations, err := h.db.GetPublishedConversations()
+	if err != nil {
+		log.Printf("[Gallery] Index failed: DB error err=%v", err)
+		http.Error(w, "Failed to get conversations", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]galleryEntry, len(conversations))
+	for i, conv := range conversations {
+		entries[i] = galleryEntry{Slug: conv.GallerySlug, Title: conv.Title, CreatedAt: conv.CreatedAt}
+	}
+	renderGalleryPage(w, galleryIndexTemplate, entries)
+}
+
+// Transcript handles GET /gallery/{slug}
+func (h *GalleryHandler) Transcript(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	conv, err := h.db.GetPublishedConversation(slug)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("[Gallery] Transcript failed: DB error getting conversation slug=%s err=%v", slug, err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	messages, err := h.db.GetMessages(conv.ID)
+	if err != nil {
+		log.Printf("[Gallery] Transcript failed: DB error getting messages conversation_id=%d err=%v", conv.ID, err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+	messageModels, err := h.db.GetConversationMessageModels(conv.ID)
+	if err != nil {
+		log.Printf("[Gallery] Transcript failed: DB error getting message models conversation_id=%d err=%v", conv.ID, err)
+		http.Error(w, "Failed to get message models", http.StatusInternalServerError)
+		return
+	}
+	members, err := h.db.GetConversationAvatars(conv.ID)
+	if err != nil {
+		log.Printf("[Gallery] Transcript failed: DB error getting avatars conversation_id=%d err=%v", conv.ID, err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+
+	page := galleryTranscript{Title: conv.Title, CreatedAt: conv.CreatedAt, Messages: make([]galleryMessage, 0, len(messages))}
+
+	// Avatars that left the conversation are still shown for the messages they wrote
+	avatars := make(map[int64]*models.Avatar, len(members))
+	index := make(map[int64]int, len(members))
+	for i := range members {
+		avatars[members[i].ID] = &members[i]
+		index[members[i].ID] = len(page.Participants)
+		page.Participants = append(page.Participants, galleryParticipant{
+			Name: members[i].Name, Emoji: members[i].Emoji, Color: members[i].Color,
+		})
+	}
+
+	var writers []string
+	seenModels := make(map[string]bool)
+	var usedModels []string
+	for _, msg := range messages {
+		item := galleryMessage{SenderType: msg.SenderType, Content: msg.Content, CreatedAt: msg.CreatedAt}
+		switch {
+		case msg.SenderType == models.SenderTypeUser:
+			item.SenderName = "User"
+		case msg.SenderType == models.SenderTypeSystem:
+			item.SenderName = "System"
+		case msg.SenderID != nil:
+			avatar, ok := avatars[*msg.SenderID]
+			if !ok {
+				avatar, err = h.db.GetAvatar(*msg.SenderID)
+				if err != nil {
+					avatar = &models.Avatar{ID: *msg.SenderID}
+				}
+				avatars[avatar.ID] = avatar
+				index[avatar.ID] = len(page.Participants)
+				page.Participants = append(page.Participants, galleryParticipant{Name: avatar.Name, Emoji: avatar.Emoji, Color: avatar.Color})
+			}
+			item.SenderName, item.Emoji, item.Color = avatar.Name, avatar.Emoji, avatar.Color
+			item.Model = messageModels[msg.ID]
+
+			p := &page.Participants[index[avatar.ID]]
+			if p.MessageCount == 0 && p.Name != "" {
+				writers = append(writers, p.Name)
+			}
+			p.MessageCount++
+			if item.Model != "" && !seenModels[item.Model] {
+				seenModels[item.Model] = true
+				usedModels = append(usedModels, item.Model)
+			}
+		}
+		page.Messages = append(page.Messages, item)
+	}
+	if len(writers) > 0 {
+		sort.Strings(usedModels)
+		page.Disclosure = logic.FormatAIDisclosure(writers, usedModels)
+	}
+
+	renderGalleryPage(w, galleryTranscriptTemplate, page)
+}
+
+// renderGalleryPage writes a gallery page, or a 500 if the template fails before anything was written
+func renderGalleryPage(w http.ResponseWriter, tmpl *template.Template, data any) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		log.Printf("[Gallery] Failed to render page template=%s err=%v", tmpl.Name(), err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	b.WriteTo(w)
+}
+
+// galleryStyle is shared by the gallery pages
+const galleryStyle = `<style>
+body { font-family: system-ui, sans-serif; max-width: 760px; margin: 2rem auto; padding: 0 1rem; color: #222; }
+header { border-bottom: 1px solid #ddd; margin-bottom: 1.5rem; }
+.meta, .disclosure { color: #666; font-size: 0.9rem; }
+.participants { list-style: none; padding: 0; display: flex; flex-wrap: wrap; gap: 0.5rem; }
+.participants li { border-left: 4px solid #999; padding: 0 0.5rem; }
+.message { border-left: 4px solid #ccc; padding: 0.25rem 0.75rem; margin: 0 0 1rem; }
+.message.user { border-left-color: #333; background: #f6f6f6; }
+.message.system { font-style: italic; color: #666; }
+.sender { font-weight: bold; }
+.content { white-space: pre-wrap; margin: 0.25rem 0 0; }
+</style>`
+
+var galleryIndexTemplate = template.Must(template.New("gallery_index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Gallery</title>
+` + galleryStyle + `
+</head>
+<body>
+<header><h1>Gallery</h1></header>
+{{if .}}<ul>
+{{range .}}<li><a href="/gallery/{{.Slug}}">{{.Title}}</a> <span class="meta">{{.CreatedAt.Format "2006-01-02"}}</span></li>
+{{end}}</ul>
+{{else}}<p class="meta">No conversations have been published yet.</p>
+{{end}}</body>
+</html>
+`))
+
+var galleryTranscriptTemplate = template.Must(template.New("gallery_transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+` + galleryStyle + `
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">Started {{.CreatedAt.Format "2006-01-02 15:04"}} UTC · {{len .Messages}} messages · <a href="/gallery">Gallery</a></p>
+{{if .Participants}}<ul class="participants">
+{{range .Participants}}<li style="border-left-color: {{.Color}}">{{.Emoji}} {{.Name}} <span class="meta">{{.MessageCount}} messages</span></li>
+{{end}}</ul>
+{{end}}{{if .Disclosure}}<p class="disclosure">{{.Disclosure}}</p>
+{{end}}</header>
+<main>
+{{range .Messages}}<article class="message {{.SenderType}}"{{if .Color}} style="border-left-color: {{.Color}}"{{end}}>
+<div><span class="sender">{{if .Emoji}}{{.Emoji}} {{end}}{{.SenderName}}</span> <time class="meta" datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.Format "15:04"}}</time>{{if .Model}} <span class="meta">{{.Model}}</span>{{end}}</div>
+<p class="content">{{.Content}}</p>
+</article>
+{{end}}</main>
+</body>
+</html>
+`))
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestPublishConversation(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Launch Demo: Q&A", "")
+	twin, _ := handler.db.CreateConversation("Launch demo q a", "")
+
+	publish := func(id int64, body string) (*httptest.ResponseRecorder, ConversationResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/api/conversations/"+strconv.FormatInt(id, 10)+"/gallery", bytes.NewBufferString(body))
+		req.SetPathValue("id", strconv.FormatInt(id, 10))
+		w := httptest.NewRecorder()
+		handler.Publish(w, req)
+		var response ConversationResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// Without a slug, the slug is derived from the title
+	w, response := publish(conv.ID, "")
+	if w.Code != http.StatusOK || !response.Published || response.GallerySlug != "launch-demo-q-a" {
+		t.Fatalf("expected the conversation to be published as launch-demo-q-a, got %d %+v", w.Code, response)
+	}
+
+	// A derived slug that is taken gets the conversation ID
+	if _, response := publish(twin.ID, ""); response.GallerySlug != "launch-demo-q-a-"+strconv.FormatInt(twin.ID, 10) {
+		t.Errorf("expected the ID to be appended to a taken slug, got %q", response.GallerySlug)
+	}
+
+	// A requested slug that is taken is a conflict
+	if w, _ := publish(twin.ID, `{"slug": "launch-demo-q-a"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	for _, slug := range []string{"Has Spaces", "trailing-", "a--b", strings.Repeat("a", maxGallerySlugLength+1)} {
+		if w, _ := publish(conv.ID, `{"slug": "`+slug+`"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for slug %q, got %d", http.StatusBadRequest, slug, w.Code)
+		}
+	}
+	if w, _ := publish(999, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Unpublishing keeps the slug
+	req := httptest.NewRequest(http.MethodDelete, "/api/conversations/"+strconv.FormatInt(conv.ID, 10)+"/gallery", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w = httptest.NewRecorder()
+	handler.Unpublish(w, req)
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusOK || response.Published || response.GallerySlug != "launch-demo-q-a" {
+		t.Errorf("expected the conversation to be unpublished with its slug, got %d %+v", w.Code, response)
+	}
+
+	entries, _ := handler.db.GetAuditEntries(models.AuditFilter{Action: models.AuditActionConversationPublish, Limit: 10})
+	if len(entries) != 2 {
+		t.Errorf("expected both publishes to be audited, got %d entries", len(entries))
+	}
+}
+
+func TestGalleryTranscript(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+	gallery := NewGalleryHandler(handler.db)
+
+	conv, _ := handler.db.CreateConversation("Public <demo>", "")
+	hidden, _ := handler.db.CreateConversation("Hidden", "")
+	alice, _ := handler.db.CreateAvatar("Alice", "secret prompt", "asst_1")
+	handler.db.AddAvatarToConversation(conv.ID, alice.ID)
+	handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hello <script>alert(1)</script>")
+	reply, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Hi there")
+	handler.db.RecordMessageModel(reply.ID, "gpt-4o")
+	handler.db.PublishConversation(conv.ID, "public-demo")
+	handler.db.PublishConversation(hidden.ID, "hidden")
+	handler.db.UnpublishConversation(hidden.ID)
+
+	get := func(slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/gallery/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		gallery.Transcript(w, req)
+		return w
+	}
+
+	w := get("public-demo")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected an HTML page, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	page := w.Body.String()
+	for _, want := range []string{"Public &lt;demo&gt;", "Hello &lt;script&gt;", "Alice", "Hi there", "gpt-4o", "AI-generated content"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the transcript to contain %q", want)
+		}
+	}
+	for _, secret := range []string{"<script>alert", "secret prompt", "asst_1"} {
+		if strings.Contains(page, secret) {
+			t.Errorf("expected the transcript not to contain %q", secret)
+		}
+	}
+
+	if w := get("hidden"); w.Code != http.StatusNotFound {
+		t.Errorf("expected an unpublished conversation to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	gallery.Index(w, httptest.NewRequest(http.MethodGet, "/gallery", nil))
+	if index := w.Body.String(); !strings.Contains(index, `href="/gallery/public-demo"`) || strings.Contains(index, "Hidden") {
+		t.Errorf("expected the index to list only published conversations, got %s", index)
+	}
+}
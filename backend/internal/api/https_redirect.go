@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net"
+	"net/http"
+)
+
+// HTTPSRedirectHandler redirects plain HTTP requests to the same URL over HTTPS
+// httpsPort is the port of the HTTPS listener; it is left out of the URL when it is 443
+func HTTPSRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + req.URL.RequestURI()
+
+		// 308 keeps the method and body of non-GET requests
+		status := http.StatusMovedPermanently
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, req, target, status)
+	})
+}
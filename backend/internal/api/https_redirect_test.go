@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		method     string
+		target     string
+		host       string
+		httpsPort  string
+		wantStatus int
+		wantURL    string
+	}{
+		{http.MethodGet, "/conversations/1?tab=log", "chat.example.com", "443", http.StatusMovedPermanently, "https://chat.example.com/conversations/1?tab=log"},
+		{http.MethodGet, "/", "chat.example.com:80", "443", http.StatusMovedPermanently, "https://chat.example.com/"},
+		{http.MethodGet, "/", "localhost:8080", "8443", http.StatusMovedPermanently, "https://localhost:8443/"},
+		{http.MethodPost, "/api/conversations", "chat.example.com", "443", http.StatusPermanentRedirect, "https://chat.example.com/api/conversations"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		HTTPSRedirectHandler(tt.httpsPort).ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.wantStatus, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != tt.wantURL {
+			t.Errorf("%s %s: expected Location %q, got %q", tt.method, tt.target, tt.wantURL, got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+// Job types run by the API
+const (
+	JobTypeDigest       = "digest"
+	JobTypeImportThread = "import_thread"
+)
+
+// JobHandler handles background job HTTP requests
+type JobHandler struct {
+	db *db.DB
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(database *db.DB) *JobHandler {
+	return &JobHandler{db: database}
+}
+
+// JobResponse represents a background job in API responses
+// Result holds the response the operation would have returned synchronously
+type JobResponse struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Status      string          `json:"status"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	StartedAt   string          `json:"started_at,omitempty"`
+	CompletedAt string          `json:"completed_at,omitempty"`
+}
+
+// newJobResponse converts a job model to its API representation
+func newJobResponse(job *models.Job) JobResponse {
+	resp := JobResponse{
+		ID:        job.ID,
+		Type:      job.Type,
+		Status:    job.Status,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+	}
+	if job.Result != "" {
+		resp.Result = json.RawMessage(job.Result)
+	}
+	if job.StartedAt != nil {
+		resp.StartedAt = job.StartedAt.Format(time.RFC3339)
+	}
+	if job.CompletedAt != nil {
+		resp.CompletedAt = job.CompletedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// writeJobAccepted answers 202 Accepted with the job handle of a started operation
+func writeJobAccepted(w http.ResponseWriter, job *models.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+strconv.FormatInt(job.ID, 10))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newJobResponse(job))
+}
+
+// statusError is an operation failure with the HTTP status it maps to
+// When the operation runs as a job, the message becomes the job error
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// writeStatusError writes an error returned by an operation that may also run as a job
+func writeStatusError(w http.ResponseWriter, err error) {
+	if se, ok := err.(*statusError); ok {
+		http.Error(w, se.message, se.status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// openAIStatusError maps a failed OpenAI call to the status and message returned to the client
+// action describes the call, e.g. "Failed to update OpenAI assistant"
+func openAIStatusError(action string, err error) *statusError {
+	switch {
+	case errors.Is(err, assistant.ErrRateLimited), errors.Is(err, assistant.ErrCircuitOpen):
+		return &statusError{http.StatusServiceUnavailable, action + ": OpenAI is busy, try again later"}
+	case errors.Is(err, assistant.ErrAuth):
+		return &statusError{http.StatusBadGateway, action + ": the OpenAI API key was rejected"}
+	case errors.Is(err, assistant.ErrContextLength):
+		return &statusError{http.StatusBadRequest, action + ": the prompt is too long for the model"}
+	case errors.Is(err, assistant.ErrInvalidRequest):
+		return &statusError{http.StatusBadRequest, action + ": " + err.Error()}
+	}
+	return &statusError{http.StatusInternalServerError, action + ": " + err.Error()}
+}
+
+// Get handles GET /api/jobs/{id}
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.db.GetJob(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newJobResponse(job))
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/jobs"
+	"multi-avatar-chat/internal/models"
+)
+
+// getJob fetches a job through the job handler
+func getJob(t *testing.T, handler *JobHandler, id int64) JobResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+strconv.FormatInt(id, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(id, 10))
+	w := httptest.NewRecorder()
+	handler.Get(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp JobResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return resp
+}
+
+// waitForJobResponse polls a job until it succeeds or fails
+func waitForJobResponse(t *testing.T, handler *JobHandler, id int64) JobResponse {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job := getJob(t, handler, id)
+		if job.Status == models.JobStatusSucceeded || job.Status == models.JobStatusFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish", id)
+	return JobResponse{}
+}
+
+// decodeAcceptedJob checks for a 202 response with a job handle
+func decodeAcceptedJob(t *testing.T, w *httptest.ResponseRecorder) JobResponse {
+	t.Helper()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var job JobResponse
+	json.NewDecoder(w.Body).Decode(&job)
+	if location := w.Header().Get("Location"); location != "/api/jobs/"+strconv.FormatInt(job.ID, 10) {
+		t.Errorf("unexpected Location %q for job %d", location, job.ID)
+	}
+	return job
+}
+
+func TestGetJob_NotFound(t *testing.T) {
+	_, database := setupTestDigestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/999", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	NewJobHandler(database).Get(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGenerateDigest_AsJob(t *testing.T) {
+	handler, database := setupTestDigestHandler(t)
+
+	handler.SetJob(digest.NewJob(database, nil, time.Hour))
+	runner := jobs.NewRunner(database, 1)
+	handler.SetJobRunner(runner)
+	runner.Start()
+	defer runner.Stop()
+
+	conv, _ := database.CreateConversation("Planning", "")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/digest", nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.Generate(w, req)
+
+	accepted := decodeAcceptedJob(t, w)
+	if accepted.Type != JobTypeDigest {
+		t.Errorf("expected a digest job, got %+v", accepted)
+	}
+
+	job := waitForJobResponse(t, NewJobHandler(database), accepted.ID)
+	if job.Status != models.JobStatusSucceeded {
+		t.Fatalf("expected the job to succeed, got %+v", job)
+	}
+
+	var resp DigestResponse
+	json.Unmarshal(job.Result, &resp)
+	if resp.ConversationID != conv.ID || resp.MessageCount != 1 {
+		t.Errorf("unexpected digest result: %s", job.Result)
+	}
+}
+
+func TestImportThread_AsJob(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	handler.assistant = newImportAssistantClient(t, make(map[string]string))
+	runner := jobs.NewRunner(handler.db, 1)
+	handler.SetJobRunner(runner)
+	runner.Start()
+	defer runner.Stop()
+
+	importThread := func(threadID string) JobResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/import-thread",
+			bytes.NewBufferString(`{"thread_id": "`+threadID+`"}`))
+		w := httptest.NewRecorder()
+		handler.ImportThread(w, req)
+		return waitForJobResponse(t, NewJobHandler(handler.db), decodeAcceptedJob(t, w).ID)
+	}
+
+	job := importThread("thread_src")
+	if job.Status != models.JobStatusSucceeded {
+		t.Fatalf("expected the job to succeed, got %+v", job)
+	}
+	var resp ImportThreadResponse
+	json.Unmarshal(job.Result, &resp)
+	if resp.ImportedMessages != 3 {
+		t.Errorf("unexpected import result: %s", job.Result)
+	}
+
+	if job := importThread("thread_missing"); job.Status != models.JobStatusFailed || job.Error != "Thread not found" {
+		t.Errorf("expected the job to fail for a missing thread, got %+v", job)
+	}
+}
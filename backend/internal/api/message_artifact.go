@@ -0,0 +1,93 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// ArtifactResponse represents a message artifact in API responses
+// Code and logs carry their text in Content; images are served from URL
+type ArtifactResponse struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Content   string `json:"content,omitempty"`
+	URL       string `json:"url,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// newArtifactResponses converts the artifacts of a message to their API representation
+func newArtifactResponses(conversationID int64, artifacts []models.MessageArtifact) []ArtifactResponse {
+	if len(artifacts) == 0 {
+		return nil
+	}
+
+	response := make([]ArtifactResponse, len(artifacts))
+	for i, a := range artifacts {
+		response[i] = ArtifactResponse{
+			ID:        a.ID,
+			Type:      a.Type,
+			Content:   a.Content,
+			CreatedAt: a.CreatedAt.Format(time.RFC3339),
+		}
+		if a.FileID != "" {
+			response[i].URL = fmt.Sprintf("/api/conversations/%d/artifacts/%d/content", conversationID, a.ID)
+		}
+	}
+	return response
+}
+
+// GetArtifactContent handles GET /api/conversations/{id}/artifacts/{artifact_id}/content
+// The file of an image artifact is downloaded from OpenAI and returned as is
+func (h *ConversationHandler) GetArtifactContent(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	artifactID, err := strconv.ParseInt(r.PathValue("artifact_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid artifact ID", http.StatusBadRequest)
+		return
+	}
+
+	artifact, err := h.db.GetMessageArtifact(conversationID, artifactID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Artifact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] GetArtifactContent failed: DB error getting artifact err=%v", err)
+		http.Error(w, "Failed to get artifact", http.StatusInternalServerError)
+		return
+	}
+
+	if artifact.FileID == "" {
+		http.Error(w, "Artifact has no file content", http.StatusNotFound)
+		return
+	}
+	if h.assistant == nil {
+		http.Error(w, "OpenAI is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	content, contentType, err := h.assistant.WithContext(r.Context()).GetFileContent(artifact.FileID)
+	if err != nil {
+		log.Printf("[API] GetArtifactContent failed: download error artifact_id=%d file_id=%s err=%v",
+			artifactID, artifact.FileID, err)
+		http.Error(w, "Failed to download artifact", http.StatusBadGateway)
+		return
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(content)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// setupArtifactMessage creates a conversation with one assistant message carrying artifacts
+func setupArtifactMessage(t *testing.T, handler *ConversationHandler) (int64, []models.MessageArtifact) {
+	t.Helper()
+
+	conv, err := handler.db.CreateConversation("Artifacts", "")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	msg, err := handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "The mean is 42.")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+
+	artifacts := []models.MessageArtifact{
+		{Type: models.ArtifactTypeCode, Content: "print(42)"},
+		{Type: models.ArtifactTypeImage, FileID: "file-plot"},
+	}
+	if err := handler.db.CreateMessageArtifacts(msg.ID, artifacts); err != nil {
+		t.Fatalf("failed to create artifacts: %v", err)
+	}
+
+	stored, err := handler.db.GetConversationArtifacts(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get artifacts: %v", err)
+	}
+	return conv.ID, stored[msg.ID]
+}
+
+func TestGetMessages_IncludesArtifacts(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	convID, _ := setupArtifactMessage(t, handler)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/conversations/%d/messages", convID), nil)
+	req.SetPathValue("id", strconv.FormatInt(convID, 10))
+	w := httptest.NewRecorder()
+	handler.GetMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response []MessageResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response) != 1 || len(response[0].Artifacts) != 2 {
+		t.Fatalf("expected 1 message with 2 artifacts, got %+v", response)
+	}
+
+	code, image := response[0].Artifacts[0], response[0].Artifacts[1]
+	if code.Type != models.ArtifactTypeCode || code.Content != "print(42)" || code.URL != "" {
+		t.Errorf("unexpected code artifact: %+v", code)
+	}
+	wantURL := fmt.Sprintf("/api/conversations/%d/artifacts/%d/content", convID, image.ID)
+	if image.Type != models.ArtifactTypeImage || image.URL != wantURL {
+		t.Errorf("unexpected image artifact: %+v", image)
+	}
+}
+
+func TestGetArtifactContent(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files/file-plot/content" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "not found"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png-bytes"))
+	}))
+	defer server.Close()
+	handler.assistant = assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+
+	convID, artifacts := setupArtifactMessage(t, handler)
+
+	get := func(convID, artifactID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet,
+			fmt.Sprintf("/api/conversations/%d/artifacts/%d/content", convID, artifactID), nil)
+		req.SetPathValue("id", strconv.FormatInt(convID, 10))
+		req.SetPathValue("artifact_id", strconv.FormatInt(artifactID, 10))
+		w := httptest.NewRecorder()
+		handler.GetArtifactContent(w, req)
+		return w
+	}
+
+	w := get(convID, artifacts[1].ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Content-Type") != "image/png" || w.Body.String() != "png-bytes" {
+		t.Errorf("unexpected content: type=%q body=%q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	// Text artifacts have no file to download
+	if w := get(convID, artifacts[0].ID); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for code artifact, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Artifacts are only reachable through their own conversation
+	if w := get(convID+1, artifacts[1].ID); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for other conversation, got %d", http.StatusNotFound, w.Code)
+	}
+}
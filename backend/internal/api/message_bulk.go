@@ -0,0 +1,284 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// maxBulkMessages bounds the number of messages inserted by one bulk request
+const maxBulkMessages = 1000
+
+// BulkMessage is one historical message of a bulk insert
+type BulkMessage struct {
+	// SenderType is user (the default), avatar or system
+	SenderType string `json:"sender_type,omitempty"`
+	// SenderID is the avatar that wrote an avatar message
+	SenderID *int64 `json:"sender_id,omitempty"`
+	Content  string `json:"content"`
+	// CreatedAt is the original time of the message in RFC 3339; the time of the request when omitted
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// BulkMessagesRequest represents the request body for inserting a batch of messages
+type BulkMessagesRequest struct {
+	Messages []BulkMessage `json:"messages"`
+	// Forward sends the batch to every avatar thread as a single condensed history message
+	Forward bool `json:"forward,omitempty"`
+}
+
+// BulkMessagesResponse reports the messages inserted by a bulk request
+type BulkMessagesResponse struct {
+	Inserted      int   `json:"inserted"`
+	FirstSequence int64 `json:"first_sequence"`
+	LastSequence  int64 `json:"last_sequence"`
+	// Deliveries reports whether the condensed history reached each avatar's thread
+	Deliveries []DeliveryResponse `json:"deliveries,omitempty"`
+	// Redactions counts the values of each kind redacted from the user messages
+	Redactions map[string]int `json:"redactions,omitempty"`
+}
+
+// BulkInsertMessages handles POST /api/conversations/{id}/messages/bulk
+// Inserts an ordered batch of historical messages in one transaction, e.g. a thread imported from a chat tool.
+// Avatars do not react to the messages; with forward set they receive them as context instead
+func (h *ConversationHandler) BulkInsertMessages(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] BulkInsertMessages started")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req BulkMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Messages) == 0 {
+		http.Error(w, "messages is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Messages) > maxBulkMessages {
+		http.Error(w, fmt.Sprintf("Too many messages (at most %d per request)", maxBulkMessages), http.StatusBadRequest)
+		return
+	}
+
+	database := h.db.WithContext(r.Context())
+	conv, err := database.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] BulkInsertMessages failed: DB error getting conversation err=%v", err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	avatars, err := database.GetAllAvatars()
+	if err != nil {
+		log.Printf("[API] BulkInsertMessages failed: DB error getting avatars err=%v", err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+	avatarNames := make(map[int64]string, len(avatars))
+	for _, a := range avatars {
+		avatarNames[a.ID] = a.Name
+	}
+
+	messages, err := bulkMessages(req.Messages, avatarNames, time.Now())
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	// Redact user messages as if they had been sent one by one
+	redactions := make([]map[string]int, len(messages))
+	totals := make(map[string]int)
+	for i := range messages {
+		if messages[i].SenderType != models.SenderTypeUser {
+			continue
+		}
+		messages[i].Content, redactions[i] = h.redactUserContent(r.Context(), conv, messages[i].Content)
+		for kind, n := range redactions[i] {
+			totals[kind] += n
+		}
+	}
+
+	// Imported messages are stored as silent, so avatars do not answer history
+	imported, err := database.ImportMessages(id, messages)
+	if err != nil {
+		log.Printf("[API] BulkInsertMessages failed: DB error inserting messages conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to save messages", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range imported {
+		if len(redactions[i]) > 0 {
+			if err := database.CreateRedactions(id, imported[i].ID, conv.RedactionPolicy, redactions[i]); err != nil {
+				log.Printf("[API] Warning: failed to record redactions message_id=%d err=%v", imported[i].ID, err)
+			}
+		}
+		if imported[i].SenderType == models.SenderTypeUser {
+			if _, err := database.RecordConversationReferences(&imported[i]); err != nil {
+				log.Printf("[API] Warning: failed to record conversation references message_id=%d err=%v", imported[i].ID, err)
+			}
+		}
+	}
+
+	first, last := imported[0].Sequence, imported[len(imported)-1].Sequence
+	if h.broadcast != nil {
+		h.broadcast.BroadcastMessagesImported(id, len(imported), first, last)
+	}
+
+	resp := BulkMessagesResponse{
+		Inserted:      len(imported),
+		FirstSequence: first,
+		LastSequence:  last,
+	}
+	if len(totals) > 0 {
+		resp.Redactions = totals
+	}
+	if req.Forward {
+		resp.Deliveries = h.forwardHistory(conv, imported, avatarNames)
+	}
+
+	log.Printf("[API] BulkInsertMessages completed conversation_id=%d inserted=%d first_sequence=%d last_sequence=%d forward=%v",
+		id, len(imported), first, last, req.Forward)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// bulkMessages validates the messages of a bulk request and converts them to conversation messages
+// Failures are returned as *statusError naming the position of the invalid message
+func bulkMessages(items []BulkMessage, avatarNames map[int64]string, now time.Time) ([]models.Message, error) {
+	messages := make([]models.Message, len(items))
+	for i, item := range items {
+		invalid := func(reason string) error {
+			return &statusError{http.StatusBadRequest, fmt.Sprintf("Invalid message %d: %s", i, reason)}
+		}
+
+		if item.Content == "" {
+			return nil, invalid("content is required")
+		}
+
+		msg := models.Message{Content: item.Content, CreatedAt: now}
+		switch models.SenderType(item.SenderType) {
+		case "", models.SenderTypeUser:
+			msg.SenderType = models.SenderTypeUser
+		case models.SenderTypeAvatar:
+			if item.SenderID == nil {
+				return nil, invalid("sender_id is required for avatar messages")
+			}
+			if _, ok := avatarNames[*item.SenderID]; !ok {
+				return nil, invalid(fmt.Sprintf("unknown avatar %d", *item.SenderID))
+			}
+			msg.SenderType = models.SenderTypeAvatar
+			msg.SenderID = item.SenderID
+		case models.SenderTypeSystem:
+			msg.SenderType = models.SenderTypeSystem
+		default:
+			return nil, invalid("sender_type must be user, avatar or system")
+		}
+
+		if item.CreatedAt != "" {
+			createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+			if err != nil {
+				return nil, invalid("created_at must be RFC 3339")
+			}
+			msg.CreatedAt = createdAt
+		}
+
+		messages[i] = msg
+	}
+	return messages, nil
+}
+
+// forwardHistory sends inserted messages to every avatar thread of a conversation as one history message
+// System messages are left out. While the OpenAI API is unavailable the history is queued like a user message
+func (h *ConversationHandler) forwardHistory(conv *models.Conversation, messages []models.Message, avatarNames map[int64]string) []DeliveryResponse {
+	id := conv.ID
+	queueOffline := h.offline != nil && h.offline.ShouldQueue(id)
+	if h.assistant == nil && !queueOffline {
+		log.Printf("[API] Skipping history forward: assistant is nil")
+		return nil
+	}
+
+	// Avatars with isolated context get a history of the user messages only
+	var history, userHistory []logic.MessageForFormat
+	for _, msg := range messages {
+		switch msg.SenderType {
+		case models.SenderTypeUser:
+			history = append(history, logic.MessageForFormat{SenderType: logic.SenderTypeUserFormat, Content: msg.Content})
+			userHistory = append(userHistory, history[len(history)-1])
+		case models.SenderTypeAvatar:
+			history = append(history, logic.MessageForFormat{
+				SenderType: logic.SenderTypeAvatarFormat,
+				SenderName: avatarNames[*msg.SenderID],
+				Content:    msg.Content,
+			})
+		}
+	}
+	content := logic.FormatImportedHistory(history, maxImportedHistoryLength)
+	if content == "" {
+		return nil
+	}
+	userContent := logic.FormatImportedHistory(userHistory, maxImportedHistoryLength)
+
+	avatars, threadIDs, err := h.db.GetConversationAvatarsWithThreads(id)
+	if err != nil {
+		log.Printf("[API] Warning: failed to get conversation avatars with threads err=%v", err)
+		return nil
+	}
+
+	lastID := messages[len(messages)-1].ID
+	deliveries := make([]DeliveryResponse, len(avatars))
+	for i, avatar := range avatars {
+		deliveries[i] = DeliveryResponse{AvatarID: avatar.ID, AvatarName: avatar.Name, Status: DeliveryStatusDelivered}
+
+		avatarContent := content
+		if avatar.IsolatedContext {
+			avatarContent = userContent
+		}
+		if i >= len(threadIDs) || threadIDs[i] == "" || avatarContent == "" {
+			deliveries[i].Status = DeliveryStatusSkipped
+			continue
+		}
+
+		if queueOffline {
+			err = h.offline.Enqueue(id, lastID, avatar.ID, threadIDs[i], avatarContent)
+			if err == nil {
+				deliveries[i].Status = DeliveryStatusQueued
+			}
+		} else {
+			err = h.assistant.ForwardQueue().Forward(assistant.ForwardItem{
+				ThreadID:       threadIDs[i],
+				ConversationID: id,
+				MessageID:      lastID,
+				AvatarID:       avatar.ID,
+				AvatarName:     avatar.Name,
+				Content:        avatarContent,
+				Organization:   conv.OpenAIOrganization,
+				Project:        conv.OpenAIProject,
+			})
+		}
+		if err != nil {
+			log.Printf("[API] Warning: failed to forward history to avatar thread thread_id=%s avatar_name=%s err=%v",
+				threadIDs[i], avatar.Name, err)
+			deliveries[i].Status = DeliveryStatusFailed
+			deliveries[i].Error = err.Error()
+		}
+	}
+	return deliveries
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/watcher"
+)
+
+func TestBulkInsertMessages(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Bulk", "")
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_alice")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "existing")
+
+	manager := watcher.NewManager(handler.db, nil, time.Second)
+	handler.SetWatcherManager(manager)
+	broadcaster := NewEventBroadcaster()
+	handler.SetBroadcaster(broadcaster)
+	events := broadcaster.Subscribe(conv.ID)
+	defer broadcaster.Unsubscribe(conv.ID, events)
+
+	body := fmt.Sprintf(`{"messages": [
+		{"content": "Hi there", "created_at": "2024-01-02T03:04:05Z"},
+		{"sender_type": "avatar", "sender_id": %d, "content": "Hello from Alice"},
+		{"sender_type": "system", "content": "Imported from chat"}
+	]}`, alice.ID)
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages/bulk", bytes.NewBufferString(body))
+	req.SetPathValue("id", fmt.Sprint(conv.ID))
+	w := httptest.NewRecorder()
+	handler.BulkInsertMessages(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var resp BulkMessagesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Inserted != 3 || resp.FirstSequence != 2 || resp.LastSequence != 4 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	messages, _ := handler.db.GetMessages(conv.ID)
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(messages))
+	}
+	if messages[1].Content != "Hi there" || messages[1].CreatedAt.Unix() != time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Unix() {
+		t.Errorf("expected the first inserted message with its original time, got %+v", messages[1])
+	}
+	if messages[2].SenderID == nil || *messages[2].SenderID != alice.ID {
+		t.Errorf("expected the second inserted message to be attributed to Alice, got %+v", messages[2])
+	}
+	if messages[3].SenderType != models.SenderTypeSystem {
+		t.Errorf("expected a system message last, got %+v", messages[3])
+	}
+
+	// Watchers pass over the inserted messages but not the ones before them
+	stored, _ := handler.db.GetMessagesAfterSequence(conv.ID, 0, 0)
+	for _, msg := range stored {
+		if want := msg.Sequence >= 2; msg.Silent != want {
+			t.Errorf("expected sequence %d to have silent=%v, got %v", msg.Sequence, want, msg.Silent)
+		}
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != "messages_imported" {
+			t.Errorf("expected a messages_imported event, got %q", event.Type)
+		}
+	default:
+		t.Error("expected a messages_imported event")
+	}
+}
+
+func TestBulkInsertMessages_Errors(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Bulk", "")
+
+	bulkInsert := func(id int64, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages/bulk", bytes.NewBufferString(body))
+		req.SetPathValue("id", fmt.Sprint(id))
+		w := httptest.NewRecorder()
+		handler.BulkInsertMessages(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		name string
+		id   int64
+		body string
+		want int
+	}{
+		{"no messages", conv.ID, `{"messages": []}`, http.StatusBadRequest},
+		{"empty content", conv.ID, `{"messages": [{"content": ""}]}`, http.StatusBadRequest},
+		{"unknown sender type", conv.ID, `{"messages": [{"sender_type": "bot", "content": "x"}]}`, http.StatusBadRequest},
+		{"avatar without sender", conv.ID, `{"messages": [{"sender_type": "avatar", "content": "x"}]}`, http.StatusBadRequest},
+		{"unknown avatar", conv.ID, `{"messages": [{"sender_type": "avatar", "sender_id": 999, "content": "x"}]}`, http.StatusBadRequest},
+		{"invalid time", conv.ID, `{"messages": [{"content": "x", "created_at": "yesterday"}]}`, http.StatusBadRequest},
+		{"missing conversation", 99999, `{"messages": [{"content": "x"}]}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := bulkInsert(tt.id, tt.body); code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, code)
+			}
+		})
+	}
+
+	// A rejected batch inserts nothing
+	messages, _ := handler.db.GetMessages(conv.ID)
+	if len(messages) != 0 {
+		t.Errorf("expected no messages after rejected batches, got %d", len(messages))
+	}
+}
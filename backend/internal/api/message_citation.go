@@ -0,0 +1,56 @@
+package api
+
+import (
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+)
+
+// CitationResponse represents a source cited by a message in API responses
+// Marker is the text in the message content, located between StartIndex and EndIndex
+type CitationResponse struct {
+	Type       string `json:"type"`
+	Marker     string `json:"marker"`
+	FileID     string `json:"file_id"`
+	Filename   string `json:"filename,omitempty"`
+	Quote      string `json:"quote,omitempty"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+// newCitationResponses converts the citations of a message to their API representation
+func newCitationResponses(citations []models.MessageCitation) []CitationResponse {
+	if len(citations) == 0 {
+		return nil
+	}
+
+	response := make([]CitationResponse, len(citations))
+	for i, c := range citations {
+		response[i] = CitationResponse{
+			Type:       c.Type,
+			Marker:     c.Marker,
+			FileID:     c.FileID,
+			Filename:   c.Filename,
+			Quote:      c.Quote,
+			StartIndex: c.StartIndex,
+			EndIndex:   c.EndIndex,
+		}
+	}
+	return response
+}
+
+// messageCitations converts the citations of an assistant response into message citations
+func messageCitations(citations []assistant.Citation) []models.MessageCitation {
+	result := make([]models.MessageCitation, len(citations))
+	for i, c := range citations {
+		result[i] = models.MessageCitation{
+			Type:       c.Type,
+			Marker:     c.Text,
+			FileID:     c.FileID,
+			Filename:   c.Filename,
+			Quote:      c.Quote,
+			StartIndex: c.StartIndex,
+			EndIndex:   c.EndIndex,
+		}
+	}
+	return result
+}
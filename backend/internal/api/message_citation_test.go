@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestGetMessages_IncludesCitations(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, err := handler.db.CreateConversation("Citations", "")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	msg, err := handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "Sales grew【4:0†source】")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	err = handler.db.CreateMessageCitations(msg.ID, []models.MessageCitation{{
+		Type: "file_citation", Marker: "【4:0†source】", FileID: "file_report", Filename: "report.pdf",
+		StartIndex: 10, EndIndex: 22,
+	}})
+	if err != nil {
+		t.Fatalf("failed to create citations: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/conversations/%d/messages", conv.ID), nil)
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.GetMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response []MessageResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response) != 1 || len(response[0].Citations) != 1 {
+		t.Fatalf("expected 1 message with 1 citation, got %+v", response)
+	}
+
+	c := response[0].Citations[0]
+	if c.Marker != "【4:0†source】" || c.Filename != "report.pdf" || c.StartIndex != 10 || c.EndIndex != 22 {
+		t.Errorf("unexpected citation: %+v", c)
+	}
+}
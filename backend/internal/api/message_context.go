@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultMessageContext is the number of messages returned on each side of a message by default
+	defaultMessageContext = 10
+	// maxMessageContext caps the before and after parameters of the message context endpoint
+	maxMessageContext = 100
+)
+
+// MessageContextResponse represents a slice of a conversation around one message
+type MessageContextResponse struct {
+	MessageID int64             `json:"message_id"`
+	Messages  []MessageResponse `json:"messages"`
+	// HasBefore and HasAfter report whether the conversation continues beyond the slice
+	HasBefore bool `json:"has_before"`
+	HasAfter  bool `json:"has_after"`
+}
+
+// GetMessageContext handles GET /api/conversations/{id}/messages/{message_id}/context
+// Returns the message with up to before (default 10) preceding and after (default 10) following messages,
+// so a search result can be shown in place without loading the whole conversation
+func (h *ConversationHandler) GetMessageContext(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("message_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	before, ok := parseMessageContextParam(r, "before")
+	if !ok {
+		http.Error(w, "Invalid before", http.StatusBadRequest)
+		return
+	}
+	after, ok := parseMessageContextParam(r, "after")
+	if !ok {
+		http.Error(w, "Invalid after", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetConversation(id); err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	target, err := h.db.GetMessage(id, messageID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get message", http.StatusInternalServerError)
+		return
+	}
+
+	// One extra message on each side tells whether the conversation continues past the slice
+	messages, err := h.db.GetMessagesAround(id, target.Sequence, before+1, after+1)
+	if err != nil {
+		log.Printf("[API] GetMessageContext failed: DB error getting messages conversation_id=%d message_id=%d err=%v",
+			id, messageID, err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageContextResponse{MessageID: messageID}
+	index := 0
+	for index < len(messages) && messages[index].Sequence < target.Sequence {
+		index++
+	}
+	if index > before {
+		resp.HasBefore = true
+		messages = messages[index-before:]
+		index = before
+	}
+	if len(messages)-index-1 > after {
+		resp.HasAfter = true
+		messages = messages[:index+after+1]
+	}
+	resp.Messages = h.newMessageResponses(id, messages)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseMessageContextParam reads a message count query parameter between 0 and maxMessageContext
+// Missing values use defaultMessageContext and larger values are capped
+func parseMessageContextParam(r *http.Request, name string) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return defaultMessageContext, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return min(n, maxMessageContext), true
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestGetMessageContext(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Search", "")
+	var ids []int64
+	for i := 1; i <= 8; i++ {
+		msg, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, fmt.Sprintf("Message %d", i))
+		ids = append(ids, msg.ID)
+	}
+
+	get := func(messageID int64, query string) (*httptest.ResponseRecorder, MessageContextResponse) {
+		t.Helper()
+		convID := strconv.FormatInt(conv.ID, 10)
+		msgID := strconv.FormatInt(messageID, 10)
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/"+convID+"/messages/"+msgID+"/context"+query, nil)
+		req.SetPathValue("id", convID)
+		req.SetPathValue("message_id", msgID)
+		w := httptest.NewRecorder()
+		handler.GetMessageContext(w, req)
+		var resp MessageContextResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w, resp
+	}
+
+	contents := func(resp MessageContextResponse) string {
+		var result []string
+		for _, msg := range resp.Messages {
+			result = append(result, msg.Content)
+		}
+		return fmt.Sprint(result)
+	}
+
+	w, resp := get(ids[4], "?before=2&after=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := contents(resp); got != "[Message 3 Message 4 Message 5 Message 6]" {
+		t.Errorf("unexpected messages: %s", got)
+	}
+	if !resp.HasBefore || !resp.HasAfter || resp.MessageID != ids[4] {
+		t.Errorf("expected more messages on both sides, got %+v", resp)
+	}
+
+	// Defaults cover the whole short conversation
+	_, resp = get(ids[1], "")
+	if len(resp.Messages) != 8 || resp.HasBefore || resp.HasAfter {
+		t.Errorf("expected the whole conversation, got %s has_before=%v has_after=%v", contents(resp), resp.HasBefore, resp.HasAfter)
+	}
+
+	_, resp = get(ids[0], "?before=3&after=0")
+	if got := contents(resp); got != "[Message 1]" || resp.HasBefore || !resp.HasAfter {
+		t.Errorf("unexpected slice at the start: %s %+v", got, resp)
+	}
+
+	if w, _ := get(ids[0], "?before=-1"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a negative count, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w, _ := get(99999, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown message, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Delivery statuses of a user message forwarded to an avatar thread
+const (
+	// DeliveryStatusPending means the message is still being added to the thread
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+	// DeliveryStatusQueued means the message waits in the offline queue until the OpenAI API recovers
+	DeliveryStatusQueued = "queued"
+	// DeliveryStatusSkipped means the avatar has no thread to forward to
+	DeliveryStatusSkipped = "skipped"
+)
+
+// DefaultSendTimeout is how long SendMessage waits for deliveries before answering 202 Accepted
+const DefaultSendTimeout = 10 * time.Second
+
+// deliveryRetention is how long the deliveries of a message can be polled after they complete
+const deliveryRetention = 10 * time.Minute
+
+// DeliveryResponse reports the outcome of forwarding a user message to one avatar's thread
+type DeliveryResponse struct {
+	AvatarID   int64  `json:"avatar_id"`
+	AvatarName string `json:"avatar_name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DeliveriesResponse represents the delivery status resource of a message
+type DeliveriesResponse struct {
+	MessageID  int64              `json:"message_id"`
+	Complete   bool               `json:"complete"`
+	Deliveries []DeliveryResponse `json:"deliveries"`
+}
+
+// messageDeliveries tracks the forwarding of one user message to the avatar threads
+type messageDeliveries struct {
+	conversationID int64
+	done           chan struct{}
+
+	mu         sync.Mutex
+	deliveries []DeliveryResponse
+	finishedAt time.Time
+}
+
+// newMessageDeliveries creates a tracker with one pending delivery per avatar
+func newMessageDeliveries(conversationID int64, deliveries []DeliveryResponse) *messageDeliveries {
+	return &messageDeliveries{
+		conversationID: conversationID,
+		done:           make(chan struct{}),
+		deliveries:     deliveries,
+	}
+}
+
+// set records the outcome of the delivery at index i
+func (d *messageDeliveries) set(i int, status, errMsg string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deliveries[i].Status = status
+	d.deliveries[i].Error = errMsg
+}
+
+// finish marks every delivery as settled
+func (d *messageDeliveries) finish() {
+	d.mu.Lock()
+	d.finishedAt = time.Now()
+	d.mu.Unlock()
+	close(d.done)
+}
+
+// wait blocks until every delivery is settled
+func (d *messageDeliveries) wait() {
+	<-d.done
+}
+
+// snapshot returns a copy of the deliveries and whether all of them are settled
+func (d *messageDeliveries) snapshot() ([]DeliveryResponse, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deliveries := make([]DeliveryResponse, len(d.deliveries))
+	copy(deliveries, d.deliveries)
+	return deliveries, !d.finishedAt.IsZero()
+}
+
+// deliveryStore keeps the deliveries of recent messages so clients can poll them
+type deliveryStore struct {
+	mu       sync.Mutex
+	messages map[int64]*messageDeliveries
+}
+
+// newDeliveryStore creates an empty delivery store
+func newDeliveryStore() *deliveryStore {
+	return &deliveryStore{messages: make(map[int64]*messageDeliveries)}
+}
+
+// add registers the deliveries of a message, dropping messages that finished long ago
+func (s *deliveryStore) add(messageID int64, d *messageDeliveries) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, existing := range s.messages {
+		existing.mu.Lock()
+		expired := !existing.finishedAt.IsZero() && time.Since(existing.finishedAt) > deliveryRetention
+		existing.mu.Unlock()
+		if expired {
+			delete(s.messages, id)
+		}
+	}
+	s.messages[messageID] = d
+}
+
+// get returns the deliveries of a message, or nil if they are unknown or expired
+func (s *deliveryStore) get(messageID int64) *messageDeliveries {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages[messageID]
+}
+
+// SetSendTimeout sets how long SendMessage waits for deliveries before answering 202 Accepted
+func (h *ConversationHandler) SetSendTimeout(d time.Duration) {
+	h.sendTimeout = d
+}
+
+// GetDeliveries handles GET /api/conversations/{id}/messages/{message_id}/deliveries
+// Deliveries are kept in memory for a while after they complete
+func (h *ConversationHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageID, err := strconv.ParseInt(r.PathValue("message_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	tracked := h.deliveries.get(messageID)
+	if tracked == nil || tracked.conversationID != id {
+		http.Error(w, "Deliveries not found", http.StatusNotFound)
+		return
+	}
+
+	deliveries, complete := tracked.snapshot()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeliveriesResponse{
+		MessageID:  messageID,
+		Complete:   complete,
+		Deliveries: deliveries,
+	})
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestSendMessage_DeadlineReturnsAccepted(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs"):
+			w.Write([]byte(`{"data": []}`))
+		case r.URL.Path == "/threads/thread_stuck/messages":
+			<-release
+			w.Write([]byte(`{"id": "msg_2", "role": "user"}`))
+		default:
+			w.Write([]byte(`{"id": "msg_1", "role": "user"}`))
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	handler.assistant = assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+	handler.SetSendTimeout(100 * time.Millisecond)
+
+	conv, _ := handler.db.CreateConversation("Deadline", "")
+	fast, _ := handler.db.CreateAvatar("Fast", "prompt", "asst")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, fast.ID, "thread_fast")
+	stuck, _ := handler.db.CreateAvatar("Stuck", "prompt", "asst")
+	handler.db.AddAvatarToConversationWithThreadID(conv.ID, stuck.ID, "thread_stuck")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages", bytes.NewBufferString(`{"content": "Hello"}`))
+	req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+	w := httptest.NewRecorder()
+	handler.SendMessage(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var response SendMessageResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	want := fmt.Sprintf("/api/conversations/%d/messages/%d/deliveries", conv.ID, response.UserMessage.ID)
+	if response.DeliveryURL != want {
+		t.Errorf("expected delivery_url %q, got %q", want, response.DeliveryURL)
+	}
+	if len(response.Deliveries) != 2 || response.Deliveries[0].Status != DeliveryStatusDelivered ||
+		response.Deliveries[1].Status != DeliveryStatusPending {
+		t.Errorf("expected a delivered and a pending delivery, got %+v", response.Deliveries)
+	}
+
+	poll := func() DeliveriesResponse {
+		req := httptest.NewRequest(http.MethodGet, want, nil)
+		req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+		req.SetPathValue("message_id", strconv.FormatInt(response.UserMessage.ID, 10))
+		w := httptest.NewRecorder()
+		handler.GetDeliveries(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var resp DeliveriesResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	if resp := poll(); resp.Complete {
+		t.Errorf("expected deliveries to be in progress, got %+v", resp)
+	}
+
+	release <- struct{}{}
+	handler.deliveries.get(response.UserMessage.ID).wait()
+
+	resp := poll()
+	if !resp.Complete || resp.Deliveries[1].Status != DeliveryStatusDelivered {
+		t.Errorf("expected all deliveries to complete, got %+v", resp)
+	}
+}
+
+func TestGetDeliveries_NotFound(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	handler.deliveries.add(5, newMessageDeliveries(1, nil))
+
+	for _, tc := range []struct{ conversationID, messageID string }{
+		{"1", "6"},
+		{"2", "5"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/"+tc.conversationID+"/messages/"+tc.messageID+"/deliveries", nil)
+		req.SetPathValue("id", tc.conversationID)
+		req.SetPathValue("message_id", tc.messageID)
+		w := httptest.NewRecorder()
+		handler.GetDeliveries(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for conversation %s message %s, got %d",
+				http.StatusNotFound, tc.conversationID, tc.messageID, w.Code)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/notify"
+)
+
+// NotificationHandler handles email notification preference HTTP requests
+type NotificationHandler struct {
+	db *db.DB
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(database *db.DB) *NotificationHandler {
+	return &NotificationHandler{
+		db: database,
+	}
+}
+
+// NotificationPreferencesRequest represents the request body for updating preferences
+// Event types that are omitted keep their current setting
+type NotificationPreferencesRequest struct {
+	Events map[string]bool `json:"events"`
+}
+
+// NotificationPreferencesResponse represents a recipient's preferences in API responses
+type NotificationPreferencesResponse struct {
+	Email  string          `json:"email"`
+	Events map[string]bool `json:"events"`
+}
+
+// listPreferences groups all stored preferences by recipient
+func (h *NotificationHandler) listPreferences() ([]NotificationPreferencesResponse, error) {
+	prefs, err := h.db.GetNotificationPreferences()
+	if err != nil {
+		return nil, err
+	}
+
+	response := []NotificationPreferencesResponse{}
+	index := make(map[string]int)
+	for _, pref := range prefs {
+		key := strings.ToLower(pref.Email)
+		i, ok := index[key]
+		if !ok {
+			i = len(response)
+			index[key] = i
+			response = append(response, NotificationPreferencesResponse{Email: pref.Email, Events: make(map[string]bool)})
+		}
+		response[i].Events[pref.EventType] = pref.Enabled
+	}
+
+	return response, nil
+}
+
+// isValidEmail checks that a path value is a bare email address
+func isValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// ListPreferences handles GET /api/notifications/preferences
+func (h *NotificationHandler) ListPreferences(w http.ResponseWriter, r *http.Request) {
+	response, err := h.listPreferences()
+	if err != nil {
+		http.Error(w, "Failed to get notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdatePreferences handles PUT /api/notifications/preferences/{email}
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	email := r.PathValue("email")
+	if !isValidEmail(email) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+
+	var req NotificationPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Events) == 0 {
+		http.Error(w, "Events are required", http.StatusBadRequest)
+		return
+	}
+	for eventType := range req.Events {
+		if !notify.IsValidEventType(eventType) {
+			http.Error(w, "Invalid event type (must be one of "+strings.Join(notify.EventTypes, ", ")+")", http.StatusBadRequest)
+			return
+		}
+	}
+
+	for eventType, enabled := range req.Events {
+		if err := h.db.SetNotificationPreference(email, eventType, enabled); err != nil {
+			http.Error(w, "Failed to update notification preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Printf("[API] Notification preferences updated email=%s events=%v", email, req.Events)
+
+	all, err := h.listPreferences()
+	if err != nil {
+		http.Error(w, "Failed to get notification preferences", http.StatusInternalServerError)
+		return
+	}
+	for _, prefs := range all {
+		if strings.EqualFold(prefs.Email, email) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(prefs)
+			return
+		}
+	}
+
+	http.Error(w, "Failed to get notification preferences", http.StatusInternalServerError)
+}
+
+// DeletePreferences handles DELETE /api/notifications/preferences/{email}
+func (h *NotificationHandler) DeletePreferences(w http.ResponseWriter, r *http.Request) {
+	email := r.PathValue("email")
+	if !isValidEmail(email) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteNotificationPreferences(email); err == sql.ErrNoRows {
+		http.Error(w, "Recipient not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to delete notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Notification preferences deleted email=%s", email)
+	w.WriteHeader(http.StatusNoContent)
+}
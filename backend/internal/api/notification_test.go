@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestNotificationHandler(t *testing.T) (*NotificationHandler, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	handler := NewNotificationHandler(database)
+
+	return handler, database
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	handler, _ := setupTestNotificationHandler(t)
+
+	body, _ := json.Marshal(NotificationPreferencesRequest{Events: map[string]bool{"digest": true, "idle_question": false}})
+	req := httptest.NewRequest(http.MethodPut, "/api/notifications/preferences/a@example.com", bytes.NewReader(body))
+	req.SetPathValue("email", "a@example.com")
+	w := httptest.NewRecorder()
+	handler.UpdatePreferences(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp NotificationPreferencesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !resp.Events["digest"] || resp.Events["idle_question"] {
+		t.Errorf("unexpected preferences: %+v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/notifications/preferences", nil)
+	w = httptest.NewRecorder()
+	handler.ListPreferences(w, req)
+
+	var list []NotificationPreferencesResponse
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].Email != "a@example.com" {
+		t.Errorf("unexpected preference list: %+v", list)
+	}
+}
+
+func TestUpdateNotificationPreferences_Invalid(t *testing.T) {
+	handler, _ := setupTestNotificationHandler(t)
+
+	tests := []struct {
+		email string
+		body  string
+	}{
+		{"not-an-email", `{"events": {"digest": true}}`},
+		{"a@example.com", `{"events": {"unknown": true}}`},
+		{"a@example.com", `{"events": {}}`},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/notifications/preferences/x", bytes.NewBufferString(tt.body))
+		req.SetPathValue("email", tt.email)
+		w := httptest.NewRecorder()
+		handler.UpdatePreferences(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("email=%q body=%s: expected status %d, got %d", tt.email, tt.body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestDeleteNotificationPreferences_NotFound(t *testing.T) {
+	handler, _ := setupTestNotificationHandler(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/notifications/preferences/a@example.com", nil)
+	req.SetPathValue("email", "a@example.com")
+	w := httptest.NewRecorder()
+	handler.DeletePreferences(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDeleteNotificationPreferences_InvalidEmail(t *testing.T) {
+	handler, _ := setupTestNotificationHandler(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/notifications/preferences/x", nil)
+	req.SetPathValue("email", "not-an-email")
+	w := httptest.NewRecorder()
+	handler.DeletePreferences(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"strings"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+)
+
+// maxOpenAIRoutingIDLength bounds OpenAI organization and project IDs accepted by the API
+const maxOpenAIRoutingIDLength = 128
+
+// invalidOpenAIRoutingID is the error message for a malformed openai_organization or openai_project
+func invalidOpenAIRoutingID(field string) string {
+	return "Invalid " + field + " (must be an OpenAI ID without spaces, at most 128 characters)"
+}
+
+// parseOpenAIRoutingID trims an OpenAI organization or project ID; "" uses the server's default
+func parseOpenAIRoutingID(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) > maxOpenAIRoutingIDLength || strings.ContainsAny(value, " \t\r\n") {
+		return "", false
+	}
+	return value, true
+}
+
+// assistantFor returns the assistant client billed to the OpenAI organization and project of a conversation
+// Returns nil when the server runs without an assistant client
+func (h *ConversationHandler) assistantFor(conv *models.Conversation) *assistant.Client {
+	return h.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+}
+
+// assistantFor returns the assistant client billed to the OpenAI organization and project of a conversation
+// The server's defaults are used when the conversation cannot be read
+func (h *ConversationAvatarHandler) assistantFor(conversationID int64) *assistant.Client {
+	conv, err := h.db.GetConversation(conversationID)
+	if err != nil {
+		return h.assistant
+	}
+	return h.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+}
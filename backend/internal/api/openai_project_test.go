@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestCreateConversation_RoutesThreadsToOpenAIProject(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	var mu sync.Mutex
+	var projects []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		projects = append(projects, r.Header.Get("OpenAI-Organization")+"/"+r.Header.Get("OpenAI-Project"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "thread_team"}`))
+	}))
+	defer server.Close()
+	handler.assistant = assistant.NewClient("test-key",
+		assistant.WithOpenAIProject("org-default", "proj_default"),
+		assistant.WithHTTPClient(&http.Client{
+			Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+		}))
+
+	avatar, _ := handler.db.CreateAvatar("Nova", "prompt", "asst_nova")
+	body := fmt.Sprintf(`{"title": "Team demo", "avatar_ids": [%d], "openai_project": " proj_team "}`, avatar.ID)
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var conv CreateConversationResponse
+	json.NewDecoder(w.Body).Decode(&conv)
+	if conv.OpenAIProject != "proj_team" || conv.OpenAIOrganization != "" {
+		t.Errorf("expected project proj_team and no organization, got %q and %q", conv.OpenAIProject, conv.OpenAIOrganization)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(projects) != 1 || projects[0] != "org-default/proj_team" {
+		t.Errorf("expected the thread to be created in org-default/proj_team, got %v", projects)
+	}
+}
+
+func TestUpdateConversation_OpenAIProject(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+	conv, _ := handler.db.CreateConversation("Team demo", "")
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/conversations/1", bytes.NewBufferString(body))
+		req.SetPathValue("id", strconv.FormatInt(conv.ID, 10))
+		w := httptest.NewRecorder()
+		handler.Update(w, req)
+		return w
+	}
+
+	w := patch(`{"openai_organization": "org-team", "openai_project": "proj_team"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var updated ConversationResponse
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.OpenAIOrganization != "org-team" || updated.OpenAIProject != "proj_team" {
+		t.Errorf("expected org-team and proj_team, got %q and %q", updated.OpenAIOrganization, updated.OpenAIProject)
+	}
+
+	if w := patch(`{"openai_project": "proj team"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a project with spaces, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// An empty value goes back to the server's default
+	if w := patch(`{"openai_project": ""}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	stored, _ := handler.db.GetConversation(conv.ID)
+	if stored.OpenAIOrganization != "org-team" || stored.OpenAIProject != "" {
+		t.Errorf("expected only the project to be cleared, got %q and %q", stored.OpenAIOrganization, stored.OpenAIProject)
+	}
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// PersonaFormat identifies .persona files
+	PersonaFormat = "multi-avatar-chat/persona"
+	// PersonaVersion is the newest version of the .persona format this server reads and writes
+	PersonaVersion = 1
+	// maxPersonaSize bounds the body of a persona import
+	maxPersonaSize = 1 << 20
+)
+
+// Collision handling for persona imports, chosen with the on_conflict query parameter
+const (
+	// PersonaConflictError rejects a persona whose name is already taken
+	PersonaConflictError = "error"
+	// PersonaConflictRename imports the persona as "Name (2)", "Name (3)", ...
+	PersonaConflictRename = "rename"
+	// PersonaConflictReplace overwrites the avatar with the same name
+	PersonaConflictReplace = "replace"
+)
+
+// Persona is the portable .persona representation of an avatar, shared between installations
+// Local data such as IDs and the OpenAI assistant is not included
+type Persona struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Prompt  string `json:"prompt"`
+	// Model is the model of the exported assistant; the server default is used when empty
+	Model    string          `json:"model,omitempty"`
+	Settings PersonaSettings `json:"settings"`
+	Icon     PersonaIcon     `json:"icon"`
+}
+
+// PersonaSettings holds the behaviour settings of a persona
+// Fields missing from an imported file keep the defaults of a new avatar
+type PersonaSettings struct {
+	Keywords           []string `json:"keywords,omitempty"`
+	RelevanceThreshold *float64 `json:"relevance_threshold,omitempty"`
+	CanSearch          *bool    `json:"can_search,omitempty"`
+	CanCode            *bool    `json:"can_code,omitempty"`
+	CanCite            *bool    `json:"can_cite,omitempty"`
+	// FormattingRules are missing from files written before formatting rules existed
+	FormattingRules *models.FormattingRules `json:"formatting_rules,omitempty"`
+	IsolatedContext *bool                   `json:"isolated_context,omitempty"`
+}
+
+// PersonaIcon holds how a persona is displayed
+type PersonaIcon struct {
+	Color string `json:"color,omitempty"`
+	Emoji string `json:"emoji,omitempty"`
+}
+
+// newPersona converts an avatar to its portable representation
+func newPersona(avatar *models.Avatar, model string) Persona {
+	threshold := avatar.RelevanceThreshold
+	canSearch, canCode, canCite := avatar.CanSearch, avatar.CanCode, avatar.CanCite
+	formattingRules := avatar.FormattingRules
+	isolatedContext := avatar.IsolatedContext
+	return Persona{
+		Format:  PersonaFormat,
+		Version: PersonaVersion,
+		Name:    avatar.Name,
+		Prompt:  avatar.Prompt,
+		Model:   model,
+		Settings: PersonaSettings{
+			Keywords:           avatar.Keywords,
+			RelevanceThreshold: &threshold,
+			CanSearch:          &canSearch,
+			CanCode:            &canCode,
+			CanCite:            &canCite,
+			FormattingRules:    &formattingRules,
+			IsolatedContext:    &isolatedContext,
+		},
+		Icon: PersonaIcon{Color: avatar.Color, Emoji: avatar.Emoji},
+	}
+}
+
+// avatarRequest converts a persona to the request that creates the same avatar
+func (p *Persona) avatarRequest() CreateAvatarRequest {
+	return CreateAvatarRequest{
+		Name:               p.Name,
+		Prompt:             p.Prompt,
+		Color:              p.Icon.Color,
+		Emoji:              p.Icon.Emoji,
+		Keywords:           p.Settings.Keywords,
+		RelevanceThreshold: p.Settings.RelevanceThreshold,
+		CanSearch:          p.Settings.CanSearch,
+		CanCode:            p.Settings.CanCode,
+		CanCite:            p.Settings.CanCite,
+		FormattingRules:    p.Settings.FormattingRules,
+		IsolatedContext:    p.Settings.IsolatedContext,
+	}
+}
+
+// validate checks that a decoded persona can be imported
+func (p *Persona) validate() error {
+	if p.Format != PersonaFormat {
+		return fmt.Errorf("not a persona file (format must be %q)", PersonaFormat)
+	}
+	if p.Version < 1 || p.Version > PersonaVersion {
+		return fmt.Errorf("unsupported persona version %d", p.Version)
+	}
+	if p.Name == "" || p.Prompt == "" {
+		return fmt.Errorf("name and prompt are required")
+	}
+	if p.Icon.Color != "" && !logic.IsValidAvatarColor(p.Icon.Color) {
+		return fmt.Errorf("invalid icon color (must be #RRGGBB)")
+	}
+	if !isValidRelevanceThreshold(p.Settings.RelevanceThreshold) {
+		return fmt.Errorf("invalid relevance_threshold (must be between 0 and 1)")
+	}
+	if !isValidFormattingRules(p.Settings.FormattingRules) {
+		return fmt.Errorf("invalid formatting_rules (max_paragraphs must be between 0 and %d)", logic.MaxFormattingParagraphs)
+	}
+	if err := validatePromptVariables(p.Prompt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ExportPersona handles GET /api/avatars/{id}/persona
+// Downloads the avatar as a .persona file
+func (h *AvatarHandler) ExportPersona(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	avatar, err := h.db.GetAvatar(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	// The model lives on the assistant; export without it if the assistant cannot be read
+	var model string
+	if h.assistant != nil && avatar.OpenAIAssistantID != "" {
+		if a, err := h.assistant.WithContext(r.Context()).GetAssistant(avatar.OpenAIAssistantID); err != nil {
+			log.Printf("[API] Warning: exporting persona without model avatar_id=%d assistant_id=%s err=%v",
+				id, avatar.OpenAIAssistantID, err)
+		} else {
+			model = a.Model
+		}
+	}
+
+	log.Printf("[API] Persona exported avatar_id=%d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": avatar.Name + ".persona",
+	}))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(newPersona(avatar, model))
+}
+
+// ImportPersona handles POST /api/avatars/import-persona
+// Creates an avatar from a .persona file. When an avatar with the same name exists,
+// on_conflict decides between rejecting the file (error, the default), importing it
+// under a numbered name (rename) and overwriting the existing avatar (replace)
+func (h *AvatarHandler) ImportPersona(w http.ResponseWriter, r *http.Request) {
+	onConflict := r.URL.Query().Get("on_conflict")
+	if onConflict == "" {
+		onConflict = PersonaConflictError
+	}
+	if onConflict != PersonaConflictError && onConflict != PersonaConflictRename && onConflict != PersonaConflictReplace {
+		http.Error(w, "Invalid on_conflict (must be error, rename or replace)", http.StatusBadRequest)
+		return
+	}
+
+	var persona Persona
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPersonaSize)).Decode(&persona); err != nil {
+		http.Error(w, "Invalid persona file", http.StatusBadRequest)
+		return
+	}
+	if err := persona.validate(); err != nil {
+		http.Error(w, "Invalid persona file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+	// Names are compared as mentions resolve them, ignoring case and character width
+	taken := make(map[string]*models.Avatar)
+	for i := range avatars {
+		if key := logic.NormalizeAvatarName(avatars[i].Name); taken[key] == nil {
+			taken[key] = &avatars[i]
+		}
+	}
+
+	if existing := taken[logic.NormalizeAvatarName(persona.Name)]; existing != nil {
+		switch onConflict {
+		case PersonaConflictError:
+			http.Error(w, "An avatar with this name already exists", http.StatusConflict)
+			return
+		case PersonaConflictReplace:
+			h.replaceWithPersona(w, r, existing, &persona)
+			return
+		case PersonaConflictRename:
+			base := persona.Name
+			for n := 2; taken[logic.NormalizeAvatarName(persona.Name)] != nil; n++ {
+				persona.Name = fmt.Sprintf("%s (%d)", base, n)
+			}
+		}
+	}
+
+	avatar, err := h.createAvatar(persona.avatarRequest(), persona.Model)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	log.Printf("[API] Persona imported avatar_id=%d name=%q", avatar.ID, avatar.Name)
+	recordAudit(h.db, r, models.AuditActionAvatarImport, "avatar", strconv.FormatInt(avatar.ID, 10),
+		nil, newAvatarResponse(avatar))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
+
+// replaceWithPersona overwrites an avatar with the prompt and settings of a persona
+// The assistant keeps its model; only its instructions and tools are updated
+func (h *AvatarHandler) replaceWithPersona(w http.ResponseWriter, r *http.Request, existing *models.Avatar, persona *Persona) {
+	req := persona.avatarRequest()
+
+	if err := checkAvatarName(h.db, req.Name, existing.ID); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	if h.assistant != nil && existing.OpenAIAssistantID != "" {
+		client := h.assistant.WithContext(r.Context())
+		if _, err := client.UpdateAssistant(existing.OpenAIAssistantID, req.Name, h.instructions.AssistantInstructions(req.Prompt)); err != nil {
+			writeStatusError(w, openAIStatusError("Failed to update OpenAI assistant", err))
+			return
+		}
+		before := *existing
+		applyCapabilities(&before, req.CanSearch, req.CanCode, nil)
+		if before.CanSearch != existing.CanSearch || before.CanCode != existing.CanCode {
+			if _, err := client.UpdateAssistantTools(existing.OpenAIAssistantID, capabilityTools(before.CanSearch, before.CanCode)); err != nil {
+				writeStatusError(w, openAIStatusError("Failed to update OpenAI assistant", err))
+				return
+			}
+		}
+	}
+
+	avatar, err := h.db.UpdateAvatar(existing.ID, req.Name, req.Prompt, existing.OpenAIAssistantID)
+	if err == db.ErrDuplicate {
+		writeStatusError(w, checkAvatarName(h.db, req.Name, existing.ID))
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	applyPrefilter(avatar, req.Keywords, req.RelevanceThreshold)
+	applyCapabilities(avatar, req.CanSearch, req.CanCode, req.CanCite)
+	if err := h.db.ApplyAvatarDisplay(avatar, req.Color, req.Emoji); err != nil {
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+	if err := h.db.UpdateAvatarPrefilter(avatar.ID, avatar.Keywords, avatar.RelevanceThreshold); err != nil {
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+	if err := h.db.UpdateAvatarCapabilities(avatar.ID, avatar.CanSearch, avatar.CanCode, avatar.CanCite); err != nil {
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+	if req.FormattingRules != nil {
+		avatar.FormattingRules = *req.FormattingRules
+		if err := h.db.UpdateAvatarFormattingRules(avatar.ID, avatar.FormattingRules); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.IsolatedContext != nil {
+		avatar.IsolatedContext = *req.IsolatedContext
+		if err := h.db.UpdateAvatarIsolatedContext(avatar.ID, avatar.IsolatedContext); err != nil {
+			http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if avatar.Name != existing.Name {
+		h.propagateRename(existing.Name, avatar)
+	}
+
+	log.Printf("[API] Persona replaced avatar avatar_id=%d name=%q", avatar.ID, avatar.Name)
+	recordAudit(h.db, r, models.AuditActionAvatarUpdate, "avatar", strconv.FormatInt(avatar.ID, 10),
+		newAvatarResponse(existing), newAvatarResponse(avatar))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAvatarResponse(avatar))
+}
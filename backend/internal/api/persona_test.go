@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportPersona(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	avatar, _ := handler.db.CreateAvatar("Socrates", "Ask questions", "")
+	handler.db.UpdateAvatarDisplay(avatar.ID, "#112233", "🦉")
+	handler.db.UpdateAvatarPrefilter(avatar.ID, []string{"ethics"}, 0.4)
+	handler.db.UpdateAvatarCapabilities(avatar.ID, true, false, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/avatars/1/persona", nil)
+	req.SetPathValue("id", strconv.FormatInt(avatar.ID, 10))
+	w := httptest.NewRecorder()
+	handler.ExportPersona(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename=Socrates.persona`) {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+
+	var persona Persona
+	json.NewDecoder(w.Body).Decode(&persona)
+	if persona.Format != PersonaFormat || persona.Version != PersonaVersion || persona.Name != "Socrates" ||
+		persona.Prompt != "Ask questions" {
+		t.Errorf("unexpected persona: %+v", persona)
+	}
+	if persona.Icon.Color != "#112233" || persona.Icon.Emoji != "🦉" {
+		t.Errorf("unexpected icon: %+v", persona.Icon)
+	}
+	s := persona.Settings
+	if len(s.Keywords) != 1 || *s.RelevanceThreshold != 0.4 || !*s.CanSearch || *s.CanCode || !*s.CanCite {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+}
+
+func TestImportPersona(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	persona := `{"format": "multi-avatar-chat/persona", "version": 1, "name": "Socrates", "prompt": "Ask questions",
+		"settings": {"keywords": ["ethics"], "can_cite": true, "isolated_context": true}, "icon": {"emoji": "🦉"}}`
+
+	importPersona := func(onConflict, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/avatars/import-persona?on_conflict="+onConflict,
+			bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.ImportPersona(w, req)
+		return w
+	}
+
+	w := importPersona("", persona)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created AvatarResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Name != "Socrates" || created.Emoji != "🦉" || !created.CanCite || !created.IsolatedContext ||
+		len(created.Keywords) != 1 {
+		t.Errorf("unexpected imported avatar: %+v", created)
+	}
+
+	if w := importPersona("", persona); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d for a taken name, got %d", http.StatusConflict, w.Code)
+	}
+
+	var renamed AvatarResponse
+	json.NewDecoder(importPersona("rename", persona).Body).Decode(&renamed)
+	json.NewDecoder(importPersona("rename", persona).Body).Decode(&renamed)
+	if renamed.Name != "Socrates (3)" {
+		t.Errorf("expected the second rename to be Socrates (3), got %q", renamed.Name)
+	}
+
+	w = importPersona("replace", strings.Replace(persona, "Ask questions", "Answer briefly", 1))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var replaced AvatarResponse
+	json.NewDecoder(w.Body).Decode(&replaced)
+	if replaced.ID != created.ID || replaced.Prompt != "Answer briefly" {
+		t.Errorf("expected the existing avatar to be replaced, got %+v", replaced)
+	}
+}
+
+func TestImportPersona_Invalid(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	for _, tc := range []struct{ query, body string }{
+		{"", `{"name": "A", "prompt": "p"}`},
+		{"", `{"format": "multi-avatar-chat/persona", "version": 99, "name": "A", "prompt": "p"}`},
+		{"", `{"format": "multi-avatar-chat/persona", "version": 1, "name": "A"}`},
+		{"", `{"format": "multi-avatar-chat/persona", "version": 1, "name": "A", "prompt": "p", "icon": {"color": "red"}}`},
+		{"?on_conflict=merge", `{"format": "multi-avatar-chat/persona", "version": 1, "name": "A", "prompt": "p"}`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/avatars/import-persona"+tc.query, bytes.NewBufferString(tc.body))
+		w := httptest.NewRecorder()
+		handler.ImportPersona(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s %s, got %d", http.StatusBadRequest, tc.query, tc.body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// awaySummaryMaxTokens is the token limit for the summary posted when the user returns
+const awaySummaryMaxTokens = 500
+
+// SetPresenceRequest is the request body of PUT /api/conversations/{id}/presence
+type SetPresenceRequest struct {
+	Status string `json:"status"`
+}
+
+// PresenceResponse is the presence of the user in a conversation
+// UpdatedAt is omitted when no client has reported it, which counts as viewing
+type PresenceResponse struct {
+	ConversationID int64  `json:"conversation_id"`
+	Status         string `json:"status"`
+	UpdatedAt      string `json:"updated_at,omitempty"`
+	// Summary is the system message summarizing what happened while the user was away,
+	// set when the user returns to a conversation with new messages
+	Summary *MessageResponse `json:"summary,omitempty"`
+}
+
+func newPresenceResponse(p *models.UserPresence) PresenceResponse {
+	return PresenceResponse{
+		ConversationID: p.ConversationID,
+		Status:         p.Status,
+		UpdatedAt:      p.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// GetPresence handles GET /api/conversations/{id}/presence
+func (h *ConversationHandler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.presenceConversationID(w, r)
+	if !ok {
+		return
+	}
+
+	response := PresenceResponse{ConversationID: id, Status: models.PresenceViewing}
+	presence, err := h.db.GetUserPresence(id)
+	if err == nil {
+		response = newPresenceResponse(presence)
+	} else if err != sql.ErrNoRows {
+		http.Error(w, "Failed to get presence", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetPresence handles PUT /api/conversations/{id}/presence
+// Clients report "away" when the user leaves the conversation (e.g. the tab is hidden) and "viewing"
+// when they come back. While the user is away avatars hold their chatter, and returning to new
+// messages posts a summary of them
+func (h *ConversationHandler) SetPresence(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.presenceConversationID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetPresenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Status != models.PresenceViewing && req.Status != models.PresenceAway {
+		http.Error(w, "Invalid status (must be viewing or away)", http.StatusBadRequest)
+		return
+	}
+
+	previous, presence, err := h.db.SetUserPresence(id, req.Status)
+	if err != nil {
+		log.Printf("[API] Set presence failed conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to set presence", http.StatusInternalServerError)
+		return
+	}
+	response := newPresenceResponse(presence)
+
+	if previous.Away() != presence.Away() {
+		log.Printf("[API] Presence changed conversation_id=%d status=%s", id, presence.Status)
+		if h.broadcast != nil {
+			h.broadcast.BroadcastPresence(id, response.Status, response.UpdatedAt)
+		}
+	}
+
+	if previous.Away() && !presence.Away() {
+		summary, err := h.summarizeAway(id, previous.AwaySequence)
+		if err != nil {
+			log.Printf("[API] Warning: failed to summarize messages while away conversation_id=%d err=%v", id, err)
+		} else if summary != nil {
+			response.Summary = &h.newMessageResponses(id, []models.Message{*summary})[0]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// summarizeAway posts a system message summarizing the messages after afterSequence
+// Returns nil without error when nothing but system messages was posted
+func (h *ConversationHandler) summarizeAway(conversationID, afterSequence int64) (*models.Message, error) {
+	conv, err := h.db.GetConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := h.db.GetMessagesAfterSequence(conversationID, afterSequence, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	avatars, err := h.db.GetAllAvatars()
+	if err != nil {
+		return nil, err
+	}
+	avatarNames := make(map[int64]string)
+	for _, a := range avatars {
+		avatarNames[a.ID] = a.Name
+	}
+
+	var formatted []logic.MessageForFormat
+	for _, msg := range messages {
+		if msg.SenderType == models.SenderTypeSystem {
+			continue
+		}
+		fm := logic.MessageForFormat{Content: msg.Content, SenderType: logic.SenderTypeUserFormat}
+		if msg.SenderType == models.SenderTypeAvatar {
+			fm.SenderType = logic.SenderTypeAvatarFormat
+			if msg.SenderID != nil {
+				fm.SenderName = avatarNames[*msg.SenderID]
+			}
+		}
+		formatted = append(formatted, fm)
+	}
+	if len(formatted) == 0 {
+		return nil, nil
+	}
+
+	summary, model := logic.FormatAwayFallback(formatted), ""
+	if h.assistant != nil {
+		client := h.assistantFor(conv)
+		completion, err := client.Completion(logic.BuildDigestPrompt(conv.Title, formatted), awaySummaryMaxTokens)
+		if err == nil && completion != "" {
+			summary, model = completion, client.JudgmentModel()
+		} else {
+			log.Printf("[API] Away summary failed, using fallback conversation_id=%d err=%v", conversationID, err)
+		}
+	}
+
+	msg, err := h.db.CreateMessage(conversationID, models.SenderTypeSystem, nil,
+		logic.FormatAwaySummaryMessage(summary, len(formatted)))
+	if err != nil {
+		return nil, err
+	}
+	if model != "" {
+		if err := h.db.RecordMessageModel(msg.ID, model); err != nil {
+			log.Printf("[API] Warning: failed to record message model message_id=%d err=%v", msg.ID, err)
+		} else {
+			msg.Model = model
+		}
+	}
+	if h.broadcast != nil {
+		h.broadcast.BroadcastMessage(conversationID, models.NewMessageEvent(msg))
+	}
+
+	log.Printf("[API] Away summary posted conversation_id=%d message_id=%d message_count=%d",
+		conversationID, msg.ID, len(formatted))
+	return msg, nil
+}
+
+// presenceConversationID parses the conversation in the path and checks that it exists,
+// writing an error response if not
+func (h *ConversationHandler) presenceConversationID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return 0, false
+	}
+	if _, err := h.db.GetConversation(id); err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return 0, false
+	} else if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestPresence(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Presence", "")
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "")
+	id := strconv.FormatInt(conv.ID, 10)
+
+	get := func() PresenceResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/"+id+"/presence", nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.GetPresence(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var response PresenceResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return response
+	}
+	set := func(body string) (int, PresenceResponse) {
+		req := httptest.NewRequest(http.MethodPut, "/api/conversations/"+id+"/presence", bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.SetPresence(w, req)
+		var response PresenceResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response
+	}
+
+	// Without a report the user counts as viewing
+	if p := get(); p.Status != models.PresenceViewing || p.UpdatedAt != "" {
+		t.Errorf("expected viewing without a report, got %+v", p)
+	}
+	if code, _ := set(`{"status": "asleep"}`); code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid status, got %d", http.StatusBadRequest, code)
+	}
+
+	if code, p := set(`{"status": "away"}`); code != http.StatusOK || p.Status != models.PresenceAway || p.UpdatedAt == "" {
+		t.Fatalf("expected away, got %d %+v", code, p)
+	}
+	if p := get(); p.Status != models.PresenceAway {
+		t.Errorf("expected away, got %+v", p)
+	}
+
+	// Coming back to new messages posts a summary of them
+	handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Let's release on Friday")
+	handler.db.CreateMessage(conv.ID, models.SenderTypeSystem, nil, "Digest")
+	code, p := set(`{"status": "viewing"}`)
+	if code != http.StatusOK || p.Status != models.PresenceViewing || p.Summary == nil {
+		t.Fatalf("expected viewing with a summary, got %d %+v", code, p)
+	}
+	if p.Summary.SenderType != string(models.SenderTypeSystem) ||
+		!strings.HasPrefix(p.Summary.Content, "【While You Were Away】(1 messages)") ||
+		!strings.Contains(p.Summary.Content, "- Alice: 1 messages") {
+		t.Errorf("unexpected summary: %+v", p.Summary)
+	}
+
+	// Nothing new, no summary
+	set(`{"status": "away"}`)
+	if _, p := set(`{"status": "viewing"}`); p.Summary != nil {
+		t.Errorf("expected no summary without new messages, got %+v", p.Summary)
+	}
+	if _, p := set(`{"status": "viewing"}`); p.Summary != nil {
+		t.Errorf("expected no summary when already viewing, got %+v", p.Summary)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/conversations/999/presence", bytes.NewBufferString(`{"status": "away"}`))
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	handler.SetPresence(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestPresence_LLMSummary(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	handler := NewConversationHandler(database, testutil.NewMockAssistant(t).Client())
+
+	conv, _ := database.CreateConversation("Presence", "")
+	id := strconv.FormatInt(conv.ID, 10)
+	database.SetUserPresence(conv.ID, models.PresenceAway)
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Posted from another device")
+
+	req := httptest.NewRequest(http.MethodPut, "/api/conversations/"+id+"/presence", bytes.NewBufferString(`{"status": "viewing"}`))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.SetPresence(w, req)
+
+	var p PresenceResponse
+	json.NewDecoder(w.Body).Decode(&p)
+	if p.Summary == nil || !p.Summary.AILabel.AIGenerated || p.Summary.AILabel.Model == "" {
+		t.Fatalf("expected an AI-labeled summary, got %+v", p.Summary)
+	}
+}
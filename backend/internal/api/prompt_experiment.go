@@ -0,0 +1,174 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// defaultPromptSplit is the percentage of messages handled with prompt B when no split is given
+const defaultPromptSplit = 50
+
+// SetPromptExperimentRequest is the request body of PUT /api/avatars/{id}/prompt-experiment
+// SplitB is the percentage of messages handled with PromptB (default 50)
+type SetPromptExperimentRequest struct {
+	PromptA string `json:"prompt_a"`
+	PromptB string `json:"prompt_b"`
+	SplitB  *int   `json:"split_b,omitempty"`
+}
+
+// PromptExperimentStatsResponse compares the variants of an avatar's prompt experiment
+type PromptExperimentStatsResponse struct {
+	AvatarID  int64                       `json:"avatar_id"`
+	SplitB    int                         `json:"split_b"`
+	StartedAt string                      `json:"started_at"`
+	Variants  []models.PromptVariantStats `json:"variants"`
+}
+
+// GetPromptExperiment handles GET /api/avatars/{id}/prompt-experiment
+func (h *AvatarHandler) GetPromptExperiment(w http.ResponseWriter, r *http.Request) {
+	experiment, ok := h.getPromptExperiment(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(experiment)
+}
+
+// SetPromptExperiment handles PUT /api/avatars/{id}/prompt-experiment
+// Starts or changes the avatar's A/B prompt experiment. Changing a prompt restarts the stats
+func (h *AvatarHandler) SetPromptExperiment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	var req SetPromptExperimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PromptA == "" || req.PromptB == "" {
+		http.Error(w, "prompt_a and prompt_b are required", http.StatusBadRequest)
+		return
+	}
+	for _, prompt := range []string{req.PromptA, req.PromptB} {
+		if err := validatePromptVariables(prompt); err != nil {
+			http.Error(w, "Invalid prompt: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	splitB := defaultPromptSplit
+	if req.SplitB != nil {
+		splitB = *req.SplitB
+	}
+	if splitB < 0 || splitB > 100 {
+		http.Error(w, "Invalid split_b (must be between 0 and 100)", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetAvatar(id); err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	before, err := h.db.GetPromptExperiment(id)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to get prompt experiment", http.StatusInternalServerError)
+		return
+	}
+
+	experiment, err := h.db.SetPromptExperiment(id, req.PromptA, req.PromptB, splitB)
+	if err != nil {
+		http.Error(w, "Failed to set prompt experiment", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Prompt experiment set avatar_id=%d split_b=%d", id, experiment.SplitB)
+	recordAudit(h.db, r, models.AuditActionPromptExperimentUpdate, "avatar", strconv.FormatInt(id, 10),
+		promptExperimentAuditState(before), promptExperimentAuditState(experiment))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(experiment)
+}
+
+// DeletePromptExperiment handles DELETE /api/avatars/{id}/prompt-experiment
+// The avatar goes back to its own prompt; messages sent during the experiment keep their variant
+func (h *AvatarHandler) DeletePromptExperiment(w http.ResponseWriter, r *http.Request) {
+	before, ok := h.getPromptExperiment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeletePromptExperiment(before.AvatarID); err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to delete prompt experiment", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Prompt experiment deleted avatar_id=%d", before.AvatarID)
+	recordAudit(h.db, r, models.AuditActionPromptExperimentDelete, "avatar", strconv.FormatInt(before.AvatarID, 10),
+		promptExperimentAuditState(before), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PromptExperimentStats handles GET /api/avatars/{id}/prompt-experiment/stats
+// Compares the variants on the messages handled since the current prompts were set
+func (h *AvatarHandler) PromptExperimentStats(w http.ResponseWriter, r *http.Request) {
+	experiment, ok := h.getPromptExperiment(w, r)
+	if !ok {
+		return
+	}
+
+	variants, err := h.db.GetPromptExperimentStats(experiment.AvatarID, experiment.StartedAt)
+	if err != nil {
+		http.Error(w, "Failed to get prompt experiment stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PromptExperimentStatsResponse{
+		AvatarID:  experiment.AvatarID,
+		SplitB:    experiment.SplitB,
+		StartedAt: experiment.StartedAt.Format(time.RFC3339),
+		Variants:  variants,
+	})
+}
+
+// getPromptExperiment loads the prompt experiment of the avatar in the path, writing an error response if it fails
+func (h *AvatarHandler) getPromptExperiment(w http.ResponseWriter, r *http.Request) (*models.PromptExperiment, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	experiment, err := h.db.GetPromptExperiment(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Prompt experiment not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get prompt experiment", http.StatusInternalServerError)
+		return nil, false
+	}
+	return experiment, true
+}
+
+// promptExperimentAuditState is the audited part of a prompt experiment, nil when there is none
+func promptExperimentAuditState(e *models.PromptExperiment) any {
+	if e == nil {
+		return nil
+	}
+	return map[string]any{"prompt_a": e.PromptA, "prompt_b": e.PromptB, "split_b": e.SplitB}
+}
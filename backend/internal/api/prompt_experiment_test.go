@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestPromptExperiment(t *testing.T) {
+	handler := setupTestAvatarHandler(t)
+
+	avatar, _ := handler.db.CreateAvatar("Alice", "prompt", "")
+
+	set := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/avatars/"+id+"/prompt-experiment", strings.NewReader(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.SetPromptExperiment(w, req)
+		return w
+	}
+	call := func(fn http.HandlerFunc, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/avatars/1/prompt-experiment"+path, nil)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		fn(w, req)
+		return w
+	}
+
+	if w := call(handler.GetPromptExperiment, http.MethodGet, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d without an experiment, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w := set("1", `{"prompt_a": "Be formal", "prompt_b": "Be casual"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var experiment models.PromptExperiment
+	json.NewDecoder(w.Body).Decode(&experiment)
+	if experiment.AvatarID != avatar.ID || experiment.PromptB != "Be casual" || experiment.SplitB != defaultPromptSplit {
+		t.Errorf("expected an even split by default, got %+v", experiment)
+	}
+
+	invalid := []struct {
+		id   string
+		body string
+		want int
+	}{
+		{"1", `{"prompt_a": "Be formal"}`, http.StatusBadRequest},
+		{"1", `{"prompt_a": "a", "prompt_b": "b", "split_b": 101}`, http.StatusBadRequest},
+		{"1", `{"prompt_a": "a", "prompt_b": "Talk about {{weather}}"}`, http.StatusBadRequest},
+		{"999", `{"prompt_a": "a", "prompt_b": "b"}`, http.StatusNotFound},
+	}
+	for _, tt := range invalid {
+		if w := set(tt.id, tt.body); w.Code != tt.want {
+			t.Errorf("PUT %s %s: expected status %d, got %d", tt.id, tt.body, tt.want, w.Code)
+		}
+	}
+
+	if w := set("1", `{"prompt_a": "Be formal", "prompt_b": "Be casual", "split_b": 0}`); w.Code != http.StatusOK {
+		t.Fatalf("expected a zero split to be accepted, got %d", w.Code)
+	}
+
+	conv, _ := handler.db.CreateConversation("Trials", "")
+	trigger, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	reply, _ := handler.db.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatar.ID, "Good day")
+	handler.db.CreatePromptTrial(&models.PromptTrial{
+		ConversationID: conv.ID, AvatarID: avatar.ID, TriggerMessageID: trigger.ID,
+		Variant: models.PromptVariantA, ShouldRespond: true, MessageID: &reply.ID,
+	})
+
+	var stats PromptExperimentStatsResponse
+	json.NewDecoder(call(handler.PromptExperimentStats, http.MethodGet, "/stats").Body).Decode(&stats)
+	if stats.SplitB != 0 || len(stats.Variants) != 2 || stats.Variants[0].Responses != 1 || stats.Variants[1].Trials != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		if w := call(handler.DeletePromptExperiment, http.MethodDelete, ""); w.Code != want {
+			t.Errorf("expected status %d, got %d", want, w.Code)
+		}
+	}
+
+	entries, _ := handler.db.GetAuditEntries(models.AuditFilter{Action: models.AuditActionPromptExperimentUpdate, Limit: 10})
+	if len(entries) != 2 || entries[0].Changes["split_b"].To != float64(0) {
+		t.Errorf("expected the experiment changes to be audited, got %+v", entries)
+	}
+}
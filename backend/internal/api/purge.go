@@ -0,0 +1,338 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/watcher"
+)
+
+const (
+	// minPurgeTextLength guards against accidentally purging most of the database
+	minPurgeTextLength = 3
+	// purgeRecordLimit is the number of audit entries returned by ListPurges
+	purgeRecordLimit = 100
+)
+
+// PurgeHandler handles data purge HTTP requests
+// Remote OpenAI data is deleted first; local rows are only removed once nothing is left
+// behind remotely, so a failed purge can simply be requested again
+type PurgeHandler struct {
+	db        *db.DB
+	assistant *assistant.Client
+	watcher   *watcher.WatcherManager
+	// broadcaster drops the SSE replay buffer of purged conversations
+	broadcaster *EventBroadcaster
+}
+
+// NewPurgeHandler creates a new purge handler
+func NewPurgeHandler(database *db.DB, assistantClient *assistant.Client, wm *watcher.WatcherManager) *PurgeHandler {
+	return &PurgeHandler{
+		db:        database,
+		assistant: assistantClient,
+		watcher:   wm,
+	}
+}
+
+// SetBroadcaster sets the event broadcaster whose replay buffers of purged conversations are dropped
+func (h *PurgeHandler) SetBroadcaster(b *EventBroadcaster) {
+	h.broadcaster = b
+}
+
+// PurgeContentRequest represents the request body for purging content
+type PurgeContentRequest struct {
+	Text string `json:"text"`
+}
+
+// PurgeResponse reports the outcome of a purge
+type PurgeResponse struct {
+	Record   models.PurgeRecord `json:"record"`
+	Failures []string           `json:"failures,omitempty"`
+}
+
+// PurgeConversation handles POST /api/admin/purge/conversations/{id}
+// Deletes the conversation thread and every avatar thread, then all local rows of the conversation
+func (h *PurgeHandler) PurgeConversation(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] PurgeConversation started")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] PurgeConversation failed: DB error getting conversation err=%v", err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	// Stop watchers so no new messages or runs are created while purging
+	if h.watcher != nil {
+		if err := h.watcher.StopRoomWatchers(id); err != nil {
+			log.Printf("[API] Warning: Failed to stop room watchers conversation_id=%d err=%v", id, err)
+		}
+	}
+
+	threadIDs, err := h.conversationThreadIDs(conv)
+	if err != nil {
+		log.Printf("[API] PurgeConversation failed: DB error getting threads err=%v", err)
+		h.restartWatchers(id)
+		http.Error(w, "Failed to get conversation threads", http.StatusInternalServerError)
+		return
+	}
+
+	record := models.PurgeRecord{
+		Scope:           models.PurgeScopeConversation,
+		Target:          strconv.FormatInt(id, 10),
+		ConversationIDs: []int64{id},
+	}
+
+	var failures []string
+	for _, threadID := range threadIDs {
+		if err := h.deleteThread(conv, threadID); err != nil {
+			failures = append(failures, fmt.Sprintf("thread %s: %v", threadID, err))
+			continue
+		}
+		record.RemoteDeleted++
+	}
+
+	if len(failures) == 0 {
+		h.discardForwards(func(item assistant.ForwardItem) bool { return item.ConversationID == id })
+
+		record.MessagesDeleted, err = h.countMessages(id)
+		if err != nil {
+			log.Printf("[API] Warning: failed to count messages conversation_id=%d err=%v", id, err)
+		}
+		if err := h.db.DeleteConversation(id); err != nil {
+			failures = append(failures, fmt.Sprintf("local data: %v", err))
+		} else if h.broadcaster != nil {
+			h.broadcaster.DropConversation(id)
+		}
+	}
+
+	// The conversation is kept when the purge fails, so its avatars keep responding until it is retried
+	if len(failures) > 0 {
+		h.restartWatchers(id)
+	}
+
+	h.finishPurge(w, r, record, failures)
+}
+
+// PurgeContent handles POST /api/admin/purge/content
+// Deletes every message containing the text (case-insensitive) locally and from the
+// conversation and avatar threads of the affected conversations
+func (h *PurgeHandler) PurgeContent(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] PurgeContent started")
+
+	var req PurgeContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if len([]rune(req.Text)) < minPurgeTextLength {
+		http.Error(w, fmt.Sprintf("text must be at least %d characters", minPurgeTextLength), http.StatusBadRequest)
+		return
+	}
+
+	conversationIDs, err := h.db.FindConversationsWithContent(req.Text)
+	if err != nil {
+		log.Printf("[API] PurgeContent failed: DB error finding conversations err=%v", err)
+		http.Error(w, "Failed to find matching content", http.StatusInternalServerError)
+		return
+	}
+
+	// The audit log identifies the purge without keeping the purged text
+	hash := sha256.Sum256([]byte(strings.ToLower(req.Text)))
+	record := models.PurgeRecord{
+		Scope:           models.PurgeScopeContent,
+		Target:          "sha256:" + hex.EncodeToString(hash[:]),
+		ConversationIDs: conversationIDs,
+	}
+
+	needle := strings.ToLower(req.Text)
+	var failures []string
+	for _, id := range conversationIDs {
+		conv, err := h.db.GetConversation(id)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("conversation %d: %v", id, err))
+			continue
+		}
+		threadIDs, err := h.conversationThreadIDs(conv)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("conversation %d: %v", id, err))
+			continue
+		}
+		for _, threadID := range threadIDs {
+			deleted, err := h.deleteThreadMessages(conv, threadID, needle)
+			record.RemoteDeleted += deleted
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("thread %s: %v", threadID, err))
+			}
+		}
+	}
+
+	if len(failures) == 0 {
+		h.discardForwards(func(item assistant.ForwardItem) bool {
+			return strings.Contains(strings.ToLower(item.Content), needle)
+		})
+
+		record.MessagesDeleted, err = h.db.DeleteContent(req.Text)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("local data: %v", err))
+		}
+	}
+
+	h.finishPurge(w, r, record, failures)
+}
+
+// ListPurges handles GET /api/admin/purges
+func (h *PurgeHandler) ListPurges(w http.ResponseWriter, r *http.Request) {
+	records, err := h.db.GetPurgeRecords(purgeRecordLimit)
+	if err != nil {
+		log.Printf("[API] ListPurges failed: DB error err=%v", err)
+		http.Error(w, "Failed to list purges", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// conversationThreadIDs returns the conversation thread and all avatar threads of a conversation
+func (h *PurgeHandler) conversationThreadIDs(conv *models.Conversation) ([]string, error) {
+	_, avatarThreadIDs, err := h.db.GetConversationAvatarsWithThreads(conv.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var threadIDs []string
+	if conv.ThreadID != "" {
+		threadIDs = append(threadIDs, conv.ThreadID)
+	}
+	for _, threadID := range avatarThreadIDs {
+		if threadID != "" {
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+	return threadIDs, nil
+}
+
+// deleteThread deletes a remote thread of a conversation, treating an already deleted thread as success
+func (h *PurgeHandler) deleteThread(conv *models.Conversation, threadID string) error {
+	if h.assistant == nil {
+		return fmt.Errorf("OpenAI client is not configured")
+	}
+	if err := h.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject).DeleteThread(threadID); err != nil && !assistant.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// deleteThreadMessages deletes the messages of a remote thread of a conversation whose text contains needle
+// Returns the number of messages deleted
+func (h *PurgeHandler) deleteThreadMessages(conv *models.Conversation, threadID, needle string) (int, error) {
+	if h.assistant == nil {
+		return 0, fmt.Errorf("OpenAI client is not configured")
+	}
+
+	client := h.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+	messages, err := client.ListAllMessages(threadID)
+	if assistant.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, msg := range messages {
+		if !strings.Contains(strings.ToLower(msg.Text()), needle) {
+			continue
+		}
+		if err := client.DeleteMessage(threadID, msg.ID); err != nil && !assistant.IsNotFound(err) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// discardForwards drops failed forwards that would otherwise re-send purged content
+func (h *PurgeHandler) discardForwards(match func(assistant.ForwardItem) bool) {
+	if h.assistant == nil {
+		return
+	}
+	for _, item := range h.assistant.ForwardQueue().List() {
+		if item.Status == assistant.ForwardStatusFailed && match(item) {
+			h.assistant.ForwardQueue().Discard(item.ID)
+		}
+	}
+}
+
+// restartWatchers starts the watchers of a conversation again after a purge stopped them but kept the conversation
+func (h *PurgeHandler) restartWatchers(conversationID int64) {
+	if h.watcher == nil {
+		return
+	}
+	if err := h.watcher.StartRoomWatchers(conversationID); err != nil {
+		log.Printf("[API] Warning: Failed to restart room watchers conversation_id=%d err=%v", conversationID, err)
+	}
+}
+
+// countMessages returns the number of messages stored for a conversation
+func (h *PurgeHandler) countMessages(conversationID int64) (int64, error) {
+	messages, err := h.db.GetMessages(conversationID)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(messages)), nil
+}
+
+// finishPurge records the purge in the audit log and writes the response
+// A purge with failures keeps local data and responds with 502 so it can be retried
+func (h *PurgeHandler) finishPurge(w http.ResponseWriter, r *http.Request, record models.PurgeRecord, failures []string) {
+	status := http.StatusOK
+	record.Status = models.PurgeStatusCompleted
+	if len(failures) > 0 {
+		status = http.StatusBadGateway
+		record.Status = models.PurgeStatusFailed
+		record.Error = strings.Join(failures, "; ")
+	}
+
+	if err := h.db.CreatePurgeRecord(&record); err != nil {
+		log.Printf("[API] Warning: failed to record purge scope=%s target=%s err=%v", record.Scope, record.Target, err)
+	}
+
+	action := models.AuditActionPurgeConversation
+	if record.Scope == models.PurgeScopeContent {
+		action = models.AuditActionPurgeContent
+	}
+	recordAudit(h.db, r, action, "purge", strconv.FormatInt(record.ID, 10), nil, map[string]any{
+		"target": record.Target,
+		"status": record.Status,
+	})
+
+	log.Printf("[API] Purge %s scope=%s target=%s messages_deleted=%d remote_deleted=%d failures=%d",
+		record.Status, record.Scope, record.Target, record.MessagesDeleted, record.RemoteDeleted, len(failures))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(PurgeResponse{Record: record, Failures: failures})
+}
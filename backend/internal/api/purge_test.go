@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+	"multi-avatar-chat/internal/watcher"
+)
+
+// purgeMockServer simulates the thread and message endpoints used by purges
+type purgeMockServer struct {
+	mu              sync.Mutex
+	fail            bool
+	deletedThreads  []string
+	deletedMessages []string
+}
+
+func newPurgeAssistantClient(t *testing.T, mock *purgeMockServer) *assistant.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if mock.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"message": "server error"}}`))
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "threads":
+			mock.deletedThreads = append(mock.deletedThreads, parts[1])
+			w.Write([]byte(`{"id": "` + parts[1] + `", "deleted": true}`))
+		case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "messages":
+			w.Write([]byte(`{"data": [
+				{"id": "msg_secret", "role": "user", "content": [{"type": "text", "text": {"value": "my phone is 555-1234"}}]},
+				{"id": "msg_other", "role": "user", "content": [{"type": "text", "text": {"value": "hello"}}]}
+			], "has_more": false}`))
+		case r.Method == http.MethodDelete && len(parts) == 4 && parts[2] == "messages":
+			mock.deletedMessages = append(mock.deletedMessages, parts[1]+"/"+parts[3])
+			w.Write([]byte(`{"id": "` + parts[3] + `", "deleted": true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "not found"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+}
+
+func TestPurgeConversation(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	database := convHandler.db
+	mock := &purgeMockServer{}
+	handler := NewPurgeHandler(database, newPurgeAssistantClient(t, mock), nil)
+
+	conv, _ := database.CreateConversation("Secret", "thread_conv")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_alice")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/purge/conversations/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.PurgeConversation(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp PurgeResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Record.Status != models.PurgeStatusCompleted || resp.Record.RemoteDeleted != 2 || resp.Record.MessagesDeleted != 1 {
+		t.Errorf("unexpected record: %+v", resp.Record)
+	}
+	if len(mock.deletedThreads) != 2 {
+		t.Errorf("expected 2 threads deleted, got %v", mock.deletedThreads)
+	}
+	if _, err := database.GetConversation(conv.ID); err == nil {
+		t.Error("expected conversation to be deleted")
+	}
+
+	records, _ := database.GetPurgeRecords(10)
+	if len(records) != 1 || records[0].Target != "1" {
+		t.Errorf("expected purge to be recorded, got %+v", records)
+	}
+}
+
+func TestPurgeConversation_RemoteFailureKeepsLocalData(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	database := convHandler.db
+	wm := watcher.NewManager(database, nil, time.Hour)
+	t.Cleanup(func() { wm.Shutdown() })
+	handler := NewPurgeHandler(database, newPurgeAssistantClient(t, &purgeMockServer{fail: true}), wm)
+
+	conv, _ := database.CreateConversation("Secret", "thread_conv")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_alice")
+	wm.StartWatcher(conv.ID, avatar.ID)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/purge/conversations/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.PurgeConversation(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, w.Code)
+	}
+	if _, err := database.GetConversation(conv.ID); err != nil {
+		t.Errorf("expected conversation to be kept for retry, got err=%v", err)
+	}
+	if !wm.HasWatcher(conv.ID, avatar.ID) {
+		t.Error("expected the watchers of the kept conversation to be restarted")
+	}
+
+	records, _ := database.GetPurgeRecords(10)
+	if len(records) != 1 || records[0].Status != models.PurgeStatusFailed || records[0].Error == "" {
+		t.Errorf("expected failed purge to be recorded, got %+v", records)
+	}
+}
+
+func TestPurgeConversation_NotFound(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	handler := NewPurgeHandler(convHandler.db, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/purge/conversations/999", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	handler.PurgeConversation(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestPurgeContent(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	database := convHandler.db
+	mock := &purgeMockServer{}
+	handler := NewPurgeHandler(database, newPurgeAssistantClient(t, mock), nil)
+
+	conv, _ := database.CreateConversation("Test", "thread_conv")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "My phone is 555-1234")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	body, _ := json.Marshal(PurgeContentRequest{Text: "555-1234"})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/purge/content", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.PurgeContent(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp PurgeResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Record.MessagesDeleted != 1 || resp.Record.RemoteDeleted != 1 {
+		t.Errorf("unexpected record: %+v", resp.Record)
+	}
+	if strings.Contains(resp.Record.Target, "555") || !strings.HasPrefix(resp.Record.Target, "sha256:") {
+		t.Errorf("expected hashed target, got %q", resp.Record.Target)
+	}
+	if len(mock.deletedMessages) != 1 || mock.deletedMessages[0] != "thread_conv/msg_secret" {
+		t.Errorf("expected only the matching remote message deleted, got %v", mock.deletedMessages)
+	}
+
+	messages, _ := database.GetMessages(conv.ID)
+	if len(messages) != 1 || messages[0].Content != "hello" {
+		t.Errorf("unexpected remaining messages: %+v", messages)
+	}
+}
+
+func TestPurgeContent_TextTooShort(t *testing.T) {
+	convHandler, _ := setupTestConversationHandler(t)
+
+	handler := NewPurgeHandler(convHandler.db, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/purge/content", strings.NewReader(`{"text": " a "}`))
+	w := httptest.NewRecorder()
+	handler.PurgeContent(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit allows Requests requests every Per, in bursts of up to Requests (a token bucket)
+// The zero value disables the limit
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled reports whether the limit restricts anything
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// String formats the limit the way ParseRateLimit reads it
+func (l RateLimit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%v", l.Requests, l.Per)
+}
+
+// ParseRateLimit parses a limit such as "30/1m" or "30/m"; "off" disables the limit
+func ParseRateLimit(value string) (RateLimit, bool) {
+	value = strings.TrimSpace(value)
+	if value == "off" {
+		return RateLimit{}, true
+	}
+
+	count, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, false
+	}
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests <= 0 {
+		return RateLimit{}, false
+	}
+	// A bare unit such as "m" means one of it
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return RateLimit{}, false
+	}
+	return RateLimit{Requests: requests, Per: per}, true
+}
+
+// RateLimits are the limits of the endpoints that create content, per client IP and per API token
+// A request carrying an "Authorization: Bearer" token must pass both its IP and its token limit
+type RateLimits struct {
+	MessagesPerIP    RateLimit
+	MessagesPerToken RateLimit
+	AvatarsPerIP     RateLimit
+	AvatarsPerToken  RateLimit
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry, for servers behind one reverse proxy
+	TrustProxy bool
+}
+
+// DefaultRateLimits returns the limits applied unless configured otherwise
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		MessagesPerIP:    RateLimit{Requests: 30, Per: time.Minute},
+		MessagesPerToken: RateLimit{Requests: 120, Per: time.Minute},
+		AvatarsPerIP:     RateLimit{Requests: 10, Per: time.Minute},
+		AvatarsPerToken:  RateLimit{Requests: 30, Per: time.Minute},
+	}
+}
+
+// Endpoint groups sharing rate limits
+const (
+	rateLimitGroupMessages = "messages"
+	rateLimitGroupAvatars  = "avatars"
+)
+
+// rateLimitState holds the limiters of every endpoint group
+type rateLimitState struct {
+	trustProxy bool
+	groups     map[string]*rateLimitGroup
+}
+
+// rateLimitGroup limits one group of endpoints per client IP and per API token
+type rateLimitGroup struct {
+	perIP    *rateLimiter
+	perToken *rateLimiter
+}
+
+func newRateLimitState(limits RateLimits) *rateLimitState {
+	return &rateLimitState{
+		trustProxy: limits.TrustProxy,
+		groups: map[string]*rateLimitGroup{
+			rateLimitGroupMessages: {perIP: newRateLimiter(limits.MessagesPerIP), perToken: newRateLimiter(limits.MessagesPerToken)},
+			rateLimitGroupAvatars:  {perIP: newRateLimiter(limits.AvatarsPerIP), perToken: newRateLimiter(limits.AvatarsPerToken)},
+		},
+	}
+}
+
+// allow checks a request against the limits of a group
+// Returns false, the limit that was hit ("ip" or "token") and how long to wait when the request is refused
+func (s *rateLimitState) allow(group string, req *http.Request) (bool, string, time.Duration) {
+	g, ok := s.groups[group]
+	if !ok {
+		return true, "", 0
+	}
+
+	if ok, wait := g.perIP.allow(clientIP(req, s.trustProxy)); !ok {
+		return false, "ip", wait
+	}
+	if token := bearerToken(req); token != "" {
+		// Tokens are kept only as hashes
+		sum := sha256.Sum256([]byte(token))
+		if ok, wait := g.perToken.allow(hex.EncodeToString(sum[:16])); !ok {
+			return false, "token", wait
+		}
+	}
+	return true, "", 0
+}
+
+// rateLimiter holds a token bucket per key
+// A nil limiter allows everything
+type rateLimiter struct {
+	limit RateLimit
+	rate  float64 // tokens per second
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket is the state of one key of a rateLimiter
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter creates a limiter, or returns nil when limit is disabled
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if !limit.Enabled() {
+		return nil
+	}
+	return &rateLimiter{
+		limit:   limit,
+		rate:    float64(limit.Requests) / limit.Per.Seconds(),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the bucket of key
+// Returns false and how long until the next token is available when the bucket is empty
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	burst := float64(l.limit.Requests)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops the buckets that have refilled completely, so memory does not grow with every client seen
+// The caller must hold l.mu
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.limit.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// clientIP returns the IP address a request came from
+// Behind a proxy this is the last X-Forwarded-For entry, the one the proxy appended; clients can set the ones before it
+func clientIP(req *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, or "" if there is none
+func bearerToken(req *http.Request) string {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// allowMessage applies the message limits to a message sent outside of an HTTP request, such as over a WebSocket
+// req is the request that opened the connection. Returns how long to wait when the message is refused
+func (r *Router) allowMessage(req *http.Request) (bool, time.Duration) {
+	if r.rateLimits == nil {
+		return true, 0
+	}
+	ok, scope, wait := r.rateLimits.allow(rateLimitGroupMessages, req)
+	if !ok {
+		log.Printf("[API] Rate limit exceeded group=%s scope=%s ip=%s path=%s",
+			rateLimitGroupMessages, scope, clientIP(req, r.rateLimits.trustProxy), req.URL.Path)
+	}
+	return ok, wait
+}
+
+// rateLimited applies the rate limits of group to a handler
+// Refused requests get 429 Too Many Requests with a Retry-After header in seconds
+func (r *Router) rateLimited(group string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.rateLimits != nil {
+			if ok, scope, wait := r.rateLimits.allow(group, req); !ok {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				log.Printf("[API] Rate limit exceeded group=%s scope=%s ip=%s path=%s retry_after=%ds",
+					group, scope, clientIP(req, r.rateLimits.trustProxy), req.URL.Path, retryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				http.Error(w, fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter), http.StatusTooManyRequests)
+				return
+			}
+		}
+		next(w, req)
+	}
+}
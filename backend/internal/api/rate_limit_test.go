@@ -0,0 +1,204 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RateLimit
+		ok    bool
+	}{
+		{"30/1m", RateLimit{Requests: 30, Per: time.Minute}, true},
+		{"5/m", RateLimit{Requests: 5, Per: time.Minute}, true},
+		{"100/30s", RateLimit{Requests: 100, Per: 30 * time.Second}, true},
+		{"off", RateLimit{}, true},
+		{"30", RateLimit{}, false},
+		{"0/1m", RateLimit{}, false},
+		{"30/soon", RateLimit{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRateLimit(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseRateLimit(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(RateLimit{Requests: 2, Per: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("a"); !ok {
+			t.Fatalf("expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	ok, wait := limiter.allow("a")
+	if ok || wait != 30*time.Second {
+		t.Fatalf("expected the third request to wait 30s, got ok=%v wait=%v", ok, wait)
+	}
+	if ok, _ := limiter.allow("b"); !ok {
+		t.Error("expected another key to have its own bucket")
+	}
+
+	// One token is refilled every 30 seconds
+	now = now.Add(30 * time.Second)
+	if ok, _ := limiter.allow("a"); !ok {
+		t.Error("expected a refilled token to be allowed")
+	}
+	if ok, _ := limiter.allow("a"); ok {
+		t.Error("expected only one token to be refilled")
+	}
+
+	// Buckets idle long enough to be full are dropped
+	now = now.Add(2 * time.Minute)
+	limiter.allow("c")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected idle buckets to be swept, got %d buckets", len(limiter.buckets))
+	}
+
+	if ok, _ := newRateLimiter(RateLimit{}).allow("a"); !ok {
+		t.Error("expected a disabled limiter to allow everything")
+	}
+}
+
+func TestRouter_RateLimited(t *testing.T) {
+	r := &Router{}
+	handler := r.rateLimited(rateLimitGroupMessages, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	send := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversations/1/messages", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// Without limits every request passes
+	for i := 0; i < 5; i++ {
+		if rec := send("10.0.0.1:1234", ""); rec.Code != http.StatusCreated {
+			t.Fatalf("expected no limit before SetRateLimits, got %d", rec.Code)
+		}
+	}
+
+	r.SetRateLimits(RateLimits{
+		MessagesPerIP:    RateLimit{Requests: 2, Per: time.Minute},
+		MessagesPerToken: RateLimit{Requests: 3, Per: time.Hour},
+	})
+
+	send("10.0.0.1:1234", "")
+	send("10.0.0.1:5678", "")
+	rec := send("10.0.0.1:9999", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the IP limit is used up, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected Retry-After 30, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	// A token is limited across IPs
+	for i, ip := range []string{"10.0.0.2:1", "10.0.0.3:1", "10.0.0.4:1"} {
+		if rec := send(ip, "secret"); rec.Code != http.StatusCreated {
+			t.Fatalf("expected request %d with the token to pass, got %d", i+1, rec.Code)
+		}
+	}
+	rec = send("10.0.0.5:1", "secret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1200" {
+		t.Errorf("expected 429 with Retry-After 1200 once the token limit is used up, got %d %q",
+			rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := send("10.0.0.5:1", "other"); rec.Code != http.StatusCreated {
+		t.Errorf("expected another token to pass, got %d", rec.Code)
+	}
+}
+
+func TestClientIP_TrustProxy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "127.0.0.1:4321"
+	// The client claims to be 198.51.100.1; the proxy appends the address it saw
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+
+	if ip := clientIP(req, false); ip != "127.0.0.1" {
+		t.Errorf("expected the remote address without trusting the proxy, got %s", ip)
+	}
+	if ip := clientIP(req, true); ip != "203.0.113.7" {
+		t.Errorf("expected the address appended by the proxy, got %s", ip)
+	}
+
+	// A proxy may add its own header line instead of appending to the client's
+	req.Header.Add("X-Forwarded-For", "192.0.2.9")
+	if ip := clientIP(req, true); ip != "192.0.2.9" {
+		t.Errorf("expected the last header line, got %s", ip)
+	}
+}
+
+func TestNewRouter_RateLimitsAvatarCreation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	router := NewRouter(database, nil, "", nil)
+	defer router.GetBroadcaster().Shutdown(0)
+	router.SetRateLimits(RateLimits{AvatarsPerIP: RateLimit{Requests: 1, Per: time.Minute}})
+
+	create := func(name string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/avatars", strings.NewReader(`{"name":"`+name+`","prompt":"p"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := create("Alice"); code != http.StatusCreated {
+		t.Fatalf("expected the first avatar to be created, got %d", code)
+	}
+	if code := create("Bob"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the second avatar to be rate limited, got %d", code)
+	}
+
+	// Reading is not limited
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/avatars", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected listing avatars to pass, got %d", rec.Code)
+	}
+}
+
+func TestNewRouter_RateLimitsConversationCreation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	router := NewRouter(database, nil, "", nil)
+	defer router.GetBroadcaster().Shutdown(0)
+	router.SetRateLimits(RateLimits{MessagesPerIP: RateLimit{Requests: 1, Per: time.Minute}})
+
+	post := func(path, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("/api/conversations", `{"title":"First"}`); code != http.StatusCreated {
+		t.Fatalf("expected the first conversation to be created, got %d", code)
+	}
+	// Creating and importing share the message limit
+	if code := post("/api/conversations", `{"title":"Second","initial_message":"hi"}`); code != http.StatusTooManyRequests {
+		t.Errorf("expected the second conversation to be rate limited, got %d", code)
+	}
+	if code := post("/api/conversations/import-thread", `{"thread_id":"thread_1"}`); code != http.StatusTooManyRequests {
+		t.Errorf("expected the import to be rate limited, got %d", code)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// piiDetectionMaxTokens bounds the LLM answer listing personal information in a message
+	piiDetectionMaxTokens = 300
+
+	defaultRedactionLimit = 100
+	maxRedactionLimit     = 1000
+)
+
+// redactUserContent applies the conversation's redaction policy to the content of a user message
+// With the llm policy, values found by the LLM are redacted after the patterns; if the LLM is
+// unavailable only the patterns are applied. Returns the redacted content and the number of
+// redactions per kind
+func (h *ConversationHandler) redactUserContent(ctx context.Context, conv *models.Conversation, content string) (string, map[string]int) {
+	policy, _ := logic.ParseRedactionPolicy(conv.RedactionPolicy)
+	if policy == logic.RedactionPolicyOff {
+		return content, nil
+	}
+
+	content, counts := h.redactor.RedactPatterns(content)
+
+	if policy == logic.RedactionPolicyLLM {
+		if h.assistant == nil {
+			log.Printf("[API] Skipping LLM redaction: assistant is nil conversation_id=%d", conv.ID)
+		} else if values, err := h.detectPII(ctx, conv, content); err != nil {
+			log.Printf("[API] Warning: LLM redaction failed, only patterns applied conversation_id=%d err=%v", conv.ID, err)
+		} else if redacted, n := logic.RedactValues(content, values, logic.RedactionKindPII); n > 0 {
+			content = redacted
+			counts[logic.RedactionKindPII] += n
+		}
+	}
+
+	if len(counts) == 0 {
+		return content, nil
+	}
+	log.Printf("[API] User message redacted conversation_id=%d policy=%s redactions=%v", conv.ID, policy, counts)
+	return content, counts
+}
+
+// detectPII asks the LLM for the personal information in pattern-redacted content
+func (h *ConversationHandler) detectPII(ctx context.Context, conv *models.Conversation, content string) ([]string, error) {
+	response, err := h.assistantFor(conv).WithContext(ctx).Completion(logic.BuildPIIDetectionPrompt(content), piiDetectionMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := logic.ParsePIIDetection(response)
+	if !ok {
+		// The answer is not included since it may contain the personal information
+		return nil, errors.New("invalid PII detection response")
+	}
+	return values, nil
+}
+
+// RedactionHandler handles HTTP requests for the redaction audit
+type RedactionHandler struct {
+	db *db.DB
+}
+
+// NewRedactionHandler creates a new redaction handler
+func NewRedactionHandler(database *db.DB) *RedactionHandler {
+	return &RedactionHandler{db: database}
+}
+
+// List handles GET /api/admin/redactions
+// Filters: conversation_id, limit
+func (h *RedactionHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var conversationID int64
+	if v := query.Get("conversation_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid conversation_id", http.StatusBadRequest)
+			return
+		}
+		conversationID = id
+	}
+
+	limit := defaultRedactionLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxRedactionLimit)
+	}
+
+	redactions, err := h.db.GetRedactions(conversationID, limit)
+	if err != nil {
+		log.Printf("[API] ListRedactions failed: DB error err=%v", err)
+		http.Error(w, "Failed to list redactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactions)
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// newPIIDetectionClient creates a client whose chat completions answer with the given content
+// An empty answer makes the completion fail
+func newPIIDetectionClient(t *testing.T, answer string) *assistant.Client {
+	t.Helper()
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if answer == "" || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"message": "server error"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": answer}}},
+		})
+	}))
+	t.Cleanup(mockServer.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: mockServer.URL, TrimPrefix: "/v1"},
+	}))
+}
+
+func TestSendMessage_RedactsWithRegexPolicy(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+	handler.SetRedactor(logic.NewRedactor([]string{"Project Falcon"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations",
+		bytes.NewBufferString(`{"title": "Private", "redaction_policy": "regex"}`))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var conv CreateConversationResponse
+	json.NewDecoder(w.Body).Decode(&conv)
+	if conv.RedactionPolicy != "regex" {
+		t.Fatalf("expected redaction policy regex, got %q", conv.RedactionPolicy)
+	}
+	id := strconv.FormatInt(conv.ID, 10)
+
+	body := `{"content": "Project Falcon: mail taro@example.com or call 090-1234-5678"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/conversations/"+id+"/messages", bytes.NewBufferString(body))
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	handler.SendMessage(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var resp SendMessageResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	expected := "[REDACTED TERM]: mail [REDACTED EMAIL] or call [REDACTED PHONE]"
+	if resp.UserMessage.Content != expected {
+		t.Errorf("expected redacted content %q, got %q", expected, resp.UserMessage.Content)
+	}
+	if resp.Redactions["email"] != 1 || resp.Redactions["phone"] != 1 || resp.Redactions["term"] != 1 {
+		t.Errorf("unexpected redactions: %v", resp.Redactions)
+	}
+
+	messages, _ := handler.db.GetMessages(conv.ID)
+	if len(messages) != 1 || messages[0].Content != expected {
+		t.Errorf("expected the redacted content to be stored, got %+v", messages)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/redactions?conversation_id="+id, nil)
+	w = httptest.NewRecorder()
+	NewRedactionHandler(handler.db).List(w, req)
+	var redactions []models.Redaction
+	json.NewDecoder(w.Body).Decode(&redactions)
+	if len(redactions) != 3 || redactions[0].MessageID != resp.UserMessage.ID || redactions[0].Policy != "regex" {
+		t.Errorf("unexpected redaction audit: %+v", redactions)
+	}
+}
+
+func TestSendMessage_NoRedactionByDefault(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Open", "")
+	id := strconv.FormatInt(conv.ID, 10)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/"+id+"/messages",
+		bytes.NewBufferString(`{"content": "mail taro@example.com"}`))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.SendMessage(w, req)
+
+	var resp SendMessageResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.UserMessage.Content != "mail taro@example.com" || resp.Redactions != nil {
+		t.Errorf("expected the message to be unchanged, got %+v", resp)
+	}
+}
+
+func TestRedactUserContent_LLMPolicy(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv := &models.Conversation{ID: 1, RedactionPolicy: "llm"}
+	content := "Taro Yamada (taro@example.com) lives at 1-2-3 Minato"
+
+	handler.assistant = newPIIDetectionClient(t, `["Taro Yamada", "1-2-3 Minato"]`)
+	redacted, counts := handler.redactUserContent(context.Background(), conv, content)
+	if redacted != "[REDACTED PII] ([REDACTED EMAIL]) lives at [REDACTED PII]" {
+		t.Errorf("unexpected redaction %q", redacted)
+	}
+	if counts["pii"] != 2 || counts["email"] != 1 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+
+	// Without an answer from the LLM only the patterns are applied
+	handler.assistant = newPIIDetectionClient(t, "")
+	redacted, counts = handler.redactUserContent(context.Background(), conv, content)
+	if redacted != "Taro Yamada ([REDACTED EMAIL]) lives at 1-2-3 Minato" || counts["pii"] != 0 {
+		t.Errorf("expected pattern redaction only, got %q counts=%v", redacted, counts)
+	}
+}
+
+func TestConversation_InvalidRedactionPolicy(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations",
+		bytes.NewBufferString(`{"title": "Private", "redaction_policy": "strict"}`))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	conv, _ := handler.db.CreateConversation("Open", "")
+	id := strconv.FormatInt(conv.ID, 10)
+	for body, status := range map[string]int{
+		`{"redaction_policy": "strict"}`: http.StatusBadRequest,
+		`{"redaction_policy": "llm"}`:    http.StatusOK,
+	} {
+		req = httptest.NewRequest(http.MethodPatch, "/api/conversations/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w = httptest.NewRecorder()
+		handler.Update(w, req)
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", body, status, w.Code)
+		}
+	}
+	if got, _ := handler.db.GetConversation(conv.ID); got.RedactionPolicy != "llm" {
+		t.Errorf("expected redaction policy llm, got %q", got.RedactionPolicy)
+	}
+}
@@ -1,16 +1,31 @@
 package api
 
 import (
+	"bufio"
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/jobs"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/offline"
+	"multi-avatar-chat/internal/suggest"
+	"multi-avatar-chat/internal/tracing"
 	"multi-avatar-chat/internal/watcher"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -35,45 +50,110 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Hijack implements http.Hijacker interface for WebSocket support
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
 // Router holds the HTTP multiplexer and dependencies
 type Router struct {
 	mux                       *http.ServeMux
 	avatarHandler             *AvatarHandler
+	teamHandler               *TeamHandler
 	conversationHandler       *ConversationHandler
 	conversationAvatarHandler *ConversationAvatarHandler
 	eventsHandler             *ConversationEventsHandler
+	digestHandler             *DigestHandler
+	suggestionHandler         *SuggestionHandler
+	notificationHandler       *NotificationHandler
+	adminHandler              *AdminHandler
+	purgeHandler              *PurgeHandler
+	auditHandler              *AuditHandler
+	jobHandler                *JobHandler
+	deadLetterHandler         *DeadLetterHandler
+	redactionHandler          *RedactionHandler
+	experimentHandler         *ExperimentHandler
 	broadcaster               *EventBroadcaster
 	watcherManager            *watcher.WatcherManager
-	staticDir                 string
+	static                    *staticFiles
+	rateLimits                *rateLimitState
+	captureHandler            *CaptureHandler
+	galleryHandler            *GalleryHandler
 }
 
 // NewRouter creates a new router with all routes configured
 func NewRouter(database *db.DB, assistantClient *assistant.Client, staticDir string, watcherManager *watcher.WatcherManager) *Router {
 	// Create event broadcaster for SSE
 	broadcaster := NewEventBroadcaster()
+	broadcaster.SetHistory(database)
 
 	// Set broadcaster on watcher manager if available
 	if watcherManager != nil {
 		watcherManager.SetBroadcaster(broadcaster)
+		watcherManager.SetLoopNotifier(broadcaster)
+		watcherManager.SetResponseStreamer(broadcaster)
+	}
+
+	// Notify connected clients when the OpenAI circuit breaker opens or closes
+	if assistantClient != nil {
+		assistantClient.CircuitBreaker().OnStateChange(broadcaster.BroadcastLLMAvailability)
 	}
 
 	convHandler := NewConversationHandler(database, assistantClient)
 	convHandler.SetWatcherManager(watcherManager)
+	convHandler.SetBroadcaster(broadcaster)
+
+	adminHandler := NewAdminHandler(database, assistantClient)
+	adminHandler.SetBroadcaster(broadcaster)
+	adminHandler.SetWatcherManager(watcherManager)
+
+	eventsHandler := NewConversationEventsHandler(broadcaster)
+	eventsHandler.SetDB(database)
+	eventsHandler.SetWatcherManager(watcherManager)
+	eventsHandler.SetMessageSender(convHandler)
+
+	avatarHandler := NewAvatarHandler(database, assistantClient)
+	avatarHandler.SetWatcherManager(watcherManager)
+	avatarHandler.SetBroadcaster(broadcaster)
 
 	// Create conversation avatar handler with broadcaster
 	convAvatarHandler := NewConversationAvatarHandler(database, assistantClient, watcherManager)
 	convAvatarHandler.SetBroadcaster(broadcaster)
 
+	purgeHandler := NewPurgeHandler(database, assistantClient, watcherManager)
+	purgeHandler.SetBroadcaster(broadcaster)
+
 	r := &Router{
 		mux:                       http.NewServeMux(),
-		avatarHandler:             NewAvatarHandler(database, assistantClient),
+		avatarHandler:             avatarHandler,
+		teamHandler:               NewTeamHandler(database),
 		conversationHandler:       convHandler,
 		conversationAvatarHandler: convAvatarHandler,
-		eventsHandler:             NewConversationEventsHandler(broadcaster),
+		eventsHandler:             eventsHandler,
+		digestHandler:             NewDigestHandler(database),
+		suggestionHandler:         NewSuggestionHandler(database),
+		notificationHandler:       NewNotificationHandler(database),
+		adminHandler:              adminHandler,
+		purgeHandler:              purgeHandler,
+		auditHandler:              NewAuditHandler(database),
+		jobHandler:                NewJobHandler(database),
+		deadLetterHandler:         NewDeadLetterHandler(database, watcherManager),
+		redactionHandler:          NewRedactionHandler(database),
+		experimentHandler:         NewExperimentHandler(database),
+		captureHandler:            NewCaptureHandler(database),
+		galleryHandler:            NewGalleryHandler(database),
 		broadcaster:               broadcaster,
 		watcherManager:            watcherManager,
-		staticDir:                 staticDir,
 	}
+	if staticDir != "" {
+		r.static = newStaticFiles(staticDir)
+	}
+	eventsHandler.allowMessage = r.allowMessage
 	r.setupRoutes()
 	return r
 }
@@ -85,54 +165,136 @@ func (r *Router) setupRoutes() {
 
 	// Avatar routes
 	r.mux.HandleFunc("GET /api/avatars", r.avatarHandler.List)
-	r.mux.HandleFunc("POST /api/avatars", r.avatarHandler.Create)
+	r.mux.HandleFunc("POST /api/avatars", r.rateLimited(rateLimitGroupAvatars, r.avatarHandler.Create))
+	r.mux.HandleFunc("POST /api/avatars/import-persona", r.rateLimited(rateLimitGroupAvatars, r.avatarHandler.ImportPersona))
 	r.mux.HandleFunc("GET /api/avatars/{id}", r.avatarHandler.Get)
 	r.mux.HandleFunc("PUT /api/avatars/{id}", r.avatarHandler.Update)
 	r.mux.HandleFunc("DELETE /api/avatars/{id}", r.avatarHandler.Delete)
+	r.mux.HandleFunc("GET /api/avatars/{id}/persona", r.avatarHandler.ExportPersona)
+	r.mux.HandleFunc("POST /api/avatars/{id}/recreate-assistant", r.rateLimited(rateLimitGroupAvatars, r.avatarHandler.RecreateAssistant))
+	r.mux.HandleFunc("GET /api/avatars/{id}/prompt-experiment", r.avatarHandler.GetPromptExperiment)
+	r.mux.HandleFunc("PUT /api/avatars/{id}/prompt-experiment", r.avatarHandler.SetPromptExperiment)
+	r.mux.HandleFunc("DELETE /api/avatars/{id}/prompt-experiment", r.avatarHandler.DeletePromptExperiment)
+	r.mux.HandleFunc("GET /api/avatars/{id}/prompt-experiment/stats", r.avatarHandler.PromptExperimentStats)
+	r.mux.HandleFunc("GET /api/avatars/{id}/script", r.avatarHandler.GetAvatarScript)
+	r.mux.HandleFunc("PUT /api/avatars/{id}/script", r.avatarHandler.SetAvatarScript)
+	r.mux.HandleFunc("DELETE /api/avatars/{id}/script", r.avatarHandler.DeleteAvatarScript)
+	r.mux.HandleFunc("POST /api/avatars/{id}/script/test", r.avatarHandler.TestAvatarScript)
+
+	// Team routes
+	r.mux.HandleFunc("GET /api/teams", r.teamHandler.List)
+	r.mux.HandleFunc("POST /api/teams", r.teamHandler.Create)
+	r.mux.HandleFunc("GET /api/teams/{id}", r.teamHandler.Get)
+	r.mux.HandleFunc("PUT /api/teams/{id}", r.teamHandler.Update)
+	r.mux.HandleFunc("DELETE /api/teams/{id}", r.teamHandler.Delete)
+	r.mux.HandleFunc("POST /api/teams/{id}/members", r.teamHandler.AddMember)
+	r.mux.HandleFunc("DELETE /api/teams/{id}/members/{avatar_id}", r.teamHandler.RemoveMember)
 
 	// Conversation routes
 	r.mux.HandleFunc("GET /api/conversations", r.conversationHandler.List)
-	r.mux.HandleFunc("POST /api/conversations", r.conversationHandler.Create)
+	// Creating a conversation can post its initial_message, and importing a thread adds its messages
+	r.mux.HandleFunc("POST /api/conversations", r.rateLimited(rateLimitGroupMessages, r.conversationHandler.Create))
+	r.mux.HandleFunc("POST /api/conversations/import-thread", r.rateLimited(rateLimitGroupMessages, r.conversationHandler.ImportThread))
 	r.mux.HandleFunc("GET /api/conversations/{id}", r.conversationHandler.Get)
+	r.mux.HandleFunc("PATCH /api/conversations/{id}", r.conversationHandler.Update)
 	r.mux.HandleFunc("DELETE /api/conversations/{id}", r.conversationHandler.Delete)
+	r.mux.HandleFunc("GET /api/conversations/{id}/backlinks", r.conversationHandler.GetBacklinks)
+	r.mux.HandleFunc("GET /api/conversations/{id}/feed", r.conversationHandler.GetFeed)
+	r.mux.HandleFunc("GET /api/conversations/{id}/disclosure", r.conversationHandler.GetDisclosure)
+	r.mux.HandleFunc("GET /api/conversations/{id}/timeline", r.conversationHandler.GetTimeline)
+	r.mux.HandleFunc("PUT /api/conversations/{id}/gallery", r.conversationHandler.Publish)
+	r.mux.HandleFunc("DELETE /api/conversations/{id}/gallery", r.conversationHandler.Unpublish)
+	r.mux.HandleFunc("GET /api/conversations/{id}/presence", r.conversationHandler.GetPresence)
+	r.mux.HandleFunc("PUT /api/conversations/{id}/presence", r.conversationHandler.SetPresence)
 
 	// Message routes
 	r.mux.HandleFunc("GET /api/conversations/{id}/messages", r.conversationHandler.GetMessages)
-	r.mux.HandleFunc("POST /api/conversations/{id}/messages", r.conversationHandler.SendMessage)
+	r.mux.HandleFunc("POST /api/conversations/{id}/messages", r.rateLimited(rateLimitGroupMessages, r.conversationHandler.SendMessage))
+	r.mux.HandleFunc("POST /api/conversations/{id}/messages/bulk", r.rateLimited(rateLimitGroupMessages, r.conversationHandler.BulkInsertMessages))
+	r.mux.HandleFunc("GET /api/conversations/{id}/messages/{message_id}/deliveries", r.conversationHandler.GetDeliveries)
+	r.mux.HandleFunc("GET /api/conversations/{id}/messages/{message_id}/context", r.conversationHandler.GetMessageContext)
+	r.mux.HandleFunc("GET /api/conversations/{id}/artifacts/{artifact_id}/content", r.conversationHandler.GetArtifactContent)
 
 	// Interrupt route
 	r.mux.HandleFunc("POST /api/conversations/{id}/interrupt", r.conversationHandler.Interrupt)
+	r.mux.HandleFunc("POST /api/conversations/{id}/split", r.conversationHandler.SplitConversation)
 
 	// Conversation avatar routes
 	r.mux.HandleFunc("GET /api/conversations/{id}/avatars", r.conversationAvatarHandler.ListAvatars)
 	r.mux.HandleFunc("POST /api/conversations/{id}/avatars", r.conversationAvatarHandler.AddAvatar)
 	r.mux.HandleFunc("DELETE /api/conversations/{id}/avatars/{avatar_id}", r.conversationAvatarHandler.RemoveAvatar)
+	r.mux.HandleFunc("POST /api/conversations/{id}/avatars/{avatar_id}/recreate-thread", r.conversationAvatarHandler.RecreateThread)
+	r.mux.HandleFunc("GET /api/conversations/{id}/avatars/{avatar_id}/triggers", r.conversationAvatarHandler.ListTriggers)
+	r.mux.HandleFunc("POST /api/conversations/{id}/avatars/{avatar_id}/triggers", r.conversationAvatarHandler.CreateTrigger)
+	r.mux.HandleFunc("DELETE /api/conversations/{id}/avatars/{avatar_id}/triggers/{trigger_id}", r.conversationAvatarHandler.DeleteTrigger)
+	r.mux.HandleFunc("POST /api/conversations/{id}/teams", r.conversationAvatarHandler.AttachTeam)
+
+	// Digest route
+	r.mux.HandleFunc("POST /api/conversations/{id}/digest", r.digestHandler.Generate)
+	r.mux.HandleFunc("GET /api/conversations/{id}/suggestions", r.suggestionHandler.Get)
+
+	// Notification preference routes
+	r.mux.HandleFunc("GET /api/notifications/preferences", r.notificationHandler.ListPreferences)
+	r.mux.HandleFunc("PUT /api/notifications/preferences/{email}", r.notificationHandler.UpdatePreferences)
+	r.mux.HandleFunc("DELETE /api/notifications/preferences/{email}", r.notificationHandler.DeletePreferences)
+
+	// Admin routes
+	r.mux.HandleFunc("GET /api/admin/default-avatars", r.conversationHandler.GetDefaultAvatars)
+	r.mux.HandleFunc("PUT /api/admin/default-avatars", r.conversationHandler.SetDefaultAvatars)
+	r.mux.HandleFunc("GET /api/admin/queues", r.adminHandler.ListQueues)
+	r.mux.HandleFunc("POST /api/admin/queues/items/{id}/retry", r.adminHandler.RetryQueueItem)
+	r.mux.HandleFunc("DELETE /api/admin/queues/items/{id}", r.adminHandler.DiscardQueueItem)
+	r.mux.HandleFunc("GET /api/admin/cache", r.adminHandler.CacheStats)
+	r.mux.HandleFunc("GET /api/admin/db/queries", r.adminHandler.QueryStats)
+	r.mux.HandleFunc("DELETE /api/admin/db/queries", r.adminHandler.ResetQueryStats)
+	r.mux.HandleFunc("GET /api/admin/judgment-cache", r.adminHandler.JudgmentCacheStats)
+	r.mux.HandleFunc("GET /api/admin/sse", r.adminHandler.SSEStats)
+	r.mux.HandleFunc("GET /api/admin/assistants", r.adminHandler.ListAssistants)
+	r.mux.HandleFunc("POST /api/admin/assistants/{assistant_id}/import", r.adminHandler.ImportAssistant)
+	r.mux.HandleFunc("POST /api/admin/assistants/{assistant_id}/relink", r.adminHandler.RelinkAssistant)
+	r.mux.HandleFunc("POST /api/admin/assistants/sync-instructions", r.adminHandler.SyncInstructions)
+	r.mux.HandleFunc("GET /api/admin/assistants/drift", r.adminHandler.ListAssistantDrift)
+	r.mux.HandleFunc("POST /api/admin/assistants/drift/{avatar_id}/resync", r.adminHandler.ResyncAssistant)
+	r.mux.HandleFunc("POST /api/admin/purge/conversations/{id}", r.purgeHandler.PurgeConversation)
+	r.mux.HandleFunc("POST /api/admin/purge/content", r.purgeHandler.PurgeContent)
+	r.mux.HandleFunc("GET /api/admin/purges", r.purgeHandler.ListPurges)
+	r.mux.HandleFunc("GET /api/admin/audit", r.auditHandler.List)
+	r.mux.HandleFunc("GET /api/admin/dead-letters", r.deadLetterHandler.List)
+	r.mux.HandleFunc("GET /api/admin/dead-letters/{id}", r.deadLetterHandler.Get)
+	r.mux.HandleFunc("POST /api/admin/dead-letters/{id}/retry", r.deadLetterHandler.Retry)
+	r.mux.HandleFunc("GET /api/admin/redactions", r.redactionHandler.List)
+	r.mux.HandleFunc("GET /api/admin/experiments", r.experimentHandler.List)
+	r.mux.HandleFunc("PUT /api/admin/experiments/{avatar_id}", r.experimentHandler.Set)
+	r.mux.HandleFunc("DELETE /api/admin/experiments/{avatar_id}", r.experimentHandler.Delete)
+	r.mux.HandleFunc("GET /api/admin/experiments/comparisons", r.experimentHandler.ListComparisons)
+	r.mux.HandleFunc("GET /api/admin/experiments/comparisons/{id}", r.experimentHandler.GetComparison)
+	r.mux.HandleFunc("POST /api/admin/experiments/comparisons/{id}/preference", r.experimentHandler.SetPreference)
+	r.mux.HandleFunc("GET "+capturePath, r.captureHandler.Download)
+	r.mux.HandleFunc("DELETE "+capturePath, r.captureHandler.Clear)
+
+	// Job routes
+	r.mux.HandleFunc("GET /api/jobs/{id}", r.jobHandler.Get)
 
 	// SSE events route
 	r.mux.HandleFunc("GET /api/conversations/{id}/events", r.eventsHandler.HandleEvents)
+	r.mux.HandleFunc("GET /api/conversations/{id}/events/history", r.eventsHandler.HandleHistory)
+	r.mux.HandleFunc("GET /api/conversations/{id}/ws", r.eventsHandler.HandleWebSocket)
+	r.mux.HandleFunc("GET /api/conversations/{id}/viewers", r.eventsHandler.HandleViewers)
+	r.mux.HandleFunc("GET /api/events/schema", r.eventsHandler.HandleSchema)
+
+	// Public gallery of published conversations (no authentication)
+	r.mux.HandleFunc("GET /gallery", r.galleryHandler.Index)
+	r.mux.HandleFunc("GET /gallery/{slug}", r.galleryHandler.Transcript)
 
 	// Static file serving (for frontend)
-	if r.staticDir != "" {
+	if r.static != nil {
 		r.mux.HandleFunc("GET /", r.serveStatic)
 	}
 }
 
-// serveStatic serves static files from the static directory
+// serveStatic serves the frontend build from the static directory
 func (r *Router) serveStatic(w http.ResponseWriter, req *http.Request) {
-	path := req.URL.Path
-	if path == "/" {
-		path = "/index.html"
-	}
-
-	filePath := filepath.Join(r.staticDir, path)
-
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Serve index.html for SPA routing
-		filePath = filepath.Join(r.staticDir, "index.html")
-	}
-
-	http.ServeFile(w, req, filePath)
+	r.static.ServeHTTP(w, req)
 }
 
 // ServeHTTP implements the http.Handler interface
@@ -141,8 +303,11 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	// Add CORS headers for development
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, traceparent, tracestate, "+
+		"If-Match, If-None-Match, If-Modified-Since, "+ActorHeader)
+	// Let browser clients read the validators for conditional requests
+	w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified")
 
 	if req.Method == "OPTIONS" {
 		log.Printf("[HTTP] CORS preflight method=OPTIONS path=%s", req.URL.Path)
@@ -150,17 +315,54 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Skip logging for static files, health checks, and SSE endpoints
-	shouldLog := strings.HasPrefix(req.URL.Path, "/api/") && !strings.HasSuffix(req.URL.Path, "/events")
+	// Skip logging for static files, health checks, and SSE and WebSocket endpoints
+	shouldLog := strings.HasPrefix(req.URL.Path, "/api/") && !strings.HasSuffix(req.URL.Path, "/events") &&
+		!strings.HasSuffix(req.URL.Path, "/ws")
 
 	if shouldLog {
 		log.Printf("[HTTP] Request started method=%s path=%s", req.Method, req.URL.Path)
 	}
 
+	// Trace API requests, continuing any trace started by the client
+	// SSE streams are left out since they stay open for the whole session
+	var span trace.Span
+	if shouldLog {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span = tracing.Start(ctx, req.Method+" "+req.URL.Path,
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", req.URL.Path),
+		)
+		req = req.WithContext(ctx)
+	}
+
+	// Record the request and response in debug mode
+	var capture *pendingCapture
+	if shouldLog {
+		if capture = r.captureHandler.begin(req); capture != nil {
+			w = capture.wrap(w)
+		}
+	}
+
 	// Wrap response writer to capture status code
 	wrapped := newResponseWriter(w)
 	r.mux.ServeHTTP(wrapped, req)
 
+	if capture != nil {
+		r.captureHandler.finish(capture, wrapped.statusCode, time.Since(start))
+	}
+
+	if span != nil {
+		// Name the span after the route pattern so requests for different IDs group together
+		if req.Pattern != "" {
+			span.SetName(req.Pattern)
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", wrapped.statusCode))
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+		}
+		span.End()
+	}
+
 	if shouldLog {
 		log.Printf("[HTTP] Request completed method=%s path=%s status=%d duration=%v",
 			req.Method, req.URL.Path, wrapped.statusCode, time.Since(start))
@@ -171,3 +373,89 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func (r *Router) GetBroadcaster() *EventBroadcaster {
 	return r.broadcaster
 }
+
+// SetOfflineQueue queues user messages while the OpenAI API is unavailable
+func (r *Router) SetOfflineQueue(q *offline.Queue) {
+	r.conversationHandler.SetOfflineQueue(q)
+}
+
+// SetForwardConcurrency sets how many avatar threads a user message is written to at once
+func (r *Router) SetForwardConcurrency(n int) {
+	r.conversationHandler.SetForwardConcurrency(n)
+}
+
+// SetMaxConversationAvatars sets how many avatars a conversation can have
+func (r *Router) SetMaxConversationAvatars(n int) {
+	r.conversationHandler.SetMaxAvatars(n)
+	r.conversationAvatarHandler.SetMaxAvatars(n)
+}
+
+// SetDefaultConversationTTL sets the TTL of conversations created without one
+func (r *Router) SetDefaultConversationTTL(d time.Duration) {
+	r.conversationHandler.SetDefaultTTL(d)
+}
+
+// SetRenameNotices enables or disables the system message posted when an avatar is renamed
+func (r *Router) SetRenameNotices(enabled bool) {
+	r.avatarHandler.SetRenameNotices(enabled)
+}
+
+// SetInstructions sets the builder of assistant instructions, which adds the safety preamble
+func (r *Router) SetInstructions(instructions logic.InstructionsBuilder) {
+	r.avatarHandler.SetInstructions(instructions)
+	r.adminHandler.SetInstructions(instructions)
+}
+
+// SetBehaviorWebhookHosts sets the hosts behavior script webhooks may call at local or private addresses
+func (r *Router) SetBehaviorWebhookHosts(hosts []string) {
+	r.avatarHandler.SetBehaviorWebhookHosts(hosts)
+}
+
+// SetSendTimeout sets how long SendMessage waits for deliveries before answering 202 Accepted
+func (r *Router) SetSendTimeout(d time.Duration) {
+	r.conversationHandler.SetSendTimeout(d)
+}
+
+// SetRedactor sets the redactor applied to user messages of conversations with a redaction policy
+func (r *Router) SetRedactor(redactor *logic.Redactor) {
+	r.conversationHandler.SetRedactor(redactor)
+}
+
+// SetSSEHeartbeat sets how often idle SSE streams get a heartbeat comment; 0 disables heartbeats
+func (r *Router) SetSSEHeartbeat(d time.Duration) {
+	r.eventsHandler.SetHeartbeatInterval(d)
+}
+
+// SetRateLimits limits message sending and avatar creation per client IP and per API token
+func (r *Router) SetRateLimits(limits RateLimits) {
+	r.rateLimits = newRateLimitState(limits)
+}
+
+// SetCapture records API requests and responses, keeping the newest keep captures; 0 disables recording
+func (r *Router) SetCapture(keep int) {
+	r.captureHandler.SetRecording(keep)
+}
+
+// SendUserMessage posts a user message to a conversation from within the process
+func (r *Router) SendUserMessage(ctx context.Context, conversationID int64, content string) (*models.Message, error) {
+	return r.conversationHandler.SendUserMessage(ctx, conversationID, content)
+}
+
+// SetJobRunner runs thread imports and on-demand digests as background jobs
+// Must be called before the runner is started so queued jobs can be resumed
+func (r *Router) SetJobRunner(runner *jobs.Runner) {
+	r.conversationHandler.SetJobRunner(runner)
+	r.digestHandler.SetJobRunner(runner)
+}
+
+// SetDigestJob enables on-demand digest generation and SSE delivery of scheduled digests
+func (r *Router) SetDigestJob(job *digest.Job) {
+	job.SetBroadcaster(r.broadcaster)
+	r.digestHandler.SetJob(job)
+}
+
+// SetSuggestionJob enables suggested replies and their SSE delivery
+func (r *Router) SetSuggestionJob(job *suggest.Job) {
+	job.SetBroadcaster(r.broadcaster)
+	r.suggestionHandler.SetJob(job)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticManifestFile is the file written by the frontend build that maps entry names to fingerprinted assets
+const StaticManifestFile = "manifest.json"
+
+const (
+	// cacheControlImmutable is sent for fingerprinted assets, whose content never changes under the same name
+	cacheControlImmutable = "public, max-age=31536000, immutable"
+	// cacheControlRevalidate is sent for index.html and other unversioned files so deploys are picked up
+	cacheControlRevalidate = "no-cache"
+
+	// minCompressSize is the smallest file compressed on the fly
+	minCompressSize = 1024
+)
+
+// fingerprintRegex matches file names with a content hash before the extension, such as bundle.1a2b3c4d.js
+var fingerprintRegex = regexp.MustCompile(`\.[0-9a-f]{8,}\.[A-Za-z0-9]+$`)
+
+// compressibleExts are the extensions of text files worth compressing
+var compressibleExts = map[string]bool{
+	".html": true, ".js": true, ".css": true, ".json": true,
+	".map": true, ".svg": true, ".txt": true, ".xml": true,
+}
+
+// precompressedEncodings are the encodings of precompressed siblings, in order of preference
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{encoding: "br", ext: ".br"},
+	{encoding: "gzip", ext: ".gz"},
+}
+
+// staticFiles serves the frontend build directory
+// Missing paths with a file extension are 404s; other missing paths fall back to index.html for SPA routing.
+// Fingerprinted assets are cached forever, everything else is revalidated with an ETag.
+type staticFiles struct {
+	dir           string
+	fingerprinted map[string]bool
+
+	mu      sync.Mutex
+	entries map[string]*staticEntry
+}
+
+// staticEntry caches the ETag and on-the-fly compressed content of a file
+type staticEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+	gzipped []byte
+}
+
+// newStaticFiles creates a static file server for dir and loads the asset manifest if there is one
+func newStaticFiles(dir string) *staticFiles {
+	s := &staticFiles{
+		dir:           dir,
+		fingerprinted: make(map[string]bool),
+		entries:       make(map[string]*staticEntry),
+	}
+
+	assets, err := loadStaticManifest(filepath.Join(dir, StaticManifestFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[API] Failed to load static manifest dir=%s err=%v", dir, err)
+	}
+	for _, asset := range assets {
+		s.fingerprinted[path.Clean("/"+asset)] = true
+	}
+	return s
+}
+
+// loadStaticManifest returns the fingerprinted asset paths listed in a manifest file
+func loadStaticManifest(manifestPath string) ([]string, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	assets := make([]string, 0, len(manifest))
+	for _, asset := range manifest {
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// ServeHTTP serves a file of the static directory
+func (s *staticFiles) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := path.Clean("/" + req.URL.Path)
+	if name == "/" {
+		name = "/index.html"
+	}
+
+	filePath := filepath.Join(s.dir, filepath.FromSlash(name))
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		// Assets must not fall back to index.html, or a stale bundle name is cached as HTML
+		if ext := path.Ext(name); ext != "" && ext != ".html" {
+			http.NotFound(w, req)
+			return
+		}
+
+		name = "/index.html"
+		filePath = filepath.Join(s.dir, "index.html")
+		if info, err = os.Stat(filePath); err != nil {
+			http.NotFound(w, req)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", s.cacheControl(name))
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if s.servePrecompressed(w, req, name, filePath) {
+		return
+	}
+
+	entry, err := s.entry(filePath, info)
+	if err != nil {
+		log.Printf("[API] Failed to read static file path=%s err=%v", filePath, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if entry.gzipped != nil && acceptsEncoding(req, "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("ETag", variantETag(entry.etag, "gzip"))
+		http.ServeContent(w, req, name, entry.modTime, bytes.NewReader(entry.gzipped))
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("ETag", entry.etag)
+	http.ServeContent(w, req, name, entry.modTime, file)
+}
+
+// servePrecompressed serves a .br or .gz sibling written by the frontend build if the client accepts it
+// Returns false when no acceptable sibling exists
+func (s *staticFiles) servePrecompressed(w http.ResponseWriter, req *http.Request, name, filePath string) bool {
+	for _, candidate := range precompressedEncodings {
+		if !acceptsEncoding(req, candidate.encoding) {
+			continue
+		}
+
+		info, err := os.Stat(filePath + candidate.ext)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		entry, err := s.entry(filePath+candidate.ext, info)
+		if err != nil {
+			continue
+		}
+		file, err := os.Open(filePath + candidate.ext)
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Encoding", candidate.encoding)
+		w.Header().Set("ETag", entry.etag)
+		// ServeContent detects the content type from name, which keeps the original extension
+		http.ServeContent(w, req, name, entry.modTime, file)
+		return true
+	}
+	return false
+}
+
+// cacheControl returns the Cache-Control header for a file
+func (s *staticFiles) cacheControl(name string) string {
+	if s.fingerprinted[name] || fingerprintRegex.MatchString(path.Base(name)) {
+		return cacheControlImmutable
+	}
+	return cacheControlRevalidate
+}
+
+// entry returns the cached ETag and compressed content of a file, reading it again if it changed
+func (s *staticFiles) entry(filePath string, info os.FileInfo) (*staticEntry, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[filePath]
+	s.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry, nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	entry = &staticEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}
+	if len(data) >= minCompressSize && compressibleExts[filepath.Ext(filePath)] {
+		if entry.gzipped, err = gzipBytes(data); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	s.entries[filePath] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+// gzipBytes compresses data with the best gzip compression
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// variantETag derives the ETag of an encoded representation, which must differ from the identity ETag
+func variantETag(etag, encoding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of a request allows encoding
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
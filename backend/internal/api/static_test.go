@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupStaticDir writes a frontend build with a fingerprinted bundle to a temporary directory
+func setupStaticDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func serveStaticRequest(s *staticFiles, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStaticFiles_FallbackAndNotFound(t *testing.T) {
+	dir := setupStaticDir(t, map[string]string{
+		"index.html":             "<html>app</html>",
+		"bundle.0123abcd4567.js": "console.log('app')",
+	})
+	s := newStaticFiles(dir)
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/", http.StatusOK, "<html>app</html>"},
+		{"/conversations/12", http.StatusOK, "<html>app</html>"},
+		{"/bundle.0123abcd4567.js", http.StatusOK, "console.log('app')"},
+		{"/bundle.deadbeef0000.js", http.StatusNotFound, ""},
+		{"/styles.css", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := serveStaticRequest(s, tt.target, nil)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.wantStatus, rec.Code)
+			continue
+		}
+		if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+			t.Errorf("%s: unexpected body %q", tt.target, rec.Body.String())
+		}
+	}
+}
+
+func TestStaticFiles_CacheControl(t *testing.T) {
+	dir := setupStaticDir(t, map[string]string{
+		"index.html":             "<html>app</html>",
+		"bundle.0123abcd4567.js": "console.log('app')",
+		"vendor.js":              "console.log('vendor')",
+		"logo.png":               "png",
+		StaticManifestFile:       `{"main.js": "bundle.0123abcd4567.js", "vendor.js": "vendor.js"}`,
+	})
+	s := newStaticFiles(dir)
+
+	tests := map[string]string{
+		"/":                       cacheControlRevalidate,
+		"/conversations/1":        cacheControlRevalidate,
+		"/bundle.0123abcd4567.js": cacheControlImmutable,
+		"/vendor.js":              cacheControlImmutable,
+		"/logo.png":               cacheControlRevalidate,
+	}
+	for target, want := range tests {
+		rec := serveStaticRequest(s, target, nil)
+		if got := rec.Header().Get("Cache-Control"); got != want {
+			t.Errorf("%s: expected Cache-Control %q, got %q", target, want, got)
+		}
+	}
+}
+
+func TestStaticFiles_ETag(t *testing.T) {
+	dir := setupStaticDir(t, map[string]string{"index.html": "<html>app</html>"})
+	s := newStaticFiles(dir)
+
+	rec := serveStaticRequest(s, "/", nil)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	rec = serveStaticRequest(s, "/", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	// A rebuilt file gets a new ETag
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>v2</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rec = serveStaticRequest(s, "/", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("expected the changed file to be served with a new ETag, got %d %s", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestStaticFiles_Compression(t *testing.T) {
+	script := strings.Repeat("console.log('compress me');\n", 100)
+	dir := setupStaticDir(t, map[string]string{
+		"index.html":                "<html>app</html>",
+		"bundle.0123abcd4567.js":    script,
+		"bundle.0123abcd4567.js.br": "brotli-bytes",
+	})
+	s := newStaticFiles(dir)
+	target := "/bundle.0123abcd4567.js"
+
+	rec := serveStaticRequest(s, target, map[string]string{"Accept-Encoding": "gzip, deflate, br"})
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli-bytes" {
+		t.Errorf("expected the precompressed brotli file, got encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("expected a JavaScript content type, got %q", rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+
+	rec = serveStaticRequest(s, target, map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip when brotli is refused, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("invalid gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if string(body) != script {
+		t.Error("expected the gzip body to decompress to the original file")
+	}
+	gzipETag := rec.Header().Get("ETag")
+
+	rec = serveStaticRequest(s, target, nil)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != script {
+		t.Error("expected the uncompressed file without Accept-Encoding")
+	}
+	if rec.Header().Get("ETag") == gzipETag {
+		t.Error("expected the gzip and identity representations to have different ETags")
+	}
+
+	// Small files are not worth compressing
+	rec = serveStaticRequest(s, "/", map[string]string{"Accept-Encoding": "gzip"})
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected a small file to be served uncompressed, got %q", rec.Header().Get("Content-Encoding"))
+	}
+}
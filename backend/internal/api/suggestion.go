@@ -0,0 +1,83 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/suggest"
+)
+
+// SuggestionHandler handles suggested reply HTTP requests
+type SuggestionHandler struct {
+	db  *db.DB
+	job *suggest.Job
+}
+
+// NewSuggestionHandler creates a new suggestion handler
+func NewSuggestionHandler(database *db.DB) *SuggestionHandler {
+	return &SuggestionHandler{
+		db: database,
+	}
+}
+
+// SetJob sets the job that generates suggestions
+func (h *SuggestionHandler) SetJob(job *suggest.Job) {
+	h.job = job
+}
+
+// SuggestionsResponse represents the suggested replies of a conversation in API responses
+type SuggestionsResponse struct {
+	ConversationID int64 `json:"conversation_id"`
+	// MessageID is the last message the suggestions follow up on; 0 when there are none
+	MessageID   int64    `json:"message_id"`
+	Suggestions []string `json:"suggestions"`
+	CreatedAt   string   `json:"created_at,omitempty"`
+}
+
+// Get handles GET /api/conversations/{id}/suggestions
+// Returns the suggestions for the latest message, generating them if the conversation has gone quiet.
+// The list is empty while the conversation is active
+func (h *SuggestionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.job == nil {
+		http.Error(w, "Suggestions are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	conv, err := h.db.GetConversation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	s, err := h.job.ForConversation(conv)
+	if err != nil {
+		log.Printf("[API] GetSuggestions failed: conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := SuggestionsResponse{ConversationID: conv.ID, Suggestions: []string{}}
+	if s != nil {
+		resp.MessageID = s.MessageID
+		resp.Suggestions = s.Suggestions
+		resp.CreatedAt = s.CreatedAt.Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
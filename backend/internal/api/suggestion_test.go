@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/suggest"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestSuggestionHandler(t *testing.T) (*SuggestionHandler, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	return NewSuggestionHandler(database), database
+}
+
+func TestGetSuggestions(t *testing.T) {
+	handler, database := setupTestSuggestionHandler(t)
+
+	conv, _ := database.CreateConversation("Planning", "")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	get := func() SuggestionsResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/suggestions", nil)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler.Get(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var resp SuggestionsResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	// A conversation that is still active has no suggestions
+	handler.SetJob(suggest.NewJob(database, nil, time.Hour))
+	if resp := get(); len(resp.Suggestions) != 0 || resp.MessageID != 0 {
+		t.Errorf("expected no suggestions, got %+v", resp)
+	}
+
+	handler.SetJob(suggest.NewJob(database, nil, time.Nanosecond))
+	if resp := get(); len(resp.Suggestions) == 0 || resp.MessageID != msg.ID {
+		t.Errorf("expected suggestions for the last message, got %+v", resp)
+	}
+}
+
+func TestGetSuggestions_NotEnabled(t *testing.T) {
+	handler, database := setupTestSuggestionHandler(t)
+
+	database.CreateConversation("Planning", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/1/suggestions", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.Get(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// TeamHandler handles avatar team HTTP requests
+type TeamHandler struct {
+	db *db.DB
+}
+
+// NewTeamHandler creates a new team handler
+func NewTeamHandler(database *db.DB) *TeamHandler {
+	return &TeamHandler{
+		db: database,
+	}
+}
+
+// CreateTeamRequest represents the request body for creating a team
+type CreateTeamRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	AvatarIDs   []int64 `json:"avatar_ids,omitempty"`
+}
+
+// UpdateTeamRequest represents the request body for updating a team
+type UpdateTeamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AddTeamMemberRequest represents the request body for adding a team member
+type AddTeamMemberRequest struct {
+	AvatarID int64 `json:"avatar_id"`
+}
+
+// TeamResponse represents a team in API responses
+type TeamResponse struct {
+	ID          int64            `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Members     []AvatarResponse `json:"members"`
+	CreatedAt   string           `json:"created_at"`
+}
+
+// newTeamResponse converts a team and its members to the API representation
+func newTeamResponse(team *models.Team, members []models.Avatar) TeamResponse {
+	response := TeamResponse{
+		ID:          team.ID,
+		Name:        team.Name,
+		Description: team.Description,
+		Members:     make([]AvatarResponse, len(members)),
+		CreatedAt:   team.CreatedAt.Format(time.RFC3339),
+	}
+	for i := range members {
+		response.Members[i] = newAvatarResponse(&members[i])
+	}
+	return response
+}
+
+// Create handles POST /api/teams
+func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[API] Create team started")
+
+	var req CreateTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[API] Create team failed: invalid request body err=%v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !logic.IsMentionableName(req.Name) {
+		log.Printf("[API] Create team failed: invalid name=%q", req.Name)
+		http.Error(w, "Name is required and must be usable as an @mention (letters, numbers, underscores)", http.StatusBadRequest)
+		return
+	}
+
+	// Validate members before creating anything
+	for _, avatarID := range req.AvatarIDs {
+		if _, err := h.db.GetAvatar(avatarID); err == sql.ErrNoRows {
+			log.Printf("[API] Create team failed: avatar not found avatar_id=%d", avatarID)
+			http.Error(w, "Avatar not found", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("[API] Create team failed: DB error getting avatar err=%v", err)
+			http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	team, err := h.db.CreateTeam(req.Name, req.Description)
+	if err == db.ErrDuplicate {
+		log.Printf("[API] Create team failed: duplicate name=%q", req.Name)
+		http.Error(w, "A team with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[API] Create team failed: DB error err=%v", err)
+		http.Error(w, "Failed to create team", http.StatusInternalServerError)
+		return
+	}
+
+	for _, avatarID := range req.AvatarIDs {
+		if err := h.db.AddTeamMember(team.ID, avatarID); err != nil {
+			log.Printf("[API] Create team failed: DB error adding member team_id=%d avatar_id=%d err=%v", team.ID, avatarID, err)
+			http.Error(w, "Failed to add team member", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	members, err := h.db.GetTeamMembers(team.ID)
+	if err != nil {
+		log.Printf("[API] Create team failed: DB error getting members err=%v", err)
+		http.Error(w, "Failed to get team members", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Create team completed team_id=%d name=%q members=%d", team.ID, team.Name, len(members))
+	recordAudit(h.db, r, models.AuditActionTeamCreate, "team", strconv.FormatInt(team.ID, 10),
+		nil, newTeamResponse(team, members))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newTeamResponse(team, members))
+}
+
+// List handles GET /api/teams
+func (h *TeamHandler) List(w http.ResponseWriter, r *http.Request) {
+	teams, err := h.db.GetAllTeams()
+	if err != nil {
+		http.Error(w, "Failed to get teams", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]TeamResponse, len(teams))
+	for i := range teams {
+		members, err := h.db.GetTeamMembers(teams[i].ID)
+		if err != nil {
+			http.Error(w, "Failed to get team members", http.StatusInternalServerError)
+			return
+		}
+		response[i] = newTeamResponse(&teams[i], members)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Get handles GET /api/teams/{id}
+func (h *TeamHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.db.GetTeam(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get team", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.db.GetTeamMembers(id)
+	if err != nil {
+		http.Error(w, "Failed to get team members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTeamResponse(team, members))
+}
+
+// Update handles PUT /api/teams/{id}
+func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !logic.IsMentionableName(req.Name) {
+		http.Error(w, "Name is required and must be usable as an @mention (letters, numbers, underscores)", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.db.UpdateTeam(id, req.Name, req.Description)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err == db.ErrDuplicate {
+		http.Error(w, "A team with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update team", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.db.GetTeamMembers(id)
+	if err != nil {
+		http.Error(w, "Failed to get team members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTeamResponse(team, members))
+}
+
+// Delete handles DELETE /api/teams/{id}
+// Conversations the team was attached to keep their avatars
+func (h *TeamHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.db.GetTeam(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get team", http.StatusInternalServerError)
+		return
+	}
+	members, err := h.db.GetTeamMembers(id)
+	if err != nil {
+		http.Error(w, "Failed to get team members", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.DeleteTeam(id); err == sql.ErrNoRows {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to delete team", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditActionTeamDelete, "team", strconv.FormatInt(id, 10),
+		newTeamResponse(team, members), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddMember handles POST /api/teams/{id}/members
+func (h *TeamHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AddTeamMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetTeam(id); err == sql.ErrNoRows {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get team", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := h.db.GetAvatar(req.AvatarID); err == sql.ErrNoRows {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get avatar", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.AddTeamMember(id, req.AvatarID); err != nil {
+		http.Error(w, "Failed to add team member", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Team member added team_id=%d avatar_id=%d", id, req.AvatarID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember handles DELETE /api/teams/{id}/members/{avatar_id}
+func (h *TeamHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	avatarID, err := strconv.ParseInt(r.PathValue("avatar_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid avatar ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RemoveTeamMember(id, avatarID); err == sql.ErrNoRows {
+		http.Error(w, "Avatar not in team", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to remove team member", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[API] Team member removed team_id=%d avatar_id=%d", id, avatarID)
+	w.WriteHeader(http.StatusNoContent)
+}
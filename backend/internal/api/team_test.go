@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func setupTestTeamHandler(t *testing.T) (*TeamHandler, *db.DB) {
+	t.Helper()
+
+	database := testutil.NewTestDB(t)
+
+	handler := NewTeamHandler(database)
+
+	return handler, database
+}
+
+func TestCreateTeamHandler(t *testing.T) {
+	handler, database := setupTestTeamHandler(t)
+
+	alice, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Prompt", "asst_2")
+
+	body, _ := json.Marshal(CreateTeamRequest{Name: "Support", AvatarIDs: []int64{alice.ID, bob.ID}})
+	req := httptest.NewRequest(http.MethodPost, "/api/teams", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var resp TeamResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Name != "Support" {
+		t.Errorf("expected name 'Support', got '%s'", resp.Name)
+	}
+	if len(resp.Members) != 2 {
+		t.Errorf("expected 2 members, got %d", len(resp.Members))
+	}
+}
+
+func TestCreateTeamHandler_InvalidName(t *testing.T) {
+	handler, _ := setupTestTeamHandler(t)
+
+	for _, name := range []string{"", "Support Team", "@support"} {
+		body, _ := json.Marshal(CreateTeamRequest{Name: name})
+		req := httptest.NewRequest(http.MethodPost, "/api/teams", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("name %q: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestCreateTeamHandler_Duplicate(t *testing.T) {
+	handler, database := setupTestTeamHandler(t)
+
+	database.CreateTeam("Support", "")
+
+	body, _ := json.Marshal(CreateTeamRequest{Name: "Support"})
+	req := httptest.NewRequest(http.MethodPost, "/api/teams", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestCreateTeamHandler_UnknownAvatar(t *testing.T) {
+	handler, database := setupTestTeamHandler(t)
+
+	body, _ := json.Marshal(CreateTeamRequest{Name: "Support", AvatarIDs: []int64{999}})
+	req := httptest.NewRequest(http.MethodPost, "/api/teams", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// No team should have been created
+	teams, _ := database.GetAllTeams()
+	if len(teams) != 0 {
+		t.Errorf("expected no teams, got %d", len(teams))
+	}
+}
+
+func TestTeamMembersHandler(t *testing.T) {
+	handler, database := setupTestTeamHandler(t)
+
+	team, _ := database.CreateTeam("Support", "")
+	avatar, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+
+	body, _ := json.Marshal(AddTeamMemberRequest{AvatarID: avatar.ID})
+	req := httptest.NewRequest(http.MethodPost, "/api/teams/1/members", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.AddMember(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	members, _ := database.GetTeamMembers(team.ID)
+	if len(members) != 1 {
+		t.Fatalf("expected 1 member, got %d", len(members))
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/teams/1/members/1", nil)
+	req.SetPathValue("id", "1")
+	req.SetPathValue("avatar_id", "1")
+	w = httptest.NewRecorder()
+	handler.RemoveMember(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	members, _ = database.GetTeamMembers(team.ID)
+	if len(members) != 0 {
+		t.Errorf("expected 0 members, got %d", len(members))
+	}
+}
+
+func TestDeleteTeamHandler_NotFound(t *testing.T) {
+	handler, _ := setupTestTeamHandler(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/teams/999", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	handler.Delete(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
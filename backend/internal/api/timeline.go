@@ -0,0 +1,205 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// targetTimelineWindows is how many windows an automatically chosen window size aims to stay under
+	targetTimelineWindows = 100
+	// maxTimelineWindows is how many windows a requested window size may split the conversation into
+	maxTimelineWindows = 1000
+)
+
+// timelineWindowSizes are the window sizes chosen from when the request does not set one
+var timelineWindowSizes = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// TimelineResponse is the activity of a conversation's participants over time, for activity charts
+// Start and End cover the first and last message; windows start at multiples of the window size
+// since the Unix epoch, in UTC
+type TimelineResponse struct {
+	ConversationID int64                 `json:"conversation_id"`
+	WindowSeconds  int64                 `json:"window_seconds"`
+	MessageCount   int                   `json:"message_count"`
+	Start          *time.Time            `json:"start,omitempty"`
+	End            *time.Time            `json:"end,omitempty"`
+	Participants   []TimelineParticipant `json:"participants"`
+}
+
+// TimelineParticipant is one sender of a conversation and the windows it wrote in
+// Participants are ordered by their first message; avatars that never wrote come last
+type TimelineParticipant struct {
+	SenderType   models.SenderType `json:"sender_type"`
+	AvatarID     *int64            `json:"avatar_id,omitempty"`
+	Name         string            `json:"name"`
+	MessageCount int               `json:"message_count"`
+	Windows      []TimelineWindow  `json:"windows"`
+}
+
+// TimelineWindow is how much a participant wrote in one time window; windows without messages are left out
+type TimelineWindow struct {
+	Start      time.Time `json:"start"`
+	Messages   int       `json:"messages"`
+	Characters int       `json:"characters"`
+}
+
+// GetTimeline handles GET /api/conversations/{id}/timeline
+// The optional window query parameter sets the window size, such as 5m or 1h; by default the
+// smallest of timelineWindowSizes that covers the conversation in targetTimelineWindows windows is used
+func (h *ConversationHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var window time.Duration
+	if v := r.URL.Query().Get("window"); v != "" {
+		window, err = time.ParseDuration(v)
+		if err != nil || window < time.Second {
+			http.Error(w, "window must be a duration of at least 1s, such as 5m or 1h", http.StatusBadRequest)
+			return
+		}
+		window = window.Truncate(time.Second)
+	}
+
+	if _, err := h.db.GetConversation(id); err == sql.ErrNoRows {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	count, first, last, err := h.db.GetMessageTimeRange(id)
+	if err != nil {
+		log.Printf("[API] GetTimeline failed: DB error getting time range conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+	span := last.Sub(first)
+	if window == 0 {
+		window = timelineWindowFor(span)
+	} else if span/window+1 > maxTimelineWindows {
+		http.Error(w, "window too small: the conversation would span more than "+strconv.Itoa(maxTimelineWindows)+" windows",
+			http.StatusBadRequest)
+		return
+	}
+
+	activity, err := h.db.GetMessageActivity(id, window)
+	if err != nil {
+		http.Error(w, "Failed to get message activity", http.StatusInternalServerError)
+		return
+	}
+	members, err := h.db.GetConversationAvatars(id)
+	if err != nil {
+		log.Printf("[API] GetTimeline failed: DB error getting avatars conversation_id=%d err=%v", id, err)
+		http.Error(w, "Failed to get avatars", http.StatusInternalServerError)
+		return
+	}
+
+	response := TimelineResponse{
+		ConversationID: id,
+		WindowSeconds:  int64(window / time.Second),
+		MessageCount:   count,
+		Participants:   h.timelineParticipants(activity, members),
+	}
+	if count > 0 {
+		response.Start, response.End = &first, &last
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// timelineWindowFor returns the smallest window size that splits span into at most targetTimelineWindows windows
+func timelineWindowFor(span time.Duration) time.Duration {
+	for _, size := range timelineWindowSizes {
+		if span/size < targetTimelineWindows {
+			return size
+		}
+	}
+	return timelineWindowSizes[len(timelineWindowSizes)-1]
+}
+
+// timelineParticipants groups message activity by sender
+// Avatars that left the conversation are named from the avatar list, and members without messages are added last
+func (h *ConversationHandler) timelineParticipants(activity []models.MessageActivity, members []models.Avatar) []TimelineParticipant {
+	names := make(map[int64]string, len(members))
+	for _, a := range members {
+		names[a.ID] = a.Name
+	}
+
+	participants := []TimelineParticipant{}
+	index := make(map[string]int)
+	for _, a := range activity {
+		key := string(a.SenderType)
+		if a.SenderID != nil {
+			key += ":" + strconv.FormatInt(*a.SenderID, 10)
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(participants)
+			index[key] = i
+			participants = append(participants, TimelineParticipant{
+				SenderType: a.SenderType,
+				AvatarID:   a.SenderID,
+				Name:       h.timelineName(a.SenderType, a.SenderID, names),
+				Windows:    []TimelineWindow{},
+			})
+		}
+		p := &participants[i]
+		p.MessageCount += a.Messages
+		p.Windows = append(p.Windows, TimelineWindow{Start: a.WindowStart, Messages: a.Messages, Characters: a.Characters})
+	}
+
+	for _, a := range members {
+		key := string(models.SenderTypeAvatar) + ":" + strconv.FormatInt(a.ID, 10)
+		if _, ok := index[key]; ok {
+			continue
+		}
+		avatarID := a.ID
+		participants = append(participants, TimelineParticipant{
+			SenderType: models.SenderTypeAvatar,
+			AvatarID:   &avatarID,
+			Name:       a.Name,
+			Windows:    []TimelineWindow{},
+		})
+	}
+	return participants
+}
+
+// timelineName returns the display name of a sender
+func (h *ConversationHandler) timelineName(senderType models.SenderType, senderID *int64, names map[int64]string) string {
+	switch {
+	case senderType == models.SenderTypeUser:
+		return "User"
+	case senderType == models.SenderTypeSystem:
+		return "System"
+	case senderID == nil:
+		return ""
+	}
+	if name, ok := names[*senderID]; ok {
+		return name
+	}
+	if avatar, err := h.db.GetAvatar(*senderID); err == nil {
+		names[*senderID] = avatar.Name
+		return avatar.Name
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func getTimeline(handler *ConversationHandler, conversationID int64, query string) *httptest.ResponseRecorder {
+	id := strconv.FormatInt(conversationID, 10)
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/"+id+"/timeline"+query, nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.GetTimeline(w, req)
+	return w
+}
+
+func TestGetTimeline(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Chat", "thread_1")
+	alice, _ := handler.db.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := handler.db.CreateAvatar("Bob", "prompt", "asst_2")
+	handler.db.AddAvatarToConversation(conv.ID, alice.ID)
+	handler.db.AddAvatarToConversation(conv.ID, bob.ID)
+
+	base := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	handler.db.ImportMessages(conv.ID, []models.Message{
+		{SenderType: models.SenderTypeUser, Content: "hello", CreatedAt: base},
+		{SenderType: models.SenderTypeAvatar, SenderID: &alice.ID, Content: "hi", CreatedAt: base.Add(30 * time.Second)},
+		{SenderType: models.SenderTypeUser, Content: "bye", CreatedAt: base.Add(10 * time.Minute)},
+	})
+
+	w := getTimeline(handler, conv.ID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp TimelineResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	// 10 minutes fit in well under 100 one-minute windows
+	if resp.WindowSeconds != 60 || resp.MessageCount != 3 || resp.Start == nil || !resp.Start.Equal(base) {
+		t.Errorf("unexpected timeline: %+v", resp)
+	}
+	if len(resp.Participants) != 3 {
+		t.Fatalf("expected the user, Alice and Bob, got %+v", resp.Participants)
+	}
+	user, first, silent := resp.Participants[0], resp.Participants[1], resp.Participants[2]
+	if user.Name != "User" || user.MessageCount != 2 || len(user.Windows) != 2 {
+		t.Errorf("unexpected user participant: %+v", user)
+	}
+	if first.Name != "Alice" || first.AvatarID == nil || *first.AvatarID != alice.ID || first.Windows[0].Characters != 2 {
+		t.Errorf("unexpected Alice participant: %+v", first)
+	}
+	if silent.Name != "Bob" || silent.MessageCount != 0 || len(silent.Windows) != 0 {
+		t.Errorf("expected Bob to be listed without windows, got %+v", silent)
+	}
+
+	w = getTimeline(handler, conv.ID, "?window=1h")
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.WindowSeconds != 3600 || len(resp.Participants[0].Windows) != 1 || resp.Participants[0].Windows[0].Messages != 2 {
+		t.Errorf("expected one hourly window for the user, got %+v", resp)
+	}
+}
+
+func TestGetTimeline_InvalidWindow(t *testing.T) {
+	handler, _ := setupTestConversationHandler(t)
+
+	conv, _ := handler.db.CreateConversation("Chat", "thread_1")
+	base := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	handler.db.ImportMessages(conv.ID, []models.Message{
+		{SenderType: models.SenderTypeUser, Content: "hello", CreatedAt: base},
+		{SenderType: models.SenderTypeUser, Content: "a day later", CreatedAt: base.Add(24 * time.Hour)},
+	})
+
+	for _, query := range []string{"?window=abc", "?window=500ms", "?window=1s"} {
+		if w := getTimeline(handler, conv.ID, query); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+	if w := getTimeline(handler, 999, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing conversation, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"log"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// invalidLanguage is the error message for a language setting that is not a language name or code
+func invalidLanguage(field string) string {
+	return fmt.Sprintf("%s must be a language name or code of at most %d characters, or empty", field, logic.MaxLanguageLength)
+}
+
+// translateUserMessage translates a user message into the conversation language before it is forwarded
+// The stored message keeps what the user wrote and records the translation avatars read.
+// Returns the message to forward, which is the original when no translation is needed or it failed
+func (h *ConversationHandler) translateUserMessage(database *db.DB, conv *models.Conversation, msg *models.Message) *models.Message {
+	if conv.Language == "" || h.assistant == nil {
+		return msg
+	}
+
+	translated, err := h.assistantFor(conv).Translate(msg.Content, conv.Language)
+	if err != nil {
+		log.Printf("[API] Warning: failed to translate user message message_id=%d language=%s err=%v", msg.ID, conv.Language, err)
+		return msg
+	}
+	if translated == msg.Content {
+		return msg
+	}
+
+	translation := models.MessageTranslation{Language: conv.Language, Content: translated}
+	if err := database.RecordMessageTranslation(msg.ID, translation); err != nil {
+		log.Printf("[API] Warning: failed to record message translation message_id=%d err=%v", msg.ID, err)
+	} else {
+		msg.Translation = &translation
+	}
+	log.Printf("[API] User message translated message_id=%d language=%s", msg.ID, conv.Language)
+
+	forwarded := *msg
+	forwarded.Content = translated
+	return &forwarded
+}
@@ -0,0 +1,129 @@
+package assistant
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures that opens the circuit
+	DefaultFailureThreshold = 5
+	// DefaultCoolDown is how long the circuit stays open before a trial request is allowed
+	DefaultCoolDown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the API while the circuit is open
+var ErrCircuitOpen = errors.New("OpenAI API unavailable: circuit breaker is open")
+
+// CircuitBreaker stops calls to the OpenAI API after repeated failures
+// After the cool-down a single trial call is allowed; its outcome closes or re-opens the circuit
+type CircuitBreaker struct {
+	mu            sync.Mutex
+	threshold     int
+	coolDown      time.Duration
+	failures      int
+	open          bool
+	openUntil     time.Time
+	onStateChange []func(open bool, retryAfter time.Duration)
+	// probing is set while the single trial call after the cool-down is in flight
+	probing bool
+}
+
+// NewCircuitBreaker creates a circuit breaker that opens after threshold consecutive failures
+func NewCircuitBreaker(threshold int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+	}
+}
+
+// OnStateChange registers a callback invoked when the circuit opens or closes
+func (b *CircuitBreaker) OnStateChange(fn func(open bool, retryAfter time.Duration)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onStateChange = append(b.onStateChange, fn)
+}
+
+// CoolDown returns how long the circuit stays open before a trial call is allowed
+func (b *CircuitBreaker) CoolDown() time.Duration {
+	return b.coolDown
+}
+
+// Allow reports whether a call may be made
+// While open, calls are rejected until the cool-down has elapsed. The first caller after it
+// makes the trial call; the others are rejected until its outcome is recorded
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// IsOpen reports whether calls are currently rejected
+// Unlike Allow it does not start a trial call, so it is false once a trial call may be made
+func (b *CircuitBreaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open && (b.probing || time.Now().Before(b.openUntil))
+}
+
+// RecordSuccess resets the failure count and closes the circuit
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	wasOpen := b.open
+	b.failures = 0
+	b.open = false
+	b.probing = false
+	callbacks := b.onStateChange
+	b.mu.Unlock()
+
+	if wasOpen {
+		log.Printf("[CircuitBreaker] Circuit closed: OpenAI API recovered")
+		for _, fn := range callbacks {
+			fn(false, 0)
+		}
+	}
+}
+
+// RecordFailure counts a failure and opens the circuit once the threshold is reached
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold {
+		b.mu.Unlock()
+		return
+	}
+
+	wasOpen := b.open
+	b.open = true
+	b.openUntil = time.Now().Add(b.coolDown)
+	callbacks := b.onStateChange
+	b.mu.Unlock()
+
+	// A failed trial call re-opens the circuit without notifying again
+	if !wasOpen {
+		log.Printf("[CircuitBreaker] Circuit opened after %d consecutive failures cool_down=%v", b.threshold, b.coolDown)
+		for _, fn := range callbacks {
+			fn(true, b.coolDown)
+		}
+	}
+}
+
+// isBreakerFailure reports whether a response indicates the API is unavailable
+// Client errors other than rate limiting do not count as failures
+func isBreakerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,188 @@
+package assistant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Minute)
+
+	var opened atomic.Int32
+	b.OnStateChange(func(open bool, retryAfter time.Duration) {
+		if open {
+			opened.Add(1)
+			if retryAfter != time.Minute {
+				t.Errorf("expected retryAfter 1m, got %v", retryAfter)
+			}
+		}
+	})
+
+	b.RecordFailure()
+	b.RecordFailure()
+	if b.IsOpen() {
+		t.Fatal("expected circuit to stay closed below threshold")
+	}
+
+	b.RecordFailure()
+	if !b.IsOpen() {
+		t.Fatal("expected circuit to open at threshold")
+	}
+	if b.Allow() {
+		t.Error("expected calls to be rejected while open")
+	}
+	if opened.Load() != 1 {
+		t.Errorf("expected 1 open notification, got %d", opened.Load())
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Minute)
+
+	b.RecordFailure()
+	b.RecordSuccess()
+	b.RecordFailure()
+
+	if b.IsOpen() {
+		t.Error("expected success to reset the consecutive failure count")
+	}
+}
+
+func TestCircuitBreaker_HalfOpenAfterCoolDown(t *testing.T) {
+	b := NewCircuitBreaker(1, 20*time.Millisecond)
+
+	var closed atomic.Int32
+	b.OnStateChange(func(open bool, retryAfter time.Duration) {
+		if !open {
+			closed.Add(1)
+		}
+	})
+
+	b.RecordFailure()
+	if b.Allow() {
+		t.Fatal("expected circuit to be open")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("expected a trial call to be allowed after the cool-down")
+	}
+
+	b.RecordSuccess()
+	if b.IsOpen() {
+		t.Error("expected circuit to close after a successful trial call")
+	}
+	if closed.Load() != 1 {
+		t.Errorf("expected 1 close notification, got %d", closed.Load())
+	}
+}
+
+func TestCircuitBreaker_SingleTrialCallAfterCoolDown(t *testing.T) {
+	b := NewCircuitBreaker(1, 20*time.Millisecond)
+	b.RecordFailure()
+	time.Sleep(30 * time.Millisecond)
+
+	const callers = 20
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != 1 {
+		t.Errorf("expected exactly 1 trial call, got %d", allowed.Load())
+	}
+	if !b.IsOpen() {
+		t.Error("expected the circuit to stay open while the trial call is in flight")
+	}
+}
+
+func TestCircuitBreaker_FailedTrialCallReopens(t *testing.T) {
+	b := NewCircuitBreaker(1, 20*time.Millisecond)
+
+	var notifications atomic.Int32
+	b.OnStateChange(func(open bool, retryAfter time.Duration) {
+		notifications.Add(1)
+	})
+
+	b.RecordFailure()
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("expected a trial call to be allowed after the cool-down")
+	}
+
+	b.RecordFailure()
+	if b.Allow() {
+		t.Error("expected a failed trial call to re-open the circuit for another cool-down")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Error("expected another trial call after the second cool-down")
+	}
+	if notifications.Load() != 1 {
+		t.Errorf("expected only the first opening to notify, got %d", notifications.Load())
+	}
+}
+
+func TestClient_CircuitBreakerRejectsCalls(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key",
+		WithCircuitBreaker(2, time.Minute),
+		WithHTTPClient(&http.Client{
+			Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+		}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.CreateThread(); err == nil {
+			t.Fatal("expected error from failing server")
+		}
+	}
+	if !client.CircuitOpen() {
+		t.Fatal("expected circuit to be open after consecutive failures")
+	}
+
+	_, err := client.CreateThread()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls to reach the server, got %d", calls.Load())
+	}
+}
+
+func TestClient_ClientErrorsDoNotOpenCircuit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key",
+		WithCircuitBreaker(1, time.Minute),
+		WithHTTPClient(&http.Client{
+			Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+		}))
+
+	client.CreateThread()
+	if client.CircuitOpen() {
+		t.Error("expected 4xx responses not to open the circuit")
+	}
+}
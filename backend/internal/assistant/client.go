@@ -2,25 +2,43 @@ package assistant
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"regexp"
 	"time"
+
+	"multi-avatar-chat/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	baseURL        = "https://api.openai.com/v1"
 	defaultModel   = "gpt-4o"
 	defaultTimeout = 30 * time.Second
+	// DefaultJudgmentModel is the chat completion model used for lightweight decisions
+	DefaultJudgmentModel = "gpt-4o-mini"
 )
 
 // Client provides access to OpenAI Assistants API
 type Client struct {
-	apiKey     string
-	httpClient *http.Client
-	model      string
+	apiKey        string
+	httpClient    *http.Client
+	model         string
+	judgmentModel string
+	forwardQueue  *ForwardQueue
+	breaker       *CircuitBreaker
+	timeouts      Timeouts
+	// organization and project are sent as the OpenAI-Organization and OpenAI-Project headers when set,
+	// so usage is billed to them instead of the API key's defaults
+	organization string
+	project      string
+	// ctx is the trace context of a view returned by WithContext; nil on the root client
+	ctx context.Context
 }
 
 // ClientOption configures the client
@@ -33,6 +51,18 @@ func WithModel(model string) ClientOption {
 	}
 }
 
+// WithJudgmentModel sets the model used by Completion and SimpleCompletion
+func WithJudgmentModel(model string) ClientOption {
+	return func(c *Client) {
+		c.judgmentModel = model
+	}
+}
+
+// JudgmentModel returns the model used by Completion and SimpleCompletion
+func (c *Client) JudgmentModel() string {
+	return c.judgmentModel
+}
+
 // WithHTTPClient sets a custom HTTP client
 func WithHTTPClient(httpClient *http.Client) ClientOption {
 	return func(c *Client) {
@@ -40,6 +70,21 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
+// WithOpenAIProject sets the organization and project API calls are billed to; empty values use the key's defaults
+func WithOpenAIProject(organization, project string) ClientOption {
+	return func(c *Client) {
+		c.organization = organization
+		c.project = project
+	}
+}
+
+// WithCircuitBreaker configures the failure threshold and cool-down of the circuit breaker
+func WithCircuitBreaker(threshold int, coolDown time.Duration) ClientOption {
+	return func(c *Client) {
+		c.breaker = NewCircuitBreaker(threshold, coolDown)
+	}
+}
+
 // NewClient creates a new OpenAI Assistants API client
 func NewClient(apiKey string, opts ...ClientOption) *Client {
 	c := &Client{
@@ -47,39 +92,165 @@ func NewClient(apiKey string, opts ...ClientOption) *Client {
 		httpClient: &http.Client{
 			Timeout: defaultTimeout,
 		},
-		model: defaultModel,
+		model:         defaultModel,
+		judgmentModel: DefaultJudgmentModel,
+		breaker:       NewCircuitBreaker(DefaultFailureThreshold, DefaultCoolDown),
+		timeouts:      Timeouts{}.withDefaults(),
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
+	c.forwardQueue = NewForwardQueue(c)
+
 	return c
 }
 
+// CircuitBreaker returns the circuit breaker guarding API calls
+func (c *Client) CircuitBreaker() *CircuitBreaker {
+	return c.breaker
+}
+
+// CircuitOpen reports whether API calls are currently being rejected
+func (c *Client) CircuitOpen() bool {
+	return c.breaker.IsOpen()
+}
+
+// WithContext returns a view of the client that records a span for each API call
+// as a child of the span in ctx. Cancellation of ctx does not abort the calls
+// The view shares the HTTP client, circuit breaker and forward queue
+func (c *Client) WithContext(ctx context.Context) *Client {
+	view := *c
+	view.ctx = context.WithoutCancel(ctx)
+	return &view
+}
+
+// ForProject returns a view of the client whose API calls are billed to the organization and project
+// Empty values keep the client's own and a nil client stays nil.
+// The view shares the HTTP client, circuit breaker and forward queue
+func (c *Client) ForProject(organization, project string) *Client {
+	if c == nil || (organization == "" && project == "") {
+		return c
+	}
+	view := *c
+	if organization != "" {
+		view.organization = organization
+	}
+	if project != "" {
+		view.project = project
+	}
+	return &view
+}
+
+// Organization returns the organization API calls are billed to, empty for the key's default
+func (c *Client) Organization() string {
+	return c.organization
+}
+
+// Project returns the project API calls are billed to, empty for the key's default
+func (c *Client) Project() string {
+	return c.project
+}
+
+// apiIDPattern matches OpenAI object IDs in request paths (thread_..., run_..., asst_...)
+var apiIDPattern = regexp.MustCompile(`/[a-z]+_[A-Za-z0-9]+`)
+
+// do sends a request through the circuit breaker
+func (c *Client) do(req *http.Request) (resp *http.Response, err error) {
+	if c.ctx != nil {
+		ctx, span := tracing.Start(c.ctx, "openai "+req.Method+" "+apiIDPattern.ReplaceAllString(req.URL.Path, "/{id}"),
+			attribute.String("http.request.method", req.Method),
+		)
+		defer func() {
+			if resp != nil {
+				span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			}
+			tracing.End(span, err)
+		}()
+		req = req.WithContext(ctx)
+	}
+
+	if !c.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	if c.organization != "" {
+		req.Header.Set("OpenAI-Organization", c.organization)
+	}
+	if c.project != "" {
+		req.Header.Set("OpenAI-Project", c.project)
+	}
+
+	resp, err = c.httpClient.Do(req)
+	if isBreakerFailure(resp, err) {
+		c.breaker.RecordFailure()
+	} else {
+		c.breaker.RecordSuccess()
+	}
+	return resp, err
+}
+
+// ForwardQueue returns the queue of messages being forwarded to threads through this client
+func (c *Client) ForwardQueue() *ForwardQueue {
+	return c.forwardQueue
+}
+
+// Tool types that can be enabled on an assistant
+const (
+	ToolCodeInterpreter = "code_interpreter"
+	ToolFileSearch      = "file_search"
+)
+
+// Tool represents a built-in tool enabled on an assistant
+type Tool struct {
+	Type string `json:"type"`
+}
+
 // Assistant represents an OpenAI Assistant
 type Assistant struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Instructions string `json:"instructions"`
-	Model        string `json:"model"`
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Instructions string   `json:"instructions"`
+	Model        string   `json:"model"`
+	Tools        []Tool   `json:"tools,omitempty"`
+	Metadata     Metadata `json:"metadata,omitempty"`
 }
 
 // CreateAssistantRequest represents a request to create an assistant
 type CreateAssistantRequest struct {
-	Name         string `json:"name"`
-	Instructions string `json:"instructions"`
-	Model        string `json:"model"`
+	Name         string   `json:"name"`
+	Instructions string   `json:"instructions"`
+	Model        string   `json:"model"`
+	Tools        []Tool   `json:"tools,omitempty"`
+	Metadata     Metadata `json:"metadata,omitempty"`
 }
 
 // CreateAssistant creates a new assistant
 func (c *Client) CreateAssistant(name, instructions string) (*Assistant, error) {
-	log.Printf("[Assistant] CreateAssistant started name=%q model=%s", name, c.model)
+	return c.CreateAssistantWithTools(name, instructions, nil)
+}
+
+// CreateAssistantWithTools creates a new assistant with the given tools enabled
+func (c *Client) CreateAssistantWithTools(name, instructions string, tools []Tool) (*Assistant, error) {
+	return c.CreateAssistantWithModel(name, instructions, "", tools)
+}
+
+// CreateAssistantWithModel creates a new assistant using the given model and tools
+// The client's model is used when model is empty. The assistant is tagged with AppMetadata;
+// set the avatar with UpdateAssistantMetadata once the avatar is saved
+func (c *Client) CreateAssistantWithModel(name, instructions, model string, tools []Tool) (*Assistant, error) {
+	if model == "" {
+		model = c.model
+	}
+	log.Printf("[Assistant] CreateAssistant started name=%q model=%s tools=%v", name, model, tools)
 
 	reqBody := CreateAssistantRequest{
 		Name:         name,
 		Instructions: instructions,
-		Model:        c.model,
+		Model:        model,
+		Tools:        tools,
+		Metadata:     AppMetadata(),
 	}
 
 	body, err := json.Marshal(reqBody)
@@ -96,7 +267,7 @@ func (c *Client) CreateAssistant(name, instructions string) (*Assistant, error)
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] CreateAssistant failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -127,7 +298,7 @@ func (c *Client) GetAssistant(id string) (*Assistant, error) {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -145,6 +316,62 @@ func (c *Client) GetAssistant(id string) (*Assistant, error) {
 	return &assistant, nil
 }
 
+// listAssistantsResponse represents a page of assistants
+type listAssistantsResponse struct {
+	Data    []Assistant `json:"data"`
+	HasMore bool        `json:"has_more"`
+	LastID  string      `json:"last_id"`
+}
+
+// ListAssistants retrieves every assistant in the account, newest first
+func (c *Client) ListAssistants() ([]Assistant, error) {
+	log.Printf("[Assistant] ListAssistants started")
+
+	var assistants []Assistant
+	after := ""
+	for {
+		url := baseURL + "/assistants?order=desc&limit=100"
+		if after != "" {
+			url += "&after=" + after
+		}
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		c.setHeaders(req)
+
+		resp, err := c.do(req)
+		if err != nil {
+			log.Printf("[Assistant] ListAssistants failed: send request err=%v", err)
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("[Assistant] ListAssistants failed: API error status=%d", resp.StatusCode)
+			err := c.handleError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page listAssistantsResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		assistants = append(assistants, page.Data...)
+		if !page.HasMore || page.LastID == "" {
+			break
+		}
+		after = page.LastID
+	}
+
+	log.Printf("[Assistant] ListAssistants completed count=%d", len(assistants))
+	return assistants, nil
+}
+
 // UpdateAssistantRequest represents a request to update an assistant
 type UpdateAssistantRequest struct {
 	Name         string `json:"name,omitempty"`
@@ -170,7 +397,49 @@ func (c *Client) UpdateAssistant(id, name, instructions string) (*Assistant, err
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleError(resp)
+	}
+
+	var assistant Assistant
+	if err := json.NewDecoder(resp.Body).Decode(&assistant); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &assistant, nil
+}
+
+// updateAssistantToolsRequest replaces the tools of an assistant
+// Tools is never omitted so an empty list disables every tool
+type updateAssistantToolsRequest struct {
+	Tools []Tool `json:"tools"`
+}
+
+// UpdateAssistantTools replaces the tools enabled on an existing assistant
+func (c *Client) UpdateAssistantTools(id string, tools []Tool) (*Assistant, error) {
+	if tools == nil {
+		tools = []Tool{}
+	}
+
+	body, err := json.Marshal(updateAssistantToolsRequest{Tools: tools})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/assistants/"+id, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -185,6 +454,7 @@ func (c *Client) UpdateAssistant(id, name, instructions string) (*Assistant, err
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	log.Printf("[Assistant] UpdateAssistantTools completed assistant_id=%s tools=%v", id, tools)
 	return &assistant, nil
 }
 
@@ -197,7 +467,7 @@ func (c *Client) DeleteAssistant(id string) error {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -217,45 +487,23 @@ func (c *Client) setHeaders(req *http.Request) {
 	req.Header.Set("OpenAI-Beta", "assistants=v2")
 }
 
-// APIError represents an error from the OpenAI API
-type APIError struct {
-	StatusCode int
-	Message    string
-}
-
-func (e *APIError) Error() string {
-	return fmt.Sprintf("OpenAI API error (status %d): %s", e.StatusCode, e.Message)
-}
-
-// handleError processes error responses from the API
-func (c *Client) handleError(resp *http.Response) error {
-	body, _ := io.ReadAll(resp.Body)
-	bodyStr := string(body)
-
-	// Truncate for logging if too long
-	logBody := bodyStr
-	if len(logBody) > 500 {
-		logBody = logBody[:500] + "..."
-	}
-	log.Printf("[Assistant] API Error status=%d body=%s", resp.StatusCode, logBody)
-
-	return &APIError{
-		StatusCode: resp.StatusCode,
-		Message:    bodyStr,
-	}
-}
-
 // SimpleCompletion sends a simple chat completion request for quick judgments
 // Uses gpt-4o-mini for efficiency
 func (c *Client) SimpleCompletion(prompt string) (string, error) {
-	log.Printf("[Assistant] SimpleCompletion started prompt_length=%d", len(prompt))
+	return c.Completion(prompt, 10)
+}
+
+// Completion sends a single-prompt chat completion request with the given token limit
+// Uses the judgment model (gpt-4o-mini by default) for efficiency
+func (c *Client) Completion(prompt string, maxTokens int) (string, error) {
+	log.Printf("[Assistant] Completion started model=%s prompt_length=%d max_tokens=%d", c.judgmentModel, len(prompt), maxTokens)
 
 	reqBody := map[string]any{
-		"model": "gpt-4o-mini",
+		"model": c.judgmentModel,
 		"messages": []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens": 10,
+		"max_tokens": maxTokens,
 	}
 
 	body, err := json.Marshal(reqBody)
@@ -271,7 +519,7 @@ func (c *Client) SimpleCompletion(prompt string) (string, error) {
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
 	}
@@ -279,7 +527,7 @@ func (c *Client) SimpleCompletion(prompt string) (string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		log.Printf("[Assistant] SimpleCompletion API error status=%d body=%s", resp.StatusCode, string(respBody))
+		log.Printf("[Assistant] Completion API error status=%d body=%s", resp.StatusCode, string(respBody))
 		return "", fmt.Errorf("OpenAI API error: %s", string(respBody))
 	}
 
@@ -299,8 +547,63 @@ func (c *Client) SimpleCompletion(prompt string) (string, error) {
 		return "", fmt.Errorf("no response from OpenAI")
 	}
 
+	// Callers log the parts of the answer they use; it may quote user messages
 	content := result.Choices[0].Message.Content
-	log.Printf("[Assistant] SimpleCompletion completed response=%q", content)
+	log.Printf("[Assistant] Completion completed response_length=%d", len(content))
+
+	return content, nil
+}
 
+// ChatCompletion sends a chat completion request with a system message to the given model
+// Used to generate a response outside the Assistants API, e.g. with a comparison model
+func (c *Client) ChatCompletion(model, system, prompt string) (string, error) {
+	log.Printf("[Assistant] ChatCompletion started model=%s system_length=%d prompt_length=%d", model, len(system), len(prompt))
+
+	reqBody := map[string]any{
+		"model": model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": prompt},
+		},
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := c.newRequest(http.MethodPost, baseURL+"/chat/completions", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", c.handleError(resp)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no response from OpenAI")
+	}
+
+	content := result.Choices[0].Message.Content
+	log.Printf("[Assistant] ChatCompletion completed model=%s response_length=%d", model, len(content))
 	return content, nil
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -195,3 +196,85 @@ func (tc *testableClient) DeleteAssistant(id string) error {
 
 	return nil
 }
+
+func TestAssistantTools(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Write([]byte(`{"id": "asst_123", "tools": [{"type": "file_search"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	created, err := client.CreateAssistantWithTools("Researcher", "You research", []Tool{{Type: ToolFileSearch}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(created.Tools) != 1 || created.Tools[0].Type != ToolFileSearch {
+		t.Errorf("expected file_search tool, got %+v", created.Tools)
+	}
+
+	// Clearing tools must send an empty list rather than omitting the field
+	if _, err := client.UpdateAssistantTools("asst_123", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tools, _ := bodies[0]["tools"].([]any); len(tools) != 1 {
+		t.Errorf("expected one tool in create request, got %v", bodies[0]["tools"])
+	}
+	if tools, ok := bodies[1]["tools"].([]any); !ok || len(tools) != 0 {
+		t.Errorf("expected empty tools list in update request, got %v", bodies[1]["tools"])
+	}
+}
+
+func TestForProject_SetsHeaders(t *testing.T) {
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		w.Write([]byte(`{"id": "thread_123"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key",
+		WithOpenAIProject("org-default", ""),
+		WithHTTPClient(&http.Client{
+			Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+		}))
+
+	if _, err := client.CreateThread(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.ForProject("", "proj_team").CreateThread(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.ForProject("org-team", "proj_team").CreateThread(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct{ organization, project string }{
+		{"org-default", ""},
+		{"org-default", "proj_team"},
+		{"org-team", "proj_team"},
+	}
+	for i, want := range expected {
+		if got := headers[i].Get("OpenAI-Organization"); got != want.organization {
+			t.Errorf("request %d: expected OpenAI-Organization %q, got %q", i, want.organization, got)
+		}
+		if got := headers[i].Get("OpenAI-Project"); got != want.project {
+			t.Errorf("request %d: expected OpenAI-Project %q, got %q", i, want.project, got)
+		}
+	}
+
+	// The view does not change the client it came from
+	if client.Project() != "" {
+		t.Errorf("expected the root client to keep its project, got %q", client.Project())
+	}
+	if client.ForProject("", "") != client {
+		t.Error("expected empty values to return the client itself")
+	}
+}
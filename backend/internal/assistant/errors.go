@@ -0,0 +1,133 @@
+package assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Classes of OpenAI API errors, matched with errors.Is against the errors returned by the client
+var (
+	// ErrRateLimited means the request was throttled (HTTP 429); retrying later may succeed
+	ErrRateLimited = errors.New("OpenAI rate limit exceeded")
+	// ErrInvalidRequest means OpenAI rejected the request itself; retrying it unchanged fails again
+	ErrInvalidRequest = errors.New("OpenAI rejected the request")
+	// ErrContextLength means the request was too long for the model; it also matches ErrInvalidRequest
+	ErrContextLength = errors.New("OpenAI context length exceeded")
+	// ErrAuth means the API key was rejected or lacks permission
+	ErrAuth = errors.New("OpenAI authentication failed")
+	// ErrAssistantNotFound means the assistant of a run was deleted on OpenAI; only relinking the avatar helps
+	ErrAssistantNotFound = errors.New("OpenAI assistant not found")
+)
+
+// Error codes and types in OpenAI error bodies used to classify errors
+const (
+	errorCodeContextLength  = "context_length_exceeded"
+	errorCodeStringTooLong  = "string_above_max_length"
+	errorCodeInvalidAPIKey  = "invalid_api_key"
+	errorTypeAuthentication = "authentication_error"
+)
+
+// APIError represents an error from the OpenAI API
+// Type, Code and Param are parsed from the error body when OpenAI provides them
+type APIError struct {
+	StatusCode int
+	Message    string
+	Type       string
+	Code       string
+	Param      string
+	// kind is the error class the error matches with errors.Is, nil for other errors
+	kind error
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("OpenAI API error (status %d, code %s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("OpenAI API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether the error belongs to the class target
+func (e *APIError) Is(target error) bool {
+	if e.kind == nil {
+		return false
+	}
+	return target == e.kind || (target == ErrInvalidRequest && e.kind == ErrContextLength)
+}
+
+// IsNotFound reports whether err is an API error for a missing object
+// Deleting an object that is already gone can be treated as success
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsRetryable reports whether a failed call may succeed when repeated unchanged
+// Invalid requests, authentication failures and deleted assistants are permanent; everything else,
+// including rate limits, server errors and network failures, is worth retrying
+func IsRetryable(err error) bool {
+	return !errors.Is(err, ErrInvalidRequest) && !errors.Is(err, ErrAuth) && !errors.Is(err, ErrAssistantNotFound)
+}
+
+// errorBody is the body OpenAI returns with an error status
+type errorBody struct {
+	Error struct {
+		Message string  `json:"message"`
+		Type    string  `json:"type"`
+		Code    *string `json:"code"`
+		Param   *string `json:"param"`
+	} `json:"error"`
+}
+
+// handleError processes error responses from the API
+func (c *Client) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	bodyStr := string(body)
+
+	// Truncate for logging if too long
+	logBody := bodyStr
+	if len(logBody) > 500 {
+		logBody = logBody[:500] + "..."
+	}
+	log.Printf("[Assistant] API Error status=%d body=%s", resp.StatusCode, logBody)
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    bodyStr,
+	}
+	var parsed errorBody
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error.Message != "" {
+		apiErr.Message = parsed.Error.Message
+		apiErr.Type = parsed.Error.Type
+		if parsed.Error.Code != nil {
+			apiErr.Code = *parsed.Error.Code
+		}
+		if parsed.Error.Param != nil {
+			apiErr.Param = *parsed.Error.Param
+		}
+	}
+	apiErr.kind = classifyAPIError(apiErr)
+	return apiErr
+}
+
+// classifyAPIError returns the error class of an API error, or nil if it has none
+func classifyAPIError(e *APIError) error {
+	switch {
+	case e.Code == errorCodeContextLength || e.Code == errorCodeStringTooLong:
+		return ErrContextLength
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+		e.Type == errorTypeAuthentication || e.Code == errorCodeInvalidAPIKey:
+		return ErrAuth
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusNotFound && strings.Contains(strings.ToLower(e.Message), "no assistant found"):
+		return ErrAssistantNotFound
+	case e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrInvalidRequest
+	}
+	return nil
+}
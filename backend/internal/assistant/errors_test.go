@@ -0,0 +1,105 @@
+package assistant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleError_Classification(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		class     error
+		retryable bool
+	}{
+		{"rate limit", http.StatusTooManyRequests,
+			`{"error": {"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"}}`, ErrRateLimited, true},
+		{"context length", http.StatusBadRequest,
+			`{"error": {"message": "This model's maximum context length is 128000 tokens", "type": "invalid_request_error", "code": "context_length_exceeded", "param": "messages"}}`,
+			ErrContextLength, false},
+		{"invalid request", http.StatusBadRequest,
+			`{"error": {"message": "Invalid value for 'model'", "type": "invalid_request_error", "code": null, "param": "model"}}`,
+			ErrInvalidRequest, false},
+		{"auth", http.StatusUnauthorized,
+			`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error", "code": "invalid_api_key"}}`, ErrAuth, false},
+		{"assistant not found", http.StatusNotFound,
+			`{"error": {"message": "No assistant found with id 'asst_deleted'.", "type": "invalid_request_error", "code": null, "param": null}}`,
+			ErrAssistantNotFound, false},
+		{"thread not found", http.StatusNotFound,
+			`{"error": {"message": "No thread found with id 'thread_deleted'.", "type": "invalid_request_error", "code": null, "param": null}}`,
+			nil, true},
+		{"server error", http.StatusInternalServerError, `upstream connect error`, nil, true},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+			Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+		}))
+
+		_, err := client.CreateThread()
+		server.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("%s: expected an APIError with status %d, got %v", tt.name, tt.status, err)
+			continue
+		}
+		for _, class := range []error{ErrRateLimited, ErrInvalidRequest, ErrContextLength, ErrAuth, ErrAssistantNotFound} {
+			want := class == tt.class || (class == ErrInvalidRequest && tt.class == ErrContextLength)
+			if errors.Is(err, class) != want {
+				t.Errorf("%s: errors.Is(err, %v) = %v, want %v", tt.name, class, !want, want)
+			}
+		}
+		if IsRetryable(err) != tt.retryable {
+			t.Errorf("%s: expected IsRetryable %v", tt.name, tt.retryable)
+		}
+	}
+}
+
+func TestHandleError_ParsesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "Invalid value for 'model'", "type": "invalid_request_error", "code": "model_not_found", "param": "model"}}`))
+	}))
+	defer server.Close()
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	_, err := client.CreateThread()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.Message != "Invalid value for 'model'" || apiErr.Type != "invalid_request_error" ||
+		apiErr.Code != "model_not_found" || apiErr.Param != "model" {
+		t.Errorf("unexpected parsed error %+v", apiErr)
+	}
+	if err.Error() != "OpenAI API error (status 400, code model_not_found): Invalid value for 'model'" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestRunEndedError_Is(t *testing.T) {
+	rateLimited := &RunEndedError{Run: &Run{Status: "failed", LastError: &RunError{Code: RunErrorRateLimitExceeded}}}
+	if !errors.Is(rateLimited, ErrRateLimited) || !IsRetryable(rateLimited) {
+		t.Error("expected a rate limited run to match ErrRateLimited and be retryable")
+	}
+
+	invalid := &RunEndedError{Run: &Run{Status: "failed", LastError: &RunError{Code: RunErrorInvalidPrompt}}}
+	if !errors.Is(invalid, ErrInvalidRequest) || IsRetryable(invalid) {
+		t.Error("expected an invalid prompt to match ErrInvalidRequest and not be retryable")
+	}
+
+	if expired := (&RunEndedError{Run: &Run{Status: "expired"}}); errors.Is(expired, ErrRateLimited) || !IsRetryable(expired) {
+		t.Error("expected an expired run to be retryable without an error class")
+	}
+}
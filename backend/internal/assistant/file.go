@@ -0,0 +1,93 @@
+package assistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// maxFileContentSize limits the size of a file downloaded from the Files API
+const maxFileContentSize = 20 << 20
+
+// File represents an OpenAI file
+type File struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Purpose  string `json:"purpose"`
+}
+
+// GetFile retrieves the metadata of a file, such as its name
+func (c *Client) GetFile(fileID string) (*File, error) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/files/"+fileID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Assistant] GetFile failed: API error status=%d file_id=%s", resp.StatusCode, fileID)
+		return nil, c.handleError(resp)
+	}
+
+	var file File
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &file, nil
+}
+
+// ResolveCitationFilenames fills in the file names of citations, looking up each file once
+// A failed lookup is logged and leaves the name empty
+func (c *Client) ResolveCitationFilenames(citations []Citation) {
+	filenames := make(map[string]string)
+	for i := range citations {
+		fileID := citations[i].FileID
+		filename, ok := filenames[fileID]
+		if !ok {
+			if file, err := c.GetFile(fileID); err != nil {
+				log.Printf("[Assistant] ResolveCitationFilenames: failed to get file file_id=%s err=%v", fileID, err)
+			} else {
+				filename = file.Filename
+			}
+			filenames[fileID] = filename
+		}
+		citations[i].Filename = filename
+	}
+}
+
+// GetFileContent downloads the content of a file, such as an image generated by the code interpreter
+// Returns the content and its media type
+func (c *Client) GetFileContent(fileID string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/files/"+fileID+"/content", nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Assistant] GetFileContent failed: API error status=%d file_id=%s", resp.StatusCode, fileID)
+		return nil, "", c.handleError(resp)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxFileContentSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return content, resp.Header.Get("Content-Type"), nil
+}
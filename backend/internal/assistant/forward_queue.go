@@ -0,0 +1,241 @@
+package assistant
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Forward item statuses
+const (
+	// ForwardStatusWaiting means the item is blocked until active runs on its thread complete
+	ForwardStatusWaiting = "waiting"
+	// ForwardStatusSending means the message is being added to the thread
+	ForwardStatusSending = "sending"
+	// ForwardStatusFailed means the message could not be added and needs a retry
+	ForwardStatusFailed = "failed"
+)
+
+var (
+	// ErrForwardNotFound is returned when a queue item does not exist
+	ErrForwardNotFound = errors.New("forward item not found")
+	// ErrForwardInProgress is returned when retrying an item that has not failed
+	ErrForwardInProgress = errors.New("forward item is still in progress")
+)
+
+// DefaultForwardDedupWindow is how long a message added to a thread is not added to it again
+const DefaultForwardDedupWindow = 10 * time.Minute
+
+// ForwardLedger remembers the messages added to each thread so a message is not added twice
+// Distinct messages with the same text are forwarded separately
+type ForwardLedger interface {
+	// ClaimThreadForward records that a message is being added to a thread, reporting false
+	// when the same message was already added at or after since
+	ClaimThreadForward(threadID string, messageID int64, since time.Time) (bool, error)
+	// ReleaseThreadForward forgets a claim whose message could not be added
+	ReleaseThreadForward(threadID string, messageID int64) error
+}
+
+// ForwardItem is a message waiting to be added to an avatar's thread
+type ForwardItem struct {
+	ID             int64  `json:"id"`
+	ThreadID       string `json:"thread_id"`
+	ConversationID int64  `json:"conversation_id"`
+	MessageID      int64  `json:"message_id"`
+	AvatarID       int64  `json:"avatar_id"`
+	AvatarName     string `json:"avatar_name"`
+	Content        string `json:"content"`
+	// Organization and Project route the forward like the other calls of its conversation
+	Organization string    `json:"organization,omitempty"`
+	Project      string    `json:"project,omitempty"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	LastError    string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ForwardQueue tracks messages being forwarded to avatar threads
+// Items are removed once delivered; failed items stay until retried or discarded
+type ForwardQueue struct {
+	client *Client
+	mu     sync.Mutex
+	items  map[int64]*ForwardItem
+	nextID int64
+	// ledger skips messages already added to a thread within dedupWindow; nil adds every message
+	ledger      ForwardLedger
+	dedupWindow time.Duration
+}
+
+// NewForwardQueue creates a forward queue that delivers through the given client
+func NewForwardQueue(client *Client) *ForwardQueue {
+	return &ForwardQueue{
+		client: client,
+		items:  make(map[int64]*ForwardItem),
+	}
+}
+
+// SetLedger deduplicates messages added to threads through the queue
+// A message added to a thread again within window is skipped; a nil ledger turns deduplication off
+func (q *ForwardQueue) SetLedger(ledger ForwardLedger, window time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ledger = ledger
+	q.dedupWindow = window
+}
+
+// Forward waits for active runs on the item's thread and adds the message to it
+// The item stays visible in the queue until it is delivered
+func (q *ForwardQueue) Forward(item ForwardItem) error {
+	q.mu.Lock()
+	q.nextID++
+	item.ID = q.nextID
+	item.Status = ForwardStatusWaiting
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+	q.items[item.ID] = &item
+	q.mu.Unlock()
+
+	return q.deliver(item.ID)
+}
+
+// deliver performs one delivery attempt for a queued item
+func (q *ForwardQueue) deliver(id int64) error {
+	q.mu.Lock()
+	item, ok := q.items[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrForwardNotFound
+	}
+	threadID, messageID, content := item.ThreadID, item.MessageID, item.Content
+	client := q.client.ForProject(item.Organization, item.Project)
+	q.mu.Unlock()
+
+	// Wait for any active runs to complete before adding message
+	if err := client.WaitForActiveRunsToComplete(threadID, client.ActiveRunTimeout()); err != nil {
+		log.Printf("[ForwardQueue] Warning: timeout waiting for active runs item_id=%d thread_id=%s err=%v", id, threadID, err)
+	}
+
+	q.setStatus(id, ForwardStatusSending, nil)
+
+	if _, err := q.AddOnce(client, threadID, messageID, content); err != nil {
+		q.setStatus(id, ForwardStatusFailed, err)
+		log.Printf("[ForwardQueue] Forward failed item_id=%d thread_id=%s err=%v", id, threadID, err)
+		return err
+	}
+
+	q.mu.Lock()
+	delete(q.items, id)
+	q.mu.Unlock()
+
+	return nil
+}
+
+// AddOnce adds a message to a thread through client unless the same message was added to it within the dedup window
+// client is the queue's client or a view of it, such as one returned by ForProject.
+// messageID identifies the conversation message, so retries and overlapping code paths forwarding it
+// do not skew the context the avatar sees. Returns false when the message was skipped as a duplicate.
+// Without a ledger, when the ledger fails or when messageID is 0, the message is always added
+func (q *ForwardQueue) AddOnce(client *Client, threadID string, messageID int64, content string) (bool, error) {
+	q.mu.Lock()
+	ledger, window := q.ledger, q.dedupWindow
+	q.mu.Unlock()
+	if messageID == 0 {
+		ledger = nil
+	}
+
+	if ledger != nil {
+		claimed, err := ledger.ClaimThreadForward(threadID, messageID, time.Now().Add(-window))
+		if err != nil {
+			log.Printf("[ForwardQueue] Warning: failed to check forwarded message thread_id=%s message_id=%d err=%v", threadID, messageID, err)
+			ledger = nil
+		} else if !claimed {
+			log.Printf("[ForwardQueue] Skipping duplicate message thread_id=%s message_id=%d", threadID, messageID)
+			return false, nil
+		}
+	}
+
+	if _, err := client.CreateMessage(threadID, content); err != nil {
+		if ledger != nil {
+			if err := ledger.ReleaseThreadForward(threadID, messageID); err != nil {
+				log.Printf("[ForwardQueue] Warning: failed to release forwarded message thread_id=%s message_id=%d err=%v", threadID, messageID, err)
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// setStatus updates the status of an item, recording the error and attempt count
+func (q *ForwardQueue) setStatus(id int64, status string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return
+	}
+	item.Status = status
+	item.UpdatedAt = time.Now()
+	if status == ForwardStatusSending {
+		item.Attempts++
+	}
+	if err != nil {
+		item.LastError = err.Error()
+	}
+}
+
+// List returns a snapshot of all queued items ordered by ID
+func (q *ForwardQueue) List() []ForwardItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]ForwardItem, 0, len(q.items))
+	for _, item := range q.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return items
+}
+
+// Retry starts a new delivery attempt for a failed item in the background
+func (q *ForwardQueue) Retry(id int64) error {
+	q.mu.Lock()
+	item, ok := q.items[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrForwardNotFound
+	}
+	if item.Status != ForwardStatusFailed {
+		q.mu.Unlock()
+		return ErrForwardInProgress
+	}
+	item.Status = ForwardStatusWaiting
+	item.UpdatedAt = time.Now()
+	q.mu.Unlock()
+
+	log.Printf("[ForwardQueue] Retry started item_id=%d", id)
+	go q.deliver(id)
+	return nil
+}
+
+// Discard removes a failed item without delivering it
+func (q *ForwardQueue) Discard(id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return ErrForwardNotFound
+	}
+	if item.Status != ForwardStatusFailed {
+		return ErrForwardInProgress
+	}
+
+	delete(q.items, id)
+	log.Printf("[ForwardQueue] Item discarded item_id=%d", id)
+	return nil
+}
@@ -0,0 +1,205 @@
+package assistant
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// redirectTransport sends OpenAI API calls to a test server
+type redirectTransport struct {
+	host string
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = "http"
+	req.URL.Host = t.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newForwardTestClient creates a client whose message creation fails while failing is set
+func newForwardTestClient(t *testing.T, failing *atomic.Bool) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs"):
+			w.Write([]byte(`{"data": []}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages"):
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error": {"message": "server error"}}`))
+				return
+			}
+			w.Write([]byte(`{"id": "msg_1", "role": "user"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient("test-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+}
+
+func TestForwardQueue_SuccessRemovesItem(t *testing.T) {
+	var failing atomic.Bool
+	client := newForwardTestClient(t, &failing)
+	queue := client.ForwardQueue()
+
+	if err := queue.Forward(ForwardItem{ThreadID: "thread_1", Content: "hello"}); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+
+	if items := queue.List(); len(items) != 0 {
+		t.Errorf("expected empty queue after delivery, got %+v", items)
+	}
+}
+
+func TestForwardQueue_FailureKeepsItemForRetry(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	client := newForwardTestClient(t, &failing)
+	queue := client.ForwardQueue()
+
+	if err := queue.Forward(ForwardItem{ThreadID: "thread_1", AvatarName: "Alice", Content: "hello"}); err == nil {
+		t.Fatal("expected Forward to fail")
+	}
+
+	items := queue.List()
+	if len(items) != 1 {
+		t.Fatalf("expected 1 failed item, got %d", len(items))
+	}
+	item := items[0]
+	if item.Status != ForwardStatusFailed || item.Attempts != 1 || item.LastError == "" {
+		t.Errorf("unexpected failed item: %+v", item)
+	}
+
+	// Retry after the API recovers
+	failing.Store(false)
+	if err := queue.Retry(item.ID); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(queue.List()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if items := queue.List(); len(items) != 0 {
+		t.Errorf("expected item to be delivered after retry, got %+v", items)
+	}
+}
+
+func TestForwardQueue_RetryAndDiscardErrors(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	client := newForwardTestClient(t, &failing)
+	queue := client.ForwardQueue()
+
+	if err := queue.Retry(999); err != ErrForwardNotFound {
+		t.Errorf("expected ErrForwardNotFound, got %v", err)
+	}
+	if err := queue.Discard(999); err != ErrForwardNotFound {
+		t.Errorf("expected ErrForwardNotFound, got %v", err)
+	}
+
+	queue.Forward(ForwardItem{ThreadID: "thread_1", Content: "hello"})
+	id := queue.List()[0].ID
+
+	if err := queue.Discard(id); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if items := queue.List(); len(items) != 0 {
+		t.Errorf("expected empty queue after discard, got %+v", items)
+	}
+}
+
+// memoryLedger is a ForwardLedger keeping its claims in memory
+type memoryLedger struct {
+	claims map[string]time.Time
+}
+
+func (l *memoryLedger) ClaimThreadForward(threadID string, messageID int64, since time.Time) (bool, error) {
+	key := fmt.Sprintf("%s/%d", threadID, messageID)
+	if at, ok := l.claims[key]; ok && !at.Before(since) {
+		return false, nil
+	}
+	l.claims[key] = time.Now()
+	return true, nil
+}
+
+func (l *memoryLedger) ReleaseThreadForward(threadID string, messageID int64) error {
+	delete(l.claims, fmt.Sprintf("%s/%d", threadID, messageID))
+	return nil
+}
+
+func TestForwardQueue_SkipsDuplicateMessages(t *testing.T) {
+	var failing atomic.Bool
+	client := newForwardTestClient(t, &failing)
+	queue := client.ForwardQueue()
+	queue.SetLedger(&memoryLedger{claims: make(map[string]time.Time)}, time.Minute)
+
+	if added, err := queue.AddOnce(client, "thread_1", 1, "hello"); err != nil || !added {
+		t.Fatalf("expected the first message to be added, got %t err=%v", added, err)
+	}
+	if added, err := queue.AddOnce(client, "thread_1", 1, "hello"); err != nil || added {
+		t.Errorf("expected the same message to be skipped, got %t err=%v", added, err)
+	}
+	if added, _ := queue.AddOnce(client, "thread_2", 1, "hello"); !added {
+		t.Error("expected the same message to be added to another thread")
+	}
+
+	// A failed forward does not count, so retrying it adds the message
+	failing.Store(true)
+	if _, err := queue.AddOnce(client, "thread_1", 2, "again"); err == nil {
+		t.Fatal("expected the forward to fail")
+	}
+	failing.Store(false)
+	if added, err := queue.AddOnce(client, "thread_1", 2, "again"); err != nil || !added {
+		t.Errorf("expected the retried message to be added, got %t err=%v", added, err)
+	}
+
+	// Forwarding through the queue skips duplicates as well
+	if err := queue.Forward(ForwardItem{ThreadID: "thread_1", MessageID: 1, Content: "hello"}); err != nil {
+		t.Errorf("expected a duplicate forward to succeed without adding, got %v", err)
+	}
+}
+
+func TestForwardQueue_ForwardsDistinctMessagesWithSameText(t *testing.T) {
+	var added atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs"):
+			w.Write([]byte(`{"data": []}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages"):
+			added.Add(1)
+			w.Write([]byte(`{"id": "msg_1", "role": "user"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+	queue := client.ForwardQueue()
+	queue.SetLedger(&memoryLedger{claims: make(map[string]time.Time)}, time.Minute)
+
+	// Two messages saying "yes" are both part of the conversation
+	for _, messageID := range []int64{1, 2} {
+		if err := queue.Forward(ForwardItem{ThreadID: "thread_1", MessageID: messageID, Content: "[User]: yes"}); err != nil {
+			t.Fatalf("Forward failed message_id=%d: %v", messageID, err)
+		}
+	}
+	if got := added.Load(); got != 2 {
+		t.Errorf("expected both messages to be added, got %d", got)
+	}
+}
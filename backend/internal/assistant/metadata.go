@@ -0,0 +1,131 @@
+package assistant
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// AppName identifies the assistants and threads created by this application in their metadata
+const AppName = "multi-avatar-chat"
+
+// Metadata keys set on assistants and threads
+const (
+	MetadataKeyApp            = "app"
+	MetadataKeyConversationID = "conversation_id"
+	MetadataKeyAvatarID       = "avatar_id"
+)
+
+// Metadata holds the key-value pairs OpenAI stores with an assistant or thread
+type Metadata map[string]string
+
+// AppMetadata returns the metadata every assistant and thread created by this application carries
+func AppMetadata() Metadata {
+	return Metadata{MetadataKeyApp: AppName}
+}
+
+// AvatarMetadata returns the metadata of the assistant of an avatar
+func AvatarMetadata(avatarID int64) Metadata {
+	m := AppMetadata()
+	m[MetadataKeyAvatarID] = strconv.FormatInt(avatarID, 10)
+	return m
+}
+
+// ThreadMetadata returns the metadata of the thread of an avatar in a conversation
+func ThreadMetadata(conversationID, avatarID int64) Metadata {
+	m := AvatarMetadata(avatarID)
+	m[MetadataKeyConversationID] = strconv.FormatInt(conversationID, 10)
+	return m
+}
+
+// IsApp reports whether the metadata marks an object created by this application
+func (m Metadata) IsApp() bool {
+	return m[MetadataKeyApp] == AppName
+}
+
+// ID returns the ID stored under key, or false if it is missing or not a number
+func (m Metadata) ID(key string) (int64, bool) {
+	id, err := strconv.ParseInt(m[key], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// Merge returns a copy of the metadata with the entries of other added, replacing existing keys
+func (m Metadata) Merge(other Metadata) Metadata {
+	merged := make(Metadata, len(m)+len(other))
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// updateMetadataRequest replaces the metadata of an assistant or thread
+type updateMetadataRequest struct {
+	Metadata Metadata `json:"metadata"`
+}
+
+// UpdateAssistantMetadata replaces the metadata of an existing assistant
+func (c *Client) UpdateAssistantMetadata(id string, metadata Metadata) (*Assistant, error) {
+	var assistant Assistant
+	if err := c.updateMetadata(baseURL+"/assistants/"+id, metadata, &assistant); err != nil {
+		log.Printf("[Assistant] UpdateAssistantMetadata failed assistant_id=%s err=%v", id, err)
+		return nil, err
+	}
+
+	log.Printf("[Assistant] UpdateAssistantMetadata completed assistant_id=%s metadata=%v", id, metadata)
+	return &assistant, nil
+}
+
+// UpdateThreadMetadata replaces the metadata of an existing thread
+func (c *Client) UpdateThreadMetadata(id string, metadata Metadata) (*Thread, error) {
+	var thread Thread
+	if err := c.updateMetadata(baseURL+"/threads/"+id, metadata, &thread); err != nil {
+		log.Printf("[Assistant] UpdateThreadMetadata failed thread_id=%s err=%v", id, err)
+		return nil, err
+	}
+
+	log.Printf("[Assistant] UpdateThreadMetadata completed thread_id=%s metadata=%v", id, metadata)
+	return &thread, nil
+}
+
+// updateMetadata posts new metadata to an object URL and decodes the updated object into v
+func (c *Client) updateMetadata(url string, metadata Metadata, v any) error {
+	if metadata == nil {
+		metadata = Metadata{}
+	}
+
+	body, err := json.Marshal(updateMetadataRequest{Metadata: metadata})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.handleError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
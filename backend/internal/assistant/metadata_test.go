@@ -0,0 +1,93 @@
+package assistant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestThreadMetadata(t *testing.T) {
+	md := ThreadMetadata(12, 34)
+	if !md.IsApp() {
+		t.Errorf("expected app metadata, got %v", md)
+	}
+	if id, ok := md.ID(MetadataKeyConversationID); !ok || id != 12 {
+		t.Errorf("expected conversation_id 12, got %d ok=%v", id, ok)
+	}
+	if id, ok := md.ID(MetadataKeyAvatarID); !ok || id != 34 {
+		t.Errorf("expected avatar_id 34, got %d ok=%v", id, ok)
+	}
+
+	if Metadata(nil).IsApp() {
+		t.Error("expected nil metadata not to be app metadata")
+	}
+	if _, ok := (Metadata{MetadataKeyAvatarID: "abc"}).ID(MetadataKeyAvatarID); ok {
+		t.Error("expected a non-numeric ID to be rejected")
+	}
+}
+
+func TestMetadata_Merge(t *testing.T) {
+	existing := Metadata{"owner": "team-a", MetadataKeyAvatarID: "1"}
+	merged := existing.Merge(AvatarMetadata(2))
+
+	if merged["owner"] != "team-a" || merged[MetadataKeyAvatarID] != "2" || !merged.IsApp() {
+		t.Errorf("unexpected merged metadata %v", merged)
+	}
+	if existing[MetadataKeyAvatarID] != "1" {
+		t.Error("expected Merge not to modify the receiver")
+	}
+}
+
+func TestMetadata_SentToOpenAI(t *testing.T) {
+	var requests []string
+	var sent []Metadata
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Metadata Metadata `json:"metadata"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		sent = append(sent, body.Metadata)
+
+		if strings.HasPrefix(r.URL.Path, "/v1/threads") {
+			json.NewEncoder(w).Encode(Thread{ID: "thread_1", Metadata: body.Metadata})
+			return
+		}
+		json.NewEncoder(w).Encode(Assistant{ID: "asst_1", Metadata: body.Metadata})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	thread, err := client.CreateThreadWithMetadata(ThreadMetadata(1, 2))
+	if err != nil {
+		t.Fatalf("CreateThreadWithMetadata failed: %v", err)
+	}
+	if thread.Metadata[MetadataKeyConversationID] != "1" {
+		t.Errorf("expected the thread metadata to be returned, got %v", thread.Metadata)
+	}
+
+	if _, err := client.CreateAssistant("Alice", "prompt"); err != nil {
+		t.Fatalf("CreateAssistant failed: %v", err)
+	}
+
+	updated, err := client.UpdateAssistantMetadata("asst_1", AvatarMetadata(2))
+	if err != nil {
+		t.Fatalf("UpdateAssistantMetadata failed: %v", err)
+	}
+	if updated.Metadata[MetadataKeyAvatarID] != "2" {
+		t.Errorf("expected the assistant metadata to be returned, got %v", updated.Metadata)
+	}
+
+	expected := []string{"POST /v1/threads", "POST /v1/assistants", "POST /v1/assistants/asst_1"}
+	if strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if !sent[1].IsApp() || sent[1][MetadataKeyAvatarID] != "" {
+		t.Errorf("expected a new assistant to carry only the app tag, got %v", sent[1])
+	}
+}
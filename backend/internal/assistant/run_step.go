@@ -0,0 +1,169 @@
+package assistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Error codes OpenAI reports in the last_error of a failed run or run step
+const (
+	RunErrorServerError       = "server_error"
+	RunErrorRateLimitExceeded = "rate_limit_exceeded"
+	RunErrorInvalidPrompt     = "invalid_prompt"
+)
+
+// RunError describes why a run or run step failed
+type RunError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e RunError) String() string {
+	return e.Code + ": " + e.Message
+}
+
+// RunStep represents a step taken by the assistant during a run
+type RunStep struct {
+	ID          string         `json:"id"`
+	RunID       string         `json:"run_id"`
+	Type        string         `json:"type"`
+	Status      string         `json:"status"`
+	StepDetails RunStepDetails `json:"step_details"`
+	// LastError is set when the step failed
+	LastError *RunError `json:"last_error,omitempty"`
+}
+
+// RunStepDetails holds the tool calls made in a tool_calls step
+type RunStepDetails struct {
+	Type      string     `json:"type"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall represents one tool invocation within a run step
+type ToolCall struct {
+	ID              string               `json:"id"`
+	Type            string               `json:"type"`
+	CodeInterpreter *CodeInterpreterCall `json:"code_interpreter,omitempty"`
+}
+
+// CodeInterpreterCall holds the code run by the code interpreter and what it produced
+type CodeInterpreterCall struct {
+	Input   string                  `json:"input"`
+	Outputs []CodeInterpreterOutput `json:"outputs"`
+}
+
+// CodeInterpreterOutput is a single output of a code interpreter call
+// Type is "logs" (text output) or "image" (a generated file)
+type CodeInterpreterOutput struct {
+	Type  string                `json:"type"`
+	Logs  string                `json:"logs,omitempty"`
+	Image *CodeInterpreterImage `json:"image,omitempty"`
+}
+
+// CodeInterpreterImage references an image file generated by the code interpreter
+type CodeInterpreterImage struct {
+	FileID string `json:"file_id"`
+}
+
+// listRunStepsResponse represents the response from listing run steps
+type listRunStepsResponse struct {
+	Data []RunStep `json:"data"`
+}
+
+// ListRunSteps retrieves the steps of a run in the order they were taken
+func (c *Client) ListRunSteps(threadID, runID string) ([]RunStep, error) {
+	log.Printf("[Assistant] ListRunSteps started thread_id=%s run_id=%s", threadID, runID)
+
+	url := fmt.Sprintf("%s/threads/%s/runs/%s/steps?order=asc&limit=100", baseURL, threadID, runID)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		log.Printf("[Assistant] ListRunSteps failed: send request err=%v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Assistant] ListRunSteps failed: API error status=%d run_id=%s", resp.StatusCode, runID)
+		return nil, c.handleError(resp)
+	}
+
+	var listResp listRunStepsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	log.Printf("[Assistant] ListRunSteps completed run_id=%s step_count=%d", runID, len(listResp.Data))
+	return listResp.Data, nil
+}
+
+// GetRunStep retrieves a single step of a run
+func (c *Client) GetRunStep(threadID, runID, stepID string) (*RunStep, error) {
+	url := fmt.Sprintf("%s/threads/%s/runs/%s/steps/%s", baseURL, threadID, runID, stepID)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		log.Printf("[Assistant] GetRunStep failed: send request err=%v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Assistant] GetRunStep failed: API error status=%d run_id=%s step_id=%s", resp.StatusCode, runID, stepID)
+		return nil, c.handleError(resp)
+	}
+
+	var step RunStep
+	if err := json.NewDecoder(resp.Body).Decode(&step); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &step, nil
+}
+
+// RunFailure classifies why a run ended without completing
+// The error of the failed step is preferred since it is more specific than the run's;
+// a run that ended without an error, e.g. expired or cancelled, is classified by its status.
+// failedStepID is empty when no step failed or the steps could not be listed
+func (c *Client) RunFailure(threadID string, run *Run) (failure RunError, failedStepID string) {
+	steps, err := c.ListRunSteps(threadID, run.ID)
+	if err != nil {
+		log.Printf("[Assistant] RunFailure: failed to list run steps run_id=%s err=%v", run.ID, err)
+	}
+	for _, step := range steps {
+		if step.Status == "failed" && step.LastError != nil {
+			return *step.LastError, step.ID
+		}
+	}
+
+	if run.LastError != nil {
+		return *run.LastError, ""
+	}
+	return RunError{Code: run.Status, Message: "run ended with status: " + run.Status}, ""
+}
+
+// CodeInterpreterCalls returns the code interpreter calls made across the given steps, in order
+func CodeInterpreterCalls(steps []RunStep) []CodeInterpreterCall {
+	var calls []CodeInterpreterCall
+	for _, step := range steps {
+		for _, call := range step.StepDetails.ToolCalls {
+			if call.Type == ToolCodeInterpreter && call.CodeInterpreter != nil {
+				calls = append(calls, *call.CodeInterpreter)
+			}
+		}
+	}
+	return calls
+}
@@ -0,0 +1,93 @@
+package assistant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForRun_ReturnsRunEndedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "run_1", "status": "failed",
+			"last_error": {"code": "rate_limit_exceeded", "message": "Rate limit reached"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	_, err := client.WaitForRun("thread_1", "run_1", time.Second)
+	var ended *RunEndedError
+	if !errors.As(err, &ended) {
+		t.Fatalf("expected RunEndedError, got %v", err)
+	}
+	if ended.Run.LastError == nil || ended.Run.LastError.Code != RunErrorRateLimitExceeded {
+		t.Errorf("expected the run's last error, got %+v", ended.Run)
+	}
+	if err.Error() != "run ended with status: failed (rate_limit_exceeded: Rate limit reached)" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	steps := map[string]string{
+		"run_step_failed": `{"data": [
+			{"id": "step_1", "status": "completed"},
+			{"id": "step_2", "status": "failed", "last_error": {"code": "rate_limit_exceeded", "message": "Rate limit reached"}}
+		]}`,
+		"run_failed": `{"data": [{"id": "step_1", "status": "completed"}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/threads/thread_1/runs/run_step_failed/steps/step_2":
+			w.Write([]byte(`{"id": "step_2", "run_id": "run_step_failed", "status": "failed",
+				"last_error": {"code": "rate_limit_exceeded", "message": "Rate limit reached"}}`))
+		case strings.HasSuffix(r.URL.Path, "/steps"):
+			parts := strings.Split(r.URL.Path, "/")
+			body, ok := steps[parts[len(parts)-2]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(body))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	serverError := &RunError{Code: RunErrorServerError, Message: "Something went wrong"}
+	tests := []struct {
+		name   string
+		run    Run
+		code   string
+		stepID string
+	}{
+		{"failed step", Run{ID: "run_step_failed", Status: "failed", LastError: serverError}, RunErrorRateLimitExceeded, "step_2"},
+		{"run error", Run{ID: "run_failed", Status: "failed", LastError: serverError}, RunErrorServerError, ""},
+		{"status only", Run{ID: "run_unknown", Status: "expired"}, "expired", ""},
+	}
+
+	for _, tt := range tests {
+		failure, stepID := client.RunFailure("thread_1", &tt.run)
+		if failure.Code != tt.code || stepID != tt.stepID {
+			t.Errorf("%s: expected code %q step %q, got %q step %q", tt.name, tt.code, tt.stepID, failure.Code, stepID)
+		}
+	}
+
+	step, err := client.GetRunStep("thread_1", "run_step_failed", "step_2")
+	if err != nil {
+		t.Fatalf("GetRunStep failed: %v", err)
+	}
+	if step.RunID != "run_step_failed" || step.LastError == nil || step.LastError.Code != RunErrorRateLimitExceeded {
+		t.Errorf("unexpected step %+v", step)
+	}
+}
@@ -0,0 +1,230 @@
+package assistant
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxStreamLine is the longest line of a run event stream that is read; run objects fit well within it
+const maxStreamLine = 1 << 20
+
+// Run stream events handled by StreamRun
+const (
+	streamEventRunCreated   = "thread.run.created"
+	streamEventMessageDelta = "thread.message.delta"
+	streamEventError        = "error"
+	streamEventDone         = "done"
+	streamEventRunPrefix    = "thread.run."
+)
+
+// RunStreamHandler receives the progress of a streamed run
+// Both callbacks are optional and are called from the goroutine running StreamRun
+type RunStreamHandler struct {
+	// OnCreated is called with the run as soon as it exists, before any text is written
+	OnCreated func(run *Run)
+	// OnDelta is called with each piece of text the assistant writes, in order
+	OnDelta func(text string)
+}
+
+// messageDelta is the data of a thread.message.delta event
+type messageDelta struct {
+	ID    string `json:"id"`
+	Delta struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text *struct {
+				Value string `json:"value"`
+			} `json:"text,omitempty"`
+		} `json:"content"`
+	} `json:"delta"`
+}
+
+// StreamRun creates a run with streaming and waits for it to finish, passing the text to the handler as it is written
+// Like WaitForRun, it returns the final run with a RunEndedError when the run ends without completing and
+// ErrRunTimeout when the run is still active after RunTimeout. If the stream breaks off, or the server answers
+// without streaming, the rest of the run is polled. Until the run is created the errors are those of CreateRun,
+// with a nil run
+func (c *Client) StreamRun(threadID string, reqBody CreateRunRequest, handler RunStreamHandler) (*Run, error) {
+	log.Printf("[Assistant] StreamRun started thread_id=%s assistant_id=%s context_length=%d",
+		threadID, reqBody.AssistantID, len(reqBody.AdditionalInstructions))
+
+	reqBody.Stream = true
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// The stream is bounded by the run timeout rather than the HTTP client's request timeout
+	timeout := c.RunTimeout()
+	deadline := time.Now().Add(timeout)
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithDeadline(parent, deadline)
+	defer cancel()
+	view := *c
+	view.ctx = ctx
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	view.httpClient = &httpClient
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/threads/"+threadID+"/runs", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	view.setHeaders(req)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := view.do(req)
+	if err != nil {
+		log.Printf("[Assistant] StreamRun failed: send request err=%v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Assistant] StreamRun failed: API error status=%d thread_id=%s assistant_id=%s",
+			resp.StatusCode, threadID, reqBody.AssistantID)
+		return nil, c.handleError(resp)
+	}
+
+	// A server that ignores stream answers with the run itself, which is then polled
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var run Run
+		if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		log.Printf("[Assistant] StreamRun: server did not stream, polling run_id=%s", run.ID)
+		if handler.OnCreated != nil {
+			handler.OnCreated(&run)
+		}
+		return c.finishStreamedRun(threadID, &run, deadline)
+	}
+
+	run, ended, err := readRunStream(resp, handler)
+	if run == nil {
+		if err == nil {
+			err = errors.New("run stream ended before the run was created")
+		}
+		log.Printf("[Assistant] StreamRun failed: no run thread_id=%s err=%v", threadID, err)
+		return nil, err
+	}
+	if ended {
+		return c.endStreamedRun(run)
+	}
+	if ctx.Err() != nil {
+		log.Printf("[Assistant] StreamRun timeout run_id=%s", run.ID)
+		return run, ErrRunTimeout
+	}
+
+	log.Printf("[Assistant] StreamRun: stream broke off, polling run_id=%s err=%v", run.ID, err)
+	return c.finishStreamedRun(threadID, run, deadline)
+}
+
+// readRunStream reads run events until the run ends or the stream stops
+// Returns the latest state of the run, nil if it was never created, and whether that state is final
+func readRunStream(resp *http.Response, handler RunStreamHandler) (*Run, bool, error) {
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+
+	var run *Run
+	var event string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		case line != "":
+			continue
+		}
+
+		// A blank line ends the event
+		payload := data.String()
+		name := event
+		event = ""
+		data.Reset()
+
+		switch {
+		case name == streamEventDone:
+			return run, false, nil
+		case name == streamEventError:
+			return run, false, fmt.Errorf("run stream error: %s", payload)
+		case name == streamEventMessageDelta:
+			var delta messageDelta
+			if err := json.Unmarshal([]byte(payload), &delta); err != nil || handler.OnDelta == nil {
+				continue
+			}
+			for _, content := range delta.Delta.Content {
+				if content.Type == "text" && content.Text != nil && content.Text.Value != "" {
+					handler.OnDelta(content.Text.Value)
+				}
+			}
+		case strings.HasPrefix(name, streamEventRunPrefix) && !strings.HasPrefix(name, "thread.run.step."):
+			var updated Run
+			if err := json.Unmarshal([]byte(payload), &updated); err != nil {
+				continue
+			}
+			created := run == nil
+			run = &updated
+			if created {
+				log.Printf("[Assistant] StreamRun created run_id=%s status=%s", run.ID, run.Status)
+				if handler.OnCreated != nil {
+					handler.OnCreated(run)
+				}
+			}
+			if name != streamEventRunCreated && runFinished(run.Status) {
+				return run, true, nil
+			}
+		}
+	}
+	return run, false, scanner.Err()
+}
+
+// runFinished reports whether a run status is final
+func runFinished(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled", "expired", "incomplete":
+		return true
+	}
+	return false
+}
+
+// endStreamedRun returns a finished run with the error WaitForRun gives for its status
+func (c *Client) endStreamedRun(run *Run) (*Run, error) {
+	if run.Status == "completed" {
+		log.Printf("[Assistant] StreamRun completed run_id=%s", run.ID)
+		return run, nil
+	}
+	log.Printf("[Assistant] StreamRun failed: run ended status=%s run_id=%s last_error=%v", run.Status, run.ID, run.LastError)
+	return run, &RunEndedError{Run: run}
+}
+
+// finishStreamedRun polls a run whose stream could not be read to the end, until the deadline
+func (c *Client) finishStreamedRun(threadID string, run *Run, deadline time.Time) (*Run, error) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return run, ErrRunTimeout
+	}
+	final, err := c.WaitForRun(threadID, run.ID, remaining)
+	if final == nil {
+		return run, err
+	}
+	return final, err
+}
@@ -0,0 +1,143 @@
+package assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStreamTestClient creates a client whose API calls go to handler
+func newStreamTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+}
+
+// writeStreamEvent writes one server-sent event of a run stream
+func writeStreamEvent(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	w.(http.Flusher).Flush()
+}
+
+func TestStreamRun_Deltas(t *testing.T) {
+	client := newStreamTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body CreateRunRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream || body.AssistantID != "asst_1" {
+			t.Errorf("expected a streamed run of asst_1, got %+v", body)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeStreamEvent(w, "thread.run.created", `{"id": "run_1", "status": "queued", "model": "gpt-4o"}`)
+		writeStreamEvent(w, "thread.run.step.created", `{"id": "step_1", "status": "in_progress"}`)
+		writeStreamEvent(w, "thread.message.delta", `{"id": "msg_1", "delta": {"content": [{"index": 0, "type": "text", "text": {"value": "Hel"}}]}}`)
+		writeStreamEvent(w, "thread.message.delta", `{"id": "msg_1", "delta": {"content": [{"index": 0, "type": "text", "text": {"value": "lo"}}]}}`)
+		writeStreamEvent(w, "thread.run.completed", `{"id": "run_1", "status": "completed", "model": "gpt-4o"}`)
+		writeStreamEvent(w, "done", "[DONE]")
+	})
+
+	var created []string
+	var text strings.Builder
+	run, err := client.StreamRun("thread_1", CreateRunRequest{AssistantID: "asst_1"}, RunStreamHandler{
+		OnCreated: func(run *Run) { created = append(created, run.ID) },
+		OnDelta:   func(delta string) { text.WriteString(delta) },
+	})
+	if err != nil {
+		t.Fatalf("StreamRun failed: %v", err)
+	}
+	if run.ID != "run_1" || run.Status != "completed" || run.Model != "gpt-4o" {
+		t.Errorf("unexpected run: %+v", run)
+	}
+	if len(created) != 1 || created[0] != "run_1" {
+		t.Errorf("expected OnCreated once with run_1, got %v", created)
+	}
+	if text.String() != "Hello" {
+		t.Errorf("expected the deltas in order, got %q", text.String())
+	}
+}
+
+func TestStreamRun_Failed(t *testing.T) {
+	client := newStreamTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeStreamEvent(w, "thread.run.created", `{"id": "run_1", "status": "queued"}`)
+		writeStreamEvent(w, "thread.run.failed", `{"id": "run_1", "status": "failed", "last_error": {"code": "rate_limit_exceeded", "message": "Rate limit reached"}}`)
+	})
+
+	run, err := client.StreamRun("thread_1", CreateRunRequest{AssistantID: "asst_1"}, RunStreamHandler{})
+	var ended *RunEndedError
+	if !errors.As(err, &ended) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected a rate limited RunEndedError, got %v", err)
+	}
+	if run == nil || run.ID != "run_1" {
+		t.Errorf("expected the failed run, got %+v", run)
+	}
+}
+
+func TestStreamRun_PollsWhenStreamBreaksOff(t *testing.T) {
+	client := newStreamTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id": "run_1", "status": "completed"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeStreamEvent(w, "thread.run.created", `{"id": "run_1", "status": "queued"}`)
+	})
+
+	run, err := client.StreamRun("thread_1", CreateRunRequest{AssistantID: "asst_1"}, RunStreamHandler{})
+	if err != nil || run.Status != "completed" {
+		t.Fatalf("expected the polled run to complete, got %+v err=%v", run, err)
+	}
+}
+
+func TestStreamRun_ServerWithoutStreaming(t *testing.T) {
+	client := newStreamTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id": "run_1", "status": "completed"}`))
+			return
+		}
+		w.Write([]byte(`{"id": "run_1", "status": "queued"}`))
+	})
+
+	created := false
+	run, err := client.StreamRun("thread_1", CreateRunRequest{AssistantID: "asst_1"}, RunStreamHandler{
+		OnCreated: func(*Run) { created = true },
+	})
+	if err != nil || run.Status != "completed" || !created {
+		t.Fatalf("expected the run to be polled to completion, got %+v err=%v created=%v", run, err, created)
+	}
+}
+
+func TestStreamRun_CreateError(t *testing.T) {
+	client := newStreamTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"message": "No assistant found with id 'asst_1'.", "type": "invalid_request_error"}}`))
+	})
+
+	run, err := client.StreamRun("thread_1", CreateRunRequest{AssistantID: "asst_1"}, RunStreamHandler{})
+	if run != nil || !errors.Is(err, ErrAssistantNotFound) {
+		t.Errorf("expected ErrAssistantNotFound without a run, got %+v err=%v", run, err)
+	}
+}
+
+func TestStreamRun_Timeout(t *testing.T) {
+	client := newStreamTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeStreamEvent(w, "thread.run.created", `{"id": "run_1", "status": "queued"}`)
+		<-r.Context().Done()
+	})
+	client.timeouts.Run = 200 * time.Millisecond
+
+	run, err := client.StreamRun("thread_1", CreateRunRequest{AssistantID: "asst_1"}, RunStreamHandler{})
+	if !errors.Is(err, ErrRunTimeout) || run == nil {
+		t.Errorf("expected ErrRunTimeout with the run, got %+v err=%v", run, err)
+	}
+}
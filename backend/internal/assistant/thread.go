@@ -3,23 +3,45 @@ package assistant
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Thread represents an OpenAI Thread
 type Thread struct {
-	ID        string `json:"id"`
-	CreatedAt int64  `json:"created_at"`
+	ID        string   `json:"id"`
+	CreatedAt int64    `json:"created_at"`
+	Metadata  Metadata `json:"metadata,omitempty"`
 }
 
-// CreateThread creates a new thread
+// createThreadRequest represents a request to create a thread
+type createThreadRequest struct {
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// CreateThread creates a new thread tagged with AppMetadata
 func (c *Client) CreateThread() (*Thread, error) {
-	log.Printf("[Assistant] CreateThread started")
+	return c.CreateThreadWithMetadata(AppMetadata())
+}
+
+// CreateThreadWithMetadata creates a new thread with the given metadata
+func (c *Client) CreateThreadWithMetadata(metadata Metadata) (*Thread, error) {
+	log.Printf("[Assistant] CreateThread started metadata=%v", metadata)
+
+	body, err := json.Marshal(createThreadRequest{Metadata: metadata})
+	if err != nil {
+		log.Printf("[Assistant] CreateThread failed: marshal request err=%v", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
 
-	req, err := http.NewRequest(http.MethodPost, baseURL+"/threads", bytes.NewReader([]byte("{}")))
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/threads", bytes.NewReader(body))
 	if err != nil {
 		log.Printf("[Assistant] CreateThread failed: create request err=%v", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -27,7 +49,7 @@ func (c *Client) CreateThread() (*Thread, error) {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] CreateThread failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -49,6 +71,33 @@ func (c *Client) CreateThread() (*Thread, error) {
 	return &thread, nil
 }
 
+// GetThread retrieves a thread, including its metadata
+func (c *Client) GetThread(id string) (*Thread, error) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/threads/"+id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleError(resp)
+	}
+
+	var thread Thread
+	if err := json.NewDecoder(resp.Body).Decode(&thread); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &thread, nil
+}
+
 // DeleteThread deletes a thread
 func (c *Client) DeleteThread(id string) error {
 	req, err := http.NewRequest(http.MethodDelete, baseURL+"/threads/"+id, nil)
@@ -58,7 +107,7 @@ func (c *Client) DeleteThread(id string) error {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -77,6 +126,8 @@ type Message struct {
 	Role      string           `json:"role"`
 	Content   []MessageContent `json:"content"`
 	CreatedAt int64            `json:"created_at"`
+	// AssistantID is the assistant that wrote an assistant message
+	AssistantID string `json:"assistant_id,omitempty"`
 }
 
 // MessageContent represents the content of a message
@@ -87,7 +138,75 @@ type MessageContent struct {
 
 // TextObject represents text content
 type TextObject struct {
-	Value string `json:"value"`
+	Value       string       `json:"value"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// Annotation types
+const (
+	AnnotationFileCitation = "file_citation"
+	AnnotationFilePath     = "file_path"
+)
+
+// Annotation marks a part of the text that refers to a file
+// Text is the marker in the message text, located between StartIndex and EndIndex
+type Annotation struct {
+	Type         string            `json:"type"`
+	Text         string            `json:"text"`
+	StartIndex   int               `json:"start_index"`
+	EndIndex     int               `json:"end_index"`
+	FileCitation *FileCitationInfo `json:"file_citation,omitempty"`
+	FilePath     *FilePathInfo     `json:"file_path,omitempty"`
+}
+
+// FileCitationInfo references the file a file_citation annotation cites
+type FileCitationInfo struct {
+	FileID string `json:"file_id"`
+	Quote  string `json:"quote,omitempty"`
+}
+
+// FilePathInfo references a file generated by a tool
+type FilePathInfo struct {
+	FileID string `json:"file_id"`
+}
+
+// Citation is a source referenced by an assistant message
+// Filename is empty until ResolveCitationFilenames looks it up
+type Citation struct {
+	Type       string
+	Text       string
+	FileID     string
+	Filename   string
+	Quote      string
+	StartIndex int
+	EndIndex   int
+}
+
+// AssistantMessage is the text of an assistant message with the sources it cites
+type AssistantMessage struct {
+	ID        string
+	Content   string
+	Citations []Citation
+}
+
+// citations converts the annotations of a text into citations
+// Annotations that reference no file are skipped
+func (t *TextObject) citations() []Citation {
+	var citations []Citation
+	for _, a := range t.Annotations {
+		citation := Citation{Type: a.Type, Text: a.Text, StartIndex: a.StartIndex, EndIndex: a.EndIndex}
+		switch {
+		case a.FileCitation != nil:
+			citation.FileID = a.FileCitation.FileID
+			citation.Quote = a.FileCitation.Quote
+		case a.FilePath != nil:
+			citation.FileID = a.FilePath.FileID
+		default:
+			continue
+		}
+		citations = append(citations, citation)
+	}
+	return citations
 }
 
 // CreateMessageRequest represents a request to create a message
@@ -98,15 +217,25 @@ type CreateMessageRequest struct {
 
 // CreateMessage adds a message to a thread
 func (c *Client) CreateMessage(threadID, content string) (*Message, error) {
+	return c.createMessage(threadID, "user", content)
+}
+
+// CreateAssistantMessage adds a message written as the assistant to a thread, such as a reply generated outside a run
+func (c *Client) CreateAssistantMessage(threadID, content string) (*Message, error) {
+	return c.createMessage(threadID, "assistant", content)
+}
+
+// createMessage adds a message with the given role to a thread
+func (c *Client) createMessage(threadID, role, content string) (*Message, error) {
 	// Truncate content for logging
 	contentPreview := content
 	if len(contentPreview) > 50 {
 		contentPreview = contentPreview[:50] + "..."
 	}
-	log.Printf("[Assistant] CreateMessage started thread_id=%s content_preview=%q", threadID, contentPreview)
+	log.Printf("[Assistant] CreateMessage started thread_id=%s role=%s content_preview=%q", threadID, role, contentPreview)
 
 	reqBody := CreateMessageRequest{
-		Role:    "user",
+		Role:    role,
 		Content: content,
 	}
 
@@ -124,7 +253,7 @@ func (c *Client) CreateMessage(threadID, content string) (*Message, error) {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] CreateMessage failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -148,14 +277,50 @@ func (c *Client) CreateMessage(threadID, content string) (*Message, error) {
 
 // ListMessagesResponse represents the response from listing messages
 type ListMessagesResponse struct {
-	Data []Message `json:"data"`
+	Data    []Message `json:"data"`
+	HasMore bool      `json:"has_more"`
+	LastID  string    `json:"last_id"`
+}
+
+// Message orders accepted by ListMessages
+const (
+	MessageOrderAsc  = "asc"
+	MessageOrderDesc = "desc"
+)
+
+// ListMessagesOptions selects a page of thread messages
+type ListMessagesOptions struct {
+	// Limit is the page size, 1 to 100; 0 uses the API default of 20
+	Limit int
+	// Order is MessageOrderDesc (newest first, the API default) or MessageOrderAsc
+	Order string
+	// After continues listing after this message ID, usually LastID of the previous page
+	After string
+}
+
+// query encodes the options as URL query parameters
+func (o ListMessagesOptions) query() string {
+	values := url.Values{}
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Order != "" {
+		values.Set("order", o.Order)
+	}
+	if o.After != "" {
+		values.Set("after", o.After)
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
 }
 
-// ListMessages retrieves messages from a thread
-func (c *Client) ListMessages(threadID string) ([]Message, error) {
-	log.Printf("[Assistant] ListMessages started thread_id=%s", threadID)
+// ListMessages retrieves one page of messages from a thread
+func (c *Client) ListMessages(threadID string, opts ListMessagesOptions) (*ListMessagesResponse, error) {
+	log.Printf("[Assistant] ListMessages started thread_id=%s limit=%d order=%s after=%s", threadID, opts.Limit, opts.Order, opts.After)
 
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/threads/"+threadID+"/messages", nil)
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/threads/"+threadID+"/messages"+opts.query(), nil)
 	if err != nil {
 		log.Printf("[Assistant] ListMessages failed: create request err=%v", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -163,7 +328,7 @@ func (c *Client) ListMessages(threadID string) ([]Message, error) {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] ListMessages failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -181,66 +346,160 @@ func (c *Client) ListMessages(threadID string) ([]Message, error) {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	log.Printf("[Assistant] ListMessages completed thread_id=%s message_count=%d", threadID, len(listResp.Data))
-	return listResp.Data, nil
+	log.Printf("[Assistant] ListMessages completed thread_id=%s message_count=%d has_more=%v", threadID, len(listResp.Data), listResp.HasMore)
+	return &listResp, nil
 }
 
-// Run represents an OpenAI Run
-type Run struct {
-	ID          string `json:"id"`
-	Status      string `json:"status"`
-	AssistantID string `json:"assistant_id"`
-	ThreadID    string `json:"thread_id"`
+// IterateMessages yields the messages of a thread starting from opts, fetching pages as they are consumed
+// Iteration stops after yielding an error; breaking out of the loop stops further requests
+func (c *Client) IterateMessages(threadID string, opts ListMessagesOptions) iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		for {
+			page, err := c.ListMessages(threadID, opts)
+			if err != nil {
+				yield(Message{}, err)
+				return
+			}
+			for _, msg := range page.Data {
+				if !yield(msg, nil) {
+					return
+				}
+			}
+			if !page.HasMore || page.LastID == "" {
+				return
+			}
+			opts.After = page.LastID
+		}
+	}
 }
 
-// CreateRunRequest represents a request to create a run
-type CreateRunRequest struct {
-	AssistantID            string `json:"assistant_id"`
-	AdditionalInstructions string `json:"additional_instructions,omitempty"`
-}
+// listMessagesPageSize is the page size used when listing every message of a thread
+const listMessagesPageSize = 100
 
-// CreateRun creates a run to generate a response from an assistant
-func (c *Client) CreateRun(threadID, assistantID string) (*Run, error) {
-	log.Printf("[Assistant] CreateRun started thread_id=%s assistant_id=%s", threadID, assistantID)
+// ListAllMessages retrieves every message of a thread, newest first, following pagination
+func (c *Client) ListAllMessages(threadID string) ([]Message, error) {
+	log.Printf("[Assistant] ListAllMessages started thread_id=%s", threadID)
 
-	reqBody := CreateRunRequest{
-		AssistantID: assistantID,
+	var messages []Message
+	for msg, err := range c.IterateMessages(threadID, ListMessagesOptions{Limit: listMessagesPageSize}) {
+		if err != nil {
+			log.Printf("[Assistant] ListAllMessages failed thread_id=%s err=%v", threadID, err)
+			return nil, err
+		}
+		messages = append(messages, msg)
 	}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		log.Printf("[Assistant] CreateRun failed: marshal request err=%v", err)
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+	log.Printf("[Assistant] ListAllMessages completed thread_id=%s message_count=%d", threadID, len(messages))
+	return messages, nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, baseURL+"/threads/"+threadID+"/runs", bytes.NewReader(body))
+// DeleteMessage deletes a message from a thread
+func (c *Client) DeleteMessage(threadID, messageID string) error {
+	req, err := http.NewRequest(http.MethodDelete, baseURL+"/threads/"+threadID+"/messages/"+messageID, nil)
 	if err != nil {
-		log.Printf("[Assistant] CreateRun failed: create request err=%v", err)
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		log.Printf("[Assistant] CreateRun failed: send request err=%v", err)
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("[Assistant] CreateRun failed: API error status=%d thread_id=%s assistant_id=%s", resp.StatusCode, threadID, assistantID)
-		return nil, c.handleError(resp)
+		return c.handleError(resp)
 	}
 
-	var run Run
-	if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
-		log.Printf("[Assistant] CreateRun failed: decode response err=%v", err)
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	log.Printf("[Assistant] Message deleted thread_id=%s message_id=%s", threadID, messageID)
+	return nil
+}
+
+// Text returns the concatenated text content of a message
+func (m *Message) Text() string {
+	var text strings.Builder
+	for _, content := range m.Content {
+		if content.Text != nil {
+			text.WriteString(content.Text.Value)
+		}
 	}
+	return text.String()
+}
 
-	log.Printf("[Assistant] CreateRun completed run_id=%s status=%s", run.ID, run.Status)
-	return &run, nil
+// ErrRunTimeout is returned by WaitForRun when the run is still active after the timeout
+var ErrRunTimeout = errors.New("timeout waiting for run to complete")
+
+// Run represents an OpenAI Run
+type Run struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	AssistantID string `json:"assistant_id"`
+	ThreadID    string `json:"thread_id"`
+	// Model is the model the run uses
+	Model string `json:"model,omitempty"`
+	// CreatedAt is the Unix time the run was created
+	CreatedAt int64 `json:"created_at"`
+	// LastError is set when the run failed
+	LastError *RunError `json:"last_error,omitempty"`
+}
+
+// RunEndedError is returned by WaitForRun when the run ends without completing
+type RunEndedError struct {
+	Run *Run
+}
+
+func (e *RunEndedError) Error() string {
+	if e.Run.LastError != nil {
+		return fmt.Sprintf("run ended with status: %s (%s)", e.Run.Status, e.Run.LastError)
+	}
+	return "run ended with status: " + e.Run.Status
+}
+
+// Is matches a run that failed on a rate limit or an invalid prompt against the corresponding error class
+func (e *RunEndedError) Is(target error) bool {
+	if e.Run.LastError == nil {
+		return false
+	}
+	switch e.Run.LastError.Code {
+	case RunErrorRateLimitExceeded:
+		return target == ErrRateLimited
+	case RunErrorInvalidPrompt:
+		return target == ErrInvalidRequest
+	}
+	return false
+}
+
+// CreateRunRequest represents a request to create a run
+type CreateRunRequest struct {
+	AssistantID string `json:"assistant_id"`
+	// Instructions replaces the assistant's instructions for this run only; empty keeps them
+	Instructions           string `json:"instructions,omitempty"`
+	AdditionalInstructions string `json:"additional_instructions,omitempty"`
+	// TruncationStrategy limits the thread messages the run reads; nil reads the whole thread
+	TruncationStrategy *TruncationStrategy `json:"truncation_strategy,omitempty"`
+	// Stream asks for the run's events as they happen; set by StreamRun
+	Stream bool `json:"stream,omitempty"`
+}
+
+// TruncationStrategy controls how a thread is truncated before a run
+type TruncationStrategy struct {
+	Type         string `json:"type"`
+	LastMessages int    `json:"last_messages,omitempty"`
+}
+
+// LastMessages returns a strategy that passes only the n most recent thread messages to a run
+// Returns nil (the whole thread) when n is not positive
+func LastMessages(n int) *TruncationStrategy {
+	if n <= 0 {
+		return nil
+	}
+	return &TruncationStrategy{Type: "last_messages", LastMessages: n}
+}
+
+// CreateRun creates a run to generate a response from an assistant
+func (c *Client) CreateRun(threadID, assistantID string) (*Run, error) {
+	return c.CreateRunWithOptions(threadID, CreateRunRequest{AssistantID: assistantID})
 }
 
 // CreateRunWithContext creates a run with additional context/instructions
@@ -249,45 +508,55 @@ func (c *Client) CreateRunWithContext(threadID, assistantID, additionalInstructi
 	log.Printf("[Assistant] CreateRunWithContext started thread_id=%s assistant_id=%s context_length=%d additional_context=%q",
 		threadID, assistantID, len(additionalInstructions), additionalInstructions)
 
-	reqBody := CreateRunRequest{
+	return c.CreateRunWithOptions(threadID, CreateRunRequest{
 		AssistantID:            assistantID,
 		AdditionalInstructions: additionalInstructions,
+	})
+}
+
+// CreateRunWithOptions creates a run from a full request, e.g. with a truncation strategy
+func (c *Client) CreateRunWithOptions(threadID string, reqBody CreateRunRequest) (*Run, error) {
+	lastMessages := 0
+	if reqBody.TruncationStrategy != nil {
+		lastMessages = reqBody.TruncationStrategy.LastMessages
 	}
+	log.Printf("[Assistant] CreateRun started thread_id=%s assistant_id=%s context_length=%d last_messages=%d",
+		threadID, reqBody.AssistantID, len(reqBody.AdditionalInstructions), lastMessages)
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		log.Printf("[Assistant] CreateRunWithContext failed: marshal request err=%v", err)
+		log.Printf("[Assistant] CreateRun failed: marshal request err=%v", err)
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequest(http.MethodPost, baseURL+"/threads/"+threadID+"/runs", bytes.NewReader(body))
 	if err != nil {
-		log.Printf("[Assistant] CreateRunWithContext failed: create request err=%v", err)
+		log.Printf("[Assistant] CreateRun failed: create request err=%v", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		log.Printf("[Assistant] CreateRunWithContext failed: send request err=%v", err)
+		log.Printf("[Assistant] CreateRun failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("[Assistant] CreateRunWithContext failed: API error status=%d thread_id=%s assistant_id=%s",
-			resp.StatusCode, threadID, assistantID)
+		log.Printf("[Assistant] CreateRun failed: API error status=%d thread_id=%s assistant_id=%s",
+			resp.StatusCode, threadID, reqBody.AssistantID)
 		return nil, c.handleError(resp)
 	}
 
 	var run Run
 	if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
-		log.Printf("[Assistant] CreateRunWithContext failed: decode response err=%v", err)
+		log.Printf("[Assistant] CreateRun failed: decode response err=%v", err)
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	log.Printf("[Assistant] CreateRunWithContext completed run_id=%s status=%s", run.ID, run.Status)
+	log.Printf("[Assistant] CreateRun completed run_id=%s status=%s", run.ID, run.Status)
 	return &run, nil
 }
 
@@ -303,7 +572,7 @@ func (c *Client) GetRun(threadID, runID string) (*Run, error) {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] GetRun failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -325,10 +594,11 @@ func (c *Client) GetRun(threadID, runID string) (*Run, error) {
 	return &run, nil
 }
 
-// WaitForRun polls until the run is complete
+// WaitForRun polls until the run is complete, waiting longer between polls as the run goes on
 func (c *Client) WaitForRun(threadID, runID string, timeout time.Duration) (*Run, error) {
 	log.Printf("[Assistant] WaitForRun started thread_id=%s run_id=%s timeout=%v", threadID, runID, timeout)
 	deadline := time.Now().Add(timeout)
+	backoff := c.newPollBackoff()
 	pollCount := 0
 
 	for time.Now().Before(deadline) {
@@ -346,15 +616,15 @@ func (c *Client) WaitForRun(threadID, runID string, timeout time.Duration) (*Run
 			log.Printf("[Assistant] WaitForRun completed run_id=%s status=completed poll_count=%d", run.ID, pollCount)
 			return run, nil
 		case "failed", "cancelled", "expired":
-			log.Printf("[Assistant] WaitForRun failed: run ended status=%s run_id=%s", run.Status, run.ID)
-			return run, fmt.Errorf("run ended with status: %s", run.Status)
+			log.Printf("[Assistant] WaitForRun failed: run ended status=%s run_id=%s last_error=%v", run.Status, run.ID, run.LastError)
+			return run, &RunEndedError{Run: run}
 		}
 
-		time.Sleep(500 * time.Millisecond)
+		time.Sleep(backoff.next())
 	}
 
 	log.Printf("[Assistant] WaitForRun timeout run_id=%s poll_count=%d", runID, pollCount)
-	return nil, fmt.Errorf("timeout waiting for run to complete")
+	return nil, ErrRunTimeout
 }
 
 // CancelRun cancels a running run
@@ -369,7 +639,7 @@ func (c *Client) CancelRun(threadID, runID string) error {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] CancelRun failed: send request err=%v", err)
 		return fmt.Errorf("failed to send request: %w", err)
@@ -385,31 +655,35 @@ func (c *Client) CancelRun(threadID, runID string) error {
 	return nil
 }
 
+// latestMessagePageSize is the page size used when looking for the latest assistant message
+const latestMessagePageSize = 10
+
 // GetLatestAssistantMessage retrieves the most recent assistant message from a thread
-func (c *Client) GetLatestAssistantMessage(threadID string) (string, error) {
+// together with the citations annotated in its text
+func (c *Client) GetLatestAssistantMessage(threadID string) (*AssistantMessage, error) {
 	log.Printf("[Assistant] GetLatestAssistantMessage started thread_id=%s", threadID)
 
-	messages, err := c.ListMessages(threadID)
-	if err != nil {
-		log.Printf("[Assistant] GetLatestAssistantMessage failed: list messages err=%v", err)
-		return "", err
-	}
-
-	log.Printf("[Assistant] GetLatestAssistantMessage found %d messages", len(messages))
-
-	// Messages are returned in reverse chronological order
-	// Find the first assistant message
-	for _, msg := range messages {
+	// Walk the thread newest first and stop at the first assistant message,
+	// so usually only the first page is fetched
+	scanned := 0
+	opts := ListMessagesOptions{Limit: latestMessagePageSize, Order: MessageOrderDesc}
+	for msg, err := range c.IterateMessages(threadID, opts) {
+		if err != nil {
+			log.Printf("[Assistant] GetLatestAssistantMessage failed: list messages err=%v", err)
+			return nil, err
+		}
+		scanned++
 		if msg.Role == "assistant" && len(msg.Content) > 0 {
 			for _, content := range msg.Content {
 				if content.Type == "text" && content.Text != nil {
-					log.Printf("[Assistant] GetLatestAssistantMessage found message_id=%s", msg.ID)
-					return content.Text.Value, nil
+					citations := content.Text.citations()
+					log.Printf("[Assistant] GetLatestAssistantMessage found message_id=%s citation_count=%d", msg.ID, len(citations))
+					return &AssistantMessage{ID: msg.ID, Content: content.Text.Value, Citations: citations}, nil
 				}
 			}
 		}
 	}
 
-	log.Printf("[Assistant] GetLatestAssistantMessage: no assistant message found")
-	return "", fmt.Errorf("no assistant message found in thread")
+	log.Printf("[Assistant] GetLatestAssistantMessage: no assistant message found scanned=%d", scanned)
+	return nil, fmt.Errorf("no assistant message found in thread")
 }
@@ -90,7 +90,7 @@ func (c *Client) ListRuns(threadID string) ([]Run, error) {
 
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.Printf("[Assistant] ListRuns failed: send request err=%v", err)
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -131,6 +131,7 @@ func (c *Client) HasActiveRun(threadID string) (bool, *Run, error) {
 func (c *Client) WaitForActiveRunsToComplete(threadID string, timeout time.Duration) error {
 	log.Printf("[Assistant] WaitForActiveRunsToComplete started thread_id=%s timeout=%v", threadID, timeout)
 	deadline := time.Now().Add(timeout)
+	backoff := c.newPollBackoff()
 
 	for time.Now().Before(deadline) {
 		hasActive, activeRun, err := c.HasActiveRun(threadID)
@@ -144,7 +145,7 @@ func (c *Client) WaitForActiveRunsToComplete(threadID string, timeout time.Durat
 		}
 
 		log.Printf("[Assistant] WaitForActiveRunsToComplete: waiting for run_id=%s status=%s", activeRun.ID, activeRun.Status)
-		time.Sleep(500 * time.Millisecond)
+		time.Sleep(backoff.next())
 	}
 
 	return fmt.Errorf("timeout waiting for active runs to complete on thread %s", threadID)
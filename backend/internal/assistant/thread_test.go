@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -312,6 +313,42 @@ func TestCreateRunWithContext_Success(t *testing.T) {
 	}
 }
 
+func TestCreateRunWithOptions_Truncation(t *testing.T) {
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"id": "run_123", "status": "queued"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	if _, err := client.CreateRunWithOptions("thread_123", CreateRunRequest{
+		AssistantID:        "asst_123",
+		TruncationStrategy: LastMessages(20),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	strategy, _ := received["truncation_strategy"].(map[string]any)
+	if strategy["type"] != "last_messages" || strategy["last_messages"] != float64(20) {
+		t.Errorf("expected a last_messages strategy of 20, got %v", received["truncation_strategy"])
+	}
+
+	// Without a limit the whole thread is read, so no strategy is sent
+	received = nil
+	if _, err := client.CreateRunWithOptions("thread_123", CreateRunRequest{
+		AssistantID:        "asst_123",
+		TruncationStrategy: LastMessages(0),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := received["truncation_strategy"]; ok {
+		t.Errorf("expected no truncation_strategy, got %v", received["truncation_strategy"])
+	}
+}
+
 func (tc *testableThreadClient) CreateRunWithContext(threadID, assistantID, additionalInstructions string) (*Run, error) {
 	reqBody := CreateRunRequest{
 		AssistantID:            assistantID,
@@ -341,3 +378,140 @@ func (tc *testableThreadClient) CreateRunWithContext(threadID, assistantID, addi
 	}
 	return &run, nil
 }
+
+func TestGetLatestAssistantMessage_Citations(t *testing.T) {
+	fileLookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/threads/thread_123/messages":
+			w.Write([]byte(`{"data": [{
+				"id": "msg_2",
+				"role": "assistant",
+				"content": [{"type": "text", "text": {
+					"value": "Sales grew【4:0†source】 and costs fell【4:1†source】",
+					"annotations": [
+						{"type": "file_citation", "text": "【4:0†source】", "start_index": 10, "end_index": 22,
+							"file_citation": {"file_id": "file_report"}},
+						{"type": "file_citation", "text": "【4:1†source】", "start_index": 37, "end_index": 49,
+							"file_citation": {"file_id": "file_report", "quote": "costs fell"}}
+					]
+				}}]
+			}]}`))
+		case "/v1/files/file_report":
+			fileLookups++
+			w.Write([]byte(`{"id": "file_report", "filename": "report.pdf"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	msg, err := client.GetLatestAssistantMessage("thread_123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.ID != "msg_2" || !strings.HasPrefix(msg.Content, "Sales grew") {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if len(msg.Citations) != 2 {
+		t.Fatalf("expected 2 citations, got %+v", msg.Citations)
+	}
+	if c := msg.Citations[1]; c.Type != AnnotationFileCitation || c.Text != "【4:1†source】" ||
+		c.FileID != "file_report" || c.Quote != "costs fell" || c.StartIndex != 37 || c.EndIndex != 49 {
+		t.Errorf("unexpected citation: %+v", c)
+	}
+
+	client.ResolveCitationFilenames(msg.Citations)
+	for _, c := range msg.Citations {
+		if c.Filename != "report.pdf" {
+			t.Errorf("expected filename report.pdf, got %q", c.Filename)
+		}
+	}
+	if fileLookups != 1 {
+		t.Errorf("expected the cited file to be looked up once, got %d lookups", fileLookups)
+	}
+}
+
+func TestIterateMessages_Pagination(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch r.URL.Query().Get("after") {
+		case "":
+			json.NewEncoder(w).Encode(ListMessagesResponse{
+				Data:    []Message{{ID: "msg_1", Role: "user"}, {ID: "msg_2", Role: "user"}},
+				HasMore: true, LastID: "msg_2",
+			})
+		case "msg_2":
+			json.NewEncoder(w).Encode(ListMessagesResponse{
+				Data:    []Message{{ID: "msg_3", Role: "user"}},
+				HasMore: false, LastID: "msg_3",
+			})
+		default:
+			t.Errorf("unexpected after cursor %q", r.URL.Query().Get("after"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	var ids []string
+	for msg, err := range client.IterateMessages("thread_123", ListMessagesOptions{Limit: 2, Order: MessageOrderAsc}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, msg.ID)
+	}
+	if strings.Join(ids, ",") != "msg_1,msg_2,msg_3" {
+		t.Errorf("expected messages across both pages, got %v", ids)
+	}
+	if len(queries) != 2 || queries[0] != "limit=2&order=asc" || queries[1] != "after=msg_2&limit=2&order=asc" {
+		t.Errorf("unexpected queries %v", queries)
+	}
+
+	// Breaking out of the loop stops fetching further pages
+	queries = nil
+	for range client.IterateMessages("thread_123", ListMessagesOptions{Limit: 2}) {
+		break
+	}
+	if len(queries) != 1 {
+		t.Errorf("expected a single request after breaking early, got %v", queries)
+	}
+}
+
+func TestGetLatestAssistantMessage_FetchesFirstPageOnly(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if q := r.URL.Query(); q.Get("order") != MessageOrderDesc || q.Get("limit") != "10" {
+			t.Errorf("expected a small newest-first page, got %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"data": [
+			{"id": "msg_3", "role": "user", "content": [{"type": "text", "text": {"value": "thanks"}}]},
+			{"id": "msg_2", "role": "assistant", "content": [{"type": "text", "text": {"value": "Hello"}}]}
+		], "has_more": true, "last_id": "msg_2"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	msg, err := client.GetLatestAssistantMessage("thread_123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.ID != "msg_2" || msg.Content != "Hello" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if requests != 1 {
+		t.Errorf("expected only the first page to be fetched, got %d requests", requests)
+	}
+}
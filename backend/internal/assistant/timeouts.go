@@ -0,0 +1,95 @@
+package assistant
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Default waits for runs, used for the fields of Timeouts left at zero
+const (
+	DefaultRunTimeout       = 30 * time.Second
+	DefaultActiveRunTimeout = 30 * time.Second
+	DefaultPollInterval     = 500 * time.Millisecond
+	DefaultMaxPollInterval  = time.Second
+)
+
+const (
+	// pollBackoffFactor is how much the poll interval grows after each poll
+	pollBackoffFactor = 1.5
+	// pollJitter is the fraction by which each poll interval is randomly shortened or lengthened
+	pollJitter = 0.1
+)
+
+// Timeouts configures how long callers wait for runs and how often runs are polled
+// Fields left at zero use the defaults
+type Timeouts struct {
+	// Run is how long to wait for a new run to finish
+	Run time.Duration
+	// ActiveRun is how long to wait for the active runs of a thread before adding to it
+	ActiveRun time.Duration
+	// PollInterval is the interval before the second poll; it then grows up to MaxPollInterval
+	PollInterval time.Duration
+	// MaxPollInterval caps the poll interval
+	MaxPollInterval time.Duration
+}
+
+// withDefaults fills the zero fields with the defaults and keeps MaxPollInterval at least PollInterval
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Run <= 0 {
+		t.Run = DefaultRunTimeout
+	}
+	if t.ActiveRun <= 0 {
+		t.ActiveRun = DefaultActiveRunTimeout
+	}
+	if t.PollInterval <= 0 {
+		t.PollInterval = DefaultPollInterval
+	}
+	if t.MaxPollInterval <= 0 {
+		t.MaxPollInterval = DefaultMaxPollInterval
+	}
+	if t.MaxPollInterval < t.PollInterval {
+		t.MaxPollInterval = t.PollInterval
+	}
+	return t
+}
+
+// WithTimeouts sets how long the client's callers wait for runs and how often runs are polled
+func WithTimeouts(timeouts Timeouts) ClientOption {
+	return func(c *Client) {
+		c.timeouts = timeouts.withDefaults()
+	}
+}
+
+// RunTimeout returns how long to wait for a new run to finish
+func (c *Client) RunTimeout() time.Duration {
+	return c.timeouts.Run
+}
+
+// ActiveRunTimeout returns how long to wait for the active runs of a thread before adding to it
+func (c *Client) ActiveRunTimeout() time.Duration {
+	return c.timeouts.ActiveRun
+}
+
+// pollBackoff yields growing, jittered intervals between polls of a run
+// Long runs are polled less often, while short runs are still noticed quickly
+type pollBackoff struct {
+	interval time.Duration
+	max      time.Duration
+}
+
+// newPollBackoff starts a backoff at the client's poll interval
+func (c *Client) newPollBackoff() *pollBackoff {
+	return &pollBackoff{interval: c.timeouts.PollInterval, max: c.timeouts.MaxPollInterval}
+}
+
+// next returns how long to wait before the next poll and grows the interval
+func (b *pollBackoff) next() time.Duration {
+	jitter := 1 + pollJitter*(2*rand.Float64()-1)
+	wait := time.Duration(float64(b.interval) * jitter)
+
+	b.interval = time.Duration(float64(b.interval) * pollBackoffFactor)
+	if b.interval > b.max {
+		b.interval = b.max
+	}
+	return wait
+}
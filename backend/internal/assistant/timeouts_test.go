@@ -0,0 +1,54 @@
+package assistant
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithTimeouts(t *testing.T) {
+	client := NewClient("test-api-key")
+	if client.RunTimeout() != DefaultRunTimeout || client.ActiveRunTimeout() != DefaultActiveRunTimeout {
+		t.Errorf("expected default timeouts, got %+v", client.timeouts)
+	}
+
+	client = NewClient("test-api-key", WithTimeouts(Timeouts{Run: time.Minute, PollInterval: 2 * time.Second}))
+	expected := Timeouts{
+		Run:             time.Minute,
+		ActiveRun:       DefaultActiveRunTimeout,
+		PollInterval:    2 * time.Second,
+		MaxPollInterval: 2 * time.Second,
+	}
+	if client.timeouts != expected {
+		t.Errorf("expected %+v, got %+v", expected, client.timeouts)
+	}
+}
+
+func TestPollBackoff(t *testing.T) {
+	client := NewClient("test-api-key", WithTimeouts(Timeouts{PollInterval: 100 * time.Millisecond, MaxPollInterval: 300 * time.Millisecond}))
+	backoff := client.newPollBackoff()
+
+	// Intervals grow by half each poll until the cap, each within the jitter
+	for i, base := range []time.Duration{100, 150, 225, 300, 300} {
+		base *= time.Millisecond
+		wait := backoff.next()
+		low := time.Duration(float64(base) * (1 - pollJitter))
+		high := time.Duration(float64(base) * (1 + pollJitter))
+		if wait < low || wait > high {
+			t.Errorf("poll %d: expected a wait between %v and %v, got %v", i+1, low, high, wait)
+		}
+	}
+}
+
+func TestPollBackoff_HalvesPollsOfLongRuns(t *testing.T) {
+	client := NewClient("test-api-key")
+	backoff := client.newPollBackoff()
+
+	// A 30 second run was polled every 500ms before, 60 times
+	polls := 0
+	for elapsed := time.Duration(0); elapsed < 30*time.Second; polls++ {
+		elapsed += backoff.next()
+	}
+	if polls > 35 {
+		t.Errorf("expected about half of the 60 fixed-interval polls, got %d", polls)
+	}
+}
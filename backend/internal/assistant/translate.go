@@ -0,0 +1,33 @@
+package assistant
+
+import (
+	"errors"
+	"strings"
+)
+
+// translationInstructions tells the model to return only the translation, keeping the parts
+// other avatars and the application parse intact
+const translationInstructions = `You are a translator in a group chat between a user and AI avatars.
+Translate the message from the user into %s.
+- Reply with the translation only, without notes or quotes
+- Keep @mentions, names, URLs, code and markdown formatting unchanged
+- If the message is already in %s, reply with it unchanged`
+
+// Translate translates text into the given language with the judgment model
+// The language is free text, such as "English" or "ja"
+func (c *Client) Translate(text, language string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+
+	system := strings.ReplaceAll(translationInstructions, "%s", language)
+	translated, err := c.ChatCompletion(c.judgmentModel, system, text)
+	if err != nil {
+		return "", err
+	}
+	translated = strings.TrimSpace(translated)
+	if translated == "" {
+		return "", errors.New("empty translation")
+	}
+	return translated, nil
+}
@@ -0,0 +1,46 @@
+package assistant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	var request struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"choices": [{"message": {"content": "  Hello @Alice  "}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", WithJudgmentModel("gpt-4o-mini"), WithHTTPClient(&http.Client{
+		Transport: &redirectTransport{host: strings.TrimPrefix(server.URL, "http://")},
+	}))
+
+	got, err := client.Translate("こんにちは @Alice", "English")
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if got != "Hello @Alice" {
+		t.Errorf("expected the trimmed translation, got %q", got)
+	}
+	if request.Model != "gpt-4o-mini" || len(request.Messages) != 2 ||
+		!strings.Contains(request.Messages[0].Content, "into English") || request.Messages[1].Content != "こんにちは @Alice" {
+		t.Errorf("unexpected request: %+v", request)
+	}
+
+	// Blank text is not sent
+	request.Model = ""
+	if got, err := client.Translate("  ", "English"); err != nil || got != "  " || request.Model != "" {
+		t.Errorf("expected blank text to be returned as is, got %q %v", got, err)
+	}
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,6 +15,19 @@ import (
 // OpenAIConfig holds OpenAI API configuration
 type OpenAIConfig struct {
 	APIKey string `yaml:"api_key"`
+	// Organization and Project bill the workspace's usage when a conversation does not set its own;
+	// empty values use the API key's defaults
+	Organization string `yaml:"organization"`
+	Project      string `yaml:"project"`
+}
+
+// SMTPConfig holds SMTP configuration for email notifications
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
 }
 
 // Config holds all application configuration
@@ -50,6 +68,14 @@ func Load() (*Config, error) {
 	}
 	cfg.OpenAI = *openaiCfg
 
+	// OPENAI_ORGANIZATION and OPENAI_PROJECT override the workspace defaults in openai.yaml
+	if v := os.Getenv("OPENAI_ORGANIZATION"); v != "" {
+		cfg.OpenAI.Organization = v
+	}
+	if v := os.Getenv("OPENAI_PROJECT"); v != "" {
+		cfg.OpenAI.Project = v
+	}
+
 	return cfg, nil
 }
 
@@ -67,3 +93,243 @@ func loadOpenAIConfig(path string) (*OpenAIConfig, error) {
 
 	return &cfg, nil
 }
+
+// OpenAITimeouts holds how long the server waits for OpenAI runs and how often it polls them
+// A zero field means the value is not configured and the client default applies
+type OpenAITimeouts struct {
+	RunTimeout       time.Duration
+	ActiveRunTimeout time.Duration
+	PollInterval     time.Duration
+	MaxPollInterval  time.Duration
+}
+
+// durationSetting is an environment variable holding a duration and the range it must fall in
+type durationSetting struct {
+	env      string
+	min, max time.Duration
+	target   *time.Duration
+}
+
+// LoadOpenAITimeouts reads the OpenAI run timeouts from the environment:
+//   - OPENAI_RUN_TIMEOUT: how long to wait for a run to finish (5s to 10m)
+//   - OPENAI_ACTIVE_RUN_TIMEOUT: how long to wait for the active runs of a thread (5s to 10m)
+//   - OPENAI_POLL_INTERVAL: the first interval between run polls (100ms to 5s)
+//   - OPENAI_MAX_POLL_INTERVAL: the longest interval between run polls (100ms to 30s, at least the poll interval)
+//
+// Values that are invalid or out of range are left unset and reported in the returned error
+func LoadOpenAITimeouts() (OpenAITimeouts, error) {
+	var t OpenAITimeouts
+	settings := []durationSetting{
+		{"OPENAI_RUN_TIMEOUT", 5 * time.Second, 10 * time.Minute, &t.RunTimeout},
+		{"OPENAI_ACTIVE_RUN_TIMEOUT", 5 * time.Second, 10 * time.Minute, &t.ActiveRunTimeout},
+		{"OPENAI_POLL_INTERVAL", 100 * time.Millisecond, 5 * time.Second, &t.PollInterval},
+		{"OPENAI_MAX_POLL_INTERVAL", 100 * time.Millisecond, 30 * time.Second, &t.MaxPollInterval},
+	}
+
+	var errs []error
+	for _, setting := range settings {
+		value := os.Getenv(setting.env)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s=%q: %w", setting.env, value, err))
+			continue
+		}
+		if d < setting.min || d > setting.max {
+			errs = append(errs, fmt.Errorf("%s=%v is out of range (%v to %v)", setting.env, d, setting.min, setting.max))
+			continue
+		}
+		*setting.target = d
+	}
+
+	if t.PollInterval > 0 && t.MaxPollInterval > 0 && t.MaxPollInterval < t.PollInterval {
+		errs = append(errs, fmt.Errorf("OPENAI_MAX_POLL_INTERVAL=%v is shorter than OPENAI_POLL_INTERVAL=%v",
+			t.MaxPollInterval, t.PollInterval))
+		t.MaxPollInterval = 0
+	}
+
+	return t, errors.Join(errs...)
+}
+
+// LoadSMTPConfig loads SMTP configuration from {settingsDir}/secrets/smtp.yaml
+// Port defaults to 587 when omitted
+func LoadSMTPConfig(settingsDir string) (*SMTPConfig, error) {
+	data, err := os.ReadFile(filepath.Join(settingsDir, "secrets", "smtp.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg SMTPConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, fmt.Errorf("smtp.yaml requires host and from")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+
+	return &cfg, nil
+}
+
+// RedactionConfig holds the terms redacted from user messages
+type RedactionConfig struct {
+	Terms []string `yaml:"terms"`
+}
+
+// LoadRedactionTerms loads the terms to redact from {settingsDir}/redaction.yaml
+// Returns an error wrapping os.ErrNotExist when the file does not exist
+func LoadRedactionTerms(settingsDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(settingsDir, "redaction.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg RedactionConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg.Terms, nil
+}
+
+// SafetyConfig holds the operator's safety preamble for assistants and judgment prompts
+type SafetyConfig struct {
+	Preamble string `yaml:"preamble"`
+}
+
+// LoadSafetyPreamble loads the safety preamble from {settingsDir}/safety.yaml
+// Returns an error wrapping os.ErrNotExist when the file does not exist
+func LoadSafetyPreamble(settingsDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(settingsDir, "safety.yaml"))
+	if err != nil {
+		return "", err
+	}
+
+	var cfg SafetyConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(cfg.Preamble), nil
+}
+
+// LoadDBEncryptionKey returns the SQLCipher key for the database, or "" if encryption is off
+// The key is read from the first of these that is set:
+//   - DB_ENCRYPTION_KEY: the key itself
+//   - DB_ENCRYPTION_KEY_FILE: a file containing the key (e.g. a mounted secret)
+//   - DB_ENCRYPTION_KEY_COMMAND: a shell command printing the key (e.g. a KMS decrypt call)
+func LoadDBEncryptionKey() (string, error) {
+	if key := os.Getenv("DB_ENCRYPTION_KEY"); key != "" {
+		return key, nil
+	}
+
+	if path := os.Getenv("DB_ENCRYPTION_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read DB_ENCRYPTION_KEY_FILE: %w", err)
+		}
+		return nonEmptyKey(string(data), "DB_ENCRYPTION_KEY_FILE")
+	}
+
+	if command := os.Getenv("DB_ENCRYPTION_KEY_COMMAND"); command != "" {
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			return "", fmt.Errorf("DB_ENCRYPTION_KEY_COMMAND failed: %w", err)
+		}
+		return nonEmptyKey(string(out), "DB_ENCRYPTION_KEY_COMMAND")
+	}
+
+	return "", nil
+}
+
+// nonEmptyKey trims surrounding whitespace and rejects an empty key
+func nonEmptyKey(key, source string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("%s produced an empty key", source)
+	}
+	return key, nil
+}
+
+// TLSMode is how the server terminates TLS
+type TLSMode string
+
+const (
+	// TLSModeOff serves plain HTTP
+	TLSModeOff TLSMode = "off"
+	// TLSModeManual serves HTTPS with a certificate and key read from files
+	TLSModeManual TLSMode = "manual"
+	// TLSModeAutocert serves HTTPS with certificates obtained from Let's Encrypt
+	TLSModeAutocert TLSMode = "autocert"
+)
+
+// DefaultAutocertCacheDir is where Let's Encrypt certificates are stored when TLS_AUTOCERT_CACHE_DIR is not set
+const DefaultAutocertCacheDir = "data/autocert"
+
+// TLSConfig holds how the server terminates TLS
+type TLSConfig struct {
+	Mode     TLSMode
+	CertFile string
+	KeyFile  string
+	// AutocertHosts are the only host names certificates are requested for
+	AutocertHosts    []string
+	AutocertCacheDir string
+	AutocertEmail    string
+	// RedirectAddr is the address of the plain HTTP listener redirecting to HTTPS; empty disables it
+	RedirectAddr string
+}
+
+// LoadTLSConfig reads the TLS settings from the environment:
+//   - TLS_CERT_FILE and TLS_KEY_FILE: serve HTTPS with this certificate and key
+//   - TLS_AUTOCERT_HOSTS: comma-separated host names to obtain Let's Encrypt certificates for
+//   - TLS_AUTOCERT_CACHE_DIR: where obtained certificates are stored (default data/autocert)
+//   - TLS_AUTOCERT_EMAIL: contact address for the Let's Encrypt account (optional)
+//   - TLS_REDIRECT_ADDR: plain HTTP address redirecting to HTTPS (default :80, "off" disables it)
+//
+// Certificate files and autocert are mutually exclusive. Without either, TLS is off
+func LoadTLSConfig() (TLSConfig, error) {
+	cfg := TLSConfig{
+		Mode:             TLSModeOff,
+		CertFile:         os.Getenv("TLS_CERT_FILE"),
+		KeyFile:          os.Getenv("TLS_KEY_FILE"),
+		AutocertCacheDir: os.Getenv("TLS_AUTOCERT_CACHE_DIR"),
+		AutocertEmail:    os.Getenv("TLS_AUTOCERT_EMAIL"),
+	}
+	for _, host := range strings.Split(os.Getenv("TLS_AUTOCERT_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			cfg.AutocertHosts = append(cfg.AutocertHosts, strings.ToLower(host))
+		}
+	}
+
+	manual := cfg.CertFile != "" || cfg.KeyFile != ""
+	switch {
+	case manual && len(cfg.AutocertHosts) > 0:
+		return TLSConfig{Mode: TLSModeOff}, fmt.Errorf("TLS_CERT_FILE/TLS_KEY_FILE and TLS_AUTOCERT_HOSTS cannot be used together")
+	case manual && (cfg.CertFile == "" || cfg.KeyFile == ""):
+		return TLSConfig{Mode: TLSModeOff}, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	case manual:
+		cfg.Mode = TLSModeManual
+	case len(cfg.AutocertHosts) > 0:
+		cfg.Mode = TLSModeAutocert
+		if cfg.AutocertCacheDir == "" {
+			cfg.AutocertCacheDir = DefaultAutocertCacheDir
+		}
+	default:
+		return TLSConfig{Mode: TLSModeOff}, nil
+	}
+
+	switch addr := os.Getenv("TLS_REDIRECT_ADDR"); addr {
+	case "":
+		cfg.RedirectAddr = ":80"
+	case "off":
+	default:
+		cfg.RedirectAddr = addr
+	}
+
+	return cfg, nil
+}
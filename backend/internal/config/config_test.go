@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadOpenAIConfig_ValidFile(t *testing.T) {
@@ -31,6 +34,38 @@ func TestLoadOpenAIConfig_ValidFile(t *testing.T) {
 	}
 }
 
+func TestLoad_OpenAIProject(t *testing.T) {
+	tmpDir := t.TempDir()
+	secretsDir := filepath.Join(tmpDir, "secrets")
+	if err := os.MkdirAll(secretsDir, 0755); err != nil {
+		t.Fatalf("failed to create secrets dir: %v", err)
+	}
+
+	content := []byte("api_key: \"test-key\"\norganization: \"org-file\"\nproject: \"proj_file\"\n")
+	if err := os.WriteFile(filepath.Join(secretsDir, "openai.yaml"), content, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("SETTINGS_DIR", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.OpenAI.Organization != "org-file" || cfg.OpenAI.Project != "proj_file" {
+		t.Errorf("expected organization and project from the file, got %q and %q", cfg.OpenAI.Organization, cfg.OpenAI.Project)
+	}
+
+	// Environment variables override the file
+	t.Setenv("OPENAI_PROJECT", "proj_env")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.OpenAI.Organization != "org-file" || cfg.OpenAI.Project != "proj_env" {
+		t.Errorf("expected OPENAI_PROJECT to override the project, got %q and %q", cfg.OpenAI.Organization, cfg.OpenAI.Project)
+	}
+}
+
 func TestLoadOpenAIConfig_FileNotFound(t *testing.T) {
 	_, err := loadOpenAIConfig("/nonexistent/path/openai.yaml")
 	if err == nil {
@@ -81,3 +116,215 @@ func TestLoad_WithEnvVars(t *testing.T) {
 	}
 }
 
+
+func TestLoadSMTPConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	secretsDir := filepath.Join(tmpDir, "secrets")
+	if err := os.MkdirAll(secretsDir, 0755); err != nil {
+		t.Fatalf("failed to create secrets dir: %v", err)
+	}
+
+	content := []byte("host: smtp.example.com\nfrom: chat@example.com\nusername: user\npassword: pass\n")
+	if err := os.WriteFile(filepath.Join(secretsDir, "smtp.yaml"), content, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadSMTPConfig(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.Host != "smtp.example.com" || cfg.From != "chat@example.com" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.Port != 587 {
+		t.Errorf("expected default port 587, got %d", cfg.Port)
+	}
+}
+
+func TestLoadSMTPConfig_MissingHost(t *testing.T) {
+	tmpDir := t.TempDir()
+	secretsDir := filepath.Join(tmpDir, "secrets")
+	if err := os.MkdirAll(secretsDir, 0755); err != nil {
+		t.Fatalf("failed to create secrets dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(secretsDir, "smtp.yaml"), []byte("from: chat@example.com\n"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	if _, err := LoadSMTPConfig(tmpDir); err == nil {
+		t.Error("expected error when host is missing")
+	}
+}
+
+func TestLoadRedactionTerms(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if _, err := LoadRedactionTerms(tmpDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist without a file, got %v", err)
+	}
+
+	content := []byte("terms:\n  - Project Falcon\n  - 社外秘\n")
+	if err := os.WriteFile(filepath.Join(tmpDir, "redaction.yaml"), content, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	terms, err := LoadRedactionTerms(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to load terms: %v", err)
+	}
+	if len(terms) != 2 || terms[0] != "Project Falcon" || terms[1] != "社外秘" {
+		t.Errorf("unexpected terms: %v", terms)
+	}
+}
+
+func TestLoadSafetyPreamble(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if _, err := LoadSafetyPreamble(tmpDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist without a file, got %v", err)
+	}
+
+	content := []byte("preamble: |\n  Never share personal data.\n  Refuse harmful requests.\n")
+	if err := os.WriteFile(filepath.Join(tmpDir, "safety.yaml"), content, 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	preamble, err := LoadSafetyPreamble(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to load preamble: %v", err)
+	}
+	if preamble != "Never share personal data.\nRefuse harmful requests." {
+		t.Errorf("unexpected preamble: %q", preamble)
+	}
+}
+
+func TestLoadOpenAITimeouts(t *testing.T) {
+	t.Setenv("OPENAI_RUN_TIMEOUT", "2m")
+	t.Setenv("OPENAI_ACTIVE_RUN_TIMEOUT", "")
+	t.Setenv("OPENAI_POLL_INTERVAL", "250ms")
+	t.Setenv("OPENAI_MAX_POLL_INTERVAL", "3s")
+
+	timeouts, err := LoadOpenAITimeouts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := OpenAITimeouts{RunTimeout: 2 * time.Minute, PollInterval: 250 * time.Millisecond, MaxPollInterval: 3 * time.Second}
+	if timeouts != expected {
+		t.Errorf("expected %+v, got %+v", expected, timeouts)
+	}
+}
+
+func TestLoadOpenAITimeouts_InvalidValues(t *testing.T) {
+	t.Setenv("OPENAI_RUN_TIMEOUT", "1s")
+	t.Setenv("OPENAI_ACTIVE_RUN_TIMEOUT", "soon")
+	t.Setenv("OPENAI_POLL_INTERVAL", "2s")
+	t.Setenv("OPENAI_MAX_POLL_INTERVAL", "1s")
+
+	timeouts, err := LoadOpenAITimeouts()
+	if err == nil {
+		t.Fatal("expected an error for invalid values")
+	}
+	for _, env := range []string{"OPENAI_RUN_TIMEOUT", "OPENAI_ACTIVE_RUN_TIMEOUT", "OPENAI_MAX_POLL_INTERVAL"} {
+		if !strings.Contains(err.Error(), env) {
+			t.Errorf("expected the error to mention %s, got %v", env, err)
+		}
+	}
+
+	// Only the valid poll interval is kept
+	if expected := (OpenAITimeouts{PollInterval: 2 * time.Second}); timeouts != expected {
+		t.Errorf("expected %+v, got %+v", expected, timeouts)
+	}
+}
+
+func TestLoadDBEncryptionKey(t *testing.T) {
+	t.Setenv("DB_ENCRYPTION_KEY", "")
+	t.Setenv("DB_ENCRYPTION_KEY_FILE", "")
+	t.Setenv("DB_ENCRYPTION_KEY_COMMAND", "")
+
+	key, err := LoadDBEncryptionKey()
+	if err != nil || key != "" {
+		t.Errorf("expected no key when nothing is configured, got %q err=%v", key, err)
+	}
+
+	t.Setenv("DB_ENCRYPTION_KEY_COMMAND", "echo from-command")
+	if key, _ := LoadDBEncryptionKey(); key != "from-command" {
+		t.Errorf("expected key from command, got %q", key)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "db.key")
+	if err := os.WriteFile(keyFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	t.Setenv("DB_ENCRYPTION_KEY_FILE", keyFile)
+	if key, _ := LoadDBEncryptionKey(); key != "from-file" {
+		t.Errorf("expected key from file, got %q", key)
+	}
+
+	t.Setenv("DB_ENCRYPTION_KEY", "from-env")
+	if key, _ := LoadDBEncryptionKey(); key != "from-env" {
+		t.Errorf("expected key from environment, got %q", key)
+	}
+}
+
+func TestLoadDBEncryptionKey_EmptyCommandOutput(t *testing.T) {
+	t.Setenv("DB_ENCRYPTION_KEY", "")
+	t.Setenv("DB_ENCRYPTION_KEY_FILE", "")
+	t.Setenv("DB_ENCRYPTION_KEY_COMMAND", "true")
+
+	if _, err := LoadDBEncryptionKey(); err == nil {
+		t.Error("expected error for empty key")
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	for _, env := range []string{"TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_AUTOCERT_HOSTS", "TLS_AUTOCERT_CACHE_DIR", "TLS_AUTOCERT_EMAIL", "TLS_REDIRECT_ADDR"} {
+		t.Setenv(env, "")
+	}
+
+	cfg, err := LoadTLSConfig()
+	if err != nil || cfg.Mode != TLSModeOff {
+		t.Fatalf("expected TLS to be off by default, got %+v err=%v", cfg, err)
+	}
+
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	t.Setenv("TLS_KEY_FILE", "key.pem")
+	cfg, err = LoadTLSConfig()
+	if err != nil || cfg.Mode != TLSModeManual || cfg.RedirectAddr != ":80" {
+		t.Errorf("expected manual TLS with the default redirect, got %+v err=%v", cfg, err)
+	}
+
+	t.Setenv("TLS_CERT_FILE", "")
+	t.Setenv("TLS_KEY_FILE", "")
+	t.Setenv("TLS_AUTOCERT_HOSTS", " Chat.example.com, ,demo.example.com")
+	t.Setenv("TLS_REDIRECT_ADDR", "off")
+	cfg, err = LoadTLSConfig()
+	if err != nil || cfg.Mode != TLSModeAutocert {
+		t.Fatalf("expected autocert, got %+v err=%v", cfg, err)
+	}
+	if strings.Join(cfg.AutocertHosts, ",") != "chat.example.com,demo.example.com" {
+		t.Errorf("unexpected hosts %v", cfg.AutocertHosts)
+	}
+	if cfg.AutocertCacheDir != DefaultAutocertCacheDir || cfg.RedirectAddr != "" {
+		t.Errorf("expected the default cache dir and no redirect, got %+v", cfg)
+	}
+}
+
+func TestLoadTLSConfig_InvalidCombinations(t *testing.T) {
+	tests := map[string]map[string]string{
+		"cert without key":  {"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "", "TLS_AUTOCERT_HOSTS": ""},
+		"cert and autocert": {"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_AUTOCERT_HOSTS": "chat.example.com"},
+	}
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			cfg, err := LoadTLSConfig()
+			if err == nil || cfg.Mode != TLSModeOff {
+				t.Errorf("expected an error and TLS off, got %+v err=%v", cfg, err)
+			}
+		})
+	}
+}
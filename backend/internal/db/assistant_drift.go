@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// RecordAssistantDrift records that an avatar's assistant has instructions other than its prompt
+// Returns true if the drift is new or the instructions differ from the ones recorded before
+func (d *DB) RecordAssistantDrift(avatarID int64, assistantID, instructions string) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		var recordedAssistantID, recordedInstructions string
+		err := d.db.QueryRow(
+			`SELECT assistant_id, instructions FROM assistant_drift WHERE avatar_id = ?`, avatarID,
+		).Scan(&recordedAssistantID, &recordedInstructions)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+
+		if err == nil && recordedAssistantID == assistantID && recordedInstructions == instructions {
+			_, err := d.db.Exec(`UPDATE assistant_drift SET checked_at = CURRENT_TIMESTAMP WHERE avatar_id = ?`, avatarID)
+			return false, err
+		}
+
+		_, err = d.db.Exec(
+			`INSERT OR REPLACE INTO assistant_drift (avatar_id, assistant_id, instructions, detected_at, checked_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+			avatarID, assistantID, instructions,
+		)
+		return err == nil, err
+	})
+}
+
+// ClearAssistantDrift removes the drift recorded for an avatar, once its assistant matches its prompt again
+func (d *DB) ClearAssistantDrift(avatarID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(`DELETE FROM assistant_drift WHERE avatar_id = ?`, avatarID)
+		return err
+	})
+}
+
+// GetAssistantDrift returns the drift recorded for an avatar
+// Returns sql.ErrNoRows if the avatar's assistant has not drifted
+func (d *DB) GetAssistantDrift(avatarID int64) (*models.AssistantDrift, error) {
+	return WithLockResult(d, func() (*models.AssistantDrift, error) {
+		var drift models.AssistantDrift
+		err := d.db.QueryRow(
+			`SELECT avatar_id, assistant_id, instructions, detected_at, checked_at FROM assistant_drift WHERE avatar_id = ?`,
+			avatarID,
+		).Scan(&drift.AvatarID, &drift.AssistantID, &drift.Instructions, &drift.DetectedAt, &drift.CheckedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &drift, nil
+	})
+}
+
+// GetAssistantDrifts returns every recorded drift, oldest first
+func (d *DB) GetAssistantDrifts() ([]models.AssistantDrift, error) {
+	return WithLockResult(d, func() ([]models.AssistantDrift, error) {
+		rows, err := d.db.Query(
+			`SELECT avatar_id, assistant_id, instructions, detected_at, checked_at FROM assistant_drift ORDER BY detected_at ASC, avatar_id ASC`,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		drifts := []models.AssistantDrift{}
+		for rows.Next() {
+			var drift models.AssistantDrift
+			if err := rows.Scan(&drift.AvatarID, &drift.AssistantID, &drift.Instructions, &drift.DetectedAt, &drift.CheckedAt); err != nil {
+				return nil, err
+			}
+			drifts = append(drifts, drift)
+		}
+		return drifts, rows.Err()
+	})
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestAssistantDrift(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	avatar, _ := db.CreateAvatar("Alice", "Be kind", "asst_1")
+
+	changed, err := db.RecordAssistantDrift(avatar.ID, "asst_1", "Edited")
+	if err != nil || !changed {
+		t.Fatalf("expected new drift to be reported, got %v err=%v", changed, err)
+	}
+	if changed, _ := db.RecordAssistantDrift(avatar.ID, "asst_1", "Edited"); changed {
+		t.Error("expected the same drift not to be reported again")
+	}
+	if changed, _ := db.RecordAssistantDrift(avatar.ID, "asst_1", "Edited again"); !changed {
+		t.Error("expected other instructions to be reported")
+	}
+
+	drift, err := db.GetAssistantDrift(avatar.ID)
+	if err != nil || drift.Instructions != "Edited again" {
+		t.Fatalf("expected the latest instructions, got %+v err=%v", drift, err)
+	}
+
+	if err := db.ClearAssistantDrift(avatar.ID); err != nil {
+		t.Fatalf("failed to clear drift: %v", err)
+	}
+	if _, err := db.GetAssistantDrift(avatar.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows after clearing, got %v", err)
+	}
+
+	// Drift goes away with its avatar
+	db.RecordAssistantDrift(avatar.ID, "asst_1", "Edited")
+	db.DeleteAvatar(avatar.ID)
+	if drifts, _ := db.GetAssistantDrifts(); len(drifts) != 0 {
+		t.Errorf("expected no drift for a deleted avatar, got %+v", drifts)
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateAuditEntry appends an entry to the audit log
+// Sets the entry's ID and CreatedAt on success
+func (d *DB) CreateAuditEntry(entry *models.AuditEntry) error {
+	changes := []byte("{}")
+	if len(entry.Changes) > 0 {
+		var err error
+		if changes, err = json.Marshal(entry.Changes); err != nil {
+			return err
+		}
+	}
+
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(
+			`INSERT INTO audit_log (actor, action, target_type, target_id, changes) VALUES (?, ?, ?, ?, ?)`,
+			entry.Actor, entry.Action, entry.TargetType, entry.TargetID, string(changes),
+		)
+		if err != nil {
+			log.Printf("[DB] CreateAuditEntry failed: action=%s err=%v", entry.Action, err)
+			return err
+		}
+
+		entry.ID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return d.db.QueryRow(`SELECT created_at FROM audit_log WHERE id = ?`, entry.ID).Scan(&entry.CreatedAt)
+	})
+}
+
+// GetAuditEntries retrieves audit entries matching filter, newest first
+func (d *DB) GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if filter.Actor != "" {
+		addCondition("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= ?", filter.Since.UTC().Format(sqliteTimeFormat))
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at < ?", filter.Until.UTC().Format(sqliteTimeFormat))
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < ?", filter.BeforeID)
+	}
+
+	query := `SELECT id, actor, action, target_type, target_id, changes, created_at FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	return WithLockResult(d, func() ([]models.AuditEntry, error) {
+		rows, err := d.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		entries := []models.AuditEntry{}
+		for rows.Next() {
+			var entry models.AuditEntry
+			var changes string
+			if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.TargetType, &entry.TargetID,
+				&changes, &entry.CreatedAt); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return entries, rows.Err()
+	})
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestAuditEntries(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	first := &models.AuditEntry{
+		Actor:      "alice",
+		Action:     models.AuditActionAvatarUpdate,
+		TargetType: "avatar",
+		TargetID:   "1",
+		Changes:    map[string]models.AuditChange{"name": {From: "Old", To: "New"}},
+	}
+	if err := db.CreateAuditEntry(first); err != nil {
+		t.Fatalf("failed to create audit entry: %v", err)
+	}
+	if first.ID == 0 || first.CreatedAt.IsZero() {
+		t.Errorf("expected ID and CreatedAt to be set, got %+v", first)
+	}
+
+	second := &models.AuditEntry{Actor: "bob", Action: models.AuditActionConversationInterrupt, TargetType: "conversation", TargetID: "7"}
+	db.CreateAuditEntry(second)
+
+	all, err := db.GetAuditEntries(models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to get audit entries: %v", err)
+	}
+	if len(all) != 2 || all[0].ID != second.ID || all[1].ID != first.ID {
+		t.Fatalf("expected newest first, got %+v", all)
+	}
+	if change := all[1].Changes["name"]; change.From != "Old" || change.To != "New" {
+		t.Errorf("unexpected changes: %+v", all[1].Changes)
+	}
+
+	byActor, _ := db.GetAuditEntries(models.AuditFilter{Actor: "alice", Limit: 10})
+	if len(byActor) != 1 || byActor[0].ID != first.ID {
+		t.Errorf("expected only alice's entry, got %+v", byActor)
+	}
+
+	byTarget, _ := db.GetAuditEntries(models.AuditFilter{TargetType: "conversation", TargetID: "7", Limit: 10})
+	if len(byTarget) != 1 || byTarget[0].ID != second.ID {
+		t.Errorf("expected only the conversation entry, got %+v", byTarget)
+	}
+
+	older, _ := db.GetAuditEntries(models.AuditFilter{BeforeID: second.ID, Limit: 10})
+	if len(older) != 1 || older[0].ID != first.ID {
+		t.Errorf("expected entries before %d, got %+v", second.ID, older)
+	}
+
+	future, _ := db.GetAuditEntries(models.AuditFilter{Since: time.Now().Add(time.Hour), Limit: 10})
+	if len(future) != 0 {
+		t.Errorf("expected no entries in the future, got %+v", future)
+	}
+}
+
+func TestAuditLog_AppendOnly(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	entry := &models.AuditEntry{Actor: "alice", Action: models.AuditActionAvatarDelete, TargetType: "avatar", TargetID: "1"}
+	db.CreateAuditEntry(entry)
+
+	if _, err := db.db.Exec(`UPDATE audit_log SET actor = 'mallory' WHERE id = ?`, entry.ID); err == nil {
+		t.Error("expected update of audit_log to be rejected")
+	}
+	if _, err := db.db.Exec(`DELETE FROM audit_log WHERE id = ?`, entry.ID); err == nil {
+		t.Error("expected delete from audit_log to be rejected")
+	}
+}
@@ -2,19 +2,65 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
+	"multi-avatar-chat/internal/logic"
 	"multi-avatar-chat/internal/models"
 )
 
+// avatarColumns lists the columns selected for an avatar (aliased as "a"), in scan order
+const avatarColumns = `a.id, a.name, a.prompt, a.openai_assistant_id, a.color, a.emoji, a.keywords, a.relevance_threshold, a.can_search, a.can_code, a.can_cite, a.formatting_rules, a.isolated_context, a.needs_relink, a.created_at, a.updated_at`
+
+// scanAvatar scans a row selected with avatarColumns, followed by any extra destinations
+func scanAvatar(row rowScanner, extra ...any) (*models.Avatar, error) {
+	var avatar models.Avatar
+	var assistantID sql.NullString
+	var color sql.NullString
+	var emoji sql.NullString
+	var keywords sql.NullString
+	var formattingRules sql.NullString
+	dest := append([]any{&avatar.ID, &avatar.Name, &avatar.Prompt, &assistantID, &color, &emoji,
+		&keywords, &avatar.RelevanceThreshold, &avatar.CanSearch, &avatar.CanCode, &avatar.CanCite,
+		&formattingRules, &avatar.IsolatedContext, &avatar.NeedsRelink, &avatar.CreatedAt, &avatar.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if assistantID.Valid {
+		avatar.OpenAIAssistantID = assistantID.String
+	}
+	avatar.Color = color.String
+	avatar.Emoji = emoji.String
+	avatar.Keywords = []string{}
+	if keywords.String != "" {
+		if err := json.Unmarshal([]byte(keywords.String), &avatar.Keywords); err != nil {
+			return nil, err
+		}
+	}
+	if formattingRules.String != "" {
+		if err := json.Unmarshal([]byte(formattingRules.String), &avatar.FormattingRules); err != nil {
+			return nil, err
+		}
+	}
+	return &avatar, nil
+}
+
 // CreateAvatar inserts a new avatar into the database
+// A stable color and emoji are assigned from the avatar name
+// Returns ErrDuplicate if another avatar has the same name, ignoring case and character width
 func (d *DB) CreateAvatar(name, prompt, openaiAssistantID string) (*models.Avatar, error) {
+	display := logic.AssignAvatarDisplay(name)
+
 	return WithLockResult(d, func() (*models.Avatar, error) {
+		updatedAt, stamp := newUpdatedAt()
 		result, err := d.db.Exec(
-			`INSERT INTO avatars (name, prompt, openai_assistant_id) VALUES (?, ?, ?)`,
-			name, prompt, openaiAssistantID,
+			`INSERT INTO avatars (name, name_key, prompt, openai_assistant_id, color, emoji, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			name, logic.NormalizeAvatarName(name), prompt, openaiAssistantID, display.Color, display.Emoji, stamp,
 		)
 		if err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrDuplicate
+			}
 			return nil, err
 		}
 
@@ -28,31 +74,34 @@ func (d *DB) CreateAvatar(name, prompt, openaiAssistantID string) (*models.Avata
 			Name:              name,
 			Prompt:            prompt,
 			OpenAIAssistantID: openaiAssistantID,
+			Color:             display.Color,
+			Emoji:             display.Emoji,
+			Keywords:          []string{},
 			CreatedAt:         time.Now(),
+			UpdatedAt:         updatedAt,
 		}, nil
 	})
 }
 
 // GetAvatar retrieves an avatar by ID
+// Results are cached until the avatar is modified or the cache TTL elapses
 func (d *DB) GetAvatar(id int64) (*models.Avatar, error) {
+	if cached, ok := d.avatarCache.get(id); ok {
+		avatar := copyAvatar(cached)
+		return &avatar, nil
+	}
+
 	return WithLockResult(d, func() (*models.Avatar, error) {
 		row := d.db.QueryRow(
-			`SELECT id, name, prompt, openai_assistant_id, created_at FROM avatars WHERE id = ?`,
+			`SELECT `+avatarColumns+` FROM avatars a WHERE a.id = ?`,
 			id,
 		)
-
-		var avatar models.Avatar
-		var assistantID sql.NullString
-		err := row.Scan(&avatar.ID, &avatar.Name, &avatar.Prompt, &assistantID, &avatar.CreatedAt)
+		avatar, err := scanAvatar(row)
 		if err != nil {
 			return nil, err
 		}
-
-		if assistantID.Valid {
-			avatar.OpenAIAssistantID = assistantID.String
-		}
-
-		return &avatar, nil
+		d.avatarCache.set(id, copyAvatar(*avatar))
+		return avatar, nil
 	})
 }
 
@@ -60,7 +109,7 @@ func (d *DB) GetAvatar(id int64) (*models.Avatar, error) {
 func (d *DB) GetAllAvatars() ([]models.Avatar, error) {
 	return WithLockResult(d, func() ([]models.Avatar, error) {
 		rows, err := d.db.Query(
-			`SELECT id, name, prompt, openai_assistant_id, created_at FROM avatars ORDER BY created_at DESC`,
+			`SELECT ` + avatarColumns + ` FROM avatars a ORDER BY a.created_at DESC`,
 		)
 		if err != nil {
 			return nil, err
@@ -69,15 +118,11 @@ func (d *DB) GetAllAvatars() ([]models.Avatar, error) {
 
 		var avatars []models.Avatar
 		for rows.Next() {
-			var avatar models.Avatar
-			var assistantID sql.NullString
-			if err := rows.Scan(&avatar.ID, &avatar.Name, &avatar.Prompt, &assistantID, &avatar.CreatedAt); err != nil {
+			avatar, err := scanAvatar(rows)
+			if err != nil {
 				return nil, err
 			}
-			if assistantID.Valid {
-				avatar.OpenAIAssistantID = assistantID.String
-			}
-			avatars = append(avatars, avatar)
+			avatars = append(avatars, *avatar)
 		}
 
 		return avatars, rows.Err()
@@ -85,34 +130,235 @@ func (d *DB) GetAllAvatars() ([]models.Avatar, error) {
 }
 
 // UpdateAvatar updates an existing avatar
+// Returns ErrDuplicate if another avatar has the same name, ignoring case and character width
 func (d *DB) UpdateAvatar(id int64, name, prompt, openaiAssistantID string) (*models.Avatar, error) {
+	return d.updateAvatar(id, name, prompt, openaiAssistantID, nil)
+}
+
+// UpdateAvatarIfUnmodified updates an avatar only if its updated_at still equals version
+// Returns ErrModified if it changed in between, so a concurrent edit is not overwritten
+func (d *DB) UpdateAvatarIfUnmodified(id int64, name, prompt, openaiAssistantID string, version time.Time) (*models.Avatar, error) {
+	return d.updateAvatar(id, name, prompt, openaiAssistantID, &version)
+}
+
+func (d *DB) updateAvatar(id int64, name, prompt, openaiAssistantID string, version *time.Time) (*models.Avatar, error) {
 	return WithLockResult(d, func() (*models.Avatar, error) {
+		if version != nil {
+			if err := d.checkUnmodified("avatars", id, *version); err != nil {
+				return nil, err
+			}
+		}
+
+		// Linking another assistant clears needs_relink
+		_, stamp := newUpdatedAt()
 		_, err := d.db.Exec(
-			`UPDATE avatars SET name = ?, prompt = ?, openai_assistant_id = ? WHERE id = ?`,
-			name, prompt, openaiAssistantID, id,
+			`UPDATE avatars SET name = ?, name_key = ?, prompt = ?,
+			needs_relink = CASE WHEN openai_assistant_id IS ? THEN needs_relink ELSE 0 END,
+			openai_assistant_id = ?, updated_at = ? WHERE id = ?`,
+			name, logic.NormalizeAvatarName(name), prompt, openaiAssistantID, openaiAssistantID, stamp, id,
 		)
 		if err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrDuplicate
+			}
 			return nil, err
 		}
+		d.invalidateAvatar(id)
 
 		// Fetch updated avatar
 		row := d.db.QueryRow(
-			`SELECT id, name, prompt, openai_assistant_id, created_at FROM avatars WHERE id = ?`,
+			`SELECT `+avatarColumns+` FROM avatars a WHERE a.id = ?`,
 			id,
 		)
+		return scanAvatar(row)
+	})
+}
 
-		var avatar models.Avatar
-		var assistantIDNull sql.NullString
-		err = row.Scan(&avatar.ID, &avatar.Name, &avatar.Prompt, &assistantIDNull, &avatar.CreatedAt)
+// MarkAvatarNeedsRelink flags an avatar whose assistant no longer exists on OpenAI
+// The flag is kept until the avatar is linked to another assistant.
+// Returns false if the avatar was already flagged or its assistant changed in the meantime
+func (d *DB) MarkAvatarNeedsRelink(id int64, assistantID string) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE avatars SET needs_relink = 1, updated_at = ? WHERE id = ? AND openai_assistant_id = ? AND needs_relink = 0`,
+			stamp, id, assistantID,
+		)
 		if err != nil {
-			return nil, err
+			return false, err
+		}
+		d.invalidateAvatar(id)
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, err
 		}
+		return rows > 0, nil
+	})
+}
 
-		if assistantIDNull.Valid {
-			avatar.OpenAIAssistantID = assistantIDNull.String
+// UpdateAvatarDisplay updates the color and emoji of an avatar
+func (d *DB) UpdateAvatarDisplay(id int64, color, emoji string) error {
+	return d.WithLock(func() error {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE avatars SET color = ?, emoji = ?, updated_at = ? WHERE id = ?`,
+			color, emoji, stamp, id,
+		)
+		if err != nil {
+			return err
 		}
+		d.invalidateAvatar(id)
 
-		return &avatar, nil
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// ApplyAvatarDisplay overrides the color and emoji of an avatar with the non-empty values given
+// The current values are read and written under one lock, so a concurrent override of the other
+// field is not lost. avatar receives the resulting color, emoji and updated_at
+func (d *DB) ApplyAvatarDisplay(avatar *models.Avatar, color, emoji string) error {
+	return d.WithLock(func() error {
+		var current struct{ color, emoji sql.NullString }
+		if err := d.db.QueryRow(`SELECT color, emoji FROM avatars WHERE id = ?`, avatar.ID).Scan(&current.color, &current.emoji); err != nil {
+			return err
+		}
+		if color == "" {
+			color = current.color.String
+		}
+		if emoji == "" {
+			emoji = current.emoji.String
+		}
+
+		updatedAt, stamp := newUpdatedAt()
+		if _, err := d.db.Exec(
+			`UPDATE avatars SET color = ?, emoji = ?, updated_at = ? WHERE id = ?`,
+			color, emoji, stamp, avatar.ID,
+		); err != nil {
+			return err
+		}
+		d.invalidateAvatar(avatar.ID)
+
+		avatar.Color = color
+		avatar.Emoji = emoji
+		avatar.UpdatedAt = updatedAt
+		return nil
+	})
+}
+
+// UpdateAvatarPrefilter updates the keywords and relevance threshold used before LLM judgment
+func (d *DB) UpdateAvatarPrefilter(id int64, keywords []string, threshold float64) error {
+	if keywords == nil {
+		keywords = []string{}
+	}
+	encoded, err := json.Marshal(keywords)
+	if err != nil {
+		return err
+	}
+
+	return d.WithLock(func() error {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE avatars SET keywords = ?, relevance_threshold = ?, updated_at = ? WHERE id = ?`,
+			string(encoded), threshold, stamp, id,
+		)
+		if err != nil {
+			return err
+		}
+		d.invalidateAvatar(id)
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// UpdateAvatarCapabilities updates the capability flags that enable assistant tools and prompt augmentations
+func (d *DB) UpdateAvatarCapabilities(id int64, canSearch, canCode, canCite bool) error {
+	return d.WithLock(func() error {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE avatars SET can_search = ?, can_code = ?, can_cite = ?, updated_at = ? WHERE id = ?`,
+			canSearch, canCode, canCite, stamp, id,
+		)
+		if err != nil {
+			return err
+		}
+		d.invalidateAvatar(id)
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// UpdateAvatarFormattingRules updates the rules applied to the layout of an avatar's responses
+func (d *DB) UpdateAvatarFormattingRules(id int64, rules models.FormattingRules) error {
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	return d.WithLock(func() error {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE avatars SET formatting_rules = ?, updated_at = ? WHERE id = ?`,
+			string(encoded), stamp, id,
+		)
+		if err != nil {
+			return err
+		}
+		d.invalidateAvatar(id)
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// UpdateAvatarIsolatedContext sets whether an avatar sees other avatars' messages
+func (d *DB) UpdateAvatarIsolatedContext(id int64, isolated bool) error {
+	return d.WithLock(func() error {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE avatars SET isolated_context = ?, updated_at = ? WHERE id = ?`,
+			isolated, stamp, id,
+		)
+		if err != nil {
+			return err
+		}
+		d.invalidateAvatar(id)
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
 	})
 }
 
@@ -123,6 +369,7 @@ func (d *DB) DeleteAvatar(id int64) error {
 		if err != nil {
 			return err
 		}
+		d.invalidateAvatar(id)
 
 		rows, err := result.RowsAffected()
 		if err != nil {
@@ -136,4 +383,3 @@ func (d *DB) DeleteAvatar(id int64) error {
 		return nil
 	})
 }
-
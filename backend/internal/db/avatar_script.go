@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const avatarScriptColumns = `avatar_id, source, last_error, last_error_at, updated_at`
+
+// scanAvatarScript scans a row selected with avatarScriptColumns
+func scanAvatarScript(scanner interface{ Scan(...any) error }) (*models.AvatarScript, error) {
+	var s models.AvatarScript
+	var lastErrorAt sql.NullTime
+	if err := scanner.Scan(&s.AvatarID, &s.Source, &s.LastError, &lastErrorAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if lastErrorAt.Valid {
+		s.LastErrorAt = &lastErrorAt.Time
+	}
+	return &s, nil
+}
+
+// SetAvatarScript attaches a behavior script to an avatar, replacing any previous one
+// The last runtime error is cleared, since it belonged to the old source
+func (d *DB) SetAvatarScript(avatarID int64, source string) (*models.AvatarScript, error) {
+	return WithLockResult(d, func() (*models.AvatarScript, error) {
+		now := time.Now().UTC().Format(sqliteTimeFormat)
+		_, err := d.db.Exec(
+			`INSERT INTO avatar_scripts (avatar_id, source, last_error, last_error_at, updated_at)
+			VALUES (?, ?, '', NULL, ?)
+			ON CONFLICT(avatar_id) DO UPDATE SET
+				source = excluded.source, last_error = '', last_error_at = NULL, updated_at = excluded.updated_at`,
+			avatarID, source, now,
+		)
+		if err != nil {
+			log.Printf("[DB] SetAvatarScript failed: exec error avatar_id=%d err=%v", avatarID, err)
+			return nil, err
+		}
+		return scanAvatarScript(d.db.QueryRow(`SELECT `+avatarScriptColumns+` FROM avatar_scripts WHERE avatar_id = ?`, avatarID))
+	})
+}
+
+// GetAvatarScript retrieves the behavior script of an avatar
+// Returns sql.ErrNoRows if the avatar has none
+func (d *DB) GetAvatarScript(avatarID int64) (*models.AvatarScript, error) {
+	return WithLockResult(d, func() (*models.AvatarScript, error) {
+		return scanAvatarScript(d.db.QueryRow(`SELECT `+avatarScriptColumns+` FROM avatar_scripts WHERE avatar_id = ?`, avatarID))
+	})
+}
+
+// DeleteAvatarScript detaches the behavior script of an avatar
+// Returns sql.ErrNoRows if the avatar has none
+func (d *DB) DeleteAvatarScript(avatarID int64) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(`DELETE FROM avatar_scripts WHERE avatar_id = ?`, avatarID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// RecordAvatarScriptError stores the latest runtime error of an avatar's behavior script
+func (d *DB) RecordAvatarScriptError(avatarID int64, errMsg string) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`UPDATE avatar_scripts SET last_error = ?, last_error_at = ? WHERE avatar_id = ?`,
+			errMsg, time.Now().UTC().Format(sqliteTimeFormat), avatarID,
+		)
+		return err
+	})
+}
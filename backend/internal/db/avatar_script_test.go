@@ -0,0 +1,49 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestAvatarScripts(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+
+	if _, err := db.GetAvatarScript(avatar.ID); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows without a script, got %v", err)
+	}
+
+	created, err := db.SetAvatarScript(avatar.ID, "respond()")
+	if err != nil || created.Source != "respond()" || created.LastError != "" || created.LastErrorAt != nil {
+		t.Fatalf("unexpected script: %+v err=%v", created, err)
+	}
+
+	if err := db.RecordAvatarScriptError(avatar.ID, "line 1: boom"); err != nil {
+		t.Fatalf("failed to record error: %v", err)
+	}
+	failed, _ := db.GetAvatarScript(avatar.ID)
+	if failed.LastError != "line 1: boom" || failed.LastErrorAt == nil {
+		t.Errorf("expected the error to be recorded, got %+v", failed)
+	}
+
+	updated, _ := db.SetAvatarScript(avatar.ID, "skip()")
+	if updated.Source != "skip()" || updated.LastError != "" || updated.LastErrorAt != nil {
+		t.Errorf("expected a new source to clear the error, got %+v", updated)
+	}
+
+	if err := db.DeleteAvatarScript(avatar.ID); err != nil {
+		t.Fatalf("failed to delete script: %v", err)
+	}
+	if err := db.DeleteAvatarScript(avatar.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting a missing script, got %v", err)
+	}
+
+	// Scripts go away with their avatar
+	db.SetAvatarScript(avatar.ID, "respond()")
+	db.DeleteAvatar(avatar.ID)
+	if _, err := db.GetAvatarScript(avatar.ID); err != sql.ErrNoRows {
+		t.Errorf("expected the script to be deleted with the avatar, got %v", err)
+	}
+}
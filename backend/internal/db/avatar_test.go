@@ -2,15 +2,15 @@ package db
 
 import (
 	"database/sql"
-	"os"
 	"testing"
+
+	"multi-avatar-chat/internal/models"
 )
 
 func setupTestDB(t *testing.T) (*DB, func()) {
 	t.Helper()
 
-	tmpFile := createTempDB(t)
-	database, err := NewDB(tmpFile)
+	database, err := NewDB(MemoryPath)
 	if err != nil {
 		t.Fatalf("failed to create database: %v", err)
 	}
@@ -21,7 +21,6 @@ func setupTestDB(t *testing.T) (*DB, func()) {
 
 	cleanup := func() {
 		database.Close()
-		os.Remove(tmpFile)
 	}
 
 	return database, cleanup
@@ -162,3 +161,279 @@ func TestDeleteAvatar_NotFound(t *testing.T) {
 	}
 }
 
+
+func TestCreateAvatar_DisplayMetadata(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, err := db.CreateAvatar("DisplayBot", "prompt", "")
+	if err != nil {
+		t.Fatalf("failed to create avatar: %v", err)
+	}
+
+	avatar, err := db.GetAvatar(created.ID)
+	if err != nil {
+		t.Fatalf("failed to get avatar: %v", err)
+	}
+
+	if avatar.Color == "" || avatar.Emoji == "" {
+		t.Errorf("expected color and emoji to be stored, got color=%q emoji=%q", avatar.Color, avatar.Emoji)
+	}
+	if avatar.Color != created.Color || avatar.Emoji != created.Emoji {
+		t.Errorf("expected stored display to match created avatar")
+	}
+}
+
+func TestUpdateAvatarDisplay(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("DisplayBot", "prompt", "")
+
+	if err := db.UpdateAvatarDisplay(created.ID, "#000000", "🐸"); err != nil {
+		t.Fatalf("failed to update display: %v", err)
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if avatar.Color != "#000000" || avatar.Emoji != "🐸" {
+		t.Errorf("expected updated display, got color=%q emoji=%q", avatar.Color, avatar.Emoji)
+	}
+
+	if err := db.UpdateAvatarDisplay(99999, "#000000", "🐸"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestApplyAvatarDisplay(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("DisplayBot", "prompt", "")
+
+	// Overrides of one field keep the other one as stored, even when the caller's copy is stale
+	stale := *created
+	if err := db.ApplyAvatarDisplay(created, "#000000", ""); err != nil {
+		t.Fatalf("failed to apply color: %v", err)
+	}
+	if err := db.ApplyAvatarDisplay(&stale, "", "🐸"); err != nil {
+		t.Fatalf("failed to apply emoji: %v", err)
+	}
+	if stale.Color != "#000000" || stale.Emoji != "🐸" {
+		t.Errorf("expected the caller's avatar to get the stored display, got color=%q emoji=%q", stale.Color, stale.Emoji)
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if avatar.Color != "#000000" || avatar.Emoji != "🐸" {
+		t.Errorf("expected both overrides to be kept, got color=%q emoji=%q", avatar.Color, avatar.Emoji)
+	}
+
+	if err := db.ApplyAvatarDisplay(&models.Avatar{ID: 99999}, "#000000", ""); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestUpdateAvatarPrefilter(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Chef", "prompt", "")
+	if len(created.Keywords) != 0 || created.RelevanceThreshold != 0 {
+		t.Errorf("expected pre-filter to be disabled by default, got %+v", created)
+	}
+
+	if err := db.UpdateAvatarPrefilter(created.ID, []string{"pasta", "pizza"}, 0.25); err != nil {
+		t.Fatalf("failed to update pre-filter: %v", err)
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if len(avatar.Keywords) != 2 || avatar.Keywords[0] != "pasta" || avatar.RelevanceThreshold != 0.25 {
+		t.Errorf("expected updated pre-filter, got keywords=%v threshold=%v", avatar.Keywords, avatar.RelevanceThreshold)
+	}
+
+	if err := db.UpdateAvatarPrefilter(99999, nil, 0); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestUpdateAvatarCapabilities(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Researcher", "prompt", "")
+	if created.CanSearch || created.CanCode || created.CanCite {
+		t.Errorf("expected capabilities to be off by default, got %+v", created)
+	}
+
+	if err := db.UpdateAvatarCapabilities(created.ID, true, false, true); err != nil {
+		t.Fatalf("failed to update capabilities: %v", err)
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if !avatar.CanSearch || avatar.CanCode || !avatar.CanCite {
+		t.Errorf("expected updated capabilities, got %+v", avatar)
+	}
+
+	if err := db.UpdateAvatarCapabilities(99999, false, false, false); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestMarkAvatarNeedsRelink(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Bob", "prompt", "asst_deleted")
+	if created.NeedsRelink {
+		t.Errorf("expected a new avatar not to need a relink")
+	}
+
+	if marked, err := db.MarkAvatarNeedsRelink(created.ID, "asst_other"); err != nil || marked {
+		t.Errorf("expected no mark for a different assistant, got marked=%v err=%v", marked, err)
+	}
+	if marked, err := db.MarkAvatarNeedsRelink(created.ID, "asst_deleted"); err != nil || !marked {
+		t.Fatalf("expected the avatar to be marked, got marked=%v err=%v", marked, err)
+	}
+	if marked, _ := db.MarkAvatarNeedsRelink(created.ID, "asst_deleted"); marked {
+		t.Errorf("expected an already flagged avatar not to be marked again")
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if !avatar.NeedsRelink {
+		t.Fatalf("expected needs_relink to be set")
+	}
+
+	// Renaming keeps the flag; linking another assistant clears it
+	avatar, _ = db.UpdateAvatar(created.ID, "Robert", "prompt", "asst_deleted")
+	if !avatar.NeedsRelink {
+		t.Errorf("expected needs_relink to survive an update of the same assistant")
+	}
+	avatar, _ = db.UpdateAvatar(created.ID, "Robert", "prompt", "asst_new")
+	if avatar.NeedsRelink {
+		t.Errorf("expected needs_relink to be cleared by a new assistant")
+	}
+}
+
+func TestUpdateAvatarFormattingRules(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Reporter", "prompt", "")
+	if created.FormattingRules != (models.FormattingRules{}) {
+		t.Errorf("expected no formatting rules by default, got %+v", created.FormattingRules)
+	}
+
+	rules := models.FormattingRules{NameTag: true, MaxParagraphs: 2}
+	if err := db.UpdateAvatarFormattingRules(created.ID, rules); err != nil {
+		t.Fatalf("failed to update formatting rules: %v", err)
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if avatar.FormattingRules != rules {
+		t.Errorf("expected %+v, got %+v", rules, avatar.FormattingRules)
+	}
+
+	if err := db.UpdateAvatarFormattingRules(99999, rules); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestUpdateAvatarIsolatedContext(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Judge", "prompt", "")
+	if created.IsolatedContext {
+		t.Errorf("expected context isolation to be off by default")
+	}
+
+	if err := db.UpdateAvatarIsolatedContext(created.ID, true); err != nil {
+		t.Fatalf("failed to update isolated context: %v", err)
+	}
+
+	avatar, _ := db.GetAvatar(created.ID)
+	if !avatar.IsolatedContext {
+		t.Errorf("expected the avatar to be isolated")
+	}
+
+	if err := db.UpdateAvatarIsolatedContext(99999, true); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestUpdateAvatar_UpdatedAt(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Alice", "Prompt", "")
+	stored, _ := db.GetAvatar(created.ID)
+	if !stored.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Fatalf("expected created updated_at %v to be stored, got %v", created.UpdatedAt, stored.UpdatedAt)
+	}
+
+	updated, err := db.UpdateAvatarIfUnmodified(created.ID, "Alice", "New prompt", "", created.UpdatedAt)
+	if err != nil {
+		t.Fatalf("expected conditional update to succeed, got %v", err)
+	}
+	if !updated.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("expected updated_at to move forward, got %v then %v", created.UpdatedAt, updated.UpdatedAt)
+	}
+
+	// A second writer holding the old version must not overwrite the first
+	if _, err := db.UpdateAvatarIfUnmodified(created.ID, "Alice", "Other prompt", "", created.UpdatedAt); err != ErrModified {
+		t.Errorf("expected ErrModified for a stale version, got %v", err)
+	}
+	if err := db.UpdateAvatarDisplay(created.ID, "#112233", "🙂"); err != nil {
+		t.Fatalf("failed to update display: %v", err)
+	}
+	afterDisplay, _ := db.GetAvatar(created.ID)
+	if afterDisplay.Prompt != "New prompt" || !afterDisplay.UpdatedAt.After(updated.UpdatedAt) {
+		t.Errorf("expected display update to move updated_at and keep the prompt, got %+v", afterDisplay)
+	}
+}
+
+func TestAvatarNames_Unique(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	alice, err := db.CreateAvatar("Alice", "Prompt", "")
+	if err != nil {
+		t.Fatalf("failed to create avatar: %v", err)
+	}
+	bob, _ := db.CreateAvatar("Bob", "Prompt", "")
+
+	for _, name := range []string{"alice", "ＡＬＩＣＥ"} {
+		if _, err := db.CreateAvatar(name, "Prompt", ""); err != ErrDuplicate {
+			t.Errorf("expected ErrDuplicate creating %q, got %v", name, err)
+		}
+	}
+	if _, err := db.UpdateAvatar(bob.ID, "ALICE", "Prompt", ""); err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate renaming to an existing name, got %v", err)
+	}
+
+	// Changing the case of an avatar's own name is allowed
+	if _, err := db.UpdateAvatar(alice.ID, "ALICE", "Prompt", ""); err != nil {
+		t.Errorf("expected renaming an avatar to its own name to succeed, got %v", err)
+	}
+}
+
+func TestMigrateAvatarNameKeys_ExistingDuplicates(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Simulate avatars created before names were unique
+	db.db.Exec(`DROP INDEX idx_avatars_name_key`)
+	db.db.Exec(`INSERT INTO avatars (name, prompt) VALUES ('Alice', 'Prompt'), ('alice', 'Prompt'), ('Bob', 'Prompt')`)
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migration failed with duplicate names: %v", err)
+	}
+
+	var keyed int
+	db.db.QueryRow(`SELECT COUNT(*) FROM avatars WHERE name_key IS NOT NULL`).Scan(&keyed)
+	if keyed != 2 {
+		t.Errorf("expected 2 avatars with a name key, got %d", keyed)
+	}
+	if _, err := db.CreateAvatar("BOB", "Prompt", ""); err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate after migration, got %v", err)
+	}
+}
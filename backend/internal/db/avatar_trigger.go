@@ -0,0 +1,80 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const avatarTriggerColumns = `id, conversation_id, avatar_id, pattern, regex, created_at`
+
+// scanAvatarTrigger scans a row selected with avatarTriggerColumns
+func scanAvatarTrigger(scanner interface{ Scan(...any) error }) (*models.AvatarTrigger, error) {
+	var t models.AvatarTrigger
+	if err := scanner.Scan(&t.ID, &t.ConversationID, &t.AvatarID, &t.Pattern, &t.Regex, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateAvatarTrigger attaches a trigger to an avatar in a conversation
+func (d *DB) CreateAvatarTrigger(conversationID, avatarID int64, pattern string, regex bool) (*models.AvatarTrigger, error) {
+	return WithLockResult(d, func() (*models.AvatarTrigger, error) {
+		result, err := d.db.Exec(
+			`INSERT INTO avatar_triggers (conversation_id, avatar_id, pattern, regex) VALUES (?, ?, ?, ?)`,
+			conversationID, avatarID, pattern, regex,
+		)
+		if err != nil {
+			log.Printf("[DB] CreateAvatarTrigger failed: exec error conversation_id=%d avatar_id=%d err=%v", conversationID, avatarID, err)
+			return nil, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		return scanAvatarTrigger(d.db.QueryRow(`SELECT `+avatarTriggerColumns+` FROM avatar_triggers WHERE id = ?`, id))
+	})
+}
+
+// GetAvatarTriggers returns the triggers of an avatar in a conversation, oldest first
+func (d *DB) GetAvatarTriggers(conversationID, avatarID int64) ([]models.AvatarTrigger, error) {
+	return WithLockResult(d, func() ([]models.AvatarTrigger, error) {
+		rows, err := d.db.Query(
+			`SELECT `+avatarTriggerColumns+` FROM avatar_triggers
+			WHERE conversation_id = ? AND avatar_id = ? ORDER BY id`,
+			conversationID, avatarID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		triggers := []models.AvatarTrigger{}
+		for rows.Next() {
+			t, err := scanAvatarTrigger(rows)
+			if err != nil {
+				return nil, err
+			}
+			triggers = append(triggers, *t)
+		}
+		return triggers, rows.Err()
+	})
+}
+
+// DeleteAvatarTrigger removes a trigger of an avatar in a conversation and returns it
+// Returns sql.ErrNoRows if the trigger does not belong to the avatar in that conversation
+func (d *DB) DeleteAvatarTrigger(conversationID, avatarID, id int64) (*models.AvatarTrigger, error) {
+	return WithLockResult(d, func() (*models.AvatarTrigger, error) {
+		trigger, err := scanAvatarTrigger(d.db.QueryRow(
+			`SELECT `+avatarTriggerColumns+` FROM avatar_triggers WHERE id = ? AND conversation_id = ? AND avatar_id = ?`,
+			id, conversationID, avatarID,
+		))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.db.Exec(`DELETE FROM avatar_triggers WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+		return trigger, nil
+	})
+}
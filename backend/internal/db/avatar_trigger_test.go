@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestAvatarTriggers(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Chat", "thread_1")
+	avatar, _ := db.CreateAvatar("LegalBot", "prompt", "asst_1")
+	db.AddAvatarToConversation(conv.ID, avatar.ID)
+
+	if triggers, err := db.GetAvatarTriggers(conv.ID, avatar.ID); err != nil || len(triggers) != 0 {
+		t.Fatalf("expected no triggers, got %+v err=%v", triggers, err)
+	}
+
+	keyword, err := db.CreateAvatarTrigger(conv.ID, avatar.ID, "contract", false)
+	if err != nil || keyword.Pattern != "contract" || keyword.Regex || keyword.CreatedAt.IsZero() {
+		t.Fatalf("unexpected trigger: %+v err=%v", keyword, err)
+	}
+	pattern, _ := db.CreateAvatarTrigger(conv.ID, avatar.ID, `\bNDA\b`, true)
+
+	triggers, _ := db.GetAvatarTriggers(conv.ID, avatar.ID)
+	if len(triggers) != 2 || triggers[0].ID != keyword.ID || !triggers[1].Regex {
+		t.Fatalf("expected both triggers oldest first, got %+v", triggers)
+	}
+
+	if _, err := db.DeleteAvatarTrigger(conv.ID, avatar.ID+1, keyword.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting another avatar's trigger, got %v", err)
+	}
+	deleted, err := db.DeleteAvatarTrigger(conv.ID, avatar.ID, keyword.ID)
+	if err != nil || deleted.Pattern != "contract" {
+		t.Fatalf("unexpected deleted trigger: %+v err=%v", deleted, err)
+	}
+
+	// Triggers go away when the avatar leaves the conversation
+	if err := db.RemoveAvatarFromConversation(conv.ID, avatar.ID); err != nil {
+		t.Fatalf("failed to remove avatar: %v", err)
+	}
+	if triggers, _ := db.GetAvatarTriggers(conv.ID, avatar.ID); len(triggers) != 0 {
+		t.Errorf("expected trigger %d to be deleted with the participant, got %+v", pattern.ID, triggers)
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// DefaultCacheTTL is how long cached avatar and participant lookups stay valid
+// Mutations invalidate entries immediately; the TTL only bounds staleness from other writers
+const DefaultCacheTTL = 10 * time.Second
+
+// CacheStats reports lookups served from the in-process cache
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// cacheEntry is a cached value with its expiry time
+type cacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// ttlCache is a small in-process cache with per-entry expiry
+// A TTL of 0 disables caching
+type ttlCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[K]cacheEntry[V]
+	hits    uint64
+	misses  uint64
+}
+
+// newTTLCache creates a cache whose entries expire after ttl
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{
+		ttl:     ttl,
+		entries: make(map[K]cacheEntry[V]),
+	}
+}
+
+// get returns a cached value if it exists and has not expired
+func (c *ttlCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expiresAt) {
+		c.hits++
+		return entry.value, true
+	}
+	if ok {
+		delete(c.entries, key)
+	}
+	c.misses++
+
+	var zero V
+	return zero, false
+}
+
+// set stores a value until the TTL elapses
+func (c *ttlCache[K, V]) set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	c.entries[key] = cacheEntry[V]{value: value, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// delete removes a single entry
+func (c *ttlCache[K, V]) delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// clear removes all entries
+func (c *ttlCache[K, V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[K]cacheEntry[V])
+}
+
+// setTTL changes the TTL and drops all entries
+func (c *ttlCache[K, V]) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.entries = make(map[K]cacheEntry[V])
+}
+
+// stats returns the hit and miss counters
+func (c *ttlCache[K, V]) stats() (uint64, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// SetCacheTTL changes how long avatar and participant lookups are cached
+// A TTL of 0 disables the cache
+func (d *DB) SetCacheTTL(ttl time.Duration) {
+	d.avatarCache.setTTL(ttl)
+	d.participantCache.setTTL(ttl)
+}
+
+// CacheStats returns the combined hit and miss counts of the avatar and participant caches
+func (d *DB) CacheStats() CacheStats {
+	avatarHits, avatarMisses := d.avatarCache.stats()
+	participantHits, participantMisses := d.participantCache.stats()
+	return CacheStats{
+		Hits:   avatarHits + participantHits,
+		Misses: avatarMisses + participantMisses,
+	}
+}
+
+// invalidateAvatar drops cached data that includes the avatar
+// Participant lists embed avatar fields, so all of them are dropped
+func (d *DB) invalidateAvatar(avatarID int64) {
+	d.avatarCache.delete(avatarID)
+	d.participantCache.clear()
+}
+
+// invalidateParticipants drops the cached participant list of a conversation
+func (d *DB) invalidateParticipants(conversationID int64) {
+	d.participantCache.delete(conversationID)
+}
+
+// invalidateAll drops every cached entry
+func (d *DB) invalidateAll() {
+	d.avatarCache.clear()
+	d.participantCache.clear()
+}
+
+// copyAvatar returns a copy of an avatar that does not share slices with the cache
+func copyAvatar(avatar models.Avatar) models.Avatar {
+	avatar.Keywords = append([]string{}, avatar.Keywords...)
+	return avatar
+}
+
+// copyParticipants returns a copy of a participant list that does not share slices with the cache
+func copyParticipants(p ConversationAvatarsWithThreads) ConversationAvatarsWithThreads {
+	if p.Avatars == nil {
+		return ConversationAvatarsWithThreads{}
+	}
+	avatars := make([]models.Avatar, len(p.Avatars))
+	for i, a := range p.Avatars {
+		avatars[i] = copyAvatar(a)
+	}
+	return ConversationAvatarsWithThreads{
+		Avatars:   avatars,
+		ThreadIDs: append([]string{}, p.ThreadIDs...),
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestGetAvatar_Cached(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Bot", "prompt", "")
+
+	db.GetAvatar(created.ID)
+	before := db.CacheStats()
+
+	avatar, err := db.GetAvatar(created.ID)
+	if err != nil {
+		t.Fatalf("failed to get avatar: %v", err)
+	}
+	if avatar.Name != "Bot" {
+		t.Errorf("expected name 'Bot', got %q", avatar.Name)
+	}
+
+	after := db.CacheStats()
+	if after.Hits != before.Hits+1 {
+		t.Errorf("expected a cache hit, stats before=%+v after=%+v", before, after)
+	}
+
+	// Callers must not be able to modify the cached copy
+	avatar.Name = "Changed"
+	again, _ := db.GetAvatar(created.ID)
+	if again.Name != "Bot" {
+		t.Errorf("expected cached avatar to be unaffected, got %q", again.Name)
+	}
+}
+
+func TestGetAvatar_InvalidatedOnUpdate(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, _ := db.CreateAvatar("Bot", "prompt", "")
+	db.GetAvatar(created.ID)
+
+	db.UpdateAvatar(created.ID, "Renamed", "prompt", "")
+	avatar, _ := db.GetAvatar(created.ID)
+	if avatar.Name != "Renamed" {
+		t.Errorf("expected updated name, got %q", avatar.Name)
+	}
+
+	db.UpdateAvatarPrefilter(created.ID, []string{"pasta"}, 0.5)
+	avatar, _ = db.GetAvatar(created.ID)
+	if avatar.RelevanceThreshold != 0.5 {
+		t.Errorf("expected updated threshold, got %v", avatar.RelevanceThreshold)
+	}
+
+	db.DeleteAvatar(created.ID)
+	if _, err := db.GetAvatar(created.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows after delete, got %v", err)
+	}
+}
+
+func TestConversationAvatars_InvalidatedOnParticipationChange(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	first, _ := db.CreateAvatar("First", "prompt", "")
+	second, _ := db.CreateAvatar("Second", "prompt", "")
+
+	db.AddAvatarToConversationWithThreadID(conv.ID, first.ID, "thread_1")
+	avatars, _ := db.GetConversationAvatars(conv.ID)
+	if len(avatars) != 1 {
+		t.Fatalf("expected 1 avatar, got %d", len(avatars))
+	}
+
+	db.AddAvatarToConversation(conv.ID, second.ID)
+	avatars, threadIDs, _ := db.GetConversationAvatarsWithThreads(conv.ID)
+	if len(avatars) != 2 || len(threadIDs) != 2 {
+		t.Fatalf("expected 2 avatars after join, got %d", len(avatars))
+	}
+
+	db.UpdateAvatarThreadID(conv.ID, second.ID, "thread_2")
+	if threadID, _ := db.GetAvatarThreadID(conv.ID, second.ID); threadID != "thread_2" {
+		t.Errorf("expected updated thread ID, got %q", threadID)
+	}
+
+	db.UpdateAvatar(first.ID, "Renamed", "prompt", "")
+	avatars, _ = db.GetConversationAvatars(conv.ID)
+	renamed := false
+	for _, a := range avatars {
+		if a.Name == "Renamed" {
+			renamed = true
+		}
+	}
+	if !renamed {
+		t.Error("expected participant list to reflect the avatar update")
+	}
+
+	db.RemoveAvatarFromConversation(conv.ID, first.ID)
+	avatars, _ = db.GetConversationAvatars(conv.ID)
+	if len(avatars) != 1 {
+		t.Errorf("expected 1 avatar after leave, got %d", len(avatars))
+	}
+	if _, err := db.GetAvatarThreadID(conv.ID, first.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for removed avatar, got %v", err)
+	}
+}
+
+func TestCache_TTLExpiry(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.SetCacheTTL(20 * time.Millisecond)
+	created, _ := db.CreateAvatar("Bot", "prompt", "")
+	db.GetAvatar(created.ID)
+
+	// Bypass the invalidation of the public helpers to simulate another writer
+	db.db.Exec(`UPDATE avatars SET name = 'Other' WHERE id = ?`, created.ID)
+
+	if avatar, _ := db.GetAvatar(created.ID); avatar.Name != "Bot" {
+		t.Errorf("expected cached name within TTL, got %q", avatar.Name)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if avatar, _ := db.GetAvatar(created.ID); avatar.Name != "Other" {
+		t.Errorf("expected fresh name after TTL, got %q", avatar.Name)
+	}
+}
+
+func TestCache_Disabled(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.SetCacheTTL(0)
+	created, _ := db.CreateAvatar("Bot", "prompt", "")
+	db.GetAvatar(created.ID)
+	db.GetAvatar(created.ID)
+
+	if stats := db.CacheStats(); stats.Hits != 0 {
+		t.Errorf("expected no cache hits when disabled, got %+v", stats)
+	}
+}
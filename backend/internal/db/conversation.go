@@ -8,12 +8,60 @@ import (
 	"multi-avatar-chat/internal/models"
 )
 
+// conversationColumns lists the columns selected for a conversation, in scan order
+const conversationColumns = `id, title, thread_id, response_style, redaction_policy, system_instructions, max_context_messages, created_at, updated_at, expires_at, language, display_language, openai_organization, openai_project, published, gallery_slug`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanConversation scans a row selected with conversationColumns
+func scanConversation(row rowScanner) (*models.Conversation, error) {
+	var conv models.Conversation
+	var threadID sql.NullString
+	var expiresAt sql.NullTime
+	var gallerySlug sql.NullString
+	if err := row.Scan(&conv.ID, &conv.Title, &threadID, &conv.ResponseStyle, &conv.RedactionPolicy, &conv.SystemInstructions, &conv.MaxContextMessages, &conv.CreatedAt, &conv.UpdatedAt, &expiresAt, &conv.Language, &conv.DisplayLanguage, &conv.OpenAIOrganization, &conv.OpenAIProject, &conv.Published, &gallerySlug); err != nil {
+		return nil, err
+	}
+	conv.GallerySlug = gallerySlug.String
+	if threadID.Valid {
+		conv.ThreadID = threadID.String
+	}
+	if expiresAt.Valid {
+		conv.ExpiresAt = &expiresAt.Time
+	}
+	return &conv, nil
+}
+
 // CreateConversation creates a new conversation
 func (d *DB) CreateConversation(title, threadID string) (*models.Conversation, error) {
+	return d.CreateConversationWithStyle(title, threadID, "")
+}
+
+// CreateConversationWithStyle creates a new conversation with a response style
+// An empty style falls back to the column default ("normal")
+func (d *DB) CreateConversationWithStyle(title, threadID, responseStyle string) (*models.Conversation, error) {
+	return d.CreateConversationWithSettings(title, threadID, responseStyle, "", "", 0)
+}
+
+// CreateConversationWithSettings creates a new conversation with a response style, redaction policy,
+// system instructions and run context limit. Empty values fall back to the column defaults ("normal", "off", none and 0)
+func (d *DB) CreateConversationWithSettings(title, threadID, responseStyle, redactionPolicy, systemInstructions string, maxContextMessages int) (*models.Conversation, error) {
+	if responseStyle == "" {
+		responseStyle = "normal"
+	}
+	if redactionPolicy == "" {
+		redactionPolicy = "off"
+	}
+
 	return WithLockResult(d, func() (*models.Conversation, error) {
+		updatedAt, stamp := newUpdatedAt()
 		result, err := d.db.Exec(
-			`INSERT INTO conversations (title, thread_id) VALUES (?, ?)`,
-			title, threadID,
+			`INSERT INTO conversations (title, thread_id, response_style, redaction_policy, system_instructions, max_context_messages, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			title, threadID, responseStyle, redactionPolicy, systemInstructions, maxContextMessages, stamp,
 		)
 		if err != nil {
 			return nil, err
@@ -25,10 +73,15 @@ func (d *DB) CreateConversation(title, threadID string) (*models.Conversation, e
 		}
 
 		return &models.Conversation{
-			ID:        id,
-			Title:     title,
-			ThreadID:  threadID,
-			CreatedAt: time.Now(),
+			ID:                 id,
+			Title:              title,
+			ThreadID:           threadID,
+			ResponseStyle:      responseStyle,
+			RedactionPolicy:    redactionPolicy,
+			SystemInstructions: systemInstructions,
+			MaxContextMessages: maxContextMessages,
+			CreatedAt:          time.Now(),
+			UpdatedAt:          updatedAt,
 		}, nil
 	})
 }
@@ -37,22 +90,10 @@ func (d *DB) CreateConversation(title, threadID string) (*models.Conversation, e
 func (d *DB) GetConversation(id int64) (*models.Conversation, error) {
 	return WithLockResult(d, func() (*models.Conversation, error) {
 		row := d.db.QueryRow(
-			`SELECT id, title, thread_id, created_at FROM conversations WHERE id = ?`,
+			`SELECT `+conversationColumns+` FROM conversations WHERE id = ?`,
 			id,
 		)
-
-		var conv models.Conversation
-		var threadID sql.NullString
-		err := row.Scan(&conv.ID, &conv.Title, &threadID, &conv.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-
-		if threadID.Valid {
-			conv.ThreadID = threadID.String
-		}
-
-		return &conv, nil
+		return scanConversation(row)
 	})
 }
 
@@ -60,7 +101,7 @@ func (d *DB) GetConversation(id int64) (*models.Conversation, error) {
 func (d *DB) GetAllConversations() ([]models.Conversation, error) {
 	return WithLockResult(d, func() ([]models.Conversation, error) {
 		rows, err := d.db.Query(
-			`SELECT id, title, thread_id, created_at FROM conversations ORDER BY created_at DESC`,
+			`SELECT ` + conversationColumns + ` FROM conversations ORDER BY created_at DESC`,
 		)
 		if err != nil {
 			return nil, err
@@ -69,21 +110,63 @@ func (d *DB) GetAllConversations() ([]models.Conversation, error) {
 
 		var conversations []models.Conversation
 		for rows.Next() {
-			var conv models.Conversation
-			var threadID sql.NullString
-			if err := rows.Scan(&conv.ID, &conv.Title, &threadID, &conv.CreatedAt); err != nil {
+			conv, err := scanConversation(rows)
+			if err != nil {
 				return nil, err
 			}
-			if threadID.Valid {
-				conv.ThreadID = threadID.String
-			}
-			conversations = append(conversations, conv)
+			conversations = append(conversations, *conv)
 		}
 
 		return conversations, rows.Err()
 	})
 }
 
+// UpdateConversation updates the mutable settings of a conversation
+func (d *DB) UpdateConversation(conv *models.Conversation) (*models.Conversation, error) {
+	return d.updateConversation(conv, nil)
+}
+
+// UpdateConversationIfUnmodified updates a conversation only if its updated_at still equals version
+// Returns ErrModified if it changed in between, so a concurrent edit is not overwritten
+func (d *DB) UpdateConversationIfUnmodified(conv *models.Conversation, version time.Time) (*models.Conversation, error) {
+	return d.updateConversation(conv, &version)
+}
+
+func (d *DB) updateConversation(conv *models.Conversation, version *time.Time) (*models.Conversation, error) {
+	return WithLockResult(d, func() (*models.Conversation, error) {
+		if version != nil {
+			if err := d.checkUnmodified("conversations", conv.ID, *version); err != nil {
+				return nil, err
+			}
+		}
+
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE conversations SET title = ?, response_style = ?, redaction_policy = ?, system_instructions = ?, max_context_messages = ?,
+			language = ?, display_language = ?, openai_organization = ?, openai_project = ?, updated_at = ? WHERE id = ?`,
+			conv.Title, conv.ResponseStyle, conv.RedactionPolicy, conv.SystemInstructions, conv.MaxContextMessages,
+			conv.Language, conv.DisplayLanguage, conv.OpenAIOrganization, conv.OpenAIProject, stamp, conv.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, sql.ErrNoRows
+		}
+
+		row := d.db.QueryRow(
+			`SELECT `+conversationColumns+` FROM conversations WHERE id = ?`,
+			conv.ID,
+		)
+		return scanConversation(row)
+	})
+}
+
 // DeleteConversation deletes a conversation and its messages
 func (d *DB) DeleteConversation(id int64) error {
 	return d.WithLock(func() error {
@@ -91,6 +174,7 @@ func (d *DB) DeleteConversation(id int64) error {
 		if err != nil {
 			return err
 		}
+		d.invalidateParticipants(id)
 
 		rows, err := result.RowsAffected()
 		if err != nil {
@@ -117,50 +201,18 @@ func (d *DB) AddAvatarToConversationWithThreadID(conversationID, avatarID int64,
 			`INSERT OR IGNORE INTO conversation_avatars (conversation_id, avatar_id, thread_id) VALUES (?, ?, ?)`,
 			conversationID, avatarID, threadID,
 		)
+		d.invalidateParticipants(conversationID)
 		return err
 	})
 }
 
 // GetConversationAvatars retrieves all avatars in a conversation
 func (d *DB) GetConversationAvatars(conversationID int64) ([]models.Avatar, error) {
-	return WithLockResult(d, func() ([]models.Avatar, error) {
-		log.Printf("[DB] GetConversationAvatars started conversation_id=%d", conversationID)
-
-		rows, err := d.db.Query(`
-			SELECT a.id, a.name, a.prompt, a.openai_assistant_id, a.created_at
-			FROM avatars a
-			INNER JOIN conversation_avatars ca ON a.id = ca.avatar_id
-			WHERE ca.conversation_id = ?
-		`, conversationID)
-		if err != nil {
-			log.Printf("[DB] GetConversationAvatars failed: query error err=%v", err)
-			return nil, err
-		}
-		defer rows.Close()
-
-		var avatars []models.Avatar
-		for rows.Next() {
-			var avatar models.Avatar
-			var assistantID sql.NullString
-			if err := rows.Scan(&avatar.ID, &avatar.Name, &avatar.Prompt, &assistantID, &avatar.CreatedAt); err != nil {
-				log.Printf("[DB] GetConversationAvatars failed: scan error err=%v", err)
-				return nil, err
-			}
-			if assistantID.Valid {
-				avatar.OpenAIAssistantID = assistantID.String
-			}
-			avatars = append(avatars, avatar)
-		}
-
-		// Log avatar names
-		avatarNames := make([]string, len(avatars))
-		for i, a := range avatars {
-			avatarNames[i] = a.Name
-		}
-		log.Printf("[DB] GetConversationAvatars completed conversation_id=%d count=%d names=%v", conversationID, len(avatars), avatarNames)
-
-		return avatars, rows.Err()
-	})
+	participants, err := d.getConversationParticipants(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return participants.Avatars, nil
 }
 
 // ConversationAvatarsWithThreads represents avatars with their thread IDs
@@ -171,11 +223,25 @@ type ConversationAvatarsWithThreads struct {
 
 // GetConversationAvatarsWithThreads retrieves all avatars in a conversation with their thread IDs
 func (d *DB) GetConversationAvatarsWithThreads(conversationID int64) ([]models.Avatar, []string, error) {
-	result, err := WithLockResult(d, func() (ConversationAvatarsWithThreads, error) {
+	participants, err := d.getConversationParticipants(conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return participants.Avatars, participants.ThreadIDs, nil
+}
+
+// getConversationParticipants retrieves the avatars of a conversation with their thread IDs
+// Results are cached until participation or an avatar changes, or the cache TTL elapses
+func (d *DB) getConversationParticipants(conversationID int64) (ConversationAvatarsWithThreads, error) {
+	if cached, ok := d.participantCache.get(conversationID); ok {
+		return copyParticipants(cached), nil
+	}
+
+	return WithLockResult(d, func() (ConversationAvatarsWithThreads, error) {
 		log.Printf("[DB] GetConversationAvatarsWithThreads started conversation_id=%d", conversationID)
 
 		rows, err := d.db.Query(`
-			SELECT a.id, a.name, a.prompt, a.openai_assistant_id, a.created_at, ca.thread_id
+			SELECT `+avatarColumns+`, ca.thread_id
 			FROM avatars a
 			INNER JOIN conversation_avatars ca ON a.id = ca.avatar_id
 			WHERE ca.conversation_id = ?
@@ -189,39 +255,66 @@ func (d *DB) GetConversationAvatarsWithThreads(conversationID int64) ([]models.A
 		var avatars []models.Avatar
 		var threadIDs []string
 		for rows.Next() {
-			var avatar models.Avatar
-			var assistantID sql.NullString
 			var threadID sql.NullString
-			if err := rows.Scan(&avatar.ID, &avatar.Name, &avatar.Prompt, &assistantID, &avatar.CreatedAt, &threadID); err != nil {
+			avatar, err := scanAvatar(rows, &threadID)
+			if err != nil {
 				log.Printf("[DB] GetConversationAvatarsWithThreads failed: scan error err=%v", err)
 				return ConversationAvatarsWithThreads{}, err
 			}
-			if assistantID.Valid {
-				avatar.OpenAIAssistantID = assistantID.String
-			}
-			avatars = append(avatars, avatar)
+			avatars = append(avatars, *avatar)
 			if threadID.Valid {
 				threadIDs = append(threadIDs, threadID.String)
 			} else {
 				threadIDs = append(threadIDs, "")
 			}
 		}
+		if err := rows.Err(); err != nil {
+			return ConversationAvatarsWithThreads{}, err
+		}
 
-		log.Printf("[DB] GetConversationAvatarsWithThreads completed conversation_id=%d count=%d", conversationID, len(avatars))
+		// Log avatar names
+		avatarNames := make([]string, len(avatars))
+		for i, a := range avatars {
+			avatarNames[i] = a.Name
+		}
+		log.Printf("[DB] GetConversationAvatarsWithThreads completed conversation_id=%d count=%d names=%v", conversationID, len(avatars), avatarNames)
 
-		return ConversationAvatarsWithThreads{
+		participants := ConversationAvatarsWithThreads{
 			Avatars:   avatars,
 			ThreadIDs: threadIDs,
-		}, rows.Err()
+		}
+		d.participantCache.set(conversationID, copyParticipants(participants))
+		return participants, nil
 	})
-	if err != nil {
-		return nil, nil, err
-	}
-	return result.Avatars, result.ThreadIDs, nil
 }
 
 // CreateMessage creates a new message in a conversation
+// The message gets the next sequence number of the conversation in the same transaction
 func (d *DB) CreateMessage(conversationID int64, senderType models.SenderType, senderID *int64, content string) (*models.Message, error) {
+	return d.CreateMessageWithCallback(conversationID, senderType, senderID, content, nil)
+}
+
+// CreateMessageWithCallback is CreateMessage with a callback that receives the message after the commit
+// but before any other database call can read it, like ImportMessagesWithCallback.
+// The callback runs with the database lock held and must not use the database
+func (d *DB) CreateMessageWithCallback(conversationID int64, senderType models.SenderType, senderID *int64, content string, onCommit func(*models.Message)) (*models.Message, error) {
+	return d.createMessage(conversationID, senderType, senderID, content, false, 0, onCommit)
+}
+
+// CreateSilentMessage is CreateMessage for a message watchers pass over without reacting, such as an admin note
+func (d *DB) CreateSilentMessage(conversationID int64, senderType models.SenderType, senderID *int64, content string) (*models.Message, error) {
+	return d.createMessage(conversationID, senderType, senderID, content, true, 0, nil)
+}
+
+// CreateResponseMessage creates an avatar's response to a trigger message
+// The avatar's claim on the trigger message is marked responded in the same transaction, so a crash
+// after the response is saved cannot leave the claim pending and get the message answered again
+func (d *DB) CreateResponseMessage(conversationID, avatarID, triggerMessageID int64, content string) (*models.Message, error) {
+	return d.createMessage(conversationID, models.SenderTypeAvatar, &avatarID, content, false, triggerMessageID, nil)
+}
+
+// createMessage inserts a message; a non-zero respondsTo completes the sender's response claim on that message
+func (d *DB) createMessage(conversationID int64, senderType models.SenderType, senderID *int64, content string, silent bool, respondsTo int64, onCommit func(*models.Message)) (*models.Message, error) {
 	return WithLockResult(d, func() (*models.Message, error) {
 		var senderIDLog any = "nil"
 		if senderID != nil {
@@ -229,9 +322,22 @@ func (d *DB) CreateMessage(conversationID int64, senderType models.SenderType, s
 		}
 		log.Printf("[DB] CreateMessage started conversation_id=%d sender_type=%s sender_id=%v", conversationID, senderType, senderIDLog)
 
-		result, err := d.db.Exec(
-			`INSERT INTO messages (conversation_id, sender_type, sender_id, content) VALUES (?, ?, ?, ?)`,
-			conversationID, string(senderType), senderID, content,
+		tx, err := d.db.Begin()
+		if err != nil {
+			log.Printf("[DB] CreateMessage failed: begin error err=%v", err)
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		sequence, err := nextMessageSequence(tx, conversationID)
+		if err != nil {
+			log.Printf("[DB] CreateMessage failed: sequence error conversation_id=%d err=%v", conversationID, err)
+			return nil, err
+		}
+
+		result, err := tx.Exec(
+			`INSERT INTO messages (conversation_id, sequence, sender_type, sender_id, content, silent) VALUES (?, ?, ?, ?, ?, ?)`,
+			conversationID, sequence, string(senderType), senderID, content, silent,
 		)
 		if err != nil {
 			log.Printf("[DB] CreateMessage failed: exec error err=%v", err)
@@ -244,16 +350,108 @@ func (d *DB) CreateMessage(conversationID int64, senderType models.SenderType, s
 			return nil, err
 		}
 
-		log.Printf("[DB] CreateMessage completed conversation_id=%d message_id=%d sender_type=%s", conversationID, id, senderType)
+		if respondsTo != 0 && senderID != nil {
+			if _, err := tx.Exec(
+				`UPDATE responded_to SET status = ? WHERE avatar_id = ? AND trigger_message_id = ?`,
+				respondedToStatusResponded, *senderID, respondsTo,
+			); err != nil {
+				log.Printf("[DB] CreateMessage failed: complete response error message_id=%d err=%v", respondsTo, err)
+				return nil, err
+			}
+		}
 
-		return &models.Message{
+		if err := tx.Commit(); err != nil {
+			log.Printf("[DB] CreateMessage failed: commit error err=%v", err)
+			return nil, err
+		}
+
+		msg := &models.Message{
 			ID:             id,
 			ConversationID: conversationID,
+			Sequence:       sequence,
 			SenderType:     senderType,
 			SenderID:       senderID,
 			Content:        content,
 			CreatedAt:      time.Now(),
-		}, nil
+			Silent:         silent,
+		}
+		if onCommit != nil {
+			onCommit(msg)
+		}
+
+		log.Printf("[DB] CreateMessage completed conversation_id=%d message_id=%d sequence=%d sender_type=%s",
+			conversationID, id, sequence, senderType)
+		return msg, nil
+	})
+}
+
+// nextMessageSequence increments and returns the message sequence counter of a conversation
+// The counter never goes back, so deleted messages do not free their numbers.
+// Returns sql.ErrNoRows if the conversation does not exist
+func nextMessageSequence(tx *timedTx, conversationID int64) (int64, error) {
+	var sequence int64
+	err := tx.QueryRow(
+		`UPDATE conversations SET message_sequence = message_sequence + 1 WHERE id = ? RETURNING message_sequence`,
+		conversationID,
+	).Scan(&sequence)
+	return sequence, err
+}
+
+// ImportMessages creates messages with their original timestamps in a single transaction
+// The messages are stored as silent, so watchers pass over them. Returns the messages with their new IDs
+func (d *DB) ImportMessages(conversationID int64, messages []models.Message) ([]models.Message, error) {
+	return d.ImportMessagesWithCallback(conversationID, messages, nil)
+}
+
+// ImportMessagesWithCallback is ImportMessages with a callback that receives the imported messages
+// after the commit but before any other database call can read them.
+// The callback runs with the database lock held and must not use the database
+func (d *DB) ImportMessagesWithCallback(conversationID int64, messages []models.Message, onCommit func([]models.Message)) ([]models.Message, error) {
+	return WithLockResult(d, func() ([]models.Message, error) {
+		log.Printf("[DB] ImportMessages started conversation_id=%d count=%d", conversationID, len(messages))
+
+		tx, err := d.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		imported := make([]models.Message, len(messages))
+		for i, msg := range messages {
+			sequence, err := nextMessageSequence(tx, conversationID)
+			if err != nil {
+				log.Printf("[DB] ImportMessages failed: sequence error err=%v", err)
+				return nil, err
+			}
+			result, err := tx.Exec(
+				`INSERT INTO messages (conversation_id, sequence, sender_type, sender_id, content, created_at, silent) VALUES (?, ?, ?, ?, ?, ?, 1)`,
+				conversationID, sequence, string(msg.SenderType), msg.SenderID, msg.Content, msg.CreatedAt.UTC().Format(sqliteTimeFormat),
+			)
+			if err != nil {
+				log.Printf("[DB] ImportMessages failed: exec error err=%v", err)
+				return nil, err
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+
+			imported[i] = msg
+			imported[i].ID = id
+			imported[i].ConversationID = conversationID
+			imported[i].Sequence = sequence
+			imported[i].Silent = true
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		if onCommit != nil {
+			onCommit(imported)
+		}
+
+		log.Printf("[DB] ImportMessages completed conversation_id=%d count=%d", conversationID, len(imported))
+		return imported, nil
 	})
 }
 
@@ -261,8 +459,8 @@ func (d *DB) CreateMessage(conversationID int64, senderType models.SenderType, s
 func (d *DB) GetMessages(conversationID int64) ([]models.Message, error) {
 	return WithLockResult(d, func() ([]models.Message, error) {
 		rows, err := d.db.Query(
-			`SELECT id, conversation_id, sender_type, sender_id, content, created_at 
-			FROM messages WHERE conversation_id = ? ORDER BY created_at ASC`,
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages WHERE conversation_id = ? ORDER BY sequence ASC`,
 			conversationID,
 		)
 		if err != nil {
@@ -275,7 +473,7 @@ func (d *DB) GetMessages(conversationID int64) ([]models.Message, error) {
 			var msg models.Message
 			var senderID sql.NullInt64
 			var senderType string
-			if err := rows.Scan(&msg.ID, &msg.ConversationID, &senderType, &senderID, &msg.Content, &msg.CreatedAt); err != nil {
+			if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent); err != nil {
 				return nil, err
 			}
 			msg.SenderType = models.SenderType(senderType)
@@ -290,6 +488,31 @@ func (d *DB) GetMessages(conversationID int64) ([]models.Message, error) {
 	})
 }
 
+// GetAvatarConversationIDs returns the IDs of the conversations an avatar takes part in
+func (d *DB) GetAvatarConversationIDs(avatarID int64) ([]int64, error) {
+	return WithLockResult(d, func() ([]int64, error) {
+		rows, err := d.db.Query(
+			`SELECT conversation_id FROM conversation_avatars WHERE avatar_id = ? ORDER BY conversation_id`,
+			avatarID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+
+		return ids, rows.Err()
+	})
+}
+
 // RemoveAvatarFromConversation removes an avatar from a conversation
 func (d *DB) RemoveAvatarFromConversation(conversationID, avatarID int64) error {
 	return d.WithLock(func() error {
@@ -299,6 +522,7 @@ func (d *DB) RemoveAvatarFromConversation(conversationID, avatarID int64) error
 			`DELETE FROM conversation_avatars WHERE conversation_id = ? AND avatar_id = ?`,
 			conversationID, avatarID,
 		)
+		d.invalidateParticipants(conversationID)
 		if err != nil {
 			log.Printf("[DB] RemoveAvatarFromConversation failed: exec error err=%v", err)
 			return err
@@ -315,6 +539,15 @@ func (d *DB) RemoveAvatarFromConversation(conversationID, avatarID int64) error
 			return sql.ErrNoRows
 		}
 
+		// Triggers belong to the avatar's place in the conversation
+		if _, err := d.db.Exec(
+			`DELETE FROM avatar_triggers WHERE conversation_id = ? AND avatar_id = ?`,
+			conversationID, avatarID,
+		); err != nil {
+			log.Printf("[DB] RemoveAvatarFromConversation failed: delete triggers error err=%v", err)
+			return err
+		}
+
 		log.Printf("[DB] RemoveAvatarFromConversation completed conversation_id=%d avatar_id=%d", conversationID, avatarID)
 		return nil
 	})
@@ -324,8 +557,8 @@ func (d *DB) RemoveAvatarFromConversation(conversationID, avatarID int64) error
 func (d *DB) GetMessagesAfter(conversationID int64, afterID int64) ([]models.Message, error) {
 	return WithLockResult(d, func() ([]models.Message, error) {
 		rows, err := d.db.Query(
-			`SELECT id, conversation_id, sender_type, sender_id, content, created_at 
-			FROM messages 
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages
 			WHERE conversation_id = ? AND id > ?
 			ORDER BY id ASC`,
 			conversationID, afterID,
@@ -340,7 +573,90 @@ func (d *DB) GetMessagesAfter(conversationID int64, afterID int64) ([]models.Mes
 			var msg models.Message
 			var senderID sql.NullInt64
 			var senderType string
-			if err := rows.Scan(&msg.ID, &msg.ConversationID, &senderType, &senderID, &msg.Content, &msg.CreatedAt); err != nil {
+			if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent); err != nil {
+				return nil, err
+			}
+			msg.SenderType = models.SenderType(senderType)
+			if senderID.Valid {
+				id := senderID.Int64
+				msg.SenderID = &id
+			}
+			messages = append(messages, msg)
+		}
+
+		return messages, rows.Err()
+	})
+}
+
+// GetMessagesAfterSequence retrieves up to limit messages with a sequence number greater than the given one,
+// in order. A limit of 0 or less returns all of them
+func (d *DB) GetMessagesAfterSequence(conversationID int64, afterSequence int64, limit int) ([]models.Message, error) {
+	if limit <= 0 {
+		limit = -1 // SQLite reads a negative LIMIT as no limit
+	}
+	return WithLockResult(d, func() ([]models.Message, error) {
+		rows, err := d.db.Query(
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages
+			WHERE conversation_id = ? AND sequence > ?
+			ORDER BY sequence ASC LIMIT ?`,
+			conversationID, afterSequence, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var messages []models.Message
+		for rows.Next() {
+			var msg models.Message
+			var senderID sql.NullInt64
+			var senderType string
+			if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent); err != nil {
+				return nil, err
+			}
+			msg.SenderType = models.SenderType(senderType)
+			if senderID.Valid {
+				id := senderID.Int64
+				msg.SenderID = &id
+			}
+			messages = append(messages, msg)
+		}
+
+		return messages, rows.Err()
+	})
+}
+
+// GetMessagesAround retrieves up to before messages preceding the message with the given sequence number,
+// that message itself and up to after messages following it, in order
+func (d *DB) GetMessagesAround(conversationID, sequence int64, before, after int) ([]models.Message, error) {
+	return WithLockResult(d, func() ([]models.Message, error) {
+		rows, err := d.db.Query(
+			`SELECT * FROM (
+				SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+				FROM messages WHERE conversation_id = ? AND sequence < ?
+				ORDER BY sequence DESC LIMIT ?
+			)
+			UNION ALL
+			SELECT * FROM (
+				SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+				FROM messages WHERE conversation_id = ? AND sequence >= ?
+				ORDER BY sequence ASC LIMIT ?
+			)
+			ORDER BY sequence ASC`,
+			conversationID, sequence, before, conversationID, sequence, after+1,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var messages []models.Message
+		for rows.Next() {
+			var msg models.Message
+			var senderID sql.NullInt64
+			var senderType string
+			if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent); err != nil {
 				return nil, err
 			}
 			msg.SenderType = models.SenderType(senderType)
@@ -355,6 +671,103 @@ func (d *DB) GetMessagesAfter(conversationID int64, afterID int64) ([]models.Mes
 	})
 }
 
+// GetRecentMessages retrieves up to limit of the latest messages of a conversation, newest first
+func (d *DB) GetRecentMessages(conversationID int64, limit int) ([]models.Message, error) {
+	return WithLockResult(d, func() ([]models.Message, error) {
+		rows, err := d.db.Query(
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages WHERE conversation_id = ?
+			ORDER BY sequence DESC LIMIT ?`,
+			conversationID, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var messages []models.Message
+		for rows.Next() {
+			var msg models.Message
+			var senderID sql.NullInt64
+			var senderType string
+			if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent); err != nil {
+				return nil, err
+			}
+			msg.SenderType = models.SenderType(senderType)
+			if senderID.Valid {
+				id := senderID.Int64
+				msg.SenderID = &id
+			}
+			messages = append(messages, msg)
+		}
+
+		return messages, rows.Err()
+	})
+}
+
+// GetLatestSequence returns the sequence number of the newest message of a conversation, 0 if it has none
+// The query is answered from the (conversation_id, sequence) index alone, so polling it reads no message rows
+func (d *DB) GetLatestSequence(conversationID int64) (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		var sequence int64
+		err := d.db.QueryRow(
+			`SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE conversation_id = ?`,
+			conversationID,
+		).Scan(&sequence)
+		return sequence, err
+	})
+}
+
+// GetLastMessage retrieves the most recent message of a conversation
+// Returns sql.ErrNoRows if the conversation has no messages
+func (d *DB) GetLastMessage(conversationID int64) (*models.Message, error) {
+	return WithLockResult(d, func() (*models.Message, error) {
+		var msg models.Message
+		var senderID sql.NullInt64
+		var senderType string
+		err := d.db.QueryRow(
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages WHERE conversation_id = ?
+			ORDER BY sequence DESC LIMIT 1`,
+			conversationID,
+		).Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.SenderType = models.SenderType(senderType)
+		if senderID.Valid {
+			id := senderID.Int64
+			msg.SenderID = &id
+		}
+		return &msg, nil
+	})
+}
+
+// GetMessage retrieves a message of a conversation by ID
+func (d *DB) GetMessage(conversationID, id int64) (*models.Message, error) {
+	return WithLockResult(d, func() (*models.Message, error) {
+		var msg models.Message
+		var senderID sql.NullInt64
+		var senderType string
+		err := d.db.QueryRow(
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages WHERE conversation_id = ? AND id = ?`,
+			conversationID, id,
+		).Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.SenderType = models.SenderType(senderType)
+		if senderID.Valid {
+			id := senderID.Int64
+			msg.SenderID = &id
+		}
+		return &msg, nil
+	})
+}
+
 // GetAllConversationAvatars retrieves all conversation-avatar pairs
 func (d *DB) GetAllConversationAvatars() ([]models.ConversationAvatar, error) {
 	return WithLockResult(d, func() ([]models.ConversationAvatar, error) {
@@ -389,7 +802,17 @@ func (d *DB) GetAllConversationAvatars() ([]models.ConversationAvatar, error) {
 }
 
 // GetAvatarThreadID retrieves the thread ID for a specific avatar in a conversation
+// Returns sql.ErrNoRows if the avatar is not in the conversation
 func (d *DB) GetAvatarThreadID(conversationID, avatarID int64) (string, error) {
+	if cached, ok := d.participantCache.get(conversationID); ok {
+		for i, a := range cached.Avatars {
+			if a.ID == avatarID {
+				return cached.ThreadIDs[i], nil
+			}
+		}
+		return "", sql.ErrNoRows
+	}
+
 	return WithLockResult(d, func() (string, error) {
 		var threadID sql.NullString
 		err := d.db.QueryRow(
@@ -413,6 +836,7 @@ func (d *DB) UpdateAvatarThreadID(conversationID, avatarID int64, threadID strin
 			`UPDATE conversation_avatars SET thread_id = ? WHERE conversation_id = ? AND avatar_id = ?`,
 			threadID, conversationID, avatarID,
 		)
+		d.invalidateParticipants(conversationID)
 		return err
 	})
 }
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateConversationEvent records a broadcast event in a conversation's activity timeline
+// data is the JSON-encoded event payload
+func (d *DB) CreateConversationEvent(conversationID int64, eventType, data string) (*models.ConversationEvent, error) {
+	return WithLockResult(d, func() (*models.ConversationEvent, error) {
+		log.Printf("[DB] CreateConversationEvent started conversation_id=%d type=%s", conversationID, eventType)
+
+		result, err := d.db.Exec(
+			`INSERT INTO conversation_events (conversation_id, event_type, data) VALUES (?, ?, ?)`,
+			conversationID, eventType, data,
+		)
+		if err != nil {
+			log.Printf("[DB] CreateConversationEvent failed: exec error err=%v", err)
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			log.Printf("[DB] CreateConversationEvent failed: get last insert id err=%v", err)
+			return nil, err
+		}
+
+		var event models.ConversationEvent
+		err = d.db.QueryRow(
+			`SELECT id, conversation_id, event_type, data, created_at FROM conversation_events WHERE id = ?`,
+			id,
+		).Scan(&event.ID, &event.ConversationID, &event.EventType, &event.Data, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[DB] CreateConversationEvent completed event_id=%d conversation_id=%d", id, conversationID)
+		return &event, nil
+	})
+}
+
+// GetConversationEvents retrieves recorded events of a conversation with an ID greater than afterID
+// Events are returned oldest first, at most limit rows
+func (d *DB) GetConversationEvents(conversationID, afterID int64, limit int) ([]models.ConversationEvent, error) {
+	return WithLockResult(d, func() ([]models.ConversationEvent, error) {
+		rows, err := d.db.Query(
+			`SELECT id, conversation_id, event_type, data, created_at
+			FROM conversation_events
+			WHERE conversation_id = ? AND id > ?
+			ORDER BY id ASC LIMIT ?`,
+			conversationID, afterID, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		events := []models.ConversationEvent{}
+		for rows.Next() {
+			var event models.ConversationEvent
+			if err := rows.Scan(&event.ID, &event.ConversationID, &event.EventType, &event.Data, &event.CreatedAt); err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		return events, rows.Err()
+	})
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestConversationEvents(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	other, _ := db.CreateConversation("Other", "")
+
+	first, err := db.CreateConversationEvent(conv.ID, "avatar_joined", `{"avatar_id":1}`)
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	if _, err := db.CreateConversationEvent(other.ID, "interrupt", `{}`); err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	second, _ := db.CreateConversationEvent(conv.ID, "avatar_left", `{"avatar_id":1}`)
+
+	events, err := db.GetConversationEvents(conv.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("failed to get events: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != first.ID || events[1].ID != second.ID {
+		t.Fatalf("expected events [%d %d] in order, got %+v", first.ID, second.ID, events)
+	}
+	if events[0].EventType != "avatar_joined" || events[0].Data != `{"avatar_id":1}` {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+
+	after, _ := db.GetConversationEvents(conv.ID, first.ID, 10)
+	if len(after) != 1 || after[0].ID != second.ID {
+		t.Errorf("expected only the second event after %d, got %+v", first.ID, after)
+	}
+
+	limited, _ := db.GetConversationEvents(conv.ID, 0, 1)
+	if len(limited) != 1 || limited[0].ID != first.ID {
+		t.Errorf("expected limit to return the oldest event, got %+v", limited)
+	}
+}
+
+func TestConversationEvents_DeletedWithConversation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	db.CreateConversationEvent(conv.ID, "interrupt", `{}`)
+
+	if err := db.DeleteConversation(conv.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+
+	events, err := db.GetConversationEvents(conv.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("failed to get events: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected events to be deleted with the conversation, got %d", len(events))
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// SetConversationExpiry sets when a conversation is deleted by the expiry job; nil removes the expiry
+func (d *DB) SetConversationExpiry(id int64, expiresAt *time.Time) (*models.Conversation, error) {
+	return WithLockResult(d, func() (*models.Conversation, error) {
+		var value any
+		if expiresAt != nil {
+			value = expiresAt.UTC().Format(sqliteTimeFormat)
+		}
+
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE conversations SET expires_at = ?, updated_at = ? WHERE id = ?`,
+			value, stamp, id,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, sql.ErrNoRows
+		}
+
+		row := d.db.QueryRow(`SELECT `+conversationColumns+` FROM conversations WHERE id = ?`, id)
+		return scanConversation(row)
+	})
+}
+
+// GetExpiredConversations returns the conversations whose expiry is at or before now, oldest expiry first
+func (d *DB) GetExpiredConversations(now time.Time) ([]models.Conversation, error) {
+	return WithLockResult(d, func() ([]models.Conversation, error) {
+		rows, err := d.db.Query(
+			`SELECT `+conversationColumns+` FROM conversations
+			WHERE expires_at IS NOT NULL AND expires_at <= ? ORDER BY expires_at, id`,
+			now.UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var conversations []models.Conversation
+		for rows.Next() {
+			conv, err := scanConversation(rows)
+			if err != nil {
+				return nil, err
+			}
+			conversations = append(conversations, *conv)
+		}
+		return conversations, rows.Err()
+	})
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestConversationExpiry(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	expired, _ := db.CreateConversation("Expired", "")
+	later, _ := db.CreateConversation("Later", "")
+	kept, _ := db.CreateConversation("Kept", "")
+
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	updated, err := db.SetConversationExpiry(expired.ID, &past)
+	if err != nil {
+		t.Fatalf("failed to set expiry: %v", err)
+	}
+	if updated.ExpiresAt == nil || !updated.ExpiresAt.Equal(past.UTC().Truncate(time.Second)) {
+		t.Errorf("expected expires_at %v, got %v", past, updated.ExpiresAt)
+	}
+	if !updated.UpdatedAt.After(expired.UpdatedAt) {
+		t.Error("expected setting the expiry to bump updated_at")
+	}
+
+	future := now.Add(time.Hour)
+	if _, err := db.SetConversationExpiry(later.ID, &future); err != nil {
+		t.Fatalf("failed to set expiry: %v", err)
+	}
+
+	conversations, err := db.GetExpiredConversations(now)
+	if err != nil {
+		t.Fatalf("failed to get expired conversations: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].ID != expired.ID {
+		t.Fatalf("expected only the expired conversation, got %+v", conversations)
+	}
+
+	// Removing the expiry keeps the conversation
+	cleared, err := db.SetConversationExpiry(expired.ID, nil)
+	if err != nil {
+		t.Fatalf("failed to clear expiry: %v", err)
+	}
+	if cleared.ExpiresAt != nil {
+		t.Errorf("expected no expiry, got %v", cleared.ExpiresAt)
+	}
+	if conversations, _ := db.GetExpiredConversations(now.Add(2 * time.Hour)); len(conversations) != 1 || conversations[0].ID != later.ID {
+		t.Errorf("expected only the later conversation, got %+v", conversations)
+	}
+
+	got, _ := db.GetConversation(kept.ID)
+	if got.ExpiresAt != nil {
+		t.Errorf("expected new conversations to have no expiry, got %v", got.ExpiresAt)
+	}
+
+	if _, err := db.SetConversationExpiry(9999, &future); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing conversation, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// RecordConversationReferences stores links for every "conversation #N" reference in a message
+// References to the message's own conversation or to unknown conversations are ignored
+// Returns the IDs of the conversations that were linked
+func (d *DB) RecordConversationReferences(msg *models.Message) ([]int64, error) {
+	targetIDs := logic.ParseConversationReferences(msg.Content)
+	if len(targetIDs) == 0 {
+		return nil, nil
+	}
+
+	return WithLockResult(d, func() ([]int64, error) {
+		log.Printf("[DB] RecordConversationReferences started message_id=%d conversation_id=%d targets=%v",
+			msg.ID, msg.ConversationID, targetIDs)
+
+		var linked []int64
+		for _, targetID := range targetIDs {
+			if targetID == msg.ConversationID {
+				continue
+			}
+
+			result, err := d.db.Exec(`
+				INSERT OR IGNORE INTO conversation_links (message_id, source_conversation_id, target_conversation_id)
+				SELECT ?, ?, id FROM conversations WHERE id = ?
+			`, msg.ID, msg.ConversationID, targetID)
+			if err != nil {
+				log.Printf("[DB] RecordConversationReferences failed: exec error err=%v", err)
+				return nil, err
+			}
+
+			if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+				linked = append(linked, targetID)
+			}
+		}
+
+		log.Printf("[DB] RecordConversationReferences completed message_id=%d linked=%v", msg.ID, linked)
+		return linked, nil
+	})
+}
+
+// GetConversationBacklinks retrieves the messages in other conversations that reference a conversation
+func (d *DB) GetConversationBacklinks(conversationID int64) ([]models.ConversationLink, error) {
+	return WithLockResult(d, func() ([]models.ConversationLink, error) {
+		rows, err := d.db.Query(`
+			SELECT l.message_id, l.source_conversation_id, c.title, l.target_conversation_id, l.created_at
+			FROM conversation_links l
+			INNER JOIN conversations c ON c.id = l.source_conversation_id
+			WHERE l.target_conversation_id = ?
+			ORDER BY l.created_at DESC, l.message_id DESC
+		`, conversationID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var links []models.ConversationLink
+		for rows.Next() {
+			var link models.ConversationLink
+			if err := rows.Scan(&link.MessageID, &link.SourceConversationID, &link.SourceTitle,
+				&link.TargetConversationID, &link.CreatedAt); err != nil {
+				return nil, err
+			}
+			links = append(links, link)
+		}
+
+		return links, rows.Err()
+	})
+}
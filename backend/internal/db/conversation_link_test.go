@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestRecordConversationReferences(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	target, _ := db.CreateConversation("Planning", "")
+	source, _ := db.CreateConversation("Follow-up", "")
+
+	content := fmt.Sprintf("see conversation #%d, conversation #%d and conversation #999", target.ID, source.ID)
+	msg, err := db.CreateMessage(source.ID, models.SenderTypeUser, nil, content)
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+
+	linked, err := db.RecordConversationReferences(msg)
+	if err != nil {
+		t.Fatalf("failed to record references: %v", err)
+	}
+
+	// Self references and unknown conversations are ignored
+	if len(linked) != 1 || linked[0] != target.ID {
+		t.Errorf("expected only target to be linked, got %v", linked)
+	}
+
+	// Recording twice does not duplicate links
+	db.RecordConversationReferences(msg)
+
+	backlinks, err := db.GetConversationBacklinks(target.ID)
+	if err != nil {
+		t.Fatalf("failed to get backlinks: %v", err)
+	}
+	if len(backlinks) != 1 {
+		t.Fatalf("expected 1 backlink, got %d", len(backlinks))
+	}
+	if backlinks[0].SourceConversationID != source.ID || backlinks[0].SourceTitle != "Follow-up" {
+		t.Errorf("unexpected backlink: %+v", backlinks[0])
+	}
+	if backlinks[0].MessageID != msg.ID {
+		t.Errorf("expected message_id %d, got %d", msg.ID, backlinks[0].MessageID)
+	}
+}
+
+func TestGetConversationBacklinks_RemovedWithSource(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	target, _ := db.CreateConversation("Planning", "")
+	source, _ := db.CreateConversation("Follow-up", "")
+
+	msg, _ := db.CreateMessage(source.ID, models.SenderTypeUser, nil, fmt.Sprintf("conversation #%d", target.ID))
+	db.RecordConversationReferences(msg)
+
+	if err := db.DeleteConversation(source.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+
+	backlinks, _ := db.GetConversationBacklinks(target.ID)
+	if len(backlinks) != 0 {
+		t.Errorf("expected backlinks to be removed with source conversation, got %d", len(backlinks))
+	}
+}
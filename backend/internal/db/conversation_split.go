@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// splitMovedTables are the tables whose rows belong to a message's conversation and move with the message
+// Each entry is the table, its conversation column and the column that points to the message
+var splitMovedTables = []struct{ table, conversationColumn, messageColumn string }{
+	{"conversation_links", "source_conversation_id", "message_id"},
+	{"conversation_digests", "conversation_id", "message_id"},
+	{"redactions", "conversation_id", "message_id"},
+	{"responded_to", "conversation_id", "trigger_message_id"},
+	{"response_comparisons", "conversation_id", "trigger_message_id"},
+	{"prompt_trials", "conversation_id", "trigger_message_id"},
+}
+
+// MoveMessagesFrom moves the messages of a conversation from the given sequence number on into another conversation
+// The moved messages keep their IDs and are numbered after the messages of the target in their original order.
+// Returns the moved messages in order, with their new sequence numbers
+func (d *DB) MoveMessagesFrom(conversationID, sequence, targetID int64) ([]models.Message, error) {
+	return WithLockResult(d, func() ([]models.Message, error) {
+		log.Printf("[DB] MoveMessagesFrom started conversation_id=%d sequence=%d target_id=%d", conversationID, sequence, targetID)
+
+		tx, err := d.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		rows, err := tx.Query(
+			`SELECT id, conversation_id, sequence, sender_type, sender_id, content, created_at, silent
+			FROM messages WHERE conversation_id = ? AND sequence >= ? ORDER BY sequence ASC`,
+			conversationID, sequence,
+		)
+		if err != nil {
+			return nil, err
+		}
+		var moved []models.Message
+		for rows.Next() {
+			var msg models.Message
+			var senderID sql.NullInt64
+			var senderType string
+			if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sequence, &senderType, &senderID, &msg.Content, &msg.CreatedAt, &msg.Silent); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			msg.SenderType = models.SenderType(senderType)
+			if senderID.Valid {
+				id := senderID.Int64
+				msg.SenderID = &id
+			}
+			moved = append(moved, msg)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for i := range moved {
+			next, err := nextMessageSequence(tx, targetID)
+			if err != nil {
+				log.Printf("[DB] MoveMessagesFrom failed: sequence error target_id=%d err=%v", targetID, err)
+				return nil, err
+			}
+			if _, err := tx.Exec(
+				`UPDATE messages SET conversation_id = ?, sequence = ? WHERE id = ?`,
+				targetID, next, moved[i].ID,
+			); err != nil {
+				log.Printf("[DB] MoveMessagesFrom failed: exec error message_id=%d err=%v", moved[i].ID, err)
+				return nil, err
+			}
+			for _, t := range splitMovedTables {
+				if _, err := tx.Exec(
+					`UPDATE `+t.table+` SET `+t.conversationColumn+` = ? WHERE `+t.messageColumn+` = ?`,
+					targetID, moved[i].ID,
+				); err != nil {
+					log.Printf("[DB] MoveMessagesFrom failed: exec error table=%s message_id=%d err=%v", t.table, moved[i].ID, err)
+					return nil, err
+				}
+			}
+			moved[i].ConversationID = targetID
+			moved[i].Sequence = next
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		log.Printf("[DB] MoveMessagesFrom completed conversation_id=%d target_id=%d count=%d", conversationID, targetID, len(moved))
+		return moved, nil
+	})
+}
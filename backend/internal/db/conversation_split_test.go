@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestMoveMessagesFrom(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	source, _ := db.CreateConversation("Planning", "")
+	other, _ := db.CreateConversation("Roadmap", "")
+	target, _ := db.CreateConversation("Hiring", "")
+
+	db.CreateMessage(source.ID, models.SenderTypeUser, nil, "When do we release?")
+	db.CreateMessage(source.ID, models.SenderTypeUser, nil, "Friday")
+	first, _ := db.CreateMessage(source.ID, models.SenderTypeUser, nil, "Unrelated: we need to hire")
+	reference, _ := db.CreateMessage(source.ID, models.SenderTypeUser, nil, fmt.Sprintf("See conversation #%d", other.ID))
+	db.RecordConversationReferences(reference)
+	db.CreateRedactions(source.ID, reference.ID, "regex", map[string]int{"email": 1})
+
+	moved, err := db.MoveMessagesFrom(source.ID, first.Sequence, target.ID)
+	if err != nil {
+		t.Fatalf("failed to move messages: %v", err)
+	}
+	if len(moved) != 2 || moved[0].ID != first.ID || moved[0].Sequence != 1 || moved[1].Sequence != 2 {
+		t.Fatalf("unexpected moved messages: %+v", moved)
+	}
+
+	remaining, _ := db.GetMessages(source.ID)
+	if len(remaining) != 2 {
+		t.Errorf("expected 2 messages to remain, got %d", len(remaining))
+	}
+	messages, _ := db.GetMessages(target.ID)
+	if len(messages) != 2 || messages[0].Content != "Unrelated: we need to hire" {
+		t.Errorf("unexpected messages in the target: %+v", messages)
+	}
+
+	// Rows tied to the moved messages move with them
+	links, _ := db.GetConversationBacklinks(other.ID)
+	if len(links) != 1 || links[0].SourceConversationID != target.ID {
+		t.Errorf("expected the backlink to come from the target, got %+v", links)
+	}
+	if redactions, _ := db.GetRedactions(target.ID, 10); len(redactions) != 1 {
+		t.Errorf("expected the redaction to move, got %+v", redactions)
+	}
+
+	// Numbers of moved messages are not reused in the source
+	next, _ := db.CreateMessage(source.ID, models.SenderTypeUser, nil, "Back to the release")
+	if next.Sequence != 5 {
+		t.Errorf("expected sequence 5 in the source, got %d", next.Sequence)
+	}
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
 
 	"multi-avatar-chat/internal/models"
@@ -79,6 +81,32 @@ func TestGetAllConversations(t *testing.T) {
 	}
 }
 
+func TestUpdateConversation_UpdatedAt(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Chat", "")
+	version := conv.UpdatedAt
+
+	conv.Title = "Renamed"
+	updated, err := db.UpdateConversationIfUnmodified(conv, version)
+	if err != nil {
+		t.Fatalf("expected conditional update to succeed, got %v", err)
+	}
+	if !updated.UpdatedAt.After(version) {
+		t.Errorf("expected updated_at to move forward, got %v then %v", version, updated.UpdatedAt)
+	}
+
+	conv.Title = "Lost"
+	if _, err := db.UpdateConversationIfUnmodified(conv, version); err != ErrModified {
+		t.Errorf("expected ErrModified for a stale version, got %v", err)
+	}
+	got, _ := db.GetConversation(conv.ID)
+	if got.Title != "Renamed" || !got.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("expected the first update to be kept, got %+v", got)
+	}
+}
+
 func TestDeleteConversation(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -348,6 +376,60 @@ func TestGetMessagesAfter(t *testing.T) {
 	}
 }
 
+func TestGetMessagesAround(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Around Test", "")
+	other, _ := db.CreateConversation("Other", "")
+	for i := 1; i <= 6; i++ {
+		db.CreateMessage(conv.ID, models.SenderTypeUser, nil, fmt.Sprintf("Message %d", i))
+		db.CreateMessage(other.ID, models.SenderTypeUser, nil, "Other")
+	}
+
+	messages, err := db.GetMessagesAround(conv.ID, 3, 1, 2)
+	if err != nil {
+		t.Fatalf("failed to get messages around: %v", err)
+	}
+	var sequences []int64
+	for _, msg := range messages {
+		sequences = append(sequences, msg.Sequence)
+	}
+	if want := []int64{2, 3, 4, 5}; fmt.Sprint(sequences) != fmt.Sprint(want) {
+		t.Errorf("expected sequences %v, got %v", want, sequences)
+	}
+
+	// Edges of the conversation return fewer messages
+	messages, _ = db.GetMessagesAround(conv.ID, 1, 5, 0)
+	if len(messages) != 1 || messages[0].Content != "Message 1" {
+		t.Errorf("expected only the first message, got %+v", messages)
+	}
+}
+
+func TestGetRecentMessages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Recent Test", "")
+	other, _ := db.CreateConversation("Other", "")
+	for i := 1; i <= 5; i++ {
+		db.CreateMessage(conv.ID, models.SenderTypeUser, nil, fmt.Sprintf("Message %d", i))
+		db.CreateMessage(other.ID, models.SenderTypeUser, nil, "Other")
+	}
+
+	messages, err := db.GetRecentMessages(conv.ID, 3)
+	if err != nil {
+		t.Fatalf("failed to get recent messages: %v", err)
+	}
+	var sequences []int64
+	for _, msg := range messages {
+		sequences = append(sequences, msg.Sequence)
+	}
+	if want := []int64{5, 4, 3}; fmt.Sprint(sequences) != fmt.Sprint(want) {
+		t.Errorf("expected sequences %v, got %v", want, sequences)
+	}
+}
+
 func TestGetMessagesAfter_NoMessages(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -426,3 +508,244 @@ func TestGetAllConversationAvatars_Empty(t *testing.T) {
 	}
 }
 
+
+func TestCreateConversation_DefaultResponseStyle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, err := db.CreateConversation("Style Test", "")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	conv, err := db.GetConversation(created.ID)
+	if err != nil {
+		t.Fatalf("failed to get conversation: %v", err)
+	}
+
+	if conv.ResponseStyle != "normal" {
+		t.Errorf("expected default response_style 'normal', got '%s'", conv.ResponseStyle)
+	}
+}
+
+func TestUpdateConversation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, err := db.CreateConversationWithStyle("Before", "", "detailed")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	if created.ResponseStyle != "detailed" {
+		t.Errorf("expected response_style 'detailed', got '%s'", created.ResponseStyle)
+	}
+
+	created.Title = "After"
+	created.ResponseStyle = "brief"
+	created.SystemInstructions = "Speak in formal English."
+	updated, err := db.UpdateConversation(created)
+	if err != nil {
+		t.Fatalf("failed to update conversation: %v", err)
+	}
+
+	if updated.Title != "After" {
+		t.Errorf("expected title 'After', got '%s'", updated.Title)
+	}
+	if updated.ResponseStyle != "brief" {
+		t.Errorf("expected response_style 'brief', got '%s'", updated.ResponseStyle)
+	}
+	if updated.SystemInstructions != "Speak in formal English." {
+		t.Errorf("expected system_instructions to be saved, got '%s'", updated.SystemInstructions)
+	}
+}
+
+func TestUpdateConversation_NotFound(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := db.UpdateConversation(&models.Conversation{ID: 99999, Title: "Missing", ResponseStyle: "normal"})
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestCreateMessage_AssignsSequences(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv1, _ := db.CreateConversation("First", "")
+	conv2, _ := db.CreateConversation("Second", "")
+
+	a, _ := db.CreateMessage(conv1.ID, models.SenderTypeUser, nil, "a")
+	b, _ := db.CreateMessage(conv2.ID, models.SenderTypeUser, nil, "b")
+	c, _ := db.CreateMessage(conv1.ID, models.SenderTypeUser, nil, "c secret")
+
+	// Each conversation is numbered separately
+	if a.Sequence != 1 || b.Sequence != 1 || c.Sequence != 2 {
+		t.Fatalf("unexpected sequences a=%d b=%d c=%d", a.Sequence, b.Sequence, c.Sequence)
+	}
+
+	// Deleting the latest message does not free its number
+	if _, err := db.DeleteContent("secret"); err != nil {
+		t.Fatalf("failed to delete content: %v", err)
+	}
+	d, err := db.CreateMessage(conv1.ID, models.SenderTypeUser, nil, "d")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	if d.Sequence != 3 {
+		t.Errorf("expected sequence 3 after deleting the latest message, got %d", d.Sequence)
+	}
+
+	stored, err := db.GetMessage(conv1.ID, d.ID)
+	if err != nil || stored.Sequence != d.Sequence {
+		t.Errorf("expected stored sequence %d, got %+v err=%v", d.Sequence, stored, err)
+	}
+
+	if _, err := db.CreateMessage(99999, models.SenderTypeUser, nil, "orphan"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing conversation, got %v", err)
+	}
+}
+
+func TestCreateMessageWithCallback(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Callback", "")
+
+	var seen *models.Message
+	msg, err := db.CreateMessageWithCallback(conv.ID, models.SenderTypeUser, nil, "hello", func(m *models.Message) {
+		seen = m
+	})
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	if seen == nil || seen.ID != msg.ID || seen.Sequence != 1 {
+		t.Errorf("expected the callback to receive the message, got %+v", seen)
+	}
+
+	seen = nil
+	if _, err := db.CreateMessageWithCallback(99999, models.SenderTypeUser, nil, "orphan", func(m *models.Message) {
+		seen = m
+	}); err == nil {
+		t.Error("expected an error for a missing conversation")
+	}
+	if seen != nil {
+		t.Error("expected the callback not to run when the insert fails")
+	}
+}
+
+func TestImportMessagesWithCallback(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Imported", "")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "existing")
+
+	var seen []models.Message
+	imported, err := db.ImportMessagesWithCallback(conv.ID, []models.Message{
+		{SenderType: models.SenderTypeUser, Content: "first"},
+		{SenderType: models.SenderTypeSystem, Content: "second"},
+	}, func(messages []models.Message) {
+		seen = messages
+	})
+	if err != nil {
+		t.Fatalf("failed to import messages: %v", err)
+	}
+
+	if len(imported) != 2 || imported[0].Sequence != 2 || imported[1].Sequence != 3 {
+		t.Fatalf("expected sequences 2 and 3 after the existing message, got %+v", imported)
+	}
+	if len(seen) != 2 || seen[0].ID != imported[0].ID || seen[1].ID != imported[1].ID {
+		t.Errorf("expected the callback to receive the imported messages, got %+v", seen)
+	}
+
+	seen = nil
+	if _, err := db.ImportMessagesWithCallback(99999, []models.Message{{SenderType: models.SenderTypeUser, Content: "orphan"}},
+		func(messages []models.Message) { seen = messages }); err == nil {
+		t.Error("expected an error for a missing conversation")
+	}
+	if seen != nil {
+		t.Error("expected the callback not to run when the import fails")
+	}
+}
+
+func TestGetMessagesAfterSequence(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Sequence Test", "")
+	first, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Message 1")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Message 2")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Message 3")
+
+	messages, err := db.GetMessagesAfterSequence(conv.ID, first.Sequence, 0)
+	if err != nil {
+		t.Fatalf("failed to get messages after sequence: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Sequence != 2 || messages[1].Sequence != 3 {
+		t.Errorf("expected messages 2 and 3 in order, got %+v", messages)
+	}
+
+	// A limit returns the oldest messages of the window
+	messages, err = db.GetMessagesAfterSequence(conv.ID, 0, 2)
+	if err != nil {
+		t.Fatalf("failed to get messages after sequence: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Sequence != 1 || messages[1].Sequence != 2 {
+		t.Errorf("expected messages 1 and 2 in order, got %+v", messages)
+	}
+}
+
+func TestGetLatestSequence(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Sequence Test", "")
+	if latest, err := db.GetLatestSequence(conv.ID); err != nil || latest != 0 {
+		t.Fatalf("expected 0 for a conversation without messages, got %d err=%v", latest, err)
+	}
+
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Message 1")
+	last, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Message 2")
+	if latest, err := db.GetLatestSequence(conv.ID); err != nil || latest != last.Sequence {
+		t.Errorf("expected latest sequence %d, got %d err=%v", last.Sequence, latest, err)
+	}
+
+	// The query must not read the message rows
+	var plan []string
+	rows, err := db.Query(`EXPLAIN QUERY PLAN SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE conversation_id = ?`, conv.ID)
+	if err != nil {
+		t.Fatalf("failed to explain query: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		rows.Scan(&id, &parent, &notUsed, &detail)
+		plan = append(plan, detail)
+	}
+	if len(plan) == 0 || !strings.Contains(strings.Join(plan, "; "), "COVERING INDEX") {
+		t.Errorf("expected a covering index scan, got %v", plan)
+	}
+}
+
+func TestGetAvatarConversationIDs(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv1, _ := db.CreateConversation("Chat 1", "")
+	conv2, _ := db.CreateConversation("Chat 2", "")
+	db.CreateConversation("Chat 3", "")
+	avatar, _ := db.CreateAvatar("Alice", "Prompt", "")
+	db.AddAvatarToConversation(conv1.ID, avatar.ID)
+	db.AddAvatarToConversation(conv2.ID, avatar.ID)
+
+	ids, err := db.GetAvatarConversationIDs(avatar.ID)
+	if err != nil {
+		t.Fatalf("failed to get conversation IDs: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != conv1.ID || ids[1] != conv2.ID {
+		t.Errorf("expected [%d %d], got %v", conv1.ID, conv2.ID, ids)
+	}
+}
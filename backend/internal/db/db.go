@@ -1,20 +1,82 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/tracing"
 
 	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// sqliteTimeFormat matches how SQLite stores CURRENT_TIMESTAMP, so times compare as text
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// updatedAtFormat keeps microseconds, so every write gives a row a new updated_at that can version it
+const updatedAtFormat = "2006-01-02 15:04:05.000000"
+
+// MemoryPath is the DB path of a database kept only in memory
+// Every NewDB(MemoryPath) gets its own empty database, which is gone once it is closed
+const MemoryPath = ":memory:"
+
+// memoryDBs numbers in-memory databases so each one gets a unique shared-cache name
+var memoryDBs atomic.Int64
+
+// ErrModified is returned by a conditional update when the row changed after the expected version
+var ErrModified = errors.New("row modified")
+
+// newUpdatedAt returns the updated_at for a write, as read back from the database and as stored
+func newUpdatedAt() (time.Time, string) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return now, now.Format(updatedAtFormat)
+}
+
+// checkUnmodified returns ErrModified unless a row's updated_at equals version
+// The lock must be held, so the row cannot change before the caller's update
+func (d *DB) checkUnmodified(table string, id int64, version time.Time) error {
+	var updatedAt time.Time
+	if err := d.db.QueryRow(`SELECT updated_at FROM `+table+` WHERE id = ?`, id).Scan(&updatedAt); err != nil {
+		return err
+	}
+	if !updatedAt.Equal(version) {
+		return ErrModified
+	}
+	return nil
+}
+
 // DB wraps the SQLite database with semaphore-based exclusive access
+// Statements and lock waits are timed for QueryStats and the slow query log
 type DB struct {
-	db    *sql.DB
-	mutex sync.Mutex
+	db    *timedDB
+	mutex *sync.Mutex
+	// ctx is the trace context of a view returned by WithContext; nil on the root DB
+	ctx context.Context
+	// Caches for avatar and participant lookups, invalidated by mutations
+	avatarCache      *ttlCache[int64, models.Avatar]
+	participantCache *ttlCache[int64, ConversationAvatarsWithThreads]
+	// keepAlive holds a second connection to an in-memory database; nil for files
+	keepAlive *sql.DB
 }
 
 // NewDB creates a new database connection with exclusive access control
+// MemoryPath opens a new in-memory database instead of a file
 func NewDB(dbPath string) (*DB, error) {
+	if IsMemoryPath(dbPath) {
+		return newMemoryDB()
+	}
+
 	// Enable WAL mode and foreign keys via connection string
 	dsn := dbPath + "?_journal_mode=WAL&_foreign_keys=on"
 
@@ -23,6 +85,63 @@ func NewDB(dbPath string) (*DB, error) {
 		return nil, err
 	}
 
+	return newDB(sqlDB)
+}
+
+// IsMemoryPath reports whether a DB path refers to an in-memory database
+func IsMemoryPath(dbPath string) bool {
+	return dbPath == MemoryPath
+}
+
+// newMemoryDB opens an in-memory database under a unique shared-cache name
+// A plain :memory: database belongs to a single connection and is silently replaced by an empty one
+// whenever database/sql reopens the connection. A named shared-cache database lives as long as any
+// connection to it is open, so a second connection is kept open until Close
+func newMemoryDB() (*DB, error) {
+	dsn := fmt.Sprintf("file:memdb%d?mode=memory&cache=shared&_foreign_keys=on", memoryDBs.Add(1))
+
+	keepAlive, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := keepAlive.Ping(); err != nil {
+		keepAlive.Close()
+		return nil, err
+	}
+
+	sqlDB, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		keepAlive.Close()
+		return nil, err
+	}
+
+	database, err := newDB(sqlDB)
+	if err != nil {
+		keepAlive.Close()
+		return nil, err
+	}
+	database.keepAlive = keepAlive
+	return database, nil
+}
+
+// RemoveFiles deletes a database file with its WAL and shared memory files
+// Files that do not exist are skipped; an in-memory path has no files
+func RemoveFiles(dbPath string) error {
+	if IsMemoryPath(dbPath) {
+		return nil
+	}
+
+	var errs []error
+	for _, path := range []string{dbPath, dbPath + "-wal", dbPath + "-shm", dbPath + "-journal"} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newDB verifies the connection and wraps it with exclusive access control
+func newDB(sqlDB *sql.DB) (*DB, error) {
 	// Verify connection works
 	if err := sqlDB.Ping(); err != nil {
 		sqlDB.Close()
@@ -33,26 +152,86 @@ func NewDB(dbPath string) (*DB, error) {
 	sqlDB.SetMaxOpenConns(1)
 	sqlDB.SetMaxIdleConns(1)
 
-	return &DB{db: sqlDB}, nil
+	return &DB{
+		db:               &timedDB{DB: sqlDB, recorder: newQueryRecorder()},
+		mutex:            &sync.Mutex{},
+		avatarCache:      newTTLCache[int64, models.Avatar](DefaultCacheTTL),
+		participantCache: newTTLCache[int64, ConversationAvatarsWithThreads](DefaultCacheTTL),
+	}, nil
+}
+
+// WithContext returns a view of the database that records a span for each call
+// as a child of the span in ctx. The view shares the connection, lock and caches
+func (d *DB) WithContext(ctx context.Context) *DB {
+	view := *d
+	view.ctx = ctx
+	return &view
 }
 
 // WithLock executes a function with exclusive database access
-func (d *DB) WithLock(fn func() error) error {
+func (d *DB) WithLock(fn func() error) (err error) {
+	if span := d.startSpan(); span != nil {
+		defer func() { tracing.End(span, err) }()
+	}
+
+	start := time.Now()
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	d.lockWaited(start)
 	return fn()
 }
 
 // WithLockResult executes a function with exclusive database access and returns a result
-func WithLockResult[T any](d *DB, fn func() (T, error)) (T, error) {
+func WithLockResult[T any](d *DB, fn func() (T, error)) (result T, err error) {
+	if span := d.startSpan(); span != nil {
+		defer func() { tracing.End(span, err) }()
+	}
+
+	start := time.Now()
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	d.lockWaited(start)
 	return fn()
 }
 
+// lockWaited records how long the DB method calling WithLock or WithLockResult waited for the lock
+func (d *DB) lockWaited(start time.Time) {
+	waited := time.Since(start)
+	if d.db.recorder.recordLockWait(waited) {
+		log.Printf("[DB] Slow lock wait duration=%v method=%s", waited, callerName(3))
+	}
+}
+
+// callerName returns the name of a DB method on the call stack, e.g. db.CreateMessage
+// skip counts the frames above callerName, as in runtime.Caller
+func callerName(skip int) string {
+	name := "db.query"
+	if pc, _, _, ok := runtime.Caller(skip); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			// e.g. multi-avatar-chat/internal/db.(*DB).CreateMessage -> db.CreateMessage
+			fullName := fn.Name()
+			name = "db." + fullName[strings.LastIndex(fullName, ".")+1:]
+		}
+	}
+	return name
+}
+
+// startSpan starts a span named after the DB method calling WithLock or WithLockResult
+// Returns nil unless the view was created by WithContext inside a recording span
+func (d *DB) startSpan() trace.Span {
+	if d.ctx == nil || !trace.SpanFromContext(d.ctx).IsRecording() {
+		return nil
+	}
+
+	_, span := tracing.Start(d.ctx, callerName(3), attribute.String("db.system", "sqlite"))
+	return span
+}
+
 // Exec executes a query with exclusive access
+// Caches are dropped because the statement may modify any table
 func (d *DB) Exec(query string, args ...any) (sql.Result, error) {
 	return WithLockResult(d, func() (sql.Result, error) {
+		defer d.invalidateAll()
 		return d.db.Exec(query, args...)
 	})
 }
@@ -66,14 +245,21 @@ func (d *DB) Query(query string, args ...any) (*sql.Rows, error) {
 
 // QueryRow executes a query that returns a single row
 func (d *DB) QueryRow(query string, args ...any) *sql.Row {
+	start := time.Now()
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	d.lockWaited(start)
 	return d.db.QueryRow(query, args...)
 }
 
 // Close closes the database connection
 func (d *DB) Close() error {
-	return d.db.Close()
+	err := d.db.Close()
+	if d.keepAlive != nil {
+		// The in-memory database is freed with its last connection
+		err = errors.Join(err, d.keepAlive.Close())
+	}
+	return err
 }
 
 // tableExists checks if a table exists in the database
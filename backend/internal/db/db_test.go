@@ -1,11 +1,19 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"multi-avatar-chat/internal/models"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNewDB_CreatesConnection(t *testing.T) {
@@ -23,6 +31,60 @@ func TestNewDB_CreatesConnection(t *testing.T) {
 	}
 }
 
+func TestNewDB_Memory(t *testing.T) {
+	first, err := NewDB(MemoryPath)
+	if err != nil {
+		t.Fatalf("failed to create in-memory database: %v", err)
+	}
+	defer first.Close()
+	second, _ := NewDB(MemoryPath)
+	defer second.Close()
+
+	if err := first.Migrate(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	second.Migrate()
+	first.CreateAvatar("Alice", "prompt", "")
+
+	// A connection replaced by the pool must still see the same database
+	first.db.SetConnMaxLifetime(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if avatars, err := first.GetAllAvatars(); err != nil || len(avatars) != 1 {
+		t.Errorf("expected the avatar to survive a new connection, got %+v err=%v", avatars, err)
+	}
+
+	if avatars, _ := second.GetAllAvatars(); len(avatars) != 0 {
+		t.Errorf("expected in-memory databases to be separate, got %+v", avatars)
+	}
+
+	var foreignKeys int
+	first.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys)
+	if foreignKeys != 1 {
+		t.Error("expected foreign keys to be enabled")
+	}
+}
+
+func TestRemoveFiles(t *testing.T) {
+	path := createTempDB(t)
+	database, _ := NewDB(path)
+	database.Migrate()
+	database.Close()
+	os.WriteFile(path+"-wal", nil, 0644)
+
+	if err := RemoveFiles(path); err != nil {
+		t.Fatalf("failed to remove files: %v", err)
+	}
+	for _, name := range []string{path, path + "-wal", path + "-shm"} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be removed, got %v", name, err)
+		}
+	}
+
+	if err := RemoveFiles(MemoryPath); err != nil {
+		t.Errorf("expected nothing to remove for an in-memory database, got %v", err)
+	}
+}
+
 func TestMigration_CreatesAllTables(t *testing.T) {
 	tmpFile := createTempDB(t)
 	defer os.Remove(tmpFile)
@@ -135,3 +197,158 @@ func createTempDB(t *testing.T) string {
 	return tmpFile.Name()
 }
 
+
+func TestMigration_AllowsSystemMessagesInLegacySchema(t *testing.T) {
+	tmpFile := createTempDB(t)
+	defer os.Remove(tmpFile)
+
+	database, err := NewDB(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer database.Close()
+
+	// Create the schema as it existed before system messages
+	legacy := []string{
+		`CREATE TABLE conversations (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, thread_id TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			sender_type TEXT NOT NULL CHECK(sender_type IN ('user', 'avatar')),
+			sender_id INTEGER,
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO conversations (title) VALUES ('Legacy')`,
+		`INSERT INTO messages (conversation_id, sender_type, content) VALUES (1, 'user', 'hello')`,
+	}
+	for _, stmt := range legacy {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("failed to create legacy schema: %v", err)
+		}
+	}
+
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	messages, err := database.GetMessages(1)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Content != "hello" {
+		t.Errorf("expected existing message to be preserved, got %+v", messages)
+	}
+
+	if _, err := database.CreateMessage(1, models.SenderTypeSystem, nil, "digest"); err != nil {
+		t.Errorf("expected system message to be allowed after migration, got %v", err)
+	}
+}
+
+func TestMigration_NumbersExistingMessages(t *testing.T) {
+	tmpFile := createTempDB(t)
+	defer os.Remove(tmpFile)
+
+	database, err := NewDB(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer database.Close()
+
+	// Create the schema as it existed before message sequences, with interleaved conversations
+	legacy := []string{
+		`CREATE TABLE conversations (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, thread_id TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			sender_type TEXT NOT NULL CHECK(sender_type IN ('user', 'avatar', 'system')),
+			sender_id INTEGER,
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO conversations (title) VALUES ('One'), ('Two')`,
+		`INSERT INTO messages (conversation_id, sender_type, content) VALUES (1, 'user', 'a'), (2, 'user', 'b'), (1, 'user', 'c')`,
+	}
+	for _, stmt := range legacy {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("failed to create legacy schema: %v", err)
+		}
+	}
+
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	messages, err := database.GetMessages(1)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Sequence != 1 || messages[1].Sequence != 2 || messages[1].Content != "c" {
+		t.Errorf("expected existing messages to be numbered 1 and 2, got %+v", messages)
+	}
+
+	// New messages continue after the existing ones
+	msg, err := database.CreateMessage(1, models.SenderTypeUser, nil, "d")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	if msg.Sequence != 3 {
+		t.Errorf("expected sequence 3, got %d", msg.Sequence)
+	}
+
+	// Running the migration again keeps the numbers
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+	if msg, _ := database.CreateMessage(2, models.SenderTypeUser, nil, "e"); msg == nil || msg.Sequence != 2 {
+		t.Errorf("expected sequence 2 in the second conversation, got %+v", msg)
+	}
+}
+
+func TestWithContext_RecordsSpans(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	if _, err := db.WithContext(ctx).GetAllConversations(); err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+	// Calls on the root DB are not traced
+	if _, err := db.CreateConversation("Untraced", ""); err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	parent.End()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Parent().SpanID() == parent.SpanContext().SpanID() {
+			names = append(names, span.Name())
+		}
+	}
+	if len(names) != 1 || names[0] != "db.GetAllConversations" {
+		t.Errorf("expected a single db.GetAllConversations span, got %v", names)
+	}
+}
+
+func TestNewEncryptedDB_RequiresSQLCipher(t *testing.T) {
+	tmpFile := createTempDB(t)
+	defer os.Remove(tmpFile)
+
+	// The default build links plain SQLite, which must not silently create an unencrypted file
+	database, err := NewEncryptedDB(tmpFile, "secret")
+	if err == nil {
+		database.Close()
+		t.Skip("SQLCipher is available in this build")
+	}
+	if !errors.Is(err, ErrEncryptionUnsupported) {
+		t.Errorf("expected ErrEncryptionUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const deadLetterColumns = `id, conversation_id, avatar_id, message_id, error, attempts, context, status, created_at, updated_at, resolved_at`
+
+// scanDeadLetter scans a row selected with deadLetterColumns
+func scanDeadLetter(scanner interface{ Scan(...any) error }) (*models.DeadLetter, error) {
+	var dl models.DeadLetter
+	var resolvedAt sql.NullTime
+	if err := scanner.Scan(&dl.ID, &dl.ConversationID, &dl.AvatarID, &dl.MessageID, &dl.Error, &dl.Attempts,
+		&dl.Context, &dl.Status, &dl.CreatedAt, &dl.UpdatedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		dl.ResolvedAt = &resolvedAt.Time
+	}
+	return &dl, nil
+}
+
+// CreateDeadLetter records an avatar response that failed after every attempt
+func (d *DB) CreateDeadLetter(conversationID, avatarID, messageID int64, errMsg string, attempts int, context string) (*models.DeadLetter, error) {
+	return WithLockResult(d, func() (*models.DeadLetter, error) {
+		now := time.Now().UTC().Format(sqliteTimeFormat)
+		result, err := d.db.Exec(
+			`INSERT INTO dead_letters (conversation_id, avatar_id, message_id, error, attempts, context, status, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			conversationID, avatarID, messageID, errMsg, attempts, context, models.DeadLetterStatusOpen, now, now,
+		)
+		if err != nil {
+			log.Printf("[DB] CreateDeadLetter failed: exec error conversation_id=%d avatar_id=%d message_id=%d err=%v",
+				conversationID, avatarID, messageID, err)
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[DB] CreateDeadLetter completed dead_letter_id=%d conversation_id=%d avatar_id=%d message_id=%d",
+			id, conversationID, avatarID, messageID)
+		return scanDeadLetter(d.db.QueryRow(`SELECT `+deadLetterColumns+` FROM dead_letters WHERE id = ?`, id))
+	})
+}
+
+// GetDeadLetter retrieves a dead letter by ID
+func (d *DB) GetDeadLetter(id int64) (*models.DeadLetter, error) {
+	return WithLockResult(d, func() (*models.DeadLetter, error) {
+		return scanDeadLetter(d.db.QueryRow(`SELECT `+deadLetterColumns+` FROM dead_letters WHERE id = ?`, id))
+	})
+}
+
+// GetDeadLetters retrieves dead letters, newest first
+// An empty status matches every status and a zero conversationID every conversation
+func (d *DB) GetDeadLetters(status string, conversationID int64) ([]models.DeadLetter, error) {
+	return WithLockResult(d, func() ([]models.DeadLetter, error) {
+		rows, err := d.db.Query(
+			`SELECT `+deadLetterColumns+` FROM dead_letters
+			WHERE (? = '' OR status = ?) AND (? = 0 OR conversation_id = ?)
+			ORDER BY id DESC`,
+			status, status, conversationID, conversationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		deadLetters := []models.DeadLetter{}
+		for rows.Next() {
+			dl, err := scanDeadLetter(rows)
+			if err != nil {
+				return nil, err
+			}
+			deadLetters = append(deadLetters, *dl)
+		}
+		return deadLetters, rows.Err()
+	})
+}
+
+// StartDeadLetterRetry marks an open dead letter as being retried
+// Returns false if the dead letter is not open
+func (d *DB) StartDeadLetterRetry(id int64) (bool, error) {
+	return d.setDeadLetterStatus(id, models.DeadLetterStatusOpen, models.DeadLetterStatusRetrying)
+}
+
+// ReopenDeadLetter marks a dead letter being retried as open again without recording an attempt
+// Used when the retry could not be started
+func (d *DB) ReopenDeadLetter(id int64) (bool, error) {
+	return d.setDeadLetterStatus(id, models.DeadLetterStatusRetrying, models.DeadLetterStatusOpen)
+}
+
+// setDeadLetterStatus changes the status of a dead letter that has the expected status
+func (d *DB) setDeadLetterStatus(id int64, from, to string) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		result, err := d.db.Exec(
+			`UPDATE dead_letters SET status = ?, updated_at = ? WHERE id = ? AND status = ?`,
+			to, time.Now().UTC().Format(sqliteTimeFormat), id, from,
+		)
+		if err != nil {
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected > 0, err
+	})
+}
+
+// FinishDeadLetterRetry records the outcome of a retry
+// The dead letter is resolved when errMsg is empty; otherwise it is open again with the new error and context
+func (d *DB) FinishDeadLetterRetry(id int64, errMsg string, attempts int, context string) error {
+	return d.WithLock(func() error {
+		now := time.Now().UTC().Format(sqliteTimeFormat)
+		var err error
+		if errMsg == "" {
+			_, err = d.db.Exec(
+				`UPDATE dead_letters SET status = ?, attempts = attempts + ?, updated_at = ?, resolved_at = ? WHERE id = ?`,
+				models.DeadLetterStatusResolved, attempts, now, now, id,
+			)
+		} else {
+			_, err = d.db.Exec(
+				`UPDATE dead_letters SET status = ?, error = ?, attempts = attempts + ?, context = ?, updated_at = ? WHERE id = ?`,
+				models.DeadLetterStatusOpen, errMsg, attempts, context, now, id,
+			)
+		}
+		if err != nil {
+			log.Printf("[DB] FinishDeadLetterRetry failed: exec error dead_letter_id=%d err=%v", id, err)
+		}
+		return err
+	})
+}
+
+// ReopenRetryingDeadLetters marks dead letters left retrying by a previous process as open
+func (d *DB) ReopenRetryingDeadLetters() (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		result, err := d.db.Exec(
+			`UPDATE dead_letters SET status = ?, updated_at = ? WHERE status = ?`,
+			models.DeadLetterStatusOpen, time.Now().UTC().Format(sqliteTimeFormat), models.DeadLetterStatusRetrying,
+		)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	})
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestDeadLetters_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Failures", "")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+	msg, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	dl, err := db.CreateDeadLetter(conv.ID, avatar.ID, msg.ID, "run failed", 3, `{"thread_id":"thread_1"}`)
+	if err != nil {
+		t.Fatalf("failed to create dead letter: %v", err)
+	}
+	if dl.Status != models.DeadLetterStatusOpen || dl.Attempts != 3 || dl.MessageID != msg.ID || dl.ResolvedAt != nil {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+
+	if started, err := db.StartDeadLetterRetry(dl.ID); err != nil || !started {
+		t.Fatalf("expected retry to start, got started=%v err=%v", started, err)
+	}
+	if started, _ := db.StartDeadLetterRetry(dl.ID); started {
+		t.Error("expected a retrying dead letter not to start again")
+	}
+
+	// A failed retry reopens the dead letter with the new error
+	if err := db.FinishDeadLetterRetry(dl.ID, "timeout", 2, `{"thread_id":"thread_2"}`); err != nil {
+		t.Fatalf("failed to finish retry: %v", err)
+	}
+	got, _ := db.GetDeadLetter(dl.ID)
+	if got.Status != models.DeadLetterStatusOpen || got.Error != "timeout" || got.Attempts != 5 || got.Context != `{"thread_id":"thread_2"}` {
+		t.Errorf("unexpected dead letter after failed retry: %+v", got)
+	}
+
+	// A successful retry resolves it
+	db.StartDeadLetterRetry(dl.ID)
+	if err := db.FinishDeadLetterRetry(dl.ID, "", 1, ""); err != nil {
+		t.Fatalf("failed to finish retry: %v", err)
+	}
+	got, _ = db.GetDeadLetter(dl.ID)
+	if got.Status != models.DeadLetterStatusResolved || got.ResolvedAt == nil || got.Attempts != 6 || got.Error != "timeout" {
+		t.Errorf("unexpected resolved dead letter: %+v", got)
+	}
+
+	if _, err := db.GetDeadLetter(999); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestGetDeadLetters_Filters(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv1, _ := db.CreateConversation("One", "")
+	conv2, _ := db.CreateConversation("Two", "")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+	msg1, _ := db.CreateMessage(conv1.ID, models.SenderTypeUser, nil, "one")
+	msg2, _ := db.CreateMessage(conv2.ID, models.SenderTypeUser, nil, "two")
+
+	first, _ := db.CreateDeadLetter(conv1.ID, avatar.ID, msg1.ID, "err", 3, "{}")
+	second, _ := db.CreateDeadLetter(conv2.ID, avatar.ID, msg2.ID, "err", 3, "{}")
+	db.StartDeadLetterRetry(second.ID)
+
+	all, err := db.GetDeadLetters("", 0)
+	if err != nil || len(all) != 2 || all[0].ID != second.ID {
+		t.Fatalf("expected both dead letters newest first, got %+v err=%v", all, err)
+	}
+
+	open, _ := db.GetDeadLetters(models.DeadLetterStatusOpen, 0)
+	if len(open) != 1 || open[0].ID != first.ID {
+		t.Errorf("expected only the open dead letter, got %+v", open)
+	}
+
+	byConversation, _ := db.GetDeadLetters("", conv2.ID)
+	if len(byConversation) != 1 || byConversation[0].ID != second.ID {
+		t.Errorf("expected only the dead letter of conversation 2, got %+v", byConversation)
+	}
+
+	if n, err := db.ReopenRetryingDeadLetters(); err != nil || n != 1 {
+		t.Errorf("expected 1 reopened dead letter, got n=%d err=%v", n, err)
+	}
+	if got, _ := db.GetDeadLetter(second.ID); got.Status != models.DeadLetterStatusOpen {
+		t.Errorf("expected the retrying dead letter to be reopened, got %+v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// GetDefaultAvatars returns the avatars added to conversations created without avatar_ids, in order
+func (d *DB) GetDefaultAvatars() ([]models.Avatar, error) {
+	return WithLockResult(d, func() ([]models.Avatar, error) {
+		rows, err := d.db.Query(
+			`SELECT ` + avatarColumns + ` FROM default_avatars da
+			INNER JOIN avatars a ON a.id = da.avatar_id
+			ORDER BY da.position`,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var avatars []models.Avatar
+		for rows.Next() {
+			avatar, err := scanAvatar(rows)
+			if err != nil {
+				return nil, err
+			}
+			avatars = append(avatars, *avatar)
+		}
+		return avatars, rows.Err()
+	})
+}
+
+// SetDefaultAvatars replaces the default avatars with the given ones, in order; an empty list clears them
+func (d *DB) SetDefaultAvatars(avatarIDs []int64) error {
+	return d.WithLock(func() error {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`DELETE FROM default_avatars`); err != nil {
+			return err
+		}
+		for i, id := range avatarIDs {
+			if _, err := tx.Exec(`INSERT INTO default_avatars (avatar_id, position) VALUES (?, ?)`, id, i); err != nil {
+				log.Printf("[DB] SetDefaultAvatars failed: exec error avatar_id=%d err=%v", id, err)
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Printf("[DB] SetDefaultAvatars completed count=%d", len(avatarIDs))
+		return nil
+	})
+}
@@ -0,0 +1,39 @@
+package db
+
+import "testing"
+
+func TestDefaultAvatars(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	alice, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := db.CreateAvatar("Bob", "prompt", "asst_2")
+
+	if avatars, err := db.GetDefaultAvatars(); err != nil || len(avatars) != 0 {
+		t.Fatalf("expected no default avatars, got %v err=%v", avatars, err)
+	}
+
+	if err := db.SetDefaultAvatars([]int64{bob.ID, alice.ID}); err != nil {
+		t.Fatalf("SetDefaultAvatars failed: %v", err)
+	}
+	avatars, _ := db.GetDefaultAvatars()
+	if len(avatars) != 2 || avatars[0].ID != bob.ID || avatars[1].ID != alice.ID {
+		t.Fatalf("expected Bob then Alice, got %+v", avatars)
+	}
+
+	// Deleted avatars leave the roster
+	if err := db.DeleteAvatar(bob.ID); err != nil {
+		t.Fatalf("DeleteAvatar failed: %v", err)
+	}
+	avatars, _ = db.GetDefaultAvatars()
+	if len(avatars) != 1 || avatars[0].ID != alice.ID {
+		t.Errorf("expected only Alice, got %+v", avatars)
+	}
+
+	if err := db.SetDefaultAvatars(nil); err != nil {
+		t.Fatalf("SetDefaultAvatars failed: %v", err)
+	}
+	if avatars, _ := db.GetDefaultAvatars(); len(avatars) != 0 {
+		t.Errorf("expected the roster to be cleared, got %+v", avatars)
+	}
+}
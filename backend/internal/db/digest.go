@@ -0,0 +1,61 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateDigest records a digest posted to a conversation
+func (d *DB) CreateDigest(conversationID, messageID, lastMessageID int64, messageCount int) (*models.Digest, error) {
+	return WithLockResult(d, func() (*models.Digest, error) {
+		log.Printf("[DB] CreateDigest started conversation_id=%d message_id=%d last_message_id=%d",
+			conversationID, messageID, lastMessageID)
+
+		result, err := d.db.Exec(
+			`INSERT INTO conversation_digests (conversation_id, message_id, last_message_id, message_count) VALUES (?, ?, ?, ?)`,
+			conversationID, messageID, lastMessageID, messageCount,
+		)
+		if err != nil {
+			log.Printf("[DB] CreateDigest failed: exec error err=%v", err)
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			log.Printf("[DB] CreateDigest failed: get last insert id err=%v", err)
+			return nil, err
+		}
+
+		var digest models.Digest
+		err = d.db.QueryRow(
+			`SELECT id, conversation_id, message_id, last_message_id, message_count, created_at
+			FROM conversation_digests WHERE id = ?`,
+			id,
+		).Scan(&digest.ID, &digest.ConversationID, &digest.MessageID, &digest.LastMessageID, &digest.MessageCount, &digest.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[DB] CreateDigest completed digest_id=%d conversation_id=%d", id, conversationID)
+		return &digest, nil
+	})
+}
+
+// GetLatestDigest retrieves the most recent digest of a conversation
+// Returns sql.ErrNoRows if no digest has been generated yet
+func (d *DB) GetLatestDigest(conversationID int64) (*models.Digest, error) {
+	return WithLockResult(d, func() (*models.Digest, error) {
+		var digest models.Digest
+		err := d.db.QueryRow(
+			`SELECT id, conversation_id, message_id, last_message_id, message_count, created_at
+			FROM conversation_digests WHERE conversation_id = ?
+			ORDER BY id DESC LIMIT 1`,
+			conversationID,
+		).Scan(&digest.ID, &digest.ConversationID, &digest.MessageID, &digest.LastMessageID, &digest.MessageCount, &digest.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &digest, nil
+	})
+}
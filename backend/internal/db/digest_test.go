@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestCreateDigest(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	last, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	summary, _ := db.CreateMessage(conv.ID, models.SenderTypeSystem, nil, "summary")
+
+	digest, err := db.CreateDigest(conv.ID, summary.ID, last.ID, 1)
+	if err != nil {
+		t.Fatalf("failed to create digest: %v", err)
+	}
+	if digest.ID == 0 || digest.MessageID != summary.ID || digest.LastMessageID != last.ID {
+		t.Errorf("unexpected digest: %+v", digest)
+	}
+
+	latest, err := db.GetLatestDigest(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get latest digest: %v", err)
+	}
+	if latest.ID != digest.ID {
+		t.Errorf("expected latest digest %d, got %d", digest.ID, latest.ID)
+	}
+}
+
+func TestGetLatestDigest_NotFound(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+
+	if _, err := db.GetLatestDigest(conv.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrEncryptionUnsupported is returned when an encryption key is configured
+// but the linked SQLite library is not SQLCipher
+var ErrEncryptionUnsupported = errors.New("database encryption requires SQLite built with SQLCipher")
+
+// NewEncryptedDB opens a SQLCipher-encrypted database with exclusive access control
+// The key is applied before any other statement on every new connection, and opening
+// fails instead of falling back to an unencrypted file when SQLCipher is not available
+func NewEncryptedDB(dbPath, key string) (*DB, error) {
+	if key == "" {
+		return nil, errors.New("database encryption key is empty")
+	}
+
+	connector := &keyedConnector{
+		dsn: dbPath,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return applyEncryptionKey(conn, key)
+			},
+		},
+	}
+
+	database, err := newDB(sql.OpenDB(connector))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DB] Opened encrypted database path=%s", dbPath)
+	return database, nil
+}
+
+// keyedConnector opens connections through a driver whose connect hook sets the key
+// Using a connector keeps the key out of the DSN and the global driver registry
+type keyedConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+// Connect opens a new connection
+func (c *keyedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the underlying driver
+func (c *keyedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// applyEncryptionKey keys a fresh connection and then enables WAL mode and foreign keys
+// These pragmas would otherwise run from the DSN before the key and fail on an encrypted file
+func applyEncryptionKey(conn *sqlite3.SQLiteConn, key string) error {
+	if _, err := conn.Exec("PRAGMA key = '"+strings.ReplaceAll(key, "'", "''")+"'", nil); err != nil {
+		return err
+	}
+
+	// Plain SQLite silently ignores PRAGMA key, so confirm SQLCipher is handling it
+	rows, err := conn.Query("PRAGMA cipher_version", nil)
+	if err != nil {
+		return err
+	}
+	version := make([]driver.Value, 1)
+	err = rows.Next(version)
+	rows.Close()
+	if err != nil {
+		return ErrEncryptionUnsupported
+	}
+
+	// Reading the schema fails with "file is not a database" when the key is wrong
+	if _, err := conn.Exec("SELECT count(*) FROM sqlite_master", nil); err != nil {
+		return fmt.Errorf("failed to decrypt database (wrong key?): %w", err)
+	}
+
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA foreign_keys=ON"} {
+		if _, err := conn.Exec(pragma, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
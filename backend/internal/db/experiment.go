@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const experimentColumns = `avatar_id, model, broadcast, created_at, updated_at`
+
+const comparisonColumns = `id, conversation_id, avatar_id, trigger_message_id, message_id, broadcast,
+	primary_model, primary_content, primary_latency_ms, primary_error,
+	comparison_model, comparison_content, comparison_latency_ms, comparison_error,
+	preferred, created_at`
+
+// scanExperiment scans a row selected with experimentColumns
+func scanExperiment(scanner interface{ Scan(...any) error }) (*models.ResponseExperiment, error) {
+	var e models.ResponseExperiment
+	if err := scanner.Scan(&e.AvatarID, &e.Model, &e.Broadcast, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// scanComparison scans a row selected with comparisonColumns
+func scanComparison(scanner interface{ Scan(...any) error }) (*models.ResponseComparison, error) {
+	var c models.ResponseComparison
+	var messageID sql.NullInt64
+	if err := scanner.Scan(&c.ID, &c.ConversationID, &c.AvatarID, &c.TriggerMessageID, &messageID, &c.Broadcast,
+		&c.Primary.Model, &c.Primary.Content, &c.Primary.LatencyMs, &c.Primary.Error,
+		&c.Comparison.Model, &c.Comparison.Content, &c.Comparison.LatencyMs, &c.Comparison.Error,
+		&c.Preferred, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if messageID.Valid {
+		id := messageID.Int64
+		c.MessageID = &id
+	}
+	return &c, nil
+}
+
+// SetResponseExperiment starts or changes the model comparison experiment of an avatar
+func (d *DB) SetResponseExperiment(avatarID int64, model, broadcast string) (*models.ResponseExperiment, error) {
+	return WithLockResult(d, func() (*models.ResponseExperiment, error) {
+		now := time.Now().UTC().Format(sqliteTimeFormat)
+		_, err := d.db.Exec(
+			`INSERT INTO response_experiments (avatar_id, model, broadcast, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(avatar_id) DO UPDATE SET model = excluded.model, broadcast = excluded.broadcast, updated_at = excluded.updated_at`,
+			avatarID, model, broadcast, now, now,
+		)
+		if err != nil {
+			log.Printf("[DB] SetResponseExperiment failed: exec error avatar_id=%d err=%v", avatarID, err)
+			return nil, err
+		}
+		return scanExperiment(d.db.QueryRow(`SELECT `+experimentColumns+` FROM response_experiments WHERE avatar_id = ?`, avatarID))
+	})
+}
+
+// GetResponseExperiment retrieves the experiment of an avatar
+// Returns sql.ErrNoRows if the avatar has none
+func (d *DB) GetResponseExperiment(avatarID int64) (*models.ResponseExperiment, error) {
+	return WithLockResult(d, func() (*models.ResponseExperiment, error) {
+		return scanExperiment(d.db.QueryRow(`SELECT `+experimentColumns+` FROM response_experiments WHERE avatar_id = ?`, avatarID))
+	})
+}
+
+// GetResponseExperiments retrieves every running experiment, ordered by avatar
+func (d *DB) GetResponseExperiments() ([]models.ResponseExperiment, error) {
+	return WithLockResult(d, func() ([]models.ResponseExperiment, error) {
+		rows, err := d.db.Query(`SELECT ` + experimentColumns + ` FROM response_experiments ORDER BY avatar_id`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		experiments := []models.ResponseExperiment{}
+		for rows.Next() {
+			e, err := scanExperiment(rows)
+			if err != nil {
+				return nil, err
+			}
+			experiments = append(experiments, *e)
+		}
+		return experiments, rows.Err()
+	})
+}
+
+// DeleteResponseExperiment ends the experiment of an avatar; recorded comparisons are kept
+// Returns sql.ErrNoRows if the avatar has no experiment
+func (d *DB) DeleteResponseExperiment(avatarID int64) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(`DELETE FROM response_experiments WHERE avatar_id = ?`, avatarID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// CreateResponseComparison records both responses of an experiment
+func (d *DB) CreateResponseComparison(c *models.ResponseComparison) (*models.ResponseComparison, error) {
+	return WithLockResult(d, func() (*models.ResponseComparison, error) {
+		result, err := d.db.Exec(
+			`INSERT INTO response_comparisons (conversation_id, avatar_id, trigger_message_id, message_id, broadcast,
+				primary_model, primary_content, primary_latency_ms, primary_error,
+				comparison_model, comparison_content, comparison_latency_ms, comparison_error, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			c.ConversationID, c.AvatarID, c.TriggerMessageID, c.MessageID, c.Broadcast,
+			c.Primary.Model, c.Primary.Content, c.Primary.LatencyMs, c.Primary.Error,
+			c.Comparison.Model, c.Comparison.Content, c.Comparison.LatencyMs, c.Comparison.Error,
+			time.Now().UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			log.Printf("[DB] CreateResponseComparison failed: exec error conversation_id=%d avatar_id=%d err=%v",
+				c.ConversationID, c.AvatarID, err)
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		return scanComparison(d.db.QueryRow(`SELECT `+comparisonColumns+` FROM response_comparisons WHERE id = ?`, id))
+	})
+}
+
+// GetResponseComparison retrieves a comparison by ID
+func (d *DB) GetResponseComparison(id int64) (*models.ResponseComparison, error) {
+	return WithLockResult(d, func() (*models.ResponseComparison, error) {
+		return scanComparison(d.db.QueryRow(`SELECT `+comparisonColumns+` FROM response_comparisons WHERE id = ?`, id))
+	})
+}
+
+// GetResponseComparisons retrieves up to limit comparisons, newest first
+// A zero conversationID or avatarID matches every conversation or avatar
+func (d *DB) GetResponseComparisons(conversationID, avatarID int64, limit int) ([]models.ResponseComparison, error) {
+	return WithLockResult(d, func() ([]models.ResponseComparison, error) {
+		rows, err := d.db.Query(
+			`SELECT `+comparisonColumns+` FROM response_comparisons
+			WHERE (? = 0 OR conversation_id = ?) AND (? = 0 OR avatar_id = ?)
+			ORDER BY id DESC LIMIT ?`,
+			conversationID, conversationID, avatarID, avatarID, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		comparisons := []models.ResponseComparison{}
+		for rows.Next() {
+			c, err := scanComparison(rows)
+			if err != nil {
+				return nil, err
+			}
+			comparisons = append(comparisons, *c)
+		}
+		return comparisons, rows.Err()
+	})
+}
+
+// SetComparisonPreference records which response of a comparison a reviewer preferred
+// Returns sql.ErrNoRows if the comparison does not exist
+func (d *DB) SetComparisonPreference(id int64, preferred string) (*models.ResponseComparison, error) {
+	return WithLockResult(d, func() (*models.ResponseComparison, error) {
+		result, err := d.db.Exec(`UPDATE response_comparisons SET preferred = ? WHERE id = ?`, preferred, id)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 0 {
+			return nil, sql.ErrNoRows
+		}
+		return scanComparison(d.db.QueryRow(`SELECT `+comparisonColumns+` FROM response_comparisons WHERE id = ?`, id))
+	})
+}
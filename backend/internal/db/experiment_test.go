@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestResponseExperiments(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	alice, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := db.CreateAvatar("Bob", "prompt", "asst_2")
+
+	if _, err := db.GetResponseExperiment(alice.ID); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows without an experiment, got %v", err)
+	}
+
+	db.SetResponseExperiment(bob.ID, "gpt-a", models.ExperimentVariantPrimary)
+	db.SetResponseExperiment(alice.ID, "gpt-a", models.ExperimentVariantPrimary)
+	updated, err := db.SetResponseExperiment(alice.ID, "gpt-b", models.ExperimentVariantComparison)
+	if err != nil || updated.Model != "gpt-b" || updated.Broadcast != models.ExperimentVariantComparison {
+		t.Fatalf("expected the experiment to be updated, got %+v err=%v", updated, err)
+	}
+
+	experiments, _ := db.GetResponseExperiments()
+	if len(experiments) != 2 || experiments[0].AvatarID != alice.ID {
+		t.Errorf("expected 2 experiments ordered by avatar, got %+v", experiments)
+	}
+
+	if err := db.DeleteResponseExperiment(alice.ID); err != nil {
+		t.Fatalf("failed to delete experiment: %v", err)
+	}
+	if err := db.DeleteResponseExperiment(alice.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting a missing experiment, got %v", err)
+	}
+}
+
+func TestResponseComparisons(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv1, _ := db.CreateConversation("One", "")
+	conv2, _ := db.CreateConversation("Two", "")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+	trigger1, _ := db.CreateMessage(conv1.ID, models.SenderTypeUser, nil, "hello")
+	reply1, _ := db.CreateMessage(conv1.ID, models.SenderTypeAvatar, &avatar.ID, "primary answer")
+	trigger2, _ := db.CreateMessage(conv2.ID, models.SenderTypeUser, nil, "hi")
+
+	first, err := db.CreateResponseComparison(&models.ResponseComparison{
+		ConversationID:   conv1.ID,
+		AvatarID:         avatar.ID,
+		TriggerMessageID: trigger1.ID,
+		MessageID:        &reply1.ID,
+		Broadcast:        models.ExperimentVariantPrimary,
+		Primary:          models.ResponseCandidate{Model: "gpt-a", Content: "primary answer", LatencyMs: 1200},
+		Comparison:       models.ResponseCandidate{Model: "gpt-b", Content: "comparison answer", LatencyMs: 800},
+	})
+	if err != nil || first.MessageID == nil || *first.MessageID != reply1.ID || first.Comparison.LatencyMs != 800 {
+		t.Fatalf("unexpected comparison: %+v err=%v", first, err)
+	}
+	db.CreateResponseComparison(&models.ResponseComparison{
+		ConversationID:   conv2.ID,
+		AvatarID:         avatar.ID,
+		TriggerMessageID: trigger2.ID,
+		Broadcast:        models.ExperimentVariantPrimary,
+		Primary:          models.ResponseCandidate{Model: "gpt-a", Content: "answer"},
+		Comparison:       models.ResponseCandidate{Model: "gpt-b", Error: "timeout"},
+	})
+
+	all, _ := db.GetResponseComparisons(0, 0, 10)
+	if len(all) != 2 || all[0].ConversationID != conv2.ID || all[0].MessageID != nil || all[0].Comparison.Error != "timeout" {
+		t.Errorf("expected 2 comparisons newest first, got %+v", all)
+	}
+	if byConversation, _ := db.GetResponseComparisons(conv1.ID, avatar.ID, 10); len(byConversation) != 1 {
+		t.Errorf("expected 1 comparison of conversation 1, got %+v", byConversation)
+	}
+
+	reviewed, err := db.SetComparisonPreference(first.ID, models.ExperimentVariantComparison)
+	if err != nil || reviewed.Preferred != models.ExperimentVariantComparison {
+		t.Errorf("expected the preference to be stored, got %+v err=%v", reviewed, err)
+	}
+	if _, err := db.SetComparisonPreference(999, "tie"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing comparison, got %v", err)
+	}
+
+	// Purging text also removes the comparisons storing it, including the unbroadcast response
+	if _, err := db.DeleteContent("comparison ANSWER"); err != nil {
+		t.Fatalf("failed to purge content: %v", err)
+	}
+	if _, err := db.GetResponseComparison(first.ID); err != sql.ErrNoRows {
+		t.Errorf("expected the purged comparison to be deleted, got %v", err)
+	}
+	if remaining, _ := db.GetResponseComparisons(0, 0, 10); len(remaining) != 1 {
+		t.Errorf("expected the other comparison to be kept, got %+v", remaining)
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// PublishConversation shows a conversation in the public gallery under slug
+// Returns ErrDuplicate if another conversation already uses the slug
+func (d *DB) PublishConversation(id int64, slug string) (*models.Conversation, error) {
+	return d.setConversationPublished(id, true, &slug)
+}
+
+// UnpublishConversation removes a conversation from the public gallery, keeping its slug
+func (d *DB) UnpublishConversation(id int64) (*models.Conversation, error) {
+	return d.setConversationPublished(id, false, nil)
+}
+
+func (d *DB) setConversationPublished(id int64, published bool, slug *string) (*models.Conversation, error) {
+	return WithLockResult(d, func() (*models.Conversation, error) {
+		_, stamp := newUpdatedAt()
+		result, err := d.db.Exec(
+			`UPDATE conversations SET published = ?, gallery_slug = COALESCE(?, gallery_slug), updated_at = ? WHERE id = ?`,
+			published, slug, stamp, id,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrDuplicate
+			}
+			return nil, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, sql.ErrNoRows
+		}
+
+		row := d.db.QueryRow(`SELECT `+conversationColumns+` FROM conversations WHERE id = ?`, id)
+		return scanConversation(row)
+	})
+}
+
+// GetPublishedConversation returns the published conversation with a gallery slug
+// Returns sql.ErrNoRows if no conversation is published under the slug
+func (d *DB) GetPublishedConversation(slug string) (*models.Conversation, error) {
+	return WithLockResult(d, func() (*models.Conversation, error) {
+		row := d.db.QueryRow(
+			`SELECT `+conversationColumns+` FROM conversations WHERE gallery_slug = ? AND published = 1`,
+			slug,
+		)
+		return scanConversation(row)
+	})
+}
+
+// GetPublishedConversations returns the conversations in the public gallery, newest first
+func (d *DB) GetPublishedConversations() ([]models.Conversation, error) {
+	return WithLockResult(d, func() ([]models.Conversation, error) {
+		rows, err := d.db.Query(
+			`SELECT ` + conversationColumns + ` FROM conversations WHERE published = 1 ORDER BY created_at DESC, id DESC`,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		conversations := []models.Conversation{}
+		for rows.Next() {
+			conv, err := scanConversation(rows)
+			if err != nil {
+				return nil, err
+			}
+			conversations = append(conversations, *conv)
+		}
+		return conversations, rows.Err()
+	})
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestConversationGallery(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	demo, _ := db.CreateConversation("Demo", "")
+	other, _ := db.CreateConversation("Other", "")
+
+	published, err := db.PublishConversation(demo.ID, "launch-demo")
+	if err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if !published.Published || published.GallerySlug != "launch-demo" {
+		t.Errorf("expected the conversation to be published as launch-demo, got %+v", published)
+	}
+
+	if _, err := db.PublishConversation(other.ID, "launch-demo"); err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate for a slug in use, got %v", err)
+	}
+	if _, err := db.PublishConversation(999, "missing"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing conversation, got %v", err)
+	}
+
+	found, err := db.GetPublishedConversation("launch-demo")
+	if err != nil || found.ID != demo.ID {
+		t.Fatalf("expected to find the published conversation, got %+v err=%v", found, err)
+	}
+	list, _ := db.GetPublishedConversations()
+	if len(list) != 1 || list[0].ID != demo.ID {
+		t.Errorf("expected only the published conversation in the gallery, got %+v", list)
+	}
+
+	// Unpublishing hides the conversation but keeps its slug
+	unpublished, err := db.UnpublishConversation(demo.ID)
+	if err != nil {
+		t.Fatalf("failed to unpublish: %v", err)
+	}
+	if unpublished.Published || unpublished.GallerySlug != "launch-demo" {
+		t.Errorf("expected the slug to be kept, got %+v", unpublished)
+	}
+	if _, err := db.GetPublishedConversation("launch-demo"); err != sql.ErrNoRows {
+		t.Errorf("expected an unpublished conversation to be hidden, got %v", err)
+	}
+	if list, _ := db.GetPublishedConversations(); len(list) != 0 {
+		t.Errorf("expected an empty gallery, got %+v", list)
+	}
+}
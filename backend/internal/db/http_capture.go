@@ -0,0 +1,92 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateHTTPCapture stores a recorded request and keeps only the newest keep captures
+// Sets the capture's ID and CreatedAt on success
+func (d *DB) CreateHTTPCapture(capture *models.HTTPCapture, keep int) error {
+	requestHeaders, err := json.Marshal(capture.RequestHeaders)
+	if err != nil {
+		return err
+	}
+	responseHeaders, err := json.Marshal(capture.ResponseHeaders)
+	if err != nil {
+		return err
+	}
+
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(
+			`INSERT INTO http_captures (method, path, query, status, duration_ms, request_headers, request_body,
+				response_headers, response_body, truncated)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			capture.Method, capture.Path, capture.Query, capture.Status, capture.DurationMS, string(requestHeaders),
+			capture.RequestBody, string(responseHeaders), capture.ResponseBody, capture.Truncated,
+		)
+		if err != nil {
+			log.Printf("[DB] CreateHTTPCapture failed: path=%s err=%v", capture.Path, err)
+			return err
+		}
+
+		capture.ID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := d.db.QueryRow(`SELECT created_at FROM http_captures WHERE id = ?`, capture.ID).Scan(&capture.CreatedAt); err != nil {
+			return err
+		}
+
+		// Roll over: drop everything older than the newest keep captures
+		_, err = d.db.Exec(`DELETE FROM http_captures WHERE id <= ?`, capture.ID-int64(keep))
+		return err
+	})
+}
+
+// GetHTTPCaptures retrieves captures with an ID greater than afterID, oldest first
+func (d *DB) GetHTTPCaptures(afterID int64, limit int) ([]models.HTTPCapture, error) {
+	return WithLockResult(d, func() ([]models.HTTPCapture, error) {
+		rows, err := d.db.Query(
+			`SELECT id, method, path, query, status, duration_ms, request_headers, request_body,
+				response_headers, response_body, truncated, created_at
+			FROM http_captures WHERE id > ? ORDER BY id LIMIT ?`,
+			afterID, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		captures := []models.HTTPCapture{}
+		for rows.Next() {
+			var c models.HTTPCapture
+			var requestHeaders, responseHeaders string
+			if err := rows.Scan(&c.ID, &c.Method, &c.Path, &c.Query, &c.Status, &c.DurationMS, &requestHeaders,
+				&c.RequestBody, &responseHeaders, &c.ResponseBody, &c.Truncated, &c.CreatedAt); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(requestHeaders), &c.RequestHeaders); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(responseHeaders), &c.ResponseHeaders); err != nil {
+				return nil, err
+			}
+			captures = append(captures, c)
+		}
+		return captures, rows.Err()
+	})
+}
+
+// DeleteHTTPCaptures deletes every capture and returns how many were deleted
+func (d *DB) DeleteHTTPCaptures() (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		result, err := d.db.Exec(`DELETE FROM http_captures`)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	})
+}
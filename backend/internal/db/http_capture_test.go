@@ -0,0 +1,50 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestHTTPCaptures(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, path := range []string{"/api/avatars", "/api/conversations", "/api/teams"} {
+		capture := &models.HTTPCapture{
+			Method:          "GET",
+			Path:            path,
+			Status:          200,
+			RequestHeaders:  map[string]string{"Accept": "application/json"},
+			ResponseHeaders: map[string]string{"Content-Type": "application/json"},
+			ResponseBody:    "[]",
+		}
+		if err := db.CreateHTTPCapture(capture, 2); err != nil {
+			t.Fatalf("failed to create capture: %v", err)
+		}
+		if capture.ID == 0 || capture.CreatedAt.IsZero() {
+			t.Fatalf("expected ID and CreatedAt to be set, got %+v", capture)
+		}
+	}
+
+	captures, err := db.GetHTTPCaptures(0, 10)
+	if err != nil {
+		t.Fatalf("failed to get captures: %v", err)
+	}
+	if len(captures) != 2 || captures[0].Path != "/api/conversations" || captures[1].Path != "/api/teams" {
+		t.Fatalf("expected the two newest captures oldest first, got %+v", captures)
+	}
+	if captures[0].RequestHeaders["Accept"] != "application/json" || captures[0].ResponseBody != "[]" {
+		t.Errorf("unexpected capture %+v", captures[0])
+	}
+
+	after, _ := db.GetHTTPCaptures(captures[0].ID, 10)
+	if len(after) != 1 || after[0].ID != captures[1].ID {
+		t.Errorf("expected only captures after the given ID, got %+v", after)
+	}
+
+	deleted, err := db.DeleteHTTPCaptures()
+	if err != nil || deleted != 2 {
+		t.Errorf("expected 2 captures deleted, got %d err=%v", deleted, err)
+	}
+}
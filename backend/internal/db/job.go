@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const jobColumns = `id, job_type, status, payload, result, error, created_at, started_at, completed_at`
+
+// scanJob scans a row selected with jobColumns
+func scanJob(scanner interface{ Scan(...any) error }) (*models.Job, error) {
+	var job models.Job
+	var startedAt, completedAt sql.NullTime
+	if err := scanner.Scan(&job.ID, &job.Type, &job.Status, &job.Payload, &job.Result, &job.Error,
+		&job.CreatedAt, &startedAt, &completedAt); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+// CreateJob stores a queued job
+func (d *DB) CreateJob(jobType, payload string) (*models.Job, error) {
+	return WithLockResult(d, func() (*models.Job, error) {
+		result, err := d.db.Exec(`INSERT INTO jobs (job_type, payload) VALUES (?, ?)`, jobType, payload)
+		if err != nil {
+			log.Printf("[DB] CreateJob failed: exec error job_type=%s err=%v", jobType, err)
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[DB] CreateJob completed job_id=%d job_type=%s", id, jobType)
+		return scanJob(d.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	})
+}
+
+// GetJob retrieves a job by ID
+func (d *DB) GetJob(id int64) (*models.Job, error) {
+	return WithLockResult(d, func() (*models.Job, error) {
+		return scanJob(d.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	})
+}
+
+// GetJobsByStatus retrieves the jobs with a status in the order they were created
+func (d *DB) GetJobsByStatus(status string) ([]models.Job, error) {
+	return WithLockResult(d, func() ([]models.Job, error) {
+		rows, err := d.db.Query(`SELECT `+jobColumns+` FROM jobs WHERE status = ? ORDER BY id ASC`, status)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var jobs []models.Job
+		for rows.Next() {
+			job, err := scanJob(rows)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, *job)
+		}
+		return jobs, rows.Err()
+	})
+}
+
+// StartJob marks a queued job as running
+// Returns false if the job is no longer queued
+func (d *DB) StartJob(id int64) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		result, err := d.db.Exec(
+			`UPDATE jobs SET status = ?, started_at = ? WHERE id = ? AND status = ?`,
+			models.JobStatusRunning, time.Now().UTC().Format(sqliteTimeFormat), id, models.JobStatusQueued,
+		)
+		if err != nil {
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected > 0, err
+	})
+}
+
+// FinishJob records the outcome of a job
+// The job succeeds when errMsg is empty and fails otherwise
+func (d *DB) FinishJob(id int64, result, errMsg string) error {
+	status := models.JobStatusSucceeded
+	if errMsg != "" {
+		status = models.JobStatusFailed
+	}
+
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`UPDATE jobs SET status = ?, result = ?, error = ?, completed_at = ? WHERE id = ?`,
+			status, result, errMsg, time.Now().UTC().Format(sqliteTimeFormat), id,
+		)
+		if err != nil {
+			log.Printf("[DB] FinishJob failed: exec error job_id=%d err=%v", id, err)
+		}
+		return err
+	})
+}
+
+// FailRunningJobs marks jobs left running by a previous process as failed
+func (d *DB) FailRunningJobs(errMsg string) (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		result, err := d.db.Exec(
+			`UPDATE jobs SET status = ?, error = ?, completed_at = ? WHERE status = ?`,
+			models.JobStatusFailed, errMsg, time.Now().UTC().Format(sqliteTimeFormat), models.JobStatusRunning,
+		)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	})
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestJobs_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	job, err := db.CreateJob("digest", `{"conversation_id": 1}`)
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	if job.Status != models.JobStatusQueued || job.StartedAt != nil || job.CompletedAt != nil {
+		t.Errorf("expected a queued job, got %+v", job)
+	}
+
+	if started, err := db.StartJob(job.ID); err != nil || !started {
+		t.Fatalf("expected job to start, got started=%v err=%v", started, err)
+	}
+	if started, _ := db.StartJob(job.ID); started {
+		t.Error("expected a running job not to start again")
+	}
+
+	if err := db.FinishJob(job.ID, `{"digest_id": 3}`, ""); err != nil {
+		t.Fatalf("failed to finish job: %v", err)
+	}
+
+	got, err := db.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	if got.Status != models.JobStatusSucceeded || got.Result != `{"digest_id": 3}` ||
+		got.StartedAt == nil || got.CompletedAt == nil {
+		t.Errorf("unexpected finished job: %+v", got)
+	}
+
+	if _, err := db.GetJob(999); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestFailRunningJobs(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	running, _ := db.CreateJob("digest", "{}")
+	db.StartJob(running.ID)
+	queued, _ := db.CreateJob("digest", "{}")
+
+	if n, err := db.FailRunningJobs("interrupted"); err != nil || n != 1 {
+		t.Fatalf("expected 1 failed job, got n=%d err=%v", n, err)
+	}
+
+	if got, _ := db.GetJob(running.ID); got.Status != models.JobStatusFailed || got.Error != "interrupted" {
+		t.Errorf("expected the running job to fail, got %+v", got)
+	}
+	jobs, _ := db.GetJobsByStatus(models.JobStatusQueued)
+	if len(jobs) != 1 || jobs[0].ID != queued.ID {
+		t.Errorf("expected the queued job to stay queued, got %+v", jobs)
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateMessageArtifacts attaches artifacts to a message in the given order
+func (d *DB) CreateMessageArtifacts(messageID int64, artifacts []models.MessageArtifact) error {
+	if len(artifacts) == 0 {
+		return nil
+	}
+
+	return d.WithLock(func() error {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, artifact := range artifacts {
+			if _, err := tx.Exec(
+				`INSERT INTO message_artifacts (message_id, artifact_type, content, file_id) VALUES (?, ?, ?, ?)`,
+				messageID, artifact.Type, artifact.Content, artifact.FileID,
+			); err != nil {
+				log.Printf("[DB] CreateMessageArtifacts failed: exec error message_id=%d err=%v", messageID, err)
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Printf("[DB] CreateMessageArtifacts completed message_id=%d count=%d", messageID, len(artifacts))
+		return nil
+	})
+}
+
+// GetMessageArtifact retrieves an artifact of a message in the given conversation
+// Returns sql.ErrNoRows if the artifact does not exist or belongs to another conversation
+func (d *DB) GetMessageArtifact(conversationID, id int64) (*models.MessageArtifact, error) {
+	return WithLockResult(d, func() (*models.MessageArtifact, error) {
+		var artifact models.MessageArtifact
+		err := d.db.QueryRow(
+			`SELECT a.id, a.message_id, a.artifact_type, a.content, a.file_id, a.created_at
+			FROM message_artifacts a
+			INNER JOIN messages m ON m.id = a.message_id
+			WHERE a.id = ? AND m.conversation_id = ?`,
+			id, conversationID,
+		).Scan(&artifact.ID, &artifact.MessageID, &artifact.Type, &artifact.Content, &artifact.FileID, &artifact.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &artifact, nil
+	})
+}
+
+// GetConversationArtifacts retrieves the artifacts of every message in a conversation, keyed by message ID
+func (d *DB) GetConversationArtifacts(conversationID int64) (map[int64][]models.MessageArtifact, error) {
+	return WithLockResult(d, func() (map[int64][]models.MessageArtifact, error) {
+		rows, err := d.db.Query(
+			`SELECT a.id, a.message_id, a.artifact_type, a.content, a.file_id, a.created_at
+			FROM message_artifacts a
+			INNER JOIN messages m ON m.id = a.message_id
+			WHERE m.conversation_id = ?
+			ORDER BY a.id ASC`,
+			conversationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		artifacts := make(map[int64][]models.MessageArtifact)
+		for rows.Next() {
+			var artifact models.MessageArtifact
+			if err := rows.Scan(&artifact.ID, &artifact.MessageID, &artifact.Type, &artifact.Content, &artifact.FileID, &artifact.CreatedAt); err != nil {
+				return nil, err
+			}
+			artifacts[artifact.MessageID] = append(artifacts[artifact.MessageID], artifact)
+		}
+		return artifacts, rows.Err()
+	})
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestMessageArtifacts(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Analysis", "")
+	msg, _ := db.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "The mean is 42")
+	other, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "thanks")
+
+	err := db.CreateMessageArtifacts(msg.ID, []models.MessageArtifact{
+		{Type: models.ArtifactTypeCode, Content: "print(sum(xs) / len(xs))"},
+		{Type: models.ArtifactTypeLogs, Content: "42.0"},
+		{Type: models.ArtifactTypeImage, FileID: "file_plot"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create artifacts: %v", err)
+	}
+
+	artifacts, err := db.GetConversationArtifacts(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get artifacts: %v", err)
+	}
+	got := artifacts[msg.ID]
+	if len(got) != 3 || got[0].Type != models.ArtifactTypeCode || got[2].FileID != "file_plot" {
+		t.Errorf("unexpected artifacts: %+v", got)
+	}
+	if len(artifacts[other.ID]) != 0 {
+		t.Errorf("expected no artifacts for other message, got %+v", artifacts[other.ID])
+	}
+
+	artifact, err := db.GetMessageArtifact(conv.ID, got[1].ID)
+	if err != nil || artifact.Content != "42.0" || artifact.MessageID != msg.ID {
+		t.Errorf("unexpected artifact: %+v err=%v", artifact, err)
+	}
+	if _, err := db.GetMessageArtifact(conv.ID+1, got[1].ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for another conversation, got %v", err)
+	}
+
+	// Artifacts are removed with their message
+	if err := db.DeleteConversation(conv.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	if _, err := db.GetMessageArtifact(conv.ID, got[0].ID); err == nil {
+		t.Error("expected artifacts to be deleted with the conversation")
+	}
+}
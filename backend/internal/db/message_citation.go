@@ -0,0 +1,70 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateMessageCitations attaches citations to a message in the given order
+func (d *DB) CreateMessageCitations(messageID int64, citations []models.MessageCitation) error {
+	if len(citations) == 0 {
+		return nil
+	}
+
+	return d.WithLock(func() error {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, c := range citations {
+			if _, err := tx.Exec(
+				`INSERT INTO message_citations (message_id, citation_type, marker, file_id, filename, quote, start_index, end_index)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				messageID, c.Type, c.Marker, c.FileID, c.Filename, c.Quote, c.StartIndex, c.EndIndex,
+			); err != nil {
+				log.Printf("[DB] CreateMessageCitations failed: exec error message_id=%d err=%v", messageID, err)
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Printf("[DB] CreateMessageCitations completed message_id=%d count=%d", messageID, len(citations))
+		return nil
+	})
+}
+
+// GetConversationCitations retrieves the citations of every message in a conversation, keyed by message ID
+func (d *DB) GetConversationCitations(conversationID int64) (map[int64][]models.MessageCitation, error) {
+	return WithLockResult(d, func() (map[int64][]models.MessageCitation, error) {
+		rows, err := d.db.Query(
+			`SELECT c.id, c.message_id, c.citation_type, c.marker, c.file_id, c.filename, c.quote,
+				c.start_index, c.end_index, c.created_at
+			FROM message_citations c
+			INNER JOIN messages m ON m.id = c.message_id
+			WHERE m.conversation_id = ?
+			ORDER BY c.id ASC`,
+			conversationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		citations := make(map[int64][]models.MessageCitation)
+		for rows.Next() {
+			var c models.MessageCitation
+			if err := rows.Scan(&c.ID, &c.MessageID, &c.Type, &c.Marker, &c.FileID, &c.Filename, &c.Quote,
+				&c.StartIndex, &c.EndIndex, &c.CreatedAt); err != nil {
+				return nil, err
+			}
+			citations[c.MessageID] = append(citations[c.MessageID], c)
+		}
+		return citations, rows.Err()
+	})
+}
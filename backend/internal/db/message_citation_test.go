@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestMessageCitations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Research", "")
+	msg, _ := db.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "Sales grew 10%【4:0†source】")
+
+	err := db.CreateMessageCitations(msg.ID, []models.MessageCitation{
+		{Type: "file_citation", Marker: "【4:0†source】", FileID: "file_report", Filename: "report.pdf", StartIndex: 15, EndIndex: 27},
+	})
+	if err != nil {
+		t.Fatalf("failed to create citations: %v", err)
+	}
+
+	citations, err := db.GetConversationCitations(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get citations: %v", err)
+	}
+	got := citations[msg.ID]
+	if len(got) != 1 || got[0].Filename != "report.pdf" || got[0].Marker != "【4:0†source】" || got[0].EndIndex != 27 {
+		t.Errorf("unexpected citations: %+v", got)
+	}
+
+	// Citations are removed with their message
+	if err := db.DeleteConversation(conv.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	citations, err = db.GetConversationCitations(conv.ID)
+	if err != nil || len(citations) != 0 {
+		t.Errorf("expected no citations after delete, got %+v err=%v", citations, err)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import "log"
+
+// RecordMessageModel records the model that generated a message, for labeling AI-generated content
+func (d *DB) RecordMessageModel(messageID int64, model string) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT INTO message_models (message_id, model) VALUES (?, ?)
+			ON CONFLICT(message_id) DO UPDATE SET model = excluded.model`,
+			messageID, model,
+		)
+		if err != nil {
+			log.Printf("[DB] RecordMessageModel failed: exec error message_id=%d err=%v", messageID, err)
+		}
+		return err
+	})
+}
+
+// GetConversationMessageModels returns the model that generated each message of a conversation
+// that has one recorded, keyed by message ID
+func (d *DB) GetConversationMessageModels(conversationID int64) (map[int64]string, error) {
+	return WithLockResult(d, func() (map[int64]string, error) {
+		rows, err := d.db.Query(
+			`SELECT mm.message_id, mm.model
+			FROM message_models mm
+			INNER JOIN messages m ON m.id = mm.message_id
+			WHERE m.conversation_id = ?`,
+			conversationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		messageModels := make(map[int64]string)
+		for rows.Next() {
+			var messageID int64
+			var model string
+			if err := rows.Scan(&messageID, &model); err != nil {
+				return nil, err
+			}
+			messageModels[messageID] = model
+		}
+		return messageModels, rows.Err()
+	})
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestMessageModels(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Labels", "")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hello")
+	reply, _ := db.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "Hi there")
+
+	if err := db.RecordMessageModel(reply.ID, "gpt-4o"); err != nil {
+		t.Fatalf("failed to record message model: %v", err)
+	}
+	if err := db.RecordMessageModel(reply.ID, "gpt-4o-mini"); err != nil {
+		t.Fatalf("failed to replace message model: %v", err)
+	}
+
+	messageModels, err := db.GetConversationMessageModels(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get message models: %v", err)
+	}
+	if len(messageModels) != 1 || messageModels[reply.ID] != "gpt-4o-mini" {
+		t.Errorf("unexpected message models: %+v", messageModels)
+	}
+
+	// Models are removed with their message
+	if err := db.DeleteConversation(conv.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	messageModels, err = db.GetConversationMessageModels(conv.ID)
+	if err != nil || len(messageModels) != 0 {
+		t.Errorf("expected no message models after delete, got %+v err=%v", messageModels, err)
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// RecordMessageTranslation records a message in the conversation language, next to the displayed content
+func (d *DB) RecordMessageTranslation(messageID int64, translation models.MessageTranslation) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT INTO message_translations (message_id, language, content) VALUES (?, ?, ?)
+			ON CONFLICT(message_id) DO UPDATE SET language = excluded.language, content = excluded.content`,
+			messageID, translation.Language, translation.Content,
+		)
+		if err != nil {
+			log.Printf("[DB] RecordMessageTranslation failed: exec error message_id=%d err=%v", messageID, err)
+		}
+		return err
+	})
+}
+
+// GetConversationTranslations returns the translations of the messages of a conversation, keyed by message ID
+func (d *DB) GetConversationTranslations(conversationID int64) (map[int64]models.MessageTranslation, error) {
+	return WithLockResult(d, func() (map[int64]models.MessageTranslation, error) {
+		rows, err := d.db.Query(
+			`SELECT mt.message_id, mt.language, mt.content
+			FROM message_translations mt
+			INNER JOIN messages m ON m.id = mt.message_id
+			WHERE m.conversation_id = ?`,
+			conversationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		translations := make(map[int64]models.MessageTranslation)
+		for rows.Next() {
+			var messageID int64
+			var t models.MessageTranslation
+			if err := rows.Scan(&messageID, &t.Language, &t.Content); err != nil {
+				return nil, err
+			}
+			translations[messageID] = t
+		}
+		return translations, rows.Err()
+	})
+}
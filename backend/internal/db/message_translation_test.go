@@ -0,0 +1,47 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestMessageTranslations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Translated", "")
+	conv.Language = "English"
+	conv.DisplayLanguage = "Japanese"
+	updated, err := db.UpdateConversation(conv)
+	if err != nil {
+		t.Fatalf("failed to update conversation: %v", err)
+	}
+	if updated.Language != "English" || updated.DisplayLanguage != "Japanese" {
+		t.Errorf("expected the languages to be saved, got %+v", updated)
+	}
+
+	question, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "こんにちは")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "untranslated")
+
+	if err := db.RecordMessageTranslation(question.ID, models.MessageTranslation{Language: "English", Content: "Hi"}); err != nil {
+		t.Fatalf("failed to record translation: %v", err)
+	}
+	if err := db.RecordMessageTranslation(question.ID, models.MessageTranslation{Language: "English", Content: "Hello"}); err != nil {
+		t.Fatalf("failed to replace translation: %v", err)
+	}
+
+	translations, err := db.GetConversationTranslations(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get translations: %v", err)
+	}
+	if len(translations) != 1 || translations[question.ID].Content != "Hello" || translations[question.ID].Language != "English" {
+		t.Errorf("unexpected translations: %+v", translations)
+	}
+
+	// Translations are removed with their message
+	db.DeleteConversation(conv.ID)
+	if translations, err := db.GetConversationTranslations(conv.ID); err != nil || len(translations) != 0 {
+		t.Errorf("expected no translations after delete, got %+v err=%v", translations, err)
+	}
+}
@@ -1,8 +1,18 @@
 package db
 
+import (
+	"log"
+	"strings"
+
+	"multi-avatar-chat/internal/logic"
+)
+
 // Migrate runs all database migrations
 func (d *DB) Migrate() error {
 	return d.WithLock(func() error {
+		// Migrations may rewrite avatars and participation, so drop cached lookups afterwards
+		defer d.invalidateAll()
+
 		// Create avatars table
 		_, err := d.db.Exec(`
 			CREATE TABLE IF NOT EXISTS avatars (
@@ -49,7 +59,7 @@ func (d *DB) Migrate() error {
 			CREATE TABLE IF NOT EXISTS messages (
 				id INTEGER PRIMARY KEY AUTOINCREMENT,
 				conversation_id INTEGER NOT NULL,
-				sender_type TEXT NOT NULL CHECK(sender_type IN ('user', 'avatar')),
+				sender_type TEXT NOT NULL CHECK(sender_type IN ('user', 'avatar', 'system')),
 				sender_id INTEGER,
 				content TEXT NOT NULL,
 				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -60,11 +70,591 @@ func (d *DB) Migrate() error {
 			return err
 		}
 
+		// Allow system messages in databases created before they existed
+		if err := d.migrateMessagesSenderTypeCheck(); err != nil {
+			return err
+		}
+
+		// Create teams table
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS teams (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+				description TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create team_members junction table
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS team_members (
+				team_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				PRIMARY KEY (team_id, avatar_id),
+				FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create conversation_links table (cross-references between conversations)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS conversation_links (
+				message_id INTEGER NOT NULL,
+				source_conversation_id INTEGER NOT NULL,
+				target_conversation_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (message_id, target_conversation_id),
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+				FOREIGN KEY (source_conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (target_conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create conversation_digests table
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS conversation_digests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				last_message_id INTEGER NOT NULL,
+				message_count INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create notification_preferences table (per-recipient email settings)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS notification_preferences (
+				email TEXT NOT NULL COLLATE NOCASE,
+				event_type TEXT NOT NULL,
+				enabled INTEGER NOT NULL DEFAULT 1,
+				PRIMARY KEY (email, event_type)
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create offline_forwards table (messages waiting for the OpenAI API to become available)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS offline_forwards (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				thread_id TEXT NOT NULL,
+				content TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create conversation_events table (activity timeline of broadcast events)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS conversation_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				event_type TEXT NOT NULL,
+				data TEXT NOT NULL DEFAULT '{}',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create purge_log table (audit trail of data purges; never stores the purged content)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS purge_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				scope TEXT NOT NULL,
+				target TEXT NOT NULL,
+				conversation_ids TEXT NOT NULL DEFAULT '[]',
+				messages_deleted INTEGER NOT NULL DEFAULT 0,
+				remote_deleted INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create audit_log table (append-only record of administrative actions)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				actor TEXT NOT NULL,
+				action TEXT NOT NULL,
+				target_type TEXT NOT NULL,
+				target_id TEXT NOT NULL DEFAULT '',
+				changes TEXT NOT NULL DEFAULT '{}',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Reject updates and deletes so the audit trail cannot be rewritten through the application
+		for _, op := range []string{"UPDATE", "DELETE"} {
+			_, err = d.db.Exec(`
+				CREATE TRIGGER IF NOT EXISTS audit_log_no_` + strings.ToLower(op) + ` BEFORE ` + op + ` ON audit_log
+				BEGIN
+					SELECT RAISE(ABORT, 'audit_log is append-only');
+				END
+			`)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Create notification_log table (prevents sending the same notification twice)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS notification_log (
+				event_type TEXT NOT NULL,
+				message_id INTEGER NOT NULL,
+				sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (event_type, message_id)
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create message_artifacts table (code interpreter outputs attached to messages)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS message_artifacts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				message_id INTEGER NOT NULL,
+				artifact_type TEXT NOT NULL,
+				content TEXT NOT NULL DEFAULT '',
+				file_id TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create message_citations table (sources annotated in avatar messages)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS message_citations (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				message_id INTEGER NOT NULL,
+				citation_type TEXT NOT NULL,
+				marker TEXT NOT NULL DEFAULT '',
+				file_id TEXT NOT NULL DEFAULT '',
+				filename TEXT NOT NULL DEFAULT '',
+				quote TEXT NOT NULL DEFAULT '',
+				start_index INTEGER NOT NULL DEFAULT 0,
+				end_index INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create jobs table (long-running operations executed in the background)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS jobs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_type TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'queued',
+				payload TEXT NOT NULL DEFAULT '{}',
+				result TEXT NOT NULL DEFAULT '',
+				error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				started_at DATETIME,
+				completed_at DATETIME
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create runs table (OpenAI runs started by avatar watchers, for the stuck run reaper)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS runs (
+				id TEXT PRIMARY KEY,
+				conversation_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				thread_id TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'running',
+				error TEXT NOT NULL DEFAULT '',
+				started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				completed_at DATETIME,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create dead_letters table (avatar responses that failed after retries)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS dead_letters (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				error TEXT NOT NULL DEFAULT '',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				context TEXT NOT NULL DEFAULT '{}',
+				status TEXT NOT NULL DEFAULT 'open',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				resolved_at DATETIME,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create redactions table (what was redacted from user messages, without the values)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS redactions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				policy TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				count INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create watcher_states table (how far each avatar watcher has processed a conversation)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS watcher_states (
+				conversation_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				last_sequence INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (conversation_id, avatar_id),
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create responded_to table (messages an avatar has responded to, so it never responds twice)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS responded_to (
+				avatar_id INTEGER NOT NULL,
+				trigger_message_id INTEGER NOT NULL,
+				conversation_id INTEGER NOT NULL,
+				status TEXT NOT NULL DEFAULT 'responding',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (avatar_id, trigger_message_id),
+				FOREIGN KEY (trigger_message_id) REFERENCES messages(id) ON DELETE CASCADE,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create http_captures table (API requests and responses recorded in debug mode)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS http_captures (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				method TEXT NOT NULL,
+				path TEXT NOT NULL,
+				query TEXT NOT NULL DEFAULT '',
+				status INTEGER NOT NULL,
+				duration_ms INTEGER NOT NULL,
+				request_headers TEXT NOT NULL DEFAULT '{}',
+				request_body TEXT NOT NULL DEFAULT '',
+				response_headers TEXT NOT NULL DEFAULT '{}',
+				response_body TEXT NOT NULL DEFAULT '',
+				truncated INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create pending_thread_deletions table (OpenAI threads of deleted conversations that could not be deleted yet)
+		// Rows outlive their conversation, so there is no foreign key
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS pending_thread_deletions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				thread_id TEXT NOT NULL UNIQUE,
+				conversation_id INTEGER NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create response_experiments table (avatars that also answer with a comparison model)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS response_experiments (
+				avatar_id INTEGER PRIMARY KEY,
+				model TEXT NOT NULL,
+				broadcast TEXT NOT NULL DEFAULT 'primary',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create response_comparisons table (both responses of an experiment, side by side)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS response_comparisons (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				trigger_message_id INTEGER NOT NULL,
+				message_id INTEGER,
+				broadcast TEXT NOT NULL,
+				primary_model TEXT NOT NULL DEFAULT '',
+				primary_content TEXT NOT NULL DEFAULT '',
+				primary_latency_ms INTEGER NOT NULL DEFAULT 0,
+				primary_error TEXT NOT NULL DEFAULT '',
+				comparison_model TEXT NOT NULL DEFAULT '',
+				comparison_content TEXT NOT NULL DEFAULT '',
+				comparison_latency_ms INTEGER NOT NULL DEFAULT 0,
+				comparison_error TEXT NOT NULL DEFAULT '',
+				preferred TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE,
+				FOREIGN KEY (trigger_message_id) REFERENCES messages(id) ON DELETE CASCADE,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create prompt_experiments table (avatars splitting messages between two prompts)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS prompt_experiments (
+				avatar_id INTEGER PRIMARY KEY,
+				prompt_a TEXT NOT NULL,
+				prompt_b TEXT NOT NULL,
+				split_b INTEGER NOT NULL DEFAULT 50,
+				started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create prompt_trials table (the variant used for each judged message and the response it led to)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS prompt_trials (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				trigger_message_id INTEGER NOT NULL,
+				variant TEXT NOT NULL,
+				should_respond INTEGER NOT NULL DEFAULT 0,
+				message_id INTEGER,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE,
+				FOREIGN KEY (trigger_message_id) REFERENCES messages(id) ON DELETE CASCADE,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create avatar_scripts table (behavior scripts run on each incoming message)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS avatar_scripts (
+				avatar_id INTEGER PRIMARY KEY,
+				source TEXT NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				last_error_at DATETIME,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create message_models table (the model that generated each AI-generated message)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS message_models (
+				message_id INTEGER PRIMARY KEY,
+				model TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create message_translations table (messages in the conversation language when they were translated)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS message_translations (
+				message_id INTEGER PRIMARY KEY,
+				language TEXT NOT NULL,
+				content TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// thread_forwards used to be keyed by content hash; it only holds recent forwards, so it is recreated
+		hashKeyed, err := d.columnExists("thread_forwards", "content_hash")
+		if err != nil {
+			return err
+		}
+		if hashKeyed {
+			if _, err := d.db.Exec(`DROP TABLE thread_forwards`); err != nil {
+				return err
+			}
+		}
+
+		// Create thread_forwards table (messages recently added to each OpenAI thread)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS thread_forwards (
+				thread_id TEXT NOT NULL,
+				message_id INTEGER NOT NULL,
+				forwarded_at DATETIME NOT NULL,
+				PRIMARY KEY (thread_id, message_id)
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create default_avatars table (avatars added to conversations created without avatar_ids, in order)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS default_avatars (
+				avatar_id INTEGER PRIMARY KEY,
+				position INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create user_presence table (whether the user is viewing each conversation, as reported by clients)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS user_presence (
+				conversation_id INTEGER PRIMARY KEY,
+				status TEXT NOT NULL,
+				away_sequence INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create avatar_triggers table (keywords and patterns that make an avatar respond in a conversation)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS avatar_triggers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_id INTEGER NOT NULL,
+				avatar_id INTEGER NOT NULL,
+				pattern TEXT NOT NULL,
+				regex INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Create assistant_drift table (avatars whose assistant instructions were changed outside the application)
+		_, err = d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS assistant_drift (
+				avatar_id INTEGER PRIMARY KEY,
+				assistant_id TEXT NOT NULL,
+				instructions TEXT NOT NULL,
+				detected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (avatar_id) REFERENCES avatars(id) ON DELETE CASCADE
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
 		// Create indexes for better query performance
 		indexes := []string{
-			"CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id)",
+			"CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id, id)",
 			"CREATE INDEX IF NOT EXISTS idx_conversation_avatars_conversation ON conversation_avatars(conversation_id)",
 			"CREATE INDEX IF NOT EXISTS idx_conversation_avatars_avatar ON conversation_avatars(avatar_id)",
+			"CREATE INDEX IF NOT EXISTS idx_team_members_avatar ON team_members(avatar_id)",
+			"CREATE INDEX IF NOT EXISTS idx_conversation_links_target ON conversation_links(target_conversation_id)",
+			"CREATE INDEX IF NOT EXISTS idx_conversation_digests_conversation ON conversation_digests(conversation_id)",
+			"CREATE INDEX IF NOT EXISTS idx_offline_forwards_conversation ON offline_forwards(conversation_id)",
+			"CREATE INDEX IF NOT EXISTS idx_conversation_events_conversation ON conversation_events(conversation_id, id)",
+			"CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id)",
+			"CREATE INDEX IF NOT EXISTS idx_message_artifacts_message ON message_artifacts(message_id)",
+			"CREATE INDEX IF NOT EXISTS idx_message_citations_message ON message_citations(message_id)",
+			"CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status)",
+			"CREATE INDEX IF NOT EXISTS idx_runs_status ON runs(status)",
+			"CREATE INDEX IF NOT EXISTS idx_dead_letters_status ON dead_letters(status)",
+			"CREATE INDEX IF NOT EXISTS idx_redactions_conversation ON redactions(conversation_id)",
+			"CREATE INDEX IF NOT EXISTS idx_responded_to_status ON responded_to(status)",
+			"CREATE INDEX IF NOT EXISTS idx_response_comparisons_conversation ON response_comparisons(conversation_id)",
+			"CREATE INDEX IF NOT EXISTS idx_prompt_trials_avatar ON prompt_trials(avatar_id, created_at)",
+			"CREATE INDEX IF NOT EXISTS idx_prompt_trials_message ON prompt_trials(message_id)",
+			"CREATE INDEX IF NOT EXISTS idx_thread_forwards_forwarded_at ON thread_forwards(forwarded_at)",
+			"CREATE INDEX IF NOT EXISTS idx_avatar_triggers_conversation ON avatar_triggers(conversation_id, avatar_id)",
 		}
 
 		for _, idx := range indexes {
@@ -73,11 +663,139 @@ func (d *DB) Migrate() error {
 			}
 		}
 
+		// idx_messages_conversation_id replaces the single-column index, which every query it served can use
+		if _, err := d.db.Exec(`DROP INDEX IF EXISTS idx_messages_conversation`); err != nil {
+			return err
+		}
+
 		// Add thread_id column to conversation_avatars table if it doesn't exist
 		if err := d.migrateConversationAvatarsThreadID(); err != nil {
 			return err
 		}
 
+		// Add response_style column to conversations table if it doesn't exist
+		if err := d.addColumnIfNotExists("conversations", "response_style", "TEXT NOT NULL DEFAULT 'normal'"); err != nil {
+			return err
+		}
+
+		// Add redaction_policy column to conversations table if it doesn't exist
+		if err := d.addColumnIfNotExists("conversations", "redaction_policy", "TEXT NOT NULL DEFAULT 'off'"); err != nil {
+			return err
+		}
+
+		// Add system_instructions column to conversations table (appended to every avatar's run instructions)
+		if err := d.addColumnIfNotExists("conversations", "system_instructions", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+
+		// Add max_context_messages column to conversations table (0 lets runs read the whole thread)
+		if err := d.addColumnIfNotExists("conversations", "max_context_messages", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
+		// Add expires_at column to conversations table (NULL keeps the conversation forever)
+		if err := d.addColumnIfNotExists("conversations", "expires_at", "DATETIME"); err != nil {
+			return err
+		}
+
+		// Add translation language columns to conversations table (empty turns translation off)
+		if err := d.addColumnIfNotExists("conversations", "language", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		if err := d.addColumnIfNotExists("conversations", "display_language", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+
+		// Add OpenAI routing columns to conversations table (empty uses the server's organization and project)
+		if err := d.addColumnIfNotExists("conversations", "openai_organization", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		if err := d.addColumnIfNotExists("conversations", "openai_project", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+
+		// Add public gallery columns to conversations table (slugs are unique; NULL until first published)
+		if err := d.addColumnIfNotExists("conversations", "published", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if err := d.addColumnIfNotExists("conversations", "gallery_slug", "TEXT"); err != nil {
+			return err
+		}
+		if _, err := d.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_gallery_slug ON conversations(gallery_slug)`); err != nil {
+			return err
+		}
+
+		// Add display metadata columns to avatars table and backfill existing rows
+		if err := d.addColumnIfNotExists("avatars", "color", "TEXT"); err != nil {
+			return err
+		}
+		if err := d.addColumnIfNotExists("avatars", "emoji", "TEXT"); err != nil {
+			return err
+		}
+		if err := d.backfillAvatarDisplay(); err != nil {
+			return err
+		}
+
+		// Add pre-filter tuning columns to avatars table
+		if err := d.addColumnIfNotExists("avatars", "keywords", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+			return err
+		}
+		if err := d.addColumnIfNotExists("avatars", "relevance_threshold", "REAL NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
+		// Add capability flags to avatars table
+		for _, column := range []string{"can_search", "can_code", "can_cite"} {
+			if err := d.addColumnIfNotExists("avatars", column, "INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+		}
+
+		// Add formatting rules to avatars table (JSON-encoded models.FormattingRules)
+		if err := d.addColumnIfNotExists("avatars", "formatting_rules", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+			return err
+		}
+
+		// Add isolated_context to avatars table (avatars that only see user messages)
+		if err := d.addColumnIfNotExists("avatars", "isolated_context", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
+		// Add needs_relink to avatars table (set when the avatar's assistant was deleted on OpenAI)
+		if err := d.addColumnIfNotExists("avatars", "needs_relink", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
+		// Add updated_at to avatars and conversations; existing rows take their creation time
+		for _, table := range []string{"avatars", "conversations"} {
+			if err := d.addColumnIfNotExists(table, "updated_at", "DATETIME"); err != nil {
+				return err
+			}
+			if _, err := d.db.Exec(`UPDATE ` + table + ` SET updated_at = created_at WHERE updated_at IS NULL`); err != nil {
+				return err
+			}
+		}
+
+		// Add the normalized name avatars are kept unique by
+		if err := d.migrateAvatarNameKeys(); err != nil {
+			return err
+		}
+
+		// Add error_code column to runs table to classify failed runs
+		if err := d.addColumnIfNotExists("runs", "error_code", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+
+		// Number messages per conversation so their order does not depend on IDs or timestamps
+		if err := d.migrateMessageSequences(); err != nil {
+			return err
+		}
+
+		// Add silent column to messages table (messages watchers pass over, such as imported history)
+		if err := d.addColumnIfNotExists("messages", "silent", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
 		// Migrate existing conversation thread_ids to avatar-specific threads
 		if err := d.migrateExistingConversationThreads(); err != nil {
 			return err
@@ -89,14 +807,110 @@ func (d *DB) Migrate() error {
 
 // migrateConversationAvatarsThreadID adds thread_id column to conversation_avatars table if it doesn't exist
 func (d *DB) migrateConversationAvatarsThreadID() error {
-	// Check if thread_id column exists
-	rows, err := d.db.Query("PRAGMA table_info(conversation_avatars)")
+	return d.addColumnIfNotExists("conversation_avatars", "thread_id", "TEXT")
+}
+
+// migrateMessagesSenderTypeCheck rebuilds the messages table when its CHECK constraint
+// predates the 'system' sender type (SQLite cannot alter constraints in place)
+func (d *DB) migrateMessagesSenderTypeCheck() error {
+	var createSQL string
+	if err := d.db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='messages'`).Scan(&createSQL); err != nil {
+		return err
+	}
+	if strings.Contains(createSQL, "'system'") {
+		return nil
+	}
+
+	log.Printf("[DB] Rebuilding messages table to allow system messages")
+
+	// Foreign keys must be off so dropping the old table does not cascade
+	if _, err := d.db.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer d.db.Exec("PRAGMA foreign_keys = ON")
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`CREATE TABLE messages_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			sender_type TEXT NOT NULL CHECK(sender_type IN ('user', 'avatar', 'system')),
+			sender_id INTEGER,
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO messages_new (id, conversation_id, sender_type, sender_id, content, created_at)
+			SELECT id, conversation_id, sender_type, sender_id, content, created_at FROM messages`,
+		`DROP TABLE messages`,
+		`ALTER TABLE messages_new RENAME TO messages`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// migrateMessageSequences adds the per-conversation message sequence columns
+// Existing messages are numbered in display order and each conversation's counter is set to its last number
+func (d *DB) migrateMessageSequences() error {
+	exists, err := d.columnExists("messages", "sequence")
 	if err != nil {
 		return err
 	}
+
+	if !exists {
+		log.Printf("[DB] Numbering existing messages with per-conversation sequences")
+
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		statements := []string{
+			`ALTER TABLE messages ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE conversations ADD COLUMN message_sequence INTEGER NOT NULL DEFAULT 0`,
+			`UPDATE messages SET sequence = numbered.sequence
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS sequence
+				FROM messages
+			) AS numbered
+			WHERE messages.id = numbered.id`,
+			`UPDATE conversations SET message_sequence =
+				(SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE messages.conversation_id = conversations.id)`,
+		}
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	_, err = d.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_conversation_sequence ON messages(conversation_id, sequence)`)
+	return err
+}
+
+// columnExists checks if a column exists in the given table
+func (d *DB) columnExists(table, column string) (bool, error) {
+	rows, err := d.db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, err
+	}
 	defer rows.Close()
 
-	columnExists := false
 	for rows.Next() {
 		var cid int
 		var name string
@@ -106,18 +920,58 @@ func (d *DB) migrateConversationAvatarsThreadID() error {
 		var pk int
 
 		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
-			return err
+			return false, err
 		}
-		if name == "thread_id" {
-			columnExists = true
-			break
+		if name == column {
+			return true, nil
 		}
 	}
 
-	if !columnExists {
-		// Add thread_id column
-		_, err := d.db.Exec("ALTER TABLE conversation_avatars ADD COLUMN thread_id TEXT")
-		if err != nil {
+	return false, rows.Err()
+}
+
+// addColumnIfNotExists adds a column with the given definition if it doesn't exist yet
+func (d *DB) addColumnIfNotExists(table, column, definition string) error {
+	exists, err := d.columnExists(table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = d.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// backfillAvatarDisplay assigns a color and emoji to avatars created before display metadata existed
+func (d *DB) backfillAvatarDisplay() error {
+	rows, err := d.db.Query(`SELECT id, name FROM avatars WHERE color IS NULL OR color = '' OR emoji IS NULL OR emoji = ''`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id   int64
+		name string
+	}
+	var avatars []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.name); err != nil {
+			rows.Close()
+			return err
+		}
+		avatars = append(avatars, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range avatars {
+		display := logic.AssignAvatarDisplay(p.name)
+		if _, err := d.db.Exec(`UPDATE avatars SET color = ?, emoji = ? WHERE id = ?`, display.Color, display.Emoji, p.id); err != nil {
 			return err
 		}
 	}
@@ -125,6 +979,55 @@ func (d *DB) migrateConversationAvatarsThreadID() error {
 	return nil
 }
 
+// migrateAvatarNameKeys adds the name_key column with a unique index and backfills existing avatars
+// Avatars whose names already collide keep an empty key, so the index can be created; they should be renamed
+func (d *DB) migrateAvatarNameKeys() error {
+	if err := d.addColumnIfNotExists("avatars", "name_key", "TEXT"); err != nil {
+		return err
+	}
+
+	rows, err := d.db.Query(`SELECT id, name FROM avatars WHERE name_key IS NULL ORDER BY id`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id   int64
+		name string
+	}
+	var avatars []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.name); err != nil {
+			rows.Close()
+			return err
+		}
+		avatars = append(avatars, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range avatars {
+		key := logic.NormalizeAvatarName(p.name)
+		var taken int
+		if err := d.db.QueryRow(`SELECT COUNT(*) FROM avatars WHERE name_key = ?`, key).Scan(&taken); err != nil {
+			return err
+		}
+		if taken > 0 {
+			log.Printf("[DB] Warning: avatar name is already used by another avatar, rename it avatar_id=%d name=%q", p.id, p.name)
+			continue
+		}
+		if _, err := d.db.Exec(`UPDATE avatars SET name_key = ? WHERE id = ?`, key, p.id); err != nil {
+			return err
+		}
+	}
+
+	_, err = d.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_avatars_name_key ON avatars(name_key)`)
+	return err
+}
+
 // migrateExistingConversationThreads migrates existing conversation thread_ids to avatar-specific threads
 // This is a one-time migration that creates new threads for avatars that don't have thread_ids yet
 // Note: This migration does not copy message history - it starts fresh threads for each avatar
@@ -144,7 +1047,7 @@ func (d *DB) migrateExistingConversationThreads() error {
 
 	var conversationsToMigrate []struct {
 		conversationID int64
-		threadID        string
+		threadID       string
 	}
 
 	for rows.Next() {
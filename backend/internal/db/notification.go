@@ -0,0 +1,123 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// SetNotificationPreference enables or disables an event type for a recipient
+func (d *DB) SetNotificationPreference(email, eventType string, enabled bool) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(`
+			INSERT INTO notification_preferences (email, event_type, enabled) VALUES (?, ?, ?)
+			ON CONFLICT(email, event_type) DO UPDATE SET enabled = excluded.enabled
+		`, email, eventType, enabled)
+		if err != nil {
+			log.Printf("[DB] SetNotificationPreference failed: email=%s event_type=%s err=%v", email, eventType, err)
+		}
+		return err
+	})
+}
+
+// GetNotificationPreferences retrieves all notification preferences ordered by recipient
+func (d *DB) GetNotificationPreferences() ([]models.NotificationPreference, error) {
+	return WithLockResult(d, func() ([]models.NotificationPreference, error) {
+		rows, err := d.db.Query(`
+			SELECT email, event_type, enabled FROM notification_preferences
+			ORDER BY email COLLATE NOCASE, event_type
+		`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var prefs []models.NotificationPreference
+		for rows.Next() {
+			var pref models.NotificationPreference
+			if err := rows.Scan(&pref.Email, &pref.EventType, &pref.Enabled); err != nil {
+				return nil, err
+			}
+			prefs = append(prefs, pref)
+		}
+
+		return prefs, rows.Err()
+	})
+}
+
+// DeleteNotificationPreferences removes all preferences of a recipient
+func (d *DB) DeleteNotificationPreferences(email string) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(`DELETE FROM notification_preferences WHERE email = ?`, email)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// GetNotificationRecipients retrieves the email addresses subscribed to an event type
+func (d *DB) GetNotificationRecipients(eventType string) ([]string, error) {
+	return WithLockResult(d, func() ([]string, error) {
+		rows, err := d.db.Query(
+			`SELECT email FROM notification_preferences WHERE event_type = ? AND enabled = 1 ORDER BY email`,
+			eventType,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var emails []string
+		for rows.Next() {
+			var email string
+			if err := rows.Scan(&email); err != nil {
+				return nil, err
+			}
+			emails = append(emails, email)
+		}
+
+		return emails, rows.Err()
+	})
+}
+
+// MarkNotificationSent records that a notification was sent for a message
+// Returns false if it had already been recorded
+func (d *DB) MarkNotificationSent(eventType string, messageID int64) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		result, err := d.db.Exec(
+			`INSERT OR IGNORE INTO notification_log (event_type, message_id) VALUES (?, ?)`,
+			eventType, messageID,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		return rows > 0, nil
+	})
+}
+
+// ReleaseNotification forgets a notification recorded by MarkNotificationSent whose email could not be sent
+// so a later attempt can send it
+func (d *DB) ReleaseNotification(eventType string, messageID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`DELETE FROM notification_log WHERE event_type = ? AND message_id = ?`,
+			eventType, messageID,
+		)
+		return err
+	})
+}
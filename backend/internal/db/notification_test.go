@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := db.SetNotificationPreference("a@example.com", "digest", true); err != nil {
+		t.Fatalf("failed to set preference: %v", err)
+	}
+	db.SetNotificationPreference("b@example.com", "digest", true)
+	// Updating is case-insensitive on the email address
+	if err := db.SetNotificationPreference("B@example.com", "digest", false); err != nil {
+		t.Fatalf("failed to update preference: %v", err)
+	}
+
+	prefs, err := db.GetNotificationPreferences()
+	if err != nil {
+		t.Fatalf("failed to get preferences: %v", err)
+	}
+	if len(prefs) != 2 {
+		t.Fatalf("expected 2 preferences, got %d", len(prefs))
+	}
+
+	recipients, err := db.GetNotificationRecipients("digest")
+	if err != nil {
+		t.Fatalf("failed to get recipients: %v", err)
+	}
+	if len(recipients) != 1 || recipients[0] != "a@example.com" {
+		t.Errorf("expected only a@example.com, got %v", recipients)
+	}
+
+	if err := db.DeleteNotificationPreferences("a@example.com"); err != nil {
+		t.Fatalf("failed to delete preferences: %v", err)
+	}
+	if err := db.DeleteNotificationPreferences("a@example.com"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestMarkNotificationSent(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	sent, err := db.MarkNotificationSent("idle_question", 1)
+	if err != nil || !sent {
+		t.Fatalf("expected first mark to succeed, got sent=%v err=%v", sent, err)
+	}
+
+	sent, err = db.MarkNotificationSent("idle_question", 1)
+	if err != nil || sent {
+		t.Errorf("expected second mark to be ignored, got sent=%v err=%v", sent, err)
+	}
+
+	// A released notification can be marked again
+	if err := db.ReleaseNotification("idle_question", 1); err != nil {
+		t.Fatalf("ReleaseNotification failed: %v", err)
+	}
+	if sent, _ := db.MarkNotificationSent("idle_question", 1); !sent {
+		t.Error("expected a released notification to be marked again")
+	}
+}
+
+func TestGetLastMessage(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	if _, err := db.GetLastMessage(conv.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "first")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "second")
+
+	msg, err := db.GetLastMessage(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get last message: %v", err)
+	}
+	if msg.Content != "second" {
+		t.Errorf("expected 'second', got %q", msg.Content)
+	}
+}
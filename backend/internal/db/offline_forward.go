@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// EnqueueOfflineForward stores a message to be added to an avatar's thread once the OpenAI API is available
+func (d *DB) EnqueueOfflineForward(conversationID, messageID, avatarID int64, threadID, content string) (*models.OfflineForward, error) {
+	return WithLockResult(d, func() (*models.OfflineForward, error) {
+		log.Printf("[DB] EnqueueOfflineForward started conversation_id=%d message_id=%d avatar_id=%d",
+			conversationID, messageID, avatarID)
+
+		result, err := d.db.Exec(
+			`INSERT INTO offline_forwards (conversation_id, message_id, avatar_id, thread_id, content) VALUES (?, ?, ?, ?, ?)`,
+			conversationID, messageID, avatarID, threadID, content,
+		)
+		if err != nil {
+			log.Printf("[DB] EnqueueOfflineForward failed: exec error err=%v", err)
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			log.Printf("[DB] EnqueueOfflineForward failed: get last insert id err=%v", err)
+			return nil, err
+		}
+
+		var forward models.OfflineForward
+		err = d.db.QueryRow(
+			`SELECT id, conversation_id, message_id, avatar_id, thread_id, content, created_at
+			FROM offline_forwards WHERE id = ?`,
+			id,
+		).Scan(&forward.ID, &forward.ConversationID, &forward.MessageID, &forward.AvatarID,
+			&forward.ThreadID, &forward.Content, &forward.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[DB] EnqueueOfflineForward completed forward_id=%d", id)
+		return &forward, nil
+	})
+}
+
+// GetOfflineForwards retrieves all queued forwards in the order they were queued
+func (d *DB) GetOfflineForwards() ([]models.OfflineForward, error) {
+	return WithLockResult(d, func() ([]models.OfflineForward, error) {
+		rows, err := d.db.Query(
+			`SELECT id, conversation_id, message_id, avatar_id, thread_id, content, created_at
+			FROM offline_forwards ORDER BY id ASC`,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var forwards []models.OfflineForward
+		for rows.Next() {
+			var forward models.OfflineForward
+			if err := rows.Scan(&forward.ID, &forward.ConversationID, &forward.MessageID, &forward.AvatarID,
+				&forward.ThreadID, &forward.Content, &forward.CreatedAt); err != nil {
+				return nil, err
+			}
+			forwards = append(forwards, forward)
+		}
+		return forwards, rows.Err()
+	})
+}
+
+// HasOfflineForwards reports whether a conversation has messages waiting for delivery
+func (d *DB) HasOfflineForwards(conversationID int64) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		var exists bool
+		err := d.db.QueryRow(
+			`SELECT EXISTS(SELECT 1 FROM offline_forwards WHERE conversation_id = ?)`,
+			conversationID,
+		).Scan(&exists)
+		return exists, err
+	})
+}
+
+// GetFirstOfflineForwardSequence returns the sequence number of the oldest queued message of a conversation
+// Returns 0 if nothing is queued
+func (d *DB) GetFirstOfflineForwardSequence(conversationID int64) (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		var sequence int64
+		err := d.db.QueryRow(
+			`SELECT COALESCE(MIN(m.sequence), 0)
+			FROM offline_forwards f
+			INNER JOIN messages m ON m.id = f.message_id
+			WHERE f.conversation_id = ?`,
+			conversationID,
+		).Scan(&sequence)
+		return sequence, err
+	})
+}
+
+// DeleteOfflineForward removes a delivered or dropped forward
+// Returns sql.ErrNoRows if the forward does not exist
+func (d *DB) DeleteOfflineForward(id int64) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(`DELETE FROM offline_forwards WHERE id = ?`, id)
+		if err != nil {
+			log.Printf("[DB] DeleteOfflineForward failed: exec error forward_id=%d err=%v", id, err)
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		log.Printf("[DB] DeleteOfflineForward completed forward_id=%d", id)
+		return nil
+	})
+}
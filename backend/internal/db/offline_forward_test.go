@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestOfflineForwards_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	avatar, _ := db.CreateAvatar("Bot", "prompt", "asst_1")
+	first, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "first")
+	second, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "second")
+
+	if has, err := db.HasOfflineForwards(conv.ID); err != nil || has {
+		t.Fatalf("expected no queued forwards, got has=%v err=%v", has, err)
+	}
+
+	f1, err := db.EnqueueOfflineForward(conv.ID, first.ID, avatar.ID, "thread_1", "first")
+	if err != nil {
+		t.Fatalf("failed to enqueue forward: %v", err)
+	}
+	if _, err := db.EnqueueOfflineForward(conv.ID, second.ID, avatar.ID, "thread_1", "second"); err != nil {
+		t.Fatalf("failed to enqueue forward: %v", err)
+	}
+
+	if has, _ := db.HasOfflineForwards(conv.ID); !has {
+		t.Error("expected conversation to have queued forwards")
+	}
+	if seq, _ := db.GetFirstOfflineForwardSequence(conv.ID); seq != first.Sequence {
+		t.Errorf("expected first queued sequence %d, got %d", first.Sequence, seq)
+	}
+
+	forwards, err := db.GetOfflineForwards()
+	if err != nil {
+		t.Fatalf("failed to get forwards: %v", err)
+	}
+	if len(forwards) != 2 || forwards[0].Content != "first" || forwards[1].Content != "second" {
+		t.Fatalf("expected forwards in queue order, got %+v", forwards)
+	}
+
+	if err := db.DeleteOfflineForward(f1.ID); err != nil {
+		t.Fatalf("failed to delete forward: %v", err)
+	}
+	if err := db.DeleteOfflineForward(f1.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	forwards, _ = db.GetOfflineForwards()
+	if len(forwards) != 1 || forwards[0].MessageID != second.ID {
+		t.Errorf("expected only the second forward to remain, got %+v", forwards)
+	}
+}
+
+func TestOfflineForwards_DeletedWithConversation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	avatar, _ := db.CreateAvatar("Bot", "prompt", "asst_1")
+	msg, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	db.EnqueueOfflineForward(conv.ID, msg.ID, avatar.ID, "thread_1", "hello")
+
+	if err := db.DeleteConversation(conv.ID); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+
+	forwards, _ := db.GetOfflineForwards()
+	if len(forwards) != 0 {
+		t.Errorf("expected forwards to be removed with the conversation, got %d", len(forwards))
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// SetUserPresence records whether the user is viewing a conversation
+// Going away stores the conversation's last message sequence; staying away keeps the first one
+// Returns the previous presence, nil when none was reported before
+func (d *DB) SetUserPresence(conversationID int64, status string) (previous, current *models.UserPresence, err error) {
+	err = d.WithLock(func() error {
+		previous, err = d.getUserPresence(conversationID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		var awaySequence int64
+		switch {
+		case status != models.PresenceAway:
+		case previous.Away():
+			awaySequence = previous.AwaySequence
+		default:
+			if err := d.db.QueryRow(
+				`SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE conversation_id = ?`, conversationID,
+			).Scan(&awaySequence); err != nil {
+				return err
+			}
+		}
+
+		_, stamp := newUpdatedAt()
+		if _, err := d.db.Exec(
+			`INSERT INTO user_presence (conversation_id, status, away_sequence, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(conversation_id) DO UPDATE SET status = excluded.status,
+			away_sequence = excluded.away_sequence, updated_at = excluded.updated_at`,
+			conversationID, status, awaySequence, stamp,
+		); err != nil {
+			return err
+		}
+
+		current, err = d.getUserPresence(conversationID)
+		return err
+	})
+	return previous, current, err
+}
+
+// GetUserPresence returns the reported presence of the user in a conversation
+// Returns sql.ErrNoRows when no client has reported it
+func (d *DB) GetUserPresence(conversationID int64) (*models.UserPresence, error) {
+	return WithLockResult(d, func() (*models.UserPresence, error) {
+		return d.getUserPresence(conversationID)
+	})
+}
+
+func (d *DB) getUserPresence(conversationID int64) (*models.UserPresence, error) {
+	var p models.UserPresence
+	err := d.db.QueryRow(
+		`SELECT conversation_id, status, away_sequence, updated_at FROM user_presence WHERE conversation_id = ?`,
+		conversationID,
+	).Scan(&p.ConversationID, &p.Status, &p.AwaySequence, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestUserPresence(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Presence", "")
+	if _, err := db.GetUserPresence(conv.ID); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows before any report, got %v", err)
+	}
+
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "first")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "second")
+
+	previous, current, err := db.SetUserPresence(conv.ID, models.PresenceAway)
+	if err != nil {
+		t.Fatalf("failed to set presence: %v", err)
+	}
+	if previous != nil {
+		t.Errorf("expected no previous presence, got %+v", previous)
+	}
+	if !current.Away() || current.AwaySequence != 2 {
+		t.Errorf("expected away since sequence 2, got %+v", current)
+	}
+
+	// Reporting away again keeps the sequence from when the user left
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "third")
+	if _, current, _ := db.SetUserPresence(conv.ID, models.PresenceAway); current.AwaySequence != 2 {
+		t.Errorf("expected the away sequence to be kept, got %+v", current)
+	}
+
+	previous, current, err = db.SetUserPresence(conv.ID, models.PresenceViewing)
+	if err != nil {
+		t.Fatalf("failed to set presence: %v", err)
+	}
+	if !previous.Away() || previous.AwaySequence != 2 {
+		t.Errorf("expected the previous presence to be away, got %+v", previous)
+	}
+	if current.Away() || current.AwaySequence != 0 {
+		t.Errorf("expected viewing, got %+v", current)
+	}
+
+	got, err := db.GetUserPresence(conv.ID)
+	if err != nil || got.Status != models.PresenceViewing {
+		t.Errorf("expected viewing, got %+v (%v)", got, err)
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const promptExperimentColumns = `avatar_id, prompt_a, prompt_b, split_b, started_at, updated_at`
+
+// scanPromptExperiment scans a row selected with promptExperimentColumns
+func scanPromptExperiment(scanner interface{ Scan(...any) error }) (*models.PromptExperiment, error) {
+	var e models.PromptExperiment
+	if err := scanner.Scan(&e.AvatarID, &e.PromptA, &e.PromptB, &e.SplitB, &e.StartedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SetPromptExperiment starts or changes the prompt experiment of an avatar
+// Changing either prompt restarts the experiment, so stats never mix trials of different prompts;
+// changing only the split keeps the trials so far
+func (d *DB) SetPromptExperiment(avatarID int64, promptA, promptB string, splitB int) (*models.PromptExperiment, error) {
+	return WithLockResult(d, func() (*models.PromptExperiment, error) {
+		now := time.Now().UTC().Format(sqliteTimeFormat)
+		_, err := d.db.Exec(
+			`INSERT INTO prompt_experiments (avatar_id, prompt_a, prompt_b, split_b, started_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(avatar_id) DO UPDATE SET
+				started_at = CASE WHEN prompt_a = excluded.prompt_a AND prompt_b = excluded.prompt_b
+					THEN started_at ELSE excluded.started_at END,
+				prompt_a = excluded.prompt_a, prompt_b = excluded.prompt_b,
+				split_b = excluded.split_b, updated_at = excluded.updated_at`,
+			avatarID, promptA, promptB, splitB, now, now,
+		)
+		if err != nil {
+			log.Printf("[DB] SetPromptExperiment failed: exec error avatar_id=%d err=%v", avatarID, err)
+			return nil, err
+		}
+		return scanPromptExperiment(d.db.QueryRow(`SELECT `+promptExperimentColumns+` FROM prompt_experiments WHERE avatar_id = ?`, avatarID))
+	})
+}
+
+// GetPromptExperiment retrieves the prompt experiment of an avatar
+// Returns sql.ErrNoRows if the avatar has none
+func (d *DB) GetPromptExperiment(avatarID int64) (*models.PromptExperiment, error) {
+	return WithLockResult(d, func() (*models.PromptExperiment, error) {
+		return scanPromptExperiment(d.db.QueryRow(`SELECT `+promptExperimentColumns+` FROM prompt_experiments WHERE avatar_id = ?`, avatarID))
+	})
+}
+
+// DeletePromptExperiment ends the prompt experiment of an avatar; trials keep tagging their messages
+// Returns sql.ErrNoRows if the avatar has no experiment
+func (d *DB) DeletePromptExperiment(avatarID int64) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(`DELETE FROM prompt_experiments WHERE avatar_id = ?`, avatarID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// CreatePromptTrial records the variant an avatar judged a message with
+func (d *DB) CreatePromptTrial(trial *models.PromptTrial) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT INTO prompt_trials (conversation_id, avatar_id, trigger_message_id, variant, should_respond, message_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			trial.ConversationID, trial.AvatarID, trial.TriggerMessageID, trial.Variant, trial.ShouldRespond, trial.MessageID,
+			time.Now().UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			log.Printf("[DB] CreatePromptTrial failed: exec error avatar_id=%d message_id=%d err=%v",
+				trial.AvatarID, trial.TriggerMessageID, err)
+		}
+		return err
+	})
+}
+
+// GetConversationPromptVariants returns the prompt variant of each message in a conversation
+// that an avatar sent under a prompt experiment, keyed by message ID
+func (d *DB) GetConversationPromptVariants(conversationID int64) (map[int64]string, error) {
+	return WithLockResult(d, func() (map[int64]string, error) {
+		rows, err := d.db.Query(
+			`SELECT message_id, variant FROM prompt_trials WHERE conversation_id = ? AND message_id IS NOT NULL`,
+			conversationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		variants := make(map[int64]string)
+		for rows.Next() {
+			var messageID int64
+			var variant string
+			if err := rows.Scan(&messageID, &variant); err != nil {
+				return nil, err
+			}
+			variants[messageID] = variant
+		}
+		return variants, rows.Err()
+	})
+}
+
+// GetPromptExperimentStats compares the variants of an avatar's trials since the given time
+// Both variants are always returned, A first. A response counts as replied to when the next
+// message of its conversation is from the user
+func (d *DB) GetPromptExperimentStats(avatarID int64, since time.Time) ([]models.PromptVariantStats, error) {
+	return WithLockResult(d, func() ([]models.PromptVariantStats, error) {
+		rows, err := d.db.Query(
+			`SELECT t.variant, COUNT(*), SUM(t.should_respond), COUNT(m.id), COALESCE(AVG(length(m.content)), 0),
+				COALESCE(SUM((SELECT n.sender_type FROM messages n
+					WHERE n.conversation_id = m.conversation_id AND n.sequence > m.sequence
+					ORDER BY n.sequence LIMIT 1) = ?), 0)
+			FROM prompt_trials t
+			LEFT JOIN messages m ON m.id = t.message_id
+			WHERE t.avatar_id = ? AND t.created_at >= ?
+			GROUP BY t.variant`,
+			models.SenderTypeUser, avatarID, since.UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			log.Printf("[DB] GetPromptExperimentStats failed: query error avatar_id=%d err=%v", avatarID, err)
+			return nil, err
+		}
+		defer rows.Close()
+
+		stats := []models.PromptVariantStats{{Variant: models.PromptVariantA}, {Variant: models.PromptVariantB}}
+		for rows.Next() {
+			var s models.PromptVariantStats
+			if err := rows.Scan(&s.Variant, &s.Trials, &s.Responded, &s.Responses, &s.AverageResponseLength, &s.UserReplies); err != nil {
+				return nil, err
+			}
+			if s.Trials > 0 {
+				s.ResponseRate = float64(s.Responded) / float64(s.Trials)
+			}
+			if s.Responses > 0 {
+				s.UserReplyRate = float64(s.UserReplies) / float64(s.Responses)
+			}
+			for i := range stats {
+				if stats[i].Variant == s.Variant {
+					stats[i] = s
+				}
+			}
+		}
+		return stats, rows.Err()
+	})
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestPromptExperiments(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+
+	if _, err := db.GetPromptExperiment(avatar.ID); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows without an experiment, got %v", err)
+	}
+
+	created, err := db.SetPromptExperiment(avatar.ID, "formal", "casual", 50)
+	if err != nil || created.PromptA != "formal" || created.PromptB != "casual" || created.SplitB != 50 {
+		t.Fatalf("unexpected experiment: %+v err=%v", created, err)
+	}
+
+	// Backdate the start to tell a restart apart from a kept start
+	db.db.Exec(`UPDATE prompt_experiments SET started_at = '2020-01-01 00:00:00'`)
+	split, _ := db.SetPromptExperiment(avatar.ID, "formal", "casual", 20)
+	if split.SplitB != 20 || split.StartedAt.Year() != 2020 {
+		t.Errorf("expected a split change to keep the start, got %+v", split)
+	}
+	changed, _ := db.SetPromptExperiment(avatar.ID, "formal", "playful", 20)
+	if changed.PromptB != "playful" || changed.StartedAt.Year() == 2020 {
+		t.Errorf("expected a prompt change to restart the experiment, got %+v", changed)
+	}
+
+	if err := db.DeletePromptExperiment(avatar.ID); err != nil {
+		t.Fatalf("failed to delete experiment: %v", err)
+	}
+	if err := db.DeletePromptExperiment(avatar.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting a missing experiment, got %v", err)
+	}
+}
+
+func TestPromptTrials(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Trials", "")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+
+	// A: two triggers, one answered with "hello" and replied to by the user
+	// B: two triggers, both judged to respond, one answered with "hi there" and replied to; the other response failed
+	trigger1, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "one")
+	reply1, _ := db.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatar.ID, "hello")
+	trigger2, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "two")
+	trigger3, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "three")
+	reply3, _ := db.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatar.ID, "hi there")
+	trigger4, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "four")
+
+	trials := []models.PromptTrial{
+		{TriggerMessageID: trigger1.ID, Variant: models.PromptVariantA, ShouldRespond: true, MessageID: &reply1.ID},
+		{TriggerMessageID: trigger2.ID, Variant: models.PromptVariantA},
+		{TriggerMessageID: trigger3.ID, Variant: models.PromptVariantB, ShouldRespond: true, MessageID: &reply3.ID},
+		{TriggerMessageID: trigger4.ID, Variant: models.PromptVariantB, ShouldRespond: true},
+	}
+	for i := range trials {
+		trials[i].ConversationID = conv.ID
+		trials[i].AvatarID = avatar.ID
+		if err := db.CreatePromptTrial(&trials[i]); err != nil {
+			t.Fatalf("failed to create trial: %v", err)
+		}
+	}
+
+	variants, err := db.GetConversationPromptVariants(conv.ID)
+	if err != nil || len(variants) != 2 || variants[reply1.ID] != models.PromptVariantA || variants[reply3.ID] != models.PromptVariantB {
+		t.Errorf("expected the responses to be tagged, got %v err=%v", variants, err)
+	}
+
+	stats, err := db.GetPromptExperimentStats(avatar.ID, time.Now().Add(-time.Hour))
+	if err != nil || len(stats) != 2 {
+		t.Fatalf("expected stats of both variants, got %+v err=%v", stats, err)
+	}
+	a, b := stats[0], stats[1]
+	if a.Variant != models.PromptVariantA || a.Trials != 2 || a.Responded != 1 || a.ResponseRate != 0.5 ||
+		a.Responses != 1 || a.AverageResponseLength != 5 || a.UserReplies != 1 || a.UserReplyRate != 1 {
+		t.Errorf("unexpected stats of variant A: %+v", a)
+	}
+	if b.Variant != models.PromptVariantB || b.Trials != 2 || b.Responded != 2 || b.ResponseRate != 1 ||
+		b.Responses != 1 || b.AverageResponseLength != 8 || b.UserReplies != 1 || b.UserReplyRate != 1 {
+		t.Errorf("unexpected stats of variant B: %+v", b)
+	}
+
+	// Trials from before the experiment started are not counted
+	stats, _ = db.GetPromptExperimentStats(avatar.ID, time.Now().Add(time.Hour))
+	if stats[0].Trials != 0 || stats[1].Trials != 0 || stats[0].ResponseRate != 0 {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+}
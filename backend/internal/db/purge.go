@@ -0,0 +1,156 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// contentMatch is the case-insensitive substring condition used by content purges
+const contentMatch = `instr(lower(content), lower(?)) > 0`
+
+// FindConversationsWithContent returns the IDs of conversations with messages containing text
+// Messages still waiting in the offline queue are included
+func (d *DB) FindConversationsWithContent(text string) ([]int64, error) {
+	return WithLockResult(d, func() ([]int64, error) {
+		rows, err := d.db.Query(
+			`SELECT conversation_id FROM messages WHERE `+contentMatch+`
+			UNION
+			SELECT conversation_id FROM offline_forwards WHERE `+contentMatch+`
+			ORDER BY conversation_id`,
+			text, text,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		ids := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, rows.Err()
+	})
+}
+
+// DeleteContent deletes every message and queued offline forward containing text
+// Returns the number of messages deleted
+func (d *DB) DeleteContent(text string) (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		log.Printf("[DB] DeleteContent started")
+
+		tx, err := d.db.Begin()
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+
+		// Forwards reference messages, so remove matching ones explicitly before the messages
+		if _, err := tx.Exec(`DELETE FROM offline_forwards WHERE `+contentMatch, text); err != nil {
+			log.Printf("[DB] DeleteContent failed: delete offline forwards err=%v", err)
+			return 0, err
+		}
+
+		// Artifacts of deleted messages cascade; artifacts that contain the text themselves go too
+		if _, err := tx.Exec(`DELETE FROM message_artifacts WHERE `+contentMatch, text); err != nil {
+			log.Printf("[DB] DeleteContent failed: delete message artifacts err=%v", err)
+			return 0, err
+		}
+
+		// Dead letters of deleted messages cascade; snapshots that quote the text go too
+		if _, err := tx.Exec(`DELETE FROM dead_letters WHERE instr(lower(context), lower(?)) > 0`, text); err != nil {
+			log.Printf("[DB] DeleteContent failed: delete dead letters err=%v", err)
+			return 0, err
+		}
+
+		// Comparisons of deleted trigger messages cascade; responses that contain the text go too
+		if _, err := tx.Exec(`DELETE FROM response_comparisons WHERE instr(lower(primary_content), lower(?)) > 0
+			OR instr(lower(comparison_content), lower(?)) > 0`, text, text); err != nil {
+			log.Printf("[DB] DeleteContent failed: delete response comparisons err=%v", err)
+			return 0, err
+		}
+
+		result, err := tx.Exec(`DELETE FROM messages WHERE `+contentMatch, text)
+		if err != nil {
+			log.Printf("[DB] DeleteContent failed: delete messages err=%v", err)
+			return 0, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+
+		log.Printf("[DB] DeleteContent completed messages_deleted=%d", deleted)
+		return deleted, nil
+	})
+}
+
+// CreatePurgeRecord appends an entry to the purge audit log
+func (d *DB) CreatePurgeRecord(record *models.PurgeRecord) error {
+	conversationIDs, err := json.Marshal(record.ConversationIDs)
+	if err != nil {
+		return err
+	}
+	if record.ConversationIDs == nil {
+		conversationIDs = []byte("[]")
+	}
+
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(
+			`INSERT INTO purge_log (scope, target, conversation_ids, messages_deleted, remote_deleted, status, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			record.Scope, record.Target, string(conversationIDs), record.MessagesDeleted, record.RemoteDeleted,
+			record.Status, record.Error,
+		)
+		if err != nil {
+			log.Printf("[DB] CreatePurgeRecord failed: exec error err=%v", err)
+			return err
+		}
+
+		record.ID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return d.db.QueryRow(`SELECT created_at FROM purge_log WHERE id = ?`, record.ID).Scan(&record.CreatedAt)
+	})
+}
+
+// GetPurgeRecords retrieves the most recent purge audit entries, newest first
+func (d *DB) GetPurgeRecords(limit int) ([]models.PurgeRecord, error) {
+	return WithLockResult(d, func() ([]models.PurgeRecord, error) {
+		rows, err := d.db.Query(
+			`SELECT id, scope, target, conversation_ids, messages_deleted, remote_deleted, status, error, created_at
+			FROM purge_log ORDER BY id DESC LIMIT ?`,
+			limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		records := []models.PurgeRecord{}
+		for rows.Next() {
+			var record models.PurgeRecord
+			var conversationIDs string
+			if err := rows.Scan(&record.ID, &record.Scope, &record.Target, &conversationIDs,
+				&record.MessagesDeleted, &record.RemoteDeleted, &record.Status, &record.Error, &record.CreatedAt); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(conversationIDs), &record.ConversationIDs); err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		return records, rows.Err()
+	})
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestDeleteContent(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	other, _ := db.CreateConversation("Other", "")
+	clean, _ := db.CreateConversation("Clean", "")
+
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "My phone is 555-1234")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Nothing to see here")
+	db.CreateMessage(other.ID, models.SenderTypeUser, nil, "call 555-1234 tomorrow")
+	db.CreateMessage(clean.ID, models.SenderTypeUser, nil, "hello")
+
+	ids, err := db.FindConversationsWithContent("555-1234")
+	if err != nil {
+		t.Fatalf("failed to find conversations: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != conv.ID || ids[1] != other.ID {
+		t.Fatalf("expected conversations [%d %d], got %v", conv.ID, other.ID, ids)
+	}
+
+	deleted, err := db.DeleteContent("555-1234")
+	if err != nil {
+		t.Fatalf("failed to delete content: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 messages deleted, got %d", deleted)
+	}
+
+	remaining, _ := db.GetMessages(conv.ID)
+	if len(remaining) != 1 || remaining[0].Content != "Nothing to see here" {
+		t.Errorf("unexpected remaining messages: %+v", remaining)
+	}
+	if ids, _ := db.FindConversationsWithContent("555-1234"); len(ids) != 0 {
+		t.Errorf("expected no matches after purge, got %v", ids)
+	}
+}
+
+func TestFindConversationsWithContent_CaseInsensitive(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Test", "")
+	db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Secret Project Falcon")
+
+	ids, err := db.FindConversationsWithContent("project falcon")
+	if err != nil {
+		t.Fatalf("failed to find conversations: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != conv.ID {
+		t.Errorf("expected conversation %d, got %v", conv.ID, ids)
+	}
+}
+
+func TestPurgeRecords(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	first := &models.PurgeRecord{
+		Scope:           models.PurgeScopeConversation,
+		Target:          "1",
+		ConversationIDs: []int64{1},
+		MessagesDeleted: 3,
+		RemoteDeleted:   2,
+		Status:          models.PurgeStatusCompleted,
+	}
+	if err := db.CreatePurgeRecord(first); err != nil {
+		t.Fatalf("failed to create purge record: %v", err)
+	}
+	if first.ID == 0 || first.CreatedAt.IsZero() {
+		t.Errorf("expected ID and CreatedAt to be set, got %+v", first)
+	}
+
+	second := &models.PurgeRecord{
+		Scope:  models.PurgeScopeContent,
+		Target: "sha256:abc",
+		Status: models.PurgeStatusFailed,
+		Error:  "thread thread_1: server error",
+	}
+	db.CreatePurgeRecord(second)
+
+	records, err := db.GetPurgeRecords(10)
+	if err != nil {
+		t.Fatalf("failed to get purge records: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != second.ID || records[1].ID != first.ID {
+		t.Fatalf("expected newest first, got %+v", records)
+	}
+	if len(records[1].ConversationIDs) != 1 || records[1].ConversationIDs[0] != 1 || records[1].MessagesDeleted != 3 {
+		t.Errorf("unexpected record: %+v", records[1])
+	}
+	if records[0].ConversationIDs == nil || records[0].Error == "" {
+		t.Errorf("unexpected record: %+v", records[0])
+	}
+}
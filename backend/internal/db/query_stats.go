@@ -0,0 +1,269 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is how long a statement or lock wait may take before it is logged as slow
+const DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+// maxQueryStats bounds the number of distinct statements tracked; later ones are counted under otherQueries
+const maxQueryStats = 500
+
+// otherQueries is the key statements beyond maxQueryStats are aggregated under
+const otherQueries = "(other)"
+
+// placeholderListPattern matches lists of placeholders, whose length varies with the arguments
+var placeholderListPattern = regexp.MustCompile(`\?(\s*,\s*\?)+`)
+
+// QueryStat is the aggregated timing of one SQL statement
+// Query times cover running the statement, not reading the rows afterwards
+type QueryStat struct {
+	Query   string  `json:"query"`
+	Count   int64   `json:"count"`
+	Errors  int64   `json:"errors"`
+	Slow    int64   `json:"slow"`
+	TotalMs float64 `json:"total_ms"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// LockStats is how long callers waited for the global database lock
+type LockStats struct {
+	Acquisitions int64   `json:"acquisitions"`
+	Slow         int64   `json:"slow"`
+	TotalWaitMs  float64 `json:"total_wait_ms"`
+	MeanWaitMs   float64 `json:"mean_wait_ms"`
+	MaxWaitMs    float64 `json:"max_wait_ms"`
+}
+
+// QueryStats reports statement timings since the server started or the stats were reset
+// Queries are sorted by total time, so the statements costing the most come first
+type QueryStats struct {
+	SlowThresholdMs float64     `json:"slow_threshold_ms"`
+	Since           time.Time   `json:"since"`
+	Lock            LockStats   `json:"lock"`
+	Queries         []QueryStat `json:"queries"`
+}
+
+// queryTiming accumulates the timings of one statement
+type queryTiming struct {
+	count, errors, slow int64
+	total, max          time.Duration
+}
+
+// queryRecorder aggregates statement timings and logs slow statements and lock waits
+type queryRecorder struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	since     time.Time
+	queries   map[string]*queryTiming
+	lock      queryTiming
+}
+
+func newQueryRecorder() *queryRecorder {
+	return &queryRecorder{
+		threshold: DefaultSlowQueryThreshold,
+		since:     time.Now(),
+		queries:   make(map[string]*queryTiming),
+	}
+}
+
+// normalizeQuery collapses whitespace and placeholder lists so the same statement shares one entry
+func normalizeQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	return placeholderListPattern.ReplaceAllString(query, "?, ...")
+}
+
+// recordQuery adds a statement's timing, logging it when it is slow
+func (r *queryRecorder) recordQuery(query string, elapsed time.Duration, err error) {
+	key := normalizeQuery(query)
+
+	r.mu.Lock()
+	t, ok := r.queries[key]
+	if !ok {
+		if len(r.queries) >= maxQueryStats {
+			key = otherQueries
+			t = r.queries[key]
+		}
+		if t == nil {
+			t = &queryTiming{}
+			r.queries[key] = t
+		}
+	}
+	slow := r.threshold > 0 && elapsed >= r.threshold
+	t.add(elapsed, slow)
+	if err != nil && err != sql.ErrNoRows {
+		t.errors++
+	}
+	r.mu.Unlock()
+
+	if slow {
+		log.Printf("[DB] Slow query duration=%v query=%q err=%v", elapsed, key, err)
+	}
+}
+
+// recordLockWait adds how long a call waited for the database lock and reports whether it was slow
+func (r *queryRecorder) recordLockWait(waited time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	slow := r.threshold > 0 && waited >= r.threshold
+	r.lock.add(waited, slow)
+	return slow
+}
+
+func (t *queryTiming) add(elapsed time.Duration, slow bool) {
+	t.count++
+	t.total += elapsed
+	if elapsed > t.max {
+		t.max = elapsed
+	}
+	if slow {
+		t.slow++
+	}
+}
+
+func (r *queryRecorder) setThreshold(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.threshold = d
+}
+
+func (r *queryRecorder) snapshot() QueryStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := QueryStats{
+		SlowThresholdMs: milliseconds(r.threshold),
+		Since:           r.since,
+		Lock: LockStats{
+			Acquisitions: r.lock.count,
+			Slow:         r.lock.slow,
+			TotalWaitMs:  milliseconds(r.lock.total),
+			MeanWaitMs:   milliseconds(r.lock.mean()),
+			MaxWaitMs:    milliseconds(r.lock.max),
+		},
+		Queries: make([]QueryStat, 0, len(r.queries)),
+	}
+	for query, t := range r.queries {
+		stats.Queries = append(stats.Queries, QueryStat{
+			Query:   query,
+			Count:   t.count,
+			Errors:  t.errors,
+			Slow:    t.slow,
+			TotalMs: milliseconds(t.total),
+			MeanMs:  milliseconds(t.mean()),
+			MaxMs:   milliseconds(t.max),
+		})
+	}
+	sort.Slice(stats.Queries, func(i, j int) bool {
+		if stats.Queries[i].TotalMs != stats.Queries[j].TotalMs {
+			return stats.Queries[i].TotalMs > stats.Queries[j].TotalMs
+		}
+		return stats.Queries[i].Query < stats.Queries[j].Query
+	})
+	return stats
+}
+
+func (r *queryRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.since = time.Now()
+	r.queries = make(map[string]*queryTiming)
+	r.lock = queryTiming{}
+}
+
+func (t *queryTiming) mean() time.Duration {
+	if t.count == 0 {
+		return 0
+	}
+	return t.total / time.Duration(t.count)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// timedDB is the SQLite connection with every statement timed
+type timedDB struct {
+	*sql.DB
+	recorder *queryRecorder
+}
+
+func (t *timedDB) Exec(query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.DB.Exec(query, args...)
+	t.recorder.recordQuery(query, time.Since(start), err)
+	return result, err
+}
+
+func (t *timedDB) Query(query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.DB.Query(query, args...)
+	t.recorder.recordQuery(query, time.Since(start), err)
+	return rows, err
+}
+
+func (t *timedDB) QueryRow(query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := t.DB.QueryRow(query, args...)
+	t.recorder.recordQuery(query, time.Since(start), row.Err())
+	return row
+}
+
+func (t *timedDB) Begin() (*timedTx, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &timedTx{Tx: tx, recorder: t.recorder}, nil
+}
+
+// timedTx is a transaction with every statement timed
+type timedTx struct {
+	*sql.Tx
+	recorder *queryRecorder
+}
+
+func (t *timedTx) Exec(query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.Tx.Exec(query, args...)
+	t.recorder.recordQuery(query, time.Since(start), err)
+	return result, err
+}
+
+func (t *timedTx) Query(query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.Tx.Query(query, args...)
+	t.recorder.recordQuery(query, time.Since(start), err)
+	return rows, err
+}
+
+func (t *timedTx) QueryRow(query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := t.Tx.QueryRow(query, args...)
+	t.recorder.recordQuery(query, time.Since(start), row.Err())
+	return row
+}
+
+// QueryStats returns the statement timings and lock waits since the server started or the last reset
+func (d *DB) QueryStats() QueryStats {
+	return d.db.recorder.snapshot()
+}
+
+// ResetQueryStats clears the statement timings and lock waits
+func (d *DB) ResetQueryStats() {
+	d.db.recorder.reset()
+}
+
+// SetSlowQueryThreshold sets how long a statement or lock wait may take before it is logged as slow
+// A threshold of 0 disables the slow query log; timings are still aggregated
+func (d *DB) SetSlowQueryThreshold(threshold time.Duration) {
+	d.db.recorder.setThreshold(threshold)
+}
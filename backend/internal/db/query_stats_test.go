@@ -0,0 +1,89 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	got := normalizeQuery("SELECT id FROM avatars\n\t\tWHERE id IN (?, ?,?) AND name = ?")
+	want := "SELECT id FROM avatars WHERE id IN (?, ...) AND name = ?"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.ResetQueryStats()
+	db.CreateAvatar("Nova", "prompt", "")
+	db.CreateAvatar("Orion", "prompt", "")
+	db.GetAllAvatars()
+
+	stats := db.QueryStats()
+	if stats.SlowThresholdMs != float64(DefaultSlowQueryThreshold/time.Millisecond) {
+		t.Errorf("expected the default slow threshold, got %v", stats.SlowThresholdMs)
+	}
+	if stats.Lock.Acquisitions < 3 {
+		t.Errorf("expected at least 3 lock acquisitions, got %d", stats.Lock.Acquisitions)
+	}
+
+	var insert *QueryStat
+	for i := range stats.Queries {
+		if strings.HasPrefix(stats.Queries[i].Query, "INSERT INTO avatars") {
+			insert = &stats.Queries[i]
+		}
+	}
+	if insert == nil {
+		t.Fatalf("expected the avatar insert to be tracked, got %+v", stats.Queries)
+	}
+	if insert.Count != 2 || insert.Errors != 0 {
+		t.Errorf("expected 2 inserts without errors, got count=%d errors=%d", insert.Count, insert.Errors)
+	}
+	for i := 1; i < len(stats.Queries); i++ {
+		if stats.Queries[i].TotalMs > stats.Queries[i-1].TotalMs {
+			t.Fatalf("expected queries sorted by total time, got %+v", stats.Queries)
+		}
+	}
+
+	// Failed statements are counted as errors
+	db.Exec("INSERT INTO no_such_table (id) VALUES (1)")
+	for _, q := range db.QueryStats().Queries {
+		if strings.Contains(q.Query, "no_such_table") && q.Errors != 1 {
+			t.Errorf("expected 1 error for the failed insert, got %d", q.Errors)
+		}
+	}
+
+	db.ResetQueryStats()
+	if stats := db.QueryStats(); len(stats.Queries) != 0 || stats.Lock.Acquisitions != 0 {
+		t.Errorf("expected empty stats after reset, got %+v", stats)
+	}
+}
+
+func TestQueryStats_SlowThreshold(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Every statement is slow with a 1ns threshold
+	db.SetSlowQueryThreshold(time.Nanosecond)
+	db.ResetQueryStats()
+	db.GetAllAvatars()
+
+	stats := db.QueryStats()
+	if len(stats.Queries) == 0 || stats.Queries[0].Slow != stats.Queries[0].Count {
+		t.Errorf("expected every statement to be slow, got %+v", stats.Queries)
+	}
+
+	// 0 turns the slow query log off
+	db.SetSlowQueryThreshold(0)
+	db.ResetQueryStats()
+	db.GetAllAvatars()
+	for _, q := range db.QueryStats().Queries {
+		if q.Slow != 0 {
+			t.Errorf("expected no slow statements with the log off, got %+v", q)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"log"
+	"sort"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CreateRedactions records the number of values of each kind redacted from a user message
+func (d *DB) CreateRedactions(conversationID, messageID int64, policy string, counts map[string]int) error {
+	kinds := make([]string, 0, len(counts))
+	for kind, count := range counts {
+		if count > 0 {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) == 0 {
+		return nil
+	}
+	sort.Strings(kinds)
+
+	return d.WithLock(func() error {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, kind := range kinds {
+			if _, err := tx.Exec(
+				`INSERT INTO redactions (conversation_id, message_id, policy, kind, count) VALUES (?, ?, ?, ?, ?)`,
+				conversationID, messageID, policy, kind, counts[kind],
+			); err != nil {
+				log.Printf("[DB] CreateRedactions failed: exec error message_id=%d err=%v", messageID, err)
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Printf("[DB] CreateRedactions completed conversation_id=%d message_id=%d kinds=%v", conversationID, messageID, kinds)
+		return nil
+	})
+}
+
+// GetRedactions retrieves redaction records, newest first
+// A zero conversationID matches every conversation
+func (d *DB) GetRedactions(conversationID int64, limit int) ([]models.Redaction, error) {
+	return WithLockResult(d, func() ([]models.Redaction, error) {
+		rows, err := d.db.Query(
+			`SELECT id, conversation_id, message_id, policy, kind, count, created_at FROM redactions
+			WHERE (? = 0 OR conversation_id = ?)
+			ORDER BY id DESC LIMIT ?`,
+			conversationID, conversationID, limit,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		redactions := []models.Redaction{}
+		for rows.Next() {
+			var r models.Redaction
+			if err := rows.Scan(&r.ID, &r.ConversationID, &r.MessageID, &r.Policy, &r.Kind, &r.Count, &r.CreatedAt); err != nil {
+				return nil, err
+			}
+			redactions = append(redactions, r)
+		}
+		return redactions, rows.Err()
+	})
+}
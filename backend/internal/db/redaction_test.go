@@ -0,0 +1,45 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestRedactions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv1, _ := db.CreateConversationWithSettings("One", "", "", "regex", "", 0)
+	conv2, _ := db.CreateConversation("Two", "")
+	msg1, _ := db.CreateMessage(conv1.ID, models.SenderTypeUser, nil, "mail [REDACTED EMAIL]")
+	msg2, _ := db.CreateMessage(conv2.ID, models.SenderTypeUser, nil, "call [REDACTED PHONE]")
+
+	if err := db.CreateRedactions(conv1.ID, msg1.ID, "regex", map[string]int{"email": 1, "phone": 0, "term": 2}); err != nil {
+		t.Fatalf("failed to create redactions: %v", err)
+	}
+	if err := db.CreateRedactions(conv2.ID, msg2.ID, "llm", map[string]int{"phone": 1}); err != nil {
+		t.Fatalf("failed to create redactions: %v", err)
+	}
+	if err := db.CreateRedactions(conv2.ID, msg2.ID, "llm", nil); err != nil {
+		t.Fatalf("expected no error without redactions, got %v", err)
+	}
+
+	all, err := db.GetRedactions(0, 10)
+	if err != nil || len(all) != 3 || all[0].Kind != "phone" || all[0].Policy != "llm" {
+		t.Fatalf("expected 3 redactions newest first, got %+v err=%v", all, err)
+	}
+
+	byConversation, _ := db.GetRedactions(conv1.ID, 10)
+	if len(byConversation) != 2 || byConversation[0].Kind != "term" || byConversation[0].Count != 2 || byConversation[1].Kind != "email" {
+		t.Errorf("unexpected redactions of conversation 1: %+v", byConversation)
+	}
+
+	got, _ := db.GetConversation(conv1.ID)
+	if got.RedactionPolicy != "regex" {
+		t.Errorf("expected redaction policy regex, got %q", got.RedactionPolicy)
+	}
+	if got, _ := db.GetConversation(conv2.ID); got.RedactionPolicy != "off" {
+		t.Errorf("expected default redaction policy off, got %q", got.RedactionPolicy)
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import "log"
+
+// Statuses of a responded_to entry
+const (
+	respondedToStatusResponding = "responding"
+	respondedToStatusResponded  = "responded"
+)
+
+// ClaimResponse records that the avatar is responding to a message
+// Returns false if the avatar has already responded, or is responding, to the message
+func (d *DB) ClaimResponse(conversationID, avatarID, messageID int64) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		result, err := d.db.Exec(
+			`INSERT INTO responded_to (avatar_id, trigger_message_id, conversation_id, status) VALUES (?, ?, ?, ?)
+			ON CONFLICT (avatar_id, trigger_message_id) DO NOTHING`,
+			avatarID, messageID, conversationID, respondedToStatusResponding,
+		)
+		if err != nil {
+			log.Printf("[DB] ClaimResponse failed: exec error avatar_id=%d message_id=%d err=%v", avatarID, messageID, err)
+			return false, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		return rows == 1, nil
+	})
+}
+
+// CompleteResponse marks a claimed response as done
+func (d *DB) CompleteResponse(avatarID, messageID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`UPDATE responded_to SET status = ? WHERE avatar_id = ? AND trigger_message_id = ?`,
+			respondedToStatusResponded, avatarID, messageID,
+		)
+		return err
+	})
+}
+
+// ReleaseResponse removes the claim of a response that failed so it can be attempted again
+func (d *DB) ReleaseResponse(avatarID, messageID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`DELETE FROM responded_to WHERE avatar_id = ? AND trigger_message_id = ? AND status = ?`,
+			avatarID, messageID, respondedToStatusResponding,
+		)
+		return err
+	})
+}
+
+// ReleasePendingResponses removes the claims of responses that were in progress when the previous process stopped
+// Returns the number of released claims
+func (d *DB) ReleasePendingResponses() (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		result, err := d.db.Exec(`DELETE FROM responded_to WHERE status = ?`, respondedToStatusResponding)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	})
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestClaimResponse(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Claims", "")
+	msg, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	if claimed, err := db.ClaimResponse(conv.ID, 1, msg.ID); err != nil || !claimed {
+		t.Fatalf("expected the first claim to succeed, got %v err=%v", claimed, err)
+	}
+	if claimed, _ := db.ClaimResponse(conv.ID, 1, msg.ID); claimed {
+		t.Error("expected a second claim by the same avatar to fail")
+	}
+	if claimed, _ := db.ClaimResponse(conv.ID, 2, msg.ID); !claimed {
+		t.Error("expected another avatar to claim the message")
+	}
+
+	// A released claim can be taken again
+	if err := db.ReleaseResponse(1, msg.ID); err != nil {
+		t.Fatalf("failed to release response: %v", err)
+	}
+	if claimed, _ := db.ClaimResponse(conv.ID, 1, msg.ID); !claimed {
+		t.Error("expected a released claim to be taken again")
+	}
+
+	// Completed responses are neither released nor cleared on startup
+	if err := db.CompleteResponse(1, msg.ID); err != nil {
+		t.Fatalf("failed to complete response: %v", err)
+	}
+	db.ReleaseResponse(1, msg.ID)
+	if n, err := db.ReleasePendingResponses(); err != nil || n != 1 {
+		t.Errorf("expected 1 pending response released, got %d err=%v", n, err)
+	}
+	if claimed, _ := db.ClaimResponse(conv.ID, 1, msg.ID); claimed {
+		t.Error("expected a completed response to stay claimed")
+	}
+	if claimed, _ := db.ClaimResponse(conv.ID, 2, msg.ID); !claimed {
+		t.Error("expected the pending response to be released")
+	}
+}
+
+func TestCreateResponseMessage_CompletesClaim(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Claims", "")
+	avatar, _ := db.CreateAvatar("Alice", "Helpful assistant", "")
+	msg, _ := db.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	db.ClaimResponse(conv.ID, avatar.ID, msg.ID)
+	reply, err := db.CreateResponseMessage(conv.ID, avatar.ID, msg.ID, "hi")
+	if err != nil {
+		t.Fatalf("CreateResponseMessage failed: %v", err)
+	}
+	if reply.SenderType != models.SenderTypeAvatar || reply.SenderID == nil || *reply.SenderID != avatar.ID {
+		t.Errorf("expected a message from the avatar, got %+v", reply)
+	}
+
+	// A restart before CompleteResponse keeps the claim, so the message is not answered again
+	if n, err := db.ReleasePendingResponses(); err != nil || n != 0 {
+		t.Errorf("expected no pending response after saving the reply, got %d err=%v", n, err)
+	}
+	if claimed, _ := db.ClaimResponse(conv.ID, avatar.ID, msg.ID); claimed {
+		t.Error("expected the answered message to stay claimed")
+	}
+}
+
+func TestWatcherState(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("State", "")
+
+	if _, err := db.GetWatcherState(conv.ID, 1); err == nil {
+		t.Error("expected no state before it is saved")
+	}
+
+	db.SaveWatcherState(conv.ID, 1, 3)
+	if err := db.SaveWatcherState(conv.ID, 1, 5); err != nil {
+		t.Fatalf("failed to save watcher state: %v", err)
+	}
+	if seq, err := db.GetWatcherState(conv.ID, 1); err != nil || seq != 5 {
+		t.Errorf("expected last sequence 5, got %d err=%v", seq, err)
+	}
+
+	if err := db.DeleteWatcherState(conv.ID, 1); err != nil {
+		t.Fatalf("failed to delete watcher state: %v", err)
+	}
+	if _, err := db.GetWatcherState(conv.ID, 1); err == nil {
+		t.Error("expected the state to be deleted")
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+const runColumns = `id, conversation_id, avatar_id, thread_id, status, error, error_code, started_at, completed_at`
+
+// scanRun scans a row selected with runColumns
+func scanRun(scanner interface{ Scan(...any) error }) (*models.Run, error) {
+	var run models.Run
+	var completedAt sql.NullTime
+	if err := scanner.Scan(&run.ID, &run.ConversationID, &run.AvatarID, &run.ThreadID, &run.Status, &run.Error,
+		&run.ErrorCode, &run.StartedAt, &completedAt); err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+	return &run, nil
+}
+
+// CreateRun records a run an avatar watcher has started
+func (d *DB) CreateRun(runID string, conversationID, avatarID int64, threadID string) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT INTO runs (id, conversation_id, avatar_id, thread_id, status, started_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			runID, conversationID, avatarID, threadID, models.RunStatusRunning, time.Now().UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			log.Printf("[DB] CreateRun failed: exec error run_id=%s err=%v", runID, err)
+		}
+		return err
+	})
+}
+
+// GetRun retrieves a run by its OpenAI run ID
+func (d *DB) GetRun(runID string) (*models.Run, error) {
+	return WithLockResult(d, func() (*models.Run, error) {
+		return scanRun(d.db.QueryRow(`SELECT `+runColumns+` FROM runs WHERE id = ?`, runID))
+	})
+}
+
+// GetRunsByStatus retrieves the runs with a status, oldest first
+func (d *DB) GetRunsByStatus(status string) ([]models.Run, error) {
+	return WithLockResult(d, func() ([]models.Run, error) {
+		rows, err := d.db.Query(`SELECT `+runColumns+` FROM runs WHERE status = ? ORDER BY started_at ASC`, status)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var runs []models.Run
+		for rows.Next() {
+			run, err := scanRun(rows)
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, *run)
+		}
+		return runs, rows.Err()
+	})
+}
+
+// FinishRun records the outcome of a running run
+// The run completes when errMsg is empty and fails otherwise; errCode classifies the failure.
+// Returns false if the run is not running, e.g. because the reaper already failed it
+func (d *DB) FinishRun(runID, errCode, errMsg string) (bool, error) {
+	status := models.RunStatusCompleted
+	if errMsg != "" {
+		status = models.RunStatusFailed
+	}
+
+	return WithLockResult(d, func() (bool, error) {
+		result, err := d.db.Exec(
+			`UPDATE runs SET status = ?, error = ?, error_code = ?, completed_at = ? WHERE id = ? AND status = ?`,
+			status, errMsg, errCode, time.Now().UTC().Format(sqliteTimeFormat), runID, models.RunStatusRunning,
+		)
+		if err != nil {
+			log.Printf("[DB] FinishRun failed: exec error run_id=%s err=%v", runID, err)
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected > 0, err
+	})
+}
+
+// FailRun marks a run as failed, recording it first if it was not started by a watcher of this process
+// Returns false if the run had already finished
+func (d *DB) FailRun(runID string, conversationID, avatarID int64, threadID, errMsg string) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		now := time.Now().UTC().Format(sqliteTimeFormat)
+		result, err := d.db.Exec(`
+			INSERT INTO runs (id, conversation_id, avatar_id, thread_id, status, error, started_at, completed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET status = excluded.status, error = excluded.error, completed_at = excluded.completed_at
+			WHERE runs.status = ?`,
+			runID, conversationID, avatarID, threadID, models.RunStatusFailed, errMsg, now, now, models.RunStatusRunning,
+		)
+		if err != nil {
+			log.Printf("[DB] FailRun failed: exec error run_id=%s err=%v", runID, err)
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected > 0, err
+	})
+}
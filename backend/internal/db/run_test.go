@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestRuns_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Runs", "")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+
+	if err := db.CreateRun("run_1", conv.ID, avatar.ID, "thread_1"); err != nil {
+		t.Fatalf("failed to create run: %v", err)
+	}
+
+	running, err := db.GetRunsByStatus(models.RunStatusRunning)
+	if err != nil || len(running) != 1 || running[0].ID != "run_1" || running[0].ThreadID != "thread_1" {
+		t.Fatalf("expected run_1 to be running, got %+v err=%v", running, err)
+	}
+
+	if finished, err := db.FinishRun("run_1", "", ""); err != nil || !finished {
+		t.Fatalf("expected run to finish, got finished=%v err=%v", finished, err)
+	}
+	if finished, _ := db.FinishRun("run_1", "server_error", "late"); finished {
+		t.Error("expected a completed run not to finish again")
+	}
+
+	got, err := db.GetRun("run_1")
+	if err != nil {
+		t.Fatalf("failed to get run: %v", err)
+	}
+	if got.Status != models.RunStatusCompleted || got.Error != "" || got.CompletedAt == nil {
+		t.Errorf("unexpected finished run: %+v", got)
+	}
+
+	// A failed run keeps its error code
+	db.CreateRun("run_2", conv.ID, avatar.ID, "thread_1")
+	db.FinishRun("run_2", "rate_limit_exceeded", "run ended with status: failed")
+	if got, _ := db.GetRun("run_2"); got.Status != models.RunStatusFailed || got.ErrorCode != "rate_limit_exceeded" {
+		t.Errorf("expected a failed run with its error code, got %+v", got)
+	}
+
+	if _, err := db.GetRun("run_missing"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestFailRun(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Runs", "")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+
+	// A tracked running run is failed
+	db.CreateRun("run_tracked", conv.ID, avatar.ID, "thread_1")
+	if failed, err := db.FailRun("run_tracked", conv.ID, avatar.ID, "thread_1", "stuck"); err != nil || !failed {
+		t.Fatalf("expected tracked run to fail, got failed=%v err=%v", failed, err)
+	}
+	if got, _ := db.GetRun("run_tracked"); got.Status != models.RunStatusFailed || got.Error != "stuck" {
+		t.Errorf("expected the tracked run to fail, got %+v", got)
+	}
+
+	// An unknown run is recorded as failed
+	if failed, err := db.FailRun("run_untracked", conv.ID, avatar.ID, "thread_1", "stuck"); err != nil || !failed {
+		t.Fatalf("expected untracked run to fail, got failed=%v err=%v", failed, err)
+	}
+	if got, err := db.GetRun("run_untracked"); err != nil || got.Status != models.RunStatusFailed || got.CompletedAt == nil {
+		t.Errorf("expected the untracked run to be recorded as failed, got %+v err=%v", got, err)
+	}
+
+	// A finished run is left alone
+	db.CreateRun("run_done", conv.ID, avatar.ID, "thread_1")
+	db.FinishRun("run_done", "", "")
+	if failed, _ := db.FailRun("run_done", conv.ID, avatar.ID, "thread_1", "stuck"); failed {
+		t.Error("expected a completed run not to fail")
+	}
+	if got, _ := db.GetRun("run_done"); got.Status != models.RunStatusCompleted {
+		t.Errorf("expected the completed run to stay completed, got %+v", got)
+	}
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// ErrDuplicate is returned when a unique constraint is violated
+var ErrDuplicate = errors.New("duplicate entry")
+
+// isUniqueViolation reports whether err is a SQLite unique constraint failure
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// CreateTeam creates a new avatar team
+func (d *DB) CreateTeam(name, description string) (*models.Team, error) {
+	return WithLockResult(d, func() (*models.Team, error) {
+		result, err := d.db.Exec(
+			`INSERT INTO teams (name, description) VALUES (?, ?)`,
+			name, description,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrDuplicate
+			}
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.Team{
+			ID:          id,
+			Name:        name,
+			Description: description,
+			CreatedAt:   time.Now(),
+		}, nil
+	})
+}
+
+// GetTeam retrieves a team by ID
+func (d *DB) GetTeam(id int64) (*models.Team, error) {
+	return WithLockResult(d, func() (*models.Team, error) {
+		var team models.Team
+		err := d.db.QueryRow(
+			`SELECT id, name, description, created_at FROM teams WHERE id = ?`,
+			id,
+		).Scan(&team.ID, &team.Name, &team.Description, &team.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &team, nil
+	})
+}
+
+// GetAllTeams retrieves all teams
+func (d *DB) GetAllTeams() ([]models.Team, error) {
+	return WithLockResult(d, func() ([]models.Team, error) {
+		rows, err := d.db.Query(
+			`SELECT id, name, description, created_at FROM teams ORDER BY name ASC`,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var teams []models.Team
+		for rows.Next() {
+			var team models.Team
+			if err := rows.Scan(&team.ID, &team.Name, &team.Description, &team.CreatedAt); err != nil {
+				return nil, err
+			}
+			teams = append(teams, team)
+		}
+
+		return teams, rows.Err()
+	})
+}
+
+// UpdateTeam updates the name and description of a team
+func (d *DB) UpdateTeam(id int64, name, description string) (*models.Team, error) {
+	return WithLockResult(d, func() (*models.Team, error) {
+		result, err := d.db.Exec(
+			`UPDATE teams SET name = ?, description = ? WHERE id = ?`,
+			name, description, id,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrDuplicate
+			}
+			return nil, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, sql.ErrNoRows
+		}
+
+		var team models.Team
+		err = d.db.QueryRow(
+			`SELECT id, name, description, created_at FROM teams WHERE id = ?`,
+			id,
+		).Scan(&team.ID, &team.Name, &team.Description, &team.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &team, nil
+	})
+}
+
+// DeleteTeam deletes a team and its memberships
+func (d *DB) DeleteTeam(id int64) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(`DELETE FROM teams WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// AddTeamMember adds an avatar to a team
+// Adding an existing member is a no-op
+func (d *DB) AddTeamMember(teamID, avatarID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT OR IGNORE INTO team_members (team_id, avatar_id) VALUES (?, ?)`,
+			teamID, avatarID,
+		)
+		return err
+	})
+}
+
+// RemoveTeamMember removes an avatar from a team
+func (d *DB) RemoveTeamMember(teamID, avatarID int64) error {
+	return d.WithLock(func() error {
+		result, err := d.db.Exec(
+			`DELETE FROM team_members WHERE team_id = ? AND avatar_id = ?`,
+			teamID, avatarID,
+		)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// GetTeamMembers retrieves all avatars in a team
+func (d *DB) GetTeamMembers(teamID int64) ([]models.Avatar, error) {
+	return WithLockResult(d, func() ([]models.Avatar, error) {
+		rows, err := d.db.Query(`
+			SELECT `+avatarColumns+`
+			FROM avatars a
+			INNER JOIN team_members tm ON a.id = tm.avatar_id
+			WHERE tm.team_id = ?
+			ORDER BY a.id ASC
+		`, teamID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var avatars []models.Avatar
+		for rows.Next() {
+			avatar, err := scanAvatar(rows)
+			if err != nil {
+				return nil, err
+			}
+			avatars = append(avatars, *avatar)
+		}
+
+		return avatars, rows.Err()
+	})
+}
+
+// GetAvatarTeamNames retrieves the names of all teams an avatar belongs to
+func (d *DB) GetAvatarTeamNames(avatarID int64) ([]string, error) {
+	return WithLockResult(d, func() ([]string, error) {
+		rows, err := d.db.Query(`
+			SELECT t.name
+			FROM teams t
+			INNER JOIN team_members tm ON t.id = tm.team_id
+			WHERE tm.avatar_id = ?
+		`, avatarID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+
+		return names, rows.Err()
+	})
+}
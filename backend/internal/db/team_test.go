@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCreateTeam(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	team, err := db.CreateTeam("Support", "Customer support avatars")
+	if err != nil {
+		t.Fatalf("failed to create team: %v", err)
+	}
+
+	if team.ID == 0 {
+		t.Error("expected non-zero ID")
+	}
+	if team.Name != "Support" {
+		t.Errorf("expected name 'Support', got '%s'", team.Name)
+	}
+	if team.Description != "Customer support avatars" {
+		t.Errorf("unexpected description '%s'", team.Description)
+	}
+}
+
+func TestCreateTeam_DuplicateName(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.CreateTeam("Support", ""); err != nil {
+		t.Fatalf("failed to create team: %v", err)
+	}
+
+	// Names are unique regardless of case
+	if _, err := db.CreateTeam("support", ""); err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestUpdateTeam(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	team, _ := db.CreateTeam("Support", "")
+
+	updated, err := db.UpdateTeam(team.ID, "Helpdesk", "New description")
+	if err != nil {
+		t.Fatalf("failed to update team: %v", err)
+	}
+	if updated.Name != "Helpdesk" || updated.Description != "New description" {
+		t.Errorf("unexpected team after update: %+v", updated)
+	}
+
+	if _, err := db.UpdateTeam(9999, "Missing", ""); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestDeleteTeam(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	team, _ := db.CreateTeam("Support", "")
+	avatar, _ := db.CreateAvatar("Bot", "Prompt", "asst_1")
+	db.AddTeamMember(team.ID, avatar.ID)
+
+	if err := db.DeleteTeam(team.ID); err != nil {
+		t.Fatalf("failed to delete team: %v", err)
+	}
+
+	if _, err := db.GetTeam(team.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	// Avatars survive team deletion
+	if _, err := db.GetAvatar(avatar.ID); err != nil {
+		t.Errorf("expected avatar to remain, got %v", err)
+	}
+
+	if err := db.DeleteTeam(team.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows on second delete, got %v", err)
+	}
+}
+
+func TestTeamMembers(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	team, _ := db.CreateTeam("Support", "")
+	alice, _ := db.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := db.CreateAvatar("Bob", "Prompt", "asst_2")
+
+	if err := db.AddTeamMember(team.ID, alice.ID); err != nil {
+		t.Fatalf("failed to add member: %v", err)
+	}
+	if err := db.AddTeamMember(team.ID, bob.ID); err != nil {
+		t.Fatalf("failed to add member: %v", err)
+	}
+	// Adding twice is a no-op
+	if err := db.AddTeamMember(team.ID, bob.ID); err != nil {
+		t.Fatalf("expected duplicate add to succeed, got %v", err)
+	}
+
+	members, err := db.GetTeamMembers(team.ID)
+	if err != nil {
+		t.Fatalf("failed to get members: %v", err)
+	}
+	if len(members) != 2 {
+		t.Fatalf("expected 2 members, got %d", len(members))
+	}
+
+	if err := db.RemoveTeamMember(team.ID, alice.ID); err != nil {
+		t.Fatalf("failed to remove member: %v", err)
+	}
+	if err := db.RemoveTeamMember(team.ID, alice.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	members, _ = db.GetTeamMembers(team.ID)
+	if len(members) != 1 || members[0].ID != bob.ID {
+		t.Errorf("expected only Bob to remain, got %+v", members)
+	}
+}
+
+func TestGetAvatarTeamNames(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	support, _ := db.CreateTeam("Support", "")
+	sales, _ := db.CreateTeam("Sales", "")
+	avatar, _ := db.CreateAvatar("Alice", "Prompt", "asst_1")
+	db.AddTeamMember(support.ID, avatar.ID)
+	db.AddTeamMember(sales.ID, avatar.ID)
+
+	names, err := db.GetAvatarTeamNames(avatar.ID)
+	if err != nil {
+		t.Fatalf("failed to get team names: %v", err)
+	}
+	if len(names) != 2 {
+		t.Errorf("expected 2 team names, got %v", names)
+	}
+}
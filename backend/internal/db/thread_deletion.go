@@ -0,0 +1,64 @@
+package db
+
+import (
+	"multi-avatar-chat/internal/models"
+)
+
+// AddPendingThreadDeletion records a thread whose deletion failed so it can be retried later
+// The failed attempt counts as the first one; a thread already pending is left unchanged
+func (d *DB) AddPendingThreadDeletion(threadID string, conversationID int64, lastError string) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT OR IGNORE INTO pending_thread_deletions (thread_id, conversation_id, attempts, last_error) VALUES (?, ?, 1, ?)`,
+			threadID, conversationID, lastError,
+		)
+		return err
+	})
+}
+
+// GetPendingThreadDeletions returns the pending deletions with fewer than maxAttempts attempts, oldest first
+// A maxAttempts of 0 returns all of them
+func (d *DB) GetPendingThreadDeletions(maxAttempts int) ([]models.PendingThreadDeletion, error) {
+	return WithLockResult(d, func() ([]models.PendingThreadDeletion, error) {
+		rows, err := d.db.Query(
+			`SELECT id, thread_id, conversation_id, attempts, last_error, created_at, updated_at
+			FROM pending_thread_deletions WHERE ? = 0 OR attempts < ? ORDER BY id ASC`,
+			maxAttempts, maxAttempts,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var deletions []models.PendingThreadDeletion
+		for rows.Next() {
+			var p models.PendingThreadDeletion
+			if err := rows.Scan(&p.ID, &p.ThreadID, &p.ConversationID, &p.Attempts, &p.LastError,
+				&p.CreatedAt, &p.UpdatedAt); err != nil {
+				return nil, err
+			}
+			deletions = append(deletions, p)
+		}
+
+		return deletions, rows.Err()
+	})
+}
+
+// RecordThreadDeletionFailure counts another failed attempt to delete a pending thread
+func (d *DB) RecordThreadDeletionFailure(id int64, lastError string) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`UPDATE pending_thread_deletions SET attempts = attempts + 1, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			lastError, id,
+		)
+		return err
+	})
+}
+
+// DeletePendingThreadDeletion removes a pending deletion once its thread is gone
+func (d *DB) DeletePendingThreadDeletion(id int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(`DELETE FROM pending_thread_deletions WHERE id = ?`, id)
+		return err
+	})
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestPendingThreadDeletions_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := db.AddPendingThreadDeletion("thread_1", 1, "timeout"); err != nil {
+		t.Fatalf("failed to add pending deletion: %v", err)
+	}
+	if err := db.AddPendingThreadDeletion("thread_2", 1, "server error"); err != nil {
+		t.Fatalf("failed to add pending deletion: %v", err)
+	}
+	// Recording the same thread again keeps the original row
+	if err := db.AddPendingThreadDeletion("thread_1", 1, "other"); err != nil {
+		t.Fatalf("failed to add duplicate pending deletion: %v", err)
+	}
+
+	pending, err := db.GetPendingThreadDeletions(0)
+	if err != nil {
+		t.Fatalf("failed to get pending deletions: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ThreadID != "thread_1" || pending[0].Attempts != 1 || pending[0].LastError != "timeout" {
+		t.Fatalf("unexpected pending deletions: %+v", pending)
+	}
+
+	if err := db.RecordThreadDeletionFailure(pending[0].ID, "still failing"); err != nil {
+		t.Fatalf("failed to record failure: %v", err)
+	}
+	// thread_1 now has 2 attempts and is skipped with a limit of 2
+	limited, _ := db.GetPendingThreadDeletions(2)
+	if len(limited) != 1 || limited[0].ThreadID != "thread_2" {
+		t.Errorf("expected only thread_2 below the attempt limit, got %+v", limited)
+	}
+
+	if err := db.DeletePendingThreadDeletion(pending[1].ID); err != nil {
+		t.Fatalf("failed to delete pending deletion: %v", err)
+	}
+	remaining, _ := db.GetPendingThreadDeletions(0)
+	if len(remaining) != 1 || remaining[0].Attempts != 2 || remaining[0].LastError != "still failing" {
+		t.Errorf("unexpected remaining deletions: %+v", remaining)
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"time"
+)
+
+// ClaimThreadForward records that a message is being added to an OpenAI thread
+// Returns false when the same message was already added to the thread at or after since.
+// Records older than since are dropped on the way, so the table only holds recent forwards
+func (d *DB) ClaimThreadForward(threadID string, messageID int64, since time.Time) (bool, error) {
+	return WithLockResult(d, func() (bool, error) {
+		if _, err := d.db.Exec(
+			`DELETE FROM thread_forwards WHERE forwarded_at < ?`,
+			since.UTC().Format(sqliteTimeFormat),
+		); err != nil {
+			return false, err
+		}
+
+		result, err := d.db.Exec(
+			`INSERT OR IGNORE INTO thread_forwards (thread_id, message_id, forwarded_at) VALUES (?, ?, ?)`,
+			threadID, messageID, time.Now().UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			return false, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		return rows > 0, nil
+	})
+}
+
+// ReleaseThreadForward forgets a claim whose message could not be added to the thread
+func (d *DB) ReleaseThreadForward(threadID string, messageID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`DELETE FROM thread_forwards WHERE thread_id = ? AND message_id = ?`,
+			threadID, messageID,
+		)
+		return err
+	})
+}
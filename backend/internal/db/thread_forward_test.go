@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaimThreadForward(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	since := time.Now().Add(-time.Minute)
+	if claimed, err := database.ClaimThreadForward("thread_1", 1, since); err != nil || !claimed {
+		t.Fatalf("expected the first forward to be claimed, got %t err=%v", claimed, err)
+	}
+	if claimed, _ := database.ClaimThreadForward("thread_1", 1, since); claimed {
+		t.Error("expected the same message to be a duplicate on the same thread")
+	}
+	if claimed, _ := database.ClaimThreadForward("thread_2", 1, since); !claimed {
+		t.Error("expected the same message to be claimed on another thread")
+	}
+	if claimed, _ := database.ClaimThreadForward("thread_1", 2, since); !claimed {
+		t.Error("expected another message to be claimed on the same thread")
+	}
+
+	// A released claim can be made again, e.g. when retrying a failed forward
+	if err := database.ReleaseThreadForward("thread_1", 1); err != nil {
+		t.Fatalf("ReleaseThreadForward failed: %v", err)
+	}
+	if claimed, _ := database.ClaimThreadForward("thread_1", 1, since); !claimed {
+		t.Error("expected a released forward to be claimed again")
+	}
+
+	// Outside the window the message can be added again
+	if claimed, _ := database.ClaimThreadForward("thread_1", 1, time.Now().Add(time.Second)); !claimed {
+		t.Error("expected a message older than the window to be claimed again")
+	}
+}
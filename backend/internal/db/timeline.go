@@ -0,0 +1,70 @@
+package db
+
+import (
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// GetMessageTimeRange returns the number of messages in a conversation and when the first and last were created
+// first and last are zero when the conversation has no messages
+func (d *DB) GetMessageTimeRange(conversationID int64) (count int, first, last time.Time, err error) {
+	err = d.WithLock(func() error {
+		var firstUnix, lastUnix int64
+		if err := d.db.QueryRow(
+			`SELECT COUNT(*),
+				COALESCE(CAST(strftime('%s', MIN(created_at)) AS INTEGER), 0),
+				COALESCE(CAST(strftime('%s', MAX(created_at)) AS INTEGER), 0)
+			FROM messages WHERE conversation_id = ?`,
+			conversationID,
+		).Scan(&count, &firstUnix, &lastUnix); err != nil {
+			return err
+		}
+		if count > 0 {
+			first = time.Unix(firstUnix, 0).UTC()
+			last = time.Unix(lastUnix, 0).UTC()
+		}
+		return nil
+	})
+	return count, first, last, err
+}
+
+// GetMessageActivity counts the messages of each sender in a conversation per time window
+// Windows are aligned to the Unix epoch and only windows with messages are returned, oldest first
+func (d *DB) GetMessageActivity(conversationID int64, window time.Duration) ([]models.MessageActivity, error) {
+	seconds := int64(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return WithLockResult(d, func() ([]models.MessageActivity, error) {
+		rows, err := d.db.Query(
+			`SELECT CAST(strftime('%s', created_at) AS INTEGER) / ? AS bucket, sender_type, sender_id,
+				COUNT(*), COALESCE(SUM(length(content)), 0)
+			FROM messages WHERE conversation_id = ?
+			GROUP BY bucket, sender_type, sender_id
+			ORDER BY bucket, MIN(sequence)`,
+			seconds, conversationID,
+		)
+		if err != nil {
+			log.Printf("[DB] GetMessageActivity failed: query error conversation_id=%d err=%v", conversationID, err)
+			return nil, err
+		}
+		defer rows.Close()
+
+		activity := []models.MessageActivity{}
+		for rows.Next() {
+			var a models.MessageActivity
+			var bucket int64
+			var senderType string
+			if err := rows.Scan(&bucket, &senderType, &a.SenderID, &a.Messages, &a.Characters); err != nil {
+				return nil, err
+			}
+			a.WindowStart = time.Unix(bucket*seconds, 0).UTC()
+			a.SenderType = models.SenderType(senderType)
+			activity = append(activity, a)
+		}
+		return activity, rows.Err()
+	})
+}
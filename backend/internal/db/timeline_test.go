@@ -0,0 +1,52 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestGetMessageActivity(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	conv, _ := db.CreateConversation("Chat", "thread_1")
+	avatar, _ := db.CreateAvatar("Alice", "prompt", "asst_1")
+
+	if count, first, _, err := db.GetMessageTimeRange(conv.ID); err != nil || count != 0 || !first.IsZero() {
+		t.Fatalf("expected an empty range, got count=%d first=%v err=%v", count, first, err)
+	}
+
+	base := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	db.ImportMessages(conv.ID, []models.Message{
+		{SenderType: models.SenderTypeUser, Content: "hello", CreatedAt: base},
+		{SenderType: models.SenderTypeAvatar, SenderID: &avatar.ID, Content: "hi", CreatedAt: base.Add(time.Minute)},
+		{SenderType: models.SenderTypeUser, Content: "again", CreatedAt: base.Add(2 * time.Minute)},
+		{SenderType: models.SenderTypeAvatar, SenderID: &avatar.ID, Content: "later", CreatedAt: base.Add(time.Hour)},
+	})
+
+	count, first, last, err := db.GetMessageTimeRange(conv.ID)
+	if err != nil || count != 4 || !first.Equal(base) || !last.Equal(base.Add(time.Hour)) {
+		t.Fatalf("unexpected range count=%d first=%v last=%v err=%v", count, first, last, err)
+	}
+
+	activity, err := db.GetMessageActivity(conv.ID, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("GetMessageActivity failed: %v", err)
+	}
+	if len(activity) != 3 {
+		t.Fatalf("expected 3 sender windows, got %+v", activity)
+	}
+
+	user := activity[0]
+	if user.SenderType != models.SenderTypeUser || user.Messages != 2 || user.Characters != 10 || !user.WindowStart.Equal(base) {
+		t.Errorf("unexpected user window: %+v", user)
+	}
+	if a := activity[1]; a.SenderID == nil || *a.SenderID != avatar.ID || a.Messages != 1 || !a.WindowStart.Equal(base) {
+		t.Errorf("unexpected avatar window: %+v", a)
+	}
+	if a := activity[2]; a.Messages != 1 || !a.WindowStart.Equal(base.Add(time.Hour)) {
+		t.Errorf("unexpected later window: %+v", a)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"log"
+	"time"
+)
+
+// GetWatcherState returns the sequence number of the last message the avatar's watcher processed
+// Returns sql.ErrNoRows if the watcher has not saved its state yet
+func (d *DB) GetWatcherState(conversationID, avatarID int64) (int64, error) {
+	return WithLockResult(d, func() (int64, error) {
+		var lastSequence int64
+		err := d.db.QueryRow(
+			`SELECT last_sequence FROM watcher_states WHERE conversation_id = ? AND avatar_id = ?`,
+			conversationID, avatarID,
+		).Scan(&lastSequence)
+		return lastSequence, err
+	})
+}
+
+// SaveWatcherState records the sequence number of the last message the avatar's watcher processed
+func (d *DB) SaveWatcherState(conversationID, avatarID, lastSequence int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`INSERT INTO watcher_states (conversation_id, avatar_id, last_sequence, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (conversation_id, avatar_id) DO UPDATE SET last_sequence = excluded.last_sequence, updated_at = excluded.updated_at`,
+			conversationID, avatarID, lastSequence, time.Now().UTC().Format(sqliteTimeFormat),
+		)
+		if err != nil {
+			log.Printf("[DB] SaveWatcherState failed: exec error conversation_id=%d avatar_id=%d err=%v",
+				conversationID, avatarID, err)
+		}
+		return err
+	})
+}
+
+// DeleteWatcherState forgets the state of the avatar's watcher
+// Used when the avatar leaves the conversation so a later watcher does not resume from old messages
+func (d *DB) DeleteWatcherState(conversationID, avatarID int64) error {
+	return d.WithLock(func() error {
+		_, err := d.db.Exec(
+			`DELETE FROM watcher_states WHERE conversation_id = ? AND avatar_id = ?`,
+			conversationID, avatarID,
+		)
+		return err
+	})
+}
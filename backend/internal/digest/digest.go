@@ -0,0 +1,264 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// digestMaxTokens is the token limit for a generated summary
+const digestMaxTokens = 500
+
+// MessageBroadcaster defines the interface for broadcasting messages
+type MessageBroadcaster interface {
+	BroadcastMessage(conversationID int64, message models.MessageEvent)
+}
+
+// Notifier delivers generated digests outside the application (e.g. webhook, email)
+type Notifier interface {
+	NotifyDigest(digest *Digest) error
+}
+
+// Digest is a generated conversation summary
+type Digest struct {
+	ID                int64     `json:"id"`
+	ConversationID    int64     `json:"conversation_id"`
+	ConversationTitle string    `json:"conversation_title"`
+	MessageID         int64     `json:"message_id"`
+	MessageCount      int       `json:"message_count"`
+	Content           string    `json:"content"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Job periodically posts a digest to every conversation with new activity
+type Job struct {
+	db          *db.DB
+	assistant   *assistant.Client
+	interval    time.Duration
+	broadcaster MessageBroadcaster
+	notifiers   []Notifier
+	mu          sync.Mutex // serializes digest generation
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewJob creates a new digest job that runs every interval
+// If assistantClient is nil, digests fall back to activity statistics
+func NewJob(database *db.DB, assistantClient *assistant.Client, interval time.Duration) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Job{
+		db:        database,
+		assistant: assistantClient,
+		interval:  interval,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SetBroadcaster sets the message broadcaster for SSE notifications
+func (j *Job) SetBroadcaster(broadcaster MessageBroadcaster) {
+	j.broadcaster = broadcaster
+}
+
+// AddNotifier registers a notifier that receives every generated digest
+func (j *Job) AddNotifier(notifier Notifier) {
+	j.notifiers = append(j.notifiers, notifier)
+}
+
+// Start begins running the job in the background
+func (j *Job) Start() {
+	j.wg.Add(1)
+	go j.run()
+	log.Printf("[Digest] Job started interval=%v notifiers=%d", j.interval, len(j.notifiers))
+}
+
+// Stop stops the job and waits for a running digest to finish
+func (j *Job) Stop() {
+	j.cancel()
+	j.wg.Wait()
+	log.Printf("[Digest] Job stopped")
+}
+
+// run is the main loop of the job
+func (j *Job) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.RunOnce(); err != nil {
+				log.Printf("[Digest] Run failed err=%v", err)
+			}
+		}
+	}
+}
+
+// RunOnce generates digests for all conversations with messages since their last digest
+func (j *Job) RunOnce() error {
+	conversations, err := j.db.GetAllConversations()
+	if err != nil {
+		return err
+	}
+
+	generated := 0
+	for i := range conversations {
+		if j.ctx.Err() != nil {
+			break
+		}
+
+		digest, err := j.GenerateForConversation(&conversations[i])
+		if err != nil {
+			log.Printf("[Digest] Failed to generate digest conversation_id=%d err=%v", conversations[i].ID, err)
+			continue
+		}
+		if digest != nil {
+			generated++
+		}
+	}
+
+	log.Printf("[Digest] Run completed conversations=%d digests=%d", len(conversations), generated)
+	return nil
+}
+
+// GenerateForConversation summarizes the messages posted since the conversation's last digest,
+// stores the summary as a system message and notifies subscribers
+// Returns nil without error when there is no new activity
+func (j *Job) GenerateForConversation(conv *models.Conversation) (*Digest, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var afterID int64
+	latest, err := j.db.GetLatestDigest(conv.ID)
+	if err == nil {
+		afterID = latest.LastMessageID
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	messages, err := j.db.GetMessagesAfter(conv.ID, afterID)
+	if err != nil {
+		return nil, err
+	}
+
+	formatMessages, lastMessageID, err := j.formatMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+	if len(formatMessages) == 0 {
+		return nil, nil
+	}
+
+	log.Printf("[Digest] Generating digest conversation_id=%d message_count=%d", conv.ID, len(formatMessages))
+
+	summary, model := j.summarize(conv, formatMessages)
+	content := logic.FormatDigestMessage(summary, len(formatMessages))
+
+	msg, err := j.db.CreateMessage(conv.ID, models.SenderTypeSystem, nil, content)
+	if err != nil {
+		return nil, err
+	}
+
+	// An LLM summary is labeled as AI-generated even though it is a system message
+	if model != "" {
+		if err := j.db.RecordMessageModel(msg.ID, model); err != nil {
+			log.Printf("[Digest] Warning: failed to record message model message_id=%d err=%v", msg.ID, err)
+		} else {
+			msg.Model = model
+		}
+	}
+
+	record, err := j.db.CreateDigest(conv.ID, msg.ID, lastMessageID, len(formatMessages))
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &Digest{
+		ID:                record.ID,
+		ConversationID:    conv.ID,
+		ConversationTitle: conv.Title,
+		MessageID:         msg.ID,
+		MessageCount:      record.MessageCount,
+		Content:           content,
+		CreatedAt:         record.CreatedAt,
+	}
+
+	if j.broadcaster != nil {
+		j.broadcaster.BroadcastMessage(conv.ID, models.NewMessageEvent(msg))
+	}
+
+	for _, notifier := range j.notifiers {
+		if err := notifier.NotifyDigest(digest); err != nil {
+			log.Printf("[Digest] Warning: notifier failed conversation_id=%d err=%v", conv.ID, err)
+		}
+	}
+
+	log.Printf("[Digest] Digest generated conversation_id=%d digest_id=%d message_id=%d",
+		conv.ID, digest.ID, msg.ID)
+
+	return digest, nil
+}
+
+// formatMessages converts user and avatar messages for summarization, skipping system messages
+// Returns the formatted messages and the ID of the last message covered
+func (j *Job) formatMessages(messages []models.Message) ([]logic.MessageForFormat, int64, error) {
+	if len(messages) == 0 {
+		return nil, 0, nil
+	}
+
+	avatars, err := j.db.GetAllAvatars()
+	if err != nil {
+		return nil, 0, err
+	}
+	avatarNameMap := make(map[int64]string)
+	for _, a := range avatars {
+		avatarNameMap[a.ID] = a.Name
+	}
+
+	var formatted []logic.MessageForFormat
+	var lastMessageID int64
+	for _, msg := range messages {
+		if msg.SenderType == models.SenderTypeSystem {
+			continue
+		}
+
+		fm := logic.MessageForFormat{Content: msg.Content, SenderType: logic.SenderTypeUserFormat}
+		if msg.SenderType == models.SenderTypeAvatar {
+			fm.SenderType = logic.SenderTypeAvatarFormat
+			if msg.SenderID != nil {
+				fm.SenderName = avatarNameMap[*msg.SenderID]
+			}
+		}
+		formatted = append(formatted, fm)
+		lastMessageID = msg.ID
+	}
+
+	return formatted, lastMessageID, nil
+}
+
+// summarize asks the LLM for a summary, falling back to activity statistics
+// Also returns the model that wrote the summary, empty for the fallback
+func (j *Job) summarize(conv *models.Conversation, messages []logic.MessageForFormat) (string, string) {
+	if j.assistant != nil {
+		client := j.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+		summary, err := client.Completion(logic.BuildDigestPrompt(conv.Title, messages), digestMaxTokens)
+		if err == nil && summary != "" {
+			return summary, client.JudgmentModel()
+		}
+		log.Printf("[Digest] Summarization failed, using fallback conversation_id=%d err=%v", conv.ID, err)
+	}
+
+	return logic.FormatDigestFallback(messages), ""
+}
@@ -0,0 +1,161 @@
+package digest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// recordingNotifier collects digests for assertions
+type recordingNotifier struct {
+	mu      sync.Mutex
+	digests []*Digest
+}
+
+func (n *recordingNotifier) NotifyDigest(digest *Digest) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.digests = append(n.digests, digest)
+	return nil
+}
+
+func TestGenerateForConversation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Planning", "")
+	avatar, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	avatarID := avatar.ID
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "When do we release?")
+	database.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatarID, "Friday")
+
+	notifier := &recordingNotifier{}
+	job := NewJob(database, nil, time.Hour)
+	job.AddNotifier(notifier)
+
+	digest, err := job.GenerateForConversation(conv)
+	if err != nil {
+		t.Fatalf("failed to generate digest: %v", err)
+	}
+	if digest == nil {
+		t.Fatal("expected a digest")
+	}
+	if digest.MessageCount != 2 {
+		t.Errorf("expected 2 messages, got %d", digest.MessageCount)
+	}
+	if !strings.Contains(digest.Content, "Alice: 1 messages") {
+		t.Errorf("expected fallback summary, got %q", digest.Content)
+	}
+
+	// Digest is stored as a system message
+	messages, _ := database.GetMessages(conv.ID)
+	if last := messages[len(messages)-1]; last.SenderType != models.SenderTypeSystem || last.ID != digest.MessageID {
+		t.Errorf("expected digest system message, got %+v", last)
+	}
+
+	if len(notifier.digests) != 1 {
+		t.Errorf("expected notifier to be called once, got %d", len(notifier.digests))
+	}
+
+	// A fallback summary is not AI-generated
+	if messageModels, _ := database.GetConversationMessageModels(conv.ID); len(messageModels) != 0 {
+		t.Errorf("expected no model for a fallback digest, got %v", messageModels)
+	}
+}
+
+func TestGenerateForConversation_LabelsLLMSummary(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	client := testutil.NewMockAssistant(t).Client()
+
+	conv, _ := database.CreateConversation("Planning", "")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "When do we release?")
+
+	digest, err := NewJob(database, client, time.Hour).GenerateForConversation(conv)
+	if err != nil || digest == nil {
+		t.Fatalf("failed to generate digest: %v", err)
+	}
+
+	messageModels, _ := database.GetConversationMessageModels(conv.ID)
+	if messageModels[digest.MessageID] != client.JudgmentModel() {
+		t.Errorf("expected the digest to be labeled with %s, got %v", client.JudgmentModel(), messageModels)
+	}
+}
+
+func TestGenerateForConversation_NoNewMessages(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Planning", "")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	job := NewJob(database, nil, time.Hour)
+
+	if _, err := job.GenerateForConversation(conv); err != nil {
+		t.Fatalf("failed to generate digest: %v", err)
+	}
+
+	// The digest message itself does not count as new activity
+	digest, err := job.GenerateForConversation(conv)
+	if err != nil {
+		t.Fatalf("failed to generate digest: %v", err)
+	}
+	if digest != nil {
+		t.Errorf("expected no digest without new messages, got %+v", digest)
+	}
+}
+
+func TestRunOnce_SkipsInactiveConversations(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	active, _ := database.CreateConversation("Active", "")
+	database.CreateConversation("Inactive", "")
+	database.CreateMessage(active.ID, models.SenderTypeUser, nil, "hello")
+
+	notifier := &recordingNotifier{}
+	job := NewJob(database, nil, time.Hour)
+	job.AddNotifier(notifier)
+
+	if err := job.RunOnce(); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	if len(notifier.digests) != 1 || notifier.digests[0].ConversationID != active.ID {
+		t.Errorf("expected a single digest for the active conversation, got %+v", notifier.digests)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Digest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL)
+	err := notifier.NotifyDigest(&Digest{ID: 1, ConversationID: 2, Content: "summary"})
+	if err != nil {
+		t.Fatalf("NotifyDigest failed: %v", err)
+	}
+
+	if received.ConversationID != 2 || received.Content != "summary" {
+		t.Errorf("unexpected webhook payload: %+v", received)
+	}
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL)
+	if err := notifier.NotifyDigest(&Digest{ID: 1}); err == nil {
+		t.Error("expected error for non-2xx status")
+	}
+}
@@ -0,0 +1,165 @@
+package drift
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// DefaultInterval is how often the assistants are compared with their avatars
+	DefaultInterval = 30 * time.Minute
+	// Actor is recorded in the audit log for drift found by the detector
+	Actor = "system:drift"
+)
+
+// Detector compares the instructions of every avatar's assistant with the avatar's prompt
+// Edits made in the OpenAI dashboard change how an avatar behaves without going through the application;
+// the detector records them so they show up in the admin API and the audit log
+type Detector struct {
+	db        *db.DB
+	assistant *assistant.Client
+	// instructions builds the instructions an assistant is expected to have
+	instructions logic.InstructionsBuilder
+	interval     time.Duration
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewDetector creates a detector fetching assistants with the given client
+func NewDetector(database *db.DB, assistantClient *assistant.Client) *Detector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Detector{
+		db:        database,
+		assistant: assistantClient,
+		interval:  DefaultInterval,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SetInstructions sets the builder of the expected instructions, which adds the safety preamble
+func (d *Detector) SetInstructions(instructions logic.InstructionsBuilder) {
+	d.instructions = instructions
+}
+
+// SetInterval sets how often the assistants are checked
+func (d *Detector) SetInterval(interval time.Duration) {
+	d.interval = interval
+}
+
+// Start begins checking the assistants in the background
+func (d *Detector) Start() {
+	d.wg.Add(1)
+	go d.run()
+	log.Printf("[Drift] Started interval=%v", d.interval)
+}
+
+// Stop stops the detector and waits for a running pass to finish
+func (d *Detector) Stop() {
+	d.cancel()
+	d.wg.Wait()
+	log.Printf("[Drift] Stopped")
+}
+
+func (d *Detector) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.RunOnce(); err != nil {
+				log.Printf("[Drift] Run failed err=%v", err)
+			}
+		}
+	}
+}
+
+// RunOnce compares every avatar's assistant with the instructions built from its prompt
+// Assistants that cannot be fetched are skipped and keep their previous state. Returns the number of drifted avatars
+func (d *Detector) RunOnce() (int, error) {
+	if d.assistant == nil {
+		return 0, nil
+	}
+
+	avatars, err := d.db.GetAllAvatars()
+	if err != nil {
+		return 0, err
+	}
+
+	client := d.assistant.WithContext(d.ctx)
+	drifted := 0
+	for i := range avatars {
+		if d.ctx.Err() != nil {
+			break
+		}
+		avatar := &avatars[i]
+		if avatar.OpenAIAssistantID == "" {
+			continue
+		}
+
+		existing, err := client.GetAssistant(avatar.OpenAIAssistantID)
+		if err != nil {
+			// Missing assistants are listed as unlinked avatars by GET /api/admin/assistants
+			if !assistant.IsNotFound(err) {
+				log.Printf("[Drift] Failed to get assistant avatar_id=%d assistant_id=%s err=%v",
+					avatar.ID, avatar.OpenAIAssistantID, err)
+			}
+			continue
+		}
+
+		expected := d.instructions.AssistantInstructions(avatar.Prompt)
+		if existing.Instructions == expected {
+			if err := d.db.ClearAssistantDrift(avatar.ID); err != nil {
+				return drifted, err
+			}
+			continue
+		}
+
+		drifted++
+		changed, err := d.db.RecordAssistantDrift(avatar.ID, avatar.OpenAIAssistantID, existing.Instructions)
+		if err != nil {
+			return drifted, err
+		}
+		if changed {
+			d.recordAudit(avatar, expected, existing.Instructions)
+		}
+	}
+
+	if drifted > 0 {
+		log.Printf("[Drift] Pass completed avatars=%d drifted=%d", len(avatars), drifted)
+	}
+	return drifted, nil
+}
+
+// recordAudit records newly found drift in the audit log
+func (d *Detector) recordAudit(avatar *models.Avatar, expected, found string) {
+	log.Printf("[Drift] Assistant instructions changed outside the application avatar_id=%d assistant_id=%s",
+		avatar.ID, avatar.OpenAIAssistantID)
+
+	entry := &models.AuditEntry{
+		Actor:      Actor,
+		Action:     models.AuditActionAssistantDrift,
+		TargetType: "avatar",
+		TargetID:   strconv.FormatInt(avatar.ID, 10),
+		Changes: map[string]models.AuditChange{
+			"instructions": {From: expected, To: found},
+		},
+	}
+	if err := d.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("[Drift] Warning: failed to record audit entry avatar_id=%d err=%v", avatar.ID, err)
+	}
+}
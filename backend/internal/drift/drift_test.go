@@ -0,0 +1,100 @@
+package drift
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// newAssistantServer serves the assistants in instructions; other assistants are not found
+func newAssistantServer(t *testing.T, instructions map[string]string) (*assistant.Client, *sync.Mutex) {
+	t.Helper()
+
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimPrefix(r.URL.Path, "/assistants/")
+		mu.Lock()
+		found, ok := instructions[id]
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "not found"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "instructions": found})
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	})), &mu
+}
+
+func TestDetector_RunOnce(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	alice, _ := database.CreateAvatar("Alice", "Be kind", "asst_alice")
+	bob, _ := database.CreateAvatar("Bob", "Be brief", "asst_bob")
+	database.CreateAvatar("Gone", "Missing assistant", "asst_gone")
+	database.CreateAvatar("Local", "No assistant", "")
+
+	// The expected instructions include the configured safety preamble
+	builder := logic.NewInstructionsBuilder("Never share personal data.")
+	instructions := map[string]string{
+		"asst_alice": builder.AssistantInstructions(alice.Prompt),
+		"asst_bob":   "Edited in the dashboard",
+	}
+	client, mu := newAssistantServer(t, instructions)
+	detector := NewDetector(database, client)
+	detector.SetInstructions(builder)
+
+	drifted, err := detector.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if drifted != 1 {
+		t.Fatalf("expected 1 drifted avatar, got %d", drifted)
+	}
+	drifts, _ := database.GetAssistantDrifts()
+	if len(drifts) != 1 || drifts[0].AvatarID != bob.ID || drifts[0].Instructions != "Edited in the dashboard" {
+		t.Fatalf("expected Bob's drift to be recorded, got %+v", drifts)
+	}
+
+	// Drift that is still there is audited only once
+	detector.RunOnce()
+	entries, _ := database.GetAuditEntries(models.AuditFilter{Action: models.AuditActionAssistantDrift, Limit: 10})
+	if len(entries) != 1 || entries[0].Actor != Actor || entries[0].Changes["instructions"].To != "Edited in the dashboard" {
+		t.Errorf("expected one audit entry for the drift, got %+v", entries)
+	}
+
+	// Drift is cleared once the assistant matches again
+	mu.Lock()
+	instructions["asst_bob"] = builder.AssistantInstructions(bob.Prompt)
+	mu.Unlock()
+	if drifted, _ := detector.RunOnce(); drifted != 0 {
+		t.Errorf("expected no drifted avatars, got %d", drifted)
+	}
+	if drifts, _ := database.GetAssistantDrifts(); len(drifts) != 0 {
+		t.Errorf("expected the drift to be cleared, got %+v", drifts)
+	}
+}
+
+func TestDetector_StartStop(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	detector := NewDetector(database, nil)
+	detector.SetInterval(10 * time.Millisecond)
+	detector.Start()
+	time.Sleep(30 * time.Millisecond)
+	detector.Stop()
+}
@@ -0,0 +1,200 @@
+package expiry
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// DefaultInterval is how often expired conversations are looked for
+	DefaultInterval = time.Minute
+	// Actor is recorded in the audit log for conversations deleted by the job
+	Actor = "system:expiry"
+)
+
+// Notifier is notified before an expired conversation is deleted, and drops what it keeps for it afterwards
+type Notifier interface {
+	BroadcastConversationExpired(conversationID int64, title string)
+	DropConversation(conversationID int64)
+}
+
+// WatcherStopper stops the avatar watchers of a conversation
+type WatcherStopper interface {
+	StopRoomWatchers(conversationID int64) error
+}
+
+// Job deletes conversations whose TTL has passed
+// Clients are notified first, then the watchers are stopped so no avatar responds while the
+// conversation is deleted, and finally the OpenAI threads are deleted. Threads that cannot be
+// deleted are recorded for the thread collector like on a manual delete
+type Job struct {
+	db        *db.DB
+	assistant *assistant.Client
+	watchers  WatcherStopper
+	notifier  Notifier
+	interval  time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewJob creates a job deleting expired conversations and their threads with the given client
+func NewJob(database *db.DB, assistantClient *assistant.Client, watchers WatcherStopper) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Job{
+		db:        database,
+		assistant: assistantClient,
+		watchers:  watchers,
+		interval:  DefaultInterval,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SetNotifier sets the notifier for expired conversations
+func (j *Job) SetNotifier(notifier Notifier) {
+	j.notifier = notifier
+}
+
+// SetInterval sets how often expired conversations are looked for
+func (j *Job) SetInterval(d time.Duration) {
+	j.interval = d
+}
+
+// Start begins deleting expired conversations in the background
+func (j *Job) Start() {
+	j.wg.Add(1)
+	go j.run()
+	log.Printf("[Expiry] Started interval=%v", j.interval)
+}
+
+// Stop stops the job and waits for a running pass to finish
+func (j *Job) Stop() {
+	j.cancel()
+	j.wg.Wait()
+	log.Printf("[Expiry] Stopped")
+}
+
+func (j *Job) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := j.RunOnce(); err != nil {
+				log.Printf("[Expiry] Run failed err=%v", err)
+			}
+		}
+	}
+}
+
+// RunOnce deletes every conversation that has expired
+// Returns the number of conversations deleted
+func (j *Job) RunOnce() (int, error) {
+	expired, err := j.db.GetExpiredConversations(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for i := range expired {
+		if j.ctx.Err() != nil {
+			break
+		}
+		if err := j.expire(&expired[i]); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		log.Printf("[Expiry] Pass completed deleted=%d", deleted)
+	}
+	return deleted, nil
+}
+
+// expire deletes one expired conversation, its watchers and its OpenAI threads
+func (j *Job) expire(conv *models.Conversation) error {
+	log.Printf("[Expiry] Conversation expired conversation_id=%d expires_at=%v", conv.ID, conv.ExpiresAt)
+
+	if j.notifier != nil {
+		j.notifier.BroadcastConversationExpired(conv.ID, conv.Title)
+	}
+
+	if j.watchers != nil {
+		if err := j.watchers.StopRoomWatchers(conv.ID); err != nil {
+			log.Printf("[Expiry] Warning: failed to stop room watchers conversation_id=%d err=%v", conv.ID, err)
+		}
+	}
+
+	// Collect every OpenAI thread of the conversation before its rows are gone
+	var threadIDs []string
+	if conv.ThreadID != "" {
+		threadIDs = append(threadIDs, conv.ThreadID)
+	}
+	_, avatarThreadIDs, err := j.db.GetConversationAvatarsWithThreads(conv.ID)
+	if err != nil {
+		return err
+	}
+	for _, threadID := range avatarThreadIDs {
+		if threadID != "" {
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+
+	if err := j.db.DeleteConversation(conv.ID); err != nil {
+		return err
+	}
+	if j.notifier != nil {
+		j.notifier.DropConversation(conv.ID)
+	}
+	j.deleteThreads(conv, threadIDs)
+
+	entry := &models.AuditEntry{
+		Actor:      Actor,
+		Action:     models.AuditActionConversationExpire,
+		TargetType: "conversation",
+		TargetID:   strconv.FormatInt(conv.ID, 10),
+		Changes: map[string]models.AuditChange{
+			"title":      {From: conv.Title},
+			"expires_at": {From: conv.ExpiresAt.UTC().Format(time.RFC3339)},
+		},
+	}
+	if err := j.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("[Expiry] Warning: failed to record audit entry conversation_id=%d err=%v", conv.ID, err)
+	}
+
+	log.Printf("[Expiry] Conversation deleted conversation_id=%d threads=%d", conv.ID, len(threadIDs))
+	return nil
+}
+
+// deleteThreads deletes the OpenAI threads of an expired conversation
+// Threads that cannot be deleted now are recorded so the thread collector retries them later
+func (j *Job) deleteThreads(conv *models.Conversation, threadIDs []string) {
+	for _, threadID := range threadIDs {
+		reason := "assistant client not configured"
+		if j.assistant != nil {
+			err := j.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject).WithContext(j.ctx).DeleteThread(threadID)
+			if err == nil || assistant.IsNotFound(err) {
+				continue
+			}
+			log.Printf("[Expiry] Warning: failed to delete OpenAI thread thread_id=%s err=%v", threadID, err)
+			reason = err.Error()
+		}
+		if err := j.db.AddPendingThreadDeletion(threadID, conv.ID, reason); err != nil {
+			log.Printf("[Expiry] Warning: failed to record pending thread deletion thread_id=%s err=%v", threadID, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package expiry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// recorder records the notifications and stopped watchers in the order they happen
+type recorder struct {
+	database *db.DB
+	calls    []string
+}
+
+func (r *recorder) BroadcastConversationExpired(conversationID int64, title string) {
+	// Clients must be notified while the conversation still exists
+	if _, err := r.database.GetConversation(conversationID); err != nil {
+		r.calls = append(r.calls, "notified after delete")
+		return
+	}
+	r.calls = append(r.calls, "notify "+title)
+}
+
+func (r *recorder) DropConversation(conversationID int64) {
+	r.calls = append(r.calls, "drop")
+}
+
+func (r *recorder) StopRoomWatchers(conversationID int64) error {
+	r.calls = append(r.calls, "stop")
+	return nil
+}
+
+// newDeleteServer answers thread deletions, failing those whose ID contains "failing"
+func newDeleteServer(t *testing.T) *assistant.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if strings.Contains(id, "failing") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "failed"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "` + id + `", "deleted": true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL},
+	}))
+}
+
+func TestJob_RunOnce(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	expired, _ := database.CreateConversation("Demo room", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(expired.ID, avatar.ID, "thread_failing")
+	past := time.Now().Add(-time.Minute)
+	database.SetConversationExpiry(expired.ID, &past)
+
+	active, _ := database.CreateConversation("Active room", "")
+	future := time.Now().Add(time.Hour)
+	database.SetConversationExpiry(active.ID, &future)
+
+	rec := &recorder{database: database}
+	job := NewJob(database, newDeleteServer(t), rec)
+	job.SetNotifier(rec)
+
+	deleted, err := job.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 deleted conversation, got %d", deleted)
+	}
+	if len(rec.calls) != 3 || rec.calls[0] != "notify Demo room" || rec.calls[1] != "stop" || rec.calls[2] != "drop" {
+		t.Errorf("expected a notification before stopping the watchers and the replay buffer dropped after the delete, got %v", rec.calls)
+	}
+
+	if _, err := database.GetConversation(expired.ID); err == nil {
+		t.Error("expected the expired conversation to be deleted")
+	}
+	if _, err := database.GetConversation(active.ID); err != nil {
+		t.Errorf("expected the active conversation to be kept, got %v", err)
+	}
+
+	// The thread that could not be deleted is left for the thread collector
+	pending, _ := database.GetPendingThreadDeletions(0)
+	if len(pending) != 1 || pending[0].ThreadID != "thread_failing" || pending[0].ConversationID != expired.ID {
+		t.Errorf("expected thread_failing to be pending, got %+v", pending)
+	}
+
+	entries, _ := database.GetAuditEntries(models.AuditFilter{Action: models.AuditActionConversationExpire, Limit: 10})
+	if len(entries) != 1 || entries[0].Actor != Actor || entries[0].Changes["title"].From != "Demo room" {
+		t.Errorf("expected an audit entry for the expired conversation, got %+v", entries)
+	}
+
+	// Nothing is left to expire
+	if deleted, _ := job.RunOnce(); deleted != 0 {
+		t.Errorf("expected nothing to expire on the second pass, got %d", deleted)
+	}
+}
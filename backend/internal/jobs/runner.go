@@ -0,0 +1,236 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+// DefaultWorkers is the number of jobs executed at the same time by default
+const DefaultWorkers = 2
+
+// interruptedError is recorded on jobs that were running when the previous process stopped
+const interruptedError = "interrupted by a server restart"
+
+// ErrUnknownJobType is returned when enqueuing a job type without a registered handler
+var ErrUnknownJobType = errors.New("unknown job type")
+
+// Handler executes a job from its JSON payload
+// The returned value is stored as the JSON result of the job
+type Handler func(ctx context.Context, payload json.RawMessage) (any, error)
+
+// Runner executes long-running operations in the background so HTTP requests can
+// return a job handle immediately
+// Jobs are stored in the database; queued jobs survive a restart, while jobs that were
+// running when the process stopped are marked as failed
+type Runner struct {
+	db       *db.DB
+	workers  int
+	handlers map[string]Handler
+
+	mu      sync.Mutex
+	pending []int64
+	notify  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunner creates a job runner executing up to workers jobs at the same time
+func NewRunner(database *db.DB, workers int) *Runner {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		db:       database,
+		workers:  workers,
+		handlers: make(map[string]Handler),
+		notify:   make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Register sets the handler for a job type
+// Handlers must be registered before Start so queued jobs from a previous run can be resumed
+func (r *Runner) Register(jobType string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = handler
+}
+
+// Start fails jobs interrupted by a restart, resumes queued jobs and starts the workers
+func (r *Runner) Start() {
+	if n, err := r.db.FailRunningJobs(interruptedError); err != nil {
+		log.Printf("[Jobs] Failed to mark interrupted jobs err=%v", err)
+	} else if n > 0 {
+		log.Printf("[Jobs] Marked interrupted jobs as failed count=%d", n)
+	}
+
+	queued, err := r.db.GetJobsByStatus(models.JobStatusQueued)
+	if err != nil {
+		log.Printf("[Jobs] Failed to load queued jobs err=%v", err)
+	}
+	for _, job := range queued {
+		r.dispatch(job.ID)
+	}
+
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	log.Printf("[Jobs] Started workers=%d resumed=%d", r.workers, len(queued))
+}
+
+// Stop waits for running jobs to finish; jobs still queued are resumed on the next start
+func (r *Runner) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Printf("[Jobs] Stopped")
+}
+
+// Enqueue stores a job and schedules it for execution
+func (r *Runner) Enqueue(jobType string, payload any) (*models.Job, error) {
+	r.mu.Lock()
+	_, ok := r.handlers[jobType]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := r.db.CreateJob(jobType, string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Jobs] Job queued job_id=%d job_type=%s", job.ID, jobType)
+	r.dispatch(job.ID)
+	return job, nil
+}
+
+// dispatch hands a job to the workers
+func (r *Runner) dispatch(id int64) {
+	r.mu.Lock()
+	r.pending = append(r.pending, id)
+	r.mu.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the oldest pending job, or returns false if there is none
+func (r *Runner) next() (int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		return 0, false
+	}
+	id := r.pending[0]
+	r.pending = r.pending[1:]
+	return id, true
+}
+
+// work executes pending jobs until the runner stops
+func (r *Runner) work() {
+	defer r.wg.Done()
+	for {
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		id, ok := r.next()
+		if !ok {
+			select {
+			case <-r.notify:
+			case <-r.ctx.Done():
+				return
+			}
+			continue
+		}
+
+		// Wake another worker in case more jobs are pending
+		select {
+		case r.notify <- struct{}{}:
+		default:
+		}
+
+		r.run(id)
+	}
+}
+
+// run executes a single job and records its outcome
+func (r *Runner) run(id int64) {
+	started, err := r.db.StartJob(id)
+	if err != nil {
+		log.Printf("[Jobs] Failed to start job job_id=%d err=%v", id, err)
+		return
+	}
+	if !started {
+		return
+	}
+
+	job, err := r.db.GetJob(id)
+	if err != nil {
+		log.Printf("[Jobs] Failed to load job job_id=%d err=%v", id, err)
+		return
+	}
+
+	r.mu.Lock()
+	handler := r.handlers[job.Type]
+	r.mu.Unlock()
+
+	log.Printf("[Jobs] Job started job_id=%d job_type=%s", id, job.Type)
+
+	var result string
+	if handler == nil {
+		err = fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type)
+	} else {
+		var value any
+		value, err = execute(r.ctx, handler, json.RawMessage(job.Payload))
+		if err == nil && value != nil {
+			data, marshalErr := json.Marshal(value)
+			if marshalErr != nil {
+				err = marshalErr
+			} else {
+				result = string(data)
+			}
+		}
+	}
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		log.Printf("[Jobs] Job failed job_id=%d job_type=%s err=%v", id, job.Type, err)
+	} else {
+		log.Printf("[Jobs] Job succeeded job_id=%d job_type=%s", id, job.Type)
+	}
+
+	if err := r.db.FinishJob(id, result, errMsg); err != nil {
+		log.Printf("[Jobs] Failed to record job outcome job_id=%d err=%v", id, err)
+	}
+}
+
+// execute calls a handler, turning a panic into an error so the worker keeps running
+func execute(ctx context.Context, handler Handler, payload json.RawMessage) (value any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return handler(ctx, payload)
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// waitForJob polls a job until it succeeds or fails
+func waitForJob(t *testing.T, database *db.DB, id int64) *models.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := database.GetJob(id)
+		if err != nil {
+			t.Fatalf("failed to get job: %v", err)
+		}
+		if job.Status == models.JobStatusSucceeded || job.Status == models.JobStatusFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish", id)
+	return nil
+}
+
+func TestRunner_ExecutesJobs(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	runner := NewRunner(database, 2)
+	runner.Register("double", func(ctx context.Context, payload json.RawMessage) (any, error) {
+		var n int
+		if err := json.Unmarshal(payload, &n); err != nil {
+			return nil, err
+		}
+		return map[string]int{"value": n * 2}, nil
+	})
+	runner.Register("broken", func(ctx context.Context, payload json.RawMessage) (any, error) {
+		return nil, errors.New("boom")
+	})
+	runner.Register("panics", func(ctx context.Context, payload json.RawMessage) (any, error) {
+		panic("unexpected")
+	})
+	runner.Start()
+	defer runner.Stop()
+
+	ok, err := runner.Enqueue("double", 21)
+	if err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	broken, _ := runner.Enqueue("broken", nil)
+	panics, _ := runner.Enqueue("panics", nil)
+
+	if job := waitForJob(t, database, ok.ID); job.Status != models.JobStatusSucceeded || job.Result != `{"value":42}` {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if job := waitForJob(t, database, broken.ID); job.Status != models.JobStatusFailed || job.Error != "boom" {
+		t.Errorf("expected a failed job, got %+v", job)
+	}
+	if job := waitForJob(t, database, panics.ID); job.Status != models.JobStatusFailed {
+		t.Errorf("expected a panicking job to fail, got %+v", job)
+	}
+
+	if _, err := runner.Enqueue("missing", nil); !errors.Is(err, ErrUnknownJobType) {
+		t.Errorf("expected ErrUnknownJobType, got %v", err)
+	}
+}
+
+func TestRunner_ResumesAfterRestart(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	interrupted, _ := database.CreateJob("noop", "{}")
+	database.StartJob(interrupted.ID)
+	queued, _ := database.CreateJob("noop", "{}")
+
+	runner := NewRunner(database, 1)
+	runner.Register("noop", func(ctx context.Context, payload json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	runner.Start()
+	defer runner.Stop()
+
+	if job := waitForJob(t, database, interrupted.ID); job.Status != models.JobStatusFailed || job.Error != interruptedError {
+		t.Errorf("expected the interrupted job to fail, got %+v", job)
+	}
+	if job := waitForJob(t, database, queued.ID); job.Status != models.JobStatusSucceeded {
+		t.Errorf("expected the queued job to resume, got %+v", job)
+	}
+}
@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"hash/fnv"
+	"regexp"
+)
+
+// avatarColors is the palette used for speaker colors (readable on light and dark backgrounds)
+var avatarColors = []string{
+	"#E57373", "#F06292", "#BA68C8", "#9575CD",
+	"#7986CB", "#64B5F6", "#4DB6AC", "#81C784",
+	"#DCE775", "#FFD54F", "#FFB74D", "#A1887F",
+}
+
+// avatarEmojis is the set of emojis assigned to avatars
+var avatarEmojis = []string{
+	"🦊", "🐼", "🦉", "🐙", "🦁", "🐧",
+	"🐢", "🦄", "🐝", "🐳", "🦜", "🐨",
+}
+
+// colorRegex matches #RRGGBB hex colors
+var colorRegex = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// AvatarDisplay holds the display metadata of an avatar
+type AvatarDisplay struct {
+	Color string
+	Emoji string
+}
+
+// AssignAvatarDisplay returns a stable color and emoji derived from the avatar name
+// The same name always yields the same combination
+func AssignAvatarDisplay(name string) AvatarDisplay {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	sum := h.Sum32()
+
+	colorIndex := sum % uint32(len(avatarColors))
+	emojiIndex := (sum / uint32(len(avatarColors))) % uint32(len(avatarEmojis))
+
+	return AvatarDisplay{
+		Color: avatarColors[colorIndex],
+		Emoji: avatarEmojis[emojiIndex],
+	}
+}
+
+// IsValidAvatarColor checks that a color is a #RRGGBB hex string
+func IsValidAvatarColor(color string) bool {
+	return colorRegex.MatchString(color)
+}
@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"testing"
+)
+
+func TestAssignAvatarDisplay_Stable(t *testing.T) {
+	first := AssignAvatarDisplay("太郎")
+	second := AssignAvatarDisplay("太郎")
+
+	if first != second {
+		t.Errorf("expected stable display for the same name, got %+v and %+v", first, second)
+	}
+	if !IsValidAvatarColor(first.Color) {
+		t.Errorf("expected valid color, got %q", first.Color)
+	}
+	if first.Emoji == "" {
+		t.Error("expected non-empty emoji")
+	}
+}
+
+func TestAssignAvatarDisplay_Varies(t *testing.T) {
+	names := []string{"Alice", "Bob", "Carol", "Dave", "Eve", "Frank"}
+	colors := make(map[string]bool)
+	for _, name := range names {
+		colors[AssignAvatarDisplay(name).Color] = true
+	}
+
+	if len(colors) < 2 {
+		t.Errorf("expected different names to spread across colors, got %d distinct", len(colors))
+	}
+}
+
+func TestIsValidAvatarColor(t *testing.T) {
+	valid := []string{"#FFFFFF", "#00aaFF", "#123456"}
+	invalid := []string{"", "red", "#FFF", "123456", "#GGGGGG"}
+
+	for _, c := range valid {
+		if !IsValidAvatarColor(c) {
+			t.Errorf("expected %q to be valid", c)
+		}
+	}
+	for _, c := range invalid {
+		if IsValidAvatarColor(c) {
+			t.Errorf("expected %q to be invalid", c)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package logic
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// NormalizeAvatarName returns the key avatar names are compared by
+// Names differing only in case or in full-width and half-width forms (e.g. "ＡＩ" and "ai") get the same key
+func NormalizeAvatarName(name string) string {
+	return strings.ToLower(width.Fold.String(strings.TrimSpace(name)))
+}
+
+// SuggestAvatarName returns a variant of name that no existing name normalizes to, e.g. "Alice2"
+// A separating underscore is added when the name already ends in a digit, so the result stays mentionable
+func SuggestAvatarName(name string, existing []string) string {
+	taken := make(map[string]bool)
+	for _, n := range existing {
+		taken[NormalizeAvatarName(n)] = true
+	}
+
+	base := strings.TrimSpace(name)
+	if last, _ := utf8.DecodeLastRuneInString(base); unicode.IsDigit(last) {
+		base += "_"
+	}
+	for i := 2; ; i++ {
+		candidate := base + strconv.Itoa(i)
+		if !taken[NormalizeAvatarName(candidate)] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"testing"
+)
+
+func TestNormalizeAvatarName(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Alice", "alice"},
+		{"ＡＬＩＣＥ", "alice"},
+		{"ｱﾘｽ", "アリス"},
+		{" Bob ", "bob"},
+	}
+	for _, tt := range tests {
+		if NormalizeAvatarName(tt.a) != NormalizeAvatarName(tt.b) {
+			t.Errorf("expected %q and %q to normalize to the same key, got %q and %q",
+				tt.a, tt.b, NormalizeAvatarName(tt.a), NormalizeAvatarName(tt.b))
+		}
+	}
+
+	if NormalizeAvatarName("Alice") == NormalizeAvatarName("Alicia") {
+		t.Error("expected different names to have different keys")
+	}
+}
+
+func TestSuggestAvatarName(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		want     string
+	}{
+		{"Alice", []string{"alice"}, "Alice2"},
+		{"Alice", []string{"Alice", "ＡＬＩＣＥ２", "alice3"}, "Alice4"},
+		{"R2", []string{"R2"}, "R2_2"},
+		{" Bob ", []string{"Bob"}, "Bob2"},
+	}
+	for _, tt := range tests {
+		if got := SuggestAvatarName(tt.name, tt.existing); got != tt.want {
+			t.Errorf("SuggestAvatarName(%q, %v) = %q, want %q", tt.name, tt.existing, got, tt.want)
+		}
+	}
+}
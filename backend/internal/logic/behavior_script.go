@@ -0,0 +1,218 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"multi-avatar-chat/internal/script"
+)
+
+// Behavior script decisions; an empty decision leaves it to the usual mention and LLM judgment
+const (
+	BehaviorDecisionRespond = "respond"
+	BehaviorDecisionSkip    = "skip"
+)
+
+// Limits of a single behavior script run
+const (
+	MaxBehaviorWebhooks      = 3
+	MaxBehaviorContextLength = 4000
+	maxBehaviorOutputLines   = 50
+)
+
+// BehaviorMessage is a message as seen by a behavior script
+type BehaviorMessage struct {
+	ID         int64
+	SenderType string
+	SenderName string
+	Content    string
+}
+
+// BehaviorInput is what a behavior script can see when a message arrives
+type BehaviorInput struct {
+	AvatarName        string
+	Message           BehaviorMessage
+	ConversationID    int64
+	ConversationTitle string
+	Participants      []string
+	// Recent holds the latest messages of the conversation, oldest first, including Message
+	Recent []BehaviorMessage
+	// WebhookHosts are the hosts webhooks may call even though they are local or private
+	// They come from the server configuration; scripts cannot read them
+	WebhookHosts []string
+}
+
+// BehaviorWebhook is a webhook call requested by a behavior script
+type BehaviorWebhook struct {
+	URL     string `json:"url"`
+	Payload any    `json:"payload"`
+}
+
+// BehaviorResult is what a behavior script asked for
+type BehaviorResult struct {
+	Decision string            `json:"decision,omitempty"`
+	Context  []string          `json:"context,omitempty"`
+	Webhooks []BehaviorWebhook `json:"webhooks,omitempty"`
+	Output   []string          `json:"output,omitempty"`
+}
+
+// behaviorNames are the values and functions a behavior script can use besides the Starlark built-ins
+var behaviorNames = []string{"message", "conversation", "avatar", "respond", "skip", "add_context", "webhook", "print"}
+
+// CompileBehaviorScript compiles a behavior script, reporting syntax errors and undefined names with their line
+func CompileBehaviorScript(src string) (*script.Program, error) {
+	return script.Compile(src, behaviorNames...)
+}
+
+// RunBehaviorScript runs an avatar's behavior script for an incoming message
+// Scripts read the message, conversation and avatar values and call respond(), skip(),
+// add_context(text), webhook(url, payload) and print(...); when both respond() and skip()
+// are called the last call wins. Webhooks are only collected here, the caller sends them
+func RunBehaviorScript(program *script.Program, in BehaviorInput) (*BehaviorResult, error) {
+	result := &BehaviorResult{}
+	contextLength := 0
+
+	predeclared := map[string]script.Value{
+		"message": behaviorMessageValue(in.Message),
+		"conversation": script.NewStruct("conversation", map[string]script.Value{
+			"id":           script.FromGo(in.ConversationID),
+			"title":        script.FromGo(in.ConversationTitle),
+			"participants": script.FromGo(in.Participants),
+			"messages":     behaviorMessagesValue(in.Recent),
+		}),
+		"avatar": script.NewStruct("avatar", map[string]script.Value{
+			"name": script.FromGo(in.AvatarName),
+		}),
+		"respond": script.NewBuiltin("respond", func(args []script.Value) (script.Value, error) {
+			result.Decision = BehaviorDecisionRespond
+			return nil, nil
+		}),
+		"skip": script.NewBuiltin("skip", func(args []script.Value) (script.Value, error) {
+			result.Decision = BehaviorDecisionSkip
+			return nil, nil
+		}),
+		"add_context": script.NewBuiltin("add_context", func(args []script.Value) (script.Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("takes 1 argument, got %d", len(args))
+			}
+			text, ok := script.AsString(args[0])
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %s", script.TypeName(args[0]))
+			}
+			text = strings.TrimSpace(text)
+			if text == "" {
+				return nil, nil
+			}
+			contextLength += len(text)
+			if contextLength > MaxBehaviorContextLength {
+				return nil, fmt.Errorf("context is limited to %d characters", MaxBehaviorContextLength)
+			}
+			result.Context = append(result.Context, text)
+			return nil, nil
+		}),
+		"webhook": script.NewBuiltin("webhook", func(args []script.Value) (script.Value, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("takes 1 or 2 arguments, got %d", len(args))
+			}
+			rawURL, ok := script.AsString(args[0])
+			if !ok {
+				return nil, fmt.Errorf("expected a URL string, got %s", script.TypeName(args[0]))
+			}
+			if err := ValidateWebhookURL(rawURL, in.WebhookHosts); err != nil {
+				return nil, err
+			}
+			if len(result.Webhooks) >= MaxBehaviorWebhooks {
+				return nil, fmt.Errorf("at most %d webhooks can be called per message", MaxBehaviorWebhooks)
+			}
+			var payload any
+			if len(args) == 2 {
+				payload = script.ToGo(args[1])
+			}
+			result.Webhooks = append(result.Webhooks, BehaviorWebhook{URL: rawURL, Payload: payload})
+			return nil, nil
+		}),
+		"print": script.NewBuiltin("print", func(args []script.Value) (script.Value, error) {
+			if len(result.Output) >= maxBehaviorOutputLines {
+				return nil, nil
+			}
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = script.String(arg)
+			}
+			result.Output = append(result.Output, strings.Join(parts, " "))
+			return nil, nil
+		}),
+	}
+
+	if err := program.Run(predeclared); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// cgnatPrefix is the shared address space of carrier-grade NAT, also used for some cloud metadata services
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// ValidateWebhookURL checks that a behavior script webhook is an absolute http or https URL
+// that does not point at localhost or a loopback, private or link-local address, unless its host is in allowedHosts.
+// Host names are checked against the addresses they resolve to when the webhook is sent
+func ValidateWebhookURL(rawURL string, allowedHosts []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid webhook URL " + rawURL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("webhook URLs must use http or https")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range allowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return nil
+		}
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("webhooks cannot call " + host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !PublicAddress(addr) {
+		return errors.New("webhooks cannot call the local or private address " + host)
+	}
+	return nil
+}
+
+// PublicAddress reports whether webhooks may connect to an address
+// Loopback, private, link-local (such as 169.254.169.254), shared, multicast and unspecified addresses are refused
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() && !cgnatPrefix.Contains(addr)
+}
+
+// FormatBehaviorContext returns the run instructions section for context added by a behavior script
+// Returns an empty string when the script added none
+func FormatBehaviorContext(context []string) string {
+	if len(context) == 0 {
+		return ""
+	}
+	return "【Behavior Notes】\n" + strings.Join(context, "\n")
+}
+
+func behaviorMessageValue(m BehaviorMessage) script.Value {
+	return script.NewStruct("message", map[string]script.Value{
+		"id":          script.FromGo(m.ID),
+		"content":     script.FromGo(m.Content),
+		"sender_type": script.FromGo(m.SenderType),
+		"sender_name": script.FromGo(m.SenderName),
+		"mentions":    script.FromGo(ParseMentions(m.Content)),
+	})
+}
+
+func behaviorMessagesValue(messages []BehaviorMessage) script.Value {
+	values := make([]script.Value, len(messages))
+	for i, m := range messages {
+		values[i] = behaviorMessageValue(m)
+	}
+	return script.FromGo(values)
+}
@@ -0,0 +1,112 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func runBehavior(t *testing.T, src string, in BehaviorInput) (*BehaviorResult, error) {
+	t.Helper()
+	program, err := CompileBehaviorScript(src)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	return RunBehaviorScript(program, in)
+}
+
+func TestRunBehaviorScript(t *testing.T) {
+	in := BehaviorInput{
+		AvatarName:        "Alice",
+		Message:           BehaviorMessage{ID: 7, SenderType: "user", Content: "@Bob what about the deploy?"},
+		ConversationID:    3,
+		ConversationTitle: "Release",
+		Participants:      []string{"User", "Alice", "Bob"},
+		Recent: []BehaviorMessage{
+			{ID: 6, SenderType: "avatar", SenderName: "Bob", Content: "ready"},
+		},
+	}
+
+	src := `
+if "deploy" in message.content.lower():
+    respond()
+    add_context("The deploy is frozen until Friday.")
+    webhook("https://example.com/hook", {"id": message.id, "mentions": message.mentions})
+else:
+    skip()
+print(avatar.name, conversation.title, len(conversation.messages), conversation.messages[-1].sender_name)
+`
+	result, err := runBehavior(t, src, in)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if result.Decision != BehaviorDecisionRespond {
+		t.Errorf("expected respond, got %q", result.Decision)
+	}
+	if len(result.Context) != 1 || FormatBehaviorContext(result.Context) != "【Behavior Notes】\nThe deploy is frozen until Friday." {
+		t.Errorf("unexpected context: %q", result.Context)
+	}
+	if len(result.Webhooks) != 1 || result.Webhooks[0].URL != "https://example.com/hook" {
+		t.Fatalf("unexpected webhooks: %+v", result.Webhooks)
+	}
+	payload := result.Webhooks[0].Payload.(map[string]any)
+	if payload["id"] != int64(7) || payload["mentions"].([]any)[0] != "Bob" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+	if len(result.Output) != 1 || result.Output[0] != "Alice Release 1 Bob" {
+		t.Errorf("unexpected output: %q", result.Output)
+	}
+}
+
+func TestRunBehaviorScript_NoDecision(t *testing.T) {
+	result, err := runBehavior(t, `x = 1`, BehaviorInput{})
+	if err != nil || result.Decision != "" || FormatBehaviorContext(result.Context) != "" {
+		t.Errorf("expected no decision or context, got %+v (%v)", result, err)
+	}
+}
+
+func TestRunBehaviorScript_Limits(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{`webhook("ftp://example.com")`, "http or https"},
+		{`webhook("/relative")`, "invalid webhook URL"},
+		{"for i in range(4):\n    webhook(\"https://example.com\")", "at most 3 webhooks"},
+		{`add_context("x" * 5000)`, "limited to 4000 characters"},
+		{`add_context(1)`, "expected a string"},
+	}
+
+	for _, tt := range tests {
+		if _, err := runBehavior(t, tt.src, BehaviorInput{}); err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%q: expected error %q, got %v", tt.src, tt.msg, err)
+		}
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed []string
+		ok      bool
+	}{
+		{"https://hooks.example.com/deploy", nil, true},
+		{"https://93.184.216.34/hook", nil, true},
+		{"http://localhost:8080/hook", nil, false},
+		{"http://127.0.0.1/hook", nil, false},
+		{"http://[::1]/hook", nil, false},
+		{"http://10.0.0.5/hook", nil, false},
+		{"http://192.168.1.1/hook", nil, false},
+		{"http://169.254.169.254/latest/meta-data", nil, false},
+		{"http://100.100.100.200/hook", nil, false},
+		{"http://[::ffff:127.0.0.1]/hook", nil, false},
+		{"http://0.0.0.0/hook", nil, false},
+		{"http://10.0.0.5/hook", []string{"10.0.0.5"}, true},
+		{"http://Hooks.Internal/hook", []string{"hooks.internal"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateWebhookURL(tt.url, tt.allowed); (err == nil) != tt.ok {
+			t.Errorf("ValidateWebhookURL(%q, %v) = %v, want ok=%v", tt.url, tt.allowed, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package logic
+
+import (
+	"strings"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// FormatCapabilityInstructions returns the run instructions for an avatar's capability flags
+// Returns an empty string when the avatar has no capabilities enabled
+func FormatCapabilityInstructions(avatar models.Avatar) string {
+	var lines []string
+	if avatar.CanSearch {
+		lines = append(lines, "Use the file search tool to look up facts in the attached files before answering factual questions.")
+	}
+	if avatar.CanCode {
+		lines = append(lines, "Use the code interpreter to run calculations or analyze data instead of estimating results.")
+	}
+	if avatar.CanCite {
+		lines = append(lines, "Cite the source of every factual claim, and say so when you cannot name a source.")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "【Capabilities】\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,22 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestFormatCapabilityInstructions(t *testing.T) {
+	if got := FormatCapabilityInstructions(models.Avatar{}); got != "" {
+		t.Errorf("expected no instructions without capabilities, got %q", got)
+	}
+
+	got := FormatCapabilityInstructions(models.Avatar{CanSearch: true, CanCite: true})
+	if !strings.Contains(got, "file search") || !strings.Contains(got, "Cite") {
+		t.Errorf("expected search and citation instructions, got %q", got)
+	}
+	if strings.Contains(got, "code interpreter") {
+		t.Errorf("expected no code interpreter instructions, got %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// conversationRefRegex matches references such as "conversation #12" or "会話#12"
+var conversationRefRegex = regexp.MustCompile(`(?i)(?:conversation|会話)\s*#(\d+)`)
+
+// ReferencedConversation holds the excerpt of a referenced conversation used as avatar context
+type ReferencedConversation struct {
+	ID       int64
+	Title    string
+	Messages []MessageForFormat
+}
+
+// ParseConversationReferences extracts referenced conversation IDs from message content
+// Returns unique IDs in order of first appearance
+func ParseConversationReferences(content string) []int64 {
+	matches := conversationRefRegex.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, match := range matches {
+		id, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// FormatConversationReferences formats referenced conversations as run instructions
+// Returns an empty string when there is nothing to include
+func FormatConversationReferences(refs []ReferencedConversation) string {
+	var sections []string
+	for _, ref := range refs {
+		history := FormatMessageHistory(ref.Messages, "")
+		if history == "" {
+			history = "(no messages)"
+		}
+		sections = append(sections, fmt.Sprintf("Conversation #%d: %s\n\n%s", ref.ID, ref.Title, history))
+	}
+
+	if len(sections) == 0 {
+		return ""
+	}
+
+	return "【Referenced Conversations】\n" +
+		"The latest message refers to the following other conversations. Use them as background when relevant.\n\n" +
+		strings.Join(sections, "\n\n===\n\n")
+}
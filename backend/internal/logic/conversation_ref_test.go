@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConversationReferences(t *testing.T) {
+	refs := ParseConversationReferences("see conversation #12 and Conversation#3, also 会話#12")
+
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 references, got %v", refs)
+	}
+	if refs[0] != 12 || refs[1] != 3 {
+		t.Errorf("expected [12 3], got %v", refs)
+	}
+}
+
+func TestParseConversationReferences_NoReference(t *testing.T) {
+	refs := ParseConversationReferences("issue #12 is not a conversation")
+
+	if len(refs) != 0 {
+		t.Errorf("expected no references, got %v", refs)
+	}
+}
+
+func TestFormatConversationReferences(t *testing.T) {
+	formatted := FormatConversationReferences([]ReferencedConversation{
+		{
+			ID:    12,
+			Title: "Planning",
+			Messages: []MessageForFormat{
+				{SenderType: SenderTypeUserFormat, Content: "Let's plan"},
+				{SenderType: SenderTypeAvatarFormat, SenderName: "Alice", Content: "Sure"},
+			},
+		},
+	})
+
+	if !strings.Contains(formatted, "【Referenced Conversations】") {
+		t.Errorf("expected header, got %q", formatted)
+	}
+	if !strings.Contains(formatted, "Conversation #12: Planning") {
+		t.Errorf("expected conversation title, got %q", formatted)
+	}
+	if !strings.Contains(formatted, "(Avatar) Alice") {
+		t.Errorf("expected avatar message, got %q", formatted)
+	}
+}
+
+func TestFormatConversationReferences_Empty(t *testing.T) {
+	if got := FormatConversationReferences(nil); got != "" {
+		t.Errorf("expected empty string, got %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+package logic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BuildDigestPrompt builds the summarization prompt for a conversation digest
+func BuildDigestPrompt(title string, messages []MessageForFormat) string {
+	return `You are summarizing a group chat between a user and AI avatars for someone who was away.
+
+【Conversation】
+` + title + `
+
+【Messages】
+` + FormatMessageHistory(messages, "") + `
+
+【Instructions】
+Summarize the messages above in the language of the conversation.
+- Start with a one-sentence overview
+- List the key points, decisions and open questions as short bullet points
+- Mention who said what when it matters
+Keep it under 200 words.`
+}
+
+// FormatDigestMessage formats a digest summary as the content of a system message
+func FormatDigestMessage(summary string, messageCount int) string {
+	return fmt.Sprintf("【Daily Digest】(%d messages)\n%s", messageCount, strings.TrimSpace(summary))
+}
+
+// FormatDigestFallback builds a digest without an LLM by counting messages per participant
+func FormatDigestFallback(messages []MessageForFormat) string {
+	return "No summary available. Activity since the last digest:\n" + formatActivityCounts(messages)
+}
+
+// FormatAwaySummaryMessage formats a summary of the messages posted while the user was away
+func FormatAwaySummaryMessage(summary string, messageCount int) string {
+	return fmt.Sprintf("【While You Were Away】(%d messages)\n%s", messageCount, strings.TrimSpace(summary))
+}
+
+// FormatAwayFallback builds an away summary without an LLM by counting messages per participant
+func FormatAwayFallback(messages []MessageForFormat) string {
+	return "No summary available. Activity while you were away:\n" + formatActivityCounts(messages)
+}
+
+// formatActivityCounts lists the number of messages of each participant, sorted by name
+func formatActivityCounts(messages []MessageForFormat) string {
+	counts := make(map[string]int)
+	for _, msg := range messages {
+		name := msg.SenderName
+		if msg.SenderType == SenderTypeUserFormat {
+			name = "ユーザ"
+		}
+		counts[name]++
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("- %s: %d messages", name, counts[name])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildDigestPrompt(t *testing.T) {
+	prompt := BuildDigestPrompt("Planning", []MessageForFormat{
+		{SenderType: SenderTypeUserFormat, Content: "When do we release?"},
+		{SenderType: SenderTypeAvatarFormat, SenderName: "Alice", Content: "Friday"},
+	})
+
+	if !strings.Contains(prompt, "Planning") {
+		t.Error("prompt should contain conversation title")
+	}
+	if !strings.Contains(prompt, "When do we release?") || !strings.Contains(prompt, "(Avatar) Alice") {
+		t.Error("prompt should contain formatted messages")
+	}
+}
+
+func TestFormatDigestMessage(t *testing.T) {
+	got := FormatDigestMessage("  Summary text\n", 5)
+
+	if got != "【Daily Digest】(5 messages)\nSummary text" {
+		t.Errorf("unexpected digest message: %q", got)
+	}
+}
+
+func TestFormatDigestFallback(t *testing.T) {
+	got := FormatDigestFallback([]MessageForFormat{
+		{SenderType: SenderTypeUserFormat, Content: "a"},
+		{SenderType: SenderTypeAvatarFormat, SenderName: "Alice", Content: "b"},
+		{SenderType: SenderTypeAvatarFormat, SenderName: "Alice", Content: "c"},
+	})
+
+	if !strings.Contains(got, "- Alice: 2 messages") {
+		t.Errorf("expected Alice count, got %q", got)
+	}
+	if !strings.Contains(got, "- ユーザ: 1 messages") {
+		t.Errorf("expected user count, got %q", got)
+	}
+}
+
+func TestFormatAwaySummary(t *testing.T) {
+	if got := FormatAwaySummaryMessage("Alice proposed Friday\n", 3); got != "【While You Were Away】(3 messages)\nAlice proposed Friday" {
+		t.Errorf("unexpected away summary: %q", got)
+	}
+
+	got := FormatAwayFallback([]MessageForFormat{{SenderType: SenderTypeAvatarFormat, SenderName: "Alice", Content: "b"}})
+	if !strings.HasPrefix(got, "No summary available. Activity while you were away:") || !strings.Contains(got, "- Alice: 1 messages") {
+		t.Errorf("unexpected away fallback: %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package logic
+
+import "strings"
+
+// FormatAIDisclosure returns the disclosure banner of a conversation with AI-generated content
+// avatarNames are the avatars that wrote messages and models the models that generated them;
+// the models are left out when none is known
+func FormatAIDisclosure(avatarNames, models []string) string {
+	var b strings.Builder
+	b.WriteString("This conversation contains AI-generated content")
+	if len(avatarNames) > 0 {
+		b.WriteString(". Messages from " + joinNames(avatarNames) + " are written by AI avatars")
+	}
+	if len(models) > 0 {
+		b.WriteString(" (" + strings.Join(models, ", ") + ")")
+	}
+	b.WriteString(". AI responses may be inaccurate and do not come from real people.")
+	return b.String()
+}
+
+// joinNames joins names as "A", "A and B" or "A, B and C"
+func joinNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -0,0 +1,30 @@
+package logic
+
+import "testing"
+
+func TestFormatAIDisclosure(t *testing.T) {
+	tests := []struct {
+		names  []string
+		models []string
+		want   string
+	}{
+		{
+			[]string{"Alice", "Bob", "Carol"}, []string{"gpt-4o"},
+			"This conversation contains AI-generated content. Messages from Alice, Bob and Carol are written by AI avatars (gpt-4o). AI responses may be inaccurate and do not come from real people.",
+		},
+		{
+			[]string{"Alice"}, nil,
+			"This conversation contains AI-generated content. Messages from Alice are written by AI avatars. AI responses may be inaccurate and do not come from real people.",
+		},
+		{
+			nil, []string{"gpt-4o-mini"},
+			"This conversation contains AI-generated content (gpt-4o-mini). AI responses may be inaccurate and do not come from real people.",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := FormatAIDisclosure(tt.names, tt.models); got != tt.want {
+			t.Errorf("FormatAIDisclosure(%v, %v) = %q, want %q", tt.names, tt.models, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package logic
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// MaxFormattingParagraphs is the largest paragraph limit accepted in formatting rules
+const MaxFormattingParagraphs = 20
+
+// paragraphBreakPattern matches the blank lines separating paragraphs
+var paragraphBreakPattern = regexp.MustCompile(`\n[ \t]*\n\s*`)
+
+// bulletMarkerPattern matches list markers other than "- " at the start of a line
+// Each marker is replaced with one of the same length so citation indices stay valid
+var bulletMarkerPattern = regexp.MustCompile(`(?m)^([ \t]*)[*•+] `)
+
+// IsValidFormattingRules checks the limits of formatting rules
+func IsValidFormattingRules(rules models.FormattingRules) bool {
+	return rules.MaxParagraphs >= 0 && rules.MaxParagraphs <= MaxFormattingParagraphs
+}
+
+// FormatNameTag returns the tag that starts the responses of an avatar with the name tag rule
+func FormatNameTag(avatarName string) string {
+	return "[" + avatarName + "] "
+}
+
+// FormatFormattingRulesInstructions returns the run instructions for an avatar's formatting rules
+// Returns an empty string when the avatar has no rules
+func FormatFormattingRulesInstructions(avatarName string, rules models.FormattingRules) string {
+	var lines []string
+	if rules.NameTag {
+		lines = append(lines, fmt.Sprintf("Start your reply with %q.", FormatNameTag(avatarName)))
+	}
+	if rules.MaxParagraphs == 1 {
+		lines = append(lines, "Write a single paragraph.")
+	} else if rules.MaxParagraphs > 1 {
+		lines = append(lines, fmt.Sprintf("Write at most %d paragraphs, separated by blank lines.", rules.MaxParagraphs))
+	}
+	if rules.BulletLists {
+		lines = append(lines, "Present lists and multiple points as bullet lists starting with \"- \".")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "【Formatting】\n" + strings.Join(lines, "\n")
+}
+
+// ApplyFormattingRules repairs a response that does not follow an avatar's formatting rules
+// Paragraphs over the limit are dropped, other list markers become "- " and a missing name tag is added.
+// Also returns the number of characters added in front of the response, to shift citation indices by
+func ApplyFormattingRules(avatarName, content string, rules models.FormattingRules) (string, int) {
+	content = strings.TrimRightFunc(content, unicode.IsSpace)
+
+	if rules.MaxParagraphs > 0 {
+		breaks := paragraphBreakPattern.FindAllStringIndex(content, -1)
+		if len(breaks) >= rules.MaxParagraphs {
+			content = content[:breaks[rules.MaxParagraphs-1][0]]
+		}
+	}
+
+	if rules.BulletLists {
+		content = bulletMarkerPattern.ReplaceAllString(content, "${1}- ")
+	}
+
+	prefix := 0
+	if rules.NameTag {
+		tag := FormatNameTag(avatarName)
+		if !strings.HasPrefix(content, strings.TrimSpace(tag)) {
+			content = tag + content
+			prefix = utf8.RuneCountInString(tag)
+		}
+	}
+
+	return content, prefix
+}
+
+// ShiftCitations moves citations by the characters ApplyFormattingRules added in front of a response
+// and drops those whose marker no longer lies within the content
+func ShiftCitations(citations []models.MessageCitation, prefix int, content string) []models.MessageCitation {
+	length := utf8.RuneCountInString(content)
+	var result []models.MessageCitation
+	for _, c := range citations {
+		c.StartIndex += prefix
+		c.EndIndex += prefix
+		if c.EndIndex > length {
+			continue
+		}
+		result = append(result, c)
+	}
+	return result
+}
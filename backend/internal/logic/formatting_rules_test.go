@@ -0,0 +1,59 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestFormatFormattingRulesInstructions(t *testing.T) {
+	if got := FormatFormattingRulesInstructions("Alice", models.FormattingRules{}); got != "" {
+		t.Errorf("expected no instructions without rules, got %q", got)
+	}
+
+	got := FormatFormattingRulesInstructions("Alice", models.FormattingRules{NameTag: true, MaxParagraphs: 2, BulletLists: true})
+	for _, want := range []string{"【Formatting】", `"[Alice] "`, "at most 2 paragraphs", `"- "`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected instructions to contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestApplyFormattingRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		rules      models.FormattingRules
+		want       string
+		wantPrefix int
+	}{
+		{"no rules", "Hello\n\n", models.FormattingRules{}, "Hello", 0},
+		{"adds name tag", "Hello", models.FormattingRules{NameTag: true}, "[Alice] Hello", 8},
+		{"keeps existing name tag", "[Alice] Hello", models.FormattingRules{NameTag: true}, "[Alice] Hello", 0},
+		{"drops extra paragraphs", "One.\n\nTwo.\n \nThree.", models.FormattingRules{MaxParagraphs: 2}, "One.\n\nTwo.", 0},
+		{"keeps paragraphs within limit", "One.\n\nTwo.", models.FormattingRules{MaxParagraphs: 2}, "One.\n\nTwo.", 0},
+		{"repairs bullets", "Points:\n* one\n  • two\n- three", models.FormattingRules{BulletLists: true}, "Points:\n- one\n  - two\n- three", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, prefix := ApplyFormattingRules("Alice", tt.content, tt.rules)
+			if got != tt.want || prefix != tt.wantPrefix {
+				t.Errorf("expected %q (prefix %d), got %q (prefix %d)", tt.want, tt.wantPrefix, got, prefix)
+			}
+		})
+	}
+}
+
+func TestShiftCitations(t *testing.T) {
+	citations := []models.MessageCitation{
+		{Marker: "[1]", StartIndex: 5, EndIndex: 8},
+		{Marker: "[2]", StartIndex: 20, EndIndex: 23},
+	}
+	content := "[Alice] Text [1] more"
+
+	got := ShiftCitations(citations, 8, content)
+	if len(got) != 1 || got[0].StartIndex != 13 || got[0].EndIndex != 16 {
+		t.Errorf("expected only the first citation shifted by 8, got %+v", got)
+	}
+}
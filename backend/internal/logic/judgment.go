@@ -0,0 +1,102 @@
+package logic
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// JudgmentCandidate is an avatar evaluated in a batched judgment
+type JudgmentCandidate struct {
+	Name   string
+	Prompt string
+}
+
+// BatchJudgmentPrompt builds a single prompt that decides for every candidate avatar
+// whether it should respond to a message
+// The model is asked to answer with a JSON object mapping avatar names to true or false
+func (b InstructionsBuilder) BatchJudgmentPrompt(topic string, participantNames []string, candidates []JudgmentCandidate, messageContent string) string {
+	var sb strings.Builder
+
+	sb.WriteString("You decide which characters in a group chat should respond to a message.\n")
+
+	if topic != "" {
+		sb.WriteString("\n【Topic】\n" + topic + "\n")
+	}
+
+	if len(participantNames) > 0 {
+		sb.WriteString("\n【Participants】\n")
+		for _, name := range participantNames {
+			if name == "ユーザ" || name == "User" {
+				sb.WriteString("- " + name + "\n")
+			} else {
+				sb.WriteString("- (Avatar) " + name + "\n")
+			}
+		}
+	}
+
+	sb.WriteString("\n【Characters】\n")
+	for _, c := range candidates {
+		sb.WriteString("### " + c.Name + "\n" + c.Prompt + "\n\n")
+	}
+
+	sb.WriteString(`【Task】
+Read the following message and determine, for each character, whether it should respond.
+
+Criteria:
+- Is the content related to the character's specialty or role?
+- Is the character being directly addressed?
+- Can the character provide useful information?
+- Should the character speak based on the conversation flow?
+
+【Message】
+` + messageContent + `
+
+【Answer】
+Answer only with a JSON object that has one key per character name and true or false as the value.
+Example: {"` + candidateExampleName(candidates) + `": true}`)
+
+	return b.WithSafetyPreamble(sb.String())
+}
+
+// candidateExampleName returns the name used in the answer example
+func candidateExampleName(candidates []JudgmentCandidate) string {
+	if len(candidates) == 0 {
+		return "Name"
+	}
+	return candidates[0].Name
+}
+
+// ParseBatchJudgment parses the answer to a batched judgment prompt
+// Returns the decision for each candidate name found in the answer (matched case-insensitively)
+// Values may be booleans or "yes"/"no" strings; the answer may be wrapped in a code fence
+func ParseBatchJudgment(response string, names []string) (map[string]bool, bool) {
+	body := strings.TrimSpace(response)
+	if start := strings.Index(body, "{"); start >= 0 {
+		if end := strings.LastIndex(body, "}"); end > start {
+			body = body[start : end+1]
+		}
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return nil, false
+	}
+
+	decisions := make(map[string]bool)
+	for _, name := range names {
+		for key, value := range raw {
+			if !strings.EqualFold(strings.TrimSpace(key), name) {
+				continue
+			}
+			switch v := value.(type) {
+			case bool:
+				decisions[name] = v
+			case string:
+				decisions[name] = strings.EqualFold(strings.TrimSpace(v), "yes") ||
+					strings.EqualFold(strings.TrimSpace(v), "true")
+			}
+		}
+	}
+
+	return decisions, true
+}
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBatchJudgmentPrompt(t *testing.T) {
+	candidates := []JudgmentCandidate{
+		{Name: "Alice", Prompt: "A chef"},
+		{Name: "Bob", Prompt: "A pilot"},
+	}
+	prompt := InstructionsBuilder{}.BatchJudgmentPrompt("Dinner", []string{"ユーザ", "Alice", "Bob"}, candidates, "What should we cook?")
+
+	for _, want := range []string{"【Topic】\nDinner", "- (Avatar) Alice", "### Alice\nA chef", "### Bob\nA pilot", "What should we cook?", "JSON object"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected prompt to contain %q", want)
+		}
+	}
+}
+
+func TestParseBatchJudgment(t *testing.T) {
+	names := []string{"Alice", "Bob", "Carol"}
+
+	tests := []struct {
+		name     string
+		response string
+		ok       bool
+		expected map[string]bool
+	}{
+		{"plain json", `{"Alice": true, "Bob": false}`, true, map[string]bool{"Alice": true, "Bob": false}},
+		{"code fence", "```json\n{\"alice\": true}\n```", true, map[string]bool{"Alice": true}},
+		{"string values", `{"Alice": "yes", "Bob": "no", "Carol": "true"}`, true, map[string]bool{"Alice": true, "Bob": false, "Carol": true}},
+		{"unknown names ignored", `{"Dave": true}`, true, map[string]bool{}},
+		{"not json", "yes", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions, ok := ParseBatchJudgment(tt.response, names)
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if len(decisions) != len(tt.expected) {
+				t.Fatalf("expected %d decisions, got %v", len(tt.expected), decisions)
+			}
+			for name, want := range tt.expected {
+				if got, found := decisions[name]; !found || got != want {
+					t.Errorf("decision for %s: expected %v, got %v (found=%v)", name, want, got, found)
+				}
+			}
+		})
+	}
+}
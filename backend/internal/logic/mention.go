@@ -1,18 +1,96 @@
 package logic
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"sync/atomic"
 )
 
-// mentionRegex matches @username patterns with Unicode support
-// First character must be a letter (any language), followed by letters, numbers, or underscores
-var mentionRegex = regexp.MustCompile(`@(\p{L}[\p{L}\p{N}_]*)`)
+const (
+	// DefaultMentionStartChars is the character class a mention name starts with (any letter)
+	DefaultMentionStartChars = `\p{L}`
+	// DefaultMentionChars is the character class of the rest of a mention name (letters, numbers, underscores)
+	DefaultMentionChars = `\p{L}\p{N}_`
+)
+
+// mentionSyntax holds the compiled patterns for the configured mention character classes
+type mentionSyntax struct {
+	// mention matches @name, or @"any name" for names the character classes cannot capture
+	mention *regexp.Regexp
+	// mentionableName matches names that can be fully captured by an unquoted mention
+	mentionableName *regexp.Regexp
+}
+
+var currentMentionSyntax atomic.Pointer[mentionSyntax]
+
+func init() {
+	syntax, err := compileMentionSyntax(DefaultMentionStartChars, DefaultMentionChars)
+	if err != nil {
+		panic(err)
+	}
+	currentMentionSyntax.Store(syntax)
+}
+
+// compileMentionSyntax builds the mention patterns from the contents of two regexp character classes
+func compileMentionSyntax(startChars, chars string) (*mentionSyntax, error) {
+	if startChars == "" || chars == "" {
+		return nil, fmt.Errorf("mention character classes must not be empty")
+	}
+	name := "[" + startChars + "][" + chars + "]*"
+
+	mention, err := regexp.Compile(`@(?:"([^"\r\n]+)"|(` + name + `))`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mention character classes: %w", err)
+	}
+	return &mentionSyntax{
+		mention:         mention,
+		mentionableName: regexp.MustCompile("^" + name + "$"),
+	}, nil
+}
+
+// ConfigureMentions sets the characters allowed in unquoted @mentions
+// startChars and chars are the contents of regexp character classes, e.g. `\p{L}` and `\p{L}\p{N}_-`
+func ConfigureMentions(startChars, chars string) error {
+	syntax, err := compileMentionSyntax(startChars, chars)
+	if err != nil {
+		return err
+	}
+	currentMentionSyntax.Store(syntax)
+	return nil
+}
+
+// UserMentionNames are the names avatars use to address the user with an @mention
+var UserMentionNames = []string{"ユーザ", "user"}
+
+// MentionsUser reports whether content contains an @mention of the user
+func MentionsUser(content string) bool {
+	return len(MatchAvatarNames(ParseMentions(content), UserMentionNames)) > 0
+}
+
+// IsMentionableName reports whether a name can be addressed with an unquoted @mention
+func IsMentionableName(name string) bool {
+	return currentMentionSyntax.Load().mentionableName.MatchString(name)
+}
+
+// FormatMention returns the @mention of a name, quoted when it cannot be mentioned unquoted
+func FormatMention(name string) string {
+	if IsMentionableName(name) {
+		return "@" + name
+	}
+	return `@"` + name + `"`
+}
+
+// FormatRenameNotice returns the system message announcing that an avatar was renamed
+func FormatRenameNotice(oldName, newName string) string {
+	return fmt.Sprintf("%s is now called %s. Mention them as %s.", oldName, newName, FormatMention(newName))
+}
 
 // ParseMentions extracts mention names from a message content
-// Returns a unique list of mentioned names (without @ prefix)
+// Names containing spaces or other characters can be quoted, e.g. @"Dr. Smith"
+// Returns a unique list of mentioned names (without @ prefix and quotes)
 func ParseMentions(content string) []string {
-	matches := mentionRegex.FindAllStringSubmatch(content, -1)
+	matches := currentMentionSyntax.Load().mention.FindAllStringSubmatch(content, -1)
 	if len(matches) == 0 {
 		return []string{}
 	}
@@ -22,12 +100,13 @@ func ParseMentions(content string) []string {
 	var mentions []string
 
 	for _, match := range matches {
-		if len(match) > 1 {
-			name := match[1]
-			if !seen[name] {
-				seen[name] = true
-				mentions = append(mentions, name)
-			}
+		name := strings.TrimSpace(match[1])
+		if name == "" {
+			name = match[2]
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			mentions = append(mentions, name)
 		}
 	}
 
@@ -36,7 +115,7 @@ func ParseMentions(content string) []string {
 
 // RemoveMentions removes all @mentions from the content
 func RemoveMentions(content string) string {
-	result := mentionRegex.ReplaceAllString(content, "")
+	result := currentMentionSyntax.Load().mention.ReplaceAllString(content, "")
 	// Clean up extra whitespace
 	result = strings.TrimSpace(result)
 	// Replace multiple spaces with single space
@@ -45,18 +124,18 @@ func RemoveMentions(content string) string {
 	return result
 }
 
-// MatchAvatarNames matches mention names against available avatar names (case-insensitive)
+// MatchAvatarNames matches mention names against available avatar names (case- and width-insensitive)
 // Returns the actual avatar names that were matched
 func MatchAvatarNames(mentions []string, avatarNames []string) []string {
-	// Create lowercase lookup map
+	// Create normalized lookup map
 	nameMap := make(map[string]string)
 	for _, name := range avatarNames {
-		nameMap[strings.ToLower(name)] = name
+		nameMap[NormalizeAvatarName(name)] = name
 	}
 
 	var matched []string
 	for _, mention := range mentions {
-		if actualName, ok := nameMap[strings.ToLower(mention)]; ok {
+		if actualName, ok := nameMap[NormalizeAvatarName(mention)]; ok {
 			matched = append(matched, actualName)
 		}
 	}
@@ -1,6 +1,7 @@
 package logic
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -138,6 +139,17 @@ func TestMatchAvatarNames_CaseInsensitive(t *testing.T) {
 	}
 }
 
+func TestMatchAvatarNames_WidthInsensitive(t *testing.T) {
+	mentions := []string{"ａｖａｔａｒ１", "ｱﾘｽ"}
+	avatarNames := []string{"Avatar1", "アリス"}
+
+	matched := MatchAvatarNames(mentions, avatarNames)
+
+	if len(matched) != 2 || matched[0] != "Avatar1" || matched[1] != "アリス" {
+		t.Errorf("expected [Avatar1 アリス], got %v", matched)
+	}
+}
+
 func TestMatchAvatarNames_NoMatch(t *testing.T) {
 	mentions := []string{"Unknown"}
 	avatarNames := []string{"Avatar1", "Avatar2"}
@@ -287,3 +299,105 @@ func TestExtractMentionedAvatars_Japanese(t *testing.T) {
 	}
 }
 
+
+func TestIsMentionableName(t *testing.T) {
+	valid := []string{"Alice", "太郎", "team_1"}
+	invalid := []string{"", "Support Team", "@alice", "1st", "a-b"}
+
+	for _, name := range valid {
+		if !IsMentionableName(name) {
+			t.Errorf("expected %q to be mentionable", name)
+		}
+	}
+	for _, name := range invalid {
+		if IsMentionableName(name) {
+			t.Errorf("expected %q not to be mentionable", name)
+		}
+	}
+}
+
+func TestMentionsUser(t *testing.T) {
+	if !MentionsUser("@ユーザ どう思いますか？") {
+		t.Error("expected @ユーザ to mention the user")
+	}
+	if !MentionsUser("what do you think, @User?") {
+		t.Error("expected @User to mention the user")
+	}
+	if MentionsUser("@Alice what do you think?") {
+		t.Error("expected avatar mention not to mention the user")
+	}
+}
+
+func TestParseMentions_QuotedName(t *testing.T) {
+	content := `@"Dr. Smith" and @Bob, what about @" 山田 太郎 " and @"Dr. Smith"?`
+	mentions := ParseMentions(content)
+
+	expected := []string{"Dr. Smith", "Bob", "山田 太郎"}
+	if len(mentions) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, mentions)
+	}
+	for i, name := range expected {
+		if mentions[i] != name {
+			t.Errorf("expected %q, got %q", name, mentions[i])
+		}
+	}
+}
+
+func TestParseMentions_UnterminatedOrEmptyQuote(t *testing.T) {
+	if mentions := ParseMentions(`@"Dr. Smith hello`); len(mentions) != 0 {
+		t.Errorf("expected no mentions for an unterminated quote, got %v", mentions)
+	}
+	if mentions := ParseMentions(`@"  " hello`); len(mentions) != 0 {
+		t.Errorf("expected no mentions for an empty quote, got %v", mentions)
+	}
+}
+
+func TestRemoveMentions_QuotedName(t *testing.T) {
+	result := RemoveMentions(`@"Dr. Smith" @Bob 診断をお願いします`)
+	if result != "診断をお願いします" {
+		t.Errorf("expected '診断をお願いします', got '%s'", result)
+	}
+}
+
+func TestExtractMentionedAvatars_QuotedName(t *testing.T) {
+	matched := ExtractMentionedAvatars(`@"dr. smith" please review`, []string{"Dr. Smith", "Bob"})
+	if len(matched) != 1 || matched[0] != "Dr. Smith" {
+		t.Errorf("expected [Dr. Smith], got %v", matched)
+	}
+}
+
+func TestConfigureMentions(t *testing.T) {
+	defer ConfigureMentions(DefaultMentionStartChars, DefaultMentionChars)
+
+	if err := ConfigureMentions(`\p{L}`, `\p{L}\p{N}_.-`); err != nil {
+		t.Fatalf("failed to configure mentions: %v", err)
+	}
+	mentions := ParseMentions("@jean-luc.picard engage")
+	if len(mentions) != 1 || mentions[0] != "jean-luc.picard" {
+		t.Errorf("expected [jean-luc.picard], got %v", mentions)
+	}
+	if !IsMentionableName("a-b") {
+		t.Error("expected 'a-b' to be mentionable with the configured characters")
+	}
+	if mentions := ParseMentions(`@"Dr. Smith"`); len(mentions) != 1 || mentions[0] != "Dr. Smith" {
+		t.Errorf("expected quoted mentions to keep working, got %v", mentions)
+	}
+
+	for _, classes := range [][2]string{{"", `\p{L}`}, {`\p{Foo}`, `\p{L}`}} {
+		if err := ConfigureMentions(classes[0], classes[1]); err == nil {
+			t.Errorf("expected an error for character classes %q", classes)
+		}
+	}
+	if mentions := ParseMentions("@jean-luc"); len(mentions) != 1 || mentions[0] != "jean-luc" {
+		t.Errorf("expected an invalid configuration to keep the previous one, got %v", mentions)
+	}
+}
+
+func TestFormatRenameNotice(t *testing.T) {
+	if got := FormatRenameNotice("Alice", "Alicia"); got != "Alice is now called Alicia. Mention them as @Alicia." {
+		t.Errorf("unexpected notice %q", got)
+	}
+	if got := FormatRenameNotice("Alice", "Dr. Alice"); !strings.Contains(got, `@"Dr. Alice"`) {
+		t.Errorf("expected a quoted mention for a name with spaces, got %q", got)
+	}
+}
@@ -13,6 +13,9 @@ const (
 	SenderTypeAvatarFormat SenderTypeFormat = "avatar"
 )
 
+// UserPriorityInstruction is prepended to the prompt in the instructions of every avatar assistant
+const UserPriorityInstruction = "【重要】`Name: ユーザ` となっているメッセージがユーザの意見です。あなたはこれを最重視して発言をする必要があります。ユーザの意見を尊重し、それに基づいて応答してください。\n\n"
+
 // MessageForFormat represents a message structure for formatting
 type MessageForFormat struct {
 	SenderType SenderTypeFormat
@@ -62,3 +65,19 @@ func FormatMessageHistory(messages []MessageForFormat, currentAvatarName string)
 
 	return strings.Join(formatted, "\n\n---\n\n")
 }
+
+// FormatImportedHistory formats the history of an imported thread as a single seed message
+// The oldest messages are dropped until the result fits within maxLength bytes
+// Returns an empty string when there are no messages
+func FormatImportedHistory(messages []MessageForFormat, maxLength int) string {
+	const header = "【Imported History】\nThe conversation so far, imported from an earlier thread:\n\n"
+
+	for len(messages) > 0 {
+		formatted := header + FormatMessageHistory(messages, "")
+		if len(formatted) <= maxLength {
+			return formatted
+		}
+		messages = messages[1:]
+	}
+	return ""
+}
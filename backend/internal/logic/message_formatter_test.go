@@ -1,6 +1,7 @@
 package logic
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -125,3 +126,26 @@ func TestFormatMessageHistory(t *testing.T) {
 	}
 }
 
+func TestFormatImportedHistory(t *testing.T) {
+	messages := []MessageForFormat{
+		{SenderType: SenderTypeUserFormat, Content: "古い質問"},
+		{SenderType: SenderTypeAvatarFormat, SenderName: "Bot", Content: "回答"},
+	}
+
+	result := FormatImportedHistory(messages, 10000)
+	want := "【Imported History】\nThe conversation so far, imported from an earlier thread:\n\n" +
+		"Name: ユーザ\nMessage:\n古い質問\n\n---\n\nName: (Avatar) Bot\nMessage:\n回答"
+	if result != want {
+		t.Errorf("FormatImportedHistory() = %q, want %q", result, want)
+	}
+
+	// The oldest messages are dropped to fit
+	limited := FormatImportedHistory(messages, len(want)-1)
+	if strings.Contains(limited, "古い質問") || !strings.Contains(limited, "回答") {
+		t.Errorf("expected only the newest message, got %q", limited)
+	}
+
+	if result := FormatImportedHistory(nil, 10000); result != "" {
+		t.Errorf("expected empty history, got %q", result)
+	}
+}
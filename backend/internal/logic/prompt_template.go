@@ -0,0 +1,113 @@
+package logic
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Prompt variables that can appear in avatar prompts as {{name}}
+const (
+	PromptVarConversationTitle = "conversation_title"
+	PromptVarToday             = "today"
+	PromptVarParticipants      = "participants"
+)
+
+// KnownPromptVariables lists the variables supported in avatar prompts
+var KnownPromptVariables = []string{PromptVarConversationTitle, PromptVarToday, PromptVarParticipants}
+
+// promptVariablePattern matches {{name}} with optional spaces around the name
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// PromptVariables holds the values substituted into an avatar prompt
+// Empty values fall back to a neutral description so the prompt stays readable
+type PromptVariables struct {
+	ConversationTitle string
+	Today             time.Time
+	Participants      []string
+}
+
+// UnknownPromptVariables returns the variables in a prompt that are not supported, in order of appearance
+func UnknownPromptVariables(prompt string) []string {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, match := range promptVariablePattern.FindAllStringSubmatch(prompt, -1) {
+		name := match[1]
+		if isKnownPromptVariable(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unknown = append(unknown, name)
+	}
+	return unknown
+}
+
+// HasPromptVariables reports whether a prompt contains any variables
+func HasPromptVariables(prompt string) bool {
+	return promptVariablePattern.MatchString(prompt)
+}
+
+// AssistantInstructions builds the instructions of an avatar's assistant from its prompt
+// Every assistant create and update goes through here so the safety preamble and the user priority
+// instruction are never dropped. Variables are resolved per run, so the assistant gets neutral placeholders
+func (b InstructionsBuilder) AssistantInstructions(prompt string) string {
+	return b.WithSafetyPreamble(UserPriorityInstruction + RenderPrompt(prompt, PromptVariables{}))
+}
+
+// PromptFromInstructions returns the prompt part of assistant instructions built by AssistantInstructions
+// Everything up to the user priority instruction is dropped, so a preamble that has changed since is removed too.
+// Instructions written elsewhere are returned unchanged
+func PromptFromInstructions(instructions string) string {
+	if _, prompt, ok := strings.Cut(instructions, UserPriorityInstruction); ok {
+		return prompt
+	}
+	return instructions
+}
+
+// RenderPrompt substitutes the variables in an avatar prompt
+// Unknown variables are left as written
+func RenderPrompt(prompt string, vars PromptVariables) string {
+	return promptVariablePattern.ReplaceAllStringFunc(prompt, func(match string) string {
+		name := promptVariablePattern.FindStringSubmatch(match)[1]
+		switch name {
+		case PromptVarConversationTitle:
+			if vars.ConversationTitle != "" {
+				return vars.ConversationTitle
+			}
+			return "the current conversation"
+		case PromptVarToday:
+			if !vars.Today.IsZero() {
+				return vars.Today.Format("2006-01-02")
+			}
+			return "today"
+		case PromptVarParticipants:
+			if len(vars.Participants) > 0 {
+				return strings.Join(vars.Participants, ", ")
+			}
+			return "the conversation participants"
+		}
+		return match
+	})
+}
+
+// FormatPromptVariablesInstructions returns the run instructions restating an avatar prompt with its
+// variables resolved for the current conversation
+// Returns an empty string when the prompt has no variables, since the assistant already has it as written
+func FormatPromptVariablesInstructions(prompt string, vars PromptVariables) string {
+	if !HasPromptVariables(prompt) {
+		return ""
+	}
+	return "【Your Settings】\n" +
+		"Your settings with the details of this conversation filled in:\n" +
+		RenderPrompt(prompt, vars)
+}
+
+// isKnownPromptVariable reports whether a variable name is supported
+func isKnownPromptVariable(name string) bool {
+	for _, known := range KnownPromptVariables {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnknownPromptVariables(t *testing.T) {
+	got := UnknownPromptVariables("Topic: {{ conversation_title }} on {{today}} with {{user}}, {{mood}} and {{user}}")
+	if want := []string{"user", "mood"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := UnknownPromptVariables("You are helpful"); got != nil {
+		t.Errorf("expected no unknown variables, got %v", got)
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	vars := PromptVariables{
+		ConversationTitle: "Travel plans",
+		Today:             time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC),
+		Participants:      []string{"ユーザ", "Alice"},
+	}
+	got := RenderPrompt("{{conversation_title}} / {{ today }} / {{participants}} / {{other}}", vars)
+	if want := "Travel plans / 2026-03-04 / ユーザ, Alice / {{other}}"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if got := RenderPrompt("Discuss {{conversation_title}}", PromptVariables{}); got != "Discuss the current conversation" {
+		t.Errorf("expected a neutral placeholder, got %q", got)
+	}
+}
+
+func TestFormatPromptVariablesInstructions(t *testing.T) {
+	if got := FormatPromptVariablesInstructions("You are helpful", PromptVariables{}); got != "" {
+		t.Errorf("expected no instructions for a prompt without variables, got %q", got)
+	}
+
+	got := FormatPromptVariablesInstructions("Guide for {{conversation_title}}", PromptVariables{ConversationTitle: "Kyoto"})
+	if !strings.HasPrefix(got, "【Your Settings】") || !strings.HasSuffix(got, "Guide for Kyoto") {
+		t.Errorf("unexpected instructions: %q", got)
+	}
+}
+
+func TestAssistantInstructions(t *testing.T) {
+	instructions := InstructionsBuilder{}.AssistantInstructions("Talk about {{conversation_title}}")
+	if !strings.HasPrefix(instructions, UserPriorityInstruction) {
+		t.Errorf("expected the user priority instruction first, got %q", instructions)
+	}
+	if !strings.HasSuffix(instructions, "Talk about the current conversation") {
+		t.Errorf("expected variables rendered with neutral placeholders, got %q", instructions)
+	}
+
+	if got := PromptFromInstructions(InstructionsBuilder{}.AssistantInstructions("Be kind")); got != "Be kind" {
+		t.Errorf("expected the prompt back, got %q", got)
+	}
+	if got := PromptFromInstructions("Written elsewhere"); got != "Written elsewhere" {
+		t.Errorf("expected other instructions unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,8 @@
+package logic
+
+import "strings"
+
+// IsQuestion reports whether a message asks a question (contains "?" or "？")
+func IsQuestion(content string) bool {
+	return strings.ContainsAny(content, "?？")
+}
@@ -0,0 +1,21 @@
+package logic
+
+import "testing"
+
+func TestIsQuestion(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected bool
+	}{
+		{"What do you think?", true},
+		{"どう思いますか？", true},
+		{"Thanks, that helps.", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsQuestion(tt.content); got != tt.expected {
+			t.Errorf("IsQuestion(%q) = %v, want %v", tt.content, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package logic
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactionPolicy controls how user messages of a conversation are redacted before they are stored
+type RedactionPolicy string
+
+const (
+	// RedactionPolicyOff stores user messages unchanged
+	RedactionPolicyOff RedactionPolicy = "off"
+	// RedactionPolicyRegex redacts emails, phone numbers and configured terms
+	RedactionPolicyRegex RedactionPolicy = "regex"
+	// RedactionPolicyLLM also asks an LLM for personal information the patterns cannot find
+	RedactionPolicyLLM RedactionPolicy = "llm"
+)
+
+// ParseRedactionPolicy validates a redaction policy string
+// An empty string is treated as RedactionPolicyOff
+func ParseRedactionPolicy(value string) (RedactionPolicy, bool) {
+	switch RedactionPolicy(value) {
+	case "", RedactionPolicyOff:
+		return RedactionPolicyOff, true
+	case RedactionPolicyRegex, RedactionPolicyLLM:
+		return RedactionPolicy(value), true
+	}
+	return "", false
+}
+
+// Redaction kinds, also used in the placeholders that replace redacted text
+const (
+	RedactionKindEmail = "email"
+	RedactionKindPhone = "phone"
+	RedactionKindTerm  = "term"
+	RedactionKindPII   = "pii"
+)
+
+// RedactionPlaceholder returns the text that replaces a redacted value of the given kind
+func RedactionPlaceholder(kind string) string {
+	return "[REDACTED " + strings.ToUpper(kind) + "]"
+}
+
+// emailRegex matches email addresses
+var emailRegex = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+// phoneRegex matches phone number candidates such as 03-1234-5678, +81 90 1234 5678 or (555) 123-4567
+// Candidates are checked with isPhoneNumber
+var phoneRegex = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)|\d{1,4})[ .-]?\d{1,4}[ .-]?\d{3,4}`)
+
+const (
+	minPhoneDigits = 10
+	maxPhoneDigits = 15
+)
+
+// Redactor redacts personal information and configured terms from text
+type Redactor struct {
+	terms *regexp.Regexp
+}
+
+// NewRedactor creates a redactor for the given terms
+// Terms are matched case-insensitively anywhere in the text; empty terms are ignored
+func NewRedactor(terms []string) *Redactor {
+	var quoted []string
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+	}
+	if len(quoted) == 0 {
+		return &Redactor{}
+	}
+
+	// Longer terms first so a term containing another is redacted whole
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return &Redactor{terms: regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)}
+}
+
+// RedactPatterns replaces emails, phone numbers and configured terms with placeholders
+// Returns the redacted content and the number of redactions per kind
+func (r *Redactor) RedactPatterns(content string) (string, map[string]int) {
+	counts := make(map[string]int)
+
+	content = replaceCounted(emailRegex, content, RedactionKindEmail, counts, nil)
+	content = replaceCounted(phoneRegex, content, RedactionKindPhone, counts, isPhoneNumber)
+	if r.terms != nil {
+		content = replaceCounted(r.terms, content, RedactionKindTerm, counts, nil)
+	}
+
+	return content, counts
+}
+
+// replaceCounted replaces the matches of re accepted by valid with the placeholder of kind
+func replaceCounted(re *regexp.Regexp, content, kind string, counts map[string]int, valid func(content string, start, end int) bool) string {
+	matches := re.FindAllStringIndex(content, -1)
+	if len(matches) == 0 {
+		return content
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if valid != nil && !valid(content, m[0], m[1]) {
+			continue
+		}
+		b.WriteString(content[last:m[0]])
+		b.WriteString(RedactionPlaceholder(kind))
+		last = m[1]
+		counts[kind]++
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// isPhoneNumber reports whether a phone candidate is a whole number with a plausible digit count
+// Candidates that are part of a longer alphanumeric code are rejected; Japanese text may touch the number
+func isPhoneNumber(content string, start, end int) bool {
+	if start > 0 && isASCIIAlphanumeric(content[start-1]) {
+		return false
+	}
+	if end < len(content) && isASCIIAlphanumeric(content[end]) {
+		return false
+	}
+
+	digits := 0
+	for _, r := range content[start:end] {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= minPhoneDigits && digits <= maxPhoneDigits
+}
+
+// isASCIIAlphanumeric reports whether a byte is an ASCII letter or digit
+func isASCIIAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// RedactValues replaces every occurrence of the given values with the placeholder of kind
+// Returns the redacted content and the number of replacements
+func RedactValues(content string, values []string, kind string) (string, int) {
+	values = append([]string(nil), values...)
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	count := 0
+	placeholder := RedactionPlaceholder(kind)
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value == "" || strings.Contains(placeholder, value) {
+			continue
+		}
+		count += strings.Count(content, value)
+		content = strings.ReplaceAll(content, value, placeholder)
+	}
+	return content, count
+}
+
+// BuildPIIDetectionPrompt builds the prompt asking an LLM for the personal information in a message
+// The message should already be pattern-redacted so emails and phone numbers are not sent
+func BuildPIIDetectionPrompt(content string) string {
+	return `Find personal information in the message below: names of private people, postal addresses, ` +
+		`ID, account or card numbers, and dates of birth. Ignore the names of AI avatars mentioned with @, ` +
+		`public figures, companies and placeholders like [REDACTED EMAIL].
+
+【Message】
+` + content + `
+
+【Instructions】
+Answer only with a JSON array of the exact substrings to redact, copied verbatim from the message.
+Answer [] if there are none.`
+}
+
+// ParsePIIDetection parses the answer to a PII detection prompt
+// Accepts a JSON array of strings, optionally wrapped in a code block
+func ParsePIIDetection(response string) ([]string, bool) {
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+
+	var values []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &values); err != nil {
+		return nil, false
+	}
+	return values, true
+}
@@ -0,0 +1,79 @@
+package logic
+
+import "testing"
+
+func TestParseRedactionPolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected RedactionPolicy
+		ok       bool
+	}{
+		{"", RedactionPolicyOff, true},
+		{"off", RedactionPolicyOff, true},
+		{"regex", RedactionPolicyRegex, true},
+		{"llm", RedactionPolicyLLM, true},
+		{"strict", "", false},
+	}
+
+	for _, tt := range tests {
+		policy, ok := ParseRedactionPolicy(tt.input)
+		if policy != tt.expected || ok != tt.ok {
+			t.Errorf("ParseRedactionPolicy(%q) = %q, %v; want %q, %v", tt.input, policy, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestRedactor_RedactPatterns(t *testing.T) {
+	redactor := NewRedactor([]string{"Project Falcon", "falcon", " "})
+
+	content := "@Alice mail taro.yamada@example.co.jp or call 090-1234-5678 / +1 (555) 123-4567 about project falcon and Falcon."
+	redacted, counts := redactor.RedactPatterns(content)
+
+	expected := "@Alice mail [REDACTED EMAIL] or call [REDACTED PHONE] / [REDACTED PHONE] about [REDACTED TERM] and [REDACTED TERM]."
+	if redacted != expected {
+		t.Errorf("unexpected redaction:\n got  %q\n want %q", redacted, expected)
+	}
+	if counts[RedactionKindEmail] != 1 || counts[RedactionKindPhone] != 2 || counts[RedactionKindTerm] != 2 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+func TestRedactor_RedactPatterns_KeepsOtherNumbers(t *testing.T) {
+	redactor := NewRedactor(nil)
+
+	content := "会議は2024-10-16の15:30から、予算は1,000,000円、注文番号はA123456789012です"
+	redacted, counts := redactor.RedactPatterns(content)
+	if redacted != content || len(counts) != 0 {
+		t.Errorf("expected content to be unchanged, got %q counts=%v", redacted, counts)
+	}
+
+	redacted, counts = redactor.RedactPatterns("電話は03-1234-5678まで")
+	if redacted != "電話は[REDACTED PHONE]まで" || counts[RedactionKindPhone] != 1 {
+		t.Errorf("expected the phone number next to Japanese text to be redacted, got %q counts=%v", redacted, counts)
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	content := "山田太郎さんは東京都港区1-2-3に住んでいます。山田太郎さんに連絡して"
+	redacted, count := RedactValues(content, []string{"山田太郎", "東京都港区1-2-3", "", "PII"}, RedactionKindPII)
+
+	expected := "[REDACTED PII]さんは[REDACTED PII]に住んでいます。[REDACTED PII]さんに連絡して"
+	if redacted != expected || count != 3 {
+		t.Errorf("unexpected redaction %q count=%d", redacted, count)
+	}
+}
+
+func TestParsePIIDetection(t *testing.T) {
+	values, ok := ParsePIIDetection("```json\n[\"Taro Yamada\", \"1-2-3 Minato\"]\n```")
+	if !ok || len(values) != 2 || values[0] != "Taro Yamada" {
+		t.Errorf("unexpected values %v ok=%v", values, ok)
+	}
+
+	if values, ok := ParsePIIDetection("[]"); !ok || len(values) != 0 {
+		t.Errorf("expected no values, got %v ok=%v", values, ok)
+	}
+
+	if _, ok := ParsePIIDetection("There is no personal information."); ok {
+		t.Error("expected prose to be rejected")
+	}
+}
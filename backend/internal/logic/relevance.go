@@ -0,0 +1,105 @@
+package logic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxRelevanceScore is the score given to a message that contains one of the avatar's keywords
+const MaxRelevanceScore = 1.0
+
+// RelevanceScore estimates how relevant a message is to an avatar without calling an LLM
+// A keyword match scores MaxRelevanceScore; otherwise the score is the fraction of message
+// terms that also appear in the avatar prompt (0 to 1)
+// Japanese and Chinese text is compared by character bigrams, other text by words
+func RelevanceScore(content string, keywords []string, prompt string) float64 {
+	lower := strings.ToLower(content)
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(lower, keyword) {
+			return MaxRelevanceScore
+		}
+	}
+
+	contentTerms := relevanceTerms(content)
+	if len(contentTerms) == 0 {
+		return 0
+	}
+
+	promptTerms := make(map[string]bool)
+	for _, term := range relevanceTerms(prompt) {
+		promptTerms[term] = true
+	}
+
+	matched := 0
+	for _, term := range contentTerms {
+		if promptTerms[term] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(contentTerms))
+}
+
+// PassesPrefilter reports whether a message is relevant enough to ask the LLM
+// A threshold of 0 or less disables the pre-filter
+func PassesPrefilter(content string, keywords []string, prompt string, threshold float64) bool {
+	if threshold <= 0 {
+		return true
+	}
+	return RelevanceScore(content, keywords, prompt) >= threshold
+}
+
+// relevanceTerms splits text into unique lowercase terms
+// Runs of CJK characters become bigrams; other words shorter than 2 characters are ignored
+func relevanceTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	var word []rune
+	var cjk []rune
+	flush := func() {
+		if len(word) >= 2 {
+			add(string(word))
+		}
+		word = word[:0]
+
+		if len(cjk) == 1 {
+			add(string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(cjk) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return terms
+}
+
+// isCJK reports whether a rune is a Han, Hiragana or Katakana character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
@@ -0,0 +1,61 @@
+package logic
+
+import "testing"
+
+func TestRelevanceScore_KeywordMatch(t *testing.T) {
+	score := RelevanceScore("Any good PASTA recipes?", []string{"pasta", "pizza"}, "")
+	if score != MaxRelevanceScore {
+		t.Errorf("expected keyword match to score %v, got %v", MaxRelevanceScore, score)
+	}
+}
+
+func TestRelevanceScore_PromptOverlap(t *testing.T) {
+	prompt := "You are a chef who loves cooking italian food"
+
+	related := RelevanceScore("cooking italian food", nil, prompt)
+	unrelated := RelevanceScore("airplane engine maintenance", nil, prompt)
+
+	if related != 1 {
+		t.Errorf("expected full overlap, got %v", related)
+	}
+	if unrelated != 0 {
+		t.Errorf("expected no overlap, got %v", unrelated)
+	}
+}
+
+func TestRelevanceScore_Japanese(t *testing.T) {
+	prompt := "あなたは料理が得意なシェフです"
+
+	related := RelevanceScore("料理のコツを教えて", nil, prompt)
+	unrelated := RelevanceScore("飛行機の整備", nil, prompt)
+
+	if related <= unrelated {
+		t.Errorf("expected related message to score higher: related=%v unrelated=%v", related, unrelated)
+	}
+	if unrelated != 0 {
+		t.Errorf("expected no overlap, got %v", unrelated)
+	}
+}
+
+func TestRelevanceScore_EmptyContent(t *testing.T) {
+	if score := RelevanceScore("!!!", nil, "chef"); score != 0 {
+		t.Errorf("expected 0 for content without terms, got %v", score)
+	}
+}
+
+func TestPassesPrefilter(t *testing.T) {
+	prompt := "You are a chef"
+
+	if !PassesPrefilter("airplanes", nil, prompt, 0) {
+		t.Error("expected a zero threshold to disable the pre-filter")
+	}
+	if PassesPrefilter("airplanes", nil, prompt, 0.2) {
+		t.Error("expected unrelated message to be filtered")
+	}
+	if !PassesPrefilter("I need a chef", nil, prompt, 0.2) {
+		t.Error("expected related message to pass")
+	}
+	if !PassesPrefilter("airplanes and pasta", []string{"pasta"}, prompt, 1) {
+		t.Error("expected keyword match to pass any threshold")
+	}
+}
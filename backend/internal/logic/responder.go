@@ -1,8 +1,6 @@
 package logic
 
 import (
-	"strings"
-
 	"multi-avatar-chat/internal/models"
 )
 
@@ -34,7 +32,7 @@ func SelectResponders(content string, avatars []models.Avatar) []models.Avatar {
 	nameToAvatar := make(map[string]models.Avatar)
 	for i, avatar := range avatars {
 		avatarNames[i] = avatar.Name
-		nameToAvatar[strings.ToLower(avatar.Name)] = avatar
+		nameToAvatar[NormalizeAvatarName(avatar.Name)] = avatar
 	}
 
 	// Parse mentions from content
@@ -54,7 +52,7 @@ func SelectResponders(content string, avatars []models.Avatar) []models.Avatar {
 	// Return only the mentioned avatars
 	var responders []models.Avatar
 	for _, name := range matchedNames {
-		if avatar, ok := nameToAvatar[strings.ToLower(name)]; ok {
+		if avatar, ok := nameToAvatar[NormalizeAvatarName(name)]; ok {
 			responders = append(responders, avatar)
 		}
 	}
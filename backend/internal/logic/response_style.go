@@ -0,0 +1,37 @@
+package logic
+
+// ResponseStyle controls how long avatar responses should be in a conversation
+type ResponseStyle string
+
+const (
+	ResponseStyleBrief    ResponseStyle = "brief"
+	ResponseStyleNormal   ResponseStyle = "normal"
+	ResponseStyleDetailed ResponseStyle = "detailed"
+)
+
+// ParseResponseStyle validates a response style string
+// An empty string is treated as ResponseStyleNormal
+func ParseResponseStyle(value string) (ResponseStyle, bool) {
+	switch ResponseStyle(value) {
+	case "", ResponseStyleNormal:
+		return ResponseStyleNormal, true
+	case ResponseStyleBrief, ResponseStyleDetailed:
+		return ResponseStyle(value), true
+	}
+	return "", false
+}
+
+// FormatResponseStyleInstructions returns the run instructions for a response style
+// Returns an empty string for the normal style so the persona prompt decides the length
+func FormatResponseStyleInstructions(style ResponseStyle) string {
+	switch style {
+	case ResponseStyleBrief:
+		return "【Response Style】\n" +
+			"Keep your reply brief: 1-3 sentences, roughly 80 tokens at most.\n" +
+			"Do not use headings or long lists."
+	case ResponseStyleDetailed:
+		return "【Response Style】\n" +
+			"Give a detailed reply with explanations and examples where helpful, up to roughly 800 tokens."
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResponseStyle(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ResponseStyle
+		valid    bool
+	}{
+		{"", ResponseStyleNormal, true},
+		{"normal", ResponseStyleNormal, true},
+		{"brief", ResponseStyleBrief, true},
+		{"detailed", ResponseStyleDetailed, true},
+		{"verbose", "", false},
+		{"BRIEF", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			style, ok := ParseResponseStyle(tt.input)
+			if ok != tt.valid {
+				t.Errorf("ParseResponseStyle(%q) valid = %v, want %v", tt.input, ok, tt.valid)
+			}
+			if style != tt.expected {
+				t.Errorf("ParseResponseStyle(%q) = %q, want %q", tt.input, style, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatResponseStyleInstructions(t *testing.T) {
+	if got := FormatResponseStyleInstructions(ResponseStyleNormal); got != "" {
+		t.Errorf("expected no instructions for normal style, got %q", got)
+	}
+
+	brief := FormatResponseStyleInstructions(ResponseStyleBrief)
+	if !strings.Contains(brief, "【Response Style】") || !strings.Contains(brief, "80 tokens") {
+		t.Errorf("unexpected brief instructions: %q", brief)
+	}
+
+	detailed := FormatResponseStyleInstructions(ResponseStyleDetailed)
+	if !strings.Contains(detailed, "800 tokens") {
+		t.Errorf("unexpected detailed instructions: %q", detailed)
+	}
+}
@@ -0,0 +1,24 @@
+package logic
+
+import "strings"
+
+// InstructionsBuilder builds assistant instructions and judgment prompts with the operator's safety preamble
+// The zero value adds no preamble
+type InstructionsBuilder struct {
+	preamble string
+}
+
+// NewInstructionsBuilder returns a builder that prepends the safety preamble
+// Surrounding whitespace is trimmed, and an empty preamble turns it off
+func NewInstructionsBuilder(preamble string) InstructionsBuilder {
+	return InstructionsBuilder{preamble: strings.TrimSpace(preamble)}
+}
+
+// WithSafetyPreamble prepends the safety preamble to a prompt
+// The prompt is returned unchanged when no preamble is configured
+func (b InstructionsBuilder) WithSafetyPreamble(prompt string) string {
+	if b.preamble == "" {
+		return prompt
+	}
+	return b.preamble + "\n\n" + prompt
+}
@@ -0,0 +1,33 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstructionsBuilder_SafetyPreamble(t *testing.T) {
+	if got := (InstructionsBuilder{}).WithSafetyPreamble("prompt"); got != "prompt" {
+		t.Errorf("expected the prompt unchanged without a preamble, got %q", got)
+	}
+
+	builder := NewInstructionsBuilder("  Never share personal data.\n")
+	if got := builder.WithSafetyPreamble("prompt"); got != "Never share personal data.\n\nprompt" {
+		t.Errorf("expected the preamble first, got %q", got)
+	}
+
+	instructions := builder.AssistantInstructions("Be kind")
+	if !strings.HasPrefix(instructions, "Never share personal data.\n\n"+UserPriorityInstruction) {
+		t.Errorf("expected the preamble before the user priority instruction, got %q", instructions)
+	}
+	if !strings.HasPrefix(builder.BatchJudgmentPrompt("", nil, []JudgmentCandidate{{Name: "Alice"}}, "hi"), "Never share personal data.") {
+		t.Errorf("expected the preamble in judgment prompts")
+	}
+
+	// The prompt is recovered even after the preamble changed
+	if got := PromptFromInstructions(NewInstructionsBuilder("Be careful.").AssistantInstructions("Be kind")); got != "Be kind" {
+		t.Errorf("expected the prompt back, got %q", got)
+	}
+	if got := PromptFromInstructions(instructions); got != "Be kind" {
+		t.Errorf("expected the prompt back, got %q", got)
+	}
+}
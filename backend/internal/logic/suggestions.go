@@ -0,0 +1,63 @@
+package logic
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxSuggestions is the largest number of suggested replies offered to the user
+const MaxSuggestions = 3
+
+// suggestionMarkerPattern matches list markers and numbering at the start of a suggestion line
+var suggestionMarkerPattern = regexp.MustCompile(`^(?:[-*•・]|\d+[.)]|[0-9０-９]+[.．)）])\s*`)
+
+// BuildSuggestionsPrompt builds the prompt asking for replies the user could send next
+func BuildSuggestionsPrompt(title string, messages []MessageForFormat) string {
+	return `You are helping the user of a group chat with AI avatars keep the conversation going.
+
+【Conversation】
+` + title + `
+
+【Recent Messages】
+` + FormatMessageHistory(messages, "") + `
+
+【Instructions】
+The conversation has gone quiet. Suggest 2 or 3 short messages the user could send next,
+such as a follow-up question to one of the avatars or a new angle on the topic.
+- Write in the language of the conversation
+- Write each suggestion as the user, in at most 20 words
+- Output one suggestion per line without numbering or any other text`
+}
+
+// ParseSuggestions extracts up to MaxSuggestions suggestions from an LLM answer, one per line
+// List markers, numbering and surrounding quotes are removed and duplicates are skipped
+func ParseSuggestions(answer string) []string {
+	var suggestions []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(suggestionMarkerPattern.ReplaceAllString(strings.TrimSpace(line), ""))
+		line = strings.Trim(line, `"「」“”`)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		suggestions = append(suggestions, line)
+		if len(suggestions) == MaxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// FallbackSuggestions returns generic suggestions for when no LLM is available
+// The last avatar that spoke is asked for its view when there is one
+func FallbackSuggestions(lastAvatarName string) []string {
+	suggestions := []string{
+		"Can you summarize the discussion so far?",
+		"What should we talk about next?",
+	}
+	if lastAvatarName != "" {
+		suggestions = append(suggestions, FormatMention(lastAvatarName)+" can you tell me more?")
+	}
+	return suggestions
+}
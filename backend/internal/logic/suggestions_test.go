@@ -0,0 +1,40 @@
+package logic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildSuggestionsPrompt(t *testing.T) {
+	prompt := BuildSuggestionsPrompt("Travel", []MessageForFormat{
+		{SenderType: SenderTypeUserFormat, Content: "Where should I go?"},
+		{SenderType: SenderTypeAvatarFormat, SenderName: "Alice", Content: "Kyoto"},
+	})
+	for _, want := range []string{"Travel", "Where should I go?", "Kyoto", "one suggestion per line"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected prompt to contain %q", want)
+		}
+	}
+}
+
+func TestParseSuggestions(t *testing.T) {
+	answer := "1. What about Osaka?\n\n- \"How long should I stay?\"\n* What about Osaka?\n２．予算はどのくらい？\nWhat should I eat?"
+	want := []string{"What about Osaka?", "How long should I stay?", "予算はどのくらい？"}
+	if got := ParseSuggestions(answer); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := ParseSuggestions("  \n"); got != nil {
+		t.Errorf("expected no suggestions, got %q", got)
+	}
+}
+
+func TestFallbackSuggestions(t *testing.T) {
+	if got := FallbackSuggestions(""); len(got) != 2 {
+		t.Errorf("expected 2 generic suggestions, got %q", got)
+	}
+	got := FallbackSuggestions("Dr. Smith")
+	if len(got) != 3 || !strings.HasPrefix(got[2], `@"Dr. Smith"`) {
+		t.Errorf("expected a quoted mention of the last avatar, got %q", got)
+	}
+}
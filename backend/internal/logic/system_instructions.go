@@ -0,0 +1,30 @@
+package logic
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxSystemInstructionsLength is the longest conversation system instructions accepted, in characters
+const MaxSystemInstructionsLength = 2000
+
+// ParseSystemInstructions trims conversation system instructions and checks their length
+func ParseSystemInstructions(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > MaxSystemInstructionsLength {
+		return "", false
+	}
+	return value, true
+}
+
+// FormatSystemInstructions returns the run instructions for a conversation's system instructions
+// Returns an empty string when the conversation has none
+func FormatSystemInstructions(instructions string) string {
+	instructions = strings.TrimSpace(instructions)
+	if instructions == "" {
+		return ""
+	}
+	return "【Conversation Instructions】\n" +
+		"Follow these instructions set for this conversation:\n" +
+		instructions
+}
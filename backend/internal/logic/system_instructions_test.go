@@ -0,0 +1,29 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSystemInstructions(t *testing.T) {
+	if got, ok := ParseSystemInstructions("  Speak in formal English.\n"); !ok || got != "Speak in formal English." {
+		t.Errorf("expected trimmed instructions, got %q ok=%v", got, ok)
+	}
+	if _, ok := ParseSystemInstructions(strings.Repeat("あ", MaxSystemInstructionsLength)); !ok {
+		t.Error("expected instructions at the limit to be accepted")
+	}
+	if _, ok := ParseSystemInstructions(strings.Repeat("a", MaxSystemInstructionsLength+1)); ok {
+		t.Error("expected instructions over the limit to be rejected")
+	}
+}
+
+func TestFormatSystemInstructions(t *testing.T) {
+	if got := FormatSystemInstructions("  "); got != "" {
+		t.Errorf("expected no instructions, got %q", got)
+	}
+
+	got := FormatSystemInstructions("Keep answers under 3 sentences.")
+	if !strings.HasPrefix(got, "【Conversation Instructions】") || !strings.HasSuffix(got, "Keep answers under 3 sentences.") {
+		t.Errorf("unexpected instructions: %q", got)
+	}
+}
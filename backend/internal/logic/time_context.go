@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"fmt"
+	"time"
+)
+
+// FormatTimeContext returns the run instructions telling an avatar the current time
+// and how long before now the previous message of the conversation was sent
+// previousAt is zero when the conversation has no earlier message
+func FormatTimeContext(now, previousAt time.Time) string {
+	text := "【Current Time】\n" +
+		"It is now " + now.Format("Monday, 2006-01-02 15:04 (MST)") + "."
+	if !previousAt.IsZero() {
+		text += "\nThe previous message in this conversation was sent " + FormatElapsed(now.Sub(previousAt)) + "."
+	}
+	return text + "\nUse this when the time matters, for example to refer to what was said earlier today or to plan ahead."
+}
+
+// FormatElapsed describes a duration in words, rounded down to its largest unit
+func FormatElapsed(elapsed time.Duration) string {
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return plural(int(elapsed/time.Minute), "minute") + " ago"
+	case elapsed < 24*time.Hour:
+		return plural(int(elapsed/time.Hour), "hour") + " ago"
+	}
+	return plural(int(elapsed/(24*time.Hour)), "day") + " ago"
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
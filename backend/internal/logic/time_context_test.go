@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTimeContext(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2026, 10, 16, 11, 59, 0, 0, jst)
+
+	got := FormatTimeContext(now, now.Add(-3*time.Hour-20*time.Minute))
+	if !strings.HasPrefix(got, "【Current Time】\nIt is now Friday, 2026-10-16 11:59 (JST).") {
+		t.Errorf("expected the current time, got %q", got)
+	}
+	if !strings.Contains(got, "sent 3 hours ago.") {
+		t.Errorf("expected the time since the previous message, got %q", got)
+	}
+
+	if got := FormatTimeContext(now, time.Time{}); strings.Contains(got, "previous message") {
+		t.Errorf("expected no elapsed time without a previous message, got %q", got)
+	}
+}
+
+func TestFormatElapsed(t *testing.T) {
+	tests := []struct {
+		elapsed time.Duration
+		want    string
+	}{
+		{30 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{45 * time.Minute, "45 minutes ago"},
+		{time.Hour, "1 hour ago"},
+		{23 * time.Hour, "23 hours ago"},
+		{50 * time.Hour, "2 days ago"},
+	}
+	for _, tt := range tests {
+		if got := FormatElapsed(tt.elapsed); got != tt.want {
+			t.Errorf("FormatElapsed(%v) = %q, want %q", tt.elapsed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLanguageLength is the longest conversation language accepted, in characters
+const MaxLanguageLength = 35
+
+// ParseLanguage trims a conversation language, such as "English" or "ja", and checks it
+// The empty language turns translation off
+func ParseLanguage(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > MaxLanguageLength {
+		return "", false
+	}
+	for _, r := range value {
+		if !unicode.IsLetter(r) && r != '-' && r != '_' && r != ' ' {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// TranslatesReplies reports whether avatar replies written in language are shown in displayLanguage
+func TranslatesReplies(language, displayLanguage string) bool {
+	return displayLanguage != "" && !strings.EqualFold(language, displayLanguage)
+}
+
+// FormatLanguageInstructions returns the run instructions asking avatars to respond in the conversation language
+// Returns an empty string when the conversation has none
+func FormatLanguageInstructions(language string) string {
+	if language == "" {
+		return ""
+	}
+	return "【Language】\nRespond in " + language + ". Messages from the user are translated into " + language + " for you."
+}
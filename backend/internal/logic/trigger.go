@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"regexp"
+	"strings"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// CompileTrigger checks that a trigger pattern can be matched
+// Keywords always can; regex patterns must compile
+func CompileTrigger(pattern string, regex bool) (*regexp.Regexp, error) {
+	if !regex {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// MatchTrigger returns the first trigger matching the message content, or nil when none does
+// Keywords match anywhere in the content ignoring case; regex patterns that do not compile never match
+func MatchTrigger(content string, triggers []models.AvatarTrigger) *models.AvatarTrigger {
+	lower := strings.ToLower(content)
+	for i := range triggers {
+		t := &triggers[i]
+		if !t.Regex {
+			keyword := strings.ToLower(strings.TrimSpace(t.Pattern))
+			if keyword != "" && strings.Contains(lower, keyword) {
+				return t
+			}
+			continue
+		}
+		re, err := CompileTrigger(t.Pattern, true)
+		if err == nil && re.MatchString(content) {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package logic
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/models"
+)
+
+func TestMatchTrigger(t *testing.T) {
+	triggers := []models.AvatarTrigger{
+		{ID: 1, Pattern: "contract"},
+		{ID: 2, Pattern: `\bNDA\b`, Regex: true},
+		{ID: 3, Pattern: "契約"},
+		{ID: 4, Pattern: "(", Regex: true},
+	}
+
+	tests := []struct {
+		content string
+		want    int64
+	}{
+		{"Can you review this Contract?", 1},
+		{"We need an NDA first", 2},
+		{"the agenda is ready", 0},
+		{"この契約書を確認して", 3},
+		{"just chatting (nothing else)", 0},
+	}
+	for _, tt := range tests {
+		matched := MatchTrigger(tt.content, triggers)
+		var got int64
+		if matched != nil {
+			got = matched.ID
+		}
+		if got != tt.want {
+			t.Errorf("MatchTrigger(%q) = trigger %d, want %d", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestCompileTrigger(t *testing.T) {
+	if _, err := CompileTrigger("(", false); err != nil {
+		t.Errorf("expected any keyword to be accepted, got %v", err)
+	}
+	if _, err := CompileTrigger("(", true); err == nil {
+		t.Error("expected an invalid regex to be rejected")
+	}
+}
@@ -4,19 +4,69 @@ import "time"
 
 // Avatar represents a chat avatar with AI personality
 type Avatar struct {
-	ID                int64     `json:"id"`
-	Name              string    `json:"name"`
-	Prompt            string    `json:"prompt"`
-	OpenAIAssistantID string    `json:"openai_assistant_id,omitempty"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                 int64    `json:"id"`
+	Name               string   `json:"name"`
+	Prompt             string   `json:"prompt"`
+	OpenAIAssistantID  string   `json:"openai_assistant_id,omitempty"`
+	Color              string   `json:"color"`
+	Emoji              string   `json:"emoji"`
+	Keywords           []string `json:"keywords"`
+	RelevanceThreshold float64  `json:"relevance_threshold"`
+	CanSearch          bool     `json:"can_search"`
+	CanCode            bool     `json:"can_code"`
+	CanCite            bool     `json:"can_cite"`
+	// FormattingRules shape the avatar's responses through its run instructions and before they are stored
+	FormattingRules FormattingRules `json:"formatting_rules"`
+	// IsolatedContext keeps other avatars' messages out of the avatar's thread and run context,
+	// so it answers only from what the user said
+	IsolatedContext bool `json:"isolated_context"`
+	// NeedsRelink is set when OpenAI no longer knows the avatar's assistant; the avatar stays silent until relinked
+	NeedsRelink bool      `json:"needs_relink"`
+	CreatedAt   time.Time `json:"created_at"`
+	// UpdatedAt changes on every write and versions the avatar for conditional requests
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FormattingRules are per-avatar rules for the layout of responses
+// The zero value applies no rules
+type FormattingRules struct {
+	// NameTag starts every response with the avatar's name in brackets
+	NameTag bool `json:"name_tag"`
+	// MaxParagraphs limits the number of paragraphs in a response; 0 means no limit
+	MaxParagraphs int `json:"max_paragraphs"`
+	// BulletLists asks for lists as "- " bullets instead of prose or other list markers
+	BulletLists bool `json:"bullet_lists"`
 }
 
 // Conversation represents a chat session
 type Conversation struct {
-	ID        int64     `json:"id"`
-	ThreadID  string    `json:"thread_id,omitempty"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int64  `json:"id"`
+	ThreadID        string `json:"thread_id,omitempty"`
+	Title           string `json:"title"`
+	ResponseStyle   string `json:"response_style"`
+	RedactionPolicy string `json:"redaction_policy"`
+	// SystemInstructions are appended to the run instructions of every avatar in the conversation
+	SystemInstructions string `json:"system_instructions"`
+	// MaxContextMessages limits how many recent thread messages an avatar's run reads; 0 reads the whole thread
+	MaxContextMessages int       `json:"max_context_messages"`
+	CreatedAt          time.Time `json:"created_at"`
+	// UpdatedAt changes on every settings change and versions the conversation for conditional requests
+	UpdatedAt time.Time `json:"updated_at"`
+	// ExpiresAt is when the conversation is deleted by the expiry job; nil keeps it forever
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Language is the language avatars talk in; user messages are translated into it before they
+	// are forwarded to the threads. Empty turns translation off
+	Language string `json:"language"`
+	// DisplayLanguage is the language avatar replies are shown in; empty shows them as written
+	DisplayLanguage string `json:"display_language"`
+	// OpenAIOrganization and OpenAIProject bill the conversation's OpenAI usage to them;
+	// empty values use the server's defaults
+	OpenAIOrganization string `json:"openai_organization"`
+	OpenAIProject      string `json:"openai_project"`
+	// Published shows the conversation in the public gallery at /gallery/{GallerySlug}
+	// The slug is kept when the conversation is unpublished, so publishing again restores the same link
+	Published   bool   `json:"published"`
+	GallerySlug string `json:"gallery_slug,omitempty"`
 }
 
 // SenderType defines who sent the message
@@ -25,16 +75,82 @@ type SenderType string
 const (
 	SenderTypeUser   SenderType = "user"
 	SenderTypeAvatar SenderType = "avatar"
+	SenderTypeSystem SenderType = "system"
 )
 
 // Message represents a single message in a conversation
 type Message struct {
 	ID             int64      `json:"id"`
 	ConversationID int64      `json:"conversation_id"`
+	Sequence       int64      `json:"sequence"` // Per-conversation order, starting at 1
 	SenderType     SenderType `json:"sender_type"`
 	SenderID       *int64     `json:"sender_id,omitempty"`
 	Content        string     `json:"content"`
 	CreatedAt      time.Time  `json:"created_at"`
+	// Silent messages are stored without avatars reacting to them, such as imported history
+	Silent bool `json:"silent,omitempty"`
+	// Citations is only filled in when a message is created with citations
+	Citations []MessageCitation `json:"citations,omitempty"`
+	// PromptVariant is only filled in when an avatar responds under a prompt experiment
+	PromptVariant string `json:"prompt_variant,omitempty"`
+	// Model is only filled in when a message is created with the model that generated it
+	Model string `json:"model,omitempty"`
+	// Translation is only filled in when a message is created with a translation
+	Translation *MessageTranslation `json:"translation,omitempty"`
+}
+
+// MessageTranslation is a message as the avatars read it, in the conversation language
+// For a user message it is the translation forwarded to the threads; for an avatar reply
+// shown translated it is the original reply
+type MessageTranslation struct {
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// AILabel marks whether a message's content was generated by AI, for AI-labeling requirements
+// Model is empty when the generating model is unknown, e.g. for imported avatar messages
+type AILabel struct {
+	AIGenerated bool   `json:"ai_generated"`
+	Model       string `json:"model,omitempty"`
+}
+
+// NewAILabel labels a message from its sender and recorded model
+// Avatar messages are always AI-generated; other messages only when a model generated them, such as digests
+func NewAILabel(senderType SenderType, model string) AILabel {
+	return AILabel{AIGenerated: senderType == SenderTypeAvatar || model != "", Model: model}
+}
+
+// Message artifact types
+const (
+	ArtifactTypeCode  = "code"
+	ArtifactTypeLogs  = "logs"
+	ArtifactTypeImage = "image"
+)
+
+// MessageArtifact is a structured output attached to a message, such as the code,
+// logs and images produced by the code interpreter while generating it
+type MessageArtifact struct {
+	ID        int64     `json:"id"`
+	MessageID int64     `json:"message_id"`
+	Type      string    `json:"type"`
+	Content   string    `json:"content,omitempty"`
+	FileID    string    `json:"file_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageCitation is a source cited by a message, such as a file found by file search
+// Marker is the text in the message content, located between StartIndex and EndIndex
+type MessageCitation struct {
+	ID         int64     `json:"id"`
+	MessageID  int64     `json:"message_id"`
+	Type       string    `json:"type"`
+	Marker     string    `json:"marker"`
+	FileID     string    `json:"file_id"`
+	Filename   string    `json:"filename,omitempty"`
+	Quote      string    `json:"quote,omitempty"`
+	StartIndex int       `json:"start_index"`
+	EndIndex   int       `json:"end_index"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 // ConversationAvatar represents avatar participation in a conversation
@@ -43,3 +159,591 @@ type ConversationAvatar struct {
 	AvatarID       int64  `json:"avatar_id"`
 	ThreadID       string `json:"thread_id,omitempty"`
 }
+
+// Team represents a named group of avatars that can be attached to conversations together
+type Team struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ConversationLink represents a message in one conversation referencing another conversation
+type ConversationLink struct {
+	MessageID            int64     `json:"message_id"`
+	SourceConversationID int64     `json:"source_conversation_id"`
+	SourceTitle          string    `json:"source_title"`
+	TargetConversationID int64     `json:"target_conversation_id"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// Digest records a periodic summary posted to a conversation as a system message
+type Digest struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	LastMessageID  int64     `json:"last_message_id"`
+	MessageCount   int       `json:"message_count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ConversationEvent is a broadcast event recorded in a conversation's activity timeline
+// Data holds the JSON-encoded event payload
+type ConversationEvent struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	EventType      string    `json:"event_type"`
+	Data           string    `json:"data"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Purge scopes
+const (
+	PurgeScopeConversation = "conversation"
+	PurgeScopeContent      = "content"
+)
+
+// Purge statuses
+const (
+	PurgeStatusCompleted = "completed"
+	PurgeStatusFailed    = "failed"
+)
+
+// PurgeRecord is an audit entry for a data purge
+// Target is the conversation ID or a SHA-256 hash of the purged text, never the text itself
+type PurgeRecord struct {
+	ID              int64     `json:"id"`
+	Scope           string    `json:"scope"`
+	Target          string    `json:"target"`
+	ConversationIDs []int64   `json:"conversation_ids"`
+	MessagesDeleted int64     `json:"messages_deleted"`
+	RemoteDeleted   int       `json:"remote_deleted"`
+	Status          string    `json:"status"`
+	Error           string    `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Audit actions
+const (
+	AuditActionAvatarCreate           = "avatar.create"
+	AuditActionAvatarUpdate           = "avatar.update"
+	AuditActionAvatarDelete           = "avatar.delete"
+	AuditActionAvatarImport           = "avatar.import"
+	AuditActionAvatarRelink           = "avatar.relink"
+	AuditActionAssistantRecreate      = "avatar.recreate_assistant"
+	AuditActionAssistantDrift         = "avatar.assistant_drift"
+	AuditActionAssistantResync        = "avatar.resync_assistant"
+	AuditActionConversationDelete     = "conversation.delete"
+	AuditActionConversationInterrupt  = "conversation.interrupt"
+	AuditActionConversationSplit      = "conversation.split"
+	AuditActionConversationExpire     = "conversation.expire"
+	AuditActionConversationPublish    = "conversation.publish"
+	AuditActionConversationUnpublish  = "conversation.unpublish"
+	AuditActionThreadRecreate         = "conversation.recreate_thread"
+	AuditActionPurgeConversation      = "purge.conversation"
+	AuditActionPurgeContent           = "purge.content"
+	AuditActionExperimentUpdate       = "experiment.update"
+	AuditActionExperimentDelete       = "experiment.delete"
+	AuditActionPromptExperimentUpdate = "prompt_experiment.update"
+	AuditActionPromptExperimentDelete = "prompt_experiment.delete"
+	AuditActionAvatarScriptUpdate     = "avatar_script.update"
+	AuditActionAvatarScriptDelete     = "avatar_script.delete"
+	AuditActionAvatarTriggerCreate    = "avatar_trigger.create"
+	AuditActionAvatarTriggerDelete    = "avatar_trigger.delete"
+	AuditActionDefaultAvatarsUpdate   = "default_avatars.update"
+	AuditActionInstructionsSync       = "assistants.sync_instructions"
+	AuditActionQueueItemRetry         = "queue_item.retry"
+	AuditActionQueueItemDiscard       = "queue_item.discard"
+	AuditActionDeadLetterRetry        = "dead_letter.retry"
+	AuditActionCapturesClear          = "captures.clear"
+	AuditActionTeamCreate             = "team.create"
+	AuditActionTeamDelete             = "team.delete"
+)
+
+// AuditChange is the old and new value of a single field
+// From is null for created fields and To is null for deleted fields
+type AuditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// AuditEntry is an append-only record of an administrative action
+type AuditEntry struct {
+	ID         int64                  `json:"id"`
+	Actor      string                 `json:"actor"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Changes    map[string]AuditChange `json:"changes"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditFilter selects audit entries; zero fields match everything
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+	// BeforeID returns only entries older than this ID, for paging backwards
+	BeforeID int64
+	Limit    int
+}
+
+// OfflineForward is a message waiting to be added to an avatar's thread until the OpenAI API is available
+type OfflineForward struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	AvatarID       int64     `json:"avatar_id"`
+	ThreadID       string    `json:"thread_id"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PendingThreadDeletion is an OpenAI thread of a deleted conversation that could not be deleted yet
+type PendingThreadDeletion struct {
+	ID             int64     `json:"id"`
+	ThreadID       string    `json:"thread_id"`
+	ConversationID int64     `json:"conversation_id"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"last_error"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AssistantDrift records an avatar whose assistant instructions no longer match its prompt,
+// for example after an edit in the OpenAI dashboard
+type AssistantDrift struct {
+	AvatarID    int64  `json:"avatar_id"`
+	AssistantID string `json:"assistant_id"`
+	// Instructions are the instructions found on the assistant
+	Instructions string    `json:"instructions"`
+	DetectedAt   time.Time `json:"detected_at"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// NotificationPreference records whether a recipient receives emails for an event type
+type NotificationPreference struct {
+	Email     string `json:"email"`
+	EventType string `json:"event_type"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job is a long-running operation executed in the background
+// Payload and Result are JSON documents whose shape depends on Type
+type Job struct {
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	Payload     string     `json:"payload"`
+	Result      string     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Run statuses
+const (
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// Run is an OpenAI run started by an avatar watcher
+// ID is the OpenAI run ID. ErrorCode classifies a failed run, e.g. rate_limit_exceeded, server_error or expired
+type Run struct {
+	ID             string     `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	AvatarID       int64      `json:"avatar_id"`
+	ThreadID       string     `json:"thread_id"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	ErrorCode      string     `json:"error_code,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// Dead letter statuses
+const (
+	DeadLetterStatusOpen     = "open"
+	DeadLetterStatusRetrying = "retrying"
+	DeadLetterStatusResolved = "resolved"
+)
+
+// DeadLetter records an avatar response that could not be generated
+// Context is a JSON snapshot of what the run was started with
+type DeadLetter struct {
+	ID             int64      `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	AvatarID       int64      `json:"avatar_id"`
+	MessageID      int64      `json:"message_id"`
+	Error          string     `json:"error"`
+	Attempts       int        `json:"attempts"`
+	Context        string     `json:"context"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Variants of an avatar response in a model comparison experiment
+const (
+	// ExperimentVariantPrimary is the response of the avatar's assistant run
+	ExperimentVariantPrimary = "primary"
+	// ExperimentVariantComparison is the response of the experiment's comparison model
+	ExperimentVariantComparison = "comparison"
+)
+
+// ResponseExperiment makes an avatar generate each response with a second model for comparison
+// Broadcast is the variant posted to the conversation; the other one is only stored for review
+type ResponseExperiment struct {
+	AvatarID  int64     `json:"avatar_id"`
+	Model     string    `json:"model"`
+	Broadcast string    `json:"broadcast"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ResponseCandidate is one model's response in a comparison; Error is set when the model failed
+type ResponseCandidate struct {
+	Model     string `json:"model"`
+	Content   string `json:"content"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ResponseComparison holds both responses of an avatar to one message under an experiment
+// MessageID is the conversation message showing the broadcast variant, nil once that message is deleted.
+// Preferred is the reviewer's verdict: primary, comparison, tie or empty when not reviewed yet
+type ResponseComparison struct {
+	ID               int64             `json:"id"`
+	ConversationID   int64             `json:"conversation_id"`
+	AvatarID         int64             `json:"avatar_id"`
+	TriggerMessageID int64             `json:"trigger_message_id"`
+	MessageID        *int64            `json:"message_id,omitempty"`
+	Broadcast        string            `json:"broadcast"`
+	Primary          ResponseCandidate `json:"primary"`
+	Comparison       ResponseCandidate `json:"comparison"`
+	Preferred        string            `json:"preferred"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
+
+// Variants of an avatar prompt in a prompt experiment
+const (
+	PromptVariantA = "a"
+	PromptVariantB = "b"
+)
+
+// PromptExperiment splits the messages an avatar handles between two prompt variants
+// SplitB is the percentage of messages handled with PromptB; the others use PromptA.
+// StartedAt is when the current prompts were set; stats only count trials since then
+type PromptExperiment struct {
+	AvatarID  int64     `json:"avatar_id"`
+	PromptA   string    `json:"prompt_a"`
+	PromptB   string    `json:"prompt_b"`
+	SplitB    int       `json:"split_b"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PromptTrial is one message an avatar judged with a prompt variant
+// MessageID is the avatar's response, nil if it did not respond or the response failed
+type PromptTrial struct {
+	ID               int64     `json:"id"`
+	ConversationID   int64     `json:"conversation_id"`
+	AvatarID         int64     `json:"avatar_id"`
+	TriggerMessageID int64     `json:"trigger_message_id"`
+	Variant          string    `json:"variant"`
+	ShouldRespond    bool      `json:"should_respond"`
+	MessageID        *int64    `json:"message_id,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// PromptVariantStats compares the trials of one prompt variant
+// UserReplies counts responses that the user answered next in the conversation
+type PromptVariantStats struct {
+	Variant               string  `json:"variant"`
+	Trials                int     `json:"trials"`
+	Responded             int     `json:"responded"`
+	ResponseRate          float64 `json:"response_rate"`
+	Responses             int     `json:"responses"`
+	AverageResponseLength float64 `json:"average_response_length"`
+	UserReplies           int     `json:"user_replies"`
+	UserReplyRate         float64 `json:"user_reply_rate"`
+}
+
+// AvatarScript is a behavior script an avatar runs on each incoming message
+// LastError is the most recent runtime error; it is cleared when the source changes
+type AvatarScript struct {
+	AvatarID    int64      `json:"avatar_id"`
+	Source      string     `json:"source"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// MessageActivity is how much one sender wrote in a conversation during one time window
+// SenderID is the avatar ID for avatar messages; Characters counts the characters of the messages
+type MessageActivity struct {
+	WindowStart time.Time
+	SenderType  SenderType
+	SenderID    *int64
+	Messages    int
+	Characters  int
+}
+
+// AvatarTrigger makes an avatar respond in a conversation whenever a message matches, without LLM judgment
+// A keyword matches anywhere in the message ignoring case; a regex pattern is matched as written
+type AvatarTrigger struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	AvatarID       int64     `json:"avatar_id"`
+	Pattern        string    `json:"pattern"`
+	Regex          bool      `json:"regex"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Redaction records how many values of a kind were redacted from a user message
+// The redacted values themselves are never stored
+type Redaction struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	Policy         string    `json:"policy"`
+	Kind           string    `json:"kind"`
+	Count          int       `json:"count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// HTTPCapture is an API request and its response recorded in debug mode, for reproducing reported bugs
+// Secret headers and JSON fields are redacted before they are stored
+type HTTPCapture struct {
+	ID              int64             `json:"id"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	Status          int               `json:"status"`
+	DurationMS      int64             `json:"duration_ms"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body"`
+	Truncated       bool              `json:"truncated"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// Presence statuses of the user in a conversation
+const (
+	PresenceViewing = "viewing"
+	PresenceAway    = "away"
+)
+
+// UserPresence is whether the user is viewing a conversation, as reported by a client
+// AwaySequence is the last message sequence when the user went away, so the messages posted
+// while away can be summarized when they return
+type UserPresence struct {
+	ConversationID int64     `json:"conversation_id"`
+	Status         string    `json:"status"`
+	AwaySequence   int64     `json:"away_sequence,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Away reports whether the user is away from the conversation
+func (p *UserPresence) Away() bool {
+	return p != nil && p.Status == PresenceAway
+}
+
+// SSE event types sent on a conversation's event stream
+const (
+	EventTypeConnected        = "connected"
+	EventTypeMessage          = "message"
+	EventTypeAvatarJoined     = "avatar_joined"
+	EventTypeAvatarLeft       = "avatar_left"
+	EventTypeInterrupt        = "interrupt"
+	EventTypeRunFailed        = "run_failed"
+	EventTypeWaitingForUser   = "waiting_for_user"
+	EventTypeMessagesImported = "messages_imported"
+	EventTypeSuggestions      = "suggestions"
+	EventTypeViewerCount      = "viewer_count"
+	EventTypeLLMUnavailable   = "llm_unavailable"
+	EventTypeLLMAvailable     = "llm_available"
+	EventTypeServerShutdown   = "server_shutdown"
+	// EventTypeConversationExpired is sent to every connected client, as other rooms list the conversation too
+	EventTypeConversationExpired = "conversation_expired"
+	EventTypePresence            = "presence"
+	// EventTypeMessageDelta carries text of an avatar response while its run is still writing it
+	EventTypeMessageDelta    = "message_delta"
+	EventTypeMessageComplete = "message_complete"
+	// EventTypeTyping is sent while the user types in a WebSocket client
+	EventTypeTyping = "typing"
+	// EventTypeResync is sent on reconnect when the missed events can no longer be replayed
+	EventTypeResync = "resync"
+)
+
+// EmptyEvent is the payload of events that carry no data, such as connected and interrupt
+type EmptyEvent struct{}
+
+// MessageEvent is the payload of a message event, matching a message in the messages API
+// Silent is set for user messages that no avatar will answer
+type MessageEvent struct {
+	ID            int64           `json:"id"`
+	Sequence      int64           `json:"sequence"`
+	SenderType    string          `json:"sender_type"`
+	SenderID      *int64          `json:"sender_id,omitempty"`
+	SenderName    string          `json:"sender_name,omitempty"`
+	SenderColor   string          `json:"sender_color,omitempty"`
+	SenderEmoji   string          `json:"sender_emoji,omitempty"`
+	Content       string          `json:"content"`
+	Citations     []EventCitation `json:"citations,omitempty"`
+	PromptVariant string          `json:"prompt_variant,omitempty"`
+	AILabel       AILabel         `json:"ai_label"`
+	Silent        bool            `json:"silent,omitempty"`
+	CreatedAt     string          `json:"created_at"`
+	// Translation is the message in the conversation language when it was translated
+	Translation *MessageTranslation `json:"translation,omitempty"`
+}
+
+// EventCitation is a citation in a message event
+type EventCitation struct {
+	Type       string `json:"type"`
+	Marker     string `json:"marker"`
+	FileID     string `json:"file_id"`
+	Filename   string `json:"filename,omitempty"`
+	Quote      string `json:"quote,omitempty"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+// NewMessageEvent builds the message event payload of a stored message
+// Sender display fields are left to the caller
+func NewMessageEvent(msg *Message) MessageEvent {
+	event := MessageEvent{
+		ID:            msg.ID,
+		Sequence:      msg.Sequence,
+		SenderType:    string(msg.SenderType),
+		SenderID:      msg.SenderID,
+		Content:       msg.Content,
+		PromptVariant: msg.PromptVariant,
+		AILabel:       NewAILabel(msg.SenderType, msg.Model),
+		CreatedAt:     msg.CreatedAt.Format(time.RFC3339),
+		Translation:   msg.Translation,
+		Silent:        msg.Silent,
+	}
+	for _, c := range msg.Citations {
+		event.Citations = append(event.Citations, EventCitation{
+			Type:       c.Type,
+			Marker:     c.Marker,
+			FileID:     c.FileID,
+			Filename:   c.Filename,
+			Quote:      c.Quote,
+			StartIndex: c.StartIndex,
+			EndIndex:   c.EndIndex,
+		})
+	}
+	return event
+}
+
+// AvatarJoinedEvent is the payload of an avatar_joined event
+type AvatarJoinedEvent struct {
+	AvatarID    int64  `json:"avatar_id"`
+	AvatarName  string `json:"avatar_name"`
+	AvatarColor string `json:"avatar_color,omitempty"`
+	AvatarEmoji string `json:"avatar_emoji,omitempty"`
+}
+
+// AvatarLeftEvent is the payload of an avatar_left event
+type AvatarLeftEvent struct {
+	AvatarID int64 `json:"avatar_id"`
+}
+
+// MessageDeltaEvent is the payload of a message_delta event, a piece of an avatar response being written
+// Deltas of the same run_id are appended in the order they arrive
+type MessageDeltaEvent struct {
+	AvatarID   int64  `json:"avatar_id"`
+	AvatarName string `json:"avatar_name"`
+	RunID      string `json:"run_id"`
+	Delta      string `json:"delta"`
+}
+
+// Statuses of a message_complete event
+const (
+	MessageCompleteSaved  = "saved"
+	MessageCompleteFailed = "failed"
+)
+
+// MessageCompleteEvent is the payload of a message_complete event, sent when a streamed response ends
+// With status saved, MessageID is the stored message, already sent in a message event, that replaces the
+// streamed text; with status failed no message was stored and the streamed text should be discarded
+type MessageCompleteEvent struct {
+	AvatarID  int64  `json:"avatar_id"`
+	RunID     string `json:"run_id"`
+	Status    string `json:"status"`
+	MessageID *int64 `json:"message_id,omitempty"`
+}
+
+// TypingEvent is the payload of a typing event
+// Clients show a typing indicator for a few seconds; typing clients repeat the event while the user keeps typing
+type TypingEvent struct {
+	SenderType SenderType `json:"sender_type"`
+}
+
+// RunFailedEvent is the payload of a run_failed event, sent when a run is cut off
+type RunFailedEvent struct {
+	AvatarID int64  `json:"avatar_id"`
+	RunID    string `json:"run_id"`
+	Reason   string `json:"reason"`
+}
+
+// WaitingForUserEvent is the payload of a waiting_for_user event
+// AvatarMessages is how many avatar messages in a row paused the avatars
+type WaitingForUserEvent struct {
+	AvatarMessages int `json:"avatar_messages"`
+}
+
+// MessagesImportedEvent is the payload of a messages_imported event
+// Clients reload the messages in the sequence range instead of getting one event per message
+type MessagesImportedEvent struct {
+	Count         int   `json:"count"`
+	FirstSequence int64 `json:"first_sequence"`
+	LastSequence  int64 `json:"last_sequence"`
+}
+
+// ViewerCountEvent is the payload of a viewer_count event
+type ViewerCountEvent struct {
+	ConversationID int64 `json:"conversation_id"`
+	Viewers        int   `json:"viewers"`
+}
+
+// LLMUnavailableEvent is the payload of an llm_unavailable event
+type LLMUnavailableEvent struct {
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// ServerShutdownEvent is the payload of a server_shutdown event
+type ServerShutdownEvent struct {
+	RetryMS int64 `json:"retry_ms"`
+}
+
+// PresenceEvent is the payload of a presence event
+type PresenceEvent struct {
+	ConversationID int64  `json:"conversation_id"`
+	Status         string `json:"status"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// ConversationExpiredEvent is the payload of a conversation_expired event
+type ConversationExpiredEvent struct {
+	ConversationID int64  `json:"conversation_id"`
+	Title          string `json:"title"`
+}
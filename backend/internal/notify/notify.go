@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// Notification event types recipients can subscribe to
+const (
+	EventAvatarMention = "avatar_mention"
+	EventIdleQuestion  = "idle_question"
+	EventDigest        = "digest"
+)
+
+// EventTypes lists all supported notification event types
+var EventTypes = []string{EventAvatarMention, EventIdleQuestion, EventDigest}
+
+// IsValidEventType checks that an event type is supported
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// idleCheckInterval is how often conversations are checked for unanswered questions
+const idleCheckInterval = time.Minute
+
+// Mailer sends emails
+type Mailer interface {
+	Send(to []string, subject, body string) error
+}
+
+// Service sends email notifications to recipients subscribed to each event type
+type Service struct {
+	db        *db.DB
+	mailer    Mailer
+	idleAfter time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewService creates a notification service
+// idleAfter is how long a user question may stay unanswered before EventIdleQuestion fires
+func NewService(database *db.DB, mailer Mailer, idleAfter time.Duration) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		db:        database,
+		mailer:    mailer,
+		idleAfter: idleAfter,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start begins checking for idle conversations in the background
+func (s *Service) Start() {
+	s.wg.Add(1)
+	go s.run()
+	log.Printf("[Notify] Service started idle_after=%v", s.idleAfter)
+}
+
+// Stop stops the background checks
+func (s *Service) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Printf("[Notify] Service stopped")
+}
+
+// run is the main loop of the idle checker
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.CheckIdleQuestions(); err != nil {
+				log.Printf("[Notify] Idle check failed err=%v", err)
+			}
+		}
+	}
+}
+
+// send emails every recipient subscribed to the event type
+func (s *Service) send(eventType, subject, body string) error {
+	recipients, err := s.db.GetNotificationRecipients(eventType)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	if err := s.mailer.Send(recipients, subject, body); err != nil {
+		log.Printf("[Notify] Send failed event_type=%s recipients=%d err=%v", eventType, len(recipients), err)
+		return err
+	}
+
+	log.Printf("[Notify] Notification sent event_type=%s recipients=%d", eventType, len(recipients))
+	return nil
+}
+
+// NotifyDigest emails a generated digest (implements digest.Notifier)
+func (s *Service) NotifyDigest(d *digest.Digest) error {
+	subject := fmt.Sprintf("[Multi-Avatar Chat] Daily digest: %s", d.ConversationTitle)
+	return s.send(EventDigest, subject, d.Content)
+}
+
+// sendOnce sends a notification about a message unless it was already sent
+// The message is claimed first so concurrent callers send it once; a failed send releases
+// the claim so a later attempt can send it
+func (s *Service) sendOnce(eventType string, messageID int64, subject, body string) error {
+	claimed, err := s.db.MarkNotificationSent(eventType, messageID)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if err := s.send(eventType, subject, body); err != nil {
+		if releaseErr := s.db.ReleaseNotification(eventType, messageID); releaseErr != nil {
+			log.Printf("[Notify] Warning: failed to release notification event_type=%s message_id=%d err=%v",
+				eventType, messageID, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// NotifyAvatarMention emails recipients when an avatar addresses the user with an @mention
+func (s *Service) NotifyAvatarMention(conversationID int64, avatarName string, msg *models.Message) error {
+
+	title := fmt.Sprintf("#%d", conversationID)
+	if conv, err := s.db.GetConversation(conversationID); err == nil {
+		title = conv.Title
+	}
+
+	subject := fmt.Sprintf("[Multi-Avatar Chat] %s mentioned you in %s", avatarName, title)
+	body := fmt.Sprintf("%s wrote in \"%s\":\n\n%s", avatarName, title, msg.Content)
+	return s.sendOnce(EventAvatarMention, msg.ID, subject, body)
+}
+
+// CheckIdleQuestions notifies about conversations whose last message is a user question
+// that has stayed unanswered for longer than idleAfter
+func (s *Service) CheckIdleQuestions() error {
+	conversations, err := s.db.GetAllConversations()
+	if err != nil {
+		return err
+	}
+
+	for _, conv := range conversations {
+		msg, err := s.db.GetLastMessage(conv.ID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.SenderType != models.SenderTypeUser || !logic.IsQuestion(msg.Content) {
+			continue
+		}
+		if time.Since(msg.CreatedAt) < s.idleAfter {
+			continue
+		}
+
+		// A failed notification is sent again on a later check
+		subject := fmt.Sprintf("[Multi-Avatar Chat] Unanswered question in %s", conv.Title)
+		body := fmt.Sprintf("Your question in \"%s\" has not been answered for %v:\n\n%s",
+			conv.Title, s.idleAfter, msg.Content)
+		if err := s.sendOnce(EventIdleQuestion, msg.ID, subject, body); err != nil {
+			log.Printf("[Notify] Warning: idle question notification failed conversation_id=%d err=%v", conv.ID, err)
+		}
+	}
+
+	return nil
+}
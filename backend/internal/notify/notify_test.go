@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/digest"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// sentMail is an email captured by mockMailer
+type sentMail struct {
+	to      []string
+	subject string
+	body    string
+}
+
+// mockMailer records emails instead of sending them
+type mockMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+	// err fails every send while set
+	err error
+}
+
+func (m *mockMailer) Send(to []string, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+func TestIsValidEventType(t *testing.T) {
+	for _, eventType := range EventTypes {
+		if !IsValidEventType(eventType) {
+			t.Errorf("expected %q to be valid", eventType)
+		}
+	}
+	if IsValidEventType("unknown") {
+		t.Error("expected unknown event type to be invalid")
+	}
+}
+
+func TestNotifyDigest_OnlySubscribedRecipients(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	database.SetNotificationPreference("a@example.com", EventDigest, true)
+	database.SetNotificationPreference("b@example.com", EventDigest, false)
+	database.SetNotificationPreference("c@example.com", EventIdleQuestion, true)
+
+	mailer := &mockMailer{}
+	service := NewService(database, mailer, time.Minute)
+
+	err := service.NotifyDigest(&digest.Digest{ConversationTitle: "Planning", Content: "summary"})
+	if err != nil {
+		t.Fatalf("NotifyDigest failed: %v", err)
+	}
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(mailer.sent))
+	}
+	if len(mailer.sent[0].to) != 1 || mailer.sent[0].to[0] != "a@example.com" {
+		t.Errorf("unexpected recipients: %v", mailer.sent[0].to)
+	}
+	if !strings.Contains(mailer.sent[0].subject, "Planning") {
+		t.Errorf("expected subject to contain conversation title, got %q", mailer.sent[0].subject)
+	}
+}
+
+func TestNotifyAvatarMention_SentOnce(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	database.SetNotificationPreference("a@example.com", EventAvatarMention, true)
+	conv, _ := database.CreateConversation("Planning", "")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "@ユーザ what do you think?")
+
+	mailer := &mockMailer{}
+	service := NewService(database, mailer, time.Minute)
+
+	service.NotifyAvatarMention(conv.ID, "Alice", msg)
+	service.NotifyAvatarMention(conv.ID, "Alice", msg)
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(mailer.sent))
+	}
+	if !strings.Contains(mailer.sent[0].subject, "Alice mentioned you in Planning") {
+		t.Errorf("unexpected subject %q", mailer.sent[0].subject)
+	}
+}
+
+func TestNotifyAvatarMention_RetriedAfterFailedSend(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	database.SetNotificationPreference("a@example.com", EventAvatarMention, true)
+	conv, _ := database.CreateConversation("Planning", "")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeAvatar, nil, "@ユーザ what do you think?")
+
+	mailer := &mockMailer{err: errors.New("smtp unavailable")}
+	service := NewService(database, mailer, time.Minute)
+
+	if err := service.NotifyAvatarMention(conv.ID, "Alice", msg); err == nil {
+		t.Fatal("expected the failed send to be reported")
+	}
+
+	// The failed notification is not recorded as sent, so the next attempt sends it
+	mailer.err = nil
+	if err := service.NotifyAvatarMention(conv.ID, "Alice", msg); err != nil {
+		t.Fatalf("NotifyAvatarMention failed: %v", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Errorf("expected 1 email after the retry, got %d", len(mailer.sent))
+	}
+}
+
+func TestCheckIdleQuestions(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	database.SetNotificationPreference("a@example.com", EventIdleQuestion, true)
+
+	question, _ := database.CreateConversation("Question", "")
+	database.CreateMessage(question.ID, models.SenderTypeUser, nil, "Is anyone there?")
+
+	statement, _ := database.CreateConversation("Statement", "")
+	database.CreateMessage(statement.ID, models.SenderTypeUser, nil, "Thanks")
+
+	answered, _ := database.CreateConversation("Answered", "")
+	database.CreateMessage(answered.ID, models.SenderTypeUser, nil, "Is anyone there?")
+	database.CreateMessage(answered.ID, models.SenderTypeAvatar, nil, "Yes")
+
+	mailer := &mockMailer{}
+	// Negative threshold treats every question as idle
+	service := NewService(database, mailer, -time.Minute)
+
+	if err := service.CheckIdleQuestions(); err != nil {
+		t.Fatalf("CheckIdleQuestions failed: %v", err)
+	}
+	if err := service.CheckIdleQuestions(); err != nil {
+		t.Fatalf("CheckIdleQuestions failed: %v", err)
+	}
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(mailer.sent))
+	}
+	if !strings.Contains(mailer.sent[0].subject, "Question") {
+		t.Errorf("unexpected subject %q", mailer.sent[0].subject)
+	}
+}
+
+func TestCheckIdleQuestions_NotYetIdle(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	database.SetNotificationPreference("a@example.com", EventIdleQuestion, true)
+	conv, _ := database.CreateConversation("Question", "")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Is anyone there?")
+
+	mailer := &mockMailer{}
+	service := NewService(database, mailer, time.Hour)
+
+	if err := service.CheckIdleQuestions(); err != nil {
+		t.Fatalf("CheckIdleQuestions failed: %v", err)
+	}
+
+	if len(mailer.sent) != 0 {
+		t.Errorf("expected no email before idle threshold, got %d", len(mailer.sent))
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	msg := string(buildMessage("chat@example.com", []string{"a@example.com", "b@example.com"}, "日本語の件名", "line1\nline2"))
+
+	if !strings.Contains(msg, "To: a@example.com, b@example.com\r\n") {
+		t.Errorf("expected To header, got %q", msg)
+	}
+	if !strings.Contains(msg, "Subject: =?utf-8?q?") {
+		t.Errorf("expected encoded subject, got %q", msg)
+	}
+	if !strings.HasSuffix(msg, "\r\n\r\nline1\r\nline2") {
+		t.Errorf("expected CRLF body, got %q", msg)
+	}
+}
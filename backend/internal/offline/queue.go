@@ -0,0 +1,206 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+)
+
+// Queue holds user messages for avatar threads while the OpenAI API is unavailable
+// and replays them in order once it recovers
+// Queued forwards are stored in the database so they survive a restart
+type Queue struct {
+	db        *db.DB
+	assistant *assistant.Client
+	mu        sync.Mutex // serializes replays
+	timerMu   sync.Mutex
+	timer     *time.Timer
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewQueue creates a new offline queue
+// If assistantClient is nil, nothing is queued since nothing could replay it; forwards left
+// from an earlier run are kept for the next start with an API key
+func NewQueue(database *db.DB, assistantClient *assistant.Client) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		db:        database,
+		assistant: assistantClient,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start replays forwards left from a previous run and replays again whenever the circuit closes
+func (q *Queue) Start() {
+	if q.assistant != nil {
+		q.assistant.CircuitBreaker().OnStateChange(q.handleStateChange)
+	}
+	q.startReplay()
+	log.Printf("[OfflineQueue] Started")
+}
+
+// Stop cancels pending replays and waits for a running replay to finish
+func (q *Queue) Stop() {
+	q.timerMu.Lock()
+	q.cancel()
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+	q.timerMu.Unlock()
+
+	q.wg.Wait()
+	log.Printf("[OfflineQueue] Stopped")
+}
+
+// ShouldQueue reports whether messages for a conversation must be queued instead of sent
+// Messages are also queued while earlier ones are pending so delivery stays in order.
+// Without an assistant messages are never queued, since the queue would only grow
+func (q *Queue) ShouldQueue(conversationID int64) bool {
+	if q.assistant == nil {
+		return false
+	}
+	if q.assistant.CircuitOpen() {
+		return true
+	}
+
+	pending, err := q.db.HasOfflineForwards(conversationID)
+	if err != nil {
+		log.Printf("[OfflineQueue] Failed to check pending forwards conversation_id=%d err=%v", conversationID, err)
+		return false
+	}
+	return pending
+}
+
+// Enqueue stores a message for an avatar's thread until it can be delivered
+func (q *Queue) Enqueue(conversationID, messageID, avatarID int64, threadID, content string) error {
+	forward, err := q.db.EnqueueOfflineForward(conversationID, messageID, avatarID, threadID, content)
+	if err != nil {
+		return err
+	}
+	log.Printf("[OfflineQueue] Forward queued forward_id=%d conversation_id=%d avatar_id=%d",
+		forward.ID, conversationID, avatarID)
+	return nil
+}
+
+// handleStateChange schedules a replay when the circuit opens and replays immediately when it closes
+func (q *Queue) handleStateChange(open bool, retryAfter time.Duration) {
+	if open {
+		q.scheduleReplay(retryAfter)
+		return
+	}
+	q.startReplay()
+}
+
+// scheduleReplay starts a replay after the given delay
+// The replay acts as the trial call that closes the circuit again
+func (q *Queue) scheduleReplay(delay time.Duration) {
+	q.timerMu.Lock()
+	defer q.timerMu.Unlock()
+
+	if q.ctx.Err() != nil {
+		return
+	}
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+	q.timer = time.AfterFunc(delay, q.startReplay)
+}
+
+// startReplay runs a replay in the background
+func (q *Queue) startReplay() {
+	q.timerMu.Lock()
+	defer q.timerMu.Unlock()
+
+	if q.ctx.Err() != nil {
+		return
+	}
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		if _, err := q.Replay(); err != nil {
+			log.Printf("[OfflineQueue] Replay stopped err=%v", err)
+		}
+	}()
+}
+
+// Replay delivers queued forwards in the order they were queued
+// Forwards queued during the replay are delivered as well
+// Stops at the first failure caused by an outage and schedules another attempt after the cool-down
+func (q *Queue) Replay() (int, error) {
+	if q.assistant == nil {
+		return 0, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delivered := 0
+	for {
+		forwards, err := q.db.GetOfflineForwards()
+		if err != nil {
+			return delivered, err
+		}
+		if len(forwards) == 0 {
+			break
+		}
+
+		log.Printf("[OfflineQueue] Replay started pending=%d", len(forwards))
+
+		for _, forward := range forwards {
+			if q.ctx.Err() != nil {
+				return delivered, q.ctx.Err()
+			}
+			if q.assistant.CircuitOpen() {
+				q.scheduleReplay(q.assistant.CircuitBreaker().CoolDown())
+				return delivered, assistant.ErrCircuitOpen
+			}
+
+			client := q.clientFor(forward.ConversationID)
+			if err := client.WaitForActiveRunsToComplete(forward.ThreadID, client.ActiveRunTimeout()); err != nil {
+				log.Printf("[OfflineQueue] Warning: timeout waiting for active runs thread_id=%s err=%v", forward.ThreadID, err)
+			}
+
+			// A forward replayed again after a crash is skipped as a duplicate
+			if _, err := q.assistant.ForwardQueue().AddOnce(client, forward.ThreadID, forward.MessageID, forward.Content); err != nil {
+				// A rate limit is temporary, so the forward is kept for the next attempt
+				if q.assistant.CircuitOpen() || errors.Is(err, assistant.ErrRateLimited) {
+					q.scheduleReplay(q.assistant.CircuitBreaker().CoolDown())
+					return delivered, err
+				}
+				// The API is reachable but rejected the message (e.g. the thread was deleted)
+				log.Printf("[OfflineQueue] Dropping undeliverable forward forward_id=%d thread_id=%s err=%v",
+					forward.ID, forward.ThreadID, err)
+			} else {
+				delivered++
+			}
+
+			if err := q.db.DeleteOfflineForward(forward.ID); err != nil {
+				return delivered, err
+			}
+		}
+	}
+
+	if delivered > 0 {
+		log.Printf("[OfflineQueue] Replay completed delivered=%d", delivered)
+	}
+	return delivered, nil
+}
+
+// clientFor returns the assistant client routed to the OpenAI organization and project of a conversation
+// The server's defaults are used when the conversation cannot be read
+func (q *Queue) clientFor(conversationID int64) *assistant.Client {
+	conv, err := q.db.GetConversation(conversationID)
+	if err != nil {
+		return q.assistant
+	}
+	return q.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+}
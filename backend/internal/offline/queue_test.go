@@ -0,0 +1,248 @@
+package offline
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// fakeOpenAI records messages added to threads and answers message creation with the given status
+type fakeOpenAI struct {
+	mu       sync.Mutex
+	status   int
+	messages []string
+}
+
+func (f *fakeOpenAI) setStatus(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+func (f *fakeOpenAI) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...)
+}
+
+func (f *fakeOpenAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs"):
+		w.Write([]byte(`{"data": []}`))
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages"):
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.status != http.StatusOK {
+			w.WriteHeader(f.status)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.messages = append(f.messages, string(body))
+		w.Write([]byte(`{"id": "msg_1", "role": "user"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestClient(t *testing.T, fake *fakeOpenAI) *assistant.Client {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-api-key",
+		assistant.WithCircuitBreaker(1, time.Hour),
+		assistant.WithHTTPClient(&http.Client{
+			Transport: &testutil.RedirectTransport{BaseURL: server.URL},
+		}))
+}
+
+// queueMessages stores user messages and queues them for the avatar's thread
+func queueMessages(t *testing.T, database *db.DB, q *Queue, contents ...string) int64 {
+	t.Helper()
+
+	conv, _ := database.CreateConversation("Outage", "")
+	avatar, _ := database.CreateAvatar("Bot", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+
+	for _, content := range contents {
+		msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, content)
+		if err := q.Enqueue(conv.ID, msg.ID, avatar.ID, "thread_1", content); err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+	}
+	return conv.ID
+}
+
+func TestShouldQueue(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	fake := &fakeOpenAI{status: http.StatusOK}
+	client := newTestClient(t, fake)
+	q := NewQueue(database, client)
+
+	convID := queueMessages(t, database, q)
+	if q.ShouldQueue(convID) {
+		t.Error("expected messages to be sent while the API is available")
+	}
+
+	client.CircuitBreaker().RecordFailure()
+	if !q.ShouldQueue(convID) {
+		t.Error("expected messages to be queued while the circuit is open")
+	}
+}
+
+func TestShouldQueue_NoAssistant(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	// Forwards left from a run with an API key stay for the next one, but nothing new is queued behind them
+	q := NewQueue(database, nil)
+	convID := queueMessages(t, database, q, "first")
+	if q.ShouldQueue(convID) {
+		t.Error("expected messages not to be queued without an assistant, since nothing replays them")
+	}
+	if delivered, err := q.Replay(); err != nil || delivered != 0 {
+		t.Errorf("expected nothing to be replayed without an assistant, got %d err=%v", delivered, err)
+	}
+}
+
+func TestShouldQueue_PendingForwardsKeepOrder(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	fake := &fakeOpenAI{status: http.StatusOK}
+	q := NewQueue(database, newTestClient(t, fake))
+
+	convID := queueMessages(t, database, q, "first")
+	if !q.ShouldQueue(convID) {
+		t.Error("expected new messages to be queued behind pending ones")
+	}
+}
+
+func TestReplay_DeliversInOrder(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	fake := &fakeOpenAI{status: http.StatusOK}
+	q := NewQueue(database, newTestClient(t, fake))
+	convID := queueMessages(t, database, q, "first", "second", "third")
+
+	delivered, err := q.Replay()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if delivered != 3 {
+		t.Errorf("expected 3 delivered, got %d", delivered)
+	}
+
+	received := fake.received()
+	if len(received) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(received))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if !strings.Contains(received[i], want) {
+			t.Errorf("message %d: expected %q, got %s", i, want, received[i])
+		}
+	}
+
+	if pending, _ := database.HasOfflineForwards(convID); pending {
+		t.Error("expected queue to be empty after replay")
+	}
+}
+
+func TestReplay_StopsWhileProviderDown(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	fake := &fakeOpenAI{status: http.StatusServiceUnavailable}
+	client := newTestClient(t, fake)
+	q := NewQueue(database, client)
+	defer q.Stop()
+	convID := queueMessages(t, database, q, "first", "second")
+
+	if _, err := q.Replay(); err == nil {
+		t.Fatal("expected replay to fail while the provider is down")
+	}
+	if !client.CircuitOpen() {
+		t.Error("expected circuit to be open")
+	}
+
+	forwards, _ := database.GetOfflineForwards()
+	if len(forwards) != 2 {
+		t.Errorf("expected both forwards to stay queued, got %d", len(forwards))
+	}
+
+	// Recovery replays the remaining forwards
+	fake.setStatus(http.StatusOK)
+	q.Start()
+	client.CircuitBreaker().RecordSuccess()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if pending, _ := database.HasOfflineForwards(convID); !pending {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(fake.received()) != 2 {
+		t.Errorf("expected 2 messages after recovery, got %d", len(fake.received()))
+	}
+}
+
+func TestReplay_DropsRejectedForwards(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	fake := &fakeOpenAI{status: http.StatusBadRequest}
+	q := NewQueue(database, newTestClient(t, fake))
+	queueMessages(t, database, q, "first")
+
+	delivered, err := q.Replay()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if delivered != 0 {
+		t.Errorf("expected nothing delivered, got %d", delivered)
+	}
+
+	forwards, _ := database.GetOfflineForwards()
+	if len(forwards) != 0 {
+		t.Errorf("expected rejected forward to be dropped, got %d", len(forwards))
+	}
+}
+
+func TestReplay_KeepsRateLimitedForwards(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	fake := &fakeOpenAI{status: http.StatusTooManyRequests}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := assistant.NewClient("test-api-key",
+		assistant.WithCircuitBreaker(5, time.Hour),
+		assistant.WithHTTPClient(&http.Client{
+			Transport: &testutil.RedirectTransport{BaseURL: server.URL},
+		}))
+	q := NewQueue(database, client)
+	defer q.Stop()
+	queueMessages(t, database, q, "first", "second")
+
+	if _, err := q.Replay(); !errors.Is(err, assistant.ErrRateLimited) {
+		t.Fatalf("expected replay to stop on the rate limit, got %v", err)
+	}
+	if client.CircuitOpen() {
+		t.Fatal("expected a single rate limit not to open the circuit")
+	}
+
+	forwards, _ := database.GetOfflineForwards()
+	if len(forwards) != 2 {
+		t.Errorf("expected rate limited forwards to stay queued, got %d", len(forwards))
+	}
+}
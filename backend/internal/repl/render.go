@@ -0,0 +1,67 @@
+package repl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-avatar-chat/internal/logic"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+)
+
+// userName is how the REPL user's own messages are labelled
+const userName = "You"
+
+// line is a chat message ready to be rendered
+type line struct {
+	At      time.Time
+	Emoji   string
+	Name    string
+	Color   string
+	Content string
+	// System marks messages posted by the server, such as digests
+	System bool
+}
+
+// colorize wraps text in the 24-bit ANSI foreground color of a #RRGGBB value
+// Text is returned unchanged for invalid colors
+func colorize(hex, text string) string {
+	if !logic.IsValidAvatarColor(hex) {
+		return text
+	}
+	rgb, _ := strconv.ParseUint(hex[1:], 16, 32)
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s%s", rgb>>16, (rgb>>8)&0xFF, rgb&0xFF, text, ansiReset)
+}
+
+// render formats a message as "[15:04] 💡 Alice: content"
+// Continuation lines of multi-line content are indented under the first line
+func render(l line, color bool) string {
+	label := l.Name
+	if l.Emoji != "" {
+		label = l.Emoji + " " + label
+	}
+	if color {
+		switch {
+		case l.System:
+			label = ansiDim + label + ansiReset
+		case l.Color != "":
+			label = ansiBold + colorize(l.Color, label)
+		default:
+			label = ansiBold + label + ansiReset
+		}
+	}
+
+	prefix := ""
+	if !l.At.IsZero() {
+		prefix = "[" + l.At.Local().Format("15:04") + "] "
+	}
+
+	content := strings.ReplaceAll(strings.TrimRight(l.Content, "\n"), "\n", "\n    ")
+	return prefix + label + ": " + content
+}
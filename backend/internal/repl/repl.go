@@ -0,0 +1,230 @@
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/api"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+// historyLimit is the number of past messages shown when the REPL starts
+const historyLimit = 20
+
+// ErrNoConversations is returned when there is no conversation to chat in
+var ErrNoConversations = errors.New("no conversations (create one in the web UI or run --seed demo)")
+
+// MessageSender posts user messages to a conversation
+type MessageSender interface {
+	SendUserMessage(ctx context.Context, conversationID int64, content string) (*models.Message, error)
+}
+
+// REPL is a terminal chat bound to one conversation
+// User input is posted through the same code path as the HTTP API and avatar
+// responses are printed as they are broadcast to SSE clients
+type REPL struct {
+	db          *db.DB
+	sender      MessageSender
+	broadcaster *api.EventBroadcaster
+	in          *bufio.Scanner
+	color       bool
+
+	mu  sync.Mutex // serializes writes from the input loop and the event printer
+	out io.Writer
+}
+
+// New creates a REPL reading commands from in and writing the chat to out
+func New(database *db.DB, sender MessageSender, broadcaster *api.EventBroadcaster, in io.Reader, out io.Writer) *REPL {
+	return &REPL{
+		db:          database,
+		sender:      sender,
+		broadcaster: broadcaster,
+		in:          bufio.NewScanner(in),
+		out:         out,
+	}
+}
+
+// SetColor enables ANSI colors for avatar names
+func (r *REPL) SetColor(enabled bool) {
+	r.color = enabled
+}
+
+// Run chats in a conversation until the input ends or /quit is entered
+// When conversationID is 0 the user picks a conversation from a list
+func (r *REPL) Run(ctx context.Context, conversationID int64) error {
+	if conversationID == 0 {
+		id, err := r.chooseConversation()
+		if err != nil {
+			return err
+		}
+		conversationID = id
+	}
+
+	conv, err := r.db.GetConversation(conversationID)
+	if err != nil {
+		return fmt.Errorf("conversation %d: %w", conversationID, err)
+	}
+
+	events := r.broadcaster.Subscribe(conversationID, "message")
+	if events == nil {
+		return errors.New("event broadcaster is shut down")
+	}
+	defer r.broadcaster.Unsubscribe(conversationID, events)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.printEvents(ctx, events)
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	r.printf("== %s (conversation #%d) ==\n", conv.Title, conv.ID)
+	r.printf("Type a message and press Enter. /help lists commands.\n\n")
+	if err := r.printHistory(conversationID, historyLimit); err != nil {
+		return err
+	}
+
+	for r.in.Scan() {
+		input := strings.TrimSpace(r.in.Text())
+		switch {
+		case input == "":
+			continue
+		case input == "/quit" || input == "/exit":
+			return nil
+		case input == "/help":
+			r.printf("/history  show the last %d messages\n/quit     leave the chat\n", historyLimit)
+		case input == "/history":
+			if err := r.printHistory(conversationID, historyLimit); err != nil {
+				r.printf("error: %v\n", err)
+			}
+		case strings.HasPrefix(input, "/"):
+			r.printf("unknown command %s (/help lists commands)\n", input)
+		default:
+			if _, err := r.sender.SendUserMessage(ctx, conversationID, input); err != nil {
+				r.printf("error: failed to send message: %v\n", err)
+			}
+		}
+	}
+	return r.in.Err()
+}
+
+// chooseConversation lists the conversations and reads the chosen ID
+func (r *REPL) chooseConversation() (int64, error) {
+	conversations, err := r.db.GetAllConversations()
+	if err != nil {
+		return 0, err
+	}
+	if len(conversations) == 0 {
+		return 0, ErrNoConversations
+	}
+
+	r.printf("Conversations:\n")
+	for _, c := range conversations {
+		r.printf("  %d) %s\n", c.ID, c.Title)
+	}
+
+	for {
+		r.printf("Conversation ID: ")
+		if !r.in.Scan() {
+			if err := r.in.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(r.in.Text()), 10, 64)
+		if err != nil {
+			r.printf("enter one of the IDs above\n")
+			continue
+		}
+		for _, c := range conversations {
+			if c.ID == id {
+				return id, nil
+			}
+		}
+		r.printf("no conversation with ID %d\n", id)
+	}
+}
+
+// printHistory prints the last limit messages of the conversation
+func (r *REPL) printHistory(conversationID int64, limit int) error {
+	messages, err := r.db.GetMessages(conversationID)
+	if err != nil {
+		return err
+	}
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+
+	avatars, err := r.db.GetAllAvatars()
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*models.Avatar)
+	for i := range avatars {
+		byID[avatars[i].ID] = &avatars[i]
+	}
+
+	for _, msg := range messages {
+		l := line{At: msg.CreatedAt, Name: userName, Content: msg.Content}
+		switch msg.SenderType {
+		case models.SenderTypeAvatar:
+			l.Name = "Avatar"
+			if msg.SenderID != nil {
+				if avatar := byID[*msg.SenderID]; avatar != nil {
+					l.Name, l.Color, l.Emoji = avatar.Name, avatar.Color, avatar.Emoji
+				}
+			}
+		case models.SenderTypeSystem:
+			l.Name, l.System = "System", true
+		}
+		r.printf("%s\n", render(l, r.color))
+	}
+	return nil
+}
+
+// printEvents prints avatar and system messages as they are broadcast
+func (r *REPL) printEvents(ctx context.Context, events chan api.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			msg, ok := event.Data.(models.MessageEvent)
+			if event.Type != models.EventTypeMessage || !ok || msg.SenderType == string(models.SenderTypeUser) {
+				continue
+			}
+
+			l := line{Name: msg.SenderName, Color: msg.SenderColor, Emoji: msg.SenderEmoji, Content: msg.Content}
+			l.At, _ = time.Parse(time.RFC3339, msg.CreatedAt)
+			if msg.SenderType == string(models.SenderTypeSystem) {
+				l.System = true
+				if l.Name == "" {
+					l.Name = "System"
+				}
+			}
+			r.printf("%s\n", render(l, r.color))
+		}
+	}
+}
+
+// printf writes to the output, keeping lines from the two goroutines apart
+func (r *REPL) printf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, format, args...)
+}
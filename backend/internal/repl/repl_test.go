@@ -0,0 +1,173 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/api"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// syncBuffer is a bytes.Buffer safe for the REPL's two writers and the test reader
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// echoSender saves the user message and answers with a broadcast avatar message
+type echoSender struct {
+	db          *db.DB
+	broadcaster *api.EventBroadcaster
+	avatar      *models.Avatar
+}
+
+func (s *echoSender) SendUserMessage(ctx context.Context, conversationID int64, content string) (*models.Message, error) {
+	msg, err := s.db.CreateMessage(conversationID, models.SenderTypeUser, nil, content)
+	if err != nil {
+		return nil, err
+	}
+	s.broadcaster.BroadcastMessage(conversationID, models.MessageEvent{
+		SenderType:  string(models.SenderTypeAvatar),
+		SenderName:  s.avatar.Name,
+		SenderColor: s.avatar.Color,
+		SenderEmoji: s.avatar.Emoji,
+		Content:     "echo: " + content,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	})
+	return msg, nil
+}
+
+func waitForOutput(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("output does not contain %q:\n%s", want, out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRun_SendsInputAndPrintsResponses(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, err := database.CreateAvatar("Alice", "prompt", "")
+	if err != nil {
+		t.Fatalf("failed to create avatar: %v", err)
+	}
+	avatar.Emoji = "💡"
+	conv, err := database.CreateConversation("REPL test", "")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	if _, err := database.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatar.ID, "earlier reply"); err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+
+	broadcaster := api.NewEventBroadcaster()
+	sender := &echoSender{db: database, broadcaster: broadcaster, avatar: avatar}
+	in, input := io.Pipe()
+	out := &syncBuffer{}
+
+	r := New(database, sender, broadcaster, in, out)
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background(), conv.ID) }()
+
+	waitForOutput(t, out, "Alice: earlier reply")
+
+	io.WriteString(input, "hello\n")
+	waitForOutput(t, out, "💡 Alice: echo: hello")
+
+	io.WriteString(input, "/quit\n")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after /quit")
+	}
+
+	messages, err := database.GetMessages(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to get messages: %v", err)
+	}
+	if len(messages) != 2 || messages[1].Content != "hello" {
+		t.Errorf("expected the input to be saved as a user message, got %+v", messages)
+	}
+}
+
+func TestRun_ChoosesConversation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, err := database.CreateConversation("Pick me", "")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	if conv.ID != 1 {
+		t.Fatalf("expected conversation ID 1, got %d", conv.ID)
+	}
+
+	out := &syncBuffer{}
+	input := "abc\n999\n1\n"
+	r := New(database, &echoSender{}, api.NewEventBroadcaster(), strings.NewReader(input), out)
+
+	if err := r.Run(context.Background(), 0); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"1) Pick me", "enter one of the IDs above", "no conversation with ID 999", "== Pick me (conversation #1) =="} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRun_NoConversations(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	r := New(database, &echoSender{}, api.NewEventBroadcaster(), strings.NewReader(""), io.Discard)
+	if err := r.Run(context.Background(), 0); err != ErrNoConversations {
+		t.Errorf("expected ErrNoConversations, got %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	at := time.Date(2024, 1, 1, 9, 5, 0, 0, time.Local)
+
+	got := render(line{At: at, Emoji: "💡", Name: "Alice", Color: "#FF8000", Content: "one\ntwo"}, false)
+	if want := "[09:05] 💡 Alice: one\n    two"; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	colored := render(line{Name: "Alice", Color: "#FF8000", Content: "hi"}, true)
+	if !strings.Contains(colored, "\x1b[38;2;255;128;0mAlice") {
+		t.Errorf("expected 24-bit color for Alice, got %q", colored)
+	}
+
+	invalid := render(line{Name: "Bob", Color: "red", Content: "hi"}, true)
+	if strings.Contains(invalid, "38;2") {
+		t.Errorf("expected no color for invalid value, got %q", invalid)
+	}
+}
@@ -0,0 +1,69 @@
+// Package script runs avatar behavior scripts with the Starlark interpreter
+package script
+
+import (
+	"errors"
+	"fmt"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// MaxSteps bounds the interpreter steps of a single run, so a script cannot hang the watcher
+const MaxSteps = 100000
+
+// filename is the name scripts are compiled under, shown in syntax errors
+const filename = "script"
+
+// fileOptions allow if and for at the top level and reassigning globals, as scripts have no functions of their own
+// while loops, recursion and sets stay disabled; load() fails because runs have no loader
+var fileOptions = &syntax.FileOptions{
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// Program is a compiled script that can be run any number of times
+type Program struct {
+	program *starlark.Program
+}
+
+// Compile parses a script and resolves its names against the given predeclared names and the built-in functions
+// Syntax errors and undefined names are reported with their line
+func Compile(src string, predeclared ...string) (*Program, error) {
+	names := make(map[string]bool, len(predeclared))
+	for _, name := range predeclared {
+		names[name] = true
+	}
+
+	_, program, err := starlark.SourceProgramOptions(fileOptions, filename, src, func(name string) bool { return names[name] })
+	if err != nil {
+		return nil, err
+	}
+	return &Program{program: program}, nil
+}
+
+// Run executes the program with values for the predeclared names it was compiled with
+// Runtime errors are returned with the line they happened on
+func (p *Program) Run(predeclared map[string]Value) error {
+	thread := &starlark.Thread{Name: filename, Print: func(*starlark.Thread, string) {}}
+	thread.SetMaxExecutionSteps(MaxSteps)
+
+	if _, err := p.program.Init(thread, starlark.StringDict(predeclared)); err != nil {
+		return runError(err)
+	}
+	return nil
+}
+
+// runError prefixes an evaluation error with the innermost line of the script it happened on
+func runError(err error) error {
+	var evalErr *starlark.EvalError
+	if !errors.As(err, &evalErr) {
+		return err
+	}
+	for i := len(evalErr.CallStack) - 1; i >= 0; i-- {
+		if pos := evalErr.CallStack[i].Pos; pos.Line > 0 && pos.Filename() == filename {
+			return fmt.Errorf("line %d: %s", pos.Line, evalErr.Msg)
+		}
+	}
+	return errors.New(evalErr.Msg)
+}
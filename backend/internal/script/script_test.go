@@ -0,0 +1,128 @@
+package script
+
+import (
+	"strings"
+	"testing"
+)
+
+// run executes src and returns the value passed to emit
+func run(t *testing.T, src string) Value {
+	t.Helper()
+
+	program, err := Compile(src, "emit")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	var result Value
+	err = program.Run(map[string]Value{
+		"emit": NewBuiltin("emit", func(args []Value) (Value, error) {
+			result = args[0]
+			return nil, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	return result
+}
+
+func TestRun(t *testing.T) {
+	src := `
+words = "the quick brown fox".split()
+counts = {}
+for w in words:
+    if len(w) > 3:
+        counts[w] = len(w)
+emit(counts)
+`
+	got := ToGo(run(t, src)).(map[string]any)
+	if len(got) != 2 || got["quick"] != int64(5) || got["brown"] != int64(5) {
+		t.Errorf("unexpected result: %+v", got)
+	}
+
+	if got := String(run(t, `emit(", ".join(["a", "b"]))`)); got != "a, b" {
+		t.Errorf("expected a, b, got %s", got)
+	}
+}
+
+func TestRun_Structs(t *testing.T) {
+	program, err := Compile(`emit(message.content.upper())`, "message", "emit")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	var got Value
+	err = program.Run(map[string]Value{
+		"message": NewStruct("message", map[string]Value{"content": FromGo("hi")}),
+		"emit": NewBuiltin("emit", func(args []Value) (Value, error) {
+			got = args[0]
+			return nil, nil
+		}),
+	})
+	if err != nil || String(got) != "HI" {
+		t.Fatalf("expected HI, got %v (%v)", got, err)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"x = 1\nif x\n    pass", "script:3:1: got newline, want ':'"},
+		{"undefined_name", "undefined: undefined_name"},
+		{"while True:\n    pass", "does not support while loops"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Compile(tt.src); err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%q: expected error %q, got %v", tt.src, tt.msg, err)
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"x = 1\ny = x + 'a'", "line 2: unknown binary op: int + string"},
+		{"{}['missing']", `key "missing" not in dict`},
+		{`load("other.star", "x")`, "load not implemented"},
+		{"emit(limit=1)", "emit: unexpected keyword arguments"},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(tt.src, "emit")
+		if err != nil {
+			t.Fatalf("%q: compile failed: %v", tt.src, err)
+		}
+		emit := NewBuiltin("emit", func(args []Value) (Value, error) { return nil, nil })
+		if err := program.Run(map[string]Value{"emit": emit}); err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%q: expected error %q, got %v", tt.src, tt.msg, err)
+		}
+	}
+}
+
+func TestRun_StepLimit(t *testing.T) {
+	program, err := Compile(`
+for i in range(1000):
+    for j in range(1000):
+        pass
+`)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if err := program.Run(nil); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Fatalf("expected the step limit to stop the script, got %v", err)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	value := FromGo(map[string]any{"n": 1, "tags": []string{"a"}, "none": nil})
+
+	got := ToGo(value).(map[string]any)
+	if got["n"] != int64(1) || got["tags"].([]any)[0] != "a" || got["none"] != nil {
+		t.Errorf("unexpected conversion: %+v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package script
+
+import (
+	"fmt"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Value is a Starlark value
+type Value = starlark.Value
+
+// NewBuiltin returns a function value that scripts can call with positional arguments
+// A nil result is returned to the script as None
+func NewBuiltin(name string, fn func(args []Value) (Value, error)) Value {
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(kwargs) > 0 {
+			return nil, fmt.Errorf("%s: unexpected keyword arguments", b.Name())
+		}
+		result, err := fn(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+		if result == nil {
+			return starlark.None, nil
+		}
+		return result, nil
+	})
+}
+
+// NewStruct returns a read-only value with named fields, such as the message passed to a script
+func NewStruct(name string, fields map[string]Value) Value {
+	return starlarkstruct.FromStringDict(starlark.String(name), starlark.StringDict(fields))
+}
+
+// AsString returns the Go string of a script string
+func AsString(v Value) (string, bool) {
+	s, ok := v.(starlark.String)
+	return string(s), ok
+}
+
+// String returns the value as str() would
+func String(v Value) string {
+	if s, ok := AsString(v); ok {
+		return s
+	}
+	return v.String()
+}
+
+// TypeName returns the Starlark type name of a value
+func TypeName(v Value) string {
+	return v.Type()
+}
+
+// FromGo converts Go values such as decoded JSON into script values
+// Unsupported types become their fmt string
+func FromGo(v any) Value {
+	switch x := v.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(x)
+	case int:
+		return starlark.MakeInt(x)
+	case int64:
+		return starlark.MakeInt64(x)
+	case float64:
+		return starlark.Float(x)
+	case string:
+		return starlark.String(x)
+	case []string:
+		elems := make([]Value, len(x))
+		for i, s := range x {
+			elems[i] = starlark.String(s)
+		}
+		return starlark.NewList(elems)
+	case []any:
+		elems := make([]Value, len(x))
+		for i, e := range x {
+			elems[i] = FromGo(e)
+		}
+		return starlark.NewList(elems)
+	case []Value:
+		return starlark.NewList(x)
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(x))
+		for _, k := range keys {
+			dict.SetKey(starlark.String(k), FromGo(x[k]))
+		}
+		return dict
+	case Value:
+		return x
+	}
+	return starlark.String(fmt.Sprint(v))
+}
+
+// ToGo converts a script value into plain Go values that encode to JSON
+// Dict keys are converted with str(); structs become objects of their fields
+func ToGo(v Value) any {
+	switch x := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(x)
+	case starlark.Int:
+		if n, ok := x.Int64(); ok {
+			return n
+		}
+		return x.String()
+	case starlark.Float:
+		return float64(x)
+	case starlark.String:
+		return string(x)
+	case *starlark.List:
+		out := make([]any, x.Len())
+		for i := range out {
+			out[i] = ToGo(x.Index(i))
+		}
+		return out
+	case starlark.Tuple:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = ToGo(e)
+		}
+		return out
+	case *starlark.Dict:
+		out := make(map[string]any, x.Len())
+		for _, item := range x.Items() {
+			out[String(item[0])] = ToGo(item[1])
+		}
+		return out
+	case *starlarkstruct.Struct:
+		out := make(map[string]any)
+		for _, name := range x.AttrNames() {
+			field, _ := x.Attr(name)
+			out[name] = ToGo(field)
+		}
+		return out
+	}
+	return v.String()
+}
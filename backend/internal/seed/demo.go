@@ -0,0 +1,41 @@
+package seed
+
+// demoDataset is a small product team discussing a feature, for local development and E2E tests
+var demoDataset = Dataset{
+	Avatars: []Avatar{
+		{
+			Name:     "Alice",
+			Prompt:   "あなたは前向きな企画担当のAliceです。新しいアイデアを積極的に提案し、ユーザの目線で価値を説明します。回答は簡潔にしてください。",
+			Color:    "#F59E0B",
+			Emoji:    "💡",
+			Keywords: []string{"企画", "アイデア", "ユーザ"},
+		},
+		{
+			Name:     "Bob",
+			Prompt:   "あなたは慎重なエンジニアのBobです。実装の難しさ、リスク、必要な工数を具体的に指摘します。回答は簡潔にしてください。",
+			Color:    "#3B82F6",
+			Emoji:    "🛠️",
+			Keywords: []string{"実装", "工数", "リスク"},
+		},
+		{
+			Name:     "Carol",
+			Prompt:   "あなたは辛口のレビュアーのCarolです。議論の抜け漏れや矛盾を指摘し、論点を整理します。回答は簡潔にしてください。",
+			Color:    "#EF4444",
+			Emoji:    "🧐",
+			Keywords: []string{"レビュー", "論点", "懸念"},
+		},
+	},
+	Conversations: []Conversation{
+		{
+			Title:   "Demo: ダークモードの検討",
+			Avatars: []string{"Alice", "Bob", "Carol"},
+			Messages: []Message{
+				{Content: "アプリにダークモードを追加したいと考えています。みなさんの意見を聞かせてください。"},
+				{Sender: "Alice", Content: "夜に使うユーザが多いので喜ばれると思います！OSの設定に合わせて自動で切り替わると便利です。"},
+				{Sender: "Bob", Content: "CSS変数で色を管理すれば実装は2〜3日です。ただ、アバターの色が暗い背景で見にくくならないか確認が必要です。"},
+				{Sender: "Carol", Content: "論点は3つです。自動切り替えの有無、アバター色のコントラスト、そして設定を保存する場所。最後の点がまだ決まっていません。"},
+				{Content: "設定はブラウザに保存する形で進めましょう。@Bob 見積もりをもう少し詳しくお願いします。"},
+			},
+		},
+	},
+}
@@ -0,0 +1,239 @@
+package seed
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// maxHistoryLength keeps the history seeded into avatar threads below
+// the OpenAI limit for the content of a single message
+const maxHistoryLength = 200000
+
+// Avatar describes a seeded avatar
+type Avatar struct {
+	Name     string
+	Prompt   string
+	Color    string
+	Emoji    string
+	Keywords []string
+}
+
+// Message is a line of seeded history; an empty Sender is the user
+type Message struct {
+	Sender  string
+	Content string
+}
+
+// Conversation describes a seeded conversation with its participants and history
+type Conversation struct {
+	Title    string
+	Avatars  []string
+	Messages []Message
+}
+
+// Dataset is a named set of avatars and conversations
+type Dataset struct {
+	Avatars       []Avatar
+	Conversations []Conversation
+}
+
+// Result reports what a seed run created
+type Result struct {
+	AvatarsCreated       int
+	AvatarsSkipped       int
+	ConversationsCreated int
+	ConversationsSkipped int
+}
+
+// datasets are the datasets available to Run
+var datasets = map[string]Dataset{
+	"demo": demoDataset,
+}
+
+// Names returns the names of the available datasets
+func Names() []string {
+	names := make([]string, 0, len(datasets))
+	for name := range datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run populates the database with a dataset
+// Avatars and conversations that already exist (by name and title) are left untouched,
+// so running the same seed twice is safe. With an OpenAI client, avatars get assistants and
+// each participant gets a thread seeded with the conversation history. instructions builds the assistants' instructions
+func Run(database *db.DB, client *assistant.Client, instructions logic.InstructionsBuilder, name string) (*Result, error) {
+	dataset, ok := datasets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown seed %q (available: %v)", name, Names())
+	}
+
+	log.Printf("[Seed] Seeding started seed=%s openai=%t", name, client != nil)
+
+	existing, err := database.GetAllAvatars()
+	if err != nil {
+		return nil, err
+	}
+	// Avatars are keyed by normalized name, which they are unique by
+	avatars := make(map[string]*models.Avatar)
+	for i := range existing {
+		avatars[logic.NormalizeAvatarName(existing[i].Name)] = &existing[i]
+	}
+
+	result := &Result{}
+	for _, a := range dataset.Avatars {
+		if avatars[logic.NormalizeAvatarName(a.Name)] != nil {
+			result.AvatarsSkipped++
+			continue
+		}
+		avatar, err := createAvatar(database, client, instructions, a)
+		if err != nil {
+			return result, fmt.Errorf("create avatar %q: %w", a.Name, err)
+		}
+		avatars[logic.NormalizeAvatarName(a.Name)] = avatar
+		result.AvatarsCreated++
+	}
+
+	conversations, err := database.GetAllConversations()
+	if err != nil {
+		return result, err
+	}
+	titles := make(map[string]bool)
+	for _, c := range conversations {
+		titles[c.Title] = true
+	}
+
+	for _, c := range dataset.Conversations {
+		if titles[c.Title] {
+			result.ConversationsSkipped++
+			continue
+		}
+		if err := createConversation(database, client, c, avatars); err != nil {
+			return result, fmt.Errorf("create conversation %q: %w", c.Title, err)
+		}
+		result.ConversationsCreated++
+	}
+
+	log.Printf("[Seed] Seeding completed seed=%s avatars_created=%d avatars_skipped=%d conversations_created=%d conversations_skipped=%d",
+		name, result.AvatarsCreated, result.AvatarsSkipped, result.ConversationsCreated, result.ConversationsSkipped)
+	return result, nil
+}
+
+// createAvatar creates an avatar and, with an OpenAI client, its assistant
+func createAvatar(database *db.DB, client *assistant.Client, instructions logic.InstructionsBuilder, a Avatar) (*models.Avatar, error) {
+	var assistantID string
+	if client != nil {
+		created, err := client.CreateAssistant(a.Name, instructions.AssistantInstructions(a.Prompt))
+		if err != nil {
+			return nil, err
+		}
+		assistantID = created.ID
+	}
+
+	avatar, err := database.CreateAvatar(a.Name, a.Prompt, assistantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if assistantID != "" {
+		if _, err := client.UpdateAssistantMetadata(assistantID, assistant.AvatarMetadata(avatar.ID)); err != nil {
+			log.Printf("[Seed] Warning: failed to tag assistant assistant_id=%s avatar_id=%d err=%v", assistantID, avatar.ID, err)
+		}
+	}
+
+	if a.Color != "" || a.Emoji != "" {
+		if a.Color != "" {
+			avatar.Color = a.Color
+		}
+		if a.Emoji != "" {
+			avatar.Emoji = a.Emoji
+		}
+		if err := database.UpdateAvatarDisplay(avatar.ID, avatar.Color, avatar.Emoji); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.Keywords) > 0 {
+		if err := database.UpdateAvatarPrefilter(avatar.ID, a.Keywords, avatar.RelevanceThreshold); err != nil {
+			return nil, err
+		}
+		avatar.Keywords = a.Keywords
+	}
+
+	log.Printf("[Seed] Avatar created avatar_id=%d name=%q assistant_id=%s", avatar.ID, avatar.Name, assistantID)
+	return avatar, nil
+}
+
+// createConversation creates a conversation with its history, one minute between messages
+func createConversation(database *db.DB, client *assistant.Client, c Conversation, avatars map[string]*models.Avatar) error {
+	start := time.Now().Add(-time.Duration(len(c.Messages)) * time.Minute)
+
+	var messages []models.Message
+	var history []logic.MessageForFormat
+	for i, m := range c.Messages {
+		msg := models.Message{
+			SenderType: models.SenderTypeUser,
+			Content:    m.Content,
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+		}
+		entry := logic.MessageForFormat{SenderType: logic.SenderTypeUserFormat, Content: m.Content}
+		if m.Sender != "" {
+			avatar := avatars[logic.NormalizeAvatarName(m.Sender)]
+			if avatar == nil {
+				return fmt.Errorf("unknown sender %q", m.Sender)
+			}
+			msg.SenderType = models.SenderTypeAvatar
+			msg.SenderID = &avatar.ID
+			entry.SenderType = logic.SenderTypeAvatarFormat
+			entry.SenderName = avatar.Name
+		}
+		messages = append(messages, msg)
+		history = append(history, entry)
+	}
+
+	conv, err := database.CreateConversation(c.Title, "")
+	if err != nil {
+		return err
+	}
+	if _, err := database.ImportMessages(conv.ID, messages); err != nil {
+		return err
+	}
+
+	seed := logic.FormatImportedHistory(history, maxHistoryLength)
+	for _, name := range c.Avatars {
+		avatar := avatars[logic.NormalizeAvatarName(name)]
+		if avatar == nil {
+			return fmt.Errorf("unknown participant %q", name)
+		}
+
+		var threadID string
+		if client != nil {
+			thread, err := client.CreateThreadWithMetadata(assistant.ThreadMetadata(conv.ID, avatar.ID))
+			if err != nil {
+				return err
+			}
+			threadID = thread.ID
+			if seed != "" {
+				if _, err := client.CreateMessage(threadID, seed); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, threadID); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[Seed] Conversation created conversation_id=%d title=%q message_count=%d", conv.ID, conv.Title, len(messages))
+	return nil
+}
@@ -0,0 +1,54 @@
+package seed
+
+import (
+	"testing"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestRun_Demo(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	result, err := Run(database, nil, logic.InstructionsBuilder{}, "demo")
+	if err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	if result.AvatarsCreated != len(demoDataset.Avatars) || result.ConversationsCreated != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	conversations, _ := database.GetAllConversations()
+	if len(conversations) != 1 {
+		t.Fatalf("expected 1 conversation, got %d", len(conversations))
+	}
+	messages, _ := database.GetMessages(conversations[0].ID)
+	if len(messages) != len(demoDataset.Conversations[0].Messages) {
+		t.Fatalf("expected the seeded history, got %d messages", len(messages))
+	}
+	if messages[0].SenderType != models.SenderTypeUser || messages[1].SenderType != models.SenderTypeAvatar {
+		t.Errorf("expected history in order, got %+v", messages[:2])
+	}
+	participants, _ := database.GetConversationAvatars(conversations[0].ID)
+	if len(participants) != 3 {
+		t.Errorf("expected 3 participants, got %d", len(participants))
+	}
+
+	// Seeding again leaves existing data untouched
+	result, err = Run(database, nil, logic.InstructionsBuilder{}, "demo")
+	if err != nil {
+		t.Fatalf("second seed failed: %v", err)
+	}
+	if result.AvatarsCreated != 0 || result.AvatarsSkipped != 3 || result.ConversationsSkipped != 1 {
+		t.Errorf("expected everything to be skipped, got %+v", result)
+	}
+}
+
+func TestRun_UnknownSeed(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	if _, err := Run(database, nil, logic.InstructionsBuilder{}, "missing"); err == nil {
+		t.Error("expected an error for an unknown seed")
+	}
+}
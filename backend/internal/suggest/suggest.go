@@ -0,0 +1,227 @@
+package suggest
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// DefaultCheckInterval is how often conversations are checked for a lull
+const DefaultCheckInterval = 10 * time.Second
+
+// suggestionsMaxTokens is the token limit for generated suggestions
+const suggestionsMaxTokens = 150
+
+// contextMessages is the number of recent messages the suggestions are based on
+const contextMessages = 10
+
+// Broadcaster delivers generated suggestions to the clients of a conversation
+type Broadcaster interface {
+	BroadcastSuggestions(conversationID int64, suggestions *Suggestions)
+	// ClientCount reports how many clients are subscribed to a conversation
+	ClientCount(conversationID int64) int
+}
+
+// Suggestions are replies the user could send next, generated after a lull in a conversation
+type Suggestions struct {
+	ConversationID int64     `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	Suggestions    []string  `json:"suggestions"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Job suggests replies to the user once a conversation has been quiet for the lull duration
+// Suggestions are kept in memory per conversation and replaced when the conversation continues
+type Job struct {
+	db          *db.DB
+	assistant   *assistant.Client
+	lull        time.Duration
+	broadcaster Broadcaster
+	now         func() time.Time
+	mu          sync.Mutex // serializes generation and guards latest
+	latest      map[int64]*Suggestions
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewJob creates a job suggesting replies after lull without messages
+// If assistantClient is nil, generic suggestions are used
+func NewJob(database *db.DB, assistantClient *assistant.Client, lull time.Duration) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Job{
+		db:        database,
+		assistant: assistantClient,
+		lull:      lull,
+		now:       time.Now,
+		latest:    make(map[int64]*Suggestions),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SetBroadcaster sets the broadcaster for suggestions SSE events
+// With a broadcaster, the background job only suggests replies in conversations someone is watching
+func (j *Job) SetBroadcaster(broadcaster Broadcaster) {
+	j.broadcaster = broadcaster
+}
+
+// Start begins checking conversations for lulls in the background
+func (j *Job) Start() {
+	interval := min(DefaultCheckInterval, j.lull)
+	j.wg.Add(1)
+	go j.run(interval)
+	log.Printf("[Suggest] Job started lull=%v interval=%v", j.lull, interval)
+}
+
+// Stop stops the job and waits for running generation to finish
+func (j *Job) Stop() {
+	j.cancel()
+	j.wg.Wait()
+	log.Printf("[Suggest] Job stopped")
+}
+
+// run is the main loop of the job
+func (j *Job) run(interval time.Duration) {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.RunOnce(); err != nil {
+				log.Printf("[Suggest] Run failed err=%v", err)
+			}
+		}
+	}
+}
+
+// RunOnce suggests replies in every watched conversation that has gone quiet
+func (j *Job) RunOnce() error {
+	conversations, err := j.db.GetAllConversations()
+	if err != nil {
+		return err
+	}
+
+	for i := range conversations {
+		if j.ctx.Err() != nil {
+			break
+		}
+		if j.broadcaster != nil && j.broadcaster.ClientCount(conversations[i].ID) == 0 {
+			continue
+		}
+		if _, err := j.ForConversation(&conversations[i]); err != nil {
+			log.Printf("[Suggest] Failed to suggest replies conversation_id=%d err=%v", conversations[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// ForConversation returns the suggestions for the latest message of a conversation,
+// generating and broadcasting them when the lull has passed and there are none yet
+// Returns nil without error while the conversation is active or has no messages
+func (j *Job) ForConversation(conv *models.Conversation) (*Suggestions, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	messages, err := j.db.GetMessages(conv.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Digests and other system messages do not count as activity
+	var recent []models.Message
+	for _, msg := range messages {
+		if msg.SenderType != models.SenderTypeSystem {
+			recent = append(recent, msg)
+		}
+	}
+	if len(recent) == 0 {
+		return nil, nil
+	}
+	if len(recent) > contextMessages {
+		recent = recent[len(recent)-contextMessages:]
+	}
+	last := recent[len(recent)-1]
+
+	if s := j.latest[conv.ID]; s != nil && s.MessageID == last.ID {
+		return s, nil
+	}
+	if j.now().Sub(last.CreatedAt) < j.lull {
+		return nil, nil
+	}
+
+	formatted, lastAvatarName, err := j.formatMessages(recent)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Suggestions{
+		ConversationID: conv.ID,
+		MessageID:      last.ID,
+		Suggestions:    j.generate(conv, formatted, lastAvatarName),
+		CreatedAt:      j.now(),
+	}
+	j.latest[conv.ID] = s
+
+	log.Printf("[Suggest] Suggestions generated conversation_id=%d message_id=%d count=%d",
+		conv.ID, s.MessageID, len(s.Suggestions))
+
+	if j.broadcaster != nil {
+		j.broadcaster.BroadcastSuggestions(conv.ID, s)
+	}
+
+	return s, nil
+}
+
+// formatMessages converts messages for the suggestions prompt
+// Also returns the name of the avatar that sent the last avatar message
+func (j *Job) formatMessages(messages []models.Message) ([]logic.MessageForFormat, string, error) {
+	avatars, err := j.db.GetAllAvatars()
+	if err != nil {
+		return nil, "", err
+	}
+	avatarNameMap := make(map[int64]string)
+	for _, a := range avatars {
+		avatarNameMap[a.ID] = a.Name
+	}
+
+	formatted := make([]logic.MessageForFormat, len(messages))
+	var lastAvatarName string
+	for i, msg := range messages {
+		fm := logic.MessageForFormat{Content: msg.Content, SenderType: logic.SenderTypeUserFormat}
+		if msg.SenderType == models.SenderTypeAvatar {
+			fm.SenderType = logic.SenderTypeAvatarFormat
+			if msg.SenderID != nil {
+				fm.SenderName = avatarNameMap[*msg.SenderID]
+				lastAvatarName = fm.SenderName
+			}
+		}
+		formatted[i] = fm
+	}
+
+	return formatted, lastAvatarName, nil
+}
+
+// generate asks the LLM for suggestions, falling back to generic ones
+func (j *Job) generate(conv *models.Conversation, messages []logic.MessageForFormat, lastAvatarName string) []string {
+	if j.assistant != nil {
+		answer, err := j.assistant.ForProject(conv.OpenAIOrganization, conv.OpenAIProject).WithContext(j.ctx).Completion(logic.BuildSuggestionsPrompt(conv.Title, messages), suggestionsMaxTokens)
+		if suggestions := logic.ParseSuggestions(answer); err == nil && len(suggestions) > 0 {
+			return suggestions
+		}
+		log.Printf("[Suggest] Generation failed, using fallback conversation_id=%d err=%v", conv.ID, err)
+	}
+
+	return logic.FallbackSuggestions(lastAvatarName)
+}
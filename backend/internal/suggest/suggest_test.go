@@ -0,0 +1,92 @@
+package suggest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// recordingBroadcaster collects broadcast suggestions and reports a fixed number of viewers
+type recordingBroadcaster struct {
+	mu          sync.Mutex
+	viewers     map[int64]int
+	suggestions []*Suggestions
+}
+
+func (b *recordingBroadcaster) BroadcastSuggestions(conversationID int64, suggestions *Suggestions) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.suggestions = append(b.suggestions, suggestions)
+}
+
+func (b *recordingBroadcaster) ClientCount(conversationID int64) int {
+	return b.viewers[conversationID]
+}
+
+func TestForConversation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Planning", "")
+	avatar, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	avatarID := avatar.ID
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "When do we release?")
+	last, _ := database.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatarID, "Friday")
+
+	broadcaster := &recordingBroadcaster{}
+	job := NewJob(database, nil, time.Minute)
+	job.SetBroadcaster(broadcaster)
+
+	// Still active: no suggestions yet
+	job.now = func() time.Time { return last.CreatedAt.Add(30 * time.Second) }
+	if s, err := job.ForConversation(conv); err != nil || s != nil {
+		t.Fatalf("expected no suggestions during activity, got %+v err=%v", s, err)
+	}
+
+	job.now = func() time.Time { return last.CreatedAt.Add(2 * time.Minute) }
+	s, err := job.ForConversation(conv)
+	if err != nil {
+		t.Fatalf("failed to suggest replies: %v", err)
+	}
+	if s == nil || s.MessageID != last.ID || len(s.Suggestions) != 3 || s.Suggestions[2] != "@Alice can you tell me more?" {
+		t.Fatalf("unexpected suggestions: %+v", s)
+	}
+
+	// The same lull is not suggested for twice
+	if again, _ := job.ForConversation(conv); again != s {
+		t.Errorf("expected the stored suggestions, got %+v", again)
+	}
+	if len(broadcaster.suggestions) != 1 {
+		t.Errorf("expected 1 broadcast, got %d", len(broadcaster.suggestions))
+	}
+
+	// System messages such as digests do not end the lull
+	database.CreateMessage(conv.ID, models.SenderTypeSystem, nil, "【Daily Digest】")
+	if again, _ := job.ForConversation(conv); again != s {
+		t.Errorf("expected system messages to be ignored, got %+v", again)
+	}
+}
+
+func TestRunOnce_SkipsUnwatchedConversations(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	watched, _ := database.CreateConversation("Watched", "")
+	unwatched, _ := database.CreateConversation("Unwatched", "")
+	database.CreateMessage(watched.ID, models.SenderTypeUser, nil, "hello")
+	database.CreateMessage(unwatched.ID, models.SenderTypeUser, nil, "hello")
+
+	broadcaster := &recordingBroadcaster{viewers: map[int64]int{watched.ID: 1}}
+	job := NewJob(database, nil, time.Minute)
+	job.SetBroadcaster(broadcaster)
+	job.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	if err := job.RunOnce(); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	if len(broadcaster.suggestions) != 1 || broadcaster.suggestions[0].ConversationID != watched.ID {
+		t.Errorf("expected suggestions for the watched conversation only, got %+v", broadcaster.suggestions)
+	}
+}
@@ -0,0 +1,336 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+)
+
+// DefaultMockResponse is the reply MockAssistant runs add to their thread
+const DefaultMockResponse = "This is a mock response from the avatar."
+
+// MockModel is the model MockAssistant runs report
+const MockModel = "gpt-4o-mock"
+
+// RedirectTransport sends OpenAI API calls to a test server
+type RedirectTransport struct {
+	BaseURL string
+	// TrimPrefix is cut from request paths so test servers can route on unversioned paths such as "/threads"
+	TrimPrefix string
+}
+
+func (rt *RedirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(rt.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = strings.TrimPrefix(req.URL.Path, rt.TrimPrefix)
+	req.URL.RawPath = ""
+	req.Host = target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// NewAssistantClient returns an assistant client whose requests go to the server at baseURL
+func NewAssistantClient(baseURL string) *assistant.Client {
+	return assistant.NewClient("mock-api-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &RedirectTransport{BaseURL: baseURL},
+	}))
+}
+
+// MockAssistant simulates the OpenAI threads, runs, and chat completion APIs
+type MockAssistant struct {
+	server       *httptest.Server
+	mutex        sync.Mutex
+	activeRuns   map[string]string // threadID -> runID
+	runStatuses  map[string]string // runID -> status
+	messages     map[string][]mockMessage
+	runCounter   int
+	msgCounter   int
+	responseText string
+	// runInstructions holds the instructions override of each created run
+	runInstructions []string
+	// runAdditionalInstructions holds the additional instructions of each created run
+	runAdditionalInstructions []string
+}
+
+type mockMessage struct {
+	ID      string
+	Role    string
+	Content string
+}
+
+// NewMockAssistant starts a mock OpenAI server that is closed when the test ends
+func NewMockAssistant(t testing.TB) *MockAssistant {
+	t.Helper()
+
+	m := &MockAssistant{
+		activeRuns:   make(map[string]string),
+		runStatuses:  make(map[string]string),
+		messages:     make(map[string][]mockMessage),
+		responseText: DefaultMockResponse,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/threads", m.handleCreateThread)
+	mux.HandleFunc("/v1/threads/", m.handleThreadsRequest)
+	mux.HandleFunc("/v1/chat/completions", m.handleChatCompletion)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// URL returns the base URL of the mock server
+func (m *MockAssistant) URL() string {
+	return m.server.URL
+}
+
+// Client returns an assistant client wired to the mock server
+func (m *MockAssistant) Client() *assistant.Client {
+	return NewAssistantClient(m.server.URL)
+}
+
+// SetResponse changes the reply added by runs created from now on
+func (m *MockAssistant) SetResponse(text string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.responseText = text
+}
+
+// RunInstructions returns the instructions override of each run created so far
+func (m *MockAssistant) RunInstructions() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.runInstructions...)
+}
+
+// RunAdditionalInstructions returns the additional instructions of each run created so far
+func (m *MockAssistant) RunAdditionalInstructions() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.runAdditionalInstructions...)
+}
+
+// ThreadMessages returns each message in a thread as "role: content"
+func (m *MockAssistant) ThreadMessages(threadID string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	messages := make([]string, 0, len(m.messages[threadID]))
+	for _, msg := range m.messages[threadID] {
+		messages = append(messages, msg.Role+": "+msg.Content)
+	}
+	return messages
+}
+
+func (m *MockAssistant) handleCreateThread(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.msgCounter++
+	threadID := fmt.Sprintf("thread_mock_%d", m.msgCounter)
+	m.messages[threadID] = []mockMessage{}
+	json.NewEncoder(w).Encode(map[string]any{
+		"id":         threadID,
+		"created_at": int64(1234567890),
+	})
+}
+
+func (m *MockAssistant) handleThreadsRequest(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Path: /v1/threads/{thread_id}[/runs[/{run_id}] | /messages]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/threads/"), "/")
+	threadID := parts[0]
+	resource := ""
+	if len(parts) > 1 {
+		resource = parts[1]
+	}
+
+	switch {
+	case r.Method == http.MethodGet && resource == "runs" && len(parts) == 2:
+		runs := []map[string]string{}
+		if runID, ok := m.activeRuns[threadID]; ok {
+			runs = append(runs, m.run(threadID, runID))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": runs})
+
+	case r.Method == http.MethodPost && resource == "runs" && len(parts) == 2:
+		if runID, ok := m.activeRuns[threadID]; ok && m.isActive(runID) {
+			writeMockError(w, "Thread "+threadID+" already has an active run "+runID+".")
+			return
+		}
+
+		var body struct {
+			Instructions           string `json:"instructions"`
+			AdditionalInstructions string `json:"additional_instructions"`
+			Stream                 bool   `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		m.runInstructions = append(m.runInstructions, body.Instructions)
+		m.runAdditionalInstructions = append(m.runAdditionalInstructions, body.AdditionalInstructions)
+
+		m.runCounter++
+		runID := fmt.Sprintf("run_mock_%d", m.runCounter)
+		m.activeRuns[threadID] = runID
+		m.runStatuses[runID] = "queued"
+		if body.Stream {
+			m.streamRun(w, threadID, runID, m.responseText)
+			return
+		}
+		go m.completeRun(threadID, runID, m.responseText)
+
+		json.NewEncoder(w).Encode(m.run(threadID, runID))
+
+	case r.Method == http.MethodGet && resource == "runs" && len(parts) > 2:
+		json.NewEncoder(w).Encode(m.run(threadID, parts[2]))
+
+	case r.Method == http.MethodGet && resource == "messages":
+		msgs := m.messages[threadID]
+		data := make([]map[string]any, 0, len(msgs))
+		for _, msg := range msgs {
+			data = append(data, map[string]any{
+				"id":   msg.ID,
+				"role": msg.Role,
+				"content": []map[string]any{
+					{
+						"type": "text",
+						"text": map[string]string{"value": msg.Content},
+					},
+				},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+
+	case r.Method == http.MethodPost && resource == "messages":
+		if runID, ok := m.activeRuns[threadID]; ok && m.isActive(runID) {
+			writeMockError(w, "Can't add messages to "+threadID+" while a run "+runID+" is active.")
+			return
+		}
+
+		var req struct {
+			Content string `json:"content"`
+			Role    string `json:"role"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		m.msgCounter++
+		msgID := fmt.Sprintf("msg_mock_%d", m.msgCounter)
+		m.messages[threadID] = append(m.messages[threadID], mockMessage{ID: msgID, Role: req.Role, Content: req.Content})
+		json.NewEncoder(w).Encode(map[string]string{"id": msgID, "role": req.Role})
+
+	case r.Method == http.MethodDelete && resource == "messages" && len(parts) > 2:
+		msgs := m.messages[threadID]
+		for i, msg := range msgs {
+			if msg.ID == parts[2] {
+				m.messages[threadID] = append(msgs[:i:i], msgs[i+1:]...)
+				json.NewEncoder(w).Encode(map[string]any{"id": msg.ID, "deleted": true})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// completeRun moves a run through in_progress to completed, then adds the reply to its thread
+func (m *MockAssistant) completeRun(threadID, runID, response string) {
+	time.Sleep(100 * time.Millisecond)
+	m.mutex.Lock()
+	m.runStatuses[runID] = "in_progress"
+	m.mutex.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.runStatuses[runID] = "completed"
+	m.msgCounter++
+	msgID := fmt.Sprintf("msg_mock_%d", m.msgCounter)
+	m.messages[threadID] = append([]mockMessage{{ID: msgID, Role: "assistant", Content: response}}, m.messages[threadID]...)
+}
+
+// streamRun writes the reply of a streamed run word by word, then completes the run
+// Called with the mutex held
+func (m *MockAssistant) streamRun(w http.ResponseWriter, threadID, runID, response string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	writeMockEvent(w, "thread.run.created", m.run(threadID, runID))
+
+	m.msgCounter++
+	msgID := fmt.Sprintf("msg_mock_%d", m.msgCounter)
+	for _, word := range strings.SplitAfter(response, " ") {
+		writeMockEvent(w, "thread.message.delta", map[string]any{
+			"id": msgID,
+			"delta": map[string]any{
+				"content": []map[string]any{
+					{"index": 0, "type": "text", "text": map[string]string{"value": word}},
+				},
+			},
+		})
+	}
+
+	m.runStatuses[runID] = "completed"
+	m.messages[threadID] = append([]mockMessage{{ID: msgID, Role: "assistant", Content: response}}, m.messages[threadID]...)
+	writeMockEvent(w, "thread.run.completed", m.run(threadID, runID))
+	fmt.Fprint(w, "event: done\ndata: [DONE]\n\n")
+}
+
+// writeMockEvent writes one server-sent event of a run stream
+func writeMockEvent(w http.ResponseWriter, event string, data any) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (m *MockAssistant) run(threadID, runID string) map[string]string {
+	status := m.runStatuses[runID]
+	if status == "" {
+		status = "completed"
+	}
+	return map[string]string{
+		"id":           runID,
+		"status":       status,
+		"thread_id":    threadID,
+		"assistant_id": "asst_mock",
+		"model":        MockModel,
+	}
+}
+
+func (m *MockAssistant) isActive(runID string) bool {
+	status := m.runStatuses[runID]
+	return status == "in_progress" || status == "queued"
+}
+
+func (m *MockAssistant) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	// Always answer "yes" so avatars decide to respond
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{
+			{"message": map[string]string{"content": "yes"}},
+		},
+	})
+}
+
+func writeMockError(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{
+			"message": message,
+			"type":    "invalid_request_error",
+		},
+	})
+}
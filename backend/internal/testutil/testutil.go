@@ -0,0 +1,72 @@
+// Package testutil provides shared fixtures and a mock OpenAI backend for tests
+package testutil
+
+import (
+	"fmt"
+	"testing"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+// NewTestDB opens a migrated in-memory database that is closed when the test ends
+func NewTestDB(t testing.TB) *db.DB {
+	t.Helper()
+
+	database, err := db.NewDB(db.MemoryPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return database
+}
+
+// NewTestConversationWithAvatars creates a conversation and one avatar per name, each joined with its own thread
+func NewTestConversationWithAvatars(t testing.TB, database *db.DB, names ...string) (*models.Conversation, []*models.Avatar) {
+	t.Helper()
+
+	conv, err := database.CreateConversation("Test Chat", "thread_test")
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	avatars := make([]*models.Avatar, 0, len(names))
+	for _, name := range names {
+		avatar, err := database.CreateAvatar(name, "You are "+name, "asst_"+name)
+		if err != nil {
+			t.Fatalf("failed to create avatar %s: %v", name, err)
+		}
+		threadID := fmt.Sprintf("thread_%d_%d", conv.ID, avatar.ID)
+		if err := database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, threadID); err != nil {
+			t.Fatalf("failed to add avatar %s: %v", name, err)
+		}
+		avatars = append(avatars, avatar)
+	}
+	return conv, avatars
+}
+
+// NewTestMessageSeries stores the contents in order as messages from the avatar, or from the user when avatar is nil
+func NewTestMessageSeries(t testing.TB, database *db.DB, conversationID int64, avatar *models.Avatar, contents ...string) []*models.Message {
+	t.Helper()
+
+	senderType := models.SenderTypeUser
+	var senderID *int64
+	if avatar != nil {
+		senderType = models.SenderTypeAvatar
+		senderID = &avatar.ID
+	}
+
+	messages := make([]*models.Message, 0, len(contents))
+	for _, content := range contents {
+		msg, err := database.CreateMessage(conversationID, senderType, senderID, content)
+		if err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
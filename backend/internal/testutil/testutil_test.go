@@ -0,0 +1,111 @@
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+)
+
+func TestNewTestConversationWithAvatars(t *testing.T) {
+	database := NewTestDB(t)
+
+	conv, avatars := NewTestConversationWithAvatars(t, database, "Alice", "Bob")
+	if len(avatars) != 2 || avatars[0].Name != "Alice" || avatars[1].Name != "Bob" {
+		t.Fatalf("expected Alice and Bob, got %+v", avatars)
+	}
+
+	members, err := database.GetConversationAvatars(conv.ID)
+	if err != nil || len(members) != 2 {
+		t.Fatalf("expected 2 avatars in the conversation, got %d (%v)", len(members), err)
+	}
+	threadIDs := map[string]bool{}
+	for _, avatar := range avatars {
+		threadID, err := database.GetAvatarThreadID(conv.ID, avatar.ID)
+		if err != nil || threadID == "" {
+			t.Fatalf("expected a thread for %s, got %q (%v)", avatar.Name, threadID, err)
+		}
+		threadIDs[threadID] = true
+	}
+	if len(threadIDs) != 2 {
+		t.Errorf("expected each avatar to get its own thread, got %v", threadIDs)
+	}
+}
+
+func TestNewTestMessageSeries(t *testing.T) {
+	database := NewTestDB(t)
+	conv, avatars := NewTestConversationWithAvatars(t, database, "Alice")
+
+	NewTestMessageSeries(t, database, conv.ID, nil, "hello", "anyone?")
+	NewTestMessageSeries(t, database, conv.ID, avatars[0], "hi")
+
+	messages, _ := database.GetMessages(conv.ID)
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+	if messages[1].Content != "anyone?" || messages[1].SenderType != models.SenderTypeUser {
+		t.Errorf("expected user messages in order, got %+v", messages[1])
+	}
+	if messages[2].SenderType != models.SenderTypeAvatar || messages[2].SenderID == nil || *messages[2].SenderID != avatars[0].ID {
+		t.Errorf("expected the last message from Alice, got %+v", messages[2])
+	}
+}
+
+func TestRedirectTransport_TrimPrefix(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"id": "thread_1"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &RedirectTransport{BaseURL: server.URL, TrimPrefix: "/v1"},
+	}))
+	if _, err := client.CreateThread(); err != nil {
+		t.Fatalf("CreateThread failed: %v", err)
+	}
+	if path != "/threads" {
+		t.Errorf("expected the version prefix to be trimmed, got %s", path)
+	}
+}
+
+func TestMockAssistant_CompletesRuns(t *testing.T) {
+	mock := NewMockAssistant(t)
+	mock.SetResponse("mocked")
+	client := mock.Client()
+
+	thread, err := client.CreateThread()
+	if err != nil {
+		t.Fatalf("CreateThread failed: %v", err)
+	}
+	if _, err := client.CreateMessage(thread.ID, "hello"); err != nil {
+		t.Fatalf("CreateMessage failed: %v", err)
+	}
+	run, err := client.CreateRunWithOptions(thread.ID, assistant.CreateRunRequest{AssistantID: "asst_1", Instructions: "be brief", AdditionalInstructions: "history"})
+	if err != nil {
+		t.Fatalf("CreateRun failed: %v", err)
+	}
+
+	// Messages cannot be added while the run is active
+	if _, err := client.CreateMessage(thread.ID, "again"); err == nil {
+		t.Error("expected adding a message during a run to fail")
+	}
+
+	if _, err := client.WaitForRun(thread.ID, run.ID, 5*time.Second); err != nil {
+		t.Fatalf("WaitForRun failed: %v", err)
+	}
+	reply, err := client.GetLatestAssistantMessage(thread.ID)
+	if err != nil || reply.Content != "mocked" {
+		t.Fatalf("expected the configured reply, got %+v (%v)", reply, err)
+	}
+	if runs := mock.RunInstructions(); len(runs) != 1 || runs[0] != "be brief" {
+		t.Errorf("expected the run instructions to be recorded, got %q", runs)
+	}
+	if runs := mock.RunAdditionalInstructions(); len(runs) != 1 || runs[0] != "history" {
+		t.Errorf("expected the additional instructions to be recorded, got %q", runs)
+	}
+}
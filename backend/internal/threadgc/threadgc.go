@@ -0,0 +1,117 @@
+package threadgc
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+)
+
+const (
+	// DefaultInterval is how often pending thread deletions are retried
+	DefaultInterval = 10 * time.Minute
+	// MaxAttempts is how many times deleting a thread is tried before it is left for an operator
+	MaxAttempts = 10
+)
+
+// Collector retries deleting the OpenAI threads of deleted conversations
+// Threads whose deletion failed when their conversation was deleted are recorded in the database;
+// without retries they would stay on the OpenAI account forever
+type Collector struct {
+	db        *db.DB
+	assistant *assistant.Client
+	interval  time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewCollector creates a collector deleting threads with the given client
+func NewCollector(database *db.DB, assistantClient *assistant.Client) *Collector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Collector{
+		db:        database,
+		assistant: assistantClient,
+		interval:  DefaultInterval,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SetInterval sets how often pending deletions are retried
+func (c *Collector) SetInterval(d time.Duration) {
+	c.interval = d
+}
+
+// Start begins retrying pending deletions in the background
+func (c *Collector) Start() {
+	c.wg.Add(1)
+	go c.run()
+	log.Printf("[ThreadGC] Started interval=%v", c.interval)
+}
+
+// Stop stops the collector and waits for a running pass to finish
+func (c *Collector) Stop() {
+	c.cancel()
+	c.wg.Wait()
+	log.Printf("[ThreadGC] Stopped")
+}
+
+func (c *Collector) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.RunOnce(); err != nil {
+				log.Printf("[ThreadGC] Run failed err=%v", err)
+			}
+		}
+	}
+}
+
+// RunOnce tries to delete every pending thread that has attempts left
+// Threads OpenAI no longer knows count as deleted. Returns the number of threads deleted
+func (c *Collector) RunOnce() (int, error) {
+	if c.assistant == nil {
+		return 0, nil
+	}
+
+	pending, err := c.db.GetPendingThreadDeletions(MaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	client := c.assistant.WithContext(c.ctx)
+	deleted := 0
+	for _, p := range pending {
+		if c.ctx.Err() != nil {
+			break
+		}
+		if err := client.DeleteThread(p.ThreadID); err != nil && !assistant.IsNotFound(err) {
+			log.Printf("[ThreadGC] Failed to delete thread thread_id=%s conversation_id=%d attempt=%d err=%v",
+				p.ThreadID, p.ConversationID, p.Attempts+1, err)
+			if err := c.db.RecordThreadDeletionFailure(p.ID, err.Error()); err != nil {
+				return deleted, err
+			}
+			continue
+		}
+		if err := c.db.DeletePendingThreadDeletion(p.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	if len(pending) > 0 {
+		log.Printf("[ThreadGC] Pass completed pending=%d deleted=%d", len(pending), deleted)
+	}
+	return deleted, nil
+}
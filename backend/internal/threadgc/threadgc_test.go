@@ -0,0 +1,95 @@
+package threadgc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// newDeleteServer answers thread deletions with the status configured for each thread ID
+func newDeleteServer(t *testing.T, statuses map[string]int) *assistant.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if status, ok := statuses[id]; ok && status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error": {"message": "failed"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "` + id + `", "deleted": true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("test-key", assistant.WithHTTPClient(&http.Client{
+		Transport: &testutil.RedirectTransport{BaseURL: server.URL},
+	}))
+}
+
+func TestCollector_RunOnce(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	client := newDeleteServer(t, map[string]int{
+		"thread_ok":      http.StatusOK,
+		"thread_gone":    http.StatusNotFound,
+		"thread_failing": http.StatusBadRequest,
+	})
+
+	for _, id := range []string{"thread_ok", "thread_gone", "thread_failing"} {
+		if err := database.AddPendingThreadDeletion(id, 1, "timeout"); err != nil {
+			t.Fatalf("failed to add pending deletion: %v", err)
+		}
+	}
+
+	collector := NewCollector(database, client)
+	deleted, err := collector.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	// A thread OpenAI no longer knows counts as deleted
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted threads, got %d", deleted)
+	}
+
+	pending, _ := database.GetPendingThreadDeletions(0)
+	if len(pending) != 1 || pending[0].ThreadID != "thread_failing" || pending[0].Attempts != 2 {
+		t.Fatalf("expected only thread_failing left with 2 attempts, got %+v", pending)
+	}
+}
+
+func TestCollector_GivesUpAfterMaxAttempts(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	client := newDeleteServer(t, map[string]int{"thread_failing": http.StatusBadRequest})
+
+	database.AddPendingThreadDeletion("thread_failing", 1, "timeout")
+	pending, _ := database.GetPendingThreadDeletions(0)
+	for i := 1; i < MaxAttempts; i++ {
+		database.RecordThreadDeletionFailure(pending[0].ID, "failed")
+	}
+
+	collector := NewCollector(database, client)
+	if _, err := collector.RunOnce(); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	// The row is kept for inspection but no longer retried
+	all, _ := database.GetPendingThreadDeletions(0)
+	if len(all) != 1 || all[0].Attempts != MaxAttempts {
+		t.Errorf("expected the thread to stay at %d attempts, got %+v", MaxAttempts, all)
+	}
+}
+
+func TestCollector_StartStop(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	collector := NewCollector(database, nil)
+	collector.SetInterval(10 * time.Millisecond)
+	collector.Start()
+	time.Sleep(30 * time.Millisecond)
+	collector.Stop()
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultServiceName is reported when OTEL_SERVICE_NAME is not set
+	DefaultServiceName = "multi-avatar-chat"
+	// instrumentationName identifies the tracer used throughout the backend
+	instrumentationName = "multi-avatar-chat"
+	// messageContextTTL is how long a message's trace context is kept for watchers
+	messageContextTTL = 10 * time.Minute
+)
+
+// Setup configures the global tracer provider with an OTLP/HTTP exporter
+// Tracing stays disabled (no-op) unless OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set; the exporter reads the standard OTEL_* variables
+// The returned function flushes pending spans and must be called on shutdown
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	// W3C trace context is accepted from clients even when spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Printf("[Tracing] OTLP exporter enabled service_name=%s", serviceName)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// messageContexts hands trace contexts from the request that stored a message to
+// the watcher goroutines that later pick the message up from the database
+var messageContexts = struct {
+	sync.Mutex
+	entries map[int64]messageContext
+}{entries: make(map[int64]messageContext)}
+
+type messageContext struct {
+	spanContext trace.SpanContext
+	expiresAt   time.Time
+}
+
+// RememberMessage associates a stored message with the trace in ctx
+// Does nothing when ctx does not carry a sampled span
+func RememberMessage(ctx context.Context, messageID int64) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return
+	}
+
+	now := time.Now()
+	messageContexts.Lock()
+	defer messageContexts.Unlock()
+
+	for id, entry := range messageContexts.entries {
+		if now.After(entry.expiresAt) {
+			delete(messageContexts.entries, id)
+		}
+	}
+	messageContexts.entries[messageID] = messageContext{spanContext: sc, expiresAt: now.Add(messageContextTTL)}
+}
+
+// MessageContext returns a context carrying the trace a message was stored in
+// Returns context.Background() if the message is unknown or its trace expired
+func MessageContext(messageID int64) context.Context {
+	messageContexts.Lock()
+	entry, ok := messageContexts.entries[messageID]
+	messageContexts.Unlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return context.Background()
+	}
+	return trace.ContextWithRemoteSpanContext(context.Background(), entry.spanContext)
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useRecorder installs a tracer provider that keeps finished spans in memory
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func TestMessageContext(t *testing.T) {
+	useRecorder(t)
+
+	ctx, span := Start(context.Background(), "request")
+	RememberMessage(ctx, 42)
+	span.End()
+
+	got := trace.SpanContextFromContext(MessageContext(42))
+	if got.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("expected trace %s, got %s", span.SpanContext().TraceID(), got.TraceID())
+	}
+
+	// Child spans started from the message context join the request's trace
+	_, child := Start(MessageContext(42), "watcher")
+	child.End()
+	if child.SpanContext().TraceID() != span.SpanContext().TraceID() {
+		t.Error("expected watcher span to continue the request trace")
+	}
+}
+
+func TestMessageContext_Unknown(t *testing.T) {
+	if sc := trace.SpanContextFromContext(MessageContext(-1)); sc.IsValid() {
+		t.Errorf("expected no trace for unknown message, got %s", sc.TraceID())
+	}
+}
+
+func TestRememberMessage_IgnoresUntracedContext(t *testing.T) {
+	RememberMessage(context.Background(), 43)
+
+	if sc := trace.SpanContextFromContext(MessageContext(43)); sc.IsValid() {
+		t.Error("expected untraced message not to be remembered")
+	}
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	recorder := useRecorder(t)
+
+	_, span := Start(context.Background(), "failing")
+	End(span, context.DeadlineExceeded)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status().Description != context.DeadlineExceeded.Error() {
+		t.Errorf("expected error status, got %+v", spans[0].Status())
+	}
+}
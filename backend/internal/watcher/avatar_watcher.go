@@ -2,6 +2,8 @@ package watcher
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"math/rand"
 	"strings"
@@ -12,6 +14,9 @@ import (
 	"multi-avatar-chat/internal/db"
 	"multi-avatar-chat/internal/logic"
 	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -19,6 +24,23 @@ const (
 	minRandomInterval = 5 * time.Second
 	// maxRandomInterval is the maximum interval for random polling (20 seconds)
 	maxRandomInterval = 20 * time.Second
+	// referencedMessageLimit is the number of recent messages included per referenced conversation
+	referencedMessageLimit = 10
+	// maxResponseAttempts is how often a response is attempted before it goes to the dead letter queue
+	maxResponseAttempts = 3
+	// defaultResponseRetryDelay is the delay before the second attempt; later attempts wait longer
+	defaultResponseRetryDelay = 2 * time.Second
+	// retryQueueSize is the number of dead letter retries a watcher can have waiting
+	retryQueueSize = 8
+	// maxMessagesPerCheck bounds how many new messages one check reads; the rest are read on the next checks
+	maxMessagesPerCheck = 50
+)
+
+var (
+	// ErrNoWatcher is returned when no watcher runs for the avatar in the conversation
+	ErrNoWatcher = errors.New("avatar has no watcher in the conversation")
+	// ErrRetryQueueFull is returned when a watcher has too many dead letter retries waiting
+	ErrRetryQueueFull = errors.New("too many retries waiting for the avatar")
 )
 
 // getRandomInterval returns a random duration between 5 and 20 seconds
@@ -41,8 +63,42 @@ type AvatarWatcher struct {
 	assistant         *assistant.Client
 	interval          time.Duration
 	useRandomInterval bool
-	lastMessageID     int64
+	lastSequence      int64
+	savedSequence     int64
+	// heldSequence is how far held messages were already checked for urgent ones while the user is away
+	heldSequence    int64
+	startAfterSet     bool
 	broadcastFn       BroadcastFunc
+	mentionNotifier   MentionNotifier
+	// streamer receives the response while it is written; nil waits for the run to finish
+	streamer          ResponseStreamer
+	batchJudge        *BatchJudge
+	// loop suppresses responses after too many avatar messages in a row; nil never suppresses
+	loop *loopBreaker
+	// avatarStreak counts the avatar messages since the last user message
+	avatarStreak int
+	// judgments caches LLM judgments by prompt; nil always asks the LLM
+	judgments *judgmentCache
+	// timeZone is the zone the avatar is told the current time in; nil leaves the time out
+	timeZone *time.Location
+	// backoff pauses checks after consecutive failures so a failing database or API is not polled nonstop
+	backoff failureBackoff
+	// circuitSkipping is set while checks are skipped for an open circuit, so the skip is logged once
+	circuitSkipping bool
+	// trial is the prompt variant of the message being handled; nil outside a prompt experiment
+	trial *promptTrial
+	// behavior is what the avatar's behavior script decided for the message being handled; nil without a script
+	behavior *logic.BehaviorResult
+	// script is the compiled behavior script, recompiled when its source changes
+	script *behaviorScript
+	// webhookHosts are the hosts behavior script webhooks may call at local or private addresses
+	webhookHosts []string
+	// instructions builds run instructions and judgment prompts with the safety preamble
+	instructions logic.InstructionsBuilder
+	// contextMu guards the avatar name, conversation title and participant names, which change on renames
+	contextMu         sync.RWMutex
+	retryDelay        time.Duration
+	retries           chan models.DeadLetter
 	ctx               context.Context
 	cancel            context.CancelFunc
 	wg                sync.WaitGroup
@@ -50,6 +106,7 @@ type AvatarWatcher struct {
 	mu            sync.RWMutex
 	currentRunID  string
 	currentThreadID string
+	currentRunStartedAt time.Time
 }
 
 // NewAvatarWatcher creates a new AvatarWatcher
@@ -68,6 +125,10 @@ func NewAvatarWatcher(
 
 	// If interval is 0, use random interval mode
 	useRandom := interval == 0
+	backoffBase := interval
+	if useRandom {
+		backoffBase = defaultFailureBackoff
+	}
 
 	return &AvatarWatcher{
 		conversationID:    conversationID,
@@ -77,6 +138,9 @@ func NewAvatarWatcher(
 		interval:          interval,
 		useRandomInterval: useRandom,
 		broadcastFn:       broadcastFn,
+		retryDelay:        defaultResponseRetryDelay,
+		retries:           make(chan models.DeadLetter, retryQueueSize),
+		backoff:           newFailureBackoff(backoffBase),
 		ctx:               ctx,
 		cancel:            cancel,
 	}
@@ -84,10 +148,58 @@ func NewAvatarWatcher(
 
 // SetConversationContext sets the conversation title and participant names
 func (w *AvatarWatcher) SetConversationContext(title string, participantNames []string) {
+	w.contextMu.Lock()
+	defer w.contextMu.Unlock()
 	w.conversationTitle = title
 	w.participantNames = participantNames
 }
 
+// SetParticipantNames replaces the participant names, e.g. after an avatar was renamed
+func (w *AvatarWatcher) SetParticipantNames(participantNames []string) {
+	w.contextMu.Lock()
+	defer w.contextMu.Unlock()
+	w.participantNames = participantNames
+}
+
+// SetAvatarName changes the name the watcher's avatar answers to
+func (w *AvatarWatcher) SetAvatarName(name string) {
+	w.contextMu.Lock()
+	defer w.contextMu.Unlock()
+	w.avatar.Name = name
+}
+
+// avatarName returns the current name of the watcher's avatar
+func (w *AvatarWatcher) avatarName() string {
+	w.contextMu.RLock()
+	defer w.contextMu.RUnlock()
+	return w.avatar.Name
+}
+
+// currentAvatar reads the avatar for each judgment and run, so prompt and assistant changes made
+// through the avatar API apply to the next message. Falls back to the avatar the watcher started with
+func (w *AvatarWatcher) currentAvatar(database *db.DB) models.Avatar {
+	if current, err := database.GetAvatar(w.avatar.ID); err == nil {
+		return *current
+	}
+	w.contextMu.RLock()
+	defer w.contextMu.RUnlock()
+	return w.avatar
+}
+
+// conversationContext returns the conversation title and participant names
+func (w *AvatarWatcher) conversationContext() (string, []string) {
+	w.contextMu.RLock()
+	defer w.contextMu.RUnlock()
+	return w.conversationTitle, w.participantNames
+}
+
+// SetStartAfter makes the watcher treat messages after the given sequence number as new
+// instead of starting from the latest message when the loop begins
+func (w *AvatarWatcher) SetStartAfter(sequence int64) {
+	w.lastSequence = sequence
+	w.startAfterSet = true
+}
+
 // Start begins the monitoring loop
 func (w *AvatarWatcher) Start() {
 	w.wg.Add(1)
@@ -101,9 +213,10 @@ func (w *AvatarWatcher) Stop() {
 }
 
 // Interrupt cancels any active LLM run and stops the watcher
+// WatcherManager.InterruptRoomWatchers starts a new watcher afterwards
 func (w *AvatarWatcher) Interrupt() {
 	log.Printf("[AvatarWatcher] Interrupt called conversation_id=%d avatar_id=%d avatar_name=%s",
-		w.conversationID, w.avatar.ID, w.avatar.Name)
+		w.conversationID, w.avatar.ID, w.avatarName())
 
 	// Cancel context to stop the watcher loop
 	w.cancel()
@@ -117,7 +230,7 @@ func (w *AvatarWatcher) Interrupt() {
 	if runID != "" && threadID != "" && w.assistant != nil {
 		log.Printf("[AvatarWatcher] Cancelling active run conversation_id=%d avatar_id=%d run_id=%s thread_id=%s",
 			w.conversationID, w.avatar.ID, runID, threadID)
-		if err := w.assistant.CancelRun(threadID, runID); err != nil {
+		if err := w.assistant.ForProject(w.openAIProject()).CancelRun(threadID, runID); err != nil {
 			log.Printf("[AvatarWatcher] Failed to cancel run conversation_id=%d avatar_id=%d run_id=%s err=%v",
 				w.conversationID, w.avatar.ID, runID, err)
 		} else {
@@ -130,15 +243,38 @@ func (w *AvatarWatcher) Interrupt() {
 	w.wg.Wait()
 }
 
+// trackRun records the run the watcher is waiting for
+// The run is also recorded in the database so the reaper can find it after the watcher gives up
+func (w *AvatarWatcher) trackRun(database *db.DB, runID, threadID string) {
+	w.mu.Lock()
+	w.currentRunID = runID
+	w.currentThreadID = threadID
+	w.currentRunStartedAt = time.Now()
+	w.mu.Unlock()
+	if err := database.CreateRun(runID, w.conversationID, w.avatar.ID, threadID); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to record run run_id=%s err=%v", runID, err)
+	}
+}
+
+// activeRun returns the run the watcher is waiting for, if any
+func (w *AvatarWatcher) activeRun() (runID, threadID string, startedAt time.Time) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.currentRunID, w.currentThreadID, w.currentRunStartedAt
+}
+
 func (w *AvatarWatcher) run() {
 	defer w.wg.Done()
 
 	log.Printf("[AvatarWatcher] Started conversation_id=%d avatar_id=%d avatar_name=%s useRandomInterval=%v interval=%v",
-		w.conversationID, w.avatar.ID, w.avatar.Name, w.useRandomInterval, w.interval)
-
-	// Initialize lastMessageID with the current latest message
-	if err := w.initializeLastMessageID(); err != nil {
-		log.Printf("[AvatarWatcher] Failed to initialize lastMessageID conversation_id=%d avatar_id=%d err=%v",
+		w.conversationID, w.avatar.ID, w.avatarName(), w.useRandomInterval, w.interval)
+
+	// Initialize lastSequence with the current latest message unless a starting point was given
+	if w.startAfterSet {
+		log.Printf("[AvatarWatcher] Starting after sequence=%d conversation_id=%d avatar_id=%d",
+			w.lastSequence, w.conversationID, w.avatar.ID)
+	} else if err := w.initializeLastSequence(); err != nil {
+		log.Printf("[AvatarWatcher] Failed to initialize lastSequence conversation_id=%d avatar_id=%d err=%v",
 			w.conversationID, w.avatar.ID, err)
 	}
 
@@ -148,6 +284,8 @@ func (w *AvatarWatcher) run() {
 	} else {
 		w.runWithFixedInterval()
 	}
+
+	w.reopenWaitingRetries()
 }
 
 // runWithFixedInterval runs the watcher with a fixed interval (for testing)
@@ -162,10 +300,9 @@ func (w *AvatarWatcher) runWithFixedInterval() {
 				w.conversationID, w.avatar.ID)
 			return
 		case <-ticker.C:
-			if err := w.checkAndRespond(); err != nil {
-				log.Printf("[AvatarWatcher] Error during check conversation_id=%d avatar_id=%d err=%v",
-					w.conversationID, w.avatar.ID, err)
-			}
+			w.check()
+		case dl := <-w.retries:
+			w.retryDeadLetter(dl)
 		}
 	}
 }
@@ -183,34 +320,130 @@ func (w *AvatarWatcher) runWithRandomInterval() {
 				w.conversationID, w.avatar.ID)
 			return
 		case <-time.After(interval):
-			if err := w.checkAndRespond(); err != nil {
-				log.Printf("[AvatarWatcher] Error during check conversation_id=%d avatar_id=%d err=%v",
-					w.conversationID, w.avatar.ID, err)
-			}
+			w.check()
+		case dl := <-w.retries:
+			w.retryDeadLetter(dl)
 		}
 	}
 }
 
-// initializeLastMessageID sets lastMessageID to the current latest message
-func (w *AvatarWatcher) initializeLastMessageID() error {
-	messages, err := w.db.GetMessages(w.conversationID)
-	if err != nil {
+// check runs checkAndRespond unless the watcher is backing off after failed checks
+// Each failure lengthens the pause; a successful check resumes normal polling
+func (w *AvatarWatcher) check() {
+	if w.backoff.waiting(time.Now()) {
+		return
+	}
+
+	if err := w.checkAndRespond(); err != nil {
+		pause := w.backoff.fail(time.Now())
+		log.Printf("[AvatarWatcher] Error during check, backing off conversation_id=%d avatar_id=%d failures=%d pause=%v err=%v",
+			w.conversationID, w.avatar.ID, w.backoff.failures, pause, err)
+		return
+	}
+	if failures := w.backoff.succeed(); failures > 0 {
+		log.Printf("[AvatarWatcher] Check succeeded, backoff reset conversation_id=%d avatar_id=%d failures=%d",
+			w.conversationID, w.avatar.ID, failures)
+	}
+}
+
+// initializeLastSequence sets lastSequence to where the previous watcher of the avatar stopped,
+// or to the current latest message if the avatar has not been watched before
+func (w *AvatarWatcher) initializeLastSequence() error {
+	saved, err := w.db.GetWatcherState(w.conversationID, w.avatar.ID)
+	if err == nil {
+		w.lastSequence = saved
+	} else if err != sql.ErrNoRows {
 		return err
+	} else {
+		msg, err := w.db.GetLastMessage(w.conversationID)
+		if err == nil {
+			w.lastSequence = msg.Sequence
+		} else if err != sql.ErrNoRows {
+			return err
+		}
 	}
+	w.savedSequence = w.lastSequence
 
-	if len(messages) > 0 {
-		w.lastMessageID = messages[len(messages)-1].ID
+	// Messages queued while the OpenAI API was unavailable are evaluated after they are replayed
+	firstQueued, err := w.db.GetFirstOfflineForwardSequence(w.conversationID)
+	if err != nil {
+		return err
+	}
+	if firstQueued > 0 && firstQueued <= w.lastSequence {
+		w.lastSequence = firstQueued - 1
 	}
 
-	log.Printf("[AvatarWatcher] Initialized lastMessageID=%d conversation_id=%d avatar_id=%d",
-		w.lastMessageID, w.conversationID, w.avatar.ID)
+	log.Printf("[AvatarWatcher] Initialized lastSequence=%d conversation_id=%d avatar_id=%d",
+		w.lastSequence, w.conversationID, w.avatar.ID)
 	return nil
 }
 
+// saveState persists lastSequence if it changed since it was last saved
+func (w *AvatarWatcher) saveState() {
+	if w.lastSequence == w.savedSequence {
+		return
+	}
+	if err := w.db.SaveWatcherState(w.conversationID, w.avatar.ID, w.lastSequence); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to save watcher state conversation_id=%d avatar_id=%d err=%v",
+			w.conversationID, w.avatar.ID, err)
+		return
+	}
+	w.savedSequence = w.lastSequence
+}
+
 // checkAndRespond checks for new messages and responds if appropriate
+// Returns the last error of a failed response, after the remaining messages were handled
 func (w *AvatarWatcher) checkAndRespond() error {
-	// Get new messages since last check
-	messages, err := w.db.GetMessagesAfter(w.conversationID, w.lastMessageID)
+	// Skip judgment and runs while the OpenAI API is unavailable
+	// lastSequence is not advanced so the messages are handled after recovery
+	if w.assistant != nil && w.assistant.CircuitOpen() {
+		if !w.circuitSkipping {
+			log.Printf("[AvatarWatcher] Circuit open, skipping checks conversation_id=%d avatar_id=%d",
+				w.conversationID, w.avatar.ID)
+			w.circuitSkipping = true
+		}
+		return nil
+	}
+	if w.circuitSkipping {
+		log.Printf("[AvatarWatcher] Circuit closed, resuming checks conversation_id=%d avatar_id=%d",
+			w.conversationID, w.avatar.ID)
+		w.circuitSkipping = false
+	}
+
+	// Wait until messages queued during an outage have been added to the avatar threads
+	pending, err := w.db.HasOfflineForwards(w.conversationID)
+	if err != nil {
+		return err
+	}
+	if pending {
+		log.Printf("[AvatarWatcher] Offline messages pending, skipping check conversation_id=%d avatar_id=%d",
+			w.conversationID, w.avatar.ID)
+		return nil
+	}
+
+	// Most checks find nothing new; the latest sequence is read from the index without loading messages
+	latest, err := w.db.GetLatestSequence(w.conversationID)
+	if err != nil {
+		return err
+	}
+	if latest <= w.lastSequence {
+		return nil
+	}
+
+	// Hold avatar chatter while the user is away; lastSequence is not advanced
+	// so the messages are handled when the user returns
+	held, err := w.holdForUser(latest)
+	if err != nil {
+		return err
+	}
+	if held {
+		log.Printf("[AvatarWatcher] User away, holding messages after_sequence=%d latest_sequence=%d conversation_id=%d avatar_id=%d",
+			w.lastSequence, latest, w.conversationID, w.avatar.ID)
+		return nil
+	}
+
+	// Get new messages since last check, a bounded window at a time
+	messages, err := w.db.GetMessagesAfterSequence(w.conversationID, w.lastSequence, maxMessagesPerCheck)
 	if err != nil {
 		return err
 	}
@@ -219,86 +452,254 @@ func (w *AvatarWatcher) checkAndRespond() error {
 		return nil
 	}
 
-	log.Printf("[AvatarWatcher] Found %d new messages conversation_id=%d avatar_id=%d",
-		len(messages), w.conversationID, w.avatar.ID)
+	log.Printf("[AvatarWatcher] Found %d new messages conversation_id=%d avatar_id=%d latest_sequence=%d",
+		len(messages), w.conversationID, w.avatar.ID, latest)
 
 	// Process each message
+	var respondErr error
 	for _, msg := range messages {
-		// Update lastMessageID
-		if msg.ID > w.lastMessageID {
-			w.lastMessageID = msg.ID
+		// Update lastSequence
+		if msg.Sequence > w.lastSequence {
+			w.lastSequence = msg.Sequence
 		}
 
-		// Skip own messages
-		if msg.SenderType == models.SenderTypeAvatar && msg.SenderID != nil && *msg.SenderID == w.avatar.ID {
-			continue
+		// Skip own messages, system messages (e.g. digests) and silent messages such as imported history
+		ownMessage := msg.SenderType == models.SenderTypeAvatar && msg.SenderID != nil && *msg.SenderID == w.avatar.ID
+		history := msg.Silent
+		if !history {
+			w.countStreak(&msg)
+		}
+		if !ownMessage && msg.SenderType != models.SenderTypeSystem && !history {
+			if err := w.handleMessage(&msg); err != nil {
+				respondErr = err
+			}
 		}
 
-		// Check if should respond
-		shouldRespond, err := w.shouldRespond(&msg)
-		if err != nil {
-			log.Printf("[AvatarWatcher] Error checking shouldRespond message_id=%d err=%v", msg.ID, err)
-			continue
+		// Saved after the message is handled so a restart resumes with the first unhandled message
+		w.saveState()
+	}
+
+	return respondErr
+}
+
+// countStreak tracks the avatar messages since the last user message, including the avatar's own
+// A user message ends the streak and any wait for the user
+func (w *AvatarWatcher) countStreak(msg *models.Message) {
+	switch msg.SenderType {
+	case models.SenderTypeUser:
+		w.avatarStreak = 0
+		if w.loop != nil {
+			w.loop.reset(w.conversationID)
 		}
+	case models.SenderTypeAvatar:
+		w.avatarStreak++
+	}
+}
 
-		if shouldRespond {
-			if err := w.generateResponse(&msg); err != nil {
-				log.Printf("[AvatarWatcher] Error generating response message_id=%d err=%v", msg.ID, err)
-			}
+// handleMessage decides whether to respond to a message and generates the response
+// Spans continue the trace of the request that stored the message, if it is known.
+// Returns the error of a judgment or response that failed; failed responses are also dead-lettered
+func (w *AvatarWatcher) handleMessage(msg *models.Message) error {
+	ctx, span := tracing.Start(tracing.MessageContext(msg.ID), "watcher.handle_message",
+		attribute.Int64("conversation.id", w.conversationID),
+		attribute.Int64("avatar.id", w.avatar.ID),
+		attribute.String("avatar.name", w.avatarName()),
+		attribute.Int64("message.id", msg.ID),
+	)
+	defer span.End()
+
+	current, err := w.db.WithContext(ctx).GetAvatar(w.avatar.ID)
+
+	// An avatar whose assistant was deleted cannot respond until it is relinked
+	if err == nil && current.NeedsRelink {
+		log.Printf("[AvatarWatcher] Avatar needs relink, not responding message_id=%d avatar_name=%s",
+			msg.ID, w.avatarName())
+		span.SetAttributes(attribute.Bool("watcher.needs_relink", true))
+		return nil
+	}
+
+	// An avatar with isolated context never sees other avatars' messages, so it does not react to them either
+	if err == nil && current.IsolatedContext && msg.SenderType == models.SenderTypeAvatar {
+		log.Printf("[AvatarWatcher] Context isolated, ignoring avatar message message_id=%d avatar_name=%s",
+			msg.ID, w.avatarName())
+		span.SetAttributes(attribute.Bool("watcher.isolated_context", true))
+		return nil
+	}
+
+	// Under a prompt experiment, the judgment and the response both use the variant picked here
+	w.trial = w.selectPromptTrial(ctx)
+	defer func() { w.trial = nil }()
+	if w.trial != nil {
+		span.SetAttributes(attribute.String("watcher.prompt_variant", w.trial.variant))
+	}
+
+	// A behavior script can decide for itself whether to respond and add to the run instructions
+	w.behavior = w.runBehaviorScript(ctx, msg)
+	defer func() { w.behavior = nil }()
+	if w.behavior != nil && w.behavior.Decision != "" {
+		span.SetAttributes(attribute.String("watcher.script_decision", w.behavior.Decision))
+	}
+
+	// Check if should respond
+	shouldRespond, err := w.shouldRespond(ctx, msg)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Error checking shouldRespond message_id=%d err=%v", msg.ID, err)
+		span.RecordError(err)
+		return err
+	}
+	span.SetAttributes(attribute.Bool("watcher.should_respond", shouldRespond))
+
+	var respondErr error
+	if shouldRespond {
+		attempts, err := w.respondWithRetries(ctx, msg)
+		if err != nil {
+			log.Printf("[AvatarWatcher] Error generating response message_id=%d attempts=%d err=%v", msg.ID, attempts, err)
+			span.RecordError(err)
+			w.recordDeadLetter(msg, attempts, err)
+			respondErr = err
 		}
 	}
 
-	return nil
+	if w.trial != nil {
+		w.recordPromptTrial(ctx, msg, shouldRespond)
+	}
+	return respondErr
 }
 
 // shouldRespond determines if the avatar should respond to the message
-func (w *AvatarWatcher) shouldRespond(message *models.Message) (bool, error) {
+func (w *AvatarWatcher) shouldRespond(ctx context.Context, message *models.Message) (bool, error) {
+	// Avatars that have talked among themselves for too long wait for the user, even when mentioned
+	if message.SenderType == models.SenderTypeAvatar && w.loop != nil && w.loop.suppress(w.conversationID, w.avatarStreak) {
+		log.Printf("[AvatarWatcher] Waiting for user, not responding message_id=%d avatar_name=%s avatar_messages=%d",
+			message.ID, w.avatarName(), w.avatarStreak)
+		return false, nil
+	}
+
+	// A behavior script decision takes precedence over mentions and the LLM judgment
+	if w.behavior != nil {
+		switch w.behavior.Decision {
+		case logic.BehaviorDecisionRespond:
+			log.Printf("[AvatarWatcher] Behavior script decided to respond message_id=%d avatar_name=%s",
+				message.ID, w.avatarName())
+			return true, nil
+		case logic.BehaviorDecisionSkip:
+			log.Printf("[AvatarWatcher] Behavior script decided to skip message_id=%d avatar_name=%s",
+				message.ID, w.avatarName())
+			return false, nil
+		}
+	}
+
+	// Triggers set for this avatar in the conversation force a response without LLM judgment
+	// They are re-read so triggers added through the API apply immediately
+	triggers, err := w.db.WithContext(ctx).GetAvatarTriggers(w.conversationID, w.avatar.ID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get avatar triggers avatar_id=%d err=%v", w.avatar.ID, err)
+	} else if trigger := logic.MatchTrigger(message.Content, triggers); trigger != nil {
+		log.Printf("[AvatarWatcher] Trigger matched message_id=%d avatar_name=%s trigger_id=%d",
+			message.ID, w.avatarName(), trigger.ID)
+		return true, nil
+	}
+
 	// Check for direct mention
 	mentionedNames := logic.ParseMentions(message.Content)
 	for _, name := range mentionedNames {
-		if strings.EqualFold(name, w.avatar.Name) {
+		if logic.NormalizeAvatarName(name) == logic.NormalizeAvatarName(w.avatarName()) {
 			log.Printf("[AvatarWatcher] Mentioned in message message_id=%d avatar_name=%s",
-				message.ID, w.avatar.Name)
+				message.ID, w.avatarName())
+			return true, nil
+		}
+	}
+
+	// Check for @team mentions that include this avatar
+	if len(mentionedNames) > 0 {
+		teamNames, err := w.db.WithContext(ctx).GetAvatarTeamNames(w.avatar.ID)
+		if err != nil {
+			log.Printf("[AvatarWatcher] Failed to get avatar teams avatar_id=%d err=%v", w.avatar.ID, err)
+		} else if matched := logic.MatchAvatarNames(mentionedNames, teamNames); len(matched) > 0 {
+			log.Printf("[AvatarWatcher] Team mentioned in message message_id=%d avatar_name=%s team=%s",
+				message.ID, w.avatarName(), matched[0])
 			return true, nil
 		}
 	}
 
 	// If no assistant configured, skip LLM judgment
-	if w.assistant == nil || w.avatar.OpenAIAssistantID == "" {
+	avatar := w.currentAvatar(w.db.WithContext(ctx))
+	if w.assistant == nil || avatar.OpenAIAssistantID == "" {
+		return false, nil
+	}
+
+	// Skip the LLM call for messages the local pre-filter considers irrelevant
+	if !logic.PassesPrefilter(message.Content, avatar.Keywords, w.promptFor(avatar.Prompt), avatar.RelevanceThreshold) {
+		log.Printf("[AvatarWatcher] Pre-filter skipped judgment message_id=%d avatar_name=%s threshold=%.2f",
+			message.ID, w.avatarName(), avatar.RelevanceThreshold)
 		return false, nil
 	}
 
 	// LLM-based judgment
-	return w.shouldRespondLLM(message)
+	return w.shouldRespondLLM(ctx, message)
 }
 
 // shouldRespondLLM uses LLM to determine if avatar should respond
-func (w *AvatarWatcher) shouldRespondLLM(message *models.Message) (bool, error) {
+func (w *AvatarWatcher) shouldRespondLLM(ctx context.Context, message *models.Message) (shouldRespond bool, err error) {
+	ctx, span := tracing.Start(ctx, "watcher.judge", attribute.Bool("judgment.batched", w.batchJudge != nil))
+	defer func() {
+		span.SetAttributes(attribute.Bool("watcher.should_respond", shouldRespond))
+		tracing.End(span, err)
+	}()
+
+	// In batch mode one call decides for every avatar in the conversation
+	// The batch judges with avatar prompts, so a prompt variant under trial is judged on its own
+	if w.batchJudge != nil && w.trial == nil {
+		title, participantNames := w.conversationContext()
+		shouldRespond, err := w.batchJudge.Judge(message, title, participantNames, w.avatar.ID)
+		if err != errNotInBatch {
+			log.Printf("[AvatarWatcher] Batched judgment message_id=%d avatar_name=%s should_respond=%v",
+				message.ID, w.avatarName(), shouldRespond)
+			return shouldRespond, err
+		}
+	}
+
 	prompt := w.buildJudgmentPrompt(message.Content)
 
+	// An identical prompt judged recently gets the same answer without another call
+	if w.judgments != nil {
+		if cached, ok := w.judgments.get(w.avatar.ID, prompt); ok {
+			span.SetAttributes(attribute.Bool("judgment.cached", true))
+			log.Printf("[AvatarWatcher] Cached LLM judgment message_id=%d avatar_name=%s should_respond=%v",
+				message.ID, w.avatarName(), cached)
+			return cached, nil
+		}
+	}
+
 	// Use a simple completion request for judgment
-	response, err := w.assistant.SimpleCompletion(prompt)
+	response, err := w.client(ctx).SimpleCompletion(prompt)
 	if err != nil {
 		log.Printf("[AvatarWatcher] LLM judgment failed message_id=%d err=%v", message.ID, err)
 		return false, err
 	}
 
 	answer := strings.TrimSpace(strings.ToLower(response))
-	shouldRespond := answer == "yes"
+	shouldRespond = answer == "yes"
+	if w.judgments != nil {
+		w.judgments.set(w.avatar.ID, prompt, shouldRespond)
+	}
 
 	log.Printf("[AvatarWatcher] LLM judgment message_id=%d avatar_name=%s answer=%q should_respond=%v",
-		message.ID, w.avatar.Name, answer, shouldRespond)
+		message.ID, w.avatarName(), answer, shouldRespond)
 
 	return shouldRespond, nil
 }
 
 // buildJudgmentPrompt creates the prompt for response judgment
 func (w *AvatarWatcher) buildJudgmentPrompt(messageContent string) string {
+	title, participantNames := w.conversationContext()
+	avatar := w.currentAvatar(w.db)
+
 	// Build participants section
 	participantsSection := ""
-	if len(w.participantNames) > 0 {
+	if len(participantNames) > 0 {
 		participantsSection = "\n【Participants】\n"
-		for _, name := range w.participantNames {
+		for _, name := range participantNames {
 			if name == "ユーザ" || name == "User" {
 				participantsSection += "- " + name + "\n"
 			} else {
@@ -309,14 +710,14 @@ func (w *AvatarWatcher) buildJudgmentPrompt(messageContent string) string {
 
 	// Build topic section
 	topicSection := ""
-	if w.conversationTitle != "" {
-		topicSection = "\n【Topic】\n" + w.conversationTitle + "\n"
+	if title != "" {
+		topicSection = "\n【Topic】\n" + title + "\n"
 	}
 
-	return `You are "` + w.avatar.Name + `" character.
+	return w.instructions.WithSafetyPreamble(`You are "` + avatar.Name + `" character.
 ` + topicSection + participantsSection + `
 【Your Settings】
-` + w.avatar.Prompt + `
+` + logic.RenderPrompt(w.promptFor(avatar.Prompt), w.promptVariables(nil)) + `
 
 【Task】
 Read the following message and determine whether you should respond to it.
@@ -331,102 +732,284 @@ Criteria:
 ` + messageContent + `
 
 【Answer】
-Answer only "yes" if you should respond, or "no" if not.`
+Answer only "yes" if you should respond, or "no" if not.`)
 }
 
 // generateResponse generates and saves a response from the avatar
-func (w *AvatarWatcher) generateResponse(message *models.Message) error {
+func (w *AvatarWatcher) generateResponse(ctx context.Context, message *models.Message) (err error) {
+	ctx, span := tracing.Start(ctx, "watcher.generate_response")
+	defer func() { tracing.End(span, err) }()
+
+	database := w.db.WithContext(ctx)
+
 	log.Printf("[AvatarWatcher] Generating response conversation_id=%d avatar_id=%d avatar_name=%s message_id=%d",
-		w.conversationID, w.avatar.ID, w.avatar.Name, message.ID)
+		w.conversationID, w.avatar.ID, w.avatarName(), message.ID)
 
 	// Get avatar-specific thread ID
-	threadID, err := w.db.GetAvatarThreadID(w.conversationID, w.avatar.ID)
+	threadID, err := database.GetAvatarThreadID(w.conversationID, w.avatar.ID)
 	if err != nil {
 		log.Printf("[AvatarWatcher] Failed to get avatar thread ID conversation_id=%d avatar_id=%d err=%v", w.conversationID, w.avatar.ID, err)
 		return err
 	}
 
-	if threadID == "" || w.avatar.OpenAIAssistantID == "" {
+	// A relinked assistant or an updated prompt applies without restarting the watcher
+	avatar := w.currentAvatar(database)
+	assistantID, prompt := avatar.OpenAIAssistantID, w.promptFor(avatar.Prompt)
+
+	// A prompt variant replaces the assistant's instructions for this run only
+	var instructions string
+	if w.trial != nil {
+		instructions = w.instructions.AssistantInstructions(prompt)
+	}
+
+	if threadID == "" || assistantID == "" {
 		log.Printf("[AvatarWatcher] Cannot generate response: missing thread_id or assistant_id conversation_id=%d avatar_id=%d thread_id=%q assistant_id=%q",
-			w.conversationID, w.avatar.ID, threadID, w.avatar.OpenAIAssistantID)
+			w.conversationID, w.avatar.ID, threadID, assistantID)
 		return nil
 	}
 
+	client := w.client(ctx)
+
 	// Wait for any active runs to complete before creating a new run
-	if err := w.assistant.WaitForActiveRunsToComplete(threadID, 30*time.Second); err != nil {
-		log.Printf("[AvatarWatcher] Timeout waiting for active runs thread_id=%s avatar_name=%s err=%v", threadID, w.avatar.Name, err)
+	if err := client.WaitForActiveRunsToComplete(threadID, client.ActiveRunTimeout()); err != nil {
+		log.Printf("[AvatarWatcher] Timeout waiting for active runs thread_id=%s avatar_name=%s err=%v", threadID, w.avatarName(), err)
 		return err
 	}
 
-	// Build additional context from conversation history
-	additionalContext := w.buildConversationContext()
+	// Build additional context from conversation history and conversation settings
+	additionalContext := w.buildRunInstructions(message)
 
 	log.Printf("[AvatarWatcher] LLM Input thread_id=%s avatar_name=%s conversation_context_length=%d assistant_id=%s",
-		threadID, w.avatar.Name, len(additionalContext), w.avatar.OpenAIAssistantID)
+		threadID, w.avatarName(), len(additionalContext), assistantID)
 	if additionalContext != "" {
 		log.Printf("[AvatarWatcher] LLM Input conversation_context=%q", additionalContext)
 	}
 
-	// Create a run with context
+	// Under a model comparison experiment, the comparison model answers while the run is in progress
+	var comparison <-chan models.ResponseCandidate
+	experiment, err := database.GetResponseExperiment(w.avatar.ID)
+	if err == nil {
+		comparison = w.startComparison(client, experiment, prompt, additionalContext, message)
+	} else if err != sql.ErrNoRows {
+		log.Printf("[AvatarWatcher] Warning: failed to get response experiment avatar_id=%d err=%v", w.avatar.ID, err)
+	}
+
+	// Create a run with context, reading only the conversation's limit of recent thread messages
+	runStarted := time.Now()
+	runRequest := assistant.CreateRunRequest{
+		AssistantID:            assistantID,
+		Instructions:           instructions,
+		AdditionalInstructions: additionalContext,
+		TruncationStrategy:     assistant.LastMessages(w.maxContextMessages()),
+	}
 	var run *assistant.Run
-	if additionalContext != "" {
-		run, err = w.assistant.CreateRunWithContext(threadID, w.avatar.OpenAIAssistantID, additionalContext)
+	if w.streamer != nil {
+		// Clients see the response as it is written; the run is tracked as soon as it exists
+		var runID string
+		run, err = client.StreamRun(threadID, runRequest, assistant.RunStreamHandler{
+			OnCreated: func(created *assistant.Run) {
+				runID = created.ID
+				w.trackRun(database, runID, threadID)
+			},
+			OnDelta: func(delta string) {
+				w.streamer.BroadcastMessageDelta(w.conversationID, w.avatar.ID, w.avatarName(), runID, delta)
+			},
+		})
 	} else {
-		run, err = w.assistant.CreateRun(threadID, w.avatar.OpenAIAssistantID)
+		run, err = client.CreateRunWithOptions(threadID, runRequest)
+		if err == nil {
+			w.trackRun(database, run.ID, threadID)
+			_, err = client.WaitForRun(threadID, run.ID, client.RunTimeout())
+		}
 	}
-	if err != nil {
+	if run == nil {
+		// The run was never created
+		if errors.Is(err, assistant.ErrAssistantNotFound) {
+			// Retrying cannot bring a deleted assistant back, so the avatar is silenced until it is relinked
+			if marked, markErr := database.MarkAvatarNeedsRelink(w.avatar.ID, assistantID); markErr != nil {
+				log.Printf("[AvatarWatcher] Warning: failed to mark avatar for relink avatar_id=%d err=%v", w.avatar.ID, markErr)
+			} else if marked {
+				log.Printf("[AvatarWatcher] Assistant no longer exists, avatar needs relink avatar_id=%d avatar_name=%s assistant_id=%s",
+					w.avatar.ID, w.avatarName(), assistantID)
+			}
+		}
 		return err
 	}
 
-	// Track the active run
-	w.mu.Lock()
-	w.currentRunID = run.ID
-	w.currentThreadID = threadID
-	w.mu.Unlock()
+	// Clients discard the streamed text unless the response is saved
+	var savedID *int64
+	if w.streamer != nil {
+		defer func() { w.streamer.BroadcastMessageComplete(w.conversationID, w.avatar.ID, run.ID, savedID) }()
+	}
 
-	// Wait for completion (30 second timeout)
-	_, err = w.assistant.WaitForRun(threadID, run.ID, 30*time.Second)
-	
 	// Clear the active run
 	w.mu.Lock()
 	w.currentRunID = ""
 	w.currentThreadID = ""
+	w.currentRunStartedAt = time.Time{}
 	w.mu.Unlock()
+
+	// A run that timed out here stays running in the database until the reaper sees it end or cancels it
+	if !errors.Is(err, assistant.ErrRunTimeout) {
+		var runErr, runErrCode string
+		if err != nil {
+			runErr = err.Error()
+		}
+		var ended *assistant.RunEndedError
+		if errors.As(err, &ended) {
+			runErrCode, runErr = w.classifyRunFailure(client, threadID, ended.Run)
+		}
+		if _, finishErr := database.FinishRun(run.ID, runErrCode, runErr); finishErr != nil {
+			log.Printf("[AvatarWatcher] Warning: failed to record run outcome run_id=%s err=%v", run.ID, finishErr)
+		}
+	}
 	
 	if err != nil {
 		return err
 	}
 
 	// Get response
-	responseContent, err := w.assistant.GetLatestAssistantMessage(threadID)
+	response, err := client.GetLatestAssistantMessage(threadID)
 	if err != nil {
 		return err
 	}
+	responseContent := response.Content
+	responseModel := run.Model
+
+	// Pair the run's response with the comparison; the broadcast variant becomes the avatar's message
+	var result *models.ResponseComparison
+	if comparison != nil {
+		primary := models.ResponseCandidate{
+			Model:     run.Model,
+			Content:   response.Content,
+			LatencyMs: time.Since(runStarted).Milliseconds(),
+		}
+		result = w.finishComparison(ctx, experiment, message, primary, comparison)
+		if result.Broadcast == models.ExperimentVariantComparison {
+			// Citations point into the primary response, so they are dropped with it
+			responseContent = result.Comparison.Content
+			responseModel = result.Comparison.Model
+			response.Citations = nil
+			w.replaceThreadResponse(client, threadID, response.ID, responseContent)
+		}
+	}
+
+	// Repair responses that ignore the avatar's formatting rules; rules are read per response so changes apply immediately
+	var formattingPrefix int
+	if avatar, err := database.GetAvatar(w.avatar.ID); err != nil {
+		log.Printf("[AvatarWatcher] Failed to get avatar for formatting rules avatar_id=%d err=%v", w.avatar.ID, err)
+	} else {
+		responseContent, formattingPrefix = logic.ApplyFormattingRules(avatar.Name, responseContent, avatar.FormattingRules)
+	}
 
-	// Save to database
-	avatarID := w.avatar.ID
-	savedMsg, err := w.db.CreateMessage(w.conversationID, models.SenderTypeAvatar, &avatarID, responseContent)
+	// Show the reply in the display language; other avatars keep reading the original
+	originalContent := responseContent
+	responseContent, translation := w.translateReply(ctx, client, responseContent)
+	if translation != nil {
+		// Citations point into the original text, so they are dropped with it
+		response.Citations = nil
+	}
+
+	// Collect code interpreter outputs before they are lost behind the final text
+	var artifacts []models.MessageArtifact
+	if result == nil || result.Broadcast == models.ExperimentVariantPrimary {
+		artifacts = w.collectArtifacts(client, threadID, run.ID)
+	}
+
+	// Save to database, completing the response claim with it
+	savedMsg, err := database.CreateResponseMessage(w.conversationID, w.avatar.ID, message.ID, responseContent)
 	if err != nil {
 		return err
 	}
 
-	// Update lastMessageID to include our own message
-	if savedMsg.ID > w.lastMessageID {
-		w.lastMessageID = savedMsg.ID
+	// Label the message with the model that generated it for AI disclosure
+	if responseModel != "" {
+		if err := database.RecordMessageModel(savedMsg.ID, responseModel); err != nil {
+			log.Printf("[AvatarWatcher] Warning: failed to record message model message_id=%d err=%v",
+				savedMsg.ID, err)
+		} else {
+			savedMsg.Model = responseModel
+		}
+	}
+
+	if translation != nil {
+		if err := database.RecordMessageTranslation(savedMsg.ID, *translation); err != nil {
+			log.Printf("[AvatarWatcher] Warning: failed to record message translation message_id=%d err=%v",
+				savedMsg.ID, err)
+		} else {
+			savedMsg.Translation = translation
+		}
+	}
+
+	if err := database.CreateMessageArtifacts(savedMsg.ID, artifacts); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to save message artifacts message_id=%d err=%v",
+			savedMsg.ID, err)
+	}
+
+	// Keep the sources the response cites so they are broadcast with the message
+	if len(response.Citations) > 0 {
+		client.ResolveCitationFilenames(response.Citations)
+		citations := logic.ShiftCitations(messageCitations(response.Citations), formattingPrefix, responseContent)
+		if err := database.CreateMessageCitations(savedMsg.ID, citations); err != nil {
+			log.Printf("[AvatarWatcher] Warning: failed to save message citations message_id=%d err=%v",
+				savedMsg.ID, err)
+		} else {
+			savedMsg.Citations = citations
+		}
+	}
+
+	if w.trial != nil {
+		w.trial.messageID = &savedMsg.ID
+		savedMsg.PromptVariant = w.trial.variant
+	}
+
+	if result != nil {
+		result.MessageID = &savedMsg.ID
+		if _, err := database.CreateResponseComparison(result); err != nil {
+			log.Printf("[AvatarWatcher] Warning: failed to save response comparison message_id=%d err=%v",
+				savedMsg.ID, err)
+		}
+	}
+
+	// Record cross-references to other conversations made by the avatar
+	if _, err := database.RecordConversationReferences(savedMsg); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to record conversation references message_id=%d err=%v",
+			savedMsg.ID, err)
+	}
+
+	savedID = &savedMsg.ID
+
+	// Update lastSequence to include our own message
+	if savedMsg.Sequence > w.lastSequence {
+		w.lastSequence = savedMsg.Sequence
 	}
 
 	log.Printf("[AvatarWatcher] Response generated conversation_id=%d avatar_id=%d avatar_name=%s response_message_id=%d",
-		w.conversationID, w.avatar.ID, w.avatar.Name, savedMsg.ID)
+		w.conversationID, w.avatar.ID, w.avatarName(), savedMsg.ID)
+
+	// Avatars responding to this message continue the same trace
+	tracing.RememberMessage(ctx, savedMsg.ID)
 
 	// Broadcast the message via SSE
 	if w.broadcastFn != nil {
-		w.broadcastFn(w.conversationID, savedMsg, w.avatar.Name)
+		_, broadcastSpan := tracing.Start(ctx, "watcher.broadcast", attribute.Int64("message.id", savedMsg.ID))
+		w.broadcastFn(w.conversationID, savedMsg, w.avatarName())
+		broadcastSpan.End()
 		log.Printf("[AvatarWatcher] Message broadcasted via SSE conversation_id=%d message_id=%d",
 			w.conversationID, savedMsg.ID)
 	}
 
+	// Notify the user by email when the avatar addresses them directly
+	if w.mentionNotifier != nil && logic.MentionsUser(responseContent) {
+		go func() {
+			if err := w.mentionNotifier.NotifyAvatarMention(w.conversationID, w.avatarName(), savedMsg); err != nil {
+				log.Printf("[AvatarWatcher] Warning: failed to notify user mention message_id=%d err=%v", savedMsg.ID, err)
+			}
+		}()
+	}
+
 	// Send the avatar's message to other avatars' threads
-	if err := w.broadcastMessageToOtherAvatars(responseContent); err != nil {
+	if err := w.broadcastMessageToOtherAvatars(savedMsg.ID, originalContent); err != nil {
 		log.Printf("[AvatarWatcher] Warning: failed to broadcast message to other avatars conversation_id=%d avatar_id=%d err=%v",
 			w.conversationID, w.avatar.ID, err)
 		// Continue - message is saved and broadcasted via SSE
@@ -435,8 +1018,80 @@ func (w *AvatarWatcher) generateResponse(message *models.Message) error {
 	return nil
 }
 
+// classifyRunFailure returns the error code and message recorded for a run that ended without completing
+// The run steps are inspected so a rate limit can be told apart from a server error
+func (w *AvatarWatcher) classifyRunFailure(client *assistant.Client, threadID string, run *assistant.Run) (string, string) {
+	failure, stepID := client.RunFailure(threadID, run)
+
+	log.Printf("[AvatarWatcher] Run failed conversation_id=%d avatar_id=%d run_id=%s status=%s error_code=%s step_id=%s error_message=%q",
+		w.conversationID, w.avatar.ID, run.ID, run.Status, failure.Code, stepID, failure.Message)
+
+	return failure.Code, runFailureMessage(run, failure)
+}
+
+// runFailureMessage describes a run that ended without completing, with the error that caused it if known
+func runFailureMessage(run *assistant.Run, failure assistant.RunError) string {
+	message := "run ended with status: " + run.Status
+	if failure.Code != run.Status {
+		message += " (" + failure.String() + ")"
+	}
+	return message
+}
+
+// collectArtifacts returns the code, logs and images produced by the code interpreter during a run
+// Run steps are only fetched for avatars with the can_code capability; failures are logged and ignored
+func (w *AvatarWatcher) collectArtifacts(client *assistant.Client, threadID, runID string) []models.MessageArtifact {
+	avatar, err := w.db.GetAvatar(w.avatar.ID)
+	if err != nil || !avatar.CanCode {
+		return nil
+	}
+
+	steps, err := client.ListRunSteps(threadID, runID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to list run steps run_id=%s err=%v", runID, err)
+		return nil
+	}
+	return codeInterpreterArtifacts(assistant.CodeInterpreterCalls(steps))
+}
+
+// codeInterpreterArtifacts converts code interpreter calls into message artifacts
+func codeInterpreterArtifacts(calls []assistant.CodeInterpreterCall) []models.MessageArtifact {
+	var artifacts []models.MessageArtifact
+	for _, call := range calls {
+		if call.Input != "" {
+			artifacts = append(artifacts, models.MessageArtifact{Type: models.ArtifactTypeCode, Content: call.Input})
+		}
+		for _, output := range call.Outputs {
+			switch {
+			case output.Type == "logs" && output.Logs != "":
+				artifacts = append(artifacts, models.MessageArtifact{Type: models.ArtifactTypeLogs, Content: output.Logs})
+			case output.Type == "image" && output.Image != nil:
+				artifacts = append(artifacts, models.MessageArtifact{Type: models.ArtifactTypeImage, FileID: output.Image.FileID})
+			}
+		}
+	}
+	return artifacts
+}
+
+// messageCitations converts the citations of a response into message citations
+func messageCitations(citations []assistant.Citation) []models.MessageCitation {
+	result := make([]models.MessageCitation, len(citations))
+	for i, c := range citations {
+		result[i] = models.MessageCitation{
+			Type:       c.Type,
+			Marker:     c.Text,
+			FileID:     c.FileID,
+			Filename:   c.Filename,
+			Quote:      c.Quote,
+			StartIndex: c.StartIndex,
+			EndIndex:   c.EndIndex,
+		}
+	}
+	return result
+}
+
 // broadcastMessageToOtherAvatars sends the avatar's message to other avatars' threads
-func (w *AvatarWatcher) broadcastMessageToOtherAvatars(content string) error {
+func (w *AvatarWatcher) broadcastMessageToOtherAvatars(messageID int64, content string) error {
 	if w.assistant == nil {
 		log.Printf("[AvatarWatcher] Cannot broadcast: assistant is nil")
 		return nil
@@ -449,7 +1104,8 @@ func (w *AvatarWatcher) broadcastMessageToOtherAvatars(content string) error {
 	}
 
 	// Format the avatar's message for other avatars' threads
-	formattedContent := logic.FormatAvatarMessage(w.avatar.Name, content)
+	formattedContent := logic.FormatAvatarMessage(w.avatarName(), content)
+	organization, project := w.openAIProject()
 
 	// Send to each other avatar's thread
 	targetCount := 0
@@ -459,6 +1115,13 @@ func (w *AvatarWatcher) broadcastMessageToOtherAvatars(content string) error {
 			continue
 		}
 
+		// Avatars with isolated context only receive user messages
+		if avatar.IsolatedContext {
+			log.Printf("[AvatarWatcher] Skipping avatar with isolated context conversation_id=%d avatar_id=%d avatar_name=%s",
+				w.conversationID, avatar.ID, avatar.Name)
+			continue
+		}
+
 		if i >= len(threadIDs) || threadIDs[i] == "" {
 			log.Printf("[AvatarWatcher] Skipping avatar without thread_id conversation_id=%d avatar_id=%d avatar_name=%s",
 				w.conversationID, avatar.ID, avatar.Name)
@@ -467,15 +1130,20 @@ func (w *AvatarWatcher) broadcastMessageToOtherAvatars(content string) error {
 
 		threadID := threadIDs[i]
 		log.Printf("[AvatarWatcher] Broadcasting message to avatar thread conversation_id=%d from_avatar_id=%d from_avatar_name=%s to_avatar_id=%d to_avatar_name=%s thread_id=%s",
-			w.conversationID, w.avatar.ID, w.avatar.Name, avatar.ID, avatar.Name, threadID)
+			w.conversationID, w.avatar.ID, w.avatarName(), avatar.ID, avatar.Name, threadID)
 		log.Printf("[AvatarWatcher] LLM Input thread_id=%s avatar_name=%s message_content=%q", threadID, avatar.Name, formattedContent)
 
-		// Wait for any active runs to complete before adding message
-		if err := w.assistant.WaitForActiveRunsToComplete(threadID, 30*time.Second); err != nil {
-			log.Printf("[AvatarWatcher] Warning: timeout waiting for active runs thread_id=%s to_avatar_name=%s err=%v", threadID, avatar.Name, err)
-		}
-
-		_, err := w.assistant.CreateMessage(threadID, formattedContent)
+		// Forward through the queue so pending and failed deliveries are visible to operators
+		err := w.assistant.ForwardQueue().Forward(assistant.ForwardItem{
+			ThreadID:       threadID,
+			ConversationID: w.conversationID,
+			MessageID:      messageID,
+			AvatarID:       avatar.ID,
+			AvatarName:     avatar.Name,
+			Content:        formattedContent,
+			Organization:   organization,
+			Project:        project,
+		})
 		if err != nil {
 			log.Printf("[AvatarWatcher] Warning: failed to send message to avatar thread thread_id=%s to_avatar_name=%s err=%v", threadID, avatar.Name, err)
 			// Continue - try other avatars
@@ -486,13 +1154,170 @@ func (w *AvatarWatcher) broadcastMessageToOtherAvatars(content string) error {
 	}
 
 	log.Printf("[AvatarWatcher] Broadcasting message to other avatars completed conversation_id=%d avatar_name=%s message_id=%d target_count=%d",
-		w.conversationID, w.avatar.Name, 0, targetCount)
+		w.conversationID, w.avatarName(), 0, targetCount)
 
 	return nil
 }
 
+// maxContextMessages returns the conversation's limit of thread messages per run, 0 for the whole thread
+// It is read on every run so setting changes apply immediately
+func (w *AvatarWatcher) maxContextMessages() int {
+	conv, err := w.db.GetConversation(w.conversationID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get conversation for run context limit conversation_id=%d err=%v",
+			w.conversationID, err)
+		return 0
+	}
+	return conv.MaxContextMessages
+}
+
+// buildRunInstructions combines the conversation history with per-conversation run settings
+// and the conversations referenced by the message being responded to
+func (w *AvatarWatcher) buildRunInstructions(message *models.Message) string {
+	var sections []string
+
+	if history := w.buildConversationContext(); history != "" {
+		sections = append(sections, history)
+	}
+
+	if refs := w.buildReferencedConversationsContext(message); refs != "" {
+		sections = append(sections, refs)
+	}
+
+	if now := w.buildTimeContext(message); now != "" {
+		sections = append(sections, now)
+	}
+
+	// Response style is read on every run so setting changes apply immediately
+	conv, err := w.db.GetConversation(w.conversationID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get conversation for run settings conversation_id=%d err=%v",
+			w.conversationID, err)
+	} else {
+		if style := logic.FormatResponseStyleInstructions(logic.ResponseStyle(conv.ResponseStyle)); style != "" {
+			sections = append(sections, style)
+		}
+		if language := logic.FormatLanguageInstructions(conv.Language); language != "" {
+			sections = append(sections, language)
+		}
+	}
+
+	// Capabilities are read on every run as well so flag changes apply without restarting the watcher
+	avatar, err := w.db.GetAvatar(w.avatar.ID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get avatar for run settings avatar_id=%d err=%v", w.avatar.ID, err)
+	} else {
+		if capabilities := logic.FormatCapabilityInstructions(*avatar); capabilities != "" {
+			sections = append(sections, capabilities)
+		}
+		if settings := logic.FormatPromptVariablesInstructions(w.promptFor(avatar.Prompt), w.promptVariables(conv)); settings != "" {
+			sections = append(sections, settings)
+		}
+		if formatting := logic.FormatFormattingRulesInstructions(avatar.Name, avatar.FormattingRules); formatting != "" {
+			sections = append(sections, formatting)
+		}
+	}
+
+	if w.behavior != nil {
+		if notes := logic.FormatBehaviorContext(w.behavior.Context); notes != "" {
+			sections = append(sections, notes)
+		}
+	}
+
+	// The conversation's own instructions come last so they take precedence over the defaults above
+	if conv != nil {
+		if instructions := logic.FormatSystemInstructions(conv.SystemInstructions); instructions != "" {
+			sections = append(sections, instructions)
+		}
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+// promptVariables returns the values for the variables in the avatar's prompt
+// The conversation's current title is used when given, falling back to the title the watcher started with
+func (w *AvatarWatcher) promptVariables(conv *models.Conversation) logic.PromptVariables {
+	title, participantNames := w.conversationContext()
+	if conv != nil {
+		title = conv.Title
+	}
+	return logic.PromptVariables{
+		ConversationTitle: title,
+		Today:             time.Now(),
+		Participants:      participantNames,
+	}
+}
+
+// buildReferencedConversationsContext resolves "conversation #N" references in a message
+// into excerpts of the referenced conversations
+func (w *AvatarWatcher) buildReferencedConversationsContext(message *models.Message) string {
+	if message == nil {
+		return ""
+	}
+
+	targetIDs := logic.ParseConversationReferences(message.Content)
+	if len(targetIDs) == 0 {
+		return ""
+	}
+
+	avatars, err := w.db.GetAllAvatars()
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get avatars for referenced conversations err=%v", err)
+		return ""
+	}
+	avatarNameMap := make(map[int64]string)
+	for _, a := range avatars {
+		avatarNameMap[a.ID] = a.Name
+	}
+
+	var refs []logic.ReferencedConversation
+	for _, targetID := range targetIDs {
+		if targetID == w.conversationID {
+			continue
+		}
+
+		conv, err := w.db.GetConversation(targetID)
+		if err != nil {
+			log.Printf("[AvatarWatcher] Skipping referenced conversation conversation_id=%d referenced_id=%d err=%v",
+				w.conversationID, targetID, err)
+			continue
+		}
+
+		messages, err := w.db.GetMessages(targetID)
+		if err != nil {
+			log.Printf("[AvatarWatcher] Failed to get messages for referenced conversation referenced_id=%d err=%v",
+				targetID, err)
+			continue
+		}
+		if len(messages) > referencedMessageLimit {
+			messages = messages[len(messages)-referencedMessageLimit:]
+		}
+
+		ref := logic.ReferencedConversation{ID: conv.ID, Title: conv.Title}
+		for _, msg := range messages {
+			if msg.SenderType == models.SenderTypeSystem {
+				continue
+			}
+			fm := logic.MessageForFormat{Content: msg.Content, SenderType: logic.SenderTypeUserFormat}
+			if msg.SenderType == models.SenderTypeAvatar {
+				fm.SenderType = logic.SenderTypeAvatarFormat
+				if msg.SenderID != nil {
+					fm.SenderName = avatarNameMap[*msg.SenderID]
+				}
+			}
+			ref.Messages = append(ref.Messages, fm)
+		}
+		refs = append(refs, ref)
+	}
+
+	return logic.FormatConversationReferences(refs)
+}
+
 // buildConversationContext builds context from recent messages for the run
+// An avatar with isolated context only gets the user's messages
 func (w *AvatarWatcher) buildConversationContext() string {
+	isolated := w.isolatedContext()
+
 	// Get recent messages from the conversation
 	messages, err := w.db.GetMessages(w.conversationID)
 	if err != nil {
@@ -518,12 +1343,20 @@ func (w *AvatarWatcher) buildConversationContext() string {
 		avatarNameMap[a.ID] = a.Name
 	}
 
-	// Convert messages to format-ready structure
+	// Convert messages to format-ready structure, in the language avatars read
+	translated := w.translatedContent()
 	var formatMessages []logic.MessageForFormat
 	for _, msg := range messages {
+		if msg.SenderType == models.SenderTypeSystem || (isolated && msg.SenderType == models.SenderTypeAvatar) {
+			continue
+		}
+
 		fm := logic.MessageForFormat{
 			Content: msg.Content,
 		}
+		if content, ok := translated[msg.ID]; ok {
+			fm.Content = content
+		}
 
 		if msg.SenderType == models.SenderTypeUser {
 			fm.SenderType = logic.SenderTypeUserFormat
@@ -541,25 +1374,54 @@ func (w *AvatarWatcher) buildConversationContext() string {
 	}
 
 	// Format message history excluding current avatar's messages
-	formattedHistory := logic.FormatMessageHistory(formatMessages, w.avatar.Name)
+	formattedHistory := logic.FormatMessageHistory(formatMessages, w.avatarName())
 
 	if formattedHistory == "" {
 		return ""
 	}
 
 	// Build the additional context
+	excluded := "Messages from you (assistant) are excluded."
+	if isolated {
+		excluded = "Only the user's messages are included; answer independently of other participants."
+	}
 	context := "【Conversation History】\n" +
 		"The following are previous messages in this conversation.\n" +
-		"Messages from you (assistant) are excluded. Respond based on this context.\n\n" +
+		excluded + " Respond based on this context.\n\n" +
 		formattedHistory
 
 	log.Printf("[AvatarWatcher] Built conversation context avatar=%s context_length=%d",
-		w.avatar.Name, len(context))
+		w.avatarName(), len(context))
 
 	return context
 }
 
-// GetLastMessageID returns the last processed message ID (for testing)
-func (w *AvatarWatcher) GetLastMessageID() int64 {
-	return w.lastMessageID
+// openAIProject returns the OpenAI organization and project the conversation is billed to
+// Empty values use the server's defaults, also when the conversation cannot be read
+func (w *AvatarWatcher) openAIProject() (string, string) {
+	conv, err := w.db.GetConversation(w.conversationID)
+	if err != nil {
+		return "", ""
+	}
+	return conv.OpenAIOrganization, conv.OpenAIProject
+}
+
+// client returns the assistant client for the conversation's OpenAI project, tracing calls under ctx
+func (w *AvatarWatcher) client(ctx context.Context) *assistant.Client {
+	return w.assistant.ForProject(w.openAIProject()).WithContext(ctx)
+}
+
+// isolatedContext reports whether the avatar only sees user messages
+// It is read on every use so setting changes apply immediately
+func (w *AvatarWatcher) isolatedContext() bool {
+	avatar, err := w.db.GetAvatar(w.avatar.ID)
+	if err != nil {
+		return w.avatar.IsolatedContext
+	}
+	return avatar.IsolatedContext
+}
+
+// GetLastSequence returns the sequence number of the last processed message (for testing)
+func (w *AvatarWatcher) GetLastSequence() int64 {
+	return w.lastSequence
 }
@@ -2,15 +2,19 @@ package watcher
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
 )
 
 func TestNewAvatarWatcher(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:     1,
@@ -35,8 +39,7 @@ func TestNewAvatarWatcher(t *testing.T) {
 }
 
 func TestAvatarWatcher_StartStop(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	// Create conversation
 	conv, err := database.CreateConversation("Test Chat", "thread_123")
@@ -75,8 +78,7 @@ func TestAvatarWatcher_StartStop(t *testing.T) {
 }
 
 func TestAvatarWatcher_ShouldRespond_Mention(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:     1,
@@ -94,7 +96,7 @@ func TestAvatarWatcher_ShouldRespond_Mention(t *testing.T) {
 		SenderType: models.SenderTypeUser,
 	}
 
-	shouldRespond, err := watcher.shouldRespond(message)
+	shouldRespond, err := watcher.shouldRespond(context.Background(), message)
 	if err != nil {
 		t.Fatalf("shouldRespond failed: %v", err)
 	}
@@ -105,8 +107,7 @@ func TestAvatarWatcher_ShouldRespond_Mention(t *testing.T) {
 }
 
 func TestAvatarWatcher_ShouldRespond_NoMention(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:     1,
@@ -124,7 +125,7 @@ func TestAvatarWatcher_ShouldRespond_NoMention(t *testing.T) {
 		SenderType: models.SenderTypeUser,
 	}
 
-	shouldRespond, err := watcher.shouldRespond(message)
+	shouldRespond, err := watcher.shouldRespond(context.Background(), message)
 	if err != nil {
 		t.Fatalf("shouldRespond failed: %v", err)
 	}
@@ -136,8 +137,7 @@ func TestAvatarWatcher_ShouldRespond_NoMention(t *testing.T) {
 }
 
 func TestAvatarWatcher_ShouldRespond_CaseInsensitive(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:     1,
@@ -155,7 +155,7 @@ func TestAvatarWatcher_ShouldRespond_CaseInsensitive(t *testing.T) {
 		SenderType: models.SenderTypeUser,
 	}
 
-	shouldRespond, err := watcher.shouldRespond(message)
+	shouldRespond, err := watcher.shouldRespond(context.Background(), message)
 	if err != nil {
 		t.Fatalf("shouldRespond failed: %v", err)
 	}
@@ -165,9 +165,81 @@ func TestAvatarWatcher_ShouldRespond_CaseInsensitive(t *testing.T) {
 	}
 }
 
+func TestAvatarWatcher_ShouldRespond_TeamMention(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	alice, _ := database.CreateAvatar("Alice", "Helpful assistant", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Helpful assistant", "asst_2")
+	team, _ := database.CreateTeam("Support", "")
+	database.AddTeamMember(team.ID, alice.ID)
+
+	ctx := context.Background()
+	aliceWatcher := NewAvatarWatcher(ctx, 1, *alice, database, nil, 100*time.Millisecond, nil)
+	bobWatcher := NewAvatarWatcher(ctx, 1, *bob, database, nil, 100*time.Millisecond, nil)
+
+	message := &models.Message{
+		ID:         1,
+		Content:    "@support 誰か助けて",
+		SenderType: models.SenderTypeUser,
+	}
+
+	shouldRespond, err := aliceWatcher.shouldRespond(context.Background(), message)
+	if err != nil {
+		t.Fatalf("shouldRespond failed: %v", err)
+	}
+	if !shouldRespond {
+		t.Error("expected team member to respond to @team mention")
+	}
+
+	shouldRespond, err = bobWatcher.shouldRespond(context.Background(), message)
+	if err != nil {
+		t.Fatalf("shouldRespond failed: %v", err)
+	}
+	if shouldRespond {
+		t.Error("expected non-member not to respond to @team mention")
+	}
+}
+
+func TestAvatarWatcher_ShouldRespond_Trigger(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	other, _ := database.CreateConversation("Other Chat", "thread_456")
+	legal, _ := database.CreateAvatar("LegalBot", "Helpful assistant", "asst_1")
+	database.AddAvatarToConversation(conv.ID, legal.ID)
+	database.AddAvatarToConversation(other.ID, legal.ID)
+	database.CreateAvatarTrigger(conv.ID, legal.ID, "contract", false)
+
+	ctx := context.Background()
+	watcher := NewAvatarWatcher(ctx, conv.ID, *legal, database, nil, 100*time.Millisecond, nil)
+	otherWatcher := NewAvatarWatcher(ctx, other.ID, *legal, database, nil, 100*time.Millisecond, nil)
+
+	// No assistant is configured, so only the trigger can make the avatar respond
+	message := &models.Message{
+		ID:         1,
+		Content:    "Please check the Contract terms",
+		SenderType: models.SenderTypeUser,
+	}
+
+	shouldRespond, err := watcher.shouldRespond(ctx, message)
+	if err != nil {
+		t.Fatalf("shouldRespond failed: %v", err)
+	}
+	if !shouldRespond {
+		t.Error("expected a matching trigger to make the avatar respond")
+	}
+
+	shouldRespond, err = otherWatcher.shouldRespond(ctx, message)
+	if err != nil {
+		t.Fatalf("shouldRespond failed: %v", err)
+	}
+	if shouldRespond {
+		t.Error("expected the trigger to apply only in its own conversation")
+	}
+}
+
 func TestAvatarWatcher_CheckAndRespond_SkipsOwnMessages(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	// Create conversation
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
@@ -186,8 +258,8 @@ func TestAvatarWatcher_CheckAndRespond_SkipsOwnMessages(t *testing.T) {
 	watcher := NewAvatarWatcher(ctx, conv.ID, avatar, database, nil, 100*time.Millisecond, nil)
 
 	// Initialize and check
-	watcher.initializeLastMessageID()
-	initialLastID := watcher.GetLastMessageID()
+	watcher.initializeLastSequence()
+	initialLastSequence := watcher.GetLastSequence()
 
 	// Create another message from the same avatar (mentioning itself)
 	database.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatarID, "@TestBot another test")
@@ -198,15 +270,188 @@ func TestAvatarWatcher_CheckAndRespond_SkipsOwnMessages(t *testing.T) {
 		t.Fatalf("checkAndRespond failed: %v", err)
 	}
 
-	// lastMessageID should be updated (message was processed)
-	if watcher.GetLastMessageID() <= initialLastID {
-		t.Error("expected lastMessageID to be updated")
+	// lastSequence should be updated (message was processed)
+	if watcher.GetLastSequence() <= initialLastSequence {
+		t.Error("expected lastSequence to be updated")
+	}
+}
+
+func TestAvatarWatcher_CheckAndRespond_SkipsImportedMessages(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+	database := testutil.NewTestDB(t)
+	client := mockServer.Client()
+
+	conv, _ := database.CreateConversation("Imported", "")
+	avatar, _ := database.CreateAvatar("Alice", "Helpful assistant", "asst_1")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+
+	database.ImportMessages(conv.ID, []models.Message{
+		{SenderType: models.SenderTypeUser, Content: "@Alice are you there?", CreatedAt: time.Now().Add(-time.Hour)},
+	})
+	mention, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+
+	// A watcher started after a restart reads the imported message again, but the flag is stored with it
+	responses := 0
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second,
+		func(_ int64, msg *models.Message, _ string) { responses++ })
+	w.SetStartAfter(0)
+
+	if err := w.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+	if responses != 1 || w.GetLastSequence() < mention.Sequence {
+		t.Errorf("expected only the new mention to be answered, got %d responses and last sequence %d", responses, w.GetLastSequence())
+	}
+}
+
+func TestAvatarWatcher_CheckAndRespond_LogsCircuitSkipOnce(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	client := assistant.NewClient("test-key", assistant.WithCircuitBreaker(1, time.Minute))
+
+	conv, _ := database.CreateConversation("Test Chat", "")
+	avatar, _ := database.CreateAvatar("TestBot", "Helpful assistant", "")
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	watcher.initializeLastSequence()
+
+	client.CircuitBreaker().RecordFailure()
+	for i := 0; i < 3; i++ {
+		if err := watcher.checkAndRespond(); err != nil {
+			t.Fatalf("checkAndRespond failed: %v", err)
+		}
+	}
+	if !watcher.circuitSkipping {
+		t.Fatal("expected the watcher to remember that checks are skipped")
+	}
+
+	client.CircuitBreaker().RecordSuccess()
+	if err := watcher.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+	if watcher.circuitSkipping {
+		t.Error("expected the watcher to resume checks once the circuit closes")
+	}
+}
+
+func TestAvatarWatcher_CheckAndRespond_BoundedWindow(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "")
+	avatar, _ := database.CreateAvatar("TestBot", "Helpful assistant", "")
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, nil, 100*time.Millisecond, nil)
+	watcher.initializeLastSequence()
+
+	// The avatar's own messages are passed over, so only the window size limits each check
+	var last *models.Message
+	for i := 0; i < maxMessagesPerCheck+10; i++ {
+		last, _ = database.CreateMessage(conv.ID, models.SenderTypeAvatar, &avatar.ID, fmt.Sprintf("message %d", i))
+	}
+
+	if err := watcher.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+	if watcher.GetLastSequence() != maxMessagesPerCheck {
+		t.Errorf("expected the first check to read %d messages, last sequence %d", maxMessagesPerCheck, watcher.GetLastSequence())
+	}
+
+	watcher.checkAndRespond()
+	if watcher.GetLastSequence() != last.Sequence {
+		t.Errorf("expected the second check to read the rest, last sequence %d", watcher.GetLastSequence())
+	}
+
+	// Nothing new: the check stops at the latest sequence
+	if err := watcher.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+	if watcher.GetLastSequence() != last.Sequence {
+		t.Errorf("expected last sequence to stay at %d, got %d", last.Sequence, watcher.GetLastSequence())
+	}
+}
+
+func TestAvatarWatcher_CheckAndRespond_SkipsWhileCircuitOpen(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+
+	avatar := models.Avatar{
+		ID:     1,
+		Name:   "TestBot",
+		Prompt: "Helpful assistant",
+	}
+
+	client := assistant.NewClient("test-api-key", assistant.WithCircuitBreaker(1, time.Minute))
+	client.CircuitBreaker().RecordFailure()
+
+	ctx := context.Background()
+	watcher := NewAvatarWatcher(ctx, conv.ID, avatar, database, client, 100*time.Millisecond, nil)
+	watcher.initializeLastSequence()
+	initialLastSequence := watcher.GetLastSequence()
+
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@TestBot hello")
+
+	if err := watcher.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+
+	// The message must stay pending so it is handled once the API recovers
+	if watcher.GetLastSequence() != initialLastSequence {
+		t.Errorf("expected lastSequence to stay %d while circuit is open, got %d",
+			initialLastSequence, watcher.GetLastSequence())
+	}
+}
+
+func TestAvatarWatcher_CheckAndRespond_WaitsForOfflineReplay(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	created, _ := database.CreateAvatar("TestBot", "Helpful assistant", "")
+	avatar := *created
+
+	ctx := context.Background()
+	watcher := NewAvatarWatcher(ctx, conv.ID, avatar, database, nil, 100*time.Millisecond, nil)
+	watcher.initializeLastSequence()
+	initialLastSequence := watcher.GetLastSequence()
+
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	forward, _ := database.EnqueueOfflineForward(conv.ID, msg.ID, avatar.ID, "thread_1", "hello")
+
+	watcher.checkAndRespond()
+	if watcher.GetLastSequence() != initialLastSequence {
+		t.Errorf("expected lastSequence to stay %d while messages are queued, got %d",
+			initialLastSequence, watcher.GetLastSequence())
+	}
+
+	database.DeleteOfflineForward(forward.ID)
+	watcher.checkAndRespond()
+	if watcher.GetLastSequence() != msg.Sequence {
+		t.Errorf("expected lastSequence %d after replay, got %d", msg.Sequence, watcher.GetLastSequence())
+	}
+}
+
+func TestAvatarWatcher_InitializeLastSequence_OfflineQueue(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	created, _ := database.CreateAvatar("TestBot", "Helpful assistant", "")
+
+	first, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "before outage")
+	queued, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "during outage")
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "also during outage")
+	database.EnqueueOfflineForward(conv.ID, queued.ID, created.ID, "thread_1", "during outage")
+
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *created, database, nil, 100*time.Millisecond, nil)
+	if err := watcher.initializeLastSequence(); err != nil {
+		t.Fatalf("initializeLastSequence failed: %v", err)
+	}
+
+	// Queued messages must still be evaluated after a restart
+	if watcher.GetLastSequence() != first.Sequence {
+		t.Errorf("expected lastSequence %d, got %d", first.Sequence, watcher.GetLastSequence())
 	}
 }
 
 func TestAvatarWatcher_BuildJudgmentPrompt(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:     1,
@@ -237,9 +482,22 @@ func TestAvatarWatcher_BuildJudgmentPrompt(t *testing.T) {
 	}
 }
 
+func TestAvatarWatcher_BuildJudgmentPrompt_UpdatedPrompt(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	avatar, _ := database.CreateAvatar("Alice", "Old prompt", "asst_1")
+
+	watcher := NewAvatarWatcher(context.Background(), 1, *avatar, database, nil, 100*time.Millisecond, nil)
+
+	// A prompt updated through the avatar API applies to the running watcher
+	database.UpdateAvatar(avatar.ID, "Alice", "New prompt", "asst_1")
+	prompt := watcher.buildJudgmentPrompt("hello")
+	if !contains(prompt, "New prompt") || contains(prompt, "Old prompt") {
+		t.Errorf("expected the updated prompt, got %q", prompt)
+	}
+}
+
 func TestAvatarWatcher_BuildJudgmentPrompt_WithContext(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:     1,
@@ -274,9 +532,141 @@ func TestAvatarWatcher_BuildJudgmentPrompt_WithContext(t *testing.T) {
 	}
 }
 
+func TestAvatarWatcher_BuildReferencedConversationsContext(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	referenced, _ := database.CreateConversation("Planning", "")
+	current, _ := database.CreateConversation("Follow-up", "")
+	database.CreateMessage(referenced.ID, models.SenderTypeUser, nil, "Release on Friday")
+
+	avatar := models.Avatar{ID: 1, Name: "Alice", Prompt: "Helpful assistant"}
+	ctx := context.Background()
+	watcher := NewAvatarWatcher(ctx, current.ID, avatar, database, nil, 100*time.Millisecond, nil)
+
+	message := &models.Message{
+		ID:      1,
+		Content: fmt.Sprintf("What did we decide in conversation #%d?", referenced.ID),
+	}
+
+	refContext := watcher.buildReferencedConversationsContext(message)
+	if !contains(refContext, "Planning") {
+		t.Error("context should contain referenced conversation title")
+	}
+	if !contains(refContext, "Release on Friday") {
+		t.Error("context should contain referenced conversation messages")
+	}
+
+	// Messages without references add no context
+	if got := watcher.buildReferencedConversationsContext(&models.Message{Content: "hello"}); got != "" {
+		t.Errorf("expected empty context, got %q", got)
+	}
+}
+
+func TestAvatarWatcher_IsolatedContext(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Isolation", "")
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	judge, _ := database.CreateAvatar("Judge", "prompt", "asst_2")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+	database.AddAvatarToConversation(conv.ID, judge.ID)
+	database.UpdateAvatarIsolatedContext(judge.ID, true)
+
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Which plan is better?")
+	fromAlice, _ := database.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Plan A, clearly")
+
+	responses := 0
+	w := NewAvatarWatcher(context.Background(), conv.ID, *judge, database, nil, time.Second,
+		func(int64, *models.Message, string) { responses++ })
+
+	// Other avatars' messages are ignored, even when they mention the avatar
+	fromAlice.Content = "@Judge Plan A, clearly"
+	if err := w.handleMessage(fromAlice); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+	if responses != 0 {
+		t.Errorf("expected an isolated avatar to ignore avatar messages, got %d responses", responses)
+	}
+
+	history := w.buildConversationContext()
+	if !strings.Contains(history, "Which plan is better?") || strings.Contains(history, "Plan A") {
+		t.Errorf("expected only the user's messages in the history, got %q", history)
+	}
+
+	// Turning isolation off applies to the next run
+	database.UpdateAvatarIsolatedContext(judge.ID, false)
+	if history := w.buildConversationContext(); !strings.Contains(history, "Plan A") {
+		t.Errorf("expected other avatars' messages once isolation is off, got %q", history)
+	}
+}
+
+func TestAvatarWatcher_BuildRunInstructions_SystemInstructions(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "Helpful assistant", "asst_1")
+	conv, _ := database.CreateConversationWithSettings("Room", "", "brief", "", "Speak in formal English.", 0)
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, nil, 100*time.Millisecond, nil)
+
+	instructions := watcher.buildRunInstructions(nil)
+	styleAt := strings.Index(instructions, "【Response Style】")
+	customAt := strings.Index(instructions, "【Conversation Instructions】")
+	if styleAt < 0 || customAt < styleAt || !strings.HasSuffix(instructions, "Speak in formal English.") {
+		t.Errorf("expected the conversation instructions after the response style, got %q", instructions)
+	}
+
+	// Changes apply to the next run
+	conv.SystemInstructions = ""
+	database.UpdateConversation(conv)
+	if got := watcher.buildRunInstructions(nil); strings.Contains(got, "【Conversation Instructions】") {
+		t.Errorf("expected removed instructions to be left out, got %q", got)
+	}
+}
+
+func TestAvatarWatcher_BuildRunInstructions_PromptVariables(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "Host of {{conversation_title}} with {{participants}}", "asst_1")
+	conv, _ := database.CreateConversation("Room", "")
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, nil, 100*time.Millisecond, nil)
+	watcher.SetConversationContext("Room", []string{"ユーザ", "Alice"})
+
+	// The current title is used so renames apply to the next run
+	conv.Title = "Kyoto trip"
+	database.UpdateConversation(conv)
+
+	instructions := watcher.buildRunInstructions(nil)
+	if !strings.Contains(instructions, "Host of Kyoto trip with ユーザ, Alice") {
+		t.Errorf("expected the resolved prompt in the run instructions, got %q", instructions)
+	}
+}
+
+func TestAvatarWatcher_BuildRunInstructions_TimeContext(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "Helpful assistant", "asst_1")
+	conv, _ := database.CreateConversation("Room", "")
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, nil, 100*time.Millisecond, nil)
+
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Standup at 10")
+	message, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "How did it go?")
+
+	// Watchers created outside the manager leave the time out
+	if got := watcher.buildRunInstructions(message); strings.Contains(got, "【Current Time】") {
+		t.Errorf("expected no time context, got %q", got)
+	}
+
+	watcher.timeZone = time.FixedZone("JST", 9*60*60)
+	instructions := watcher.buildRunInstructions(message)
+	if !strings.Contains(instructions, "【Current Time】") || !strings.Contains(instructions, "(JST)") {
+		t.Errorf("expected the current time in the run instructions, got %q", instructions)
+	}
+	if !strings.Contains(instructions, "The previous message in this conversation was sent just now.") {
+		t.Errorf("expected the time since the previous message, got %q", instructions)
+	}
+}
+
 func TestAvatarWatcher_SetConversationContext(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	avatar := models.Avatar{
 		ID:   1,
@@ -311,9 +701,8 @@ func containsHelper(s, substr string) bool {
 	return false
 }
 
-func TestAvatarWatcher_InitializeLastMessageID(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+func TestAvatarWatcher_InitializeLastSequence(t *testing.T) {
+	database := testutil.NewTestDB(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 
@@ -330,19 +719,18 @@ func TestAvatarWatcher_InitializeLastMessageID(t *testing.T) {
 	ctx := context.Background()
 	watcher := NewAvatarWatcher(ctx, conv.ID, avatar, database, nil, 100*time.Millisecond, nil)
 
-	err := watcher.initializeLastMessageID()
+	err := watcher.initializeLastSequence()
 	if err != nil {
-		t.Fatalf("initializeLastMessageID failed: %v", err)
+		t.Fatalf("initializeLastSequence failed: %v", err)
 	}
 
-	if watcher.GetLastMessageID() != msg2.ID {
-		t.Errorf("expected lastMessageID to be %d, got %d", msg2.ID, watcher.GetLastMessageID())
+	if watcher.GetLastSequence() != msg2.Sequence {
+		t.Errorf("expected lastSequence to be %d, got %d", msg2.Sequence, watcher.GetLastSequence())
 	}
 }
 
-func TestAvatarWatcher_InitializeLastMessageID_Empty(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+func TestAvatarWatcher_InitializeLastSequence_Empty(t *testing.T) {
+	database := testutil.NewTestDB(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 
@@ -355,13 +743,13 @@ func TestAvatarWatcher_InitializeLastMessageID_Empty(t *testing.T) {
 	ctx := context.Background()
 	watcher := NewAvatarWatcher(ctx, conv.ID, avatar, database, nil, 100*time.Millisecond, nil)
 
-	err := watcher.initializeLastMessageID()
+	err := watcher.initializeLastSequence()
 	if err != nil {
-		t.Fatalf("initializeLastMessageID failed: %v", err)
+		t.Fatalf("initializeLastSequence failed: %v", err)
 	}
 
-	if watcher.GetLastMessageID() != 0 {
-		t.Errorf("expected lastMessageID to be 0 for empty conversation, got %d", watcher.GetLastMessageID())
+	if watcher.GetLastSequence() != 0 {
+		t.Errorf("expected lastSequence to be 0 for empty conversation, got %d", watcher.GetLastSequence())
 	}
 }
 
@@ -401,3 +789,125 @@ func TestGetRandomInterval_Variance(t *testing.T) {
 	}
 }
 
+
+func TestAvatarWatcher_SetStartAfter(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	avatar := models.Avatar{ID: 1, Name: "TestBot", Prompt: "Helpful assistant"}
+
+	// The first message is saved before the watcher starts
+	msg, err := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hello")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, avatar, database, nil, time.Hour, nil)
+	watcher.SetStartAfter(msg.Sequence - 1)
+	watcher.Start()
+	watcher.Stop()
+
+	// The watcher must not initialize past the message
+	if watcher.GetLastSequence() != msg.Sequence-1 {
+		t.Errorf("expected lastSequence %d, got %d", msg.Sequence-1, watcher.GetLastSequence())
+	}
+}
+
+func TestCodeInterpreterArtifacts(t *testing.T) {
+	calls := []assistant.CodeInterpreterCall{{
+		Input: "plt.plot(xs)",
+		Outputs: []assistant.CodeInterpreterOutput{
+			{Type: "logs", Logs: "done"},
+			{Type: "image", Image: &assistant.CodeInterpreterImage{FileID: "file_plot"}},
+			{Type: "logs"},
+		},
+	}}
+
+	artifacts := codeInterpreterArtifacts(calls)
+	if len(artifacts) != 3 {
+		t.Fatalf("expected 3 artifacts, got %+v", artifacts)
+	}
+	if artifacts[0].Type != models.ArtifactTypeCode || artifacts[0].Content != "plt.plot(xs)" {
+		t.Errorf("unexpected code artifact: %+v", artifacts[0])
+	}
+	if artifacts[1].Type != models.ArtifactTypeLogs || artifacts[1].Content != "done" {
+		t.Errorf("unexpected logs artifact: %+v", artifacts[1])
+	}
+	if artifacts[2].Type != models.ArtifactTypeImage || artifacts[2].FileID != "file_plot" {
+		t.Errorf("unexpected image artifact: %+v", artifacts[2])
+	}
+}
+
+func TestAvatarWatcher_RecordsMessageModel(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+	database := testutil.NewTestDB(t)
+	client := mockServer.Client()
+
+	conv, _ := database.CreateConversation("Labels", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+
+	var broadcast *models.Message
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second,
+		func(_ int64, msg *models.Message, _ string) { broadcast = msg })
+
+	trigger, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+	if err := w.handleMessage(trigger); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+
+	if broadcast == nil || broadcast.Model != testutil.MockModel {
+		t.Fatalf("expected the response to carry the run's model, got %+v", broadcast)
+	}
+	if event := models.NewMessageEvent(broadcast); !event.AILabel.AIGenerated || event.AILabel.Model != testutil.MockModel {
+		t.Errorf("unexpected AI label in the message event: %+v", event.AILabel)
+	}
+	if messageModels, _ := database.GetConversationMessageModels(conv.ID); messageModels[broadcast.ID] != testutil.MockModel {
+		t.Errorf("expected the model to be stored, got %v", messageModels)
+	}
+}
+
+func TestMessageCitations(t *testing.T) {
+	citations := messageCitations([]assistant.Citation{{
+		Type: assistant.AnnotationFileCitation, Text: "【4:0†source】", FileID: "file_report",
+		Filename: "report.pdf", StartIndex: 10, EndIndex: 22,
+	}})
+	if len(citations) != 1 || citations[0].Marker != "【4:0†source】" || citations[0].Filename != "report.pdf" {
+		t.Fatalf("unexpected citations: %+v", citations)
+	}
+
+	// Broadcast payloads use the same fields as the messages API
+	event := models.NewMessageEvent(&models.Message{Citations: citations})
+	data, _ := json.Marshal(event.Citations[0])
+	if string(data) != `{"type":"file_citation","marker":"【4:0†source】","file_id":"file_report","filename":"report.pdf","start_index":10,"end_index":22}` {
+		t.Errorf("unexpected citation data: %s", data)
+	}
+}
+
+func TestAvatarWatcher_ResumesFromSavedState(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	created, _ := database.CreateAvatar("TestBot", "Helpful assistant", "")
+
+	first, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "handled")
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *created, database, nil, 100*time.Millisecond, nil)
+	watcher.SetStartAfter(0)
+	if err := watcher.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+	if saved, err := database.GetWatcherState(conv.ID, created.ID); err != nil || saved != first.Sequence {
+		t.Fatalf("expected saved sequence %d, got %d err=%v", first.Sequence, saved, err)
+	}
+
+	// Messages stored after the last check are handled by the next watcher of the avatar
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "not handled before the restart")
+	restarted := NewAvatarWatcher(context.Background(), conv.ID, *created, database, nil, 100*time.Millisecond, nil)
+	if err := restarted.initializeLastSequence(); err != nil {
+		t.Fatalf("initializeLastSequence failed: %v", err)
+	}
+	if restarted.GetLastSequence() != first.Sequence {
+		t.Errorf("expected the watcher to resume after sequence %d, got %d", first.Sequence, restarted.GetLastSequence())
+	}
+}
@@ -0,0 +1,51 @@
+package watcher
+
+import "time"
+
+const (
+	// defaultFailureBackoff is the pause after the first failed check of a watcher polling at random intervals
+	defaultFailureBackoff = 10 * time.Second
+	// maxFailureBackoff caps the pause after consecutive failed checks
+	maxFailureBackoff = 5 * time.Minute
+)
+
+// failureBackoff pauses a watcher's checks after consecutive failures
+// Each failure doubles the pause, starting at base and capped at max; a successful check ends it.
+// It is used only from the watcher's own goroutine and needs no locking
+type failureBackoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures int
+	until    time.Time
+}
+
+func newFailureBackoff(base time.Duration) failureBackoff {
+	return failureBackoff{base: base, max: maxFailureBackoff}
+}
+
+// waiting reports whether checks are paused at now
+func (b *failureBackoff) waiting(now time.Time) bool {
+	return now.Before(b.until)
+}
+
+// fail records a failed check at now and returns the pause before the next one
+func (b *failureBackoff) fail(now time.Time) time.Duration {
+	b.failures++
+	pause := b.base
+	for i := 1; i < b.failures && pause < b.max; i++ {
+		pause *= 2
+	}
+	if pause > b.max {
+		pause = b.max
+	}
+	b.until = now.Add(pause)
+	return pause
+}
+
+// succeed ends the pause and returns the number of failures before it
+func (b *failureBackoff) succeed() int {
+	failures := b.failures
+	b.failures = 0
+	b.until = time.Time{}
+	return failures
+}
@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestFailureBackoff(t *testing.T) {
+	backoff := newFailureBackoff(time.Second)
+	now := time.Now()
+
+	if backoff.waiting(now) {
+		t.Error("expected no pause before any failure")
+	}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if pause := backoff.fail(now); pause != want {
+			t.Errorf("failure %d: expected pause %v, got %v", i+1, want, pause)
+		}
+	}
+	if !backoff.waiting(now.Add(3*time.Second)) || backoff.waiting(now.Add(4*time.Second)) {
+		t.Error("expected checks to pause for the last pause only")
+	}
+
+	for i := 0; i < 20; i++ {
+		backoff.fail(now)
+	}
+	if pause := backoff.fail(now); pause != maxFailureBackoff {
+		t.Errorf("expected the pause to be capped at %v, got %v", maxFailureBackoff, pause)
+	}
+
+	if failures := backoff.succeed(); failures != 24 {
+		t.Errorf("expected 24 failures before success, got %d", failures)
+	}
+	if backoff.waiting(now) {
+		t.Error("expected success to end the pause")
+	}
+	if pause := backoff.fail(now); pause != time.Second {
+		t.Errorf("expected the pause to start over after success, got %v", pause)
+	}
+}
+
+func TestAvatarWatcher_CheckBacksOff(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Backoff", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "")
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, nil, time.Hour, nil)
+	w.check()
+	if w.backoff.failures != 0 {
+		t.Fatalf("expected a working check not to back off, got %d failures", w.backoff.failures)
+	}
+
+	// Checks fail while the database is closed, and the next check waits out the pause
+	database.Close()
+	w.check()
+	w.check()
+	if w.backoff.failures != 1 || !w.backoff.waiting(time.Now()) {
+		t.Errorf("expected one failure and a pause, got %d failures", w.backoff.failures)
+	}
+}
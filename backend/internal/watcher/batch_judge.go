@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// batchResultTTL is how long batched decisions are kept for watchers that have not polled yet
+	batchResultTTL = 10 * time.Minute
+	// batchJudgmentBaseTokens and batchJudgmentTokensPerAvatar size the completion for the JSON answer
+	batchJudgmentBaseTokens      = 20
+	batchJudgmentTokensPerAvatar = 15
+)
+
+// errNotInBatch is returned when an avatar was not part of the batch for a message
+// (e.g. it joined after the message was judged); the caller falls back to a single judgment
+var errNotInBatch = errors.New("avatar not included in batched judgment")
+
+// batchResult holds the decisions of one batched judgment
+type batchResult struct {
+	done      chan struct{}
+	decisions map[int64]bool
+	err       error
+	createdAt time.Time
+}
+
+// BatchJudge evaluates all avatars of a conversation with a single LLM call per message
+// The first watcher to judge a message runs the call; the other watchers reuse its result
+type BatchJudge struct {
+	db        *db.DB
+	assistant *assistant.Client
+	// instructions adds the safety preamble to the judgment prompt
+	instructions logic.InstructionsBuilder
+	mu           sync.Mutex
+	results      map[int64]*batchResult // messageID -> result
+}
+
+// NewBatchJudge creates a new batched judgment coordinator
+func NewBatchJudge(database *db.DB, assistantClient *assistant.Client) *BatchJudge {
+	return &BatchJudge{
+		db:        database,
+		assistant: assistantClient,
+		results:   make(map[int64]*batchResult),
+	}
+}
+
+// Judge returns whether the avatar should respond to the message
+func (j *BatchJudge) Judge(message *models.Message, topic string, participantNames []string, avatarID int64) (bool, error) {
+	j.mu.Lock()
+	j.pruneLocked()
+	result, exists := j.results[message.ID]
+	if !exists {
+		result = &batchResult{done: make(chan struct{}), createdAt: time.Now()}
+		j.results[message.ID] = result
+	}
+	j.mu.Unlock()
+
+	if !exists {
+		result.decisions, result.err = j.evaluate(message, topic, participantNames)
+		close(result.done)
+	} else {
+		<-result.done
+	}
+
+	if result.err != nil {
+		return false, result.err
+	}
+
+	decision, ok := result.decisions[avatarID]
+	if !ok {
+		return false, errNotInBatch
+	}
+	return decision, nil
+}
+
+// evaluate runs one completion covering every avatar in the conversation except the sender
+func (j *BatchJudge) evaluate(message *models.Message, topic string, participantNames []string) (map[int64]bool, error) {
+	avatars, err := j.db.GetConversationAvatars(message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := make(map[int64]bool)
+
+	var candidates []logic.JudgmentCandidate
+	var names []string
+	ids := make(map[string]int64)
+	for _, a := range avatars {
+		if a.OpenAIAssistantID == "" {
+			continue
+		}
+		if message.SenderType == models.SenderTypeAvatar && message.SenderID != nil && *message.SenderID == a.ID {
+			continue
+		}
+		// Avatars rejected by the local pre-filter are left out of the prompt
+		if !logic.PassesPrefilter(message.Content, a.Keywords, a.Prompt, a.RelevanceThreshold) {
+			decisions[a.ID] = false
+			continue
+		}
+		candidates = append(candidates, logic.JudgmentCandidate{Name: a.Name, Prompt: a.Prompt})
+		names = append(names, a.Name)
+		ids[a.Name] = a.ID
+	}
+
+	if len(candidates) == 0 {
+		return decisions, nil
+	}
+
+	prompt := j.instructions.BatchJudgmentPrompt(topic, participantNames, candidates, message.Content)
+	maxTokens := batchJudgmentBaseTokens + batchJudgmentTokensPerAvatar*len(candidates)
+
+	client := j.assistant
+	if conv, err := j.db.GetConversation(message.ConversationID); err == nil {
+		client = client.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+	}
+	response, err := client.Completion(prompt, maxTokens)
+	if err != nil {
+		log.Printf("[BatchJudge] LLM judgment failed message_id=%d err=%v", message.ID, err)
+		return nil, err
+	}
+
+	parsed, ok := logic.ParseBatchJudgment(response, names)
+	if !ok {
+		return nil, fmt.Errorf("invalid batched judgment response: %q", response)
+	}
+
+	// Avatars missing from the answer do not respond
+	for name, id := range ids {
+		decisions[id] = parsed[name]
+	}
+
+	log.Printf("[BatchJudge] LLM judgment message_id=%d conversation_id=%d avatars=%d decisions=%v",
+		message.ID, message.ConversationID, len(candidates), parsed)
+
+	return decisions, nil
+}
+
+// pruneLocked removes finished results older than batchResultTTL
+func (j *BatchJudge) pruneLocked() {
+	cutoff := time.Now().Add(-batchResultTTL)
+	for id, result := range j.results {
+		if result.createdAt.After(cutoff) {
+			continue
+		}
+		select {
+		case <-result.done:
+			delete(j.results, id)
+		default:
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// newCompletionServer answers every chat completion with the given content and counts the calls
+func newCompletionServer(t *testing.T, content string, calls *atomic.Int32, model *atomic.Value) *assistant.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		model.Store(req.Model)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"content": content}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("mock-api-key",
+		assistant.WithJudgmentModel("judge-model"),
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+}
+
+func TestBatchJudge_OneCallForAllAvatars(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Dinner", "")
+	chef, _ := database.CreateAvatar("Chef", "A chef", "asst_chef")
+	pilot, _ := database.CreateAvatar("Pilot", "A pilot", "asst_pilot")
+	database.AddAvatarToConversation(conv.ID, chef.ID)
+	database.AddAvatarToConversation(conv.ID, pilot.ID)
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "What should we cook?")
+
+	var calls atomic.Int32
+	var model atomic.Value
+	client := newCompletionServer(t, `{"Chef": true, "Pilot": false}`, &calls, &model)
+	judge := NewBatchJudge(database, client)
+
+	var wg sync.WaitGroup
+	results := make(map[int64]bool)
+	var mu sync.Mutex
+	for _, id := range []int64{chef.ID, pilot.ID} {
+		wg.Add(1)
+		go func(avatarID int64) {
+			defer wg.Done()
+			decision, err := judge.Judge(msg, conv.Title, nil, avatarID)
+			if err != nil {
+				t.Errorf("judge failed: %v", err)
+			}
+			mu.Lock()
+			results[avatarID] = decision
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 completion call, got %d", calls.Load())
+	}
+	if model.Load() != "judge-model" {
+		t.Errorf("expected configured judgment model, got %v", model.Load())
+	}
+	if !results[chef.ID] || results[pilot.ID] {
+		t.Errorf("unexpected decisions: %v", results)
+	}
+}
+
+func TestBatchJudge_AvatarNotInBatch(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Dinner", "")
+	chef, _ := database.CreateAvatar("Chef", "A chef", "asst_chef")
+	database.AddAvatarToConversation(conv.ID, chef.ID)
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hello")
+
+	var calls atomic.Int32
+	var model atomic.Value
+	judge := NewBatchJudge(database, newCompletionServer(t, `{"Chef": true}`, &calls, &model))
+
+	if _, err := judge.Judge(msg, conv.Title, nil, 999); err != errNotInBatch {
+		t.Errorf("expected errNotInBatch, got %v", err)
+	}
+}
+
+func TestAvatarWatcher_ShouldRespond_BatchJudgment(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Dinner", "")
+	chef, _ := database.CreateAvatar("Chef", "A chef", "asst_chef")
+	database.AddAvatarToConversation(conv.ID, chef.ID)
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "What should we cook?")
+
+	var calls atomic.Int32
+	var model atomic.Value
+	client := newCompletionServer(t, "```json\n{\"Chef\": true}\n```", &calls, &model)
+
+	manager := NewManager(database, client, 0)
+	manager.SetBatchJudgment(true)
+
+	watcher := NewAvatarWatcher(manager.ctx, conv.ID, *chef, database, client, 0, nil)
+	watcher.batchJudge = manager.batchJudge
+
+	shouldRespond, err := watcher.shouldRespond(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("shouldRespond failed: %v", err)
+	}
+	if !shouldRespond {
+		t.Error("expected batched judgment to select the avatar")
+	}
+	if model.Load() != "judge-model" {
+		t.Errorf("unexpected model %v", model.Load())
+	}
+}
+
+func TestAvatarWatcher_ShouldRespond_PrefilterSkipsLLM(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Dinner", "")
+	chef, _ := database.CreateAvatar("Chef", "You are a chef who loves cooking", "asst_chef")
+	database.AddAvatarToConversation(conv.ID, chef.ID)
+	database.UpdateAvatarPrefilter(chef.ID, []string{"pasta"}, 0.5)
+
+	var calls atomic.Int32
+	var model atomic.Value
+	client := newCompletionServer(t, "yes", &calls, &model)
+	watcher := NewAvatarWatcher(context.Background(), conv.ID, *chef, database, client, 0, nil)
+
+	irrelevant, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "airplane engine maintenance")
+	if shouldRespond, _ := watcher.shouldRespond(context.Background(), irrelevant); shouldRespond {
+		t.Error("expected irrelevant message to be filtered")
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no LLM call for a filtered message, got %d", calls.Load())
+	}
+
+	relevant, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "any pasta ideas?")
+	if shouldRespond, _ := watcher.shouldRespond(context.Background(), relevant); !shouldRespond {
+		t.Error("expected keyword match to reach LLM judgment")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 LLM call, got %d", calls.Load())
+	}
+}
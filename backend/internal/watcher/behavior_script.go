@@ -0,0 +1,195 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/script"
+)
+
+// behaviorRecentMessages is how many of the latest messages a behavior script can read
+const behaviorRecentMessages = 10
+
+// behaviorWebhookTimeout bounds a behavior script webhook call
+const behaviorWebhookTimeout = 5 * time.Second
+
+// behaviorWebhookClient sends the webhooks requested by behavior scripts
+// It checks the address a host name resolved to when connecting, so a public name cannot lead to a private address,
+// does not use a proxy that would connect for it, and does not follow redirects
+var behaviorWebhookClient = newBehaviorWebhookClient(refusePrivateAddress)
+
+// allowedWebhookClient sends the webhooks to the hosts allowed in the server configuration
+var allowedWebhookClient = newBehaviorWebhookClient(nil)
+
+func newBehaviorWebhookClient(control func(network, address string, conn syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: behaviorWebhookTimeout, Control: control}
+	return &http.Client{
+		Timeout:   behaviorWebhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: behaviorWebhookTimeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refusePrivateAddress stops a webhook connection to a local or private address
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if addr, err := netip.ParseAddr(host); err != nil || !logic.PublicAddress(addr) {
+		return fmt.Errorf("webhooks cannot call the local or private address %s", host)
+	}
+	return nil
+}
+
+// SetBehaviorWebhookHosts sets the hosts behavior script webhooks may call even though they are local or private,
+// such as an internal automation server. Only watchers started afterwards use it
+func (m *WatcherManager) SetBehaviorWebhookHosts(hosts []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhookHosts = hosts
+}
+
+// behaviorScript is a compiled behavior script, kept until the avatar's source changes
+type behaviorScript struct {
+	source  string
+	program *script.Program
+}
+
+// runBehaviorScript runs the avatar's behavior script for a message and sends the webhooks it asked for
+// Returns nil when the avatar has no script or the script failed; failures are recorded on the script
+// so the normal judgment applies instead
+func (w *AvatarWatcher) runBehaviorScript(ctx context.Context, msg *models.Message) *logic.BehaviorResult {
+	stored, err := w.db.WithContext(ctx).GetAvatarScript(w.avatar.ID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[AvatarWatcher] Warning: failed to get behavior script avatar_id=%d err=%v", w.avatar.ID, err)
+		}
+		return nil
+	}
+
+	if w.script == nil || w.script.source != stored.Source {
+		program, err := logic.CompileBehaviorScript(stored.Source)
+		if err != nil {
+			w.recordBehaviorScriptError(msg, err)
+			return nil
+		}
+		w.script = &behaviorScript{source: stored.Source, program: program}
+	}
+
+	result, err := logic.RunBehaviorScript(w.script.program, w.behaviorInput(ctx, msg))
+	if err != nil {
+		w.recordBehaviorScriptError(msg, err)
+		return nil
+	}
+	for _, line := range result.Output {
+		log.Printf("[AvatarWatcher] Behavior script output message_id=%d avatar_name=%s: %s", msg.ID, w.avatarName(), line)
+	}
+	log.Printf("[AvatarWatcher] Behavior script ran message_id=%d avatar_name=%s decision=%q context=%d webhooks=%d",
+		msg.ID, w.avatarName(), result.Decision, len(result.Context), len(result.Webhooks))
+
+	for _, hook := range result.Webhooks {
+		go sendBehaviorWebhook(msg.ID, w.avatarName(), hook, w.webhookHosts)
+	}
+	return result
+}
+
+func (w *AvatarWatcher) recordBehaviorScriptError(msg *models.Message, err error) {
+	log.Printf("[AvatarWatcher] Behavior script failed message_id=%d avatar_name=%s err=%v", msg.ID, w.avatarName(), err)
+	if err := w.db.RecordAvatarScriptError(w.avatar.ID, err.Error()); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to record behavior script error avatar_id=%d err=%v", w.avatar.ID, err)
+	}
+}
+
+// behaviorInput collects the message and conversation state passed to a behavior script
+func (w *AvatarWatcher) behaviorInput(ctx context.Context, msg *models.Message) logic.BehaviorInput {
+	title, participantNames := w.conversationContext()
+	database := w.db.WithContext(ctx)
+
+	avatarNames := make(map[int64]string)
+	if avatars, err := database.GetConversationAvatars(w.conversationID); err == nil {
+		for _, a := range avatars {
+			avatarNames[a.ID] = a.Name
+		}
+	}
+	toBehavior := func(m *models.Message) logic.BehaviorMessage {
+		bm := logic.BehaviorMessage{ID: m.ID, SenderType: string(m.SenderType), Content: m.Content}
+		switch {
+		case m.SenderType == models.SenderTypeUser:
+			bm.SenderName = "User"
+		case m.SenderID != nil:
+			bm.SenderName = avatarNames[*m.SenderID]
+		}
+		return bm
+	}
+
+	in := logic.BehaviorInput{
+		AvatarName:        w.avatarName(),
+		Message:           toBehavior(msg),
+		ConversationID:    w.conversationID,
+		ConversationTitle: title,
+		Participants:      participantNames,
+		WebhookHosts:      w.webhookHosts,
+	}
+
+	// Recent messages come newest first; scripts read them oldest first like the conversation
+	// An avatar with isolated context does not see the other avatars' messages here either
+	isolated := w.isolatedContext()
+	recent, err := database.GetRecentMessages(w.conversationID, behaviorRecentMessages)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to get recent messages for behavior script conversation_id=%d err=%v",
+			w.conversationID, err)
+	}
+	for i := len(recent) - 1; i >= 0; i-- {
+		if isolated && recent[i].SenderType == models.SenderTypeAvatar && (recent[i].SenderID == nil || *recent[i].SenderID != w.avatar.ID) {
+			continue
+		}
+		in.Recent = append(in.Recent, toBehavior(&recent[i]))
+	}
+	return in
+}
+
+// sendBehaviorWebhook posts the payload of a behavior script webhook as JSON
+// Only the allowed hosts may resolve to local or private addresses
+func sendBehaviorWebhook(messageID int64, avatarName string, hook logic.BehaviorWebhook, allowedHosts []string) {
+	body, err := json.Marshal(hook.Payload)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Behavior webhook payload invalid message_id=%d avatar_name=%s err=%v", messageID, avatarName, err)
+		return
+	}
+
+	client := behaviorWebhookClient
+	if u, err := url.Parse(hook.URL); err == nil {
+		for _, allowed := range allowedHosts {
+			if strings.EqualFold(u.Hostname(), allowed) {
+				client = allowedWebhookClient
+			}
+		}
+	}
+
+	resp, err := client.Post(hook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[AvatarWatcher] Behavior webhook failed message_id=%d avatar_name=%s url=%s err=%v",
+			messageID, avatarName, hook.URL, err)
+		return
+	}
+	resp.Body.Close()
+
+	log.Printf("[AvatarWatcher] Behavior webhook sent message_id=%d avatar_name=%s url=%s status=%d",
+		messageID, avatarName, hook.URL, resp.StatusCode)
+}
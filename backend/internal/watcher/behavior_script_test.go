@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestAvatarWatcher_BehaviorScript(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+	database := testutil.NewTestDB(t)
+	client := mockServer.Client()
+
+	conv, _ := database.CreateConversation("Behavior script", "")
+	avatar, _ := database.CreateAvatar("Alice", "Own prompt", "asst_1")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+
+	hooks := make(chan map[string]any, 1)
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		hooks <- payload
+	}))
+	t.Cleanup(hookServer.Close)
+
+	database.SetAvatarScript(avatar.ID, `
+if message.content.startswith("deploy"):
+    respond()
+    add_context("Deploys are frozen this week.")
+    webhook("`+hookServer.URL+`", {"message": message.content, "sender": message.sender_name})
+elif avatar.name in message.mentions:
+    skip()
+`)
+
+	responses := 0
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second,
+		func(_ int64, msg *models.Message, _ string) { responses++ })
+	// The test server listens on a loopback address, which webhooks may only call when allowed
+	w.webhookHosts = []string{"127.0.0.1"}
+
+	// The script skips a direct mention
+	mention, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+	if err := w.handleMessage(mention); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+	if responses != 0 {
+		t.Fatalf("expected the script to skip the mention, got %d responses", responses)
+	}
+
+	// The script responds without a mention and adds to the run instructions
+	deploy, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "deploy today?")
+	if err := w.handleMessage(deploy); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+	if responses != 1 {
+		t.Fatalf("expected the script to respond, got %d responses", responses)
+	}
+	if runs := mockServer.RunAdditionalInstructions(); len(runs) != 1 || !strings.Contains(runs[0], "【Behavior Notes】\nDeploys are frozen this week.") {
+		t.Errorf("expected the script context in the run instructions, got %q", runs)
+	}
+	select {
+	case payload := <-hooks:
+		if payload["message"] != "deploy today?" || payload["sender"] != "User" {
+			t.Errorf("unexpected webhook payload: %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the webhook to be called")
+	}
+	if w.behavior != nil {
+		t.Errorf("expected the script result to end with the message")
+	}
+}
+
+func TestBehaviorWebhookClient_RefusesPrivateAddresses(t *testing.T) {
+	called := false
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	t.Cleanup(hookServer.Close)
+
+	// The address is checked when connecting, so names resolving to loopback are refused too
+	hostURL := strings.Replace(hookServer.URL, "127.0.0.1", "localhost", 1)
+	for _, target := range []string{hookServer.URL, hostURL} {
+		if resp, err := behaviorWebhookClient.Post(target, "application/json", strings.NewReader("{}")); err == nil {
+			resp.Body.Close()
+			t.Errorf("expected the webhook to %s to be refused", target)
+		}
+	}
+	if called {
+		t.Error("expected the server not to be called")
+	}
+
+	// Allowed hosts are sent with the unguarded client
+	sendBehaviorWebhook(1, "Alice", logic.BehaviorWebhook{URL: hookServer.URL}, []string{"127.0.0.1"})
+	if !called {
+		t.Error("expected the allowed host to be called")
+	}
+}
+
+func TestAvatarWatcher_BehaviorScriptErrorFallsBack(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	conv, avatars := testutil.NewTestConversationWithAvatars(t, database, "Alice")
+	avatar := avatars[0]
+	database.SetAvatarScript(avatar.ID, "x = message.nope")
+
+	w := &AvatarWatcher{conversationID: conv.ID, avatar: *avatar, db: database}
+	msg := testutil.NewTestMessageSeries(t, database, conv.ID, nil, "@Alice hi")[0]
+	if result := w.runBehaviorScript(context.Background(), msg); result != nil {
+		t.Fatalf("expected no result from a failing script, got %+v", result)
+	}
+
+	stored, _ := database.GetAvatarScript(avatar.ID)
+	if !strings.Contains(stored.LastError, `"message" struct has no .nope attribute`) || stored.LastErrorAt == nil {
+		t.Errorf("expected the error to be recorded, got %+v", stored)
+	}
+
+	// Without a decision the usual mention handling applies
+	if respond, _ := w.shouldRespond(context.Background(), msg); !respond {
+		t.Error("expected the mention to be answered")
+	}
+}
@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// deadLetterContext is the snapshot stored with a dead letter
+type deadLetterContext struct {
+	ThreadID     string `json:"thread_id"`
+	AssistantID  string `json:"assistant_id"`
+	Instructions string `json:"instructions"`
+}
+
+// respondWithRetries responds to a message unless the avatar has already responded to it
+// The message is claimed in the responded_to table first, so it is not answered twice even after a restart.
+// Returns the number of attempts made (0 if already responded) and the error of the last one
+func (w *AvatarWatcher) respondWithRetries(ctx context.Context, msg *models.Message) (int, error) {
+	claimed, err := w.db.ClaimResponse(w.conversationID, w.avatar.ID, msg.ID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to claim response, responding anyway conversation_id=%d avatar_id=%d message_id=%d err=%v",
+			w.conversationID, w.avatar.ID, msg.ID, err)
+	} else if !claimed {
+		log.Printf("[AvatarWatcher] Already responded, skipping conversation_id=%d avatar_id=%d message_id=%d",
+			w.conversationID, w.avatar.ID, msg.ID)
+		return 0, nil
+	}
+
+	attempts, err := w.attemptResponse(ctx, msg)
+	if claimed {
+		// A saved response already completed the claim; this covers responses that saved nothing.
+		// A failed response is released so a dead letter retry can claim it again
+		finish := w.db.CompleteResponse
+		if err != nil {
+			finish = w.db.ReleaseResponse
+		}
+		if finishErr := finish(w.avatar.ID, msg.ID); finishErr != nil {
+			log.Printf("[AvatarWatcher] Warning: failed to update response claim avatar_id=%d message_id=%d err=%v",
+				w.avatar.ID, msg.ID, finishErr)
+		}
+	}
+	return attempts, err
+}
+
+// attemptResponse generates a response, waiting longer before each new attempt
+// Attempts stop early when the watcher stops, the OpenAI circuit is open or OpenAI rejected the request
+// in a way retrying cannot fix, such as an invalid request or a rejected API key.
+// Returns the number of attempts made and the error of the last one
+func (w *AvatarWatcher) attemptResponse(ctx context.Context, msg *models.Message) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := w.generateResponse(ctx, msg)
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt == maxResponseAttempts || w.ctx.Err() != nil || errors.Is(err, assistant.ErrCircuitOpen) ||
+			!assistant.IsRetryable(err) {
+			return attempt, err
+		}
+
+		delay := w.retryDelay * time.Duration(attempt)
+		log.Printf("[AvatarWatcher] Response attempt failed, retrying in %v conversation_id=%d avatar_id=%d message_id=%d attempt=%d err=%v",
+			delay, w.conversationID, w.avatar.ID, msg.ID, attempt, err)
+
+		select {
+		case <-w.ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// recordDeadLetter stores a response that failed after every attempt so it can be inspected and retried
+// Responses abandoned because the watcher was stopped or interrupted are not recorded
+func (w *AvatarWatcher) recordDeadLetter(msg *models.Message, attempts int, err error) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	dl, dbErr := w.db.CreateDeadLetter(w.conversationID, w.avatar.ID, msg.ID, err.Error(), attempts, w.responseContext(msg))
+	if dbErr != nil {
+		log.Printf("[AvatarWatcher] Failed to record dead letter conversation_id=%d avatar_id=%d message_id=%d err=%v",
+			w.conversationID, w.avatar.ID, msg.ID, dbErr)
+		return
+	}
+	log.Printf("[AvatarWatcher] Response moved to dead letter queue dead_letter_id=%d conversation_id=%d avatar_id=%d message_id=%d",
+		dl.ID, w.conversationID, w.avatar.ID, msg.ID)
+}
+
+// responseContext returns the JSON snapshot of what a run for the message is started with
+func (w *AvatarWatcher) responseContext(msg *models.Message) string {
+	snapshot := deadLetterContext{
+		AssistantID:  w.avatar.OpenAIAssistantID,
+		Instructions: w.buildRunInstructions(msg),
+	}
+	if threadID, err := w.db.GetAvatarThreadID(w.conversationID, w.avatar.ID); err == nil {
+		snapshot.ThreadID = threadID
+	}
+	if current, err := w.db.GetAvatar(w.avatar.ID); err == nil {
+		snapshot.AssistantID = current.OpenAIAssistantID
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// Retry queues a dead letter of this watcher to be responded to again
+// The dead letter must already be marked as retrying
+func (w *AvatarWatcher) Retry(dl models.DeadLetter) error {
+	if w.ctx.Err() != nil {
+		return ErrNoWatcher
+	}
+	select {
+	case w.retries <- dl:
+		return nil
+	default:
+		return ErrRetryQueueFull
+	}
+}
+
+// retryDeadLetter responds to the trigger message of a dead letter without judging it again
+func (w *AvatarWatcher) retryDeadLetter(dl models.DeadLetter) {
+	log.Printf("[AvatarWatcher] Retrying dead letter dead_letter_id=%d conversation_id=%d avatar_id=%d message_id=%d",
+		dl.ID, w.conversationID, w.avatar.ID, dl.MessageID)
+
+	msg, err := w.db.GetMessage(w.conversationID, dl.MessageID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get dead letter message dead_letter_id=%d message_id=%d err=%v",
+			dl.ID, dl.MessageID, err)
+		if err := w.db.FinishDeadLetterRetry(dl.ID, "trigger message unavailable: "+err.Error(), 0, dl.Context); err != nil {
+			log.Printf("[AvatarWatcher] Failed to record dead letter retry dead_letter_id=%d err=%v", dl.ID, err)
+		}
+		return
+	}
+
+	ctx, span := tracing.Start(tracing.MessageContext(msg.ID), "watcher.retry_dead_letter",
+		attribute.Int64("conversation.id", w.conversationID),
+		attribute.Int64("avatar.id", w.avatar.ID),
+		attribute.Int64("message.id", msg.ID),
+		attribute.Int64("dead_letter.id", dl.ID),
+	)
+	defer span.End()
+
+	attempts, err := w.respondWithRetries(ctx, msg)
+	if err != nil && w.ctx.Err() != nil {
+		// Stopped while retrying; the dead letter can be retried again
+		w.db.ReopenDeadLetter(dl.ID)
+		return
+	}
+
+	var errMsg, snapshot string
+	if err != nil {
+		span.RecordError(err)
+		errMsg = err.Error()
+		snapshot = w.responseContext(msg)
+	}
+	if err := w.db.FinishDeadLetterRetry(dl.ID, errMsg, attempts, snapshot); err != nil {
+		log.Printf("[AvatarWatcher] Failed to record dead letter retry dead_letter_id=%d err=%v", dl.ID, err)
+		return
+	}
+
+	log.Printf("[AvatarWatcher] Dead letter retry completed dead_letter_id=%d resolved=%t attempts=%d",
+		dl.ID, err == nil, attempts)
+}
+
+// reopenWaitingRetries reopens dead letters still waiting for a retry when the watcher stops
+func (w *AvatarWatcher) reopenWaitingRetries() {
+	for {
+		select {
+		case dl := <-w.retries:
+			if _, err := w.db.ReopenDeadLetter(dl.ID); err != nil {
+				log.Printf("[AvatarWatcher] Failed to reopen dead letter dead_letter_id=%d err=%v", dl.ID, err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// RetryDeadLetter queues a dead letter to be responded to again by the avatar's watcher
+// The dead letter must already be marked as retrying; a hibernated conversation is woken first
+func (m *WatcherManager) RetryDeadLetter(dl models.DeadLetter) error {
+	if err := m.Wake(dl.ConversationID); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	watcher, exists := m.watchers[watcherKey{ConversationID: dl.ConversationID, AvatarID: dl.AvatarID}]
+	if !exists {
+		return ErrNoWatcher
+	}
+	return watcher.Retry(dl)
+}
@@ -0,0 +1,293 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestAvatarWatcher_HandleMessage_RecordsDeadLetter(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": "boom"}})
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithCircuitBreaker(100, time.Minute),
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	conv, _ := database.CreateConversation("Failures", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice are you there?")
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	w.retryDelay = time.Millisecond
+	w.handleMessage(msg)
+
+	deadLetters, err := database.GetDeadLetters(models.DeadLetterStatusOpen, conv.ID)
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("expected 1 dead letter, got %+v err=%v", deadLetters, err)
+	}
+	dl := deadLetters[0]
+	if dl.MessageID != msg.ID || dl.AvatarID != avatar.ID || dl.Attempts != maxResponseAttempts || dl.Error == "" {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+	if calls.Load() != maxResponseAttempts {
+		t.Errorf("expected %d calls to the API, got %d", maxResponseAttempts, calls.Load())
+	}
+
+	var snapshot deadLetterContext
+	if err := json.Unmarshal([]byte(dl.Context), &snapshot); err != nil {
+		t.Fatalf("invalid context snapshot %q: %v", dl.Context, err)
+	}
+	if snapshot.ThreadID != "thread_1" || snapshot.AssistantID != "asst_1" || !contains(snapshot.Instructions, "are you there?") {
+		t.Errorf("unexpected context snapshot: %+v", snapshot)
+	}
+}
+
+func TestAvatarWatcher_HandleMessage_DoesNotRetryInvalidRequests(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "maximum context length exceeded", "type": "invalid_request_error", "code": "context_length_exceeded"}}`))
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	conv, _ := database.CreateConversation("Failures", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice are you there?")
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	w.retryDelay = time.Millisecond
+	w.handleMessage(msg)
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single call for a request retrying cannot fix, got %d", calls.Load())
+	}
+	deadLetters, _ := database.GetDeadLetters(models.DeadLetterStatusOpen, conv.ID)
+	if len(deadLetters) != 1 || deadLetters[0].Attempts != 1 {
+		t.Errorf("expected a dead letter after one attempt, got %+v", deadLetters)
+	}
+}
+
+func TestAvatarWatcher_HandleMessage_MarksDeletedAssistant(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	var runs atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		runs.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"message": "No assistant found with id 'asst_1'.", "type": "invalid_request_error"}}`))
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	conv, _ := database.CreateConversation("Deleted", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	first, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice are you there?")
+	second, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello?")
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	w.retryDelay = time.Millisecond
+	w.handleMessage(first)
+
+	if runs.Load() != 1 {
+		t.Errorf("expected a single run attempt for a deleted assistant, got %d", runs.Load())
+	}
+	if stored, _ := database.GetAvatar(avatar.ID); !stored.NeedsRelink {
+		t.Fatalf("expected the avatar to need a relink")
+	}
+
+	// Later messages are skipped without calling OpenAI or adding dead letters
+	w.handleMessage(second)
+	if runs.Load() != 1 {
+		t.Errorf("expected no run for an avatar that needs a relink, got %d", runs.Load())
+	}
+	if deadLetters, _ := database.GetDeadLetters(models.DeadLetterStatusOpen, conv.ID); len(deadLetters) != 1 {
+		t.Errorf("expected only the first message to be dead-lettered, got %+v", deadLetters)
+	}
+}
+
+func TestAvatarWatcher_HandleMessage_NoDeadLetterWhenStopped(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	conv, _ := database.CreateConversation("Stopped", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	w.cancel()
+	w.handleMessage(msg)
+
+	if deadLetters, _ := database.GetDeadLetters("", 0); len(deadLetters) != 0 {
+		t.Errorf("expected no dead letters for a stopped watcher, got %+v", deadLetters)
+	}
+}
+
+func TestWatcherManager_RetryDeadLetter(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+
+	database := testutil.NewTestDB(t)
+
+	client := mockServer.Client()
+	conv, _ := database.CreateConversation("Retry", "")
+	avatar, _ := database.CreateAvatar("RetryBot", "prompt", "asst_retry")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Please answer")
+
+	dl, _ := database.CreateDeadLetter(conv.ID, avatar.ID, msg.ID, "run failed", 3, "{}")
+
+	manager := NewManager(database, client, 100*time.Millisecond)
+	defer manager.Shutdown()
+
+	if err := manager.RetryDeadLetter(*dl); err != ErrNoWatcher {
+		t.Fatalf("expected ErrNoWatcher without a watcher, got %v", err)
+	}
+
+	if err := manager.StartWatcher(conv.ID, avatar.ID); err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	database.StartDeadLetterRetry(dl.ID)
+	if err := manager.RetryDeadLetter(*dl); err != nil {
+		t.Fatalf("failed to retry dead letter: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		got, _ := database.GetDeadLetter(dl.ID)
+		if got.Status == models.DeadLetterStatusResolved {
+			if got.Attempts != 4 {
+				t.Errorf("expected the retry attempt to be counted, got %d attempts", got.Attempts)
+			}
+			messages, _ := database.GetMessages(conv.ID)
+			if len(messages) != 2 || messages[1].SenderType != models.SenderTypeAvatar {
+				t.Errorf("expected the avatar to respond, got %+v", messages)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("dead letter was not resolved within timeout")
+}
+
+func TestAvatarWatcher_RespondWithRetries_SkipsAnsweredMessage(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithCircuitBreaker(100, time.Minute),
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	conv, _ := database.CreateConversation("Answered", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+
+	// A previous process already responded to the message
+	database.ClaimResponse(conv.ID, avatar.ID, msg.ID)
+	database.CompleteResponse(avatar.ID, msg.ID)
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	w.retryDelay = time.Millisecond
+	if attempts, err := w.respondWithRetries(context.Background(), msg); attempts != 0 || err != nil {
+		t.Errorf("expected the message to be skipped, got attempts=%d err=%v", attempts, err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no calls to the API, got %d", calls.Load())
+	}
+
+	// A failed response is released so it can be retried
+	other, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice again")
+	if _, err := w.respondWithRetries(context.Background(), other); err == nil {
+		t.Fatal("expected the response to fail")
+	}
+	if claimed, _ := database.ClaimResponse(conv.ID, avatar.ID, other.ID); !claimed {
+		t.Error("expected the failed response to be released")
+	}
+}
+
+func TestAvatarWatcher_GenerateResponse_RecordsRunFailure(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/threads/thread_1/runs":
+			w.Write([]byte(`{"id": "run_1", "status": "queued"}`))
+		case r.URL.Path == "/v1/threads/thread_1/runs":
+			w.Write([]byte(`{"data": []}`))
+		case r.URL.Path == "/v1/threads/thread_1/runs/run_1":
+			w.Write([]byte(`{"id": "run_1", "status": "failed",
+				"last_error": {"code": "server_error", "message": "Something went wrong"}}`))
+		case r.URL.Path == "/v1/threads/thread_1/runs/run_1/steps":
+			w.Write([]byte(`{"data": [{"id": "step_1", "status": "failed",
+				"last_error": {"code": "rate_limit_exceeded", "message": "Rate limit reached"}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	conv, _ := database.CreateConversation("Failures", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	msg, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+	err := w.generateResponse(context.Background(), msg)
+
+	var ended *assistant.RunEndedError
+	if !errors.As(err, &ended) {
+		t.Fatalf("expected RunEndedError, got %v", err)
+	}
+	run, err := database.GetRun("run_1")
+	if err != nil {
+		t.Fatalf("failed to get run: %v", err)
+	}
+	if run.Status != models.RunStatusFailed || run.ErrorCode != assistant.RunErrorRateLimitExceeded ||
+		run.Error != "run ended with status: failed (rate_limit_exceeded: Rate limit reached)" {
+		t.Errorf("expected the run to be classified by its failed step, got %+v", run)
+	}
+}
@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// startComparison generates the comparison model's response to a message in the background
+// The model gets the avatar's assistant instructions and the run instructions as the system message,
+// and the message formatted as it appears in the avatar's thread. The channel receives one candidate
+func (w *AvatarWatcher) startComparison(client *assistant.Client, experiment *models.ResponseExperiment,
+	prompt, runInstructions string, message *models.Message) <-chan models.ResponseCandidate {
+	system := w.instructions.AssistantInstructions(prompt)
+	if runInstructions != "" {
+		system += "\n\n" + runInstructions
+	}
+	userPrompt := w.threadMessage(message)
+
+	result := make(chan models.ResponseCandidate, 1)
+	go func() {
+		started := time.Now()
+		content, err := client.ChatCompletion(experiment.Model, system, userPrompt)
+		candidate := models.ResponseCandidate{
+			Model:     experiment.Model,
+			Content:   content,
+			LatencyMs: time.Since(started).Milliseconds(),
+		}
+		if err != nil {
+			candidate.Error = err.Error()
+		}
+		result <- candidate
+	}()
+	return result
+}
+
+// finishComparison waits for the comparison response and pairs it with the primary response
+// The experiment's broadcast variant is chosen unless the comparison failed, in which case the primary is broadcast
+func (w *AvatarWatcher) finishComparison(ctx context.Context, experiment *models.ResponseExperiment, message *models.Message,
+	primary models.ResponseCandidate, pending <-chan models.ResponseCandidate) *models.ResponseComparison {
+	var candidate models.ResponseCandidate
+	select {
+	case candidate = <-pending:
+	case <-ctx.Done():
+		candidate = models.ResponseCandidate{Model: experiment.Model, Error: ctx.Err().Error()}
+	}
+
+	broadcast := experiment.Broadcast
+	if broadcast == models.ExperimentVariantComparison && candidate.Error != "" {
+		log.Printf("[AvatarWatcher] Comparison response failed, broadcasting the primary avatar_id=%d model=%s err=%s",
+			w.avatar.ID, candidate.Model, candidate.Error)
+		broadcast = models.ExperimentVariantPrimary
+	}
+
+	log.Printf("[AvatarWatcher] Response comparison avatar_id=%d message_id=%d primary_model=%s primary_latency_ms=%d comparison_model=%s comparison_latency_ms=%d broadcast=%s",
+		w.avatar.ID, message.ID, primary.Model, primary.LatencyMs, candidate.Model, candidate.LatencyMs, broadcast)
+
+	return &models.ResponseComparison{
+		ConversationID:   w.conversationID,
+		AvatarID:         w.avatar.ID,
+		TriggerMessageID: message.ID,
+		Broadcast:        broadcast,
+		Primary:          primary,
+		Comparison:       candidate,
+	}
+}
+
+// replaceThreadResponse swaps the run's response in the avatar's thread for the broadcast comparison response
+// so the avatar's own context matches what the conversation shows. Failures are logged and leave the thread as is
+func (w *AvatarWatcher) replaceThreadResponse(client *assistant.Client, threadID, responseID, content string) {
+	if err := client.DeleteMessage(threadID, responseID); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to delete the primary response from the thread thread_id=%s message_id=%s err=%v",
+			threadID, responseID, err)
+		return
+	}
+	if _, err := client.CreateAssistantMessage(threadID, content); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to add the comparison response to the thread thread_id=%s err=%v",
+			threadID, err)
+	}
+}
+
+// threadMessage formats a message as it was added to the avatar's thread
+func (w *AvatarWatcher) threadMessage(message *models.Message) string {
+	if message.SenderType == models.SenderTypeAvatar && message.SenderID != nil {
+		if sender, err := w.db.GetAvatar(*message.SenderID); err == nil {
+			return logic.FormatAvatarMessage(sender.Name, message.Content)
+		}
+	}
+	return logic.FormatUserMessage(message.Content)
+}
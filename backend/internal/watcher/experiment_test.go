@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestAvatarWatcher_Experiment(t *testing.T) {
+	tests := []struct {
+		name      string
+		broadcast string
+		want      string
+	}{
+		// The mock answers chat completions with "yes"
+		{name: "primary broadcast", broadcast: models.ExperimentVariantPrimary, want: testutil.DefaultMockResponse},
+		{name: "comparison broadcast", broadcast: models.ExperimentVariantComparison, want: "yes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := testutil.NewMockAssistant(t)
+			database := testutil.NewTestDB(t)
+			client := mockServer.Client()
+
+			conv, avatars := testutil.NewTestConversationWithAvatars(t, database, "Alice")
+			avatar := avatars[0]
+			database.SetResponseExperiment(avatar.ID, "gpt-test", tt.broadcast)
+			trigger, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+
+			w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second, nil)
+			if err := w.handleMessage(trigger); err != nil {
+				t.Fatalf("handleMessage failed: %v", err)
+			}
+
+			messages, _ := database.GetMessages(conv.ID)
+			if len(messages) != 2 || messages[1].Content != tt.want {
+				t.Fatalf("expected the %s response to be broadcast, got %+v", tt.broadcast, messages)
+			}
+
+			comparisons, err := database.GetResponseComparisons(conv.ID, avatar.ID, 10)
+			if err != nil || len(comparisons) != 1 {
+				t.Fatalf("expected one comparison, got %+v err=%v", comparisons, err)
+			}
+			c := comparisons[0]
+			if c.Broadcast != tt.broadcast || c.TriggerMessageID != trigger.ID || c.MessageID == nil || *c.MessageID != messages[1].ID {
+				t.Errorf("unexpected comparison: %+v", c)
+			}
+			if c.Primary.Content != "This is a mock response from the avatar." || c.Comparison.Content != "yes" ||
+				c.Comparison.Model != "gpt-test" || c.Comparison.Error != "" {
+				t.Errorf("expected both responses to be stored, got %+v", c)
+			}
+
+			// The avatar's thread holds the broadcast response, so its context matches the conversation
+			threadID, _ := database.GetAvatarThreadID(conv.ID, avatar.ID)
+			thread := mockServer.ThreadMessages(threadID)
+			if !slices.Contains(thread, "assistant: "+tt.want) || len(thread) != 1 {
+				t.Errorf("expected the thread to hold only the %s response, got %q", tt.broadcast, thread)
+			}
+		})
+	}
+}
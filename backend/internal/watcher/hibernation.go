@@ -0,0 +1,198 @@
+package watcher
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// DefaultHibernationInterval is how often conversations are checked for inactivity
+const DefaultHibernationInterval = 5 * time.Minute
+
+// SetIdleHibernation stops the watchers of conversations without messages for idleAfter
+// and restarts them when the conversation is woken; 0 disables hibernation.
+// Must be called before InitializeAll so idle conversations are not started
+func (m *WatcherManager) SetIdleHibernation(idleAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idleAfter = idleAfter
+}
+
+// SetLazyStart makes InitializeAll skip starting watchers
+// The watchers of a conversation then start when it is first woken by a message or SSE subscription.
+// Must be called before InitializeAll
+func (m *WatcherManager) SetLazyStart(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lazyStart = enabled
+}
+
+// StartHibernation hibernates idle conversations every interval until Shutdown
+func (m *WatcherManager) StartHibernation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+				m.HibernateIdle()
+			}
+		}
+	}()
+}
+
+// HibernateIdle stops the watchers of every conversation idle for longer than the idle timeout
+// Conversations with a run in progress or woken during the check are left running.
+// Returns the number of conversations hibernated
+func (m *WatcherManager) HibernateIdle() int {
+	m.mu.RLock()
+	idleAfter := m.idleAfter
+	conversations := make(map[int64]bool)
+	for key := range m.watchers {
+		conversations[key.ConversationID] = true
+	}
+	m.mu.RUnlock()
+
+	if idleAfter <= 0 {
+		return 0
+	}
+
+	cutoff := time.Now().Add(-idleAfter)
+	hibernated := 0
+	for conversationID := range conversations {
+		idle, err := m.idleSince(conversationID, cutoff)
+		if err != nil {
+			log.Printf("[WatcherManager] Failed to check conversation activity conversation_id=%d err=%v", conversationID, err)
+			continue
+		}
+		if !idle {
+			continue
+		}
+
+		m.mu.Lock()
+		if m.lastWake[conversationID].After(cutoff) || m.hasActiveRunLocked(conversationID) {
+			m.mu.Unlock()
+			continue
+		}
+		stopped := m.stopRoomLocked(conversationID)
+		m.hibernated[conversationID] = true
+		m.mu.Unlock()
+
+		log.Printf("[WatcherManager] Conversation hibernated conversation_id=%d stopped_count=%d idle_after=%v",
+			conversationID, stopped, idleAfter)
+		hibernated++
+	}
+	return hibernated
+}
+
+// Wake restarts the watchers of a hibernated conversation and marks it as active
+// In lazy start mode it also starts the watchers of a conversation woken for the first time.
+// Call it before saving a new message: the restarted watchers treat messages saved afterwards as new
+func (m *WatcherManager) Wake(conversationID int64) error {
+	m.mu.Lock()
+	m.lastWake[conversationID] = time.Now()
+	if !m.hibernated[conversationID] && (!m.lazyStart || m.awake[conversationID]) {
+		m.mu.Unlock()
+		return nil
+	}
+
+	// Decided under the lock so a message saved by a concurrent caller is always after it
+	afterSequence, err := m.wakeStartAfter(conversationID)
+	if err != nil {
+		m.mu.Unlock()
+		log.Printf("[WatcherManager] Failed to wake conversation conversation_id=%d err=%v", conversationID, err)
+		return err
+	}
+	delete(m.hibernated, conversationID)
+	if m.lazyStart {
+		m.awake[conversationID] = true
+	}
+	m.mu.Unlock()
+
+	avatars, err := m.db.GetConversationAvatars(conversationID)
+	if err != nil {
+		log.Printf("[WatcherManager] Failed to get conversation avatars conversation_id=%d err=%v", conversationID, err)
+		return err
+	}
+
+	for _, avatar := range avatars {
+		if err := m.StartWatcherAfter(conversationID, avatar.ID, afterSequence); err != nil {
+			log.Printf("[WatcherManager] Failed to start watcher conversation_id=%d avatar_id=%d err=%v",
+				conversationID, avatar.ID, err)
+		}
+	}
+
+	log.Printf("[WatcherManager] Conversation woken conversation_id=%d avatars=%d after_sequence=%d",
+		conversationID, len(avatars), afterSequence)
+	return nil
+}
+
+// IsHibernated reports whether the watchers of a conversation are stopped for inactivity
+func (m *WatcherManager) IsHibernated(conversationID int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hibernated[conversationID]
+}
+
+// HibernatedCount returns the number of hibernated conversations
+func (m *WatcherManager) HibernatedCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.hibernated)
+}
+
+// idleSince reports whether a conversation has had no messages since cutoff
+// A conversation without messages is idle if it was created before cutoff
+func (m *WatcherManager) idleSince(conversationID int64, cutoff time.Time) (bool, error) {
+	msg, err := m.db.GetLastMessage(conversationID)
+	if err == nil {
+		return msg.CreatedAt.Before(cutoff), nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	conv, err := m.db.GetConversation(conversationID)
+	if err != nil {
+		return false, err
+	}
+	return conv.CreatedAt.Before(cutoff), nil
+}
+
+// wakeStartAfter returns the sequence number after which restarted watchers look for new messages
+// Messages queued while the OpenAI API was unavailable are evaluated after they are replayed
+func (m *WatcherManager) wakeStartAfter(conversationID int64) (int64, error) {
+	var afterSequence int64
+	msg, err := m.db.GetLastMessage(conversationID)
+	if err == nil {
+		afterSequence = msg.Sequence
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	firstQueued, err := m.db.GetFirstOfflineForwardSequence(conversationID)
+	if err != nil {
+		return 0, err
+	}
+	if firstQueued > 0 && firstQueued <= afterSequence {
+		afterSequence = firstQueued - 1
+	}
+	return afterSequence, nil
+}
+
+// hasActiveRunLocked reports whether a watcher of the conversation is waiting for a run
+// The caller must hold m.mu
+func (m *WatcherManager) hasActiveRunLocked(conversationID int64) bool {
+	for key, watcher := range m.watchers {
+		if key.ConversationID != conversationID {
+			continue
+		}
+		if runID, _, _ := watcher.activeRun(); runID != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// ageConversation moves the creation time of a conversation and its messages into the past
+func ageConversation(t *testing.T, database *db.DB, conversationID int64, age time.Duration) {
+	t.Helper()
+
+	modifier := fmt.Sprintf("-%d seconds", int(age.Seconds()))
+	if _, err := database.Exec(`UPDATE conversations SET created_at = datetime('now', ?) WHERE id = ?`, modifier, conversationID); err != nil {
+		t.Fatalf("failed to age conversation: %v", err)
+	}
+	if _, err := database.Exec(`UPDATE messages SET created_at = datetime('now', ?) WHERE conversation_id = ?`, modifier, conversationID); err != nil {
+		t.Fatalf("failed to age messages: %v", err)
+	}
+}
+
+func TestManager_InitializeAll_HibernatesIdleConversations(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	idleConv, _ := database.CreateConversation("Old", "")
+	activeConv, _ := database.CreateConversation("New", "")
+	database.AddAvatarToConversation(idleConv.ID, avatar.ID)
+	database.AddAvatarToConversation(activeConv.ID, avatar.ID)
+	database.CreateMessage(idleConv.ID, models.SenderTypeUser, nil, "hello")
+	ageConversation(t, database, idleConv.ID, 2*time.Hour)
+
+	manager := NewManager(database, nil, time.Second)
+	defer manager.Shutdown()
+	manager.SetIdleHibernation(time.Hour)
+
+	if err := manager.InitializeAll(context.Background()); err != nil {
+		t.Fatalf("InitializeAll failed: %v", err)
+	}
+
+	if manager.HasWatcher(idleConv.ID, avatar.ID) || !manager.IsHibernated(idleConv.ID) {
+		t.Error("expected the idle conversation to be hibernated")
+	}
+	if !manager.HasWatcher(activeConv.ID, avatar.ID) || manager.IsHibernated(activeConv.ID) {
+		t.Error("expected the active conversation to be watched")
+	}
+}
+
+func TestManager_HibernateIdleAndWake(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "prompt", "asst_2")
+	conv, _ := database.CreateConversation("Room", "")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+	database.AddAvatarToConversation(conv.ID, bob.ID)
+	last, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	manager := NewManager(database, nil, time.Second)
+	defer manager.Shutdown()
+	manager.StartWatcher(conv.ID, alice.ID)
+	manager.StartWatcher(conv.ID, bob.ID)
+
+	// Hibernation is disabled by default
+	ageConversation(t, database, conv.ID, 2*time.Hour)
+	if n := manager.HibernateIdle(); n != 0 {
+		t.Fatalf("expected no hibernation when disabled, got %d", n)
+	}
+
+	manager.SetIdleHibernation(time.Hour)
+	if n := manager.HibernateIdle(); n != 1 {
+		t.Fatalf("expected 1 hibernated conversation, got %d", n)
+	}
+	if manager.WatcherCount() != 0 || !manager.IsHibernated(conv.ID) {
+		t.Fatalf("expected the watchers to be stopped, got %d watchers", manager.WatcherCount())
+	}
+
+	if err := manager.Wake(conv.ID); err != nil {
+		t.Fatalf("Wake failed: %v", err)
+	}
+	if manager.WatcherCount() != 2 || manager.IsHibernated(conv.ID) {
+		t.Fatalf("expected both watchers to restart, got %d watchers", manager.WatcherCount())
+	}
+
+	// Restarted watchers treat messages saved after the wake as new
+	manager.mu.RLock()
+	watcher := manager.watchers[watcherKey{ConversationID: conv.ID, AvatarID: alice.ID}]
+	manager.mu.RUnlock()
+	if got := watcher.GetLastSequence(); got != last.Sequence {
+		t.Errorf("expected the watcher to start after sequence %d, got %d", last.Sequence, got)
+	}
+
+	// A recently woken conversation is not hibernated again even without new messages
+	if n := manager.HibernateIdle(); n != 0 {
+		t.Errorf("expected a woken conversation to stay active, got %d hibernated", n)
+	}
+}
+
+func TestManager_StopRoomWatchers_ClearsHibernation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	conv, _ := database.CreateConversation("Room", "")
+	database.AddAvatarToConversation(conv.ID, avatar.ID)
+	ageConversation(t, database, conv.ID, 2*time.Hour)
+
+	manager := NewManager(database, nil, time.Second)
+	defer manager.Shutdown()
+	manager.SetIdleHibernation(time.Hour)
+	manager.StartWatcher(conv.ID, avatar.ID)
+
+	if n := manager.HibernateIdle(); n != 1 {
+		t.Fatalf("expected 1 hibernated conversation, got %d", n)
+	}
+
+	manager.StopRoomWatchers(conv.ID)
+	if manager.IsHibernated(conv.ID) {
+		t.Error("expected StopRoomWatchers to clear the hibernation")
+	}
+	manager.Wake(conv.ID)
+	if manager.WatcherCount() != 0 {
+		t.Errorf("expected a stopped conversation not to be woken, got %d watchers", manager.WatcherCount())
+	}
+}
+
+func TestManager_LazyStart(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "prompt", "asst_2")
+	conv, _ := database.CreateConversation("Room", "")
+	other, _ := database.CreateConversation("Other", "")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+	database.AddAvatarToConversation(conv.ID, bob.ID)
+	database.AddAvatarToConversation(other.ID, alice.ID)
+	last, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	manager := NewManager(database, nil, time.Second)
+	defer manager.Shutdown()
+	manager.SetLazyStart(true)
+
+	if err := manager.InitializeAll(context.Background()); err != nil {
+		t.Fatalf("InitializeAll failed: %v", err)
+	}
+	if manager.WatcherCount() != 0 {
+		t.Fatalf("expected no watchers after a lazy start, got %d", manager.WatcherCount())
+	}
+
+	if err := manager.Wake(conv.ID); err != nil {
+		t.Fatalf("Wake failed: %v", err)
+	}
+	if !manager.HasWatcher(conv.ID, alice.ID) || !manager.HasWatcher(conv.ID, bob.ID) {
+		t.Fatal("expected the woken conversation's watchers to start")
+	}
+	if manager.HasWatcher(other.ID, alice.ID) {
+		t.Error("expected other conversations to stay stopped")
+	}
+
+	manager.mu.RLock()
+	watcher := manager.watchers[watcherKey{ConversationID: conv.ID, AvatarID: bob.ID}]
+	manager.mu.RUnlock()
+	if got := watcher.GetLastSequence(); got != last.Sequence {
+		t.Errorf("expected the watcher to start after sequence %d, got %d", last.Sequence, got)
+	}
+
+	// Watchers are started only on the first wake
+	manager.StopWatcher(conv.ID, bob.ID)
+	manager.Wake(conv.ID)
+	if manager.HasWatcher(conv.ID, bob.ID) {
+		t.Error("expected an awake conversation not to be started again")
+	}
+
+	manager.StopRoomWatchers(other.ID)
+	manager.Wake(other.ID)
+	if manager.HasWatcher(other.ID, alice.ID) {
+		t.Error("expected a stopped conversation not to be started")
+	}
+}
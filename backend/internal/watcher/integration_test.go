@@ -2,349 +2,24 @@ package watcher
 
 import (
 	"context"
-	"encoding/json"
-	"net/http"
-	"net/http/httptest"
-	"os"
-	"sync"
 	"testing"
 	"time"
 
-	"multi-avatar-chat/internal/assistant"
-	"multi-avatar-chat/internal/db"
 	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
 )
 
-// MockOpenAIServer simulates OpenAI API for integration testing
-type MockOpenAIServer struct {
-	server       *httptest.Server
-	mutex        sync.Mutex
-	activeRuns   map[string]string // threadID -> runID
-	runStatuses  map[string]string // runID -> status
-	messages     map[string][]mockMessage
-	runCounter   int
-	msgCounter   int
-	responseText string
-}
-
-type mockMessage struct {
-	ID      string
-	Role    string
-	Content string
-}
-
-func newMockOpenAIServer() *MockOpenAIServer {
-	m := &MockOpenAIServer{
-		activeRuns:   make(map[string]string),
-		runStatuses:  make(map[string]string),
-		messages:     make(map[string][]mockMessage),
-		responseText: "This is a mock response from the avatar.",
-	}
-
-	mux := http.NewServeMux()
-
-	// Create thread endpoint
-	mux.HandleFunc("POST /v1/threads", func(w http.ResponseWriter, r *http.Request) {
-		m.handleCreateThread(w, r)
-	})
-
-	// List runs endpoint
-	mux.HandleFunc("/v1/threads/", func(w http.ResponseWriter, r *http.Request) {
-		m.handleThreadsRequest(w, r)
-	})
-
-	// Chat completions endpoint (for SimpleCompletion)
-	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
-		m.handleChatCompletion(w, r)
-	})
-
-	m.server = httptest.NewServer(mux)
-	return m
-}
-
-func (m *MockOpenAIServer) handleCreateThread(w http.ResponseWriter, r *http.Request) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	m.msgCounter++
-	threadID := "thread_mock_" + string(rune('0'+m.msgCounter))
-	m.messages[threadID] = []mockMessage{}
-	json.NewEncoder(w).Encode(map[string]any{
-		"id":         threadID,
-		"created_at": int64(1234567890),
-	})
-}
-
-func (m *MockOpenAIServer) handleThreadsRequest(w http.ResponseWriter, r *http.Request) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	path := r.URL.Path
-
-	// Extract thread ID from path: /v1/threads/{thread_id}/...
-	// Pattern: /v1/threads/thread_123/runs or /v1/threads/thread_123/messages
-	var threadID string
-	if len(path) > 12 { // "/v1/threads/"
-		rest := path[12:]
-		for i, c := range rest {
-			if c == '/' {
-				threadID = rest[:i]
-				break
-			}
-		}
-		if threadID == "" {
-			threadID = rest
-		}
-	}
-
-	// Handle /v1/threads/{thread_id}/runs
-	if r.Method == "GET" && containsStr(path, "/runs") && !containsStr(path, "/runs/") {
-		// List runs
-		runs := []map[string]string{}
-		if runID, ok := m.activeRuns[threadID]; ok {
-			status := m.runStatuses[runID]
-			if status == "" {
-				status = "completed"
-			}
-			runs = append(runs, map[string]string{
-				"id":           runID,
-				"status":       status,
-				"thread_id":    threadID,
-				"assistant_id": "asst_mock",
-			})
-		}
-		json.NewEncoder(w).Encode(map[string]any{"data": runs})
-		return
-	}
-
-	// Handle POST /v1/threads/{thread_id}/runs - Create run
-	if r.Method == "POST" && containsStr(path, "/runs") && !containsStr(path, "/runs/") {
-		// Check if there's already an active run
-		if existingRunID, ok := m.activeRuns[threadID]; ok {
-			status := m.runStatuses[existingRunID]
-			if status == "in_progress" || status == "queued" {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]any{
-					"error": map[string]string{
-						"message": "Thread " + threadID + " already has an active run " + existingRunID + ".",
-						"type":    "invalid_request_error",
-					},
-				})
-				return
-			}
-		}
-
-		m.runCounter++
-		runID := "run_mock_" + string(rune('0'+m.runCounter))
-		m.activeRuns[threadID] = runID
-		m.runStatuses[runID] = "queued"
-
-		// Simulate run completion after a short delay
-		go func(rid string) {
-			time.Sleep(100 * time.Millisecond)
-			m.mutex.Lock()
-			m.runStatuses[rid] = "in_progress"
-			m.mutex.Unlock()
-
-			time.Sleep(100 * time.Millisecond)
-			m.mutex.Lock()
-			m.runStatuses[rid] = "completed"
-			// Add assistant message
-			m.msgCounter++
-			msgID := "msg_mock_" + string(rune('0'+m.msgCounter))
-			m.messages[threadID] = append([]mockMessage{{
-				ID:      msgID,
-				Role:    "assistant",
-				Content: m.responseText,
-			}}, m.messages[threadID]...)
-			m.mutex.Unlock()
-		}(runID)
-
-		json.NewEncoder(w).Encode(map[string]string{
-			"id":           runID,
-			"status":       "queued",
-			"thread_id":    threadID,
-			"assistant_id": "asst_mock",
-		})
-		return
-	}
-
-	// Handle GET /v1/threads/{thread_id}/runs/{run_id} - Get run status
-	if r.Method == "GET" && containsStr(path, "/runs/") {
-		// Extract run ID
-		parts := splitPath(path)
-		var runID string
-		for i, p := range parts {
-			if p == "runs" && i+1 < len(parts) {
-				runID = parts[i+1]
-				break
-			}
-		}
-
-		status := m.runStatuses[runID]
-		if status == "" {
-			status = "completed"
-		}
-
-		json.NewEncoder(w).Encode(map[string]string{
-			"id":           runID,
-			"status":       status,
-			"thread_id":    threadID,
-			"assistant_id": "asst_mock",
-		})
-		return
-	}
-
-	// Handle GET /v1/threads/{thread_id}/messages - List messages
-	if r.Method == "GET" && containsStr(path, "/messages") {
-		msgs := m.messages[threadID]
-		data := make([]map[string]any, 0, len(msgs))
-		for _, msg := range msgs {
-			data = append(data, map[string]any{
-				"id":   msg.ID,
-				"role": msg.Role,
-				"content": []map[string]any{
-					{
-						"type": "text",
-						"text": map[string]string{
-							"value": msg.Content,
-						},
-					},
-				},
-			})
-		}
-		json.NewEncoder(w).Encode(map[string]any{"data": data})
-		return
-	}
-
-	// Handle POST /v1/threads/{thread_id}/messages - Create message
-	if r.Method == "POST" && containsStr(path, "/messages") {
-		// Check if there's an active run
-		if runID, ok := m.activeRuns[threadID]; ok {
-			status := m.runStatuses[runID]
-			if status == "in_progress" || status == "queued" {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]any{
-					"error": map[string]string{
-						"message": "Can't add messages to " + threadID + " while a run " + runID + " is active.",
-						"type":    "invalid_request_error",
-					},
-				})
-				return
-			}
-		}
-
-		var req struct {
-			Content string `json:"content"`
-			Role    string `json:"role"`
-		}
-		json.NewDecoder(r.Body).Decode(&req)
-
-		m.msgCounter++
-		msgID := "msg_mock_" + string(rune('0'+m.msgCounter))
-		m.messages[threadID] = append(m.messages[threadID], mockMessage{
-			ID:      msgID,
-			Role:    req.Role,
-			Content: req.Content,
-		})
-
-		json.NewEncoder(w).Encode(map[string]string{
-			"id":   msgID,
-			"role": req.Role,
-		})
-		return
-	}
-
-	w.WriteHeader(http.StatusNotFound)
-}
-
-func (m *MockOpenAIServer) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
-	// Always respond "yes" to simulate avatar wanting to respond
-	json.NewEncoder(w).Encode(map[string]any{
-		"choices": []map[string]any{
-			{
-				"message": map[string]string{
-					"content": "yes",
-				},
-			},
-		},
-	})
-}
-
-func (m *MockOpenAIServer) Close() {
-	m.server.Close()
-}
-
-func (m *MockOpenAIServer) URL() string {
-	return m.server.URL
-}
-
-func containsStr(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
-func splitPath(path string) []string {
-	var parts []string
-	current := ""
-	for _, c := range path {
-		if c == '/' {
-			if current != "" {
-				parts = append(parts, current)
-				current = ""
-			}
-		} else {
-			current += string(c)
-		}
-	}
-	if current != "" {
-		parts = append(parts, current)
-	}
-	return parts
-}
-
-// Helper to create a test assistant client with mock server
-func createMockAssistantClient(serverURL string) *assistant.Client {
-	// Create client with custom HTTP client pointing to mock server
-	httpClient := &http.Client{
-		Transport: &mockTransport{baseURL: serverURL},
-	}
-	return assistant.NewClient("mock-api-key", assistant.WithHTTPClient(httpClient))
-}
-
-type mockTransport struct {
-	baseURL string
-}
-
-func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Redirect OpenAI API calls to mock server
-	req.URL.Scheme = "http"
-	req.URL.Host = t.baseURL[7:] // Remove "http://"
-	return http.DefaultTransport.RoundTrip(req)
-}
-
 // Integration Tests
 
 func TestIntegration_WatcherRespondsToNewMessage(t *testing.T) {
 	// Setup mock OpenAI server
-	mockServer := newMockOpenAIServer()
-	defer mockServer.Close()
+	mockServer := testutil.NewMockAssistant(t)
 
 	// Setup database
-	tmpFile, _ := os.CreateTemp("", "integration_test_*.db")
-	tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
-
-	database, _ := db.NewDB(tmpFile.Name())
-	defer database.Close()
-	database.Migrate()
+	database := testutil.NewTestDB(t)
 
 	// Create assistant client with mock
-	assistantClient := createMockAssistantClient(mockServer.URL())
+	assistantClient := mockServer.Client()
 
 	// Create conversation with thread
 	conv, _ := database.CreateConversation("Integration Test Chat", "thread_integration_1")
@@ -393,20 +68,13 @@ func TestIntegration_WatcherRespondsToNewMessage(t *testing.T) {
 
 func TestIntegration_MultipleWatchersNoConflict(t *testing.T) {
 	// Setup mock OpenAI server
-	mockServer := newMockOpenAIServer()
-	defer mockServer.Close()
+	mockServer := testutil.NewMockAssistant(t)
 
 	// Setup database
-	tmpFile, _ := os.CreateTemp("", "integration_multi_*.db")
-	tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
-
-	database, _ := db.NewDB(tmpFile.Name())
-	defer database.Close()
-	database.Migrate()
+	database := testutil.NewTestDB(t)
 
 	// Create assistant client with mock
-	assistantClient := createMockAssistantClient(mockServer.URL())
+	assistantClient := mockServer.Client()
 
 	// Create conversation
 	conv, _ := database.CreateConversation("Multi Watcher Test", "thread_multi_1")
@@ -461,20 +129,13 @@ func TestIntegration_MultipleWatchersNoConflict(t *testing.T) {
 
 func TestIntegration_DynamicAvatarJoinLeave(t *testing.T) {
 	// Setup mock OpenAI server
-	mockServer := newMockOpenAIServer()
-	defer mockServer.Close()
+	mockServer := testutil.NewMockAssistant(t)
 
 	// Setup database
-	tmpFile, _ := os.CreateTemp("", "integration_dynamic_*.db")
-	tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
-
-	database, _ := db.NewDB(tmpFile.Name())
-	defer database.Close()
-	database.Migrate()
+	database := testutil.NewTestDB(t)
 
 	// Create assistant client with mock
-	assistantClient := createMockAssistantClient(mockServer.URL())
+	assistantClient := mockServer.Client()
 
 	// Create conversation
 	conv, _ := database.CreateConversation("Dynamic Join Test", "thread_dynamic_1")
@@ -515,20 +176,13 @@ func TestIntegration_DynamicAvatarJoinLeave(t *testing.T) {
 
 func TestIntegration_GracefulShutdown(t *testing.T) {
 	// Setup mock OpenAI server
-	mockServer := newMockOpenAIServer()
-	defer mockServer.Close()
+	mockServer := testutil.NewMockAssistant(t)
 
 	// Setup database
-	tmpFile, _ := os.CreateTemp("", "integration_shutdown_*.db")
-	tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
-
-	database, _ := db.NewDB(tmpFile.Name())
-	defer database.Close()
-	database.Migrate()
+	database := testutil.NewTestDB(t)
 
 	// Create assistant client with mock
-	assistantClient := createMockAssistantClient(mockServer.URL())
+	assistantClient := mockServer.Client()
 
 	// Create multiple conversations with avatars
 	conv1, _ := database.CreateConversation("Shutdown Test 1", "thread_shutdown_1")
@@ -575,20 +229,13 @@ func TestIntegration_GracefulShutdown(t *testing.T) {
 
 func TestIntegration_MentionTriggersResponse(t *testing.T) {
 	// Setup mock OpenAI server
-	mockServer := newMockOpenAIServer()
-	defer mockServer.Close()
+	mockServer := testutil.NewMockAssistant(t)
 
 	// Setup database
-	tmpFile, _ := os.CreateTemp("", "integration_mention_*.db")
-	tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
-
-	database, _ := db.NewDB(tmpFile.Name())
-	defer database.Close()
-	database.Migrate()
+	database := testutil.NewTestDB(t)
 
 	// Create assistant client with mock
-	assistantClient := createMockAssistantClient(mockServer.URL())
+	assistantClient := mockServer.Client()
 
 	// Create conversation
 	conv, _ := database.CreateConversation("Mention Test", "thread_mention_1")
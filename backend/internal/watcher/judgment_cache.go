@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// DefaultJudgmentCacheTTL is how long an avatar's judgment of a prompt is reused
+const DefaultJudgmentCacheTTL = 5 * time.Minute
+
+// JudgmentCacheStats reports judgment calls answered from the cache instead of the LLM
+type JudgmentCacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// judgmentKey identifies a judgment by avatar and the SHA-256 of its prompt
+// The prompt holds the message content along with the topic, participants and avatar settings,
+// so a changed setting never reuses an outdated decision
+type judgmentKey struct {
+	avatarID int64
+	prompt   [sha256.Size]byte
+}
+
+type judgmentEntry struct {
+	shouldRespond bool
+	expiresAt     time.Time
+}
+
+// judgmentCache keeps the LLM's answers to judgment prompts for a short TTL,
+// so retries, restarted watchers and repeated identical messages do not pay for the same call again
+// A TTL of 0 disables caching
+type judgmentCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[judgmentKey]judgmentEntry
+	hits    uint64
+	misses  uint64
+}
+
+func newJudgmentCache(ttl time.Duration) *judgmentCache {
+	return &judgmentCache{ttl: ttl, entries: make(map[judgmentKey]judgmentEntry)}
+}
+
+// get returns the cached judgment of a prompt if it has not expired
+func (c *judgmentCache) get(avatarID int64, prompt string) (shouldRespond, ok bool) {
+	key := judgmentKey{avatarID: avatarID, prompt: sha256.Sum256([]byte(prompt))}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expiresAt) {
+		c.hits++
+		return entry.shouldRespond, true
+	}
+	if ok {
+		delete(c.entries, key)
+	}
+	c.misses++
+	return false, false
+}
+
+// set stores a judgment until the TTL elapses, dropping expired entries on the way
+func (c *judgmentCache) set(avatarID int64, prompt string, shouldRespond bool) {
+	key := judgmentKey{avatarID: avatarID, prompt: sha256.Sum256([]byte(prompt))}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = judgmentEntry{shouldRespond: shouldRespond, expiresAt: now.Add(c.ttl)}
+}
+
+// SetJudgmentCacheTTL changes how long judgments are reused and drops the cached ones
+// A TTL of 0 disables the cache
+func (m *WatcherManager) SetJudgmentCacheTTL(ttl time.Duration) {
+	m.judgments.mu.Lock()
+	defer m.judgments.mu.Unlock()
+	m.judgments.ttl = ttl
+	m.judgments.entries = make(map[judgmentKey]judgmentEntry)
+}
+
+// JudgmentCacheStats returns the hit and miss counts of the judgment cache
+func (m *WatcherManager) JudgmentCacheStats() JudgmentCacheStats {
+	m.judgments.mu.Lock()
+	defer m.judgments.mu.Unlock()
+	return JudgmentCacheStats{
+		Hits:    m.judgments.hits,
+		Misses:  m.judgments.misses,
+		Entries: len(m.judgments.entries),
+	}
+}
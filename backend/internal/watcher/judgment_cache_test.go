@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestJudgmentCache(t *testing.T) {
+	cache := newJudgmentCache(time.Minute)
+
+	if _, ok := cache.get(1, "prompt"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	cache.set(1, "prompt", true)
+
+	if got, ok := cache.get(1, "prompt"); !ok || !got {
+		t.Errorf("expected a cached yes, got %v ok=%v", got, ok)
+	}
+	if _, ok := cache.get(2, "prompt"); ok {
+		t.Error("expected judgments to be kept per avatar")
+	}
+	if _, ok := cache.get(1, "other prompt"); ok {
+		t.Error("expected a different prompt to miss")
+	}
+	if cache.hits != 1 || cache.misses != 3 {
+		t.Errorf("expected 1 hit and 3 misses, got %d and %d", cache.hits, cache.misses)
+	}
+
+	// Expired entries are not reused
+	cache.ttl = time.Nanosecond
+	cache.set(1, "short", false)
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.get(1, "short"); ok {
+		t.Error("expected an expired judgment to miss")
+	}
+
+	// A TTL of 0 disables caching
+	disabled := newJudgmentCache(0)
+	disabled.set(1, "prompt", true)
+	if _, ok := disabled.get(1, "prompt"); ok {
+		t.Error("expected a disabled cache to miss")
+	}
+}
+
+func TestAvatarWatcher_ReusesCachedJudgment(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	var calls atomic.Int32
+	var model atomic.Value
+	client := newCompletionServer(t, "yes", &calls, &model)
+
+	manager := NewManager(database, client, 10*time.Second)
+	avatar := models.Avatar{ID: 1, Name: "Chef", Prompt: "A chef", OpenAIAssistantID: "asst_chef", RelevanceThreshold: 0}
+	watcher := NewAvatarWatcher(context.Background(), 1, avatar, database, client, 100*time.Millisecond, nil)
+	watcher.judgments = manager.judgments
+
+	for id := int64(1); id <= 2; id++ {
+		message := &models.Message{ID: id, SenderType: models.SenderTypeUser, Content: "What should we cook?"}
+		respond, err := watcher.shouldRespondLLM(context.Background(), message)
+		if err != nil || !respond {
+			t.Fatalf("expected a yes judgment, got %v err=%v", respond, err)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("expected one LLM call for two identical messages, got %d", calls.Load())
+	}
+	if stats := manager.JudgmentCacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
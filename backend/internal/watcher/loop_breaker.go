@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"log"
+	"sync"
+)
+
+// DefaultLoopLimit is how many avatar messages in a row avatars may exchange before they wait for the user
+const DefaultLoopLimit = 10
+
+// LoopNotifier is notified when a conversation starts waiting for the user
+type LoopNotifier interface {
+	BroadcastWaitingForUser(conversationID int64, avatarMessages int)
+}
+
+// loopBreaker stops avatars from answering each other endlessly
+// Once a conversation has limit avatar messages in a row, avatars do not respond until a user message arrives.
+// Each watcher counts the streak itself; the breaker only remembers which conversations are waiting
+type loopBreaker struct {
+	mu       sync.Mutex
+	limit    int
+	notifier LoopNotifier
+	waiting  map[int64]bool
+}
+
+func newLoopBreaker(limit int) *loopBreaker {
+	return &loopBreaker{limit: limit, waiting: make(map[int64]bool)}
+}
+
+// suppress reports whether avatars must not respond after streak avatar messages in a row
+// The first watcher to reach the limit moves the conversation to waiting and notifies clients
+func (b *loopBreaker) suppress(conversationID int64, streak int) bool {
+	b.mu.Lock()
+	if b.limit <= 0 || streak < b.limit {
+		b.mu.Unlock()
+		return false
+	}
+	entered := !b.waiting[conversationID]
+	b.waiting[conversationID] = true
+	notifier := b.notifier
+	b.mu.Unlock()
+
+	if entered {
+		log.Printf("[LoopBreaker] Waiting for user conversation_id=%d avatar_messages=%d", conversationID, streak)
+		if notifier != nil {
+			notifier.BroadcastWaitingForUser(conversationID, streak)
+		}
+	}
+	return true
+}
+
+// reset ends the waiting state of a conversation after a user message
+func (b *loopBreaker) reset(conversationID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.waiting, conversationID)
+}
+
+// SetLoopLimit sets how many avatar messages in a row avatars may exchange before they wait for the user
+// 0 disables the limit. Running watchers use the new limit from their next message
+func (m *WatcherManager) SetLoopLimit(limit int) {
+	m.loop.mu.Lock()
+	defer m.loop.mu.Unlock()
+	m.loop.limit = limit
+}
+
+// SetLoopNotifier sets the notifier for conversations that start waiting for the user
+func (m *WatcherManager) SetLoopNotifier(notifier LoopNotifier) {
+	m.loop.mu.Lock()
+	defer m.loop.mu.Unlock()
+	m.loop.notifier = notifier
+}
+
+// WaitingForUser reports whether the avatars of a conversation stopped responding until the user speaks
+func (m *WatcherManager) WaitingForUser(conversationID int64) bool {
+	m.loop.mu.Lock()
+	defer m.loop.mu.Unlock()
+	return m.loop.waiting[conversationID]
+}
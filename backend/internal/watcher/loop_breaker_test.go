@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+type recordingLoopNotifier struct {
+	calls []int
+}
+
+func (n *recordingLoopNotifier) BroadcastWaitingForUser(conversationID int64, avatarMessages int) {
+	n.calls = append(n.calls, avatarMessages)
+}
+
+func TestLoopBreaker(t *testing.T) {
+	notifier := &recordingLoopNotifier{}
+	breaker := newLoopBreaker(3)
+	breaker.notifier = notifier
+
+	if breaker.suppress(1, 2) {
+		t.Error("expected no suppression below the limit")
+	}
+	if !breaker.suppress(1, 3) || !breaker.suppress(1, 4) {
+		t.Error("expected suppression at and above the limit")
+	}
+	if len(notifier.calls) != 1 || notifier.calls[0] != 3 {
+		t.Errorf("expected one notification for 3 messages, got %v", notifier.calls)
+	}
+
+	// A user message ends the wait, so the next loop notifies again
+	breaker.reset(1)
+	breaker.suppress(1, 3)
+	if len(notifier.calls) != 2 {
+		t.Errorf("expected a second notification after a reset, got %v", notifier.calls)
+	}
+
+	breaker.limit = 0
+	if breaker.suppress(2, 100) {
+		t.Error("expected a limit of 0 to disable the breaker")
+	}
+}
+
+func TestAvatarWatcher_WaitsForUserAfterLoop(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	manager := NewManager(database, nil, 10*time.Second)
+	manager.SetLoopLimit(2)
+
+	avatar := models.Avatar{ID: 1, Name: "Bot", Prompt: "Helpful assistant"}
+	watcher := NewAvatarWatcher(context.Background(), 1, avatar, database, nil, 100*time.Millisecond, nil)
+	watcher.loop = manager.loop
+
+	otherID := int64(2)
+	mention := &models.Message{ID: 3, SenderType: models.SenderTypeAvatar, SenderID: &otherID, Content: "@Bot what do you think?"}
+
+	watcher.countStreak(&models.Message{SenderType: models.SenderTypeUser})
+	watcher.countStreak(&models.Message{SenderType: models.SenderTypeAvatar, SenderID: &avatar.ID})
+	watcher.countStreak(mention)
+
+	respond, err := watcher.shouldRespond(context.Background(), mention)
+	if err != nil || respond {
+		t.Errorf("expected no response after the loop limit, got %v err=%v", respond, err)
+	}
+	if !manager.WaitingForUser(1) {
+		t.Error("expected the conversation to wait for the user")
+	}
+
+	// The user speaks again, and the avatars may respond
+	watcher.countStreak(&models.Message{SenderType: models.SenderTypeUser})
+	if manager.WaitingForUser(1) {
+		t.Error("expected a user message to end the wait")
+	}
+	watcher.countStreak(mention)
+	respond, err = watcher.shouldRespond(context.Background(), mention)
+	if err != nil || !respond {
+		t.Errorf("expected a response to the mention after the user spoke, got %v err=%v", respond, err)
+	}
+}
@@ -2,18 +2,25 @@ package watcher
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"sync"
 	"time"
 
 	"multi-avatar-chat/internal/assistant"
 	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
 	"multi-avatar-chat/internal/models"
 )
 
 // MessageBroadcaster defines the interface for broadcasting messages
 type MessageBroadcaster interface {
-	BroadcastMessage(conversationID int64, message any)
+	BroadcastMessage(conversationID int64, message models.MessageEvent)
+}
+
+// MentionNotifier is notified when an avatar addresses the user with an @mention
+type MentionNotifier interface {
+	NotifyAvatarMention(conversationID int64, avatarName string, msg *models.Message) error
 }
 
 // WatcherManager manages avatar watcher goroutines
@@ -21,12 +28,35 @@ type WatcherManager struct {
 	db                *db.DB
 	assistant         *assistant.Client
 	broadcaster       MessageBroadcaster
+	mentionNotifier   MentionNotifier
+	streamer          ResponseStreamer
+	batchJudge        *BatchJudge
 	watchers          map[watcherKey]*AvatarWatcher
 	mu                sync.RWMutex
 	interval          time.Duration
 	useRandomInterval bool
 	ctx               context.Context
 	cancel            context.CancelFunc
+	// idleAfter is how long a conversation may go without messages before it hibernates (0 disables)
+	idleAfter time.Duration
+	// hibernated holds conversations whose watchers were stopped for inactivity
+	hibernated map[int64]bool
+	// lastWake records when each conversation was last woken by a message or SSE subscription
+	lastWake map[int64]time.Time
+	// lazyStart defers starting the watchers of a conversation until it is first woken
+	lazyStart bool
+	// awake holds conversations whose watchers were started on demand in lazy start mode
+	awake map[int64]bool
+	// loop stops avatars from answering each other endlessly
+	loop *loopBreaker
+	// judgments reuses recent LLM judgments across the manager's watchers
+	judgments *judgmentCache
+	// timeZone is the zone avatars are told the current time in; nil leaves the time out
+	timeZone *time.Location
+	// webhookHosts are the hosts behavior script webhooks may call at local or private addresses
+	webhookHosts []string
+	// instructions builds run instructions and judgment prompts with the safety preamble
+	instructions logic.InstructionsBuilder
 }
 
 type watcherKey struct {
@@ -47,6 +77,12 @@ func NewManager(database *db.DB, assistantClient *assistant.Client, interval tim
 		db:                database,
 		assistant:         assistantClient,
 		watchers:          make(map[watcherKey]*AvatarWatcher),
+		hibernated:        make(map[int64]bool),
+		lastWake:          make(map[int64]time.Time),
+		awake:             make(map[int64]bool),
+		loop:              newLoopBreaker(DefaultLoopLimit),
+		judgments:         newJudgmentCache(DefaultJudgmentCacheTTL),
+		timeZone:          time.Local,
 		interval:          interval,
 		useRandomInterval: useRandom,
 		ctx:               ctx,
@@ -59,8 +95,48 @@ func (m *WatcherManager) SetBroadcaster(broadcaster MessageBroadcaster) {
 	m.broadcaster = broadcaster
 }
 
+// SetMentionNotifier sets the notifier for avatar messages that mention the user
+// Only watchers started afterwards use it
+func (m *WatcherManager) SetMentionNotifier(notifier MentionNotifier) {
+	m.mentionNotifier = notifier
+}
+
+// SetBatchJudgment enables or disables batched judgment
+// When enabled, a single LLM call per message decides for all avatars in the conversation
+// Only watchers started afterwards use it
+func (m *WatcherManager) SetBatchJudgment(enabled bool) {
+	if enabled && m.assistant != nil {
+		m.batchJudge = NewBatchJudge(m.db, m.assistant)
+		m.batchJudge.instructions = m.instructions
+	} else {
+		m.batchJudge = nil
+	}
+}
+
+// SetInstructions sets the builder of run instructions and judgment prompts, which adds the safety preamble
+// Only watchers started afterwards use it
+func (m *WatcherManager) SetInstructions(instructions logic.InstructionsBuilder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instructions = instructions
+	if m.batchJudge != nil {
+		m.batchJudge.instructions = instructions
+	}
+}
+
 // StartWatcher starts a new watcher for the given conversation and avatar
 func (m *WatcherManager) StartWatcher(conversationID, avatarID int64) error {
+	return m.startWatcher(conversationID, avatarID, -1)
+}
+
+// StartWatcherAfter starts a new watcher that treats messages after the given sequence number as new
+// Used when messages are saved before the watcher starts, such as the initial message of a conversation
+func (m *WatcherManager) StartWatcherAfter(conversationID, avatarID, sequence int64) error {
+	return m.startWatcher(conversationID, avatarID, sequence)
+}
+
+// startWatcher starts a watcher; a negative afterSequence starts from the latest message
+func (m *WatcherManager) startWatcher(conversationID, avatarID, afterSequence int64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -93,30 +169,17 @@ func (m *WatcherManager) StartWatcher(conversationID, avatarID int64) error {
 		return err
 	}
 
-	// Build participant names list (User + all avatars)
-	participantNames := []string{"ユーザ"}
-	for _, a := range conversationAvatars {
-		participantNames = append(participantNames, a.Name)
-	}
+	participantNames := buildParticipantNames(conversationAvatars)
 
 	// Create and start watcher with broadcast callback
 	var broadcastFn func(conversationID int64, msg *models.Message, senderName string)
 	if m.broadcaster != nil {
 		broadcastFn = func(convID int64, msg *models.Message, senderName string) {
-			// Create a response object similar to MessageResponse in API
-			msgData := map[string]any{
-				"id":          msg.ID,
-				"sender_type": string(msg.SenderType),
-				"content":     msg.Content,
-				"created_at":  msg.CreatedAt.Format(time.RFC3339),
-			}
-			if msg.SenderID != nil {
-				msgData["sender_id"] = *msg.SenderID
-			}
-			if senderName != "" {
-				msgData["sender_name"] = senderName
-			}
-			m.broadcaster.BroadcastMessage(convID, msgData)
+			event := models.NewMessageEvent(msg)
+			event.SenderName = senderName
+			event.SenderColor = avatar.Color
+			event.SenderEmoji = avatar.Emoji
+			m.broadcaster.BroadcastMessage(convID, event)
 		}
 	}
 
@@ -125,6 +188,17 @@ func (m *WatcherManager) StartWatcher(conversationID, avatarID int64) error {
 
 	// Set conversation context for improved prompts
 	watcher.SetConversationContext(conv.Title, participantNames)
+	watcher.mentionNotifier = m.mentionNotifier
+	watcher.streamer = m.streamer
+	watcher.batchJudge = m.batchJudge
+	watcher.loop = m.loop
+	watcher.judgments = m.judgments
+	watcher.timeZone = m.timeZone
+	watcher.webhookHosts = m.webhookHosts
+	watcher.instructions = m.instructions
+	if afterSequence >= 0 {
+		watcher.SetStartAfter(afterSequence)
+	}
 
 	watcher.Start()
 
@@ -132,6 +206,87 @@ func (m *WatcherManager) StartWatcher(conversationID, avatarID int64) error {
 	log.Printf("[WatcherManager] Watcher started conversation_id=%d avatar_id=%d avatar_name=%s",
 		conversationID, avatarID, avatar.Name)
 
+	// The avatars already watching learn about the one that joined
+	if err := m.refreshRoomLocked(conversationID); err != nil {
+		log.Printf("[WatcherManager] Failed to refresh conversation context conversation_id=%d err=%v", conversationID, err)
+	}
+
+	return nil
+}
+
+// buildParticipantNames returns the participant names of a conversation (User + all avatars)
+func buildParticipantNames(avatars []models.Avatar) []string {
+	participantNames := []string{"ユーザ"}
+	for _, a := range avatars {
+		participantNames = append(participantNames, a.Name)
+	}
+	return participantNames
+}
+
+// RefreshConversation updates the title and participant names of the running watchers of a conversation
+// Call it after participants or the title change without a watcher starting, e.g. when an avatar leaves
+func (m *WatcherManager) RefreshConversation(conversationID int64) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.refreshRoomLocked(conversationID)
+}
+
+// refreshRoomLocked rebuilds the conversation context of a conversation's watchers from the database
+// m.mu must be held
+func (m *WatcherManager) refreshRoomLocked(conversationID int64) error {
+	var watchers []*AvatarWatcher
+	for key, watcher := range m.watchers {
+		if key.ConversationID == conversationID {
+			watchers = append(watchers, watcher)
+		}
+	}
+	if len(watchers) == 0 {
+		return nil
+	}
+
+	conv, err := m.db.GetConversation(conversationID)
+	if err != nil {
+		return err
+	}
+	conversationAvatars, err := m.db.GetConversationAvatars(conversationID)
+	if err != nil {
+		return err
+	}
+	participantNames := buildParticipantNames(conversationAvatars)
+	for _, watcher := range watchers {
+		watcher.SetConversationContext(conv.Title, participantNames)
+	}
+
+	log.Printf("[WatcherManager] Conversation context refreshed conversation_id=%d watchers=%d participants=%v",
+		conversationID, len(watchers), participantNames)
+	return nil
+}
+
+// RefreshAvatar updates running watchers after an avatar was renamed
+// The avatar's own watchers answer to the new name, and the participant names of every
+// conversation it takes part in are rebuilt so @mentions of the new name are recognized
+func (m *WatcherManager) RefreshAvatar(avatarID int64) error {
+	avatar, err := m.db.GetAvatar(avatarID)
+	if err != nil {
+		return err
+	}
+	conversationIDs, err := m.db.GetAvatarConversationIDs(avatarID)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, conversationID := range conversationIDs {
+		if watcher, ok := m.watchers[watcherKey{ConversationID: conversationID, AvatarID: avatarID}]; ok {
+			watcher.SetAvatarName(avatar.Name)
+		}
+		if err := m.refreshRoomLocked(conversationID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -152,14 +307,57 @@ func (m *WatcherManager) StopWatcher(conversationID, avatarID int64) error {
 	delete(m.watchers, key)
 	log.Printf("[WatcherManager] Watcher stopped conversation_id=%d avatar_id=%d", conversationID, avatarID)
 
+	// The avatar left the conversation; if it joins again it starts from the latest message
+	if err := m.db.DeleteWatcherState(conversationID, avatarID); err != nil {
+		log.Printf("[WatcherManager] Failed to delete watcher state conversation_id=%d avatar_id=%d err=%v",
+			conversationID, avatarID, err)
+	}
+
 	return nil
 }
 
 // StopRoomWatchers stops all watchers for a conversation
+// The conversation is not restarted by Wake afterwards
 func (m *WatcherManager) StopRoomWatchers(conversationID int64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	stoppedCount := m.stopRoomLocked(conversationID)
+	delete(m.hibernated, conversationID)
+	delete(m.lastWake, conversationID)
+	if m.lazyStart {
+		// Keep lazy start from starting the watchers again
+		m.awake[conversationID] = true
+	}
+
+	log.Printf("[WatcherManager] StopRoomWatchers completed conversation_id=%d stopped_count=%d",
+		conversationID, stoppedCount)
+	return nil
+}
+
+// StartRoomWatchers starts a watcher for every avatar of a conversation, e.g. after a purge that failed
+// Watchers resume from their saved state, so messages saved while they were stopped are still handled
+func (m *WatcherManager) StartRoomWatchers(conversationID int64) error {
+	avatars, err := m.db.GetConversationAvatars(conversationID)
+	if err != nil {
+		log.Printf("[WatcherManager] Failed to get conversation avatars conversation_id=%d err=%v", conversationID, err)
+		return err
+	}
+
+	for _, avatar := range avatars {
+		if err := m.StartWatcher(conversationID, avatar.ID); err != nil {
+			log.Printf("[WatcherManager] Failed to start watcher conversation_id=%d avatar_id=%d err=%v",
+				conversationID, avatar.ID, err)
+		}
+	}
+
+	log.Printf("[WatcherManager] StartRoomWatchers completed conversation_id=%d avatars=%d", conversationID, len(avatars))
+	return nil
+}
+
+// stopRoomLocked stops and removes all watchers for a conversation
+// The caller must hold m.mu
+func (m *WatcherManager) stopRoomLocked(conversationID int64) int {
 	stoppedCount := 0
 	for key, watcher := range m.watchers {
 		if key.ConversationID == conversationID {
@@ -170,36 +368,82 @@ func (m *WatcherManager) StopRoomWatchers(conversationID int64) error {
 			stoppedCount++
 		}
 	}
-
-	log.Printf("[WatcherManager] StopRoomWatchers completed conversation_id=%d stopped_count=%d",
-		conversationID, stoppedCount)
-	return nil
+	return stoppedCount
 }
 
 // InterruptRoomWatchers interrupts all watchers for a conversation
-// This cancels any active LLM runs and stops the watchers
+// Active LLM runs are cancelled and the watchers are restarted after the latest message,
+// so messages not answered yet are skipped but the avatars respond to new messages again
 func (m *WatcherManager) InterruptRoomWatchers(conversationID int64) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
-	interruptedCount := 0
+	// Decided under the lock so a message saved by a concurrent caller is always after it
+	var afterSequence int64
+	msg, err := m.db.GetLastMessage(conversationID)
+	if err == nil {
+		afterSequence = msg.Sequence
+	} else if err != sql.ErrNoRows {
+		m.mu.Unlock()
+		return err
+	}
+
+	var avatarIDs []int64
 	for key, watcher := range m.watchers {
 		if key.ConversationID == conversationID {
 			watcher.Interrupt()
 			delete(m.watchers, key)
 			log.Printf("[WatcherManager] Watcher interrupted conversation_id=%d avatar_id=%d",
 				key.ConversationID, key.AvatarID)
-			interruptedCount++
+			avatarIDs = append(avatarIDs, key.AvatarID)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, avatarID := range avatarIDs {
+		// Saved so a restart before the next message does not pick up the skipped messages
+		if err := m.db.SaveWatcherState(conversationID, avatarID, afterSequence); err != nil {
+			log.Printf("[WatcherManager] Failed to save watcher state conversation_id=%d avatar_id=%d err=%v",
+				conversationID, avatarID, err)
+		}
+		if err := m.StartWatcherAfter(conversationID, avatarID, afterSequence); err != nil {
+			log.Printf("[WatcherManager] Failed to restart watcher conversation_id=%d avatar_id=%d err=%v",
+				conversationID, avatarID, err)
 		}
 	}
 
-	log.Printf("[WatcherManager] InterruptRoomWatchers completed conversation_id=%d interrupted_count=%d",
-		conversationID, interruptedCount)
+	log.Printf("[WatcherManager] InterruptRoomWatchers completed conversation_id=%d interrupted_count=%d after_sequence=%d",
+		conversationID, len(avatarIDs), afterSequence)
 	return nil
 }
 
 // InitializeAll starts watchers for all existing conversation-avatar pairs
+// With idle hibernation enabled, conversations already idle are hibernated instead.
+// In lazy start mode no watchers are started; each conversation starts when it is first woken
 func (m *WatcherManager) InitializeAll(ctx context.Context) error {
+	// Retries that were waiting when the previous process stopped can be requested again
+	if n, err := m.db.ReopenRetryingDeadLetters(); err != nil {
+		log.Printf("[WatcherManager] Failed to reopen dead letters err=%v", err)
+	} else if n > 0 {
+		log.Printf("[WatcherManager] Reopened dead letters left retrying count=%d", n)
+	}
+
+	// Responses cut off by the previous process were never saved, so they may be generated again
+	if n, err := m.db.ReleasePendingResponses(); err != nil {
+		log.Printf("[WatcherManager] Failed to release pending responses err=%v", err)
+	} else if n > 0 {
+		log.Printf("[WatcherManager] Released responses left in progress count=%d", n)
+	}
+
+	m.mu.RLock()
+	idleAfter := m.idleAfter
+	lazyStart := m.lazyStart
+	m.mu.RUnlock()
+
+	if lazyStart {
+		log.Printf("[WatcherManager] Lazy start enabled, watchers start when a conversation is woken")
+		return nil
+	}
+
 	pairs, err := m.db.GetAllConversationAvatars()
 	if err != nil {
 		log.Printf("[WatcherManager] Failed to get conversation avatars err=%v", err)
@@ -208,7 +452,29 @@ func (m *WatcherManager) InitializeAll(ctx context.Context) error {
 
 	log.Printf("[WatcherManager] Initializing %d watchers", len(pairs))
 
+	cutoff := time.Now().Add(-idleAfter)
+	idle := make(map[int64]bool)
+	if idleAfter > 0 {
+		for _, pair := range pairs {
+			if _, checked := idle[pair.ConversationID]; checked {
+				continue
+			}
+			isIdle, err := m.idleSince(pair.ConversationID, cutoff)
+			if err != nil {
+				log.Printf("[WatcherManager] Failed to check conversation activity conversation_id=%d err=%v",
+					pair.ConversationID, err)
+			}
+			idle[pair.ConversationID] = isIdle
+		}
+	}
+
 	for _, pair := range pairs {
+		if idle[pair.ConversationID] {
+			m.mu.Lock()
+			m.hibernated[pair.ConversationID] = true
+			m.mu.Unlock()
+			continue
+		}
 		if err := m.StartWatcher(pair.ConversationID, pair.AvatarID); err != nil {
 			log.Printf("[WatcherManager] Failed to start watcher conversation_id=%d avatar_id=%d err=%v",
 				pair.ConversationID, pair.AvatarID, err)
@@ -216,7 +482,8 @@ func (m *WatcherManager) InitializeAll(ctx context.Context) error {
 		}
 	}
 
-	log.Printf("[WatcherManager] Initialization completed active_watchers=%d", m.WatcherCount())
+	log.Printf("[WatcherManager] Initialization completed active_watchers=%d hibernated_conversations=%d",
+		m.WatcherCount(), m.HibernatedCount())
 	return nil
 }
 
@@ -236,6 +503,8 @@ func (m *WatcherManager) Shutdown() error {
 
 	watcherCount := len(m.watchers)
 	m.watchers = make(map[watcherKey]*AvatarWatcher)
+	m.hibernated = make(map[int64]bool)
+	m.awake = make(map[int64]bool)
 
 	log.Printf("[WatcherManager] Shutdown complete stopped_count=%d", watcherCount)
 	return nil
@@ -256,3 +525,25 @@ func (m *WatcherManager) HasWatcher(conversationID, avatarID int64) bool {
 	_, exists := m.watchers[key]
 	return exists
 }
+
+// activeRuns returns the runs the watchers are currently waiting for
+func (m *WatcherManager) activeRuns() []models.Run {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var runs []models.Run
+	for key, watcher := range m.watchers {
+		runID, threadID, startedAt := watcher.activeRun()
+		if runID == "" {
+			continue
+		}
+		runs = append(runs, models.Run{
+			ID:             runID,
+			ConversationID: key.ConversationID,
+			AvatarID:       key.AvatarID,
+			ThreadID:       threadID,
+			StartedAt:      startedAt,
+		})
+	}
+	return runs
+}
@@ -2,46 +2,17 @@ package watcher
 
 import (
 	"context"
-	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
 )
 
-func setupTestDB(t *testing.T) (*db.DB, func()) {
-	t.Helper()
-
-	// Create a temporary file for the test database
-	tmpFile, err := os.CreateTemp("", "test_watcher_*.db")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
-	database, err := db.NewDB(tmpFile.Name())
-	if err != nil {
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to open database: %v", err)
-	}
-
-	if err := database.Migrate(); err != nil {
-		database.Close()
-		os.Remove(tmpFile.Name())
-		t.Fatalf("failed to migrate database: %v", err)
-	}
-
-	cleanup := func() {
-		database.Close()
-		os.Remove(tmpFile.Name())
-	}
-
-	return database, cleanup
-}
-
 func TestNewManager(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	manager := NewManager(database, nil, 10*time.Second)
 
@@ -55,8 +26,7 @@ func TestNewManager(t *testing.T) {
 }
 
 func TestManager_StartWatcher(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	// Create a conversation and avatar
 	conv, err := database.CreateConversation("Test Chat", "thread_123")
@@ -87,9 +57,28 @@ func TestManager_StartWatcher(t *testing.T) {
 	}
 }
 
+func TestManager_SetInstructions(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	conv, avatars := testutil.NewTestConversationWithAvatars(t, database, "Alice")
+
+	manager := NewManager(database, nil, 100*time.Millisecond)
+	defer manager.Shutdown()
+	manager.SetInstructions(logic.NewInstructionsBuilder("Never share personal data."))
+
+	if err := manager.StartWatcher(conv.ID, avatars[0].ID); err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+
+	manager.mu.RLock()
+	watcher := manager.watchers[watcherKey{conv.ID, avatars[0].ID}]
+	manager.mu.RUnlock()
+	if prompt := watcher.buildJudgmentPrompt("hello"); !strings.HasPrefix(prompt, "Never share personal data.\n\n") {
+		t.Errorf("expected the safety preamble in the judgment prompt, got %q", prompt)
+	}
+}
+
 func TestManager_StartWatcher_Duplicate(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 	avatar, _ := database.CreateAvatar("TestBot", "Helpful assistant", "asst_123")
@@ -107,8 +96,7 @@ func TestManager_StartWatcher_Duplicate(t *testing.T) {
 }
 
 func TestManager_StopWatcher(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 	avatar, _ := database.CreateAvatar("TestBot", "Helpful assistant", "asst_123")
@@ -134,8 +122,7 @@ func TestManager_StopWatcher(t *testing.T) {
 }
 
 func TestManager_StopWatcher_NotFound(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	manager := NewManager(database, nil, 100*time.Millisecond)
 	defer manager.Shutdown()
@@ -148,8 +135,7 @@ func TestManager_StopWatcher_NotFound(t *testing.T) {
 }
 
 func TestManager_StopRoomWatchers(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 	avatar1, _ := database.CreateAvatar("Bot1", "Prompt1", "asst_1")
@@ -176,9 +162,31 @@ func TestManager_StopRoomWatchers(t *testing.T) {
 	}
 }
 
+func TestManager_StartRoomWatchers(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Test Chat", "thread_123")
+	avatar1, _ := database.CreateAvatar("Bot1", "Prompt1", "asst_1")
+	avatar2, _ := database.CreateAvatar("Bot2", "Prompt2", "asst_2")
+	database.AddAvatarToConversation(conv.ID, avatar1.ID)
+	database.AddAvatarToConversation(conv.ID, avatar2.ID)
+
+	manager := NewManager(database, nil, 100*time.Millisecond)
+	defer manager.Shutdown()
+
+	manager.StartWatcher(conv.ID, avatar1.ID)
+	manager.StopRoomWatchers(conv.ID)
+
+	if err := manager.StartRoomWatchers(conv.ID); err != nil {
+		t.Fatalf("failed to start room watchers: %v", err)
+	}
+	if !manager.HasWatcher(conv.ID, avatar1.ID) || !manager.HasWatcher(conv.ID, avatar2.ID) {
+		t.Errorf("expected a watcher for every avatar, got %d watchers", manager.WatcherCount())
+	}
+}
+
 func TestManager_InitializeAll(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	// Create conversations and avatars
 	conv1, _ := database.CreateConversation("Conv1", "thread_1")
@@ -217,8 +225,7 @@ func TestManager_InitializeAll(t *testing.T) {
 }
 
 func TestManager_Shutdown(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	conv, _ := database.CreateConversation("Test Chat", "thread_123")
 	avatar1, _ := database.CreateAvatar("Bot1", "Prompt1", "asst_1")
@@ -244,8 +251,7 @@ func TestManager_Shutdown(t *testing.T) {
 }
 
 func TestManager_MultipleRooms(t *testing.T) {
-	database, cleanup := setupTestDB(t)
-	defer cleanup()
+	database := testutil.NewTestDB(t)
 
 	conv1, _ := database.CreateConversation("Conv1", "thread_1")
 	conv2, _ := database.CreateConversation("Conv2", "thread_2")
@@ -277,3 +283,190 @@ func TestManager_MultipleRooms(t *testing.T) {
 	}
 }
 
+
+func TestManager_StopWatcher_DeletesState(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	conv, _ := database.CreateConversation("Room", "")
+	database.AddAvatarToConversation(conv.ID, avatar.ID)
+	database.SaveWatcherState(conv.ID, avatar.ID, 7)
+
+	manager := NewManager(database, nil, time.Second)
+	defer manager.Shutdown()
+	manager.StartWatcher(conv.ID, avatar.ID)
+	manager.StopWatcher(conv.ID, avatar.ID)
+
+	if _, err := database.GetWatcherState(conv.ID, avatar.ID); err == nil {
+		t.Error("expected the watcher state to be deleted when the avatar leaves")
+	}
+}
+
+func TestManager_RefreshAvatar(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv1, _ := database.CreateConversation("Conv1", "thread_1")
+	conv2, _ := database.CreateConversation("Conv2", "thread_2")
+	alice, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Prompt", "asst_2")
+	database.AddAvatarToConversation(conv1.ID, alice.ID)
+	database.AddAvatarToConversation(conv1.ID, bob.ID)
+	database.AddAvatarToConversation(conv2.ID, bob.ID)
+
+	manager := NewManager(database, nil, 100*time.Millisecond)
+	defer manager.Shutdown()
+
+	manager.StartWatcher(conv1.ID, alice.ID)
+	manager.StartWatcher(conv1.ID, bob.ID)
+	manager.StartWatcher(conv2.ID, bob.ID)
+
+	database.UpdateAvatar(alice.ID, "Alicia", "Prompt", "asst_1")
+	if err := manager.RefreshAvatar(alice.ID); err != nil {
+		t.Fatalf("RefreshAvatar failed: %v", err)
+	}
+
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	if name := manager.watchers[watcherKey{conv1.ID, alice.ID}].avatarName(); name != "Alicia" {
+		t.Errorf("expected the renamed avatar's watcher to use the new name, got %q", name)
+	}
+	_, names := manager.watchers[watcherKey{conv1.ID, bob.ID}].conversationContext()
+	if !containsName(names, "Alicia") || containsName(names, "Alice") {
+		t.Errorf("expected participants with the new name, got %v", names)
+	}
+	_, names = manager.watchers[watcherKey{conv2.ID, bob.ID}].conversationContext()
+	if containsName(names, "Alicia") {
+		t.Errorf("expected conversations without the avatar to be unchanged, got %v", names)
+	}
+}
+
+// containsName reports whether names contains name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestManager_RefreshConversation(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Topic", "thread_1")
+	alice, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "Prompt", "asst_2")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+
+	manager := NewManager(database, nil, 100*time.Millisecond)
+	defer manager.Shutdown()
+	manager.StartWatcher(conv.ID, alice.ID)
+
+	aliceContext := func() (string, []string) {
+		manager.mu.RLock()
+		defer manager.mu.RUnlock()
+		return manager.watchers[watcherKey{conv.ID, alice.ID}].conversationContext()
+	}
+
+	// Starting a watcher for a new participant refreshes the others
+	database.AddAvatarToConversation(conv.ID, bob.ID)
+	manager.StartWatcher(conv.ID, bob.ID)
+	if _, names := aliceContext(); !containsName(names, "Bob") {
+		t.Errorf("expected Bob to be listed after joining, got %v", names)
+	}
+
+	// Leaving does not start a watcher, so the conversation is refreshed explicitly
+	manager.StopWatcher(conv.ID, bob.ID)
+	database.RemoveAvatarFromConversation(conv.ID, bob.ID)
+	conv.Title = "New Topic"
+	database.UpdateConversation(conv)
+	if err := manager.RefreshConversation(conv.ID); err != nil {
+		t.Fatalf("RefreshConversation failed: %v", err)
+	}
+	title, names := aliceContext()
+	if containsName(names, "Bob") {
+		t.Errorf("expected Bob not to be listed after leaving, got %v", names)
+	}
+	if title != "New Topic" {
+		t.Errorf("expected the new title, got %q", title)
+	}
+}
+
+func TestManager_InterruptRoomWatchers_KeepsWatchers(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Chat", "thread_1")
+	avatar, _ := database.CreateAvatar("Bot", "Prompt", "asst_1")
+	database.AddAvatarToConversation(conv.ID, avatar.ID)
+
+	manager := NewManager(database, nil, time.Hour)
+	defer manager.Shutdown()
+	manager.StartWatcher(conv.ID, avatar.ID)
+
+	// Messages not answered yet when the interrupt arrives
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "first")
+	last, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "second")
+
+	if err := manager.InterruptRoomWatchers(conv.ID); err != nil {
+		t.Fatalf("InterruptRoomWatchers failed: %v", err)
+	}
+
+	if !manager.HasWatcher(conv.ID, avatar.ID) {
+		t.Fatal("expected the watcher to keep running after an interrupt")
+	}
+	manager.mu.RLock()
+	watcher := manager.watchers[watcherKey{conv.ID, avatar.ID}]
+	manager.mu.RUnlock()
+	if !watcher.startAfterSet || watcher.lastSequence != last.Sequence {
+		t.Errorf("expected the watcher to start after sequence %d, got %d", last.Sequence, watcher.lastSequence)
+	}
+	if saved, _ := database.GetWatcherState(conv.ID, avatar.ID); saved != last.Sequence {
+		t.Errorf("expected saved state %d, got %d", last.Sequence, saved)
+	}
+}
+
+func TestManager_SilentMessagesSkippedAfterRestart(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+	database := testutil.NewTestDB(t)
+	client := mockServer.Client()
+
+	conv, _ := database.CreateConversation("Room", "")
+	avatar, _ := database.CreateAvatar("Alice", "Helpful assistant", "asst_1")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+	database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+
+	// The first manager stops after handling the first message
+	first := NewManager(database, client, time.Hour)
+	if err := first.InitializeAll(context.Background()); err != nil {
+		t.Fatalf("InitializeAll failed: %v", err)
+	}
+	first.Shutdown()
+	database.SaveWatcherState(conv.ID, avatar.ID, 1)
+
+	// A silent message and a regular one arrive while the server is down
+	database.CreateSilentMessage(conv.ID, models.SenderTypeUser, nil, "@Alice note for the record")
+	mention, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice are you there?")
+
+	restarted := NewManager(database, client, 50*time.Millisecond)
+	defer restarted.Shutdown()
+	if err := restarted.InitializeAll(context.Background()); err != nil {
+		t.Fatalf("InitializeAll failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if saved, _ := database.GetWatcherState(conv.ID, avatar.ID); saved >= mention.Sequence {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the restarted watcher to catch up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if runs := mockServer.RunAdditionalInstructions(); len(runs) != 1 {
+		t.Errorf("expected only the regular message to be answered, got %d runs", len(runs))
+	}
+}
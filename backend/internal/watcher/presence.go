@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"log"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// holdForUser reports whether the messages up to latest should wait until the user returns
+// While the user is away, avatars do not keep chatting among themselves; the held messages are
+// handled once the user is viewing again. Messages from the user and mentions of the avatar are
+// urgent and are handled right away, together with the messages before them.
+// Held messages are read once, in bounded windows, so a long absence does not make every check slower
+func (w *AvatarWatcher) holdForUser(latest int64) (bool, error) {
+	presence, err := w.db.GetUserPresence(w.conversationID)
+	if err != nil || !presence.Away() {
+		w.heldSequence = 0
+		return false, nil
+	}
+
+	after := max(w.lastSequence, w.heldSequence)
+	for after < latest {
+		messages, err := w.db.GetMessagesAfterSequence(w.conversationID, after, maxMessagesPerCheck)
+		if err != nil {
+			return false, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+		for i := range messages {
+			if w.urgent(&messages[i]) {
+				w.heldSequence = 0
+				return false, nil
+			}
+		}
+		after = messages[len(messages)-1].Sequence
+		w.heldSequence = after
+	}
+	return true, nil
+}
+
+// urgent reports whether a message needs a response even while the user is away
+func (w *AvatarWatcher) urgent(msg *models.Message) bool {
+	switch msg.SenderType {
+	case models.SenderTypeUser:
+		return true
+	case models.SenderTypeSystem:
+		return false
+	}
+	if msg.SenderID != nil && *msg.SenderID == w.avatar.ID {
+		return false
+	}
+	for _, name := range logic.ParseMentions(msg.Content) {
+		if logic.NormalizeAvatarName(name) == logic.NormalizeAvatarName(w.avatarName()) {
+			log.Printf("[AvatarWatcher] Mentioned while user is away message_id=%d avatar_name=%s", msg.ID, w.avatarName())
+			return true
+		}
+	}
+	return false
+}
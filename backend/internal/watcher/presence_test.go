@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestAvatarWatcher_HoldsWhileUserAway(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Presence", "")
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "prompt", "asst_2")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+	database.AddAvatarToConversation(conv.ID, bob.ID)
+
+	w := NewAvatarWatcher(context.Background(), conv.ID, *bob, database, nil, time.Second, nil)
+
+	database.SetUserPresence(conv.ID, models.PresenceAway)
+	database.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Anyone want to talk about Go?")
+
+	// Avatar chatter waits for the user
+	if err := w.checkAndRespond(); err != nil {
+		t.Fatalf("checkAndRespond failed: %v", err)
+	}
+	if w.GetLastSequence() != 0 {
+		t.Errorf("expected the message to be held, last sequence %d", w.GetLastSequence())
+	}
+
+	// A mention is handled right away, together with the held message
+	database.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "I'll ask @Carol instead")
+	w.checkAndRespond()
+	if w.GetLastSequence() != 0 {
+		t.Errorf("expected a mention of another avatar to be held, last sequence %d", w.GetLastSequence())
+	}
+	mention, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Bob what do you think?")
+	w.checkAndRespond()
+	if w.GetLastSequence() != mention.Sequence {
+		t.Errorf("expected a user message to release the held messages, last sequence %d", w.GetLastSequence())
+	}
+
+	// Back to viewing, nothing is held
+	database.SetUserPresence(conv.ID, models.PresenceViewing)
+	chatter, _ := database.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "Go is great")
+	w.checkAndRespond()
+	if w.GetLastSequence() != chatter.Sequence {
+		t.Errorf("expected messages to be handled while the user is viewing, last sequence %d", w.GetLastSequence())
+	}
+}
+
+func TestAvatarWatcher_HoldScansEachMessageOnce(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Presence", "")
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "prompt", "asst_2")
+	w := NewAvatarWatcher(context.Background(), conv.ID, *bob, database, nil, time.Second, nil)
+
+	database.SetUserPresence(conv.ID, models.PresenceAway)
+	for i := 0; i < maxMessagesPerCheck*2+5; i++ {
+		database.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, "chatter")
+	}
+
+	// Held messages are scanned in windows and remembered, so the next check starts after them
+	w.checkAndRespond()
+	if w.GetLastSequence() != 0 || w.heldSequence != maxMessagesPerCheck*2+5 {
+		t.Fatalf("expected all messages held and scanned, last sequence %d held sequence %d", w.GetLastSequence(), w.heldSequence)
+	}
+
+	// An urgent message after a long absence releases the held messages a window at a time
+	urgent, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "I'm back")
+	for i := 0; i < 5 && w.GetLastSequence() < urgent.Sequence; i++ {
+		w.checkAndRespond()
+	}
+	if w.GetLastSequence() != urgent.Sequence {
+		t.Errorf("expected the held messages to be handled, last sequence %d", w.GetLastSequence())
+	}
+}
+
+func TestAvatarWatcher_UrgentMention(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Presence", "")
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	bob, _ := database.CreateAvatar("Bob", "prompt", "asst_2")
+	w := NewAvatarWatcher(context.Background(), conv.ID, *bob, database, nil, time.Second, nil)
+
+	tests := []struct {
+		msg  models.Message
+		want bool
+	}{
+		{models.Message{SenderType: models.SenderTypeUser, Content: "hi"}, true},
+		{models.Message{SenderType: models.SenderTypeSystem, Content: "@Bob digest"}, false},
+		{models.Message{SenderType: models.SenderTypeAvatar, SenderID: &alice.ID, Content: "@bob over to you"}, true},
+		{models.Message{SenderType: models.SenderTypeAvatar, SenderID: &alice.ID, Content: "just chatting"}, false},
+		{models.Message{SenderType: models.SenderTypeAvatar, SenderID: &bob.ID, Content: "@Bob talking to myself"}, false},
+	}
+	for _, tt := range tests {
+		if got := w.urgent(&tt.msg); got != tt.want {
+			t.Errorf("%s %q: expected urgent=%t, got %t", tt.msg.SenderType, tt.msg.Content, tt.want, got)
+		}
+	}
+}
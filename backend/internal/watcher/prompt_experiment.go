@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math/rand"
+
+	"multi-avatar-chat/internal/models"
+)
+
+// promptTrial is the prompt variant an avatar uses while handling one message
+type promptTrial struct {
+	variant string
+	prompt  string
+	// messageID is the response sent with the variant, nil until one is saved
+	messageID *int64
+}
+
+// selectPromptTrial picks the variant of the avatar's prompt experiment for a message
+// Returns nil when the avatar has no experiment
+func (w *AvatarWatcher) selectPromptTrial(ctx context.Context) *promptTrial {
+	experiment, err := w.db.WithContext(ctx).GetPromptExperiment(w.avatar.ID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[AvatarWatcher] Warning: failed to get prompt experiment avatar_id=%d err=%v", w.avatar.ID, err)
+		}
+		return nil
+	}
+
+	if rand.Intn(100) < experiment.SplitB {
+		return &promptTrial{variant: models.PromptVariantB, prompt: experiment.PromptB}
+	}
+	return &promptTrial{variant: models.PromptVariantA, prompt: experiment.PromptA}
+}
+
+// promptFor returns the prompt of the variant being tried, or the avatar's own prompt outside an experiment
+func (w *AvatarWatcher) promptFor(avatarPrompt string) string {
+	if w.trial != nil {
+		return w.trial.prompt
+	}
+	return avatarPrompt
+}
+
+// recordPromptTrial stores the judgment and the response of a message handled under a prompt experiment
+func (w *AvatarWatcher) recordPromptTrial(ctx context.Context, message *models.Message, shouldRespond bool) {
+	trial := &models.PromptTrial{
+		ConversationID:   w.conversationID,
+		AvatarID:         w.avatar.ID,
+		TriggerMessageID: message.ID,
+		Variant:          w.trial.variant,
+		ShouldRespond:    shouldRespond,
+		MessageID:        w.trial.messageID,
+	}
+	if err := w.db.WithContext(ctx).CreatePromptTrial(trial); err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to record prompt trial message_id=%d err=%v", message.ID, err)
+	}
+}
@@ -0,0 +1,74 @@
+package watcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestAvatarWatcher_PromptExperiment(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+	database := testutil.NewTestDB(t)
+	client := mockServer.Client()
+
+	conv, _ := database.CreateConversation("Prompt experiment", "")
+	avatar, _ := database.CreateAvatar("Alice", "Own prompt", "asst_1")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+	database.SetPromptExperiment(avatar.ID, "Prompt A", "Prompt B", 100)
+
+	var broadcast *models.Message
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second,
+		func(_ int64, msg *models.Message, _ string) { broadcast = msg })
+
+	trigger, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice hello")
+	if err := w.handleMessage(trigger); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+
+	if runs := mockServer.RunInstructions(); len(runs) != 1 || !strings.HasSuffix(runs[0], "Prompt B") {
+		t.Errorf("expected the run to use prompt B, got %q", runs)
+	}
+	if broadcast == nil || broadcast.PromptVariant != models.PromptVariantB {
+		t.Fatalf("expected the response to be tagged with variant b, got %+v", broadcast)
+	}
+	if variants, _ := database.GetConversationPromptVariants(conv.ID); variants[broadcast.ID] != models.PromptVariantB {
+		t.Errorf("expected the stored response to be tagged, got %v", variants)
+	}
+	if w.trial != nil {
+		t.Errorf("expected the trial to end with the message")
+	}
+
+	stats, _ := database.GetPromptExperimentStats(avatar.ID, time.Now().Add(-time.Hour))
+	if stats[1].Trials != 1 || stats[1].Responded != 1 || stats[1].Responses != 1 {
+		t.Errorf("expected one trial of variant b, got %+v", stats)
+	}
+
+	// Without an experiment the avatar's assistant keeps its own instructions
+	database.DeletePromptExperiment(avatar.ID)
+	second, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "@Alice again")
+	w.handleMessage(second)
+	if runs := mockServer.RunInstructions(); len(runs) != 2 || runs[1] != "" {
+		t.Errorf("expected no instructions override, got %q", runs)
+	}
+	if broadcast.PromptVariant != "" {
+		t.Errorf("expected an untagged response, got %q", broadcast.PromptVariant)
+	}
+}
+
+func TestAvatarWatcher_BuildJudgmentPrompt_UsesPromptVariant(t *testing.T) {
+	w := &AvatarWatcher{avatar: models.Avatar{Name: "Alice", Prompt: "Own prompt"}, db: testutil.NewTestDB(t)}
+
+	if prompt := w.buildJudgmentPrompt("hello"); !strings.Contains(prompt, "Own prompt") {
+		t.Errorf("expected the avatar prompt, got %q", prompt)
+	}
+
+	w.trial = &promptTrial{variant: models.PromptVariantA, prompt: "Variant prompt"}
+	if prompt := w.buildJudgmentPrompt("hello"); !strings.Contains(prompt, "Variant prompt") || strings.Contains(prompt, "Own prompt") {
+		t.Errorf("expected the variant prompt, got %q", prompt)
+	}
+}
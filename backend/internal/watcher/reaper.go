@@ -0,0 +1,255 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/db"
+	"multi-avatar-chat/internal/models"
+)
+
+const (
+	// DefaultMaxRunDuration is how long a run may stay active before the reaper cancels it
+	DefaultMaxRunDuration = 5 * time.Minute
+	// DefaultReapInterval is how often the reaper looks for stuck runs
+	DefaultReapInterval = time.Minute
+)
+
+// RunFailureNotifier is notified when the reaper fails a stuck run
+type RunFailureNotifier interface {
+	BroadcastRunFailed(conversationID int64, avatarID int64, runID, reason string)
+}
+
+// Reaper cancels OpenAI runs that stay active longer than a maximum duration
+// A stuck in_progress run blocks its thread, so the avatar could never respond again.
+// Runs are collected from the watchers, the runs table and the run lists of the avatar threads
+type Reaper struct {
+	manager     *WatcherManager
+	db          *db.DB
+	assistant   *assistant.Client
+	notifier    RunFailureNotifier
+	maxDuration time.Duration
+	interval    time.Duration
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewReaper creates a reaper for the runs of the manager's watchers
+func NewReaper(manager *WatcherManager, database *db.DB, assistantClient *assistant.Client) *Reaper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reaper{
+		manager:     manager,
+		db:          database,
+		assistant:   assistantClient,
+		maxDuration: DefaultMaxRunDuration,
+		interval:    DefaultReapInterval,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// SetNotifier sets the notifier for failed runs
+func (r *Reaper) SetNotifier(notifier RunFailureNotifier) {
+	r.notifier = notifier
+}
+
+// SetMaxDuration sets how long a run may stay active
+func (r *Reaper) SetMaxDuration(d time.Duration) {
+	r.maxDuration = d
+}
+
+// SetInterval sets how often the reaper looks for stuck runs
+func (r *Reaper) SetInterval(d time.Duration) {
+	r.interval = d
+}
+
+// Start begins reaping in the background
+func (r *Reaper) Start() {
+	r.wg.Add(1)
+	go r.run()
+	log.Printf("[Reaper] Started max_duration=%v interval=%v", r.maxDuration, r.interval)
+}
+
+// Stop stops reaping and waits for a running scan to finish
+func (r *Reaper) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Printf("[Reaper] Stopped")
+}
+
+func (r *Reaper) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reap()
+		}
+	}
+}
+
+// Reap cancels and fails every run that has been active longer than the maximum duration
+// Runs left running in the database that OpenAI reports as finished are closed without a notification.
+// Returns the number of runs failed
+func (r *Reaper) Reap() int {
+	cutoff := time.Now().Add(-r.maxDuration)
+
+	// Runs the watchers are waiting for and runs recorded as running in the database
+	candidates := make(map[string]models.Run)
+	for _, run := range r.manager.activeRuns() {
+		candidates[run.ID] = run
+	}
+	running, err := r.db.GetRunsByStatus(models.RunStatusRunning)
+	if err != nil {
+		log.Printf("[Reaper] Failed to get running runs err=%v", err)
+	}
+	for _, run := range running {
+		if _, ok := candidates[run.ID]; !ok {
+			candidates[run.ID] = run
+		}
+	}
+
+	// Active runs OpenAI knows about, including runs this process never tracked
+	threadRuns := r.listThreadRuns(candidates)
+
+	failed := 0
+	for _, run := range candidates {
+		if !run.StartedAt.Before(cutoff) {
+			continue
+		}
+
+		threadRun, known := threadRuns[run.ID]
+		status := threadRun.Status
+		if known && !isActiveRunStatus(status) {
+			// The watcher gave up waiting but the run ended on its own
+			var errCode, errMsg string
+			if status != "completed" {
+				errCode, errMsg = r.classifyRunFailure(run.ConversationID, run.ThreadID, &threadRun)
+			}
+			if _, err := r.db.FinishRun(run.ID, errCode, errMsg); err != nil {
+				log.Printf("[Reaper] Failed to record run outcome run_id=%s err=%v", run.ID, err)
+			}
+			continue
+		}
+		if status == "cancelling" {
+			continue
+		}
+
+		if r.reap(run) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("[Reaper] Reap completed failed_count=%d", failed)
+	}
+	return failed
+}
+
+// listThreadRuns returns the runs on every avatar thread by ID
+// Active runs that are not candidates yet are added to candidates
+func (r *Reaper) listThreadRuns(candidates map[string]models.Run) map[string]assistant.Run {
+	threadRuns := make(map[string]assistant.Run)
+	if r.assistant == nil || r.assistant.CircuitOpen() {
+		return threadRuns
+	}
+
+	pairs, err := r.db.GetAllConversationAvatars()
+	if err != nil {
+		log.Printf("[Reaper] Failed to get conversation avatars err=%v", err)
+		return threadRuns
+	}
+
+	for _, pair := range pairs {
+		if pair.ThreadID == "" || r.ctx.Err() != nil {
+			continue
+		}
+
+		runs, err := r.clientFor(pair.ConversationID).ListRuns(pair.ThreadID)
+		if err != nil {
+			log.Printf("[Reaper] Failed to list runs thread_id=%s err=%v", pair.ThreadID, err)
+			continue
+		}
+		for _, run := range runs {
+			threadRuns[run.ID] = run
+			if _, ok := candidates[run.ID]; ok || !isActiveRunStatus(run.Status) {
+				continue
+			}
+			candidates[run.ID] = models.Run{
+				ID:             run.ID,
+				ConversationID: pair.ConversationID,
+				AvatarID:       pair.AvatarID,
+				ThreadID:       pair.ThreadID,
+				StartedAt:      time.Unix(run.CreatedAt, 0),
+			}
+		}
+	}
+	return threadRuns
+}
+
+// classifyRunFailure returns the error code and message recorded for a run that ended without completing
+func (r *Reaper) classifyRunFailure(conversationID int64, threadID string, run *assistant.Run) (string, string) {
+	failure, stepID := r.clientFor(conversationID).RunFailure(threadID, run)
+
+	log.Printf("[Reaper] Run ended without completing run_id=%s status=%s error_code=%s step_id=%s error_message=%q",
+		run.ID, run.Status, failure.Code, stepID, failure.Message)
+
+	return failure.Code, runFailureMessage(run, failure)
+}
+
+// reap cancels a stuck run, marks it failed and notifies the conversation
+// Returns false if the run had already been failed
+func (r *Reaper) reap(run models.Run) bool {
+	reason := fmt.Sprintf("run exceeded the maximum duration of %v", r.maxDuration)
+
+	log.Printf("[Reaper] Cancelling stuck run conversation_id=%d avatar_id=%d run_id=%s thread_id=%s started_at=%s",
+		run.ConversationID, run.AvatarID, run.ID, run.ThreadID, run.StartedAt.Format(time.RFC3339))
+
+	if r.assistant != nil {
+		if err := r.clientFor(run.ConversationID).CancelRun(run.ThreadID, run.ID); err != nil {
+			log.Printf("[Reaper] Failed to cancel run run_id=%s err=%v", run.ID, err)
+		}
+	}
+
+	failed, err := r.db.FailRun(run.ID, run.ConversationID, run.AvatarID, run.ThreadID, reason)
+	if err != nil {
+		log.Printf("[Reaper] Failed to mark run failed run_id=%s err=%v", run.ID, err)
+		return false
+	}
+	if !failed {
+		return false
+	}
+
+	if r.notifier != nil {
+		r.notifier.BroadcastRunFailed(run.ConversationID, run.AvatarID, run.ID, reason)
+	}
+	return true
+}
+
+// isActiveRunStatus reports whether a run with the status still occupies its thread
+func isActiveRunStatus(status string) bool {
+	switch status {
+	case "queued", "in_progress", "requires_action", "cancelling":
+		return true
+	}
+	return false
+}
+
+// clientFor returns the assistant client for the OpenAI project of a conversation's threads
+func (r *Reaper) clientFor(conversationID int64) *assistant.Client {
+	client := r.assistant.WithContext(r.ctx)
+	if conv, err := r.db.GetConversation(conversationID); err == nil {
+		client = client.ForProject(conv.OpenAIOrganization, conv.OpenAIProject)
+	}
+	return client
+}
@@ -0,0 +1,195 @@
+package watcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// runFailureRecorder records the runs the reaper reports as failed
+type runFailureRecorder struct {
+	mu     sync.Mutex
+	failed []string
+}
+
+func (r *runFailureRecorder) BroadcastRunFailed(conversationID int64, avatarID int64, runID, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, runID)
+}
+
+// newRunsServer lists the given runs for every thread and records cancelled run IDs
+func newRunsServer(t *testing.T, runs []assistant.Run, cancelled *[]string) *assistant.Client {
+	t.Helper()
+
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/cancel"):
+			parts := strings.Split(r.URL.Path, "/")
+			mu.Lock()
+			*cancelled = append(*cancelled, parts[len(parts)-2])
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{"status": "cancelling"})
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs"):
+			json.NewEncoder(w).Encode(map[string]any{"data": runs})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return assistant.NewClient("mock-api-key",
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+}
+
+func TestReaper_CancelsStuckRuns(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Stuck", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+
+	now := time.Now()
+	var cancelled []string
+	client := newRunsServer(t, []assistant.Run{
+		{ID: "run_stuck", Status: "in_progress", CreatedAt: now.Add(-10 * time.Minute).Unix()},
+		{ID: "run_recent", Status: "in_progress", CreatedAt: now.Unix()},
+		{ID: "run_done", Status: "completed", CreatedAt: now.Add(-time.Hour).Unix()},
+	}, &cancelled)
+
+	manager := NewManager(database, client, time.Second)
+	reaper := NewReaper(manager, database, client)
+	notifier := &runFailureRecorder{}
+	reaper.SetNotifier(notifier)
+
+	if n := reaper.Reap(); n != 1 {
+		t.Fatalf("expected 1 reaped run, got %d", n)
+	}
+	if len(cancelled) != 1 || cancelled[0] != "run_stuck" {
+		t.Errorf("expected run_stuck to be cancelled, got %v", cancelled)
+	}
+	if len(notifier.failed) != 1 || notifier.failed[0] != "run_stuck" {
+		t.Errorf("expected a failure notification for run_stuck, got %v", notifier.failed)
+	}
+
+	run, err := database.GetRun("run_stuck")
+	if err != nil {
+		t.Fatalf("failed to get run: %v", err)
+	}
+	if run.Status != models.RunStatusFailed || run.ConversationID != conv.ID || run.AvatarID != avatar.ID || run.Error == "" {
+		t.Errorf("expected run_stuck to be recorded as failed, got %+v", run)
+	}
+
+	// A run that is already failed is not reported again
+	if n := reaper.Reap(); n != 0 {
+		t.Errorf("expected no reaped runs on the second pass, got %d", n)
+	}
+	if len(notifier.failed) != 1 {
+		t.Errorf("expected no further notifications, got %v", notifier.failed)
+	}
+}
+
+func TestReaper_TrackedRuns(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Tracked", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+
+	// run_finished ended after its watcher gave up; run_lost is unknown to OpenAI's run list
+	database.CreateRun("run_finished", conv.ID, avatar.ID, "thread_1")
+	database.CreateRun("run_lost", conv.ID, avatar.ID, "thread_1")
+
+	var cancelled []string
+	client := newRunsServer(t, []assistant.Run{
+		{ID: "run_finished", Status: "completed", CreatedAt: time.Now().Unix()},
+	}, &cancelled)
+
+	manager := NewManager(database, client, time.Second)
+	reaper := NewReaper(manager, database, client)
+	reaper.SetMaxDuration(0)
+	time.Sleep(10 * time.Millisecond)
+
+	if n := reaper.Reap(); n != 1 {
+		t.Fatalf("expected 1 reaped run, got %d", n)
+	}
+	if len(cancelled) != 1 || cancelled[0] != "run_lost" {
+		t.Errorf("expected only run_lost to be cancelled, got %v", cancelled)
+	}
+
+	if run, _ := database.GetRun("run_finished"); run.Status != models.RunStatusCompleted {
+		t.Errorf("expected run_finished to be closed as completed, got %+v", run)
+	}
+	if run, _ := database.GetRun("run_lost"); run.Status != models.RunStatusFailed {
+		t.Errorf("expected run_lost to fail, got %+v", run)
+	}
+}
+
+func TestReaper_StartStop(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	reaper := NewReaper(NewManager(database, nil, time.Second), database, nil)
+	reaper.SetInterval(10 * time.Millisecond)
+	reaper.Start()
+	time.Sleep(30 * time.Millisecond)
+	reaper.Stop()
+}
+
+func TestReaper_ClassifiesEndedRuns(t *testing.T) {
+	database := testutil.NewTestDB(t)
+
+	conv, _ := database.CreateConversation("Ended", "")
+	avatar, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, "thread_1")
+	database.CreateRun("run_rate_limited", conv.ID, avatar.ID, "thread_1")
+	database.CreateRun("run_expired", conv.ID, avatar.ID, "thread_1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/threads/thread_1/runs":
+			w.Write([]byte(`{"data": [
+				{"id": "run_rate_limited", "status": "failed", "last_error": {"code": "server_error", "message": "Something went wrong"}},
+				{"id": "run_expired", "status": "expired"}
+			]}`))
+		case "/v1/threads/thread_1/runs/run_rate_limited/steps":
+			w.Write([]byte(`{"data": [{"id": "step_1", "status": "failed",
+				"last_error": {"code": "rate_limit_exceeded", "message": "Rate limit reached"}}]}`))
+		case "/v1/threads/thread_1/runs/run_expired/steps":
+			w.Write([]byte(`{"data": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := assistant.NewClient("mock-api-key",
+		assistant.WithHTTPClient(&http.Client{Transport: &testutil.RedirectTransport{BaseURL: server.URL}}))
+
+	reaper := NewReaper(NewManager(database, client, time.Second), database, client)
+	reaper.SetMaxDuration(0)
+	time.Sleep(10 * time.Millisecond)
+
+	if n := reaper.Reap(); n != 0 {
+		t.Fatalf("expected runs that ended on their own not to be reaped, got %d", n)
+	}
+
+	// The failed step is more specific than the run's error
+	run, _ := database.GetRun("run_rate_limited")
+	if run.Status != models.RunStatusFailed || run.ErrorCode != assistant.RunErrorRateLimitExceeded ||
+		run.Error != "run ended with status: failed (rate_limit_exceeded: Rate limit reached)" {
+		t.Errorf("expected run_rate_limited to be classified by its failed step, got %+v", run)
+	}
+	if run, _ := database.GetRun("run_expired"); run.ErrorCode != "expired" || run.Error != "run ended with status: expired" {
+		t.Errorf("expected run_expired to be classified by its status, got %+v", run)
+	}
+}
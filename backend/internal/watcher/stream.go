@@ -0,0 +1,14 @@
+package watcher
+
+// ResponseStreamer receives avatar responses while they are being written
+// Each streamed run ends with BroadcastMessageComplete, with the saved message ID or nil when nothing was saved
+type ResponseStreamer interface {
+	BroadcastMessageDelta(conversationID, avatarID int64, avatarName, runID, delta string)
+	BroadcastMessageComplete(conversationID, avatarID int64, runID string, messageID *int64)
+}
+
+// SetResponseStreamer sets the receiver of avatar responses as they are written
+// nil waits for each run to finish instead. Only watchers started afterwards use it
+func (m *WatcherManager) SetResponseStreamer(streamer ResponseStreamer) {
+	m.streamer = streamer
+}
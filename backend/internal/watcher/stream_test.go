@@ -0,0 +1,67 @@
+package watcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+// recordingStreamer records the streamed text and how each run ended
+type recordingStreamer struct {
+	deltas    []string
+	runIDs    []string
+	completed []*int64
+}
+
+func (s *recordingStreamer) BroadcastMessageDelta(conversationID, avatarID int64, avatarName, runID, delta string) {
+	s.deltas = append(s.deltas, delta)
+	s.runIDs = append(s.runIDs, runID)
+}
+
+func (s *recordingStreamer) BroadcastMessageComplete(conversationID, avatarID int64, runID string, messageID *int64) {
+	s.completed = append(s.completed, messageID)
+}
+
+func TestAvatarWatcher_GenerateResponse_Streams(t *testing.T) {
+	mockServer := testutil.NewMockAssistant(t)
+	database := testutil.NewTestDB(t)
+	client := mockServer.Client()
+
+	conv, _ := database.CreateConversation("Streaming", "")
+	avatar, _ := database.CreateAvatar("Alice", "Prompt", "asst_1")
+	thread, _ := client.CreateThread()
+	database.AddAvatarToConversationWithThreadID(conv.ID, avatar.ID, thread.ID)
+
+	var broadcast *models.Message
+	w := NewAvatarWatcher(context.Background(), conv.ID, *avatar, database, client, time.Second,
+		func(_ int64, msg *models.Message, _ string) { broadcast = msg })
+	streamer := &recordingStreamer{}
+	w.streamer = streamer
+
+	message, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "hello")
+	if err := w.generateResponse(context.Background(), message); err != nil {
+		t.Fatalf("generateResponse failed: %v", err)
+	}
+
+	if got := strings.Join(streamer.deltas, ""); got != testutil.DefaultMockResponse {
+		t.Errorf("expected the response to be streamed, got %q", got)
+	}
+	if len(streamer.deltas) < 2 || streamer.runIDs[0] == "" {
+		t.Errorf("expected several deltas of the run, got %v runs=%v", streamer.deltas, streamer.runIDs)
+	}
+	if broadcast == nil {
+		t.Fatal("expected the saved message to be broadcast")
+	}
+	if len(streamer.completed) != 1 || streamer.completed[0] == nil || *streamer.completed[0] != broadcast.ID {
+		t.Errorf("expected the run to complete with message %d, got %v", broadcast.ID, streamer.completed)
+	}
+
+	run, err := database.GetRun(streamer.runIDs[0])
+	if err != nil || run.Status != models.RunStatusCompleted {
+		t.Errorf("expected the streamed run to be recorded as completed, got %+v err=%v", run, err)
+	}
+}
@@ -0,0 +1,38 @@
+package watcher
+
+import (
+	"log"
+	"time"
+
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// SetTimeAwareness sets the time zone avatars are told the current time in
+// nil leaves the time out of the run instructions. Only watchers started afterwards use it
+func (m *WatcherManager) SetTimeAwareness(location *time.Location) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeZone = location
+}
+
+// buildTimeContext tells the avatar the current time and how long ago the message before
+// the one being responded to was sent. Returns an empty string when time awareness is off
+func (w *AvatarWatcher) buildTimeContext(message *models.Message) string {
+	if w.timeZone == nil {
+		return ""
+	}
+
+	var previousAt time.Time
+	if message != nil {
+		messages, err := w.db.GetMessagesAround(w.conversationID, message.Sequence, 1, 0)
+		if err != nil {
+			log.Printf("[AvatarWatcher] Failed to get previous message for time context conversation_id=%d sequence=%d err=%v",
+				w.conversationID, message.Sequence, err)
+		} else if len(messages) > 1 {
+			previousAt = messages[0].CreatedAt
+		}
+	}
+
+	return logic.FormatTimeContext(time.Now().In(w.timeZone), previousAt)
+}
@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"context"
+	"log"
+
+	"multi-avatar-chat/internal/assistant"
+	"multi-avatar-chat/internal/logic"
+	"multi-avatar-chat/internal/models"
+)
+
+// translateReply translates an avatar reply into the conversation's display language
+// Returns the content to show and the original reply kept as the message translation, which is nil
+// when the reply is shown as written. A failed translation shows the original so the reply is not lost
+func (w *AvatarWatcher) translateReply(ctx context.Context, client *assistant.Client, content string) (string, *models.MessageTranslation) {
+	conv, err := w.db.WithContext(ctx).GetConversation(w.conversationID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Failed to get conversation for translation conversation_id=%d err=%v",
+			w.conversationID, err)
+		return content, nil
+	}
+	if !logic.TranslatesReplies(conv.Language, conv.DisplayLanguage) {
+		return content, nil
+	}
+
+	translated, err := client.Translate(content, conv.DisplayLanguage)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to translate reply conversation_id=%d avatar_name=%s language=%s err=%v",
+			w.conversationID, w.avatarName(), conv.DisplayLanguage, err)
+		return content, nil
+	}
+	if translated == content {
+		return content, nil
+	}
+
+	log.Printf("[AvatarWatcher] Reply translated conversation_id=%d avatar_name=%s language=%s",
+		w.conversationID, w.avatarName(), conv.DisplayLanguage)
+	return translated, &models.MessageTranslation{Language: conv.Language, Content: content}
+}
+
+// translatedContent returns the content avatars read for each message of the conversation
+// Replies shown translated are read in their original language and user messages in the conversation language
+func (w *AvatarWatcher) translatedContent() map[int64]string {
+	translations, err := w.db.GetConversationTranslations(w.conversationID)
+	if err != nil {
+		log.Printf("[AvatarWatcher] Warning: failed to get message translations conversation_id=%d err=%v",
+			w.conversationID, err)
+		return nil
+	}
+	content := make(map[int64]string, len(translations))
+	for id, translation := range translations {
+		content[id] = translation.Content
+	}
+	return content
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-avatar-chat/internal/models"
+	"multi-avatar-chat/internal/testutil"
+)
+
+func TestAvatarWatcher_TranslatesReplyForDisplay(t *testing.T) {
+	database := testutil.NewTestDB(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": "Good morning"}}},
+		})
+	}))
+	defer server.Close()
+	client := testutil.NewAssistantClient(server.URL)
+
+	conv, _ := database.CreateConversation("Salon", "")
+	alice, _ := database.CreateAvatar("Alice", "prompt", "asst_1")
+	database.AddAvatarToConversation(conv.ID, alice.ID)
+	w := NewAvatarWatcher(context.Background(), conv.ID, *alice, database, client, time.Second, nil)
+
+	// Without a display language the reply is shown as written
+	if content, translation := w.translateReply(context.Background(), client, "Bonjour"); content != "Bonjour" || translation != nil {
+		t.Errorf("expected no translation, got %q %+v", content, translation)
+	}
+
+	conv.Language, conv.DisplayLanguage = "French", "English"
+	database.UpdateConversation(conv)
+
+	content, translation := w.translateReply(context.Background(), client, "Bonjour")
+	if content != "Good morning" {
+		t.Errorf("expected the reply in English, got %q", content)
+	}
+	if translation == nil || translation.Language != "French" || translation.Content != "Bonjour" {
+		t.Fatalf("expected the French original to be kept, got %+v", translation)
+	}
+
+	// Avatars read the original reply and the translated user message
+	user, _ := database.CreateMessage(conv.ID, models.SenderTypeUser, nil, "Hi Alice")
+	database.RecordMessageTranslation(user.ID, models.MessageTranslation{Language: "French", Content: "Salut Alice"})
+	bob, _ := database.CreateAvatar("Bob", "prompt", "asst_2")
+	database.AddAvatarToConversation(conv.ID, bob.ID)
+	reply, _ := database.CreateMessage(conv.ID, models.SenderTypeAvatar, &alice.ID, content)
+	database.RecordMessageTranslation(reply.ID, *translation)
+
+	history := NewAvatarWatcher(context.Background(), conv.ID, *bob, database, client, time.Second, nil).buildConversationContext()
+	if !strings.Contains(history, "Salut Alice") || !strings.Contains(history, "Bonjour") || strings.Contains(history, "Good morning") {
+		t.Errorf("expected the history in French, got %q", history)
+	}
+}
@@ -51,7 +51,8 @@ const ChatArea: React.FC = () => {
     if (!newChatTitle.trim()) return;
 
     try {
-      await createConversation(newChatTitle.trim(), selectedAvatars);
+      // アバターを選ばなかった場合はデフォルトのアバターに任せる
+      await createConversation(newChatTitle.trim(), selectedAvatars.length > 0 ? selectedAvatars : undefined);
       setShowNewChat(false);
       setNewChatTitle('');
       setSelectedAvatars([]);
@@ -23,6 +23,9 @@ const MessageList: React.FC = () => {
     if (message.sender_type === 'user') {
       return 'You';
     }
+    if (message.sender_type === 'system') {
+      return 'System';
+    }
     if (message.sender_name) {
       return message.sender_name;
     }
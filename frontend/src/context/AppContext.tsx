@@ -132,7 +132,7 @@ export const AppProvider: React.FC<AppProviderProps> = ({ children }) => {
     }
   }, []);
 
-  const createConversation = useCallback(async (title: string, avatarIds: number[] = []) => {
+  const createConversation = useCallback(async (title: string, avatarIds?: number[]) => {
     try {
       setLoading(true);
       const conversation = await api.createConversation(title, avatarIds);
@@ -230,8 +230,10 @@ export const AppProvider: React.FC<AppProviderProps> = ({ children }) => {
     // 楽観的にユーザメッセージを追加
     const optimisticMessage: Message = {
       id: Date.now(), // 一時的なID
+      sequence: 0, // 保存後に確定する
       sender_type: 'user',
       content: content,
+      ai_label: { ai_generated: false },
       created_at: new Date().toISOString(),
     };
     
@@ -303,6 +305,15 @@ export const AppProvider: React.FC<AppProviderProps> = ({ children }) => {
           console.error('SSEエラー:', error);
           // SSE接続エラーはユーザーに表示しない
           // ユーザーがメッセージ送信時にはポーリングで動作する
+        },
+        // 切断中のイベントを再送できなかった時
+        async () => {
+          try {
+            const messages = await api.getMessages(conversationId);
+            setState(s => (s.currentConversation?.id === conversationId ? { ...s, messages: messages || [] } : s));
+          } catch (err) {
+            console.error('メッセージの再取得に失敗:', err);
+          }
         }
       );
 
@@ -318,6 +329,24 @@ export const AppProvider: React.FC<AppProviderProps> = ({ children }) => {
     };
   }, [state.currentConversation, loadConversationAvatars]);
 
+  // タブの表示状態をプレゼンスとして報告する
+  // 離れている間はアバター同士の会話が保留され、戻ったときに要約がメッセージとして届く
+  useEffect(() => {
+    if (!state.currentConversation) return;
+    const conversationId = state.currentConversation.id;
+
+    const report = () => {
+      const status = document.visibilityState === 'hidden' ? 'away' : 'viewing';
+      api.setPresence(conversationId, status).catch((err) => {
+        console.error('プレゼンスの報告に失敗:', err);
+      });
+    };
+
+    report();
+    document.addEventListener('visibilitychange', report);
+    return () => document.removeEventListener('visibilitychange', report);
+  }, [state.currentConversation]);
+
   // 初期読み込み
   useEffect(() => {
     loadAvatars();
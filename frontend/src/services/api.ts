@@ -5,7 +5,17 @@ export interface Avatar {
   name: string;
   prompt: string;
   openai_assistant_id?: string;
+  color: string;
+  emoji: string;
+  keywords: string[];
+  relevance_threshold: number;
+  can_search: boolean;
+  can_code: boolean;
+  can_cite: boolean;
+  isolated_context: boolean;
+  needs_relink: boolean;
   created_at: string;
+  updated_at: string;
 }
 
 export interface Conversation {
@@ -13,33 +23,195 @@ export interface Conversation {
   title: string;
   thread_id?: string;
   created_at: string;
+  updated_at: string;
+  // TTLを過ぎると削除される日時。期限がない会話では省略される
+  expires_at?: string;
+  // アバター同士が話す言語と、アバターの応答を表示する言語。空なら翻訳しない
+  language?: string;
+  display_language?: string;
+  // OpenAIの利用を計上する組織とプロジェクト。空ならサーバーの既定を使う
+  openai_organization?: string;
+  openai_project?: string;
+  // 公開ギャラリーに載せているか。/gallery/{gallery_slug} で読み取り専用の記録として表示される
+  published?: boolean;
+  // 一度公開した会話のスラッグ。非公開にしても残る
+  gallery_slug?: string;
+}
+
+// 翻訳されたメッセージの、アバターが読む側の内容
+export interface MessageTranslation {
+  language: string;
+  content: string;
+}
+
+export interface MessageArtifact {
+  id: number;
+  type: 'code' | 'logs' | 'image';
+  content?: string;
+  url?: string;
+  created_at: string;
+}
+
+export interface MessageCitation {
+  type: 'file_citation' | 'file_path';
+  marker: string;
+  file_id: string;
+  filename?: string;
+  quote?: string;
+  start_index: number;
+  end_index: number;
+}
+
+export interface AILabel {
+  ai_generated: boolean;
+  model?: string;
 }
 
 export interface Message {
   id: number;
-  sender_type: 'user' | 'avatar';
+  sequence: number;
+  sender_type: 'user' | 'avatar' | 'system';
   sender_id?: number;
   sender_name?: string;
+  sender_color?: string;
+  sender_emoji?: string;
   content: string;
+  artifacts?: MessageArtifact[];
+  citations?: MessageCitation[];
+  prompt_variant?: 'a' | 'b';
+  ai_label: AILabel;
+  translation?: MessageTranslation;
+  // アバターが応答しないメッセージ (silent で送信、またはインポート) で true になる
+  silent?: boolean;
+  created_at: string;
+}
+
+export interface ConversationDisclosure {
+  conversation_id: number;
+  contains_ai_content: boolean;
+  message_count: number;
+  ai_message_count: number;
+  models: string[];
+  avatars: { id: number; name: string; message_count: number }[];
+  banner?: string;
+}
+
+// 会話の参加者ごとの発言量を時間窓ごとに集計したもの。メッセージのない窓は含まれない
+export interface TimelineWindow {
+  start: string;
+  messages: number;
+  characters: number;
+}
+
+export interface TimelineParticipant {
+  sender_type: 'user' | 'avatar' | 'system';
+  avatar_id?: number;
+  name: string;
+  message_count: number;
+  windows: TimelineWindow[];
+}
+
+export interface ConversationTimeline {
+  conversation_id: number;
+  window_seconds: number;
+  message_count: number;
+  start?: string;
+  end?: string;
+  participants: TimelineParticipant[];
+}
+
+// ユーザが会話を見ているか。報告がない会話は viewing として扱われる
+export type PresenceStatus = 'viewing' | 'away';
+
+export interface UserPresence {
+  conversation_id: number;
+  status: PresenceStatus;
+  updated_at?: string;
+  // 離れている間のメッセージの要約。戻ったときに新しいメッセージがあれば投稿される
+  summary?: Message;
+}
+
+// 会話内でアバターに必ず応答させるトリガー。キーワードは大文字小文字を区別せず部分一致、regex は正規表現
+export interface AvatarTrigger {
+  id: number;
+  conversation_id: number;
+  avatar_id: number;
+  pattern: string;
+  regex: boolean;
   created_at: string;
 }
 
+export interface MessageDelivery {
+  avatar_id: number;
+  avatar_name: string;
+  status: 'pending' | 'delivered' | 'failed' | 'queued' | 'skipped';
+  error?: string;
+}
+
 export interface SendMessageResponse {
   user_message: Message;
   avatar_responses?: Message[];
+  deliveries?: MessageDelivery[];
+  delivery_url?: string;
+  // 会話のredaction_policyにより保存前に伏せ字にした件数（種類別）
+  redactions?: Record<string, number>;
+}
+
+export interface MessageDeliveries {
+  message_id: number;
+  complete: boolean;
+  deliveries: MessageDelivery[];
 }
 
 // SSEイベント型
-export type SSEEventType = 'message' | 'avatar_joined' | 'avatar_left' | 'connected';
+// ペイロードの形はバックエンドの GET /api/events/schema と一致させる
+export type SSEEventType =
+  | 'connected'
+  | 'message'
+  | 'avatar_joined'
+  | 'avatar_left'
+  | 'interrupt'
+  | 'run_failed'
+  | 'waiting_for_user'
+  | 'messages_imported'
+  | 'suggestions'
+  | 'viewer_count'
+  | 'llm_unavailable'
+  | 'llm_available'
+  | 'server_shutdown'
+  | 'conversation_expired'
+  | 'presence'
+  | 'message_delta'
+  | 'message_complete'
+  | 'typing'
+  | 'resync';
 
 export interface SSEMessageEvent {
   type: 'message';
   data: Message;
 }
 
+// 生成中のアバター応答の断片。run_id ごとに連結すると途中までの応答になる
+export interface SSEMessageDeltaEvent {
+  type: 'message_delta';
+  data: { avatar_id: number; avatar_name: string; run_id: string; delta: string };
+}
+
+// 応答の生成終了。saved なら直前の message イベントで置き換え、failed なら断片を破棄する
+export interface SSEMessageCompleteEvent {
+  type: 'message_complete';
+  data: { avatar_id: number; run_id: string; status: 'saved' | 'failed'; message_id?: number };
+}
+
+// WebSocketクライアントでユーザーが入力中
+export interface SSETypingEvent {
+  type: 'typing';
+  data: { sender_type: 'user' };
+}
+
 export interface SSEAvatarJoinedEvent {
   type: 'avatar_joined';
-  data: { avatar_id: number; avatar_name: string };
+  data: { avatar_id: number; avatar_name: string; avatar_color?: string; avatar_emoji?: string };
 }
 
 export interface SSEAvatarLeftEvent {
@@ -47,7 +219,98 @@ export interface SSEAvatarLeftEvent {
   data: { avatar_id: number };
 }
 
-export type SSEEvent = SSEMessageEvent | SSEAvatarJoinedEvent | SSEAvatarLeftEvent;
+// 最大実行時間を超えて打ち切られたアバターの実行
+export interface SSERunFailedEvent {
+  type: 'run_failed';
+  data: { avatar_id: number; run_id: string; reason: string };
+}
+
+// アバター同士の発言が続いたため、ユーザの発言まで応答を止めた
+export interface SSEWaitingForUserEvent {
+  type: 'waiting_for_user';
+  data: { avatar_messages: number };
+}
+
+// まとめて挿入されたメッセージの範囲。メッセージ一覧を取得し直す
+export interface SSEMessagesImportedEvent {
+  type: 'messages_imported';
+  data: { count: number; first_sequence: number; last_sequence: number };
+}
+
+export interface SSESuggestionsEvent {
+  type: 'suggestions';
+  data: { conversation_id: number; message_id: number; suggestions: string[]; created_at: string };
+}
+
+export interface SSEViewerCountEvent {
+  type: 'viewer_count';
+  data: { conversation_id: number; viewers: number };
+}
+
+export interface SSELLMUnavailableEvent {
+  type: 'llm_unavailable';
+  data: { retry_after_seconds: number };
+}
+
+export interface SSEServerShutdownEvent {
+  type: 'server_shutdown';
+  data: { retry_ms: number };
+}
+
+export interface SSEPresenceEvent {
+  type: 'presence';
+  data: { conversation_id: number; status: PresenceStatus; updated_at: string };
+}
+
+// 有効期限を過ぎた会話がまもなく削除される。すべての会話のストリームに送信される
+export interface SSEConversationExpiredEvent {
+  type: 'conversation_expired';
+  data: { conversation_id: number; title: string };
+}
+
+// データを持たないイベント
+// resync は再接続時に切断中のイベントを再送できなかったことを表し、メッセージ一覧を取得し直す
+export interface SSEEmptyEvent {
+  type: 'connected' | 'interrupt' | 'llm_available' | 'resync';
+  data: Record<string, never>;
+}
+
+export type SSEEvent =
+  | SSEMessageEvent
+  | SSEMessageDeltaEvent
+  | SSEMessageCompleteEvent
+  | SSEAvatarJoinedEvent
+  | SSEAvatarLeftEvent
+  | SSERunFailedEvent
+  | SSEWaitingForUserEvent
+  | SSEMessagesImportedEvent
+  | SSESuggestionsEvent
+  | SSEViewerCountEvent
+  | SSELLMUnavailableEvent
+  | SSEServerShutdownEvent
+  | SSEConversationExpiredEvent
+  | SSEPresenceEvent
+  | SSETypingEvent
+  | SSEEmptyEvent;
+
+// WebSocket (GET /api/conversations/:id/ws) でクライアントから送るメッセージ
+// id は任意で、対応する応答にそのまま含まれる
+export type SocketRequest =
+  | { type: 'send_message'; id?: string; data: { content: string; silent?: boolean } }
+  | { type: 'typing' };
+
+// WebSocketで送ったメッセージへの応答。イベントと同じ接続で届く
+export type SocketReply =
+  | { type: 'message_sent'; id?: string; data: { user_message: Message; redactions?: Record<string, number> } }
+  | { type: 'error'; id?: string; data: { message: string; retry_after_seconds?: number } };
+
+// 会話の保存済みイベント履歴
+export interface ConversationEventHistory {
+  id: number;
+  type: 'avatar_joined' | 'avatar_left' | 'interrupt' | 'run_failed';
+  data: Record<string, unknown>;
+  created_at: string;
+}
 
 class ApiService {
   private async request<T>(
@@ -93,6 +356,13 @@ class ApiService {
     });
   }
 
+  async importPersona(persona: unknown, onConflict: 'error' | 'rename' | 'replace' = 'error'): Promise<Avatar> {
+    return this.request<Avatar>(`/avatars/import-persona?on_conflict=${onConflict}`, {
+      method: 'POST',
+      body: JSON.stringify(persona),
+    });
+  }
+
   async deleteAvatar(id: number): Promise<void> {
     return this.request<void>(`/avatars/${id}`, {
       method: 'DELETE',
@@ -104,9 +374,10 @@ class ApiService {
     return this.request<Conversation[]>('/conversations');
   }
 
+  // avatarIds を省略するとデフォルトのアバターが参加する
   async createConversation(
     title: string,
-    avatarIds: number[] = []
+    avatarIds?: number[]
   ): Promise<Conversation> {
     return this.request<Conversation>('/conversations', {
       method: 'POST',
@@ -132,12 +403,66 @@ class ApiService {
     });
   }
 
+  async getMessageDeliveries(conversationId: number, messageId: number): Promise<MessageDeliveries> {
+    return this.request<MessageDeliveries>(
+      `/conversations/${conversationId}/messages/${messageId}/deliveries`
+    );
+  }
+
+  async getConversationDisclosure(conversationId: number): Promise<ConversationDisclosure> {
+    return this.request<ConversationDisclosure>(`/conversations/${conversationId}/disclosure`);
+  }
+
+  // window は "5m" や "1h" などの時間窓。省略すると会話の長さから自動で選ばれる
+  async getConversationTimeline(conversationId: number, window?: string): Promise<ConversationTimeline> {
+    const query = window ? `?window=${encodeURIComponent(window)}` : '';
+    return this.request<ConversationTimeline>(`/conversations/${conversationId}/timeline${query}`);
+  }
+
+  // slug を省略すると、前回のスラッグかタイトルから作ったスラッグで公開する
+  async publishConversation(conversationId: number, slug?: string): Promise<Conversation> {
+    return this.request<Conversation>(`/conversations/${conversationId}/gallery`, {
+      method: 'PUT',
+      body: JSON.stringify(slug ? { slug } : {}),
+    });
+  }
+
+  async unpublishConversation(conversationId: number): Promise<Conversation> {
+    return this.request<Conversation>(`/conversations/${conversationId}/gallery`, {
+      method: 'DELETE',
+    });
+  }
+
+  async getPresence(conversationId: number): Promise<UserPresence> {
+    return this.request<UserPresence>(`/conversations/${conversationId}/presence`);
+  }
+
+  async setPresence(conversationId: number, status: PresenceStatus): Promise<UserPresence> {
+    return this.request<UserPresence>(`/conversations/${conversationId}/presence`, {
+      method: 'PUT',
+      body: JSON.stringify({ status }),
+    });
+  }
+
+  async splitConversation(conversationId: number, messageId: number): Promise<Conversation & { moved_messages: number }> {
+    return this.request<Conversation & { moved_messages: number }>(
+      `/conversations/${conversationId}/split?at=${messageId}`,
+      { method: 'POST' }
+    );
+  }
+
   async interruptConversation(conversationId: number): Promise<void> {
     return this.request<void>(`/conversations/${conversationId}/interrupt`, {
       method: 'POST',
     });
   }
 
+  async getEventHistory(conversationId: number, afterId = 0): Promise<ConversationEventHistory[]> {
+    return this.request<ConversationEventHistory[]>(
+      `/conversations/${conversationId}/events/history?after_id=${afterId}`
+    );
+  }
+
   // 会話アバター管理エンドポイント
   async getConversationAvatars(conversationId: number): Promise<Avatar[]> {
     return this.request<Avatar[]>(`/conversations/${conversationId}/avatars`);
@@ -156,13 +481,36 @@ class ApiService {
     });
   }
 
+  async getAvatarTriggers(conversationId: number, avatarId: number): Promise<AvatarTrigger[]> {
+    return this.request<AvatarTrigger[]>(`/conversations/${conversationId}/avatars/${avatarId}/triggers`);
+  }
+
+  async createAvatarTrigger(
+    conversationId: number,
+    avatarId: number,
+    pattern: string,
+    regex = false
+  ): Promise<AvatarTrigger> {
+    return this.request<AvatarTrigger>(`/conversations/${conversationId}/avatars/${avatarId}/triggers`, {
+      method: 'POST',
+      body: JSON.stringify({ pattern, regex }),
+    });
+  }
+
+  async deleteAvatarTrigger(conversationId: number, avatarId: number, triggerId: number): Promise<void> {
+    return this.request<void>(`/conversations/${conversationId}/avatars/${avatarId}/triggers/${triggerId}`, {
+      method: 'DELETE',
+    });
+  }
+
   // リアルタイム更新のためのSSE購読
   subscribeToMessages(
     conversationId: number,
     onMessage: (message: Message) => void,
     onAvatarJoined?: (data: { avatar_id: number; avatar_name: string }) => void,
     onAvatarLeft?: (data: { avatar_id: number }) => void,
-    onError?: (error: Error) => void
+    onError?: (error: Error) => void,
+    onResync?: () => void
   ): () => void {
     const eventSource = new EventSource(`${API_BASE}/conversations/${conversationId}/events`);
 
@@ -197,6 +545,18 @@ class ApiService {
       console.log('SSE接続完了 conversation_id:', conversationId);
     });
 
+    // 再接続時、ブラウザは最後に受信したイベントIDを Last-Event-ID で送り、サーバーは切断中のイベントを再送する
+    // 再送できなかった場合は resync が届くので、メッセージを取得し直す
+    eventSource.addEventListener('resync', () => {
+      console.log('イベントを再送できないため再取得 conversation_id:', conversationId);
+      onResync?.();
+    });
+
+    // サーバー停止時はストリームが閉じられ、ブラウザがretry経過後に自動で再接続する
+    eventSource.addEventListener('server_shutdown', () => {
+      console.log('サーバー停止のため再接続待ち conversation_id:', conversationId);
+    });
+
     eventSource.onerror = () => {
       if (onError) {
         onError(new Error('SSE接続エラー'));
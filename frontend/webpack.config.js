@@ -1,6 +1,51 @@
 const path = require('path');
+const zlib = require('zlib');
+const webpack = require('webpack');
 const HtmlWebpackPlugin = require('html-webpack-plugin');
 
+// Text assets smaller than this are not worth precompressing
+const MIN_COMPRESS_SIZE = 1024;
+
+// Writes manifest.json, mapping entry names to fingerprinted files, and .br/.gz siblings of text assets.
+// The backend caches the listed files forever and serves the siblings to clients that accept them.
+class AssetManifestPlugin {
+  apply(compiler) {
+    compiler.hooks.thisCompilation.tap('AssetManifestPlugin', (compilation) => {
+      const { RawSource } = webpack.sources;
+
+      compilation.hooks.processAssets.tap(
+        { name: 'AssetManifestPlugin', stage: webpack.Compilation.PROCESS_ASSETS_STAGE_SUMMARIZE },
+        () => {
+          const manifest = {};
+          for (const chunk of compilation.chunks) {
+            for (const file of chunk.files) {
+              manifest[`${chunk.name || chunk.id}${path.extname(file)}`] = file;
+            }
+          }
+          compilation.emitAsset('manifest.json', new RawSource(JSON.stringify(manifest, null, 2)));
+        },
+      );
+
+      compilation.hooks.processAssets.tap(
+        { name: 'AssetManifestPlugin', stage: webpack.Compilation.PROCESS_ASSETS_STAGE_OPTIMIZE_TRANSFER },
+        (assets) => {
+          for (const [file, source] of Object.entries(assets)) {
+            if (!/\.(js|css|html|json|svg)$/.test(file)) {
+              continue;
+            }
+            const buffer = source.buffer();
+            if (buffer.length < MIN_COMPRESS_SIZE) {
+              continue;
+            }
+            compilation.emitAsset(`${file}.br`, new RawSource(zlib.brotliCompressSync(buffer)));
+            compilation.emitAsset(`${file}.gz`, new RawSource(zlib.gzipSync(buffer, { level: 9 })));
+          }
+        },
+      );
+    });
+  }
+}
+
 module.exports = {
   entry: './src/index.tsx',
   output: {
@@ -41,6 +86,7 @@ module.exports = {
     new HtmlWebpackPlugin({
       template: './public/index.html',
     }),
+    new AssetManifestPlugin(),
   ],
   devServer: {
     port: 3000,
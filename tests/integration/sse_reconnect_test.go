@@ -0,0 +1,167 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ai-book-demo/tests/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSSEReconnect はSSEの再接続、イベントの順序、切断中のイベントの再送をテストする
+func TestSSEReconnect(t *testing.T) {
+	suite := utils.NewTestSuite()
+
+	// サーバーの準備を待つ
+	if err := suite.WaitForServer(10 * time.Second); err != nil {
+		t.Fatalf("Server not ready: %v", err)
+	}
+
+	// アバターなしの会話を使い、silentメッセージのmessageイベントだけを観測する
+	var testConversationID int64
+
+	t.Run("Setup: Create test conversation", func(t *testing.T) {
+		payload := map[string]any{
+			"title":      fmt.Sprintf("SSEReconnectTestConv_%d", time.Now().UnixNano()),
+			"avatar_ids": []int64{},
+		}
+
+		resp, err := suite.Client.POST("/api/conversations", payload)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		if resp.StatusCode == 201 {
+			var conversation Conversation
+			err = utils.ReadJSON(resp, &conversation)
+			require.NoError(t, err)
+			testConversationID = conversation.ID
+		}
+	})
+
+	eventsPath := func() string {
+		return fmt.Sprintf("/api/conversations/%d/events", testConversationID)
+	}
+
+	// sendSilent はアバターに転送されず、messageイベントとして配信されるメッセージを送る
+	sendSilent := func(t *testing.T, content string) {
+		t.Helper()
+		resp, err := suite.Client.POST(fmt.Sprintf("/api/conversations/%d/messages", testConversationID),
+			map[string]any{"content": content, "silent": true})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		utils.AssertStatusCreated(t, resp)
+	}
+
+	// messageWithContent は指定された内容のmessageイベントを選ぶ
+	messageWithContent := func(content string) utils.SSEEventFilter {
+		return func(e utils.SSEEvent) bool {
+			if e.Type != "message" {
+				return false
+			}
+			var data struct {
+				Content string `json:"content"`
+			}
+			return e.JSON(&data) == nil && data.Content == content
+		}
+	}
+
+	connect := func(t *testing.T, ctx context.Context) *utils.SSEConnection {
+		t.Helper()
+		conn, err := utils.NewSSEClient(suite.Client.BaseURL).Connect(ctx, eventsPath())
+		require.NoError(t, err, "SSE接続の確立に失敗")
+		_, err = conn.WaitForEvent("connected", 3*time.Second)
+		require.NoError(t, err, "connectedイベントの受信に失敗")
+		return conn
+	}
+
+	t.Run("Reconnected stream receives new events", func(t *testing.T) {
+		if testConversationID == 0 {
+			t.Skip("No test conversation available")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		conn := connect(t, ctx)
+		conn, err := conn.Reconnect(ctx)
+		require.NoError(t, err, "SSEの再接続に失敗")
+		defer conn.Close()
+		_, err = conn.WaitForEvent("connected", 3*time.Second)
+		require.NoError(t, err, "再接続後のconnectedイベントの受信に失敗")
+
+		content := fmt.Sprintf("after reconnect %d", time.Now().UnixNano())
+		sendSilent(t, content)
+		_, err = conn.WaitForMatch(messageWithContent(content), 3*time.Second)
+		assert.NoError(t, err, "再接続後のmessageイベントの受信に失敗")
+	})
+
+	t.Run("Events arrive in the order they were sent", func(t *testing.T) {
+		if testConversationID == 0 {
+			t.Skip("No test conversation available")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		conn := connect(t, ctx)
+		defer conn.Close()
+
+		prefix := fmt.Sprintf("ordered %d", time.Now().UnixNano())
+		contents := []string{prefix + " first", prefix + " second", prefix + " third"}
+		for _, content := range contents {
+			sendSilent(t, content)
+		}
+
+		events := utils.AssertEventSequence(t, conn, 5*time.Second, "message", "message", "message")
+		for i, event := range events {
+			var data struct {
+				Content string `json:"content"`
+			}
+			require.NoError(t, event.JSON(&data))
+			assert.Equal(t, contents[i], data.Content, "messageイベントが送信順に届いていない")
+		}
+	})
+
+	t.Run("Missed events are replayed after reconnect", func(t *testing.T) {
+		if testConversationID == 0 {
+			t.Skip("No test conversation available")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		conn := connect(t, ctx)
+		seen := fmt.Sprintf("seen %d", time.Now().UnixNano())
+		sendSilent(t, seen)
+		_, err := conn.WaitForMatch(messageWithContent(seen), 3*time.Second)
+		require.NoError(t, err)
+		lastEventID := conn.LastEventID()
+		require.NotEmpty(t, lastEventID, "イベントにIDが付いていない")
+
+		// 切断中に送られたイベントは、Last-Event-IDでの再接続時に再送される
+		conn.Close()
+		missed := fmt.Sprintf("missed %d", time.Now().UnixNano())
+		sendSilent(t, missed)
+
+		conn, err = conn.Reconnect(ctx)
+		require.NoError(t, err, "SSEの再接続に失敗")
+		defer conn.Close()
+		replayed, err := conn.WaitForMatch(messageWithContent(missed), 3*time.Second)
+		require.NoError(t, err, "切断中のmessageイベントが再送されない")
+		assert.NotEqual(t, lastEventID, replayed.ID, "再送されたイベントのIDが切断前と同じ")
+		// 受信済みのイベントは再送されない
+		utils.AssertNoEvent(t, conn, 500*time.Millisecond, "message")
+	})
+
+	// Cleanup
+	t.Run("Cleanup: Delete test conversation", func(t *testing.T) {
+		if testConversationID == 0 {
+			return
+		}
+
+		resp, err := suite.Client.DELETE(fmt.Sprintf("/api/conversations/%d", testConversationID))
+		if err == nil {
+			resp.Body.Close()
+		}
+	})
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"net/http"
 	"testing"
+	"time"
 )
 
 // AssertStatusCode checks if response has expected status code
@@ -62,3 +63,27 @@ func AssertJSONContentType(t *testing.T, resp *http.Response) {
 	}
 }
 
+
+// AssertEventSequence waits for SSE events of the given types and checks they arrive in that order
+// Events of other types are ignored. Returns the events received
+func AssertEventSequence(t *testing.T, conn *SSEConnection, timeout time.Duration, types ...string) []SSEEvent {
+	t.Helper()
+	events, err := conn.Collect(EventTypes(types...), len(types), timeout)
+	if err != nil {
+		t.Errorf("Expected events %v, got %d of them: %v", types, len(events), err)
+	}
+	for i, event := range events {
+		if event.Type != types[i] {
+			t.Errorf("Expected event %d to be %q, got %q", i, types[i], event.Type)
+		}
+	}
+	return events
+}
+
+// AssertNoEvent checks that no SSE event of the given types arrives within the duration
+func AssertNoEvent(t *testing.T, conn *SSEConnection, within time.Duration, types ...string) {
+	t.Helper()
+	if event := conn.ExpectNone(EventTypes(types...), within); event != nil {
+		t.Errorf("Expected no %v event, got %q with data %s", types, event.Type, event.Data)
+	}
+}
@@ -7,15 +7,41 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
 // SSEEvent はServer-Sent Eventを表す
+// ID はサーバーが id: 行を送った場合のみ設定される
 type SSEEvent struct {
+	ID   string
 	Type string
 	Data string
 }
 
+// JSON はイベントのデータをJSONとしてパースする
+func (e SSEEvent) JSON(target any) error {
+	if err := json.Unmarshal([]byte(e.Data), target); err != nil {
+		return fmt.Errorf("failed to parse %s event data: %w", e.Type, err)
+	}
+	return nil
+}
+
+// SSEEventFilter はイベントを選ぶ条件を表す
+type SSEEventFilter func(SSEEvent) bool
+
+// EventTypes は指定されたタイプのいずれかのイベントを選ぶフィルタを返す
+func EventTypes(types ...string) SSEEventFilter {
+	return func(e SSEEvent) bool {
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // SSEClient はSSE接続を管理するクライアント
 type SSEClient struct {
 	baseURL    string
@@ -34,22 +60,38 @@ func NewSSEClient(baseURL string) *SSEClient {
 
 // SSEConnection はアクティブなSSE接続を表す
 type SSEConnection struct {
-	resp     *http.Response
-	scanner  *bufio.Scanner
-	eventCh  chan SSEEvent
-	errCh    chan error
-	ctx      context.Context
-	cancel   context.CancelFunc
+	client  *SSEClient
+	path    string
+	resp    *http.Response
+	scanner *bufio.Scanner
+	eventCh chan SSEEvent
+	errCh   chan error
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// mu は最後に受信したイベントIDとリトライ間隔を保護する
+	mu          sync.Mutex
+	lastEventID string
+	retry       time.Duration
 }
 
 // Connect はSSEエンドポイントに接続する
 func (c *SSEClient) Connect(ctx context.Context, path string) (*SSEConnection, error) {
+	return c.ConnectWithLastEventID(ctx, path, "")
+}
+
+// ConnectWithLastEventID は Last-Event-ID ヘッダを付けてSSEエンドポイントに接続する
+// 空の lastEventID ではヘッダを付けない
+func (c *SSEClient) ConnectWithLastEventID(ctx context.Context, path, lastEventID string) (*SSEConnection, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -63,12 +105,15 @@ func (c *SSEClient) Connect(ctx context.Context, path string) (*SSEConnection, e
 
 	connCtx, cancel := context.WithCancel(ctx)
 	conn := &SSEConnection{
-		resp:    resp,
-		scanner: bufio.NewScanner(resp.Body),
-		eventCh: make(chan SSEEvent, 10),
-		errCh:   make(chan error, 1),
-		ctx:     connCtx,
-		cancel:  cancel,
+		client:      c,
+		path:        path,
+		resp:        resp,
+		scanner:     bufio.NewScanner(resp.Body),
+		eventCh:     make(chan SSEEvent, 100),
+		errCh:       make(chan error, 1),
+		ctx:         connCtx,
+		cancel:      cancel,
+		lastEventID: lastEventID,
 	}
 
 	// バックグラウンドでイベントを読み取る
@@ -82,7 +127,7 @@ func (conn *SSEConnection) readEvents() {
 	defer close(conn.eventCh)
 	defer close(conn.errCh)
 
-	var eventType string
+	var eventID, eventType string
 	var eventData strings.Builder
 
 	for conn.scanner.Scan() {
@@ -98,24 +143,42 @@ func (conn *SSEConnection) readEvents() {
 			// 空行はイベントの終了を示す
 			if eventData.Len() > 0 {
 				event := SSEEvent{
+					ID:   eventID,
 					Type: eventType,
 					Data: strings.TrimSpace(eventData.String()),
 				}
+				if eventID != "" {
+					conn.mu.Lock()
+					conn.lastEventID = eventID
+					conn.mu.Unlock()
+				}
 				select {
 				case conn.eventCh <- event:
 				case <-conn.ctx.Done():
 					return
 				}
 			}
+			eventID = ""
 			eventType = ""
 			eventData.Reset()
 			continue
 		}
 
-		if strings.HasPrefix(line, "event:") {
+		switch {
+		case strings.HasPrefix(line, ":"):
+			// コメント行（keep-alive）は無視する
+		case strings.HasPrefix(line, "id:"):
+			eventID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
 			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-		} else if strings.HasPrefix(line, "data:") {
+		case strings.HasPrefix(line, "data:"):
 			eventData.WriteString(strings.TrimPrefix(line, "data:"))
+		case strings.HasPrefix(line, "retry:"):
+			if ms, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(line, "retry:")) + "ms"); err == nil {
+				conn.mu.Lock()
+				conn.retry = ms
+				conn.mu.Unlock()
+			}
 		}
 	}
 
@@ -143,28 +206,86 @@ func (conn *SSEConnection) Close() {
 	conn.resp.Body.Close()
 }
 
+// LastEventID は最後に受信したイベントIDを返す。サーバーがIDを送っていなければ空になる
+func (conn *SSEConnection) LastEventID() string {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.lastEventID
+}
+
+// Retry はサーバーが retry: 行で指定した再接続間隔を返す。指定がなければ0になる
+func (conn *SSEConnection) Retry() time.Duration {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.retry
+}
+
+// Reconnect は接続を閉じ、最後に受信したイベントIDを Last-Event-ID として同じパスに再接続する
+// 切断中に送られたイベントの再送はサーバー側の対応に依存する
+func (conn *SSEConnection) Reconnect(ctx context.Context) (*SSEConnection, error) {
+	conn.Close()
+	return conn.client.ConnectWithLastEventID(ctx, conn.path, conn.LastEventID())
+}
+
 // WaitForEvent は指定されたタイプのイベントを待つ
 func (conn *SSEConnection) WaitForEvent(eventType string, timeout time.Duration) (*SSEEvent, error) {
+	event, err := conn.WaitForMatch(EventTypes(eventType), timeout)
+	if err != nil && strings.HasPrefix(err.Error(), "timeout") {
+		return nil, fmt.Errorf("timeout waiting for event type: %s", eventType)
+	}
+	return event, err
+}
+
+// WaitForMatch は条件に合うイベントを待つ。条件に合わないイベントは読み捨てる
+func (conn *SSEConnection) WaitForMatch(filter SSEEventFilter, timeout time.Duration) (*SSEEvent, error) {
+	events, err := conn.Collect(filter, 1, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// Collect は条件に合うイベントを受信順に n 件集める
+// タイムアウトや切断の場合は、それまでに集めたイベントとエラーを返す
+func (conn *SSEConnection) Collect(filter SSEEventFilter, n int, timeout time.Duration) ([]SSEEvent, error) {
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
-	for {
+	// errCh は読み取り終了時に閉じられるので、閉じた後は eventCh の終了を待つ
+	errCh := conn.errCh
+	var events []SSEEvent
+	for len(events) < n {
 		select {
 		case event, ok := <-conn.eventCh:
 			if !ok {
-				return nil, fmt.Errorf("connection closed")
+				return events, fmt.Errorf("connection closed after %d of %d events", len(events), n)
 			}
-			if event.Type == eventType {
-				return &event, nil
+			if filter == nil || filter(event) {
+				events = append(events, event)
+			}
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
 			}
-		case err := <-conn.errCh:
-			return nil, err
+			return events, err
 		case <-timer.C:
-			return nil, fmt.Errorf("timeout waiting for event type: %s", eventType)
+			return events, fmt.Errorf("timeout after %d of %d events", len(events), n)
 		case <-conn.ctx.Done():
-			return nil, conn.ctx.Err()
+			return events, conn.ctx.Err()
 		}
 	}
+	return events, nil
+}
+
+// ExpectNone は条件に合うイベントが期間中に届かないことを確かめる
+// 届いた場合はそのイベントを返す
+func (conn *SSEConnection) ExpectNone(filter SSEEventFilter, within time.Duration) *SSEEvent {
+	event, err := conn.WaitForMatch(filter, within)
+	if err != nil {
+		return nil
+	}
+	return event
 }
 
 // WaitForMessageEvent はメッセージイベントを待ち、JSONをパースする